@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"qcc_plus/internal/client"
+	"qcc_plus/internal/logging"
 	"qcc_plus/internal/proxy"
 	"qcc_plus/internal/store"
 	"qcc_plus/internal/version"
@@ -46,6 +47,10 @@ func buildLocalURL(listenAddr string) string {
 
 func main() {
 	if len(os.Args) > 1 && os.Args[1] == "proxy" {
+		// 结构化日志接管标准库 log 输出，LOG_FORMAT/LOG_LEVEL 控制格式与默认级别
+		logger := logging.New(logging.ConfigFromEnv())
+		logging.SetDefault(logger)
+
 		info := version.GetVersionInfo()
 		log.Printf("qcc_plus version: %s (commit=%s, build_utc=%s, build_bj=%s, go=%s)", info.Version, info.GitCommit, info.BuildDate, info.BuildDateBeijing, info.GoVersion)
 
@@ -106,6 +111,7 @@ func main() {
 			WithAdminKey(adminKey).
 			WithDefaultAccount(defaultAccountName, defaultProxyKey).
 			WithTransport(nil).
+			WithLogger(logger).
 			WithEnv().
 			Build()
 		if err != nil {
@@ -0,0 +1,103 @@
+import {
+  BarElement,
+  CategoryScale,
+  Chart as ChartJS,
+  type ChartDataset,
+  type ChartOptions,
+  Legend,
+  LinearScale,
+  Tooltip,
+} from 'chart.js'
+import { Bar } from 'react-chartjs-2'
+import type { MetricsPoint } from '../types'
+import { useChartColors } from '../hooks/useChartColors'
+import { parseToDate } from '../utils/date'
+
+ChartJS.register(CategoryScale, LinearScale, BarElement, Tooltip, Legend)
+
+// 错误分类与展示名，顺序即堆叠顺序；客户端取消单独一层，不属于失败
+const CATEGORY_LABELS: [string, string][] = [
+  ['overloaded', '过载 529'],
+  ['rate_limit', '限流 429'],
+  ['auth', '认证'],
+  ['billing', '额度'],
+  ['client', '请求错误'],
+  ['server', '上游 5xx'],
+  ['network', '连接错误'],
+  ['timeout', '超时'],
+  ['unknown', '未知'],
+]
+
+interface FailureBreakdownChartProps {
+  data: MetricsPoint[]
+}
+
+export default function FailureBreakdownChart({ data }: FailureBreakdownChartProps) {
+  const colors = useChartColors()
+  const labels = data.map((p) => {
+    const d = parseToDate(p.timestamp)
+    return d ? d.toLocaleTimeString('zh-CN', { hour: '2-digit', minute: '2-digit' }) : p.timestamp
+  })
+
+  const categoryColors = [colors.danger, colors.warning, ...colors.palette, colors.info]
+  const datasets: ChartDataset<'bar', number[]>[] = CATEGORY_LABELS
+    .filter(([key]) => data.some((p) => (p.errors_by_category?.[key] ?? 0) > 0))
+    .map(([key, label], i) => ({
+      label,
+      data: data.map((p) => p.errors_by_category?.[key] ?? 0),
+      backgroundColor: categoryColors[i % categoryColors.length],
+      stack: 'failures',
+    }))
+  if (data.some((p) => p.requests_canceled > 0)) {
+    datasets.push({
+      label: '客户端取消 499',
+      data: data.map((p) => p.requests_canceled),
+      backgroundColor: colors.textMuted,
+      stack: 'failures',
+    })
+  }
+
+  if (datasets.length === 0) {
+    return <div className="muted">近 24 小时无失败请求</div>
+  }
+
+  const options: ChartOptions<'bar'> = {
+    responsive: true,
+    maintainAspectRatio: false,
+    plugins: {
+      legend: {
+        display: true,
+        labels: { color: colors.textSecondary, usePointStyle: true },
+      },
+      tooltip: {
+        mode: 'index',
+        intersect: false,
+        callbacks: {
+          footer(items) {
+            const p = data[items[0]?.dataIndex ?? 0]
+            return p ? `4xx ${p.failed_4xx} · 5xx ${p.failed_5xx} · 总请求 ${p.requests_total}` : ''
+          },
+        },
+      },
+    },
+    scales: {
+      x: {
+        stacked: true,
+        grid: { display: false },
+        ticks: { color: colors.textMuted, maxRotation: 0, autoSkip: true, maxTicksLimit: 8 },
+      },
+      y: {
+        stacked: true,
+        beginAtZero: true,
+        ticks: { color: colors.textSecondary, precision: 0 },
+        grid: { color: colors.gridColor },
+      },
+    },
+  }
+
+  return (
+    <div className="trend-chart failure-chart">
+      <Bar data={{ labels, datasets }} options={options} />
+    </div>
+  )
+}
@@ -3,6 +3,7 @@ import { createPortal } from 'react-dom'
 import { request } from '../services/api'
 import type { HealthCheckRecord, HealthHistory } from '../types'
 import { formatBeijingTime, parseToDate } from '../utils/date'
+import { errorCategoryLabel } from '../utils/errorCategory'
 import './HealthTimeline.css'
 
 type RangeKey = '24h'
@@ -57,6 +58,7 @@ function normalizeRecord(nodeId: string, rec: HealthCheckRecord): HealthCheckRec
 		success: rec.success,
 		response_time_ms: typeof rec.response_time_ms === 'number' ? rec.response_time_ms : 0,
 		error_message: rec.error_message || '',
+		error_category: rec.error_category || '',
 		check_method: rec.check_method || 'api',
 	}
 }
@@ -218,6 +220,7 @@ export default function HealthTimeline({ nodeId, refreshKey = 0, latest, shareTo
 				<div className="health-tooltip__row">状态：{status}</div>
 				<div className="health-tooltip__row">耗时：{rec.response_time_ms || 0} ms</div>
 				<div className="health-tooltip__row">方式：{rec.check_method || 'api'}</div>
+				{rec.error_category && <div className="health-tooltip__row">分类：{errorCategoryLabel(rec.error_category)}</div>}
 				{rec.error_message && <div className="health-tooltip__row">错误：{rec.error_message}</div>}
 			</div>
 		)
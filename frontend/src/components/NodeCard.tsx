@@ -1,6 +1,7 @@
 import type { HealthCheckRecord, MonitorNode } from '../types'
 import HealthTimeline from './HealthTimeline'
 import Tooltip from './Tooltip'
+import { errorCategoryLabel } from '../utils/errorCategory'
 import { useNodeMetrics } from '../contexts/NodeMetricsContext'
 import './NodeCard.css'
 
@@ -18,6 +19,7 @@ export default function NodeCard({ node, historyRefreshKey, healthEvent, shareTo
   const totalReq = Number(node.traffic?.total_requests ?? 0)
   const failedReq = Number(node.traffic?.failed_requests ?? 0)
   const lastError = (node.last_error || node.health?.last_ping_err || '').trim()
+  const errorCategory = errorCategoryLabel(node.last_error_category)
   const healthLabel: Record<string, string> = { up: '在线', down: '离线' }
   const rawHealthStatus = node.health?.status || 'down'
   const healthStatus = rawHealthStatus === 'up' ? 'up' : 'down'
@@ -37,7 +39,7 @@ export default function NodeCard({ node, historyRefreshKey, healthEvent, shareTo
     <div className={cardClass}>
       {lastError && (
         <div className="node-card__error-badge">
-          <Tooltip content={lastError} trigger="both" maxWidth="300px">
+          <Tooltip content={errorCategory ? `[${errorCategory}] ${lastError}` : lastError} trigger="both" maxWidth="300px">
             <span className="node-card__error-icon" role="img" aria-label="错误">⚠️</span>
           </Tooltip>
         </div>
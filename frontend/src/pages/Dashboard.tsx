@@ -425,19 +425,20 @@ export default function Dashboard() {
                 <th>成功率</th>
                 <th>输出速率</th>
                 <th>Tokens</th>
+                <th>缓存命中率</th>
                 <th>操作</th>
               </tr>
             </thead>
             <tbody>
               {loading ? (
                 <tr>
-                  <td colSpan={7}>
+                  <td colSpan={8}>
                     <div className="skeleton" style={{ height: 18 }} />
                   </td>
                 </tr>
               ) : nodes.length === 0 ? (
                 <tr>
-                  <td colSpan={7}>暂无节点</td>
+                  <td colSpan={8}>暂无节点</td>
                 </tr>
               ) : (
                 nodes
@@ -464,6 +465,7 @@ export default function Dashboard() {
                         </td>
                         <td>{formatBps(bps)}</td>
                         <td>{formatNumber(tokens)}</td>
+                        <td>{n.cache_hit_ratio ? `${(n.cache_hit_ratio * 100).toFixed(1)}%` : '-'}</td>
                         <td>
                           <div className="table-actions">
                             <button className="btn ghost" type="button" onClick={() => handleActivate(n.id)} disabled={n.disabled}>
@@ -1,6 +1,7 @@
 import { useCallback, useEffect, useMemo, useRef, useState } from 'react'
 import { useParams } from 'react-router-dom'
 import Card from '../components/Card'
+import FailureBreakdownChart from '../components/FailureBreakdownChart'
 import NodeCard from '../components/NodeCard'
 import Toast from '../components/Toast'
 import { useAuth } from '../hooks/useAuth'
@@ -12,6 +13,7 @@ import type {
   Account,
   CreateMonitorShareRequest,
   HealthCheckRecord,
+  MetricsPoint,
   MonitorDashboard,
   MonitorNode,
   MonitorShare,
@@ -43,6 +45,9 @@ export default function Monitor({ shared = false }: MonitorProps) {
   const [expireIn, setExpireIn] = useState<CreateMonitorShareRequest['expire_in']>('24h')
   const [historyRefreshKey, setHistoryRefreshKey] = useState(0)
   const [healthEvents, setHealthEvents] = useState<Record<string, HealthCheckRecord>>({})
+  const [failureSeries, setFailureSeries] = useState<MetricsPoint[]>([])
+  const [modelFilter, setModelFilter] = useState('')
+  const [modelSeries, setModelSeries] = useState<MetricsPoint[]>([])
   const lastNodeIdsRef = useRef('')
 
   const wsAccountId = shared ? undefined : accountId || undefined
@@ -97,6 +102,37 @@ export default function Monitor({ shared = false }: MonitorProps) {
     [accountId, shareToken, shared],
   )
 
+  const fetchFailureSeries = useCallback(async () => {
+    if (shared || !accountId) return
+    try {
+      const from = new Date(Date.now() - 24 * 60 * 60 * 1000).toISOString()
+      const [res, grouped] = await Promise.all([
+        api.getAccountMetrics(accountId, { granularity: 'hour', from, model_id: modelFilter || undefined }),
+        api.getAccountMetrics(accountId, { granularity: 'hour', from, group_by: 'model', limit: 1000 }),
+      ])
+      setFailureSeries(res.data || [])
+      setModelSeries(grouped.data || [])
+    } catch (err) {
+      setFailureSeries([])
+      setModelSeries([])
+    }
+  }, [accountId, modelFilter, shared])
+
+  // 近 24 小时按模型汇总，模型名为空的历史记录归入“未知”
+  const modelSummary = useMemo(() => {
+    const byModel = new Map<string, { requests: number; failed: number }>()
+    modelSeries.forEach((p) => {
+      const key = p.model_id || ''
+      const cur = byModel.get(key) || { requests: 0, failed: 0 }
+      cur.requests += p.requests_total
+      cur.failed += p.requests_failed
+      byModel.set(key, cur)
+    })
+    return Array.from(byModel.entries())
+      .map(([model, v]) => ({ model, ...v }))
+      .sort((a, b) => b.requests - a.requests)
+  }, [modelSeries])
+
   const loadShares = useCallback(async () => {
     if (shared || !isAdmin || !accountId) return
     setShareLoading(true)
@@ -134,10 +170,18 @@ export default function Monitor({ shared = false }: MonitorProps) {
     loadShares()
   }, [loadShares])
 
+  useEffect(() => {
+    fetchFailureSeries()
+  }, [fetchFailureSeries])
+
   useEffect(() => {
     setHealthEvents({})
   }, [accountId, shareToken])
 
+  useEffect(() => {
+    setModelFilter('')
+  }, [accountId])
+
   useEffect(() => {
     if (!lastMessage) return
 
@@ -151,6 +195,7 @@ export default function Monitor({ shared = false }: MonitorProps) {
           success: payload.success,
           response_time_ms: payload.response_time_ms ?? 0,
           error_message: payload.error_message || '',
+          error_category: payload.error_category || '',
           check_method: payload.check_method || 'api',
         },
       }))
@@ -408,6 +453,57 @@ export default function Monitor({ shared = false }: MonitorProps) {
         )}
       </Card>
 
+      {!shared && (
+        <Card
+          title="失败分布"
+          extra={
+            <div className="monitor-header-actions">
+              <select value={modelFilter} onChange={(e) => setModelFilter(e.target.value)}>
+                <option value="">全部模型</option>
+                {modelSummary
+                  .filter((m) => m.model)
+                  .map((m) => (
+                    <option key={m.model} value={m.model}>
+                      {m.model}
+                    </option>
+                  ))}
+              </select>
+              <div className="badge gray">近 24h · 按小时 · 按错误分类堆叠</div>
+            </div>
+          }
+        >
+          <FailureBreakdownChart data={failureSeries} />
+          {modelSummary.length > 0 && (
+            <div className="table-wrapper">
+              <table className="model-summary-table">
+                <thead>
+                  <tr>
+                    <th>模型</th>
+                    <th>请求数</th>
+                    <th>失败数</th>
+                    <th>失败率</th>
+                  </tr>
+                </thead>
+                <tbody>
+                  {modelSummary.map((m) => (
+                    <tr
+                      key={m.model || 'unknown'}
+                      className={m.model && m.model === modelFilter ? 'active' : undefined}
+                      onClick={() => m.model && setModelFilter(m.model === modelFilter ? '' : m.model)}
+                    >
+                      <td>{m.model || '未知'}</td>
+                      <td>{m.requests.toLocaleString('en-US')}</td>
+                      <td>{m.failed.toLocaleString('en-US')}</td>
+                      <td>{m.requests > 0 ? `${((m.failed / m.requests) * 100).toFixed(1)}%` : '-'}</td>
+                    </tr>
+                  ))}
+                </tbody>
+              </table>
+            </div>
+          )}
+        </Card>
+      )}
+
       <Card title="节点实时状态" extra={<div className="badge gray">每 30 秒自动刷新 · WebSocket 增量更新</div>}>
         {loading ? (
           <div className="nodes-grid">
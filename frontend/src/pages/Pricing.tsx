@@ -19,6 +19,8 @@ export default function Pricing() {
     model_name: '',
     input_price_mtok: 0,
     output_price_mtok: 0,
+    cache_write_price_mtok: 0,
+    cache_read_price_mtok: 0,
     is_active: true,
   })
 
@@ -49,6 +51,8 @@ export default function Pricing() {
       model_name: '',
       input_price_mtok: 0,
       output_price_mtok: 0,
+      cache_write_price_mtok: 0,
+      cache_read_price_mtok: 0,
       is_active: true,
     })
     setEditingId(null)
@@ -61,6 +65,8 @@ export default function Pricing() {
       model_name: pricing.model_name,
       input_price_mtok: pricing.input_price_mtok,
       output_price_mtok: pricing.output_price_mtok,
+      cache_write_price_mtok: pricing.cache_write_price_mtok,
+      cache_read_price_mtok: pricing.cache_read_price_mtok,
       is_active: pricing.is_active,
     })
     setEditingId(pricing.model_id)
@@ -169,6 +175,28 @@ export default function Pricing() {
                   onChange={(e) => setFormData({ ...formData, output_price_mtok: parseFloat(e.target.value) || 0 })}
                 />
               </label>
+              <label>
+                <span className="label-title">缓存写入价格 ($/MTok)</span>
+                <input
+                  type="number"
+                  step="0.01"
+                  min="0"
+                  placeholder="默认输入价格 × 1.25"
+                  value={formData.cache_write_price_mtok}
+                  onChange={(e) => setFormData({ ...formData, cache_write_price_mtok: parseFloat(e.target.value) || 0 })}
+                />
+              </label>
+              <label>
+                <span className="label-title">缓存读取价格 ($/MTok)</span>
+                <input
+                  type="number"
+                  step="0.01"
+                  min="0"
+                  placeholder="默认输入价格 × 0.1"
+                  value={formData.cache_read_price_mtok}
+                  onChange={(e) => setFormData({ ...formData, cache_read_price_mtok: parseFloat(e.target.value) || 0 })}
+                />
+              </label>
               <label className="checkbox-label">
                 <input
                   type="checkbox"
@@ -201,6 +229,8 @@ export default function Pricing() {
                   <th>模型</th>
                   <th>输入价格</th>
                   <th>输出价格</th>
+                  <th>缓存写入</th>
+                  <th>缓存读取</th>
                   <th>状态</th>
                   <th>操作</th>
                 </tr>
@@ -216,6 +246,8 @@ export default function Pricing() {
                     </td>
                     <td className="price-cell">{formatPrice(pricing.input_price_mtok)}/MTok</td>
                     <td className="price-cell">{formatPrice(pricing.output_price_mtok)}/MTok</td>
+                    <td className="price-cell">{formatPrice(pricing.cache_write_price_mtok)}/MTok</td>
+                    <td className="price-cell">{formatPrice(pricing.cache_read_price_mtok)}/MTok</td>
                     <td>
                       <span className={`status-badge ${pricing.is_active ? 'active' : 'inactive'}`}>
                         {pricing.is_active ? '启用' : '禁用'}
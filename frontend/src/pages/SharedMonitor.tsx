@@ -64,6 +64,7 @@ export default function SharedMonitor() {
           success: payload.success,
           response_time_ms: payload.response_time_ms ?? 0,
           error_message: payload.error_message || '',
+          error_category: payload.error_category || '',
           check_method: payload.check_method || 'api',
         },
       }))
@@ -16,9 +16,22 @@ import type {
   HealthHistory,
   ClaudeConfigTemplate,
   ModelPricing,
+  PricingRule,
+  UnpricedModel,
+  RepriceResult,
+  NodeCost,
+  NodePriceOverride,
+  Statement,
+  BillingAdjustment,
   UsageLog,
   UsageSummary,
   UsageQueryParams,
+  RequestLog,
+  RequestLogQueryParams,
+  RequestCapture,
+  CaptureConfig,
+  MetricsPoint,
+  MetricsQueryParams,
 } from '../types'
 
 const defaultHeaders = { 'Content-Type': 'application/json' }
@@ -456,6 +469,61 @@ async function deletePricing(modelId: string): Promise<void> {
   })
 }
 
+async function getPricingRules(modelId = ''): Promise<PricingRule[]> {
+  const url = modelId ? `/api/pricing/rules?model_id=${encodeURIComponent(modelId)}` : '/api/pricing/rules'
+  const data = await request<{ rules: PricingRule[] }>(url)
+  return data.rules || []
+}
+
+async function savePricingRule(rule: Partial<PricingRule>): Promise<string> {
+  const data = await request<{ id: string }>('/api/pricing/rules', {
+    method: 'POST',
+    headers: defaultHeaders,
+    body: JSON.stringify(rule),
+  })
+  return data.id
+}
+
+async function deletePricingRule(id: string): Promise<void> {
+  await request(`/api/pricing/rules?id=${encodeURIComponent(id)}`, {
+    method: 'DELETE',
+  })
+}
+
+async function getUnpricedModels(): Promise<UnpricedModel[]> {
+  const data = await request<{ models: UnpricedModel[] }>('/api/pricing/unpriced')
+  return data.models || []
+}
+
+async function repriceUsage(params: { model_id?: string; from?: string; to?: string; include_priced?: boolean } = {}): Promise<RepriceResult> {
+  return request<RepriceResult>('/api/pricing/reprice', {
+    method: 'POST',
+    headers: defaultHeaders,
+    body: JSON.stringify(params),
+  })
+}
+
+// 节点成本 API
+async function getNodeCosts(accountId = ''): Promise<{ strategy: string; nodes: NodeCost[] }> {
+  const url = accountId ? `/admin/api/node-costs?account_id=${encodeURIComponent(accountId)}` : '/admin/api/node-costs'
+  const data = await request<{ strategy: string; nodes: NodeCost[] }>(url)
+  return { strategy: data.strategy, nodes: data.nodes || [] }
+}
+
+async function saveNodeCost(nodeId: string, cfg: { cost_multiplier: number; overrides: NodePriceOverride[] }): Promise<NodeCost> {
+  return request<NodeCost>(`/admin/api/node-costs/${encodeURIComponent(nodeId)}`, {
+    method: 'PUT',
+    headers: defaultHeaders,
+    body: JSON.stringify(cfg),
+  })
+}
+
+async function deleteNodeCost(nodeId: string): Promise<NodeCost> {
+  return request<NodeCost>(`/admin/api/node-costs/${encodeURIComponent(nodeId)}`, {
+    method: 'DELETE',
+  })
+}
+
 // 使用统计 API
 async function getUsageLogs(params: UsageQueryParams = {}): Promise<{ logs: UsageLog[]; count: number }> {
   const search = new URLSearchParams()
@@ -471,6 +539,110 @@ async function getUsageLogs(params: UsageQueryParams = {}): Promise<{ logs: Usag
   return request<{ logs: UsageLog[]; count: number }>(url)
 }
 
+// 请求审计日志 API
+async function getRequestLogs(params: RequestLogQueryParams = {}): Promise<{ logs: RequestLog[]; total: number; limit: number; offset: number }> {
+  const search = new URLSearchParams()
+  Object.entries(params).forEach(([key, value]) => {
+    if (value !== undefined && value !== '' && value !== false) search.set(key, String(value))
+  })
+  const qs = search.toString()
+  const url = qs ? `/api/request-logs?${qs}` : '/api/request-logs'
+  return request<{ logs: RequestLog[]; total: number; limit: number; offset: number }>(url)
+}
+
+// 账号监控指标（按时间桶汇总，可按模型过滤或分组）
+async function getAccountMetrics(accountId: string, params: MetricsQueryParams = {}): Promise<{ data: MetricsPoint[]; granularity: string; from: string; to: string }> {
+  const search = new URLSearchParams()
+  Object.entries(params).forEach(([key, value]) => {
+    if (value !== undefined && value !== '') search.set(key, String(value))
+  })
+  const qs = search.toString()
+  const base = `/api/accounts/${encodeURIComponent(accountId)}/metrics`
+  return request<{ data: MetricsPoint[]; granularity: string; from: string; to: string }>(qs ? `${base}?${qs}` : base)
+}
+
+// 数据导出：返回下载链接，由浏览器携带会话 Cookie 直接下载
+function getExportUrl(
+  kind: 'usage' | 'metrics' | 'health',
+  params: { format?: 'csv' | 'ndjson' | 'parquet'; account_id?: string; node_id?: string; model_id?: string; granularity?: string; from?: string; to?: string } = {},
+): string {
+  const search = new URLSearchParams()
+  Object.entries(params).forEach(([key, value]) => {
+    if (value) search.set(key, value)
+  })
+  const qs = search.toString()
+  return qs ? `/api/export/${kind}?${qs}` : `/api/export/${kind}`
+}
+
+// 月度账单 API
+async function getStatements(params: { account_id?: string; period?: string } = {}): Promise<Statement[]> {
+  const search = new URLSearchParams()
+  if (params.account_id) search.set('account_id', params.account_id)
+  if (params.period) search.set('period', params.period)
+  const qs = search.toString()
+  const data = await request<{ statements: Statement[] }>(qs ? `/api/statements?${qs}` : '/api/statements')
+  return data.statements || []
+}
+
+async function getStatement(id: string): Promise<Statement> {
+  return request<Statement>(`/api/statements/${encodeURIComponent(id)}`)
+}
+
+async function generateStatement(accountId: string, period = ''): Promise<Statement> {
+  return request<Statement>('/api/statements', {
+    method: 'POST',
+    headers: defaultHeaders,
+    body: JSON.stringify({ account_id: accountId, period }),
+  })
+}
+
+function getStatementUrl(id: string, format: 'csv' | 'html'): string {
+  return `/api/statements/${encodeURIComponent(id)}?format=${format}`
+}
+
+async function getBillingAdjustments(params: { account_id?: string; period?: string } = {}): Promise<BillingAdjustment[]> {
+  const search = new URLSearchParams()
+  if (params.account_id) search.set('account_id', params.account_id)
+  if (params.period) search.set('period', params.period)
+  const qs = search.toString()
+  const data = await request<{ adjustments: BillingAdjustment[] }>(qs ? `/api/statements/adjustments?${qs}` : '/api/statements/adjustments')
+  return data.adjustments || []
+}
+
+async function createBillingAdjustment(adj: Omit<BillingAdjustment, 'id' | 'created_at'>): Promise<string> {
+  const data = await request<{ id: string }>('/api/statements/adjustments', {
+    method: 'POST',
+    headers: defaultHeaders,
+    body: JSON.stringify(adj),
+  })
+  return data.id
+}
+
+async function deleteBillingAdjustment(id: string): Promise<void> {
+  await request(`/api/statements/adjustments?id=${encodeURIComponent(id)}`, {
+    method: 'DELETE',
+  })
+}
+
+// 调试抓包 API（仅管理员）
+async function getCaptures(accountId?: string, limit = 50, offset = 0): Promise<{ captures: RequestCapture[]; active: Record<string, CaptureConfig> }> {
+  const search = new URLSearchParams({ limit: String(limit), offset: String(offset) })
+  if (accountId) search.set('account_id', accountId)
+  return request<{ captures: RequestCapture[]; active: Record<string, CaptureConfig> }>(`/admin/api/captures?${search.toString()}`)
+}
+
+async function getCapture(id: number): Promise<{ capture: RequestCapture; request_body: string; response_body: string }> {
+  return request<{ capture: RequestCapture; request_body: string; response_body: string }>(`/admin/api/captures/${id}`)
+}
+
+async function replayCapture(id: number, nodeId: string, allowRedacted = false): Promise<{ status: number; duration_ms: number; response_headers: Record<string, string>; response_body: string; truncated: boolean; redacted: boolean }> {
+  return request(`/admin/api/captures/${id}/replay`, {
+    method: 'POST',
+    headers: defaultHeaders,
+    body: JSON.stringify({ node_id: nodeId, allow_redacted: allowRedacted }),
+  })
+}
+
 async function getUsageSummary(params: UsageQueryParams = {}): Promise<UsageSummary | UsageSummary[]> {
   const search = new URLSearchParams()
   if (params.account_id) search.set('account_id', params.account_id)
@@ -568,8 +740,29 @@ export default {
   getPricing,
   savePricing,
   deletePricing,
+  getPricingRules,
+  savePricingRule,
+  deletePricingRule,
+  getUnpricedModels,
+  repriceUsage,
+  getNodeCosts,
+  saveNodeCost,
+  deleteNodeCost,
   getUsageLogs,
   getUsageSummary,
+  getRequestLogs,
+  getExportUrl,
+  getStatements,
+  getStatement,
+  generateStatement,
+  getStatementUrl,
+  getBillingAdjustments,
+  createBillingAdjustment,
+  deleteBillingAdjustment,
+  getAccountMetrics,
+  getCaptures,
+  getCapture,
+  replayCapture,
   cleanupUsageLogs,
   // 环境变量
   getEnvVarCategories,
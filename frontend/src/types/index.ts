@@ -25,6 +25,9 @@ export interface Node {
   stream_dur_ms?: number;
   input_tokens?: number;
   output_tokens?: number;
+  cache_creation_tokens?: number;
+  cache_read_tokens?: number;
+  cache_hit_ratio?: number; // 缓存命中率（0-1）
   last_health_check_at?: string;
   last_ping_ms?: number;
   last_ping_error?: string;
@@ -121,6 +124,32 @@ export interface TrendPoint {
   avg_time: number;
 }
 
+export interface MetricsPoint {
+  timestamp: string;
+  model_id?: string;
+  requests_total: number;
+  requests_success: number;
+  requests_failed: number;
+  requests_canceled: number;
+  failed_4xx: number;
+  failed_5xx: number;
+  errors_by_category: Record<string, number>;
+  avg_response_time_ms: number;
+  p50_response_time_ms?: number;
+  p95_response_time_ms?: number;
+  p99_response_time_ms?: number;
+}
+
+export interface MetricsQueryParams {
+  granularity?: 'raw' | 'hour' | 'day' | 'month';
+  from?: string;
+  to?: string;
+  model_id?: string;
+  group_by?: 'model';
+  limit?: number;
+  offset?: number;
+}
+
 export interface ProxySummary {
   success_rate: number;
   avg_response_time: number;
@@ -142,6 +171,7 @@ export interface HealthCheckRecord {
   success: boolean;
   response_time_ms: number;
   error_message: string;
+  error_category?: string;
   check_method: string;
 }
 
@@ -163,6 +193,7 @@ export interface MonitorNode {
   circuit_open: boolean;
   disabled: boolean;
   last_error?: string;
+  last_error_category?: string;
   traffic: ProxySummary;
   health: HealthSummary;
   trend_24h?: TrendPoint[];
@@ -220,6 +251,7 @@ export type WSMessage =
         success: boolean;
         response_time_ms: number;
         error_message?: string;
+        error_category?: string;
         check_method?: string;
       };
     };
@@ -231,11 +263,72 @@ export interface ModelPricing {
   model_name: string;
   input_price_mtok: number;  // 输入价格 $/MTok
   output_price_mtok: number; // 输出价格 $/MTok
+  cache_write_price_mtok: number; // 缓存写入价格 $/MTok
+  cache_read_price_mtok: number;  // 缓存读取价格 $/MTok
+  is_active: boolean;
+  created_at: string;
+  updated_at: string;
+}
+
+// 定价规则（分段/生效期/倍率）
+export interface PricingRule {
+  id: string;
+  model_id: string;
+  name: string;
+  input_threshold: number; // 输入 tokens 总数超过该值时命中，0 表示不限
+  input_price_mtok: number;
+  output_price_mtok: number;
+  cache_write_price_mtok: number;
+  cache_read_price_mtok: number;
+  multiplier: number;
+  priority: number;
+  effective_from?: string;
+  effective_to?: string;
   is_active: boolean;
   created_at: string;
   updated_at: string;
 }
 
+// 使用日志中未定价的模型
+export interface UnpricedModel {
+  model_id: string;
+  requests: number;
+  input_tokens: number;
+  output_tokens: number;
+  cache_creation_tokens: number;
+  cache_read_tokens: number;
+  last_seen: string;
+  matched_pricing?: string; // 当前可命中的定价条目，非空时可重新计费
+}
+
+// 节点成本价格覆盖（model_id 支持与定价相同的 glob/正则模式）
+export interface NodePriceOverride {
+  model_id: string;
+  input_price_mtok: number;
+  output_price_mtok: number;
+  cache_write_price_mtok: number;
+  cache_read_price_mtok: number;
+}
+
+// 节点成本配置
+export interface NodeCost {
+  node_id: string;
+  node_name: string;
+  account_id: string;
+  weight: number;
+  cost_multiplier: number;
+  overrides: NodePriceOverride[];
+  cost_factor: number; // 实际成本相对官方价格的系数，cost 策略按此排序
+  list_cost_usd: number;
+  actual_cost_usd: number;
+}
+
+// 重新计费结果
+export interface RepriceResult {
+  updated: number;
+  still_unpriced: number;
+}
+
 // 使用日志记录
 export interface UsageLog {
   id: number;
@@ -244,8 +337,16 @@ export interface UsageLog {
   model_id: string;
   input_tokens: number;
   output_tokens: number;
+  cache_creation_tokens: number;
+  cache_read_tokens: number;
   cost_usd: number;
+  actual_cost_usd: number; // 按节点成本倍率/价格覆盖折算的实际成本
   request_id?: string;
+  upstream_request_id?: string;
+  attempt_trace?: string; // 节点尝试轨迹（JSON 数组）
+  pricing_rule_id?: string; // 计费时命中的定价规则
+  unpriced?: boolean; // 计费时未找到模型价格
+  key_hint?: string; // 代理 Key 脱敏提示
   success: boolean;
   created_at: string;
 }
@@ -259,7 +360,55 @@ export interface UsageSummary {
   success_requests: number;
   total_input_tokens: number;
   total_output_tokens: number;
+  total_cache_creation_tokens: number;
+  total_cache_read_tokens: number;
   total_cost_usd: number;
+  total_actual_cost_usd: number;
+}
+
+// 账单明细行（按模型、节点或 API Key 汇总）
+export interface StatementLine {
+  key: string;
+  label?: string;
+  requests: number;
+  input_tokens: number;
+  output_tokens: number;
+  cache_creation_tokens: number;
+  cache_read_tokens: number;
+  cost_usd: number;
+}
+
+// 账单调整与抵扣
+export interface BillingAdjustment {
+  id: string;
+  account_id: string;
+  period: string; // YYYY-MM
+  kind: 'adjustment' | 'credit';
+  amount_usd: number;
+  description: string;
+  created_at: string;
+}
+
+// 月度账单快照（列表接口不含明细）
+export interface Statement {
+  id: string;
+  account_id: string;
+  account_name: string;
+  period: string;
+  version: number;
+  timezone: string;
+  period_start: string;
+  period_end: string;
+  totals: StatementLine;
+  adjustments_usd: number;
+  credits_usd: number;
+  amount_due_usd: number;
+  generated_by: string;
+  created_at: string;
+  by_model?: StatementLine[];
+  by_node?: StatementLine[];
+  by_api_key?: StatementLine[];
+  adjustments?: BillingAdjustment[];
 }
 
 // 使用日志查询参数
@@ -273,3 +422,72 @@ export interface UsageQueryParams {
   offset?: number;
   group_by?: 'model' | 'node';
 }
+
+// 请求审计日志
+export interface RequestLog {
+  id: number;
+  request_id: string;
+  account_id: string;
+  key_hint: string;
+  client_ip: string;
+  user_agent: string;
+  session_id: string; // Claude Code 会话 ID
+  model_id: string;
+  stream: boolean;
+  status: number;
+  error_type: string;
+  node_id: string;
+  attempts: number;
+  attempt_trace?: string; // 节点尝试轨迹（JSON 数组）
+  ttfb_ms: number;
+  duration_ms: number;
+  input_tokens: number;
+  output_tokens: number;
+  cache_creation_tokens: number;
+  cache_read_tokens: number;
+  created_at: string;
+}
+
+// 调试抓包
+export interface RequestCapture {
+  id: number;
+  request_id: string;
+  account_id: string;
+  node_id: string;
+  model_id: string;
+  method: string;
+  path: string;
+  status: number;
+  stream: boolean;
+  request_headers?: string; // JSON 对象
+  response_headers?: string; // JSON 对象
+  request_bytes: number;
+  response_bytes: number;
+  truncated: boolean;
+  created_at: string;
+}
+
+// 账号级抓包模式（settings: proxy.capture，scope=account）
+export interface CaptureConfig {
+  until: string;
+  model?: string;
+  redact_patterns?: string[];
+  redact_fields?: string[];
+  max_body_bytes?: number;
+}
+
+export interface RequestLogQueryParams {
+  account_id?: string;
+  node_id?: string;
+  model_id?: string;
+  session_id?: string;
+  request_id?: string;
+  client_ip?: string;
+  error_type?: string;
+  status?: number;
+  failed?: boolean;
+  from?: string;
+  to?: string;
+  limit?: number;
+  offset?: number;
+}
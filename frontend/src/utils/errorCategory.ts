@@ -0,0 +1,17 @@
+// 上游错误分类（与后端 ErrorCategory* 常量对应）
+const ERROR_CATEGORY_LABELS: Record<string, string> = {
+  auth: '认证失败',
+  billing: '余额不足',
+  rate_limit: '限流',
+  overloaded: '过载',
+  client: '请求错误',
+  server: '上游错误',
+  network: '网络错误',
+  timeout: '超时',
+  unknown: '未知错误',
+}
+
+export function errorCategoryLabel(category?: string | null): string {
+  if (!category) return ''
+  return ERROR_CATEGORY_LABELS[category] || category
+}
@@ -0,0 +1,122 @@
+// Package batcher 提供有界队列与批量发送协程，供审计日志、指标推送等异步写入复用。
+//
+// 入队不阻塞，队列满时丢弃并计数；发送协程按批量大小或刷新间隔调用 flush，
+// 关闭时先清空队列再返回。flush 由单个协程串行调用，失败重试等策略由调用方实现。
+package batcher
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Config 队列与批量参数。
+type Config struct {
+	QueueSize     int
+	BatchSize     int
+	FlushInterval time.Duration
+}
+
+const (
+	defaultQueueSize     = 10000
+	defaultBatchSize     = 500
+	defaultFlushInterval = 5 * time.Second
+)
+
+// Batcher 有界队列与批量发送协程。
+type Batcher[T any] struct {
+	cfg     Config
+	queue   chan T
+	flush   func([]T)
+	dropped atomic.Int64
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// New 启动发送协程。刷新间隔到达时即使批次为空也会调用 flush；
+// flush 收到的切片在返回后会被复用，调用方不应保留。
+func New[T any](cfg Config, flush func(batch []T)) *Batcher[T] {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultQueueSize
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = defaultFlushInterval
+	}
+	b := &Batcher[T]{
+		cfg:    cfg,
+		queue:  make(chan T, cfg.QueueSize),
+		flush:  flush,
+		stopCh: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+// Add 非阻塞入队，队列满时丢弃并返回 false。
+func (b *Batcher[T]) Add(v T) bool {
+	select {
+	case b.queue <- v:
+		return true
+	default:
+		b.dropped.Add(1)
+		return false
+	}
+}
+
+// Dropped 返回累计丢弃条数。
+func (b *Batcher[T]) Dropped() int64 { return b.dropped.Load() }
+
+// Len 返回队列中等待发送的条数。
+func (b *Batcher[T]) Len() int { return len(b.queue) }
+
+// Cap 返回队列容量。
+func (b *Batcher[T]) Cap() int { return cap(b.queue) }
+
+// Close 发送队列中剩余的数据后返回，可重复调用。
+func (b *Batcher[T]) Close() {
+	b.stopOnce.Do(func() { close(b.stopCh) })
+	<-b.done
+}
+
+func (b *Batcher[T]) run() {
+	defer close(b.done)
+	ticker := time.NewTicker(b.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]T, 0, b.cfg.BatchSize)
+	add := func(v T) {
+		batch = append(batch, v)
+		if len(batch) >= b.cfg.BatchSize {
+			batch = b.send(batch)
+		}
+	}
+	for {
+		select {
+		case v := <-b.queue:
+			add(v)
+		case <-ticker.C:
+			batch = b.send(batch)
+		case <-b.stopCh:
+			for {
+				select {
+				case v := <-b.queue:
+					add(v)
+				default:
+					b.send(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+func (b *Batcher[T]) send(batch []T) []T {
+	b.flush(batch)
+	return batch[:0]
+}
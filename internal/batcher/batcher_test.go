@@ -0,0 +1,48 @@
+package batcher
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestBatcherFlushesBySizeAndOnClose 测试按批量大小发送、队列满时丢弃与关闭时清空队列
+func TestBatcherFlushesBySizeAndOnClose(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		batches [][]int
+	)
+	release := make(chan struct{})
+	b := New(Config{QueueSize: 2, BatchSize: 2, FlushInterval: time.Hour}, func(batch []int) {
+		if len(batch) == 0 {
+			return
+		}
+		<-release
+		mu.Lock()
+		batches = append(batches, append([]int(nil), batch...))
+		mu.Unlock()
+	})
+
+	// 前两条组成一批并阻塞在 flush 中，随后队列再容纳 2 条。
+	b.Add(1)
+	b.Add(2)
+	deadline := time.Now().Add(2 * time.Second)
+	for b.Len() != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	for i := 3; i <= 6; i++ {
+		b.Add(i)
+	}
+	if b.Dropped() != 2 || b.Len() != 2 || b.Cap() != 2 {
+		t.Fatalf("unexpected queue state dropped=%d len=%d", b.Dropped(), b.Len())
+	}
+
+	close(release)
+	b.Close()
+	b.Close()
+	mu.Lock()
+	defer mu.Unlock()
+	if len(batches) != 2 || len(batches[0]) != 2 || batches[1][0] != 3 || batches[1][1] != 4 {
+		t.Fatalf("unexpected batches %v", batches)
+	}
+}
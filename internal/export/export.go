@@ -0,0 +1,240 @@
+// Package export 将表格数据按行流式写出为 CSV、NDJSON 或 Parquet，
+// 供用量日志、监控指标与健康检查历史的离线导出使用。
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Type 列的数据类型。
+type Type int
+
+const (
+	String Type = iota
+	Int64
+	Float64
+	Bool
+	Timestamp // time.Time，Parquet 中以毫秒时间戳保存
+)
+
+// Column 描述一列的名称与类型。
+type Column struct {
+	Name string
+	Type Type
+}
+
+// Format 导出格式。
+type Format string
+
+const (
+	FormatCSV     Format = "csv"
+	FormatNDJSON  Format = "ndjson"
+	FormatParquet Format = "parquet"
+)
+
+// ParseFormat 解析导出格式，空值默认 CSV；jsonl 视为 NDJSON 的别名。
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "csv":
+		return FormatCSV, nil
+	case "ndjson", "jsonl":
+		return FormatNDJSON, nil
+	case "parquet":
+		return FormatParquet, nil
+	default:
+		return "", fmt.Errorf("unsupported format %q", s)
+	}
+}
+
+// ContentType 返回格式对应的 HTTP Content-Type。
+func (f Format) ContentType() string {
+	switch f {
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	case FormatParquet:
+		return "application/vnd.apache.parquet"
+	default:
+		return "text/csv; charset=utf-8"
+	}
+}
+
+// FileExt 返回格式对应的文件扩展名（不含点）。
+func (f Format) FileExt() string {
+	if f == FormatNDJSON {
+		return "jsonl"
+	}
+	return string(f)
+}
+
+// Writer 按列定义顺序逐行写出数据，Close 负责刷新缓冲并写入文件尾。
+// 行中的值须与列类型匹配：string、int64（兼容 int）、float64、bool、time.Time。
+type Writer interface {
+	Write(row []any) error
+	Close() error
+}
+
+// NewWriter 创建指定格式的写出器。
+func NewWriter(f Format, w io.Writer, cols []Column) (Writer, error) {
+	if len(cols) == 0 {
+		return nil, fmt.Errorf("no columns")
+	}
+	switch f {
+	case FormatCSV:
+		return newCSVWriter(w, cols)
+	case FormatNDJSON:
+		return &ndjsonWriter{w: bufio.NewWriter(w), cols: cols}, nil
+	case FormatParquet:
+		return newParquetWriter(w, cols)
+	default:
+		return nil, fmt.Errorf("unsupported format %q", f)
+	}
+}
+
+func checkRow(cols []Column, row []any) error {
+	if len(row) != len(cols) {
+		return fmt.Errorf("row has %d values, want %d", len(row), len(cols))
+	}
+	return nil
+}
+
+func asInt64(v any) (int64, error) {
+	switch n := v.(type) {
+	case int64:
+		return n, nil
+	case int:
+		return int64(n), nil
+	case int32:
+		return int64(n), nil
+	default:
+		return 0, fmt.Errorf("expected integer, got %T", v)
+	}
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// formatValue 将单元格格式化为文本（CSV 使用）。
+func formatValue(c Column, v any) (string, error) {
+	switch c.Type {
+	case String:
+		s, ok := v.(string)
+		if !ok {
+			return "", fmt.Errorf("column %s: expected string, got %T", c.Name, v)
+		}
+		return s, nil
+	case Int64:
+		n, err := asInt64(v)
+		if err != nil {
+			return "", fmt.Errorf("column %s: %w", c.Name, err)
+		}
+		return strconv.FormatInt(n, 10), nil
+	case Float64:
+		f, ok := v.(float64)
+		if !ok {
+			return "", fmt.Errorf("column %s: expected float64, got %T", c.Name, v)
+		}
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	case Bool:
+		b, ok := v.(bool)
+		if !ok {
+			return "", fmt.Errorf("column %s: expected bool, got %T", c.Name, v)
+		}
+		return strconv.FormatBool(b), nil
+	case Timestamp:
+		t, ok := v.(time.Time)
+		if !ok {
+			return "", fmt.Errorf("column %s: expected time.Time, got %T", c.Name, v)
+		}
+		return formatTime(t), nil
+	default:
+		return "", fmt.Errorf("column %s: unknown type", c.Name)
+	}
+}
+
+type csvWriter struct {
+	w      *csv.Writer
+	cols   []Column
+	record []string
+}
+
+func newCSVWriter(w io.Writer, cols []Column) (*csvWriter, error) {
+	cw := &csvWriter{w: csv.NewWriter(w), cols: cols, record: make([]string, len(cols))}
+	for i, c := range cols {
+		cw.record[i] = c.Name
+	}
+	if err := cw.w.Write(cw.record); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+func (c *csvWriter) Write(row []any) error {
+	if err := checkRow(c.cols, row); err != nil {
+		return err
+	}
+	for i, col := range c.cols {
+		s, err := formatValue(col, row[i])
+		if err != nil {
+			return err
+		}
+		c.record[i] = s
+	}
+	return c.w.Write(c.record)
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// ndjsonWriter 每行一个 JSON 对象，键顺序与列定义一致。
+type ndjsonWriter struct {
+	w    *bufio.Writer
+	cols []Column
+}
+
+func (n *ndjsonWriter) Write(row []any) error {
+	if err := checkRow(n.cols, row); err != nil {
+		return err
+	}
+	n.w.WriteByte('{')
+	for i, col := range n.cols {
+		if i > 0 {
+			n.w.WriteByte(',')
+		}
+		key, _ := json.Marshal(col.Name)
+		n.w.Write(key)
+		n.w.WriteByte(':')
+		// 校验类型后按原值编码，整数与布尔保持 JSON 原生类型，时间统一为 RFC3339 字符串。
+		if _, err := formatValue(col, row[i]); err != nil {
+			return err
+		}
+		val := row[i]
+		if t, ok := val.(time.Time); ok {
+			val = formatTime(t)
+		}
+		b, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Errorf("column %s: %w", col.Name, err)
+		}
+		n.w.Write(b)
+	}
+	n.w.WriteByte('}')
+	_, err := n.w.WriteString("\n")
+	return err
+}
+
+func (n *ndjsonWriter) Close() error {
+	return n.w.Flush()
+}
@@ -0,0 +1,362 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testCols = []Column{
+	{Name: "ts", Type: Timestamp},
+	{Name: "node", Type: String},
+	{Name: "count", Type: Int64},
+	{Name: "cost", Type: Float64},
+	{Name: "ok", Type: Bool},
+}
+
+func testRows() [][]any {
+	ts := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	return [][]any{
+		{ts, "n1", int64(3), 0.25, true},
+		{ts.Add(time.Second), `a,"b"`, 7, 1.5, false},
+	}
+}
+
+func writeAll(t *testing.T, f Format, rows [][]any) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(f, &buf, testCols)
+	if err != nil {
+		t.Fatalf("new writer: %v", err)
+	}
+	for _, row := range rows {
+		if err := w.Write(row); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// TestCSVAndNDJSON 测试文本格式的表头、转义与类型编码
+func TestCSVAndNDJSON(t *testing.T) {
+	got := string(writeAll(t, FormatCSV, testRows()))
+	want := "ts,node,count,cost,ok\n" +
+		"2026-01-02T03:04:05Z,n1,3,0.25,true\n" +
+		"2026-01-02T03:04:06Z,\"a,\"\"b\"\"\",7,1.5,false\n"
+	if got != want {
+		t.Fatalf("csv:\n got %q\nwant %q", got, want)
+	}
+
+	got = string(writeAll(t, FormatNDJSON, testRows()[:1]))
+	if got != `{"ts":"2026-01-02T03:04:05Z","node":"n1","count":3,"cost":0.25,"ok":true}`+"\n" {
+		t.Fatalf("ndjson: %q", got)
+	}
+
+	w, _ := NewWriter(FormatCSV, &bytes.Buffer{}, testCols)
+	if err := w.Write([]any{"x", "n1", int64(1), 0.1, true}); err == nil {
+		t.Fatalf("expected type mismatch rejected")
+	}
+	if f, err := ParseFormat("JSONL"); err != nil || f != FormatNDJSON || f.FileExt() != "jsonl" {
+		t.Fatalf("unexpected format %q %v", f, err)
+	}
+	if _, err := ParseFormat("xlsx"); err == nil {
+		t.Fatalf("expected unsupported format rejected")
+	}
+}
+
+// thriftReader 测试用的 Thrift Compact 解码，结构体解码为 字段ID -> 值。
+type thriftReader struct {
+	b []byte
+	i int
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.b[r.i:])
+	r.i += n
+	return v
+}
+
+func (r *thriftReader) zigzag() int64 {
+	u := r.uvarint()
+	return int64(u>>1) ^ -int64(u&1)
+}
+
+func (r *thriftReader) value(typ byte) any {
+	switch typ {
+	case thriftI32, thriftI64:
+		return r.zigzag()
+	case thriftBinary:
+		n := int(r.uvarint())
+		s := string(r.b[r.i : r.i+n])
+		r.i += n
+		return s
+	case thriftList:
+		h := r.b[r.i]
+		r.i++
+		size := int(h >> 4)
+		if size == 15 {
+			size = int(r.uvarint())
+		}
+		out := make([]any, size)
+		for k := range out {
+			out[k] = r.value(h & 0x0F)
+		}
+		return out
+	case thriftStruct:
+		return r.readStruct()
+	}
+	panic("unexpected thrift type")
+}
+
+func (r *thriftReader) readStruct() map[int]any {
+	out := map[int]any{}
+	last := 0
+	for {
+		h := r.b[r.i]
+		r.i++
+		if h == 0 {
+			return out
+		}
+		id := last + int(h>>4)
+		if h>>4 == 0 {
+			id = int(r.zigzag())
+		}
+		out[id] = r.value(h & 0x0F)
+		last = id
+	}
+}
+
+// TestParquetLayout 测试 Parquet 文件结构：魔数、页脚元数据、行组切分与 PLAIN 编码的数据页
+func TestParquetLayout(t *testing.T) {
+	var rows [][]any
+	for i := 0; i < parquetRowGroupRows+5; i++ {
+		rows = append(rows, testRows()[i%2])
+	}
+	data := writeAll(t, FormatParquet, rows)
+	if !strings.HasPrefix(string(data), "PAR1") || !strings.HasSuffix(string(data), "PAR1") {
+		t.Fatalf("missing magic")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footer := &thriftReader{b: data[len(data)-8-footerLen : len(data)-8]}
+	meta := footer.readStruct()
+	if meta[3].(int64) != int64(len(rows)) {
+		t.Fatalf("unexpected num_rows %v", meta[3])
+	}
+	schema := meta[2].([]any)
+	if len(schema) != len(testCols)+1 || schema[2].(map[int]any)[4] != "node" || schema[1].(map[int]any)[6].(int64) != pqConvertedTimestampMillis {
+		t.Fatalf("unexpected schema %v", schema)
+	}
+	groups := meta[4].([]any)
+	if len(groups) != 2 || groups[1].(map[int]any)[3].(int64) != 5 {
+		t.Fatalf("expected two row groups, got %v", groups)
+	}
+
+	// 读取第二个行组的 node 列：页头后为 4 字节长度前缀的字符串。
+	chunk := groups[1].(map[int]any)[1].([]any)[1].(map[int]any)
+	offset := int(chunk[2].(int64))
+	page := &thriftReader{b: data, i: offset}
+	header := page.readStruct()
+	if header[5].(map[int]any)[1].(int64) != 5 {
+		t.Fatalf("unexpected page header %v", header)
+	}
+	n := int(binary.LittleEndian.Uint32(data[page.i:]))
+	if got := string(data[page.i+4 : page.i+4+n]); got != "n1" {
+		t.Fatalf("unexpected first value %q", got)
+	}
+
+	// 布尔列按位打包：第二个行组从第 10000 行（true）开始交替。
+	boolChunk := groups[1].(map[int]any)[1].([]any)[4].(map[int]any)
+	page = &thriftReader{b: data, i: int(boolChunk[2].(int64))}
+	page.readStruct()
+	if data[page.i] != 0b10101 {
+		t.Fatalf("unexpected packed bools %08b", data[page.i])
+	}
+}
+
+// readParquet 按页脚元数据逐个列块读取 Parquet 文件（即通用读取器的读取路径），
+// 同时校验 parquet.thrift 要求的必填字段、列块字节范围与页大小，返回解码后的全部行。
+func readParquet(t *testing.T, data []byte) [][]any {
+	t.Helper()
+	footerLen := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footerStart := len(data) - 8 - footerLen
+	footer := &thriftReader{b: data[footerStart : len(data)-8]}
+	meta := footer.readStruct()
+	if footer.i != footerLen {
+		t.Fatalf("footer decoded %d of %d bytes", footer.i, footerLen)
+	}
+	if meta[1].(int64) != 1 || meta[6] != parquetCreatedBy {
+		t.Fatalf("unexpected version/created_by %v %v", meta[1], meta[6])
+	}
+	schema := meta[2].([]any)
+	root := schema[0].(map[int]any)
+	if root[4] != "schema" || root[5].(int64) != int64(len(schema)-1) {
+		t.Fatalf("unexpected schema root %v", root)
+	}
+	for _, el := range schema[1:] {
+		if el.(map[int]any)[3].(int64) != pqRequired {
+			t.Fatalf("expected required column %v", el)
+		}
+	}
+
+	var rows [][]any
+	pos := int64(len(parquetMagic))
+	for _, g := range meta[4].([]any) {
+		group := g.(map[int]any)
+		n := int(group[3].(int64))
+		chunks := group[1].([]any)
+		if len(chunks) != len(schema)-1 {
+			t.Fatalf("row group has %d column chunks", len(chunks))
+		}
+		out := make([][]any, n)
+		for i := range out {
+			out[i] = make([]any, len(chunks))
+		}
+		var groupBytes int64
+		for c, ch := range chunks {
+			chunk := ch.(map[int]any)
+			col := chunk[3].(map[int]any)
+			el := schema[c+1].(map[int]any)
+			if chunk[2].(int64) != pos || col[9].(int64) != pos {
+				t.Fatalf("column chunk %d at %v/%v, expected contiguous offset %d", c, chunk[2], col[9], pos)
+			}
+			if col[1] != el[1] || len(col[3].([]any)) != 1 || col[3].([]any)[0] != el[4] ||
+				col[4].(int64) != pqUncompressed || col[5].(int64) != int64(n) || col[6] != col[7] {
+				t.Fatalf("unexpected column meta %v for %v", col, el)
+			}
+			page := &thriftReader{b: data, i: int(pos)}
+			header := page.readStruct()
+			dp := header[5].(map[int]any)
+			size := int(header[3].(int64))
+			if header[1].(int64) != pqDataPage || header[2] != header[3] || dp[1].(int64) != int64(n) || dp[2].(int64) != pqEncodingPlain {
+				t.Fatalf("unexpected page header %v", header)
+			}
+			if int64(page.i+size)-pos != col[7].(int64) {
+				t.Fatalf("column chunk %d size %v, page ends at %d", c, col[7], page.i+size)
+			}
+			values := data[page.i : page.i+size]
+			for r := 0; r < n; r++ {
+				switch el[1].(int64) {
+				case pqBoolean:
+					out[r][c] = values[r/8]&(1<<(r%8)) != 0
+				case pqInt64:
+					v := int64(binary.LittleEndian.Uint64(values))
+					values = values[8:]
+					if el[6] == int64(pqConvertedTimestampMillis) {
+						out[r][c] = time.UnixMilli(v).UTC()
+					} else {
+						out[r][c] = v
+					}
+				case pqDouble:
+					out[r][c] = math.Float64frombits(binary.LittleEndian.Uint64(values))
+					values = values[8:]
+				case pqByteArray:
+					l := int(binary.LittleEndian.Uint32(values))
+					out[r][c] = string(values[4 : 4+l])
+					values = values[4+l:]
+				}
+			}
+			pos += col[7].(int64)
+			groupBytes += col[7].(int64)
+		}
+		if group[2].(int64) != groupBytes {
+			t.Fatalf("row group total_byte_size %v, columns sum %d", group[2], groupBytes)
+		}
+		rows = append(rows, out...)
+	}
+	if pos != int64(footerStart) {
+		t.Fatalf("column data ends at %d, footer starts at %d", pos, footerStart)
+	}
+	if meta[3].(int64) != int64(len(rows)) {
+		t.Fatalf("num_rows %v, row groups hold %d", meta[3], len(rows))
+	}
+	return rows
+}
+
+// TestParquetRoundTrip 测试按规范读取路径解码所有行组与列，结果与写入的行一致
+func TestParquetRoundTrip(t *testing.T) {
+	var rows [][]any
+	for i := 0; i < parquetRowGroupRows+13; i++ {
+		rows = append(rows, testRows()[i%2])
+	}
+	got := readParquet(t, writeAll(t, FormatParquet, rows))
+	if len(got) != len(rows) {
+		t.Fatalf("read %d rows, wrote %d", len(got), len(rows))
+	}
+	for i, row := range rows {
+		for c, v := range row {
+			if n, ok := v.(int); ok {
+				v = int64(n)
+			}
+			if got[i][c] != v {
+				t.Fatalf("row %d column %s: got %v want %v", i, testCols[c].Name, got[i][c], v)
+			}
+		}
+	}
+
+	if got := readParquet(t, writeAll(t, FormatParquet, nil)); len(got) != 0 {
+		t.Fatalf("expected empty file, got %d rows", len(got))
+	}
+}
+
+// TestParquetPyArrow 使用 pyarrow 读取导出文件，验证与第三方读取器的互操作性；
+// 环境中没有 python3 或 pyarrow 时跳过。
+func TestParquetPyArrow(t *testing.T) {
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 not available")
+	}
+	if err := exec.Command(python, "-c", "import pyarrow.parquet").Run(); err != nil {
+		t.Skip("pyarrow not available")
+	}
+	var rows [][]any
+	for i := 0; i < parquetRowGroupRows+3; i++ {
+		rows = append(rows, testRows()[i%2])
+	}
+	path := filepath.Join(t.TempDir(), "export.parquet")
+	if err := os.WriteFile(path, writeAll(t, FormatParquet, rows), 0o600); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	script := `import json, sys
+import pyarrow.parquet as pq
+f = pq.ParquetFile(sys.argv[1])
+t = f.read()
+last = t.slice(t.num_rows - 1).to_pylist()[0]
+last["ts"] = last["ts"].isoformat()
+print(json.dumps({"rows": t.num_rows, "groups": f.num_row_groups,
+    "types": [str(x) for x in t.schema.types], "last": last}))`
+	out, err := exec.Command(python, "-c", script, path).CombinedOutput()
+	if err != nil {
+		t.Fatalf("pyarrow read failed: %v\n%s", err, out)
+	}
+	var res struct {
+		Rows   int            `json:"rows"`
+		Groups int            `json:"groups"`
+		Types  []string       `json:"types"`
+		Last   map[string]any `json:"last"`
+	}
+	if err := json.Unmarshal(out, &res); err != nil {
+		t.Fatalf("decode pyarrow output %q: %v", out, err)
+	}
+	if res.Rows != len(rows) || res.Groups != 2 {
+		t.Fatalf("unexpected rows/groups %d/%d", res.Rows, res.Groups)
+	}
+	// TIMESTAMP_MILLIS 按 UTC 解释，pyarrow 版本不同可能带或不带时区后缀。
+	if len(res.Types) != 5 || !strings.HasPrefix(res.Types[0], "timestamp[ms") ||
+		strings.Join(res.Types[1:], ",") != "string,int64,double,bool" {
+		t.Fatalf("unexpected types %v", res.Types)
+	}
+	if res.Last["node"] != "n1" || res.Last["count"] != float64(3) || res.Last["ok"] != true ||
+		!strings.HasPrefix(res.Last["ts"].(string), "2026-01-02T03:04:05") {
+		t.Fatalf("unexpected last row %v", res.Last)
+	}
+}
@@ -0,0 +1,352 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"time"
+)
+
+// Parquet 写出：不依赖第三方库，按行组缓冲各列后写出。
+// 每个行组的每列仅包含一个 DATA_PAGE（v1），PLAIN 编码、不压缩，所有列均为 REQUIRED，
+// 因此页内无定义/重复级别；文件尾为 Thrift Compact 编码的 FileMetaData。
+
+const (
+	parquetMagic        = "PAR1"
+	parquetRowGroupRows = 10000
+	parquetRowGroupSize = 32 << 20
+	parquetCreatedBy    = "qcc_plus export"
+)
+
+// parquet.thrift 中用到的枚举值。
+const (
+	pqBoolean   = 0
+	pqInt64     = 2
+	pqDouble    = 5
+	pqByteArray = 6
+
+	pqConvertedUTF8            = 0
+	pqConvertedTimestampMillis = 9
+
+	pqEncodingPlain = 0
+	pqEncodingRLE   = 3
+
+	pqRequired     = 0
+	pqDataPage     = 0
+	pqUncompressed = 0
+)
+
+type parquetColumnMeta struct {
+	offset int64 // 数据页页头在文件中的偏移
+	size   int64 // 页头 + 页数据
+}
+
+type parquetRowGroup struct {
+	rows    int64
+	columns []parquetColumnMeta
+}
+
+type parquetWriter struct {
+	w      io.Writer
+	offset int64
+	cols   []Column
+	values []bytes.Buffer // 各列已 PLAIN 编码的值（布尔列在刷出时打包）
+	bools  [][]bool
+	rows   int
+	bytes  int
+	groups []parquetRowGroup
+}
+
+func newParquetWriter(w io.Writer, cols []Column) (*parquetWriter, error) {
+	p := &parquetWriter{w: w, cols: cols, values: make([]bytes.Buffer, len(cols)), bools: make([][]bool, len(cols))}
+	if err := p.write([]byte(parquetMagic)); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *parquetWriter) write(b []byte) error {
+	n, err := p.w.Write(b)
+	p.offset += int64(n)
+	return err
+}
+
+func (p *parquetWriter) Write(row []any) error {
+	if err := checkRow(p.cols, row); err != nil {
+		return err
+	}
+	// 先整体校验再追加，避免类型错误时各列行数不一致。
+	for i, c := range p.cols {
+		if _, err := formatValue(c, row[i]); err != nil {
+			return err
+		}
+	}
+	var scratch [8]byte
+	for i, c := range p.cols {
+		buf := &p.values[i]
+		before := buf.Len()
+		switch c.Type {
+		case String:
+			s := row[i].(string)
+			binary.LittleEndian.PutUint32(scratch[:4], uint32(len(s)))
+			buf.Write(scratch[:4])
+			buf.WriteString(s)
+		case Int64:
+			n, _ := asInt64(row[i])
+			binary.LittleEndian.PutUint64(scratch[:], uint64(n))
+			buf.Write(scratch[:])
+		case Float64:
+			binary.LittleEndian.PutUint64(scratch[:], math.Float64bits(row[i].(float64)))
+			buf.Write(scratch[:])
+		case Timestamp:
+			var ms int64
+			if t := row[i].(time.Time); !t.IsZero() {
+				ms = t.UnixMilli()
+			}
+			binary.LittleEndian.PutUint64(scratch[:], uint64(ms))
+			buf.Write(scratch[:])
+		case Bool:
+			p.bools[i] = append(p.bools[i], row[i].(bool))
+		}
+		p.bytes += buf.Len() - before
+	}
+	p.rows++
+	if p.rows >= parquetRowGroupRows || p.bytes >= parquetRowGroupSize {
+		return p.flushRowGroup()
+	}
+	return nil
+}
+
+// flushRowGroup 将缓冲的行写成一个行组。
+func (p *parquetWriter) flushRowGroup() error {
+	if p.rows == 0 {
+		return nil
+	}
+	group := parquetRowGroup{rows: int64(p.rows), columns: make([]parquetColumnMeta, len(p.cols))}
+	for i, c := range p.cols {
+		data := p.values[i].Bytes()
+		if c.Type == Bool {
+			data = packBools(p.bools[i])
+		}
+		header := encodePageHeader(len(data), p.rows)
+		group.columns[i] = parquetColumnMeta{offset: p.offset, size: int64(len(header) + len(data))}
+		if err := p.write(header); err != nil {
+			return err
+		}
+		if err := p.write(data); err != nil {
+			return err
+		}
+		p.values[i].Reset()
+		p.bools[i] = p.bools[i][:0]
+	}
+	p.groups = append(p.groups, group)
+	p.rows, p.bytes = 0, 0
+	return nil
+}
+
+func (p *parquetWriter) Close() error {
+	if err := p.flushRowGroup(); err != nil {
+		return err
+	}
+	footer := p.encodeFileMetaData()
+	if err := p.write(footer); err != nil {
+		return err
+	}
+	var n [4]byte
+	binary.LittleEndian.PutUint32(n[:], uint32(len(footer)))
+	if err := p.write(n[:]); err != nil {
+		return err
+	}
+	return p.write([]byte(parquetMagic))
+}
+
+// packBools 按 PLAIN 编码将布尔值逐位打包（低位在前）。
+func packBools(vals []bool) []byte {
+	out := make([]byte, (len(vals)+7)/8)
+	for i, v := range vals {
+		if v {
+			out[i/8] |= 1 << (i % 8)
+		}
+	}
+	return out
+}
+
+func physicalType(t Type) int32 {
+	switch t {
+	case Bool:
+		return pqBoolean
+	case Int64, Timestamp:
+		return pqInt64
+	case Float64:
+		return pqDouble
+	default:
+		return pqByteArray
+	}
+}
+
+func encodePageHeader(size, rows int) []byte {
+	var t thriftWriter
+	t.begin()
+	t.i32(1, pqDataPage)
+	t.i32(2, int32(size))
+	t.i32(3, int32(size))
+	t.structField(5)
+	t.i32(1, int32(rows))
+	t.i32(2, pqEncodingPlain)
+	t.i32(3, pqEncodingRLE)
+	t.i32(4, pqEncodingRLE)
+	t.end()
+	t.end()
+	return t.buf.Bytes()
+}
+
+func (p *parquetWriter) encodeFileMetaData() []byte {
+	var numRows int64
+	for _, g := range p.groups {
+		numRows += g.rows
+	}
+
+	var t thriftWriter
+	t.begin()
+	t.i32(1, 1)
+
+	t.list(2, thriftStruct, len(p.cols)+1)
+	t.begin()
+	t.binary(4, "schema")
+	t.i32(5, int32(len(p.cols)))
+	t.end()
+	for _, c := range p.cols {
+		t.begin()
+		t.i32(1, physicalType(c.Type))
+		t.i32(3, pqRequired)
+		t.binary(4, c.Name)
+		switch c.Type {
+		case String:
+			t.i32(6, pqConvertedUTF8)
+		case Timestamp:
+			t.i32(6, pqConvertedTimestampMillis)
+		}
+		t.end()
+	}
+
+	t.i64(3, numRows)
+
+	t.list(4, thriftStruct, len(p.groups))
+	for _, g := range p.groups {
+		t.begin()
+		t.list(1, thriftStruct, len(p.cols))
+		var groupSize int64
+		for i, c := range p.cols {
+			meta := g.columns[i]
+			groupSize += meta.size
+			t.begin()
+			t.i64(2, meta.offset)
+			t.structField(3)
+			t.i32(1, physicalType(c.Type))
+			t.list(2, thriftI32, 2)
+			t.varint(pqEncodingPlain)
+			t.varint(pqEncodingRLE)
+			t.list(3, thriftBinary, 1)
+			t.rawBinary(c.Name)
+			t.i32(4, pqUncompressed)
+			t.i64(5, g.rows)
+			t.i64(6, meta.size)
+			t.i64(7, meta.size)
+			t.i64(9, meta.offset)
+			t.end()
+			t.end()
+		}
+		t.i64(2, groupSize)
+		t.i64(3, g.rows)
+		t.end()
+	}
+
+	t.binary(6, parquetCreatedBy)
+	t.end()
+	return t.buf.Bytes()
+}
+
+// Thrift Compact 协议的最小写入实现，仅覆盖 Parquet 元数据所需类型。
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+type thriftWriter struct {
+	buf  bytes.Buffer
+	last []int16 // 各层结构体上一个字段 ID，用于增量编码字段头
+}
+
+// begin 开始一个结构体（顶层或列表元素）。
+func (t *thriftWriter) begin() {
+	t.last = append(t.last, 0)
+}
+
+// structField 写入结构体类型的字段头并进入该结构体。
+func (t *thriftWriter) structField(id int16) {
+	t.field(id, thriftStruct)
+	t.begin()
+}
+
+// end 写入 STOP 并结束当前结构体。
+func (t *thriftWriter) end() {
+	t.buf.WriteByte(0)
+	t.last = t.last[:len(t.last)-1]
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	last := &t.last[len(t.last)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.varint(int64(id))
+	}
+	*last = id
+}
+
+// varint 写入 zigzag 编码的变长整数。
+func (t *thriftWriter) varint(v int64) {
+	u := uint64(v<<1) ^ uint64(v>>63)
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], u)
+	t.buf.Write(b[:n])
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.varint(int64(v))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.varint(v)
+}
+
+func (t *thriftWriter) binary(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.rawBinary(s)
+}
+
+func (t *thriftWriter) rawBinary(s string) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], uint64(len(s)))
+	t.buf.Write(b[:n])
+	t.buf.WriteString(s)
+}
+
+func (t *thriftWriter) list(id int16, elem byte, size int) {
+	t.field(id, thriftList)
+	if size < 15 {
+		t.buf.WriteByte(byte(size)<<4 | elem)
+		return
+	}
+	t.buf.WriteByte(0xF0 | elem)
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], uint64(size))
+	t.buf.Write(b[:n])
+}
@@ -0,0 +1,74 @@
+package logging
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// DefaultLevelKey 级别配置中表示默认级别的键。
+const DefaultLevelKey = "default"
+
+// Levels 维护默认级别与各组件级别，可并发读写。
+type Levels struct {
+	mu         sync.RWMutex
+	base       slog.Level // 启动时配置的默认级别，运行时配置未指定 default 时回退到它
+	def        slog.Level
+	components map[string]slog.Level
+}
+
+// NewLevels 创建级别配置。
+func NewLevels(def slog.Level) *Levels {
+	return &Levels{base: def, def: def, components: map[string]slog.Level{}}
+}
+
+// Level 返回组件生效的级别。
+func (l *Levels) Level(component string) slog.Level {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if component != "" {
+		if lvl, ok := l.components[component]; ok {
+			return lvl
+		}
+	}
+	return l.def
+}
+
+// Set 整体替换组件级别；任一级别非法时不做修改。
+func (l *Levels) Set(levels map[string]string) error {
+	def := l.base
+	components := make(map[string]slog.Level, len(levels))
+	for name, raw := range levels {
+		lvl, err := ParseLevel(raw)
+		if err != nil {
+			return fmt.Errorf("component %q: %w", name, err)
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == DefaultLevelKey {
+			def = lvl
+			continue
+		}
+		components[name] = lvl
+	}
+	l.mu.Lock()
+	l.def = def
+	l.components = components
+	l.mu.Unlock()
+	return nil
+}
+
+// ParseLevel 解析 debug/info/warn/error，空字符串视为 info。
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return slog.LevelInfo, fmt.Errorf("invalid log level %q", s)
+}
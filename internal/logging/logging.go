@@ -0,0 +1,213 @@
+// Package logging 提供结构化分级日志：支持 JSON / logfmt 输出、按组件调整级别，
+// 并在输出前自动脱敏 API Key 等敏感信息。
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// 输出格式。
+const (
+	FormatLogfmt = "logfmt"
+	FormatJSON   = "json"
+)
+
+// 常用字段名，便于日志管道按账号、节点、请求过滤。
+const (
+	KeyComponent = "component"
+	KeyAccountID = "account_id"
+	KeyNodeID    = "node_id"
+	KeyRequestID = "request_id"
+	KeyError     = "error"
+)
+
+// Config 日志配置。
+type Config struct {
+	Format string    // json / logfmt，默认 logfmt
+	Level  string    // 默认级别 debug/info/warn/error，默认 info
+	Output io.Writer // 默认 os.Stderr
+}
+
+// ConfigFromEnv 从 LOG_FORMAT、LOG_LEVEL 读取配置。
+func ConfigFromEnv() Config {
+	return Config{
+		Format: strings.ToLower(strings.TrimSpace(os.Getenv("LOG_FORMAT"))),
+		Level:  strings.TrimSpace(os.Getenv("LOG_LEVEL")),
+	}
+}
+
+// Logger 结构化日志器，nil 时所有方法均为空操作。
+// 同一 New 派生出的 Logger 共享级别配置，运行时调整立即生效。
+type Logger struct {
+	sl        *slog.Logger
+	levels    *Levels
+	component string
+}
+
+// New 按配置创建 Logger；非法级别回退为 info。
+func New(cfg Config) *Logger {
+	out := cfg.Output
+	if out == nil {
+		out = os.Stderr
+	}
+	// 级别由外层 handler 按组件判断，内层全部放行。
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug - 4}
+	var inner slog.Handler
+	if cfg.Format == FormatJSON {
+		inner = slog.NewJSONHandler(out, opts)
+	} else {
+		inner = slog.NewTextHandler(out, opts)
+	}
+	lvl, err := ParseLevel(cfg.Level)
+	if err != nil {
+		lvl = slog.LevelInfo
+	}
+	levels := NewLevels(lvl)
+	return &Logger{sl: slog.New(&handler{inner: inner, levels: levels}), levels: levels}
+}
+
+// Discard 返回丢弃所有输出的 Logger，用于测试。
+func Discard() *Logger {
+	return New(Config{Output: io.Discard})
+}
+
+var (
+	defaultMu     sync.RWMutex
+	defaultLogger *Logger
+)
+
+// Default 返回全局 Logger；未设置时按环境变量创建。
+func Default() *Logger {
+	defaultMu.RLock()
+	l := defaultLogger
+	defaultMu.RUnlock()
+	if l != nil {
+		return l
+	}
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	if defaultLogger == nil {
+		defaultLogger = New(ConfigFromEnv())
+	}
+	return defaultLogger
+}
+
+// SetDefault 设置全局 Logger，并接管标准库 log 与 slog 的默认输出，
+// 使尚未迁移的 log.Printf 同样经过格式化与脱敏。
+func SetDefault(l *Logger) {
+	if l == nil {
+		return
+	}
+	defaultMu.Lock()
+	defaultLogger = l
+	defaultMu.Unlock()
+	slog.SetDefault(l.sl)
+}
+
+// With 返回附加字段的 Logger（键值对交替传入）。
+func (l *Logger) With(args ...any) *Logger {
+	if l == nil || len(args) == 0 {
+		return l
+	}
+	component := l.component
+	for i := 0; i+1 < len(args); i += 2 {
+		if k, ok := args[i].(string); ok && k == KeyComponent {
+			component = fmt.Sprint(args[i+1])
+		}
+	}
+	return &Logger{sl: l.sl.With(args...), levels: l.levels, component: component}
+}
+
+// Component 返回指定组件的 Logger，组件名用于按组件调整级别。
+func (l *Logger) Component(name string) *Logger {
+	if l == nil || name == "" || name == l.component {
+		return l
+	}
+	return l.With(KeyComponent, name)
+}
+
+// Enabled 判断当前组件是否输出指定级别。
+func (l *Logger) Enabled(level slog.Level) bool {
+	return l != nil && l.sl.Enabled(context.Background(), level)
+}
+
+// Debug 输出调试日志。
+func (l *Logger) Debug(msg string, args ...any) { l.log(slog.LevelDebug, msg, args...) }
+
+// Info 输出普通日志。
+func (l *Logger) Info(msg string, args ...any) { l.log(slog.LevelInfo, msg, args...) }
+
+// Warn 输出警告日志。
+func (l *Logger) Warn(msg string, args ...any) { l.log(slog.LevelWarn, msg, args...) }
+
+// Error 输出错误日志。
+func (l *Logger) Error(msg string, args ...any) { l.log(slog.LevelError, msg, args...) }
+
+func (l *Logger) log(level slog.Level, msg string, args ...any) {
+	if l == nil {
+		return
+	}
+	l.sl.Log(context.Background(), level, msg, args...)
+}
+
+// Printf 兼容 log.Logger 的格式化输出：参数含 error 时记为 warn，否则为 info；
+// 消息以 "[tag] " 开头且未指定组件时，tag 作为组件名。
+func (l *Logger) Printf(format string, v ...any) {
+	if l == nil {
+		return
+	}
+	level := slog.LevelInfo
+	for _, arg := range v {
+		if _, ok := arg.(error); ok {
+			level = slog.LevelWarn
+			break
+		}
+	}
+	target := l
+	msg := fmt.Sprintf(format, v...)
+	if l.component == "" {
+		if tag, rest, ok := splitTag(msg); ok {
+			target = l.Component(tag)
+			msg = rest
+		}
+	}
+	target.log(level, msg)
+}
+
+// splitTag 解析 "[tag] message" 形式的前缀。
+func splitTag(msg string) (string, string, bool) {
+	if !strings.HasPrefix(msg, "[") {
+		return "", "", false
+	}
+	end := strings.Index(msg, "] ")
+	if end <= 1 || end > 32 {
+		return "", "", false
+	}
+	tag := msg[1:end]
+	if strings.ContainsAny(tag, " \t") {
+		return "", "", false
+	}
+	return strings.ToLower(tag), msg[end+2:], true
+}
+
+// SetLevels 运行时调整级别，键为组件名，"default" 为默认级别。
+func (l *Logger) SetLevels(levels map[string]string) error {
+	if l == nil {
+		return nil
+	}
+	return l.levels.Set(levels)
+}
+
+// Slog 返回底层 slog.Logger。
+func (l *Logger) Slog() *slog.Logger {
+	if l == nil {
+		return slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	return l.sl
+}
@@ -0,0 +1,122 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+const redacted = "***"
+
+var redactRules = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	// Anthropic / OpenAI 风格的密钥
+	{regexp.MustCompile(`\bsk-ant-[A-Za-z0-9_\-]{8,}`), "sk-ant-" + redacted},
+	{regexp.MustCompile(`\bsk-[A-Za-z0-9_\-]{16,}`), "sk-" + redacted},
+	// URL 查询参数中的密钥
+	{regexp.MustCompile(`(?i)([?&](?:key|api_key|apikey|access_token|token|secret|password)=)[^&\s"']+`), "${1}" + redacted},
+	// Telegram Bot Token（位于 URL 路径）
+	{regexp.MustCompile(`/bot\d+:[A-Za-z0-9_\-]+`), "/bot" + redacted},
+	// URL 中的用户名密码
+	{regexp.MustCompile(`(://[^/\s:@]+:)[^@/\s]+@`), "${1}" + redacted + "@"},
+	// 认证头
+	{regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/\-]+=*`), "${1}" + redacted},
+	{regexp.MustCompile(`(?i)(x-api-key["']?\s*[:=]\s*["']?)[^\s"',}]+`), "${1}" + redacted},
+}
+
+// secretKeys 字段名即表明值为凭据，整个值替换为 ***。
+var secretKeys = map[string]bool{
+	"key": true, "api_key": true, "apikey": true, "token": true,
+	"password": true, "secret": true, "authorization": true,
+}
+
+// secretKeySuffixes 以这些后缀结尾的字段名同样视为凭据，如 proxy_api_key、access_token。
+var secretKeySuffixes = []string{"_api_key", "_token", "_password", "_secret"}
+
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	if secretKeys[key] {
+		return true
+	}
+	for _, suffix := range secretKeySuffixes {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact 脱敏字符串中的 API Key、URL 凭据与认证头。
+func Redact(s string) string {
+	for _, r := range redactRules {
+		s = r.re.ReplaceAllString(s, r.repl)
+	}
+	return s
+}
+
+// redactAttr 按字段名整体隐藏凭据字段，并脱敏字符串、error 与 Stringer（如 *url.URL）类型的字段值。
+func redactAttr(a slog.Attr) slog.Attr {
+	if a.Value.Kind() != slog.KindGroup && isSecretKey(a.Key) {
+		a.Value = slog.StringValue(redacted)
+		return a
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(Redact(a.Value.String()))
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case error:
+			a.Value = slog.StringValue(Redact(v.Error()))
+		case fmt.Stringer:
+			a.Value = slog.StringValue(Redact(v.String()))
+		}
+	case slog.KindGroup:
+		attrs := a.Value.Group()
+		out := make([]slog.Attr, len(attrs))
+		for i, ga := range attrs {
+			out[i] = redactAttr(ga)
+		}
+		a.Value = slog.GroupValue(out...)
+	}
+	return a
+}
+
+// handler 在内层 handler 之外实现按组件过滤级别与脱敏。
+type handler struct {
+	inner     slog.Handler
+	levels    *Levels
+	component string
+}
+
+func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.levels.Level(h.component)
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	out := slog.NewRecord(r.Time, r.Level, Redact(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(redactAttr(a))
+		return true
+	})
+	return h.inner.Handle(ctx, out)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	component := h.component
+	clean := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		if a.Key == KeyComponent {
+			component = a.Value.String()
+		}
+		clean[i] = redactAttr(a)
+	}
+	return &handler{inner: h.inner.WithAttrs(clean), levels: h.levels, component: component}
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return &handler{inner: h.inner.WithGroup(name), levels: h.levels, component: h.component}
+}
@@ -0,0 +1,154 @@
+package metricsink
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type influxSink struct {
+	writeURL  string
+	target    string
+	token     string
+	prefix    string
+	precision time.Duration
+	client    *http.Client
+}
+
+// NewInflux 创建 InfluxDB 行协议后端。writeURL 为完整写入地址，如
+// http://influxdb:8086/api/v2/write?org=o&bucket=b 或 http://influxdb:8086/write?db=d；
+// 时间戳精度跟随 URL 中的 precision 参数（ns/us/ms/s），缺省为纳秒。
+// token 非空时以 "Authorization: Token <token>" 鉴权，measurement 为 prefix_measurement。
+func NewInflux(writeURL, token, prefix string, timeout time.Duration) (Sink, error) {
+	u, err := url.Parse(writeURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		// 不回显原始地址，其中可能带有凭据
+		return nil, errors.New("invalid influxdb write url: http(s) scheme and host required")
+	}
+	precision := time.Nanosecond
+	switch u.Query().Get("precision") {
+	case "", "ns", "n":
+	case "us", "u":
+		precision = time.Microsecond
+	case "ms":
+		precision = time.Millisecond
+	case "s":
+		precision = time.Second
+	default:
+		return nil, fmt.Errorf("unsupported influxdb precision %q", u.Query().Get("precision"))
+	}
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	target := u.Scheme + "://" + u.Host + u.Path
+	return &influxSink{
+		writeURL:  writeURL,
+		target:    target,
+		token:     token,
+		prefix:    strings.Trim(prefix, "_"),
+		precision: precision,
+		client:    &http.Client{Timeout: timeout},
+	}, nil
+}
+
+func (s *influxSink) Type() string   { return "influxdb" }
+func (s *influxSink) Target() string { return s.target }
+func (s *influxSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+func (s *influxSink) Send(ctx context.Context, points []Point) error {
+	var body bytes.Buffer
+	for _, p := range points {
+		s.writeLine(&body, p)
+	}
+	if body.Len() == 0 {
+		return nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.writeURL, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Token "+s.token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("influxdb write status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// writeLine 输出一行行协议：measurement,tag=v field=1i,field2=2.5 timestamp。
+func (s *influxSink) writeLine(b *bytes.Buffer, p Point) {
+	if len(p.Fields) == 0 {
+		return
+	}
+	name := p.Measurement
+	if s.prefix != "" {
+		name = s.prefix + "_" + name
+	}
+	b.WriteString(influxEscape(name, false))
+	for _, t := range p.Tags {
+		if t.Value == "" {
+			continue
+		}
+		b.WriteByte(',')
+		b.WriteString(influxEscape(t.Key, true))
+		b.WriteByte('=')
+		b.WriteString(influxEscape(t.Value, true))
+	}
+	b.WriteByte(' ')
+	for i, f := range p.Fields {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(influxEscape(f.Name, true))
+		b.WriteByte('=')
+		if f.Kind == Counter {
+			b.WriteString(strconv.FormatInt(int64(math.Round(f.Value)), 10))
+			b.WriteByte('i')
+		} else {
+			b.WriteString(strconv.FormatFloat(f.Value, 'f', -1, 64))
+		}
+	}
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatInt(p.Time.UnixNano()/int64(s.precision), 10))
+	b.WriteByte('\n')
+}
+
+// influxEscape 转义行协议中的空格、逗号，以及标签/字段键值中的等号。
+func influxEscape(s string, escapeEquals bool) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case ' ', ',':
+			b.WriteByte('\\')
+		case '=':
+			if escapeEquals {
+				b.WriteByte('\\')
+			}
+		case '\n':
+			r = ' '
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
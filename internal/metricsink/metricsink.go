@@ -0,0 +1,243 @@
+// Package metricsink 将请求与探活数据点异步推送到推送式监控后端，
+// 目前支持 StatsD（UDP）与 InfluxDB 行协议（HTTP）。
+//
+// 每个后端独立使用一个有界队列与批量发送协程，某个后端变慢或不可用时
+// 只会丢弃该后端的数据点，不会阻塞请求路径或影响其它后端。
+package metricsink
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"qcc_plus/internal/batcher"
+	"qcc_plus/internal/logging"
+)
+
+// Kind 数据点字段类型，决定 StatsD 的指标类型与 InfluxDB 的字段编码。
+type Kind int
+
+const (
+	Counter Kind = iota // 累加计数（StatsD c，InfluxDB 整数）
+	Gauge               // 瞬时值（StatsD g，InfluxDB 浮点）
+	Timing              // 耗时毫秒（StatsD ms，InfluxDB 浮点）
+)
+
+// Tag 数据点维度。
+type Tag struct {
+	Key   string
+	Value string
+}
+
+// Field 数据点字段。
+type Field struct {
+	Name  string
+	Value float64
+	Kind  Kind
+}
+
+// Point 一个数据点，Measurement 由各后端按自身约定加上前缀。
+type Point struct {
+	Measurement string
+	Tags        []Tag
+	Fields      []Field
+	Time        time.Time
+}
+
+// Sink 推送后端。Send 由单个协程串行调用。
+type Sink interface {
+	// Type 后端类型，如 statsd、influxdb。
+	Type() string
+	// Target 目标地址（不含凭据），用于展示。
+	Target() string
+	Send(ctx context.Context, points []Point) error
+	Close() error
+}
+
+// Config 队列与批量参数，对每个后端分别生效。
+type Config struct {
+	QueueSize     int
+	BatchSize     int
+	FlushInterval time.Duration
+	Timeout       time.Duration // 单次发送超时
+}
+
+const (
+	defaultQueueSize     = 10000
+	defaultBatchSize     = 500
+	defaultFlushInterval = 5 * time.Second
+	defaultTimeout       = 10 * time.Second
+)
+
+// Stats 单个后端的运行状态。
+type Stats struct {
+	Type          string    `json:"type"`
+	Target        string    `json:"target"`
+	Healthy       bool      `json:"healthy"`
+	Sent          int64     `json:"sent"`
+	Dropped       int64     `json:"dropped"`
+	Failed        int64     `json:"failed"`
+	FailedBatches int64     `json:"failed_batches"`
+	QueueLen      int       `json:"queue_len"`
+	QueueCap      int       `json:"queue_cap"`
+	LastError     string    `json:"last_error,omitempty"`
+	LastErrorAt   time.Time `json:"last_error_at,omitempty"`
+	LastSuccessAt time.Time `json:"last_success_at,omitempty"`
+}
+
+type worker struct {
+	sink  Sink
+	batch *batcher.Batcher[Point]
+
+	sent          atomic.Int64
+	failed        atomic.Int64
+	failedBatches atomic.Int64
+
+	mu            sync.Mutex
+	lastError     string
+	lastErrorAt   time.Time
+	lastSuccessAt time.Time
+}
+
+// Emitter 将数据点分发到各后端。nil Emitter 的所有方法均为空操作。
+type Emitter struct {
+	cfg     Config
+	workers []*worker
+	logger  *logging.Logger
+}
+
+// New 为每个后端启动发送协程；sinks 为空时返回 nil，logger 为空时使用默认日志。
+func New(cfg Config, sinks []Sink, logger *logging.Logger) *Emitter {
+	if len(sinks) == 0 {
+		return nil
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultQueueSize
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = defaultFlushInterval
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if logger == nil {
+		logger = logging.Default()
+	}
+	e := &Emitter{cfg: cfg, logger: logger}
+	bcfg := batcher.Config{QueueSize: cfg.QueueSize, BatchSize: cfg.BatchSize, FlushInterval: cfg.FlushInterval}
+	for _, s := range sinks {
+		w := &worker{sink: s}
+		w.batch = batcher.New(bcfg, func(batch []Point) { e.flush(w, batch) })
+		e.workers = append(e.workers, w)
+	}
+	return e
+}
+
+// Emit 非阻塞入队，队列满时丢弃并计数。
+func (e *Emitter) Emit(p Point) {
+	if e == nil {
+		return
+	}
+	if p.Time.IsZero() {
+		p.Time = time.Now()
+	}
+	for _, w := range e.workers {
+		w.batch.Add(p)
+	}
+}
+
+// Stats 返回各后端的发送计数与最近错误。
+func (e *Emitter) Stats() []Stats {
+	if e == nil {
+		return nil
+	}
+	out := make([]Stats, 0, len(e.workers))
+	for _, w := range e.workers {
+		w.mu.Lock()
+		st := Stats{
+			Type:          w.sink.Type(),
+			Target:        w.sink.Target(),
+			Sent:          w.sent.Load(),
+			Dropped:       w.batch.Dropped(),
+			Failed:        w.failed.Load(),
+			FailedBatches: w.failedBatches.Load(),
+			QueueLen:      w.batch.Len(),
+			QueueCap:      w.batch.Cap(),
+			LastError:     w.lastError,
+			LastErrorAt:   w.lastErrorAt,
+			LastSuccessAt: w.lastSuccessAt,
+		}
+		w.mu.Unlock()
+		// 最近一次发送成功（或尚未出错）视为健康。
+		st.Healthy = st.LastErrorAt.IsZero() || st.LastSuccessAt.After(st.LastErrorAt)
+		out = append(out, st)
+	}
+	return out
+}
+
+// Close 发送队列中剩余的数据点并关闭各后端。
+func (e *Emitter) Close() {
+	if e == nil {
+		return
+	}
+	var wg sync.WaitGroup
+	for _, w := range e.workers {
+		wg.Add(1)
+		go func(w *worker) {
+			defer wg.Done()
+			w.batch.Close()
+			_ = w.sink.Close()
+		}(w)
+	}
+	wg.Wait()
+}
+
+// flush 发送当前批次，失败时丢弃该批次。
+func (e *Emitter) flush(w *worker, batch []Point) {
+	if len(batch) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), e.cfg.Timeout)
+	err := w.sink.Send(ctx, batch)
+	cancel()
+	now := time.Now()
+	if err != nil {
+		err = redactURLError(err)
+	}
+	w.mu.Lock()
+	if err != nil {
+		w.lastError = err.Error()
+		w.lastErrorAt = now
+	} else {
+		w.lastSuccessAt = now
+	}
+	w.mu.Unlock()
+	if err != nil {
+		w.failed.Add(int64(len(batch)))
+		w.failedBatches.Add(1)
+		e.logger.Warn("send points failed", "sink", w.sink.Type(), "target", w.sink.Target(), "count", len(batch), logging.KeyError, err)
+	} else {
+		w.sent.Add(int64(len(batch)))
+	}
+}
+
+// redactURLError 去除 *url.Error 中 URL 的用户信息与查询参数，避免凭据出现在状态接口与日志中。
+func redactURLError(err error) error {
+	var ue *url.Error
+	if !errors.As(err, &ue) {
+		return err
+	}
+	u, perr := url.Parse(ue.URL)
+	if perr != nil {
+		return &url.Error{Op: ue.Op, URL: "(redacted)", Err: ue.Err}
+	}
+	u.User = nil
+	u.RawQuery = ""
+	return &url.Error{Op: ue.Op, URL: u.String(), Err: ue.Err}
+}
@@ -0,0 +1,141 @@
+package metricsink
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// statsdMaxPacket 单个 UDP 包上限，保证以太网 MTU 下不分片。
+const statsdMaxPacket = 1432
+
+// StatsD 标签格式。
+const (
+	StatsDTagsDogStatsD = "dogstatsd" // name:1|c|#k:v,k2:v2
+	StatsDTagsInflux    = "influx"    // name,k=v,k2=v2:1|c（Telegraf statsd 输入）
+	StatsDTagsNone      = "none"      // 不输出标签
+)
+
+type statsdSink struct {
+	addr      string
+	prefix    string
+	tagFormat string
+	conn      net.Conn
+}
+
+// NewStatsD 创建 StatsD UDP 后端，指标名为 prefix.measurement.field。
+func NewStatsD(addr, prefix, tagFormat string) (Sink, error) {
+	switch tagFormat {
+	case "":
+		tagFormat = StatsDTagsDogStatsD
+	case StatsDTagsDogStatsD, StatsDTagsInflux, StatsDTagsNone:
+	default:
+		return nil, fmt.Errorf("unsupported statsd tag format %q", tagFormat)
+	}
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &statsdSink{addr: addr, prefix: strings.Trim(prefix, "."), tagFormat: tagFormat, conn: conn}, nil
+}
+
+func (s *statsdSink) Type() string   { return "statsd" }
+func (s *statsdSink) Target() string { return "udp://" + s.addr }
+func (s *statsdSink) Close() error   { return s.conn.Close() }
+
+// Send 将多行合并为不超过 statsdMaxPacket 的 UDP 包发送。
+func (s *statsdSink) Send(ctx context.Context, points []Point) error {
+	var (
+		packet  []byte
+		lastErr error
+	)
+	write := func() {
+		if len(packet) == 0 {
+			return
+		}
+		if _, err := s.conn.Write(packet); err != nil {
+			lastErr = err
+		}
+		packet = packet[:0]
+	}
+	for _, p := range points {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for _, f := range p.Fields {
+			line := s.formatLine(p, f)
+			if len(packet) > 0 && len(packet)+1+len(line) > statsdMaxPacket {
+				write()
+			}
+			if len(packet) > 0 {
+				packet = append(packet, '\n')
+			}
+			packet = append(packet, line...)
+		}
+	}
+	write()
+	return lastErr
+}
+
+func (s *statsdSink) formatLine(p Point, f Field) string {
+	var b strings.Builder
+	if s.prefix != "" {
+		b.WriteString(statsdName(s.prefix))
+		b.WriteByte('.')
+	}
+	b.WriteString(statsdName(p.Measurement))
+	b.WriteByte('.')
+	b.WriteString(statsdName(f.Name))
+	if s.tagFormat == StatsDTagsInflux {
+		for _, t := range p.Tags {
+			if t.Value == "" {
+				continue
+			}
+			b.WriteByte(',')
+			b.WriteString(statsdName(t.Key))
+			b.WriteByte('=')
+			b.WriteString(statsdName(t.Value))
+		}
+	}
+	b.WriteByte(':')
+	b.WriteString(strconv.FormatFloat(f.Value, 'f', -1, 64))
+	switch f.Kind {
+	case Gauge:
+		b.WriteString("|g")
+	case Timing:
+		b.WriteString("|ms")
+	default:
+		b.WriteString("|c")
+	}
+	if s.tagFormat == StatsDTagsDogStatsD {
+		first := true
+		for _, t := range p.Tags {
+			if t.Value == "" {
+				continue
+			}
+			if first {
+				b.WriteString("|#")
+				first = false
+			} else {
+				b.WriteByte(',')
+			}
+			b.WriteString(statsdName(t.Key))
+			b.WriteByte(':')
+			b.WriteString(statsdName(t.Value))
+		}
+	}
+	return b.String()
+}
+
+// statsdName 替换 StatsD 协议中的保留字符。
+func statsdName(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ':', '|', '@', ',', '#', '=', ' ', '\n':
+			return '_'
+		}
+		return r
+	}, s)
+}
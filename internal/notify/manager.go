@@ -4,10 +4,11 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
-	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"qcc_plus/internal/logging"
 	"qcc_plus/internal/store"
 )
 
@@ -26,6 +27,7 @@ type Manager struct {
 	stopped    chan struct{}
 	dedupMu    sync.Mutex
 	lastNotify map[string]time.Time
+	dropped    atomic.Int64 // 队列满被丢弃的事件数
 }
 
 // Option 自定义管理器配置。
@@ -58,11 +60,11 @@ func WithDedupWindow(d time.Duration) Option {
 	}
 }
 
-// WithLogger 设置自定义日志。
-func WithLogger(l Logger) Option {
+// WithLogger 设置自定义日志，输出归属 notify 组件。
+func WithLogger(l *logging.Logger) Option {
 	return func(c *ManagerConfig) {
 		if l != nil {
-			c.Logger = l
+			c.Logger = l.Component("notify")
 		}
 	}
 }
@@ -82,7 +84,7 @@ func NewManager(store Store, opts ...Option) *Manager {
 		QueueSize:   128,
 		WorkerCount: 2,
 		DedupWindow: 5 * time.Minute,
-		Logger:      log.Default(),
+		Logger:      logging.Default().Component("notify"),
 		SendTimeout: 8 * time.Second,
 	}
 	for _, opt := range opts {
@@ -116,10 +118,19 @@ func (m *Manager) Publish(evt Event) {
 	select {
 	case m.queue <- evt:
 	default:
-		m.logf("notify queue full, drop event %s for account %s", evt.EventType, evt.AccountID)
+		m.dropped.Add(1)
+		m.cfg.Logger.Warn("notify queue full, drop event", "event_type", evt.EventType, logging.KeyAccountID, evt.AccountID)
 	}
 }
 
+// QueueStats 返回队列当前长度、容量与累计丢弃事件数。
+func (m *Manager) QueueStats() (depth, capacity int, dropped int64) {
+	if m == nil {
+		return 0, 0, 0
+	}
+	return len(m.queue), cap(m.queue), m.dropped.Load()
+}
+
 // Stop 停止后台 worker。
 func (m *Manager) Stop() {
 	if m == nil {
@@ -146,7 +157,7 @@ func (m *Manager) handleEvent(evt Event) {
 
 	subs, err := m.store.ListEnabledSubscriptionsForEvent(ctx, evt.AccountID, evt.EventType)
 	if err != nil {
-		m.logf("list subscriptions failed: %v", err)
+		m.cfg.Logger.Error("list subscriptions failed", logging.KeyAccountID, evt.AccountID, logging.KeyError, err)
 		return
 	}
 	if len(subs) == 0 {
@@ -159,7 +170,7 @@ func (m *Manager) handleEvent(evt Event) {
 		}
 		ch, err := buildChannel(sub.Channel)
 		if err != nil {
-			m.logf("build channel %s failed: %v", sub.Channel.ID, err)
+			m.cfg.Logger.Warn("build channel failed", logging.KeyAccountID, evt.AccountID, "channel_id", sub.Channel.ID, logging.KeyError, err)
 			continue
 		}
 		msg := NotificationMessage{
@@ -192,10 +203,10 @@ func (m *Manager) handleEvent(evt Event) {
 			SentAt:    sentAt,
 			CreatedAt: time.Now(),
 		}); err != nil {
-			m.logf("insert notification history failed: %v", err)
+			m.cfg.Logger.Error("insert notification history failed", logging.KeyAccountID, evt.AccountID, logging.KeyError, err)
 		}
 		if sendErr != nil {
-			m.logf("send notification via %s failed: %v", sub.Channel.ChannelType, sendErr)
+			m.cfg.Logger.Warn("send notification failed", logging.KeyAccountID, evt.AccountID, "channel_type", sub.Channel.ChannelType, logging.KeyError, sendErr)
 		}
 	}
 }
@@ -221,12 +232,6 @@ func (m *Manager) shouldSend(key string) bool {
 	return true
 }
 
-func (m *Manager) logf(format string, v ...interface{}) {
-	if m.cfg.Logger != nil {
-		m.cfg.Logger.Printf(format, v...)
-	}
-}
-
 func randomID() string {
 	b := make([]byte, 12)
 	if _, err := rand.Read(b); err != nil {
@@ -1,6 +1,10 @@
 package notify
 
-import "time"
+import (
+	"time"
+
+	"qcc_plus/internal/logging"
+)
 
 // 事件类型常量。
 const (
@@ -24,6 +28,13 @@ const (
 	// 账号相关
 	EventAccountQuotaWarning = "account.quota_warning"
 	EventAccountAuthFailed   = "account.auth_failed"
+	EventAccountStatement    = "account.statement_ready"
+
+	// 流量异常
+	EventAnomalyRequestRate = "anomaly.request_rate"
+	EventAnomalyErrorRate   = "anomaly.error_rate"
+	EventAnomalyTokenRate   = "anomaly.token_rate"
+	EventAnomalyCostRate    = "anomaly.cost_rate"
 
 	// 系统相关
 	EventSystemTunnelStarted = "system.tunnel_started"
@@ -56,11 +67,6 @@ type ManagerConfig struct {
 	QueueSize   int
 	WorkerCount int
 	DedupWindow time.Duration
-	Logger      Logger
+	Logger      *logging.Logger
 	SendTimeout time.Duration
 }
-
-// Logger 抽象日志接口，兼容标准 log.Logger。
-type Logger interface {
-	Printf(format string, v ...interface{})
-}
@@ -0,0 +1,467 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"qcc_plus/internal/logging"
+	"qcc_plus/internal/notify"
+	"qcc_plus/internal/store"
+)
+
+const (
+	defaultAnomalyInterval    = time.Minute
+	defaultAnomalyAlpha       = 0.1
+	defaultAnomalyThreshold   = 4.0
+	defaultAnomalyWarmup      = 30
+	defaultAnomalyMinRequests = 20
+	defaultAnomalyDriftRatio  = 3.0
+	defaultAnomalyLongAlpha   = 0.005
+	anomalyBaselineTTL        = 24 * time.Hour
+)
+
+// anomalyMetric 描述一项被检测的流量指标。
+type anomalyMetric struct {
+	name      string
+	eventType string
+	label     string
+	unit      string
+	// minBand 偏差带下限，避免基线极平稳时微小波动触发告警。
+	minBand float64
+	// rate 为 true 时无流量窗口按 0 计入基线；比率类指标无流量时不更新。
+	rate bool
+	// lower 为 true 时账号汇总低于基线下限也告警（流量骤降）。
+	lower bool
+}
+
+// 异常类型。
+const (
+	anomalySpike = "spike" // 节点指标高于短期基线上限
+	anomalyDrop  = "drop"  // 账号汇总低于短期基线下限
+	anomalyDrift = "drift" // 账号汇总短期均值持续高于长期基线，短期基线跟随缓慢爬升时仍能发现
+)
+
+var anomalyMetrics = []anomalyMetric{
+	{name: "request_rate", eventType: notify.EventAnomalyRequestRate, label: "请求速率", unit: "次/分钟", minBand: 5, rate: true, lower: true},
+	{name: "error_rate", eventType: notify.EventAnomalyErrorRate, label: "错误率", unit: "%", minBand: 0.05},
+	{name: "token_rate", eventType: notify.EventAnomalyTokenRate, label: "Token 速率", unit: "tokens/分钟", minBand: 1000, rate: true, lower: true},
+	{name: "cost_rate", eventType: notify.EventAnomalyCostRate, label: "费用速率", unit: "USD/分钟", minBand: 0.01, rate: true},
+}
+
+// AnomalyConfig 流量异常检测参数。
+type AnomalyConfig struct {
+	Enabled     bool
+	Interval    time.Duration
+	Alpha       float64 // EWMA 平滑系数，越大越快适应新水平
+	Threshold   float64 // 超过基线 Threshold 倍标准差视为异常
+	Warmup      int     // 基线样本数达到后才开始告警
+	MinRequests int64   // 错误率仅在窗口请求数达到该值时参与检测
+	DriftRatio  float64 // 账号短期均值超过长期基线该倍数视为持续上升
+	LongAlpha   float64 // 长期基线平滑系数，需小于 Alpha
+	// DashboardURL 通知中附带的管理后台地址前缀，为空时仅给出相对路径。
+	DashboardURL string
+}
+
+func loadAnomalyConfig(logger *logging.Logger) AnomalyConfig {
+	cfg := AnomalyConfig{
+		Enabled:      parseEnvBool("ANOMALY_DETECTION_ENABLED", true, logger),
+		Interval:     parseEnvDuration("ANOMALY_INTERVAL", defaultAnomalyInterval, logger),
+		Alpha:        parseEnvFloat("ANOMALY_ALPHA", defaultAnomalyAlpha, logger),
+		Threshold:    parseEnvFloat("ANOMALY_THRESHOLD", defaultAnomalyThreshold, logger),
+		Warmup:       parseEnvInt("ANOMALY_WARMUP", defaultAnomalyWarmup, logger),
+		MinRequests:  int64(parseEnvInt("ANOMALY_MIN_REQUESTS", defaultAnomalyMinRequests, logger)),
+		DriftRatio:   parseEnvFloat("ANOMALY_DRIFT_RATIO", defaultAnomalyDriftRatio, logger),
+		LongAlpha:    parseEnvFloat("ANOMALY_LONG_ALPHA", defaultAnomalyLongAlpha, logger),
+		DashboardURL: strings.TrimRight(os.Getenv("ANOMALY_DASHBOARD_URL"), "/"),
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultAnomalyInterval
+	}
+	if cfg.Alpha <= 0 || cfg.Alpha >= 1 {
+		cfg.Alpha = defaultAnomalyAlpha
+	}
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = defaultAnomalyThreshold
+	}
+	if cfg.DriftRatio <= 1 {
+		cfg.DriftRatio = defaultAnomalyDriftRatio
+	}
+	if cfg.LongAlpha <= 0 || cfg.LongAlpha >= cfg.Alpha {
+		cfg.LongAlpha = cfg.Alpha / 20
+	}
+	return cfg
+}
+
+// ewmaBaseline 指数加权的均值与方差，另以更小的平滑系数维护长期均值。
+type ewmaBaseline struct {
+	mean      float64
+	variance  float64
+	long      float64 // 长期均值
+	samples   int
+	anomalous bool // 当前处于异常状态，回落前不重复告警
+	driftRun  int  // 短期均值连续高于长期基线上限的窗口数
+}
+
+func (b *ewmaBaseline) update(x, alpha, longAlpha float64) {
+	if b.samples == 0 {
+		b.mean = x
+		b.long = x
+		b.samples = 1
+		return
+	}
+	diff := x - b.mean
+	incr := alpha * diff
+	b.mean += incr
+	b.variance = (1 - alpha) * (b.variance + diff*incr)
+	b.long += longAlpha * (x - b.long)
+	b.samples++
+}
+
+// anomalyKey 检测对象；nodeID 为空表示账号汇总。
+type anomalyKey struct {
+	accountID string
+	nodeID    string
+}
+
+type anomalyState struct {
+	baselines map[string]*ewmaBaseline
+	lastSeen  time.Time
+}
+
+// anomalyFinding 一次检测到的异常。
+type anomalyFinding struct {
+	accountID string
+	nodeID    string
+	metric    anomalyMetric
+	kind      string
+	observed  float64
+	baseline  float64
+	limit     float64
+	window    time.Time
+}
+
+// AnomalyDetector 按分钟读取流量汇总，为每个账号/节点维护 EWMA 基线并在显著偏离时发送通知。
+type AnomalyDetector struct {
+	store  *store.Store
+	cfg    AnomalyConfig
+	logger *logging.Logger
+
+	// isLeader 多实例部署时仅主实例检测；为空表示单实例。
+	isLeader func() bool
+	// publish 发送通知，为空时仅记录日志。
+	publish func(notify.Event)
+	// nodeName 将节点 ID 解析为展示名称，为空时直接使用 ID。
+	nodeName func(id string) string
+
+	mu         sync.Mutex
+	states     map[anomalyKey]*anomalyState
+	lastWindow time.Time
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewAnomalyDetector 创建检测器。
+func NewAnomalyDetector(s *store.Store, cfg AnomalyConfig, logger *logging.Logger) *AnomalyDetector {
+	if logger == nil {
+		logger = logging.Default()
+	}
+	return &AnomalyDetector{
+		store:  s,
+		cfg:    cfg,
+		logger: logger.Component("anomaly"),
+		states: make(map[anomalyKey]*anomalyState),
+		stopCh: make(chan struct{}),
+	}
+}
+
+// Start 启动检测循环。
+func (d *AnomalyDetector) Start() error {
+	if d == nil || d.store == nil {
+		return nil
+	}
+	d.wg.Add(1)
+	go d.loop()
+	return nil
+}
+
+// Stop 停止检测循环并等待退出。
+func (d *AnomalyDetector) Stop() {
+	if d == nil {
+		return
+	}
+	d.stopOnce.Do(func() {
+		close(d.stopCh)
+	})
+	d.wg.Wait()
+}
+
+func (d *AnomalyDetector) loop() {
+	defer d.wg.Done()
+	defer func() {
+		if r := recover(); r != nil {
+			d.logger.Error("anomaly detector panic recovered", "panic", r)
+		}
+	}()
+
+	ticker := time.NewTicker(d.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.stopCh:
+			return
+		case now := <-ticker.C:
+			d.tick(now.UTC())
+		}
+	}
+}
+
+// tick 检测上一个完整窗口。窗口整体后移一个周期，给请求结束后异步写入的指标留出余量。
+func (d *AnomalyDetector) tick(now time.Time) {
+	if d.isLeader != nil && !d.isLeader() {
+		// 主实例切换后基线需在新主实例上重新预热。
+		d.reset()
+		return
+	}
+	end := now.Truncate(d.cfg.Interval).Add(-d.cfg.Interval)
+	start := end.Add(-d.cfg.Interval)
+
+	d.mu.Lock()
+	if !d.lastWindow.IsZero() && !start.After(d.lastWindow) {
+		d.mu.Unlock()
+		return
+	}
+	d.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	samples, err := d.store.QueryTrafficWindow(ctx, start, end)
+	if err != nil {
+		d.logger.Error("query traffic window failed", logging.KeyError, err)
+		return
+	}
+	for _, f := range d.observe(start, samples) {
+		d.notify(f)
+	}
+}
+
+func (d *AnomalyDetector) reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.states) > 0 {
+		d.states = make(map[anomalyKey]*anomalyState)
+	}
+	d.lastWindow = time.Time{}
+}
+
+// observe 将一个窗口的样本计入基线并返回新出现的异常。节点样本检测突增，
+// 按账号汇总的样本检测骤降与持续上升。
+func (d *AnomalyDetector) observe(window time.Time, samples []store.TrafficSample) []anomalyFinding {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.lastWindow = window
+
+	minutes := d.cfg.Interval.Minutes()
+	if minutes <= 0 {
+		minutes = 1
+	}
+	totals := make(map[string]*store.TrafficSample)
+	var accounts []string
+	for _, s := range samples {
+		t := totals[s.AccountID]
+		if t == nil {
+			t = &store.TrafficSample{AccountID: s.AccountID}
+			totals[s.AccountID] = t
+			accounts = append(accounts, s.AccountID)
+		}
+		t.RequestsTotal += s.RequestsTotal
+		t.RequestsFailed += s.RequestsFailed
+		t.Tokens += s.Tokens
+		t.CostUSD += s.CostUSD
+	}
+	samples = samples[:len(samples):len(samples)]
+	for _, id := range accounts {
+		samples = append(samples, *totals[id])
+	}
+
+	seen := make(map[anomalyKey]bool, len(samples))
+	var findings []anomalyFinding
+	for _, s := range samples {
+		key := anomalyKey{accountID: s.AccountID, nodeID: s.NodeID}
+		seen[key] = true
+		st := d.states[key]
+		if st == nil {
+			st = &anomalyState{baselines: make(map[string]*ewmaBaseline)}
+			d.states[key] = st
+		}
+		st.lastSeen = window
+		for _, m := range anomalyMetrics {
+			x, ok := d.metricValue(m, s, minutes)
+			if !ok {
+				continue
+			}
+			findings = append(findings, d.evaluate(st, key, m, x, window)...)
+		}
+	}
+
+	// 无流量的已知节点与账号按 0 计入速率基线，超过保留期则丢弃；账号整体无流量可触发骤降告警。
+	for key, st := range d.states {
+		if seen[key] {
+			continue
+		}
+		if window.Sub(st.lastSeen) > anomalyBaselineTTL {
+			delete(d.states, key)
+			continue
+		}
+		for _, m := range anomalyMetrics {
+			if !m.rate {
+				continue
+			}
+			if f := d.evaluate(st, key, m, 0, window); key.nodeID == "" {
+				findings = append(findings, f...)
+			}
+		}
+	}
+	return findings
+}
+
+// metricValue 计算样本的指标值；错误率在请求数不足时不参与检测。
+func (d *AnomalyDetector) metricValue(m anomalyMetric, s store.TrafficSample, minutes float64) (float64, bool) {
+	switch m.name {
+	case "request_rate":
+		return float64(s.RequestsTotal) / minutes, true
+	case "error_rate":
+		if s.RequestsTotal < d.cfg.MinRequests || s.RequestsTotal == 0 {
+			return 0, false
+		}
+		return float64(s.RequestsFailed) / float64(s.RequestsTotal), true
+	case "token_rate":
+		return float64(s.Tokens) / minutes, true
+	case "cost_rate":
+		return s.CostUSD / minutes, true
+	}
+	return 0, false
+}
+
+// evaluate 先与现有基线比较再更新基线；仅在由正常转为异常时返回告警。
+func (d *AnomalyDetector) evaluate(st *anomalyState, key anomalyKey, m anomalyMetric, x float64, window time.Time) []anomalyFinding {
+	b := st.baselines[m.name]
+	if b == nil {
+		b = &ewmaBaseline{}
+		st.baselines[m.name] = b
+	}
+	var findings []anomalyFinding
+	fire := func(kind string, observed, baseline, limit float64) {
+		findings = append(findings, anomalyFinding{
+			accountID: key.accountID,
+			nodeID:    key.nodeID,
+			metric:    m,
+			kind:      kind,
+			observed:  observed,
+			baseline:  baseline,
+			limit:     limit,
+			window:    window,
+		})
+	}
+	if b.samples >= d.cfg.Warmup {
+		band := math.Max(math.Sqrt(b.variance), 0.1*b.mean)
+		band = math.Max(band, m.minBand)
+		if key.nodeID != "" {
+			upper := b.mean + d.cfg.Threshold*band
+			if x > upper {
+				if !b.anomalous {
+					fire(anomalySpike, x, b.mean, upper)
+				}
+				b.anomalous = true
+			} else {
+				b.anomalous = false
+			}
+		} else {
+			lower := b.mean - d.cfg.Threshold*band
+			if m.lower && lower > 0 && x < lower {
+				if !b.anomalous {
+					fire(anomalyDrop, x, b.mean, lower)
+				}
+				b.anomalous = true
+			} else {
+				b.anomalous = false
+			}
+			// 需持续约一个短期平滑周期（1/Alpha 个窗口），单个窗口的突增不视为持续上升
+			limit := b.long * d.cfg.DriftRatio
+			if b.mean > limit && b.mean-b.long > m.minBand {
+				b.driftRun++
+				if b.driftRun == int(math.Ceil(1/d.cfg.Alpha)) {
+					fire(anomalyDrift, b.mean, b.long, limit)
+				}
+			} else {
+				b.driftRun = 0
+			}
+		}
+	}
+	b.update(x, d.cfg.Alpha, d.cfg.LongAlpha)
+	return findings
+}
+
+func (d *AnomalyDetector) notify(f anomalyFinding) {
+	node := f.nodeID
+	if node == "" {
+		node = "账号汇总"
+	} else if d.nodeName != nil {
+		if name := d.nodeName(f.nodeID); name != "" {
+			node = name
+		}
+	}
+	d.logger.Warn("traffic anomaly detected",
+		logging.KeyAccountID, f.accountID,
+		logging.KeyNodeID, f.nodeID,
+		"metric", f.metric.name,
+		"kind", f.kind,
+		"observed", f.observed,
+		"baseline", f.baseline,
+	)
+	if d.publish == nil {
+		return
+	}
+	link := d.cfg.DashboardURL + "/admin/monitor"
+	content := fmt.Sprintf("**节点**: %s\n**指标**: %s\n**观测值**: %s\n**基线**: %s\n**告警阈值**: %s\n**时间窗口**: %s\n**查看**: %s",
+		node,
+		f.metric.label,
+		formatAnomalyValue(f.metric, f.observed),
+		formatAnomalyValue(f.metric, f.baseline),
+		formatAnomalyValue(f.metric, f.limit),
+		f.window.Format("2006-01-02 15:04 MST"),
+		link,
+	)
+	title, dedup := "突增", f.accountID+"|"+f.nodeID+"|"+f.metric.name
+	switch f.kind {
+	case anomalyDrop:
+		title, dedup = "骤降", dedup+"|"+anomalyDrop
+	case anomalyDrift:
+		title, dedup = "持续上升", dedup+"|"+anomalyDrift
+	}
+	d.publish(notify.Event{
+		AccountID:  f.accountID,
+		EventType:  f.metric.eventType,
+		Title:      fmt.Sprintf("流量异常：%s %s%s", node, f.metric.label, title),
+		Content:    content,
+		DedupKey:   dedup,
+		OccurredAt: f.window,
+	})
+}
+
+func formatAnomalyValue(m anomalyMetric, v float64) string {
+	switch m.name {
+	case "error_rate":
+		return fmt.Sprintf("%.1f%s", v*100, m.unit)
+	case "cost_rate":
+		return fmt.Sprintf("%.4f %s", v, m.unit)
+	default:
+		return fmt.Sprintf("%.1f %s", v, m.unit)
+	}
+}
@@ -0,0 +1,175 @@
+package proxy
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"qcc_plus/internal/notify"
+	"qcc_plus/internal/store"
+)
+
+func newTestAnomalyDetector(st *store.Store, events *[]notify.Event) *AnomalyDetector {
+	d := NewAnomalyDetector(st, AnomalyConfig{
+		Enabled:      true,
+		Interval:     time.Minute,
+		Alpha:        0.1,
+		Threshold:    4,
+		Warmup:       10,
+		MinRequests:  20,
+		DashboardURL: "https://proxy.example.com",
+	}, nil)
+	d.publish = func(evt notify.Event) { *events = append(*events, evt) }
+	return d
+}
+
+// TestAnomalyDetectorAlertsOnSpikeOnce 测试突增只在进入异常状态时告警一次
+func TestAnomalyDetectorAlertsOnSpikeOnce(t *testing.T) {
+	var events []notify.Event
+	d := newTestAnomalyDetector(nil, &events)
+
+	window := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	steady := func(i int) store.TrafficSample {
+		return store.TrafficSample{AccountID: "acc", NodeID: "n1", RequestsTotal: int64(100 + i%5), RequestsFailed: 2, Tokens: 50000, CostUSD: 0.5}
+	}
+	for i := 0; i < 30; i++ {
+		for _, f := range d.observe(window, []store.TrafficSample{steady(i)}) {
+			d.notify(f)
+		}
+		window = window.Add(time.Minute)
+	}
+	if len(events) != 0 {
+		t.Fatalf("steady traffic should not alert, got %+v", events)
+	}
+
+	spike := store.TrafficSample{AccountID: "acc", NodeID: "n1", RequestsTotal: 1000, RequestsFailed: 20, Tokens: 50000, CostUSD: 0.5}
+	for i := 0; i < 3; i++ {
+		for _, f := range d.observe(window, []store.TrafficSample{spike}) {
+			d.notify(f)
+		}
+		window = window.Add(time.Minute)
+	}
+	if len(events) != 1 {
+		t.Fatalf("expected exactly one alert, got %d: %+v", len(events), events)
+	}
+	evt := events[0]
+	if evt.EventType != notify.EventAnomalyRequestRate || evt.AccountID != "acc" || evt.DedupKey != "acc|n1|request_rate" {
+		t.Fatalf("unexpected event %+v", evt)
+	}
+	if !strings.Contains(evt.Content, "1000.0") || !strings.Contains(evt.Content, "https://proxy.example.com/admin/monitor") {
+		t.Fatalf("content missing observed value or link: %s", evt.Content)
+	}
+}
+
+// TestAnomalyDetectorErrorRateNeedsVolume 测试低流量窗口不参与错误率检测
+func TestAnomalyDetectorErrorRateNeedsVolume(t *testing.T) {
+	var events []notify.Event
+	d := newTestAnomalyDetector(nil, &events)
+
+	window := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 20; i++ {
+		d.observe(window, []store.TrafficSample{{AccountID: "acc", NodeID: "n1", RequestsTotal: 100, RequestsFailed: 1}})
+		window = window.Add(time.Minute)
+	}
+	// 请求数骤降会触发账号级 drop，这里只关心错误率
+	for _, f := range d.observe(window, []store.TrafficSample{{AccountID: "acc", NodeID: "n1", RequestsTotal: 5, RequestsFailed: 5}}) {
+		if f.metric.name == "error_rate" {
+			t.Fatalf("low volume window should be ignored, got %+v", f)
+		}
+	}
+	window = window.Add(time.Minute)
+	f := d.observe(window, []store.TrafficSample{{AccountID: "acc", NodeID: "n1", RequestsTotal: 100, RequestsFailed: 60}})
+	if len(f) != 1 || f[0].metric.name != "error_rate" || f[0].kind != anomalySpike {
+		t.Fatalf("expected error rate finding, got %+v", f)
+	}
+}
+
+// TestAnomalyDetectorDropAndDrift 测试账号流量骤降与缓慢爬升的告警
+func TestAnomalyDetectorDropAndDrift(t *testing.T) {
+	var events []notify.Event
+	d := newTestAnomalyDetector(nil, &events)
+	d.cfg.DriftRatio = 3
+	d.cfg.LongAlpha = 0.005
+
+	window := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	step := func(requests int64) {
+		for _, f := range d.observe(window, []store.TrafficSample{{AccountID: "acc", NodeID: "n1", RequestsTotal: requests}}) {
+			d.notify(f)
+		}
+		window = window.Add(time.Minute)
+	}
+	for i := 0; i < 30; i++ {
+		step(100)
+	}
+	// 账号整体无流量
+	for _, f := range d.observe(window, nil) {
+		d.notify(f)
+	}
+	window = window.Add(time.Minute)
+	if len(events) != 1 || events[0].DedupKey != "acc||request_rate|drop" || !strings.Contains(events[0].Title, "骤降") {
+		t.Fatalf("expected one account drop alert, got %+v", events)
+	}
+
+	// 每分钟增长 3%，短期基线跟随爬升不会触发突增，但会超过长期基线
+	d.reset()
+	events = nil
+	for i := 0; i < 30; i++ {
+		step(100)
+	}
+	rate := 100.0
+	for i := 0; i < 120 && len(events) == 0; i++ {
+		rate *= 1.03
+		step(int64(rate))
+	}
+	if len(events) != 1 || events[0].DedupKey != "acc||request_rate|drift" || !strings.Contains(events[0].Title, "持续上升") {
+		t.Fatalf("expected one drift alert, got %+v", events)
+	}
+}
+
+// TestAnomalyDetectorTickReadsStore 测试按分钟窗口从原始监控与使用日志读取流量
+func TestAnomalyDetectorTickReadsStore(t *testing.T) {
+	st, err := store.OpenSQLite(filepath.Join(t.TempDir(), "anomaly.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer st.Close()
+
+	ctx := context.Background()
+	now := time.Date(2025, 3, 1, 10, 5, 30, 0, time.UTC)
+	window := time.Date(2025, 3, 1, 10, 3, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		if err := st.InsertMetrics(ctx, store.MetricsRecord{AccountID: "acc", NodeID: "n1", Timestamp: window.Add(time.Duration(i) * 10 * time.Second), RequestsTotal: 1, RequestsSuccess: 1, InputTokensTotal: 100, OutputTokensTotal: 20}); err != nil {
+			t.Fatalf("insert metrics: %v", err)
+		}
+	}
+	// 窗口外的数据不应计入。
+	if err := st.InsertMetrics(ctx, store.MetricsRecord{AccountID: "acc", NodeID: "n1", Timestamp: window.Add(time.Minute), RequestsTotal: 1}); err != nil {
+		t.Fatalf("insert metrics: %v", err)
+	}
+	if err := st.InsertUsageLog(ctx, store.UsageLogRecord{AccountID: "acc", NodeID: "n1", ModelID: "m", CostUSD: 0.25, Success: true, CreatedAt: window.Add(5 * time.Second)}); err != nil {
+		t.Fatalf("insert usage: %v", err)
+	}
+
+	samples, err := st.QueryTrafficWindow(ctx, window, window.Add(time.Minute))
+	if err != nil || len(samples) != 1 {
+		t.Fatalf("query window: %v (%d samples)", err, len(samples))
+	}
+	if s := samples[0]; s.RequestsTotal != 3 || s.Tokens != 360 || s.CostUSD != 0.25 {
+		t.Fatalf("unexpected sample %+v", s)
+	}
+
+	var events []notify.Event
+	d := newTestAnomalyDetector(st, &events)
+	d.tick(now)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.lastWindow.Equal(window) {
+		t.Fatalf("expected window %v, got %v", window, d.lastWindow)
+	}
+	b := d.states[anomalyKey{accountID: "acc", nodeID: "n1"}].baselines["request_rate"]
+	if b == nil || b.mean != 3 {
+		t.Fatalf("unexpected baseline %+v", b)
+	}
+}
@@ -127,6 +127,8 @@ func (p *Server) handleAccounts(w http.ResponseWriter, r *http.Request) {
 		delete(p.accounts, acc.ProxyAPIKey)
 		delete(p.accountByID, id)
 		p.mu.Unlock()
+		p.retryBudget.remove(id)
+		p.prom.removeAccount(id)
 		if p.store != nil {
 			_ = p.store.DeleteAccount(context.Background(), id)
 		}
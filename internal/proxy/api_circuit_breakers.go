@@ -0,0 +1,243 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"qcc_plus/internal/logging"
+	"qcc_plus/internal/store"
+	"qcc_plus/internal/timeutil"
+)
+
+type circuitBreakerView struct {
+	NodeID    string                 `json:"node_id"`
+	NodeName  string                 `json:"node_name"`
+	AccountID string                 `json:"account_id"`
+	State     string                 `json:"state"`
+	Forced    bool                   `json:"forced"`
+	ChangedAt string                 `json:"state_changed_at"`
+	Counters  CircuitBreakerSnapshot `json:"counters"`
+	Override  CircuitBreakerOverride `json:"override"`
+	Effective CircuitBreakerConfig   `json:"effective_config"`
+}
+
+func (p *Server) circuitBreakerView(n *Node) circuitBreakerView {
+	cfg, override := p.effectiveBreakerConfig(n.ID)
+	snap := p.getCircuitBreaker(n.ID).Snapshot()
+	view := circuitBreakerView{
+		NodeID:    n.ID,
+		NodeName:  n.Name,
+		AccountID: n.AccountID,
+		State:     snap.State,
+		Forced:    snap.Forced,
+		Counters:  snap,
+		Override:  override,
+		Effective: cfg,
+	}
+	if !snap.StateChangedAt.IsZero() {
+		view.ChangedAt = timeutil.FormatBeijingTime(snap.StateChangedAt)
+	}
+	return view
+}
+
+func circuitBreakerEventView(rec store.CircuitBreakerEventRecord) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                rec.ID,
+		"node_id":           rec.NodeID,
+		"account_id":        rec.AccountID,
+		"instance_id":       rec.InstanceID,
+		"from":              rec.FromState,
+		"to":                rec.ToState,
+		"reason":            rec.Reason,
+		"consecutive_fails": rec.ConsecutiveFails,
+		"failure_rate":      rec.FailureRate,
+		"created_at":        timeutil.FormatBeijingTime(rec.CreatedAt),
+	}
+}
+
+// GET /admin/api/circuit-breakers?account_id=xxx&state=open
+// 列出节点熔断器状态、计数器、覆盖项与生效配置（仅管理员）。
+func (p *Server) handleCircuitBreakers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !isAdmin(r.Context()) {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "forbidden"})
+		return
+	}
+	accountID := r.URL.Query().Get("account_id")
+	stateFilter := strings.ToLower(r.URL.Query().Get("state"))
+
+	p.mu.RLock()
+	nodes := make([]*Node, 0, len(p.nodeIndex))
+	for _, n := range p.nodeIndex {
+		if accountID != "" && n.AccountID != accountID {
+			continue
+		}
+		nodes = append(nodes, n)
+	}
+	p.mu.RUnlock()
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].AccountID != nodes[j].AccountID {
+			return nodes[i].AccountID < nodes[j].AccountID
+		}
+		return nodes[i].Weight < nodes[j].Weight
+	})
+
+	items := make([]circuitBreakerView, 0, len(nodes))
+	for _, n := range nodes {
+		view := p.circuitBreakerView(n)
+		if stateFilter != "" && view.State != stateFilter {
+			continue
+		}
+		items = append(items, view)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"enabled":       p.cbConfig.Enabled,
+		"global_config": p.cbConfig,
+		"breakers":      items,
+	})
+}
+
+// /admin/api/circuit-breakers/{node_id}[/trip|/reset|/override|/events]
+// - GET    /{node_id}           熔断器详情及最近转换日志
+// - POST   /{node_id}/trip      手动熔断（直到手动重置）
+// - POST   /{node_id}/reset     手动重置
+// - PUT    /{node_id}/override  设置节点参数覆盖；DELETE 清除覆盖
+// - GET    /{node_id}/events    状态转换日志（from/to/limit）
+func (p *Server) handleCircuitBreakerByNode(w http.ResponseWriter, r *http.Request) {
+	if !isAdmin(r.Context()) {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "forbidden"})
+		return
+	}
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/api/circuit-breakers/"), "/")
+	parts := strings.SplitN(rest, "/", 2)
+	nodeID := parts[0]
+	action := ""
+	if len(parts) > 1 {
+		action = parts[1]
+	}
+	if nodeID == "" {
+		http.NotFound(w, r)
+		return
+	}
+	node := p.getNode(nodeID)
+	if node == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "node not found"})
+		return
+	}
+
+	switch action {
+	case "":
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		resp := map[string]interface{}{"breaker": p.circuitBreakerView(node)}
+		if p.store != nil {
+			recs, err := p.store.ListCircuitBreakerEvents(r.Context(), "", nodeID, time.Time{}, time.Time{}, 20)
+			if err == nil {
+				events := make([]map[string]interface{}, 0, len(recs))
+				for _, rec := range recs {
+					events = append(events, circuitBreakerEventView(rec))
+				}
+				resp["recent_events"] = events
+			}
+		}
+		writeJSON(w, http.StatusOK, resp)
+	case "trip":
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		p.tripBreaker(nodeID)
+		p.publishCluster(ClusterEventBreaker, node.AccountID, nodeID, clusterBreakerChanged{Action: cbActionTrip})
+		p.logger.Component("breaker").Info("circuit breaker tripped manually", logging.KeyAccountID, node.AccountID, logging.KeyNodeID, nodeID, "node", node.Name)
+		writeJSON(w, http.StatusOK, p.circuitBreakerView(node))
+	case "reset":
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		p.resetBreaker(nodeID)
+		p.publishCluster(ClusterEventBreaker, node.AccountID, nodeID, clusterBreakerChanged{Action: cbActionReset})
+		p.logger.Component("breaker").Info("circuit breaker reset manually", logging.KeyAccountID, node.AccountID, logging.KeyNodeID, nodeID, "node", node.Name)
+		writeJSON(w, http.StatusOK, p.circuitBreakerView(node))
+	case "override":
+		var o CircuitBreakerOverride
+		switch r.Method {
+		case http.MethodPut:
+			if err := json.NewDecoder(r.Body).Decode(&o); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+				return
+			}
+			if o.WindowSeconds < 0 || o.ConsecutiveFails < 0 || o.CooldownSeconds < 0 || o.HalfOpenMaxCalls < 0 ||
+				o.FailureRate < 0 || o.FailureRate > 1 {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid override values"})
+				return
+			}
+		case http.MethodDelete:
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if p.store != nil {
+			if err := p.store.SaveCircuitBreakerOverride(r.Context(), store.CircuitBreakerRecord{
+				NodeID:           nodeID,
+				AccountID:        node.AccountID,
+				WindowSeconds:    o.WindowSeconds,
+				FailureRate:      o.FailureRate,
+				ConsecutiveFails: o.ConsecutiveFails,
+				CooldownSeconds:  o.CooldownSeconds,
+				HalfOpenMaxCalls: o.HalfOpenMaxCalls,
+			}); err != nil {
+				writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				return
+			}
+		}
+		p.setBreakerOverride(nodeID, o)
+		p.publishCluster(ClusterEventBreaker, node.AccountID, nodeID, clusterBreakerChanged{Action: cbActionOverride, Override: o})
+		writeJSON(w, http.StatusOK, p.circuitBreakerView(node))
+	case "events":
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if p.store == nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "store not enabled"})
+			return
+		}
+		q := r.URL.Query()
+		from, err := parseTime(q.Get("from"))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid from time"})
+			return
+		}
+		to, err := parseTime(q.Get("to"))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid to time"})
+			return
+		}
+		limit, _ := strconv.Atoi(q.Get("limit"))
+		if limit <= 0 || limit > 1000 {
+			limit = 100
+		}
+		recs, err := p.store.ListCircuitBreakerEvents(r.Context(), "", nodeID, from, to, limit)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		events := make([]map[string]interface{}, 0, len(recs))
+		for _, rec := range recs {
+			events = append(events, circuitBreakerEventView(rec))
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"events": events})
+	default:
+		http.NotFound(w, r)
+	}
+}
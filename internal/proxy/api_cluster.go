@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"qcc_plus/internal/timeutil"
+)
+
+// GET /admin/api/cluster/leader
+// 返回当前实例标识、是否为主实例以及租约持有者（仅管理员）。
+func (p *Server) handleClusterLeader(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !isAdmin(r.Context()) {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "forbidden"})
+		return
+	}
+	writeJSON(w, http.StatusOK, p.leader.Status(r.Context()))
+}
+
+// GET /admin/api/cluster/status
+// 返回集群事件通道类型、发布/应用/过期丢弃计数等（仅管理员）。
+func (p *Server) handleClusterStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !isAdmin(r.Context()) {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "forbidden"})
+		return
+	}
+	writeJSON(w, http.StatusOK, p.ClusterStatus())
+}
+
+// GET /admin/api/cluster/events?after=0&limit=100
+// 列出数据库中的集群事件（仅管理员，按版本升序）。
+func (p *Server) handleClusterEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !isAdmin(r.Context()) {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "forbidden"})
+		return
+	}
+	if p.store == nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "store not enabled"})
+		return
+	}
+	q := r.URL.Query()
+	after, _ := strconv.ParseInt(q.Get("after"), 10, 64)
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	recs, err := p.store.ListClusterEventsAfter(r.Context(), after, limit)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	items := make([]map[string]interface{}, 0, len(recs))
+	for _, rec := range recs {
+		item := map[string]interface{}{
+			"version":     rec.ID,
+			"instance_id": rec.InstanceID,
+			"type":        rec.EventType,
+			"account_id":  rec.AccountID,
+			"node_id":     rec.NodeID,
+			"occurred_at": timeutil.FormatBeijingTime(rec.CreatedAt),
+		}
+		if rec.Payload != "" {
+			item["payload"] = json.RawMessage(rec.Payload)
+		}
+		items = append(items, item)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"events": items})
+}
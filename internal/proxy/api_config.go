@@ -91,22 +91,34 @@ func (p *Server) updateConfigForAccount(acc *Account, retries, failLimit int, he
 		return errors.New("invalid config values")
 	}
 
-	p.mu.Lock()
-	acc.Config = Config{Retries: retries, FailLimit: failLimit, HealthEvery: healthEvery}
-	active := acc.ActiveID
-	if acc.ID == store.DefaultAccountID {
-		if rt, ok := p.transport.(*retryTransport); ok {
-			rt.attempts = retries
-		}
-		p.retries = retries
-		p.failLimit = failLimit
-		p.healthEvery = healthEvery
-	}
-	p.mu.Unlock()
+	active := p.setAccountConfig(acc, Config{Retries: retries, FailLimit: failLimit, HealthEvery: healthEvery})
 
 	if p.store != nil {
 		cfg := store.Config{Retries: retries, FailLimit: failLimit, HealthEvery: healthEvery}
-		return p.store.UpdateConfig(context.Background(), acc.ID, cfg, active)
+		if err := p.store.UpdateConfig(context.Background(), acc.ID, cfg, active); err != nil {
+			return err
+		}
 	}
+	p.publishCluster(ClusterEventAccountConfig, acc.ID, "", clusterAccountConfig{
+		Retries:        retries,
+		FailLimit:      failLimit,
+		HealthEverySec: int(healthEvery / time.Second),
+	})
 	return nil
 }
+
+// setAccountConfig 更新账号运行时配置（默认账号同步全局参数），返回当前活跃节点 ID。
+func (p *Server) setAccountConfig(acc *Account, cfg Config) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	acc.Config = cfg
+	if acc.ID == store.DefaultAccountID {
+		if rt, ok := p.transport.(*retryTransport); ok {
+			rt.attempts = cfg.Retries
+		}
+		p.retries = cfg.Retries
+		p.failLimit = cfg.FailLimit
+		p.healthEvery = cfg.HealthEvery
+	}
+	return acc.ActiveID
+}
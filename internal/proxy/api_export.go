@@ -0,0 +1,201 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"qcc_plus/internal/export"
+	"qcc_plus/internal/logging"
+	"qcc_plus/internal/store"
+)
+
+var usageExportColumns = []export.Column{
+	{Name: "id", Type: export.Int64},
+	{Name: "created_at", Type: export.Timestamp},
+	{Name: "account_id", Type: export.String},
+	{Name: "node_id", Type: export.String},
+	{Name: "model_id", Type: export.String},
+	{Name: "request_id", Type: export.String},
+	{Name: "upstream_request_id", Type: export.String},
+	{Name: "success", Type: export.Bool},
+	{Name: "input_tokens", Type: export.Int64},
+	{Name: "output_tokens", Type: export.Int64},
+	{Name: "cache_creation_tokens", Type: export.Int64},
+	{Name: "cache_read_tokens", Type: export.Int64},
+	{Name: "cost_usd", Type: export.Float64},
+	{Name: "pricing_rule_id", Type: export.String},
+	{Name: "actual_cost_usd", Type: export.Float64},
+}
+
+var metricsExportColumns = []export.Column{
+	{Name: "ts", Type: export.Timestamp},
+	{Name: "account_id", Type: export.String},
+	{Name: "node_id", Type: export.String},
+	{Name: "model_id", Type: export.String},
+	{Name: "requests_total", Type: export.Int64},
+	{Name: "requests_success", Type: export.Int64},
+	{Name: "requests_failed", Type: export.Int64},
+	{Name: "requests_canceled", Type: export.Int64},
+	{Name: "failed_4xx", Type: export.Int64},
+	{Name: "failed_5xx", Type: export.Int64},
+	{Name: "retry_attempts_total", Type: export.Int64},
+	{Name: "retry_success", Type: export.Int64},
+	{Name: "avg_response_time_ms", Type: export.Float64},
+	{Name: "p50_response_time_ms", Type: export.Float64},
+	{Name: "p95_response_time_ms", Type: export.Float64},
+	{Name: "p99_response_time_ms", Type: export.Float64},
+	{Name: "first_byte_time_sum_ms", Type: export.Int64},
+	{Name: "stream_duration_sum_ms", Type: export.Int64},
+	{Name: "bytes_total", Type: export.Int64},
+	{Name: "input_tokens", Type: export.Int64},
+	{Name: "output_tokens", Type: export.Int64},
+	{Name: "cache_creation_tokens", Type: export.Int64},
+	{Name: "cache_read_tokens", Type: export.Int64},
+}
+
+var healthExportColumns = []export.Column{
+	{Name: "check_time", Type: export.Timestamp},
+	{Name: "account_id", Type: export.String},
+	{Name: "node_id", Type: export.String},
+	{Name: "success", Type: export.Bool},
+	{Name: "response_time_ms", Type: export.Int64},
+	{Name: "error_category", Type: export.String},
+	{Name: "error_message", Type: export.String},
+	{Name: "check_method", Type: export.String},
+	{Name: "check_source", Type: export.String},
+}
+
+// exportRequest 导出接口的公共参数。
+type exportRequest struct {
+	format    export.Format
+	accountID string
+	nodeID    string
+	modelID   string
+	from      time.Time
+	to        time.Time
+}
+
+// parseExportRequest 解析公共参数；非管理员强制限定为本账号。
+func parseExportRequest(w http.ResponseWriter, r *http.Request) (*exportRequest, bool) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return nil, false
+	}
+	q := r.URL.Query()
+	req := &exportRequest{nodeID: q.Get("node_id"), modelID: q.Get("model_id")}
+	var err error
+	if req.format, err = export.ParseFormat(q.Get("format")); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return nil, false
+	}
+	if req.from, err = parseTime(q.Get("from")); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid from"})
+		return nil, false
+	}
+	if req.to, err = parseTime(q.Get("to")); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid to"})
+		return nil, false
+	}
+	if isAdmin(r.Context()) {
+		req.accountID = q.Get("account_id")
+	} else {
+		acc := accountFromCtx(r)
+		if acc == nil {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return nil, false
+		}
+		req.accountID = acc.ID
+	}
+	return req, true
+}
+
+// streamExport 设置下载响应头并把 fill 产出的行写出；响应头发出后的错误只能记录日志。
+func (p *Server) streamExport(w http.ResponseWriter, req *exportRequest, name string, cols []export.Column, fill func(write func(row []any) error) error) {
+	filename := fmt.Sprintf("%s-%s.%s", name, time.Now().UTC().Format("20060102-150405"), req.format.FileExt())
+	w.Header().Set("Content-Type", req.format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Header().Set("Cache-Control", "no-store")
+
+	ew, err := export.NewWriter(req.format, w, cols)
+	if err == nil {
+		err = fill(ew.Write)
+		if cerr := ew.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		p.logger.Warn("export failed", "export", name, logging.KeyAccountID, req.accountID, logging.KeyError, err)
+	}
+}
+
+// handleExportUsage GET /api/export/usage 导出使用日志
+func (p *Server) handleExportUsage(w http.ResponseWriter, r *http.Request) {
+	if p.store == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+	req, ok := parseExportRequest(w, r)
+	if !ok {
+		return
+	}
+	params := store.QueryUsageParams{AccountID: req.accountID, NodeID: req.nodeID, ModelID: req.modelID, From: req.from, To: req.to}
+	p.streamExport(w, req, "usage", usageExportColumns, func(write func([]any) error) error {
+		return p.store.StreamUsageLogs(r.Context(), params, func(l *store.UsageLogRecord) error {
+			return write([]any{l.ID, l.CreatedAt, l.AccountID, l.NodeID, l.ModelID, l.RequestID, l.UpstreamRequestID, l.Success,
+				l.InputTokens, l.OutputTokens, l.CacheCreationTokens, l.CacheReadTokens, l.CostUSD, l.PricingRuleID, l.ActualCostUSD})
+		})
+	})
+}
+
+// handleExportMetrics GET /api/export/metrics?granularity=raw|hour|day|month 导出监控数据
+func (p *Server) handleExportMetrics(w http.ResponseWriter, r *http.Request) {
+	if p.store == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+	req, ok := parseExportRequest(w, r)
+	if !ok {
+		return
+	}
+	gran, err := parseGranularity(r.URL.Query().Get("granularity"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	q := store.MetricsQuery{AccountID: req.accountID, NodeID: req.nodeID, ModelID: req.modelID, From: req.from, To: req.to, Granularity: gran}
+	p.streamExport(w, req, "metrics-"+string(gran), metricsExportColumns, func(write func([]any) error) error {
+		return p.store.StreamMetrics(r.Context(), q, func(m *store.MetricsRecord) error {
+			var avg float64
+			if m.ResponseTimeCount > 0 {
+				avg = float64(m.ResponseTimeSumMs) / float64(m.ResponseTimeCount)
+			}
+			return write([]any{m.Timestamp, m.AccountID, m.NodeID, m.ModelID,
+				m.RequestsTotal, m.RequestsSuccess, m.RequestsFailed,
+				m.Failures.Canceled, m.Failures.Status4xx, m.Failures.Status5xx,
+				m.RetryAttemptsTotal, m.RetrySuccess,
+				avg, m.ResponseTimeHist.Quantile(0.5), m.ResponseTimeHist.Quantile(0.95), m.ResponseTimeHist.Quantile(0.99),
+				m.FirstByteTimeSumMs, m.StreamDurationSumMs, m.BytesTotal,
+				m.InputTokensTotal, m.OutputTokensTotal, m.CacheCreationTokensTotal, m.CacheReadTokensTotal})
+		})
+	})
+}
+
+// handleExportHealth GET /api/export/health 导出健康检查历史
+func (p *Server) handleExportHealth(w http.ResponseWriter, r *http.Request) {
+	if p.store == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+	req, ok := parseExportRequest(w, r)
+	if !ok {
+		return
+	}
+	params := store.QueryHealthCheckParams{AccountID: req.accountID, NodeID: req.nodeID, From: req.from, To: req.to, CheckSource: r.URL.Query().Get("source")}
+	p.streamExport(w, req, "health", healthExportColumns, func(write func([]any) error) error {
+		return p.store.StreamHealthChecks(r.Context(), params, func(h *store.HealthCheckRecord) error {
+			return write([]any{h.CheckTime, h.AccountID, h.NodeID, h.Success, h.ResponseTimeMs,
+				h.ErrorCategory, h.ErrorMessage, h.CheckMethod, h.CheckSource})
+		})
+	})
+}
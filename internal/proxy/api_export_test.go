@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"qcc_plus/internal/store"
+)
+
+// TestExportEndpoints 测试导出接口的格式、过滤条件与非管理员账号隔离
+func TestExportEndpoints(t *testing.T) {
+	st, err := store.OpenSQLite(filepath.Join(t.TempDir(), "export.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer st.Close()
+	srv := newClusterTestServer(t, NewLocalClusterBus(), "a")
+	srv.store = st
+
+	ctx := context.Background()
+	base := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	for i, acc := range []string{"acc1", "acc1", "acc2"} {
+		if err := st.InsertUsageLog(ctx, store.UsageLogRecord{AccountID: acc, NodeID: "n1", ModelID: "claude-sonnet-4-5",
+			InputTokens: int64(10 * (i + 1)), CostUSD: 0.5, Success: true, RequestID: "r" + string(rune('0'+i)), CreatedAt: base.Add(time.Duration(i) * time.Minute)}); err != nil {
+			t.Fatalf("insert usage: %v", err)
+		}
+		if err := st.InsertMetrics(ctx, store.MetricsRecord{AccountID: acc, NodeID: "n1", Timestamp: base.Add(time.Duration(i) * time.Minute),
+			RequestsTotal: 2, RequestsSuccess: 2, ResponseTimeSumMs: 300, ResponseTimeCount: 2}); err != nil {
+			t.Fatalf("insert metrics: %v", err)
+		}
+	}
+	if err := st.InsertHealthCheck(ctx, &store.HealthCheckRecord{AccountID: "acc1", NodeID: "n1", CheckTime: base, Success: true, ResponseTimeMs: 120, CheckMethod: "api", CheckSource: "scheduled"}); err != nil {
+		t.Fatalf("insert health: %v", err)
+	}
+
+	userCtx := context.WithValue(ctx, accountContextKey{}, &Account{ID: "acc1"})
+	req := httptest.NewRequest(http.MethodGet, "/api/export/usage?account_id=acc2", nil).WithContext(userCtx)
+	rec := httptest.NewRecorder()
+	srv.handleExportUsage(rec, req)
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	if rec.Code != http.StatusOK || len(lines) != 3 || !strings.HasPrefix(lines[0], "id,created_at,account_id") || !strings.Contains(lines[2], ",acc1,n1,claude-sonnet-4-5,r1,") {
+		t.Fatalf("unexpected usage csv %d %q", rec.Code, rec.Body.String())
+	}
+	if cd := rec.Header().Get("Content-Disposition"); !strings.Contains(cd, "attachment") || !strings.Contains(cd, ".csv") {
+		t.Fatalf("unexpected content disposition %q", cd)
+	}
+
+	adminCtx := context.WithValue(ctx, isAdminContextKey{}, true)
+	req = httptest.NewRequest(http.MethodGet, "/api/export/metrics?format=ndjson&from="+base.Add(30*time.Second).Format(time.RFC3339), nil).WithContext(adminCtx)
+	rec = httptest.NewRecorder()
+	srv.handleExportMetrics(rec, req)
+	lines = strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 metrics rows across accounts, got %q", rec.Body.String())
+	}
+	var row map[string]any
+	if err := json.Unmarshal([]byte(lines[1]), &row); err != nil || row["account_id"] != "acc2" || row["avg_response_time_ms"] != 150.0 {
+		t.Fatalf("unexpected metrics row %v err=%v", row, err)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/export/health?format=parquet", nil).WithContext(adminCtx)
+	rec = httptest.NewRecorder()
+	srv.handleExportHealth(rec, req)
+	body := rec.Body.String()
+	if rec.Header().Get("Content-Type") != "application/vnd.apache.parquet" || !strings.HasPrefix(body, "PAR1") || !strings.HasSuffix(body, "PAR1") || !strings.Contains(body, "scheduled") {
+		t.Fatalf("unexpected parquet export (%d bytes)", len(body))
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/export/usage?format=xlsx", nil).WithContext(adminCtx)
+	rec = httptest.NewRecorder()
+	srv.handleExportUsage(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected bad request for unknown format, got %d", rec.Code)
+	}
+}
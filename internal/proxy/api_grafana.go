@@ -0,0 +1,628 @@
+package proxy
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"qcc_plus/internal/store"
+)
+
+// Grafana JSON datasource 兼容接口（simple-json / simpod-json-datasource 约定）：
+//
+//	GET  /api/grafana              连通性测试
+//	POST /api/grafana/search       指标名或变量取值（target 为 accounts/nodes/nodes:<account_id>）
+//	POST /api/grafana/metrics      指标列表及可选 payload
+//	POST /api/grafana/query        时间序列与表格
+//	POST /api/grafana/annotations  节点故障/恢复/切换标注
+//
+// 通过 GRAFANA_TOKEN 只读令牌鉴权（Bearer 或 Basic 密码），未配置时接口关闭。
+
+const grafanaMaxAnnotations = 1000
+
+// grafanaSeries 时间序列指标，按数据来源区分。
+var grafanaSeries = []struct {
+	name   string
+	source string // metrics | health
+	label  string
+}{
+	{"requests_total", "metrics", "请求总数"},
+	{"requests_success", "metrics", "成功请求数"},
+	{"requests_failed", "metrics", "失败请求数"},
+	{"requests_canceled", "metrics", "客户端取消数"},
+	{"failed_4xx", "metrics", "4xx 失败数"},
+	{"failed_5xx", "metrics", "5xx 失败数"},
+	{"success_rate", "metrics", "成功率（%）"},
+	{"avg_response_time_ms", "metrics", "平均响应时间（毫秒）"},
+	{"p50_response_time_ms", "metrics", "P50 响应时间（毫秒）"},
+	{"p95_response_time_ms", "metrics", "P95 响应时间（毫秒）"},
+	{"p99_response_time_ms", "metrics", "P99 响应时间（毫秒）"},
+	{"avg_first_byte_ms", "metrics", "平均首字节时间（毫秒）"},
+	{"input_tokens", "metrics", "输入 tokens"},
+	{"output_tokens", "metrics", "输出 tokens"},
+	{"cache_creation_tokens", "metrics", "缓存写入 tokens"},
+	{"cache_read_tokens", "metrics", "缓存读取 tokens"},
+	{"bytes_total", "metrics", "响应字节数"},
+	{"health_success_rate", "health", "健康检查成功率（%）"},
+	{"health_response_time_ms", "health", "健康检查平均耗时（毫秒）"},
+}
+
+// grafanaTables 表格指标，来自使用汇总。
+var grafanaTables = []struct {
+	name  string
+	label string
+}{
+	{"usage_summary", "使用汇总"},
+	{"usage_by_model", "按模型使用汇总"},
+	{"usage_by_node", "按节点使用汇总"},
+}
+
+func loadGrafanaToken() string {
+	return strings.TrimSpace(os.Getenv("GRAFANA_TOKEN"))
+}
+
+// grafanaPayload 查询目标的过滤条件，兼容 payload（新版）与 data（simple-json）字段。
+type grafanaPayload struct {
+	AccountID string `json:"account_id"`
+	NodeID    string `json:"node_id"`
+	ModelID   string `json:"model_id"`
+	GroupBy   string `json:"group_by"` // account | node | model，为空时合并为一条序列
+}
+
+type grafanaTarget struct {
+	Target  string          `json:"target"`
+	RefID   string          `json:"refId"`
+	Type    string          `json:"type"`
+	Hide    bool            `json:"hide"`
+	Payload *grafanaPayload `json:"payload"`
+	Data    *grafanaPayload `json:"data"`
+}
+
+func (t grafanaTarget) filter() grafanaPayload {
+	if t.Payload != nil {
+		return *t.Payload
+	}
+	if t.Data != nil {
+		return *t.Data
+	}
+	return grafanaPayload{}
+}
+
+type grafanaRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+func (r grafanaRange) parse() (time.Time, time.Time, error) {
+	from, err := time.Parse(time.RFC3339Nano, r.From)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid range.from")
+	}
+	to, err := time.Parse(time.RFC3339Nano, r.To)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid range.to")
+	}
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf("range.from must be before range.to")
+	}
+	return from.UTC(), to.UTC(), nil
+}
+
+// handleGrafana 分发 /api/grafana 下的请求。
+func (p *Server) handleGrafana(w http.ResponseWriter, r *http.Request) {
+	if p.grafanaToken == "" || p.store == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "grafana datasource disabled"})
+		return
+	}
+	if !p.grafanaAuthorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="grafana"`)
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	sub := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/grafana"), "/")
+	if sub == "" {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	switch sub {
+	case "/search":
+		p.handleGrafanaSearch(w, r)
+	case "/metrics":
+		p.handleGrafanaMetrics(w, r)
+	case "/query":
+		p.handleGrafanaQuery(w, r)
+	case "/annotations":
+		p.handleGrafanaAnnotations(w, r)
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+	}
+}
+
+func (p *Server) grafanaAuthorized(r *http.Request) bool {
+	got := ""
+	if _, pass, ok := r.BasicAuth(); ok {
+		got = pass
+	} else if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		got = strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+	return got != "" && subtle.ConstantTimeCompare([]byte(got), []byte(p.grafanaToken)) == 1
+}
+
+// POST /api/grafana/search
+func (p *Server) handleGrafanaSearch(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Target string `json:"target"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&req)
+
+	type option struct {
+		Text  string `json:"text"`
+		Value string `json:"value"`
+	}
+	target := strings.TrimSpace(req.Target)
+	switch {
+	case target == "accounts":
+		p.mu.RLock()
+		opts := make([]option, 0, len(p.accountByID))
+		for _, acc := range p.accountByID {
+			opts = append(opts, option{Text: acc.Name, Value: acc.ID})
+		}
+		p.mu.RUnlock()
+		sort.Slice(opts, func(i, j int) bool { return opts[i].Text < opts[j].Text })
+		writeJSON(w, http.StatusOK, opts)
+	case target == "nodes" || strings.HasPrefix(target, "nodes:"):
+		accountID := strings.TrimPrefix(strings.TrimPrefix(target, "nodes"), ":")
+		p.mu.RLock()
+		opts := make([]option, 0, len(p.nodeIndex))
+		for id, n := range p.nodeIndex {
+			if accountID != "" && n.AccountID != accountID {
+				continue
+			}
+			opts = append(opts, option{Text: n.Name, Value: id})
+		}
+		p.mu.RUnlock()
+		sort.Slice(opts, func(i, j int) bool { return opts[i].Text < opts[j].Text })
+		writeJSON(w, http.StatusOK, opts)
+	default:
+		names := make([]string, 0, len(grafanaSeries)+len(grafanaTables))
+		for _, s := range grafanaSeries {
+			if target == "" || strings.Contains(s.name, target) {
+				names = append(names, s.name)
+			}
+		}
+		for _, t := range grafanaTables {
+			if target == "" || strings.Contains(t.name, target) {
+				names = append(names, t.name)
+			}
+		}
+		writeJSON(w, http.StatusOK, names)
+	}
+}
+
+// POST /api/grafana/metrics
+func (p *Server) handleGrafanaMetrics(w http.ResponseWriter, r *http.Request) {
+	payloads := []map[string]interface{}{
+		{"name": "account_id", "label": "账号 ID", "type": "input"},
+		{"name": "node_id", "label": "节点 ID", "type": "input"},
+		{"name": "model_id", "label": "模型", "type": "input"},
+		{"name": "group_by", "label": "分组", "type": "select", "options": []map[string]string{
+			{"label": "不分组", "value": ""},
+			{"label": "账号", "value": "account"},
+			{"label": "节点", "value": "node"},
+			{"label": "模型", "value": "model"},
+		}},
+	}
+	out := make([]map[string]interface{}, 0, len(grafanaSeries)+len(grafanaTables))
+	for _, s := range grafanaSeries {
+		out = append(out, map[string]interface{}{"label": s.label, "value": s.name, "payloads": payloads})
+	}
+	for _, t := range grafanaTables {
+		out = append(out, map[string]interface{}{"label": t.label, "value": t.name, "payloads": payloads[:3]})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// POST /api/grafana/query
+func (p *Server) handleGrafanaQuery(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Range      grafanaRange    `json:"range"`
+		IntervalMs int64           `json:"intervalMs"`
+		Targets    []grafanaTarget `json:"targets"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+		return
+	}
+	from, to, err := req.Range.parse()
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	interval := time.Duration(req.IntervalMs) * time.Millisecond
+
+	out := make([]interface{}, 0, len(req.Targets))
+	for _, t := range req.Targets {
+		if t.Hide || t.Target == "" {
+			continue
+		}
+		var (
+			res []interface{}
+			err error
+		)
+		switch {
+		case isGrafanaTable(t.Target):
+			res, err = p.grafanaTable(r, t, from, to)
+		case grafanaSeriesSource(t.Target) == "metrics":
+			res, err = p.grafanaMetricsSeries(r, t, from, to, interval)
+		case grafanaSeriesSource(t.Target) == "health":
+			res, err = p.grafanaHealthSeries(r, t, from, to, interval)
+		default:
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unknown target: " + t.Target})
+			return
+		}
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		out = append(out, res...)
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func isGrafanaTable(name string) bool {
+	for _, t := range grafanaTables {
+		if t.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func grafanaSeriesSource(name string) string {
+	for _, s := range grafanaSeries {
+		if s.name == name {
+			return s.source
+		}
+	}
+	return ""
+}
+
+// grafanaGranularity 按查询跨度选择监控数据粒度，并给出不小于该粒度的分桶间隔。
+func grafanaGranularity(from, to time.Time, interval time.Duration) (store.MetricsGranularity, time.Duration) {
+	span := to.Sub(from)
+	var gran store.MetricsGranularity
+	var step time.Duration
+	switch {
+	case span <= 24*time.Hour:
+		gran, step = store.MetricsGranularityRaw, time.Minute
+	case span <= 31*24*time.Hour:
+		gran, step = store.MetricsGranularityHourly, time.Hour
+	case span <= 366*24*time.Hour:
+		gran, step = store.MetricsGranularityDaily, 24*time.Hour
+	default:
+		// 月桶不等长，直接使用每条记录自身的时间戳。
+		return store.MetricsGranularityMonthly, 0
+	}
+	if interval > step {
+		step = interval.Truncate(step)
+	}
+	return gran, step
+}
+
+// grafanaSeriesKey 返回记录所属序列的分组值。
+func (p *Server) grafanaSeriesKey(groupBy, accountID, nodeID, modelID string) string {
+	switch groupBy {
+	case "account":
+		if acc := p.getAccountByID(accountID); acc != nil && acc.Name != "" {
+			return acc.Name
+		}
+		return accountID
+	case "node":
+		if n := p.getNode(nodeID); n != nil && n.Name != "" {
+			return n.Name
+		}
+		return nodeID
+	case "model":
+		return chooseNonEmpty(modelID, "unknown")
+	default:
+		return ""
+	}
+}
+
+func grafanaSeriesName(target, group string) string {
+	if group == "" {
+		return target
+	}
+	return target + " {" + group + "}"
+}
+
+func (p *Server) grafanaMetricsSeries(r *http.Request, t grafanaTarget, from, to time.Time, interval time.Duration) ([]interface{}, error) {
+	f := t.filter()
+	gran, step := grafanaGranularity(from, to, interval)
+	type bucketKey struct {
+		group string
+		ts    time.Time
+	}
+	buckets := make(map[bucketKey]*store.MetricsRecord)
+	q := store.MetricsQuery{AccountID: f.AccountID, NodeID: f.NodeID, ModelID: f.ModelID, Granularity: gran, From: from, To: to}
+	err := p.store.StreamMetrics(r.Context(), q, func(rec *store.MetricsRecord) error {
+		ts := rec.Timestamp.UTC()
+		if step > 0 {
+			ts = ts.Truncate(step)
+		}
+		k := bucketKey{group: p.grafanaSeriesKey(f.GroupBy, rec.AccountID, rec.NodeID, rec.ModelID), ts: ts}
+		cur := buckets[k]
+		if cur == nil {
+			cur = &store.MetricsRecord{Timestamp: ts}
+			buckets[k] = cur
+		}
+		accumulateMetrics(cur, *rec)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	series := make(map[string][][2]float64)
+	for k, rec := range buckets {
+		v, ok := grafanaMetricValue(t.Target, rec)
+		if !ok {
+			continue
+		}
+		series[k.group] = append(series[k.group], [2]float64{v, float64(k.ts.UnixMilli())})
+	}
+	return grafanaTimeseries(t.Target, series), nil
+}
+
+// grafanaMetricValue 计算单个桶的指标值；分位数在桶内无样本时返回 false。
+func grafanaMetricValue(target string, rec *store.MetricsRecord) (float64, bool) {
+	switch target {
+	case "requests_total":
+		return float64(rec.RequestsTotal), true
+	case "requests_success":
+		return float64(rec.RequestsSuccess), true
+	case "requests_failed":
+		return float64(rec.RequestsFailed), true
+	case "requests_canceled":
+		return float64(rec.Failures.Canceled), true
+	case "failed_4xx":
+		return float64(rec.Failures.Status4xx), true
+	case "failed_5xx":
+		return float64(rec.Failures.Status5xx), true
+	case "success_rate":
+		if rec.RequestsTotal <= 0 {
+			return 0, false
+		}
+		return float64(rec.RequestsSuccess) * 100 / float64(rec.RequestsTotal), true
+	case "avg_response_time_ms":
+		return safeDiv(rec.ResponseTimeSumMs, rec.ResponseTimeCount), rec.ResponseTimeCount > 0
+	case "p50_response_time_ms", "p95_response_time_ms", "p99_response_time_ms":
+		if rec.ResponseTimeHist.Count() == 0 {
+			return 0, false
+		}
+		q := map[string]float64{"p50_response_time_ms": 0.5, "p95_response_time_ms": 0.95, "p99_response_time_ms": 0.99}[target]
+		return rec.ResponseTimeHist.Quantile(q), true
+	case "avg_first_byte_ms":
+		return safeDiv(rec.FirstByteTimeSumMs, rec.ResponseTimeCount), rec.ResponseTimeCount > 0
+	case "input_tokens":
+		return float64(rec.InputTokensTotal), true
+	case "output_tokens":
+		return float64(rec.OutputTokensTotal), true
+	case "cache_creation_tokens":
+		return float64(rec.CacheCreationTokensTotal), true
+	case "cache_read_tokens":
+		return float64(rec.CacheReadTokensTotal), true
+	case "bytes_total":
+		return float64(rec.BytesTotal), true
+	}
+	return 0, false
+}
+
+func (p *Server) grafanaHealthSeries(r *http.Request, t grafanaTarget, from, to time.Time, interval time.Duration) ([]interface{}, error) {
+	f := t.filter()
+	step := interval
+	if step < time.Minute {
+		step = time.Minute
+	}
+	type bucketKey struct {
+		group string
+		ts    time.Time
+	}
+	type agg struct {
+		total, success, latencySum int64
+	}
+	buckets := make(map[bucketKey]*agg)
+	params := store.QueryHealthCheckParams{AccountID: f.AccountID, NodeID: f.NodeID, From: from, To: to}
+	err := p.store.StreamHealthChecks(r.Context(), params, func(rec *store.HealthCheckRecord) error {
+		k := bucketKey{group: p.grafanaSeriesKey(f.GroupBy, rec.AccountID, rec.NodeID, ""), ts: rec.CheckTime.UTC().Truncate(step)}
+		cur := buckets[k]
+		if cur == nil {
+			cur = &agg{}
+			buckets[k] = cur
+		}
+		cur.total++
+		if rec.Success {
+			cur.success++
+			cur.latencySum += int64(rec.ResponseTimeMs)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	series := make(map[string][][2]float64)
+	for k, a := range buckets {
+		var v float64
+		switch t.Target {
+		case "health_success_rate":
+			v = float64(a.success) * 100 / float64(a.total)
+		case "health_response_time_ms":
+			if a.success == 0 {
+				continue
+			}
+			v = safeDiv(a.latencySum, a.success)
+		}
+		series[k.group] = append(series[k.group], [2]float64{v, float64(k.ts.UnixMilli())})
+	}
+	return grafanaTimeseries(t.Target, series), nil
+}
+
+// grafanaTimeseries 输出 [{target, datapoints: [[value, unix_ms], ...]}]，序列与数据点均有序。
+func grafanaTimeseries(target string, series map[string][][2]float64) []interface{} {
+	groups := make([]string, 0, len(series))
+	for g := range series {
+		groups = append(groups, g)
+	}
+	sort.Strings(groups)
+	out := make([]interface{}, 0, len(groups))
+	for _, g := range groups {
+		points := series[g]
+		sort.Slice(points, func(i, j int) bool { return points[i][1] < points[j][1] })
+		out = append(out, map[string]interface{}{
+			"target":     grafanaSeriesName(target, g),
+			"datapoints": points,
+		})
+	}
+	return out
+}
+
+func (p *Server) grafanaTable(r *http.Request, t grafanaTarget, from, to time.Time) ([]interface{}, error) {
+	f := t.filter()
+	params := store.QueryUsageParams{AccountID: f.AccountID, NodeID: f.NodeID, ModelID: f.ModelID, From: from, To: to}
+	var (
+		rows    []store.UsageSummary
+		keyCol  map[string]string
+		keyFunc func(store.UsageSummary) string
+		err     error
+	)
+	switch t.Target {
+	case "usage_summary":
+		var sum *store.UsageSummary
+		sum, err = p.store.GetUsageSummary(r.Context(), params)
+		if sum != nil {
+			rows = []store.UsageSummary{*sum}
+		}
+	case "usage_by_model":
+		rows, err = p.store.GetUsageSummaryByModel(r.Context(), params)
+		keyCol = map[string]string{"text": "model_id", "type": "string"}
+		keyFunc = func(s store.UsageSummary) string { return s.ModelID }
+	case "usage_by_node":
+		rows, err = p.store.GetUsageSummaryByNode(r.Context(), params)
+		keyCol = map[string]string{"text": "node", "type": "string"}
+		keyFunc = func(s store.UsageSummary) string { return p.grafanaSeriesKey("node", "", s.NodeID, "") }
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make([]map[string]string, 0, 8)
+	if keyCol != nil {
+		columns = append(columns, keyCol)
+	}
+	for _, c := range []string{"total_requests", "success_requests", "input_tokens", "output_tokens", "cache_creation_tokens", "cache_read_tokens", "cost_usd"} {
+		columns = append(columns, map[string]string{"text": c, "type": "number"})
+	}
+	tableRows := make([][]interface{}, 0, len(rows))
+	for _, s := range rows {
+		row := make([]interface{}, 0, len(columns))
+		if keyFunc != nil {
+			row = append(row, keyFunc(s))
+		}
+		row = append(row, s.TotalRequests, s.SuccessRequests, s.TotalInputTokens, s.TotalOutputTokens,
+			s.TotalCacheCreationTokens, s.TotalCacheReadTokens, s.TotalCostUSD)
+		tableRows = append(tableRows, row)
+	}
+	return []interface{}{map[string]interface{}{
+		"type":    "table",
+		"refId":   t.RefID,
+		"columns": columns,
+		"rows":    tableRows,
+	}}, nil
+}
+
+// POST /api/grafana/annotations
+// annotation.query 为 URL 查询串，支持 account_id、node_id 与 type（failed,recovered,switched）。
+func (p *Server) handleGrafanaAnnotations(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Range      grafanaRange           `json:"range"`
+		Annotation map[string]interface{} `json:"annotation"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+		return
+	}
+	from, to, err := req.Range.parse()
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	query, _ := req.Annotation["query"].(string)
+	filter, err := url.ParseQuery(strings.TrimSpace(query))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid annotation query"})
+		return
+	}
+	var types []string
+	for _, t := range strings.Split(filter.Get("type"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types = append(types, t)
+		}
+	}
+
+	events, err := p.store.ListNodeEvents(r.Context(), filter.Get("account_id"), types, from, to, grafanaMaxAnnotations)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	nodeID := filter.Get("node_id")
+	out := make([]map[string]interface{}, 0, len(events))
+	for i := len(events) - 1; i >= 0; i-- {
+		ev := events[i]
+		if nodeID != "" && ev.NodeID != nodeID && ev.FromNodeID != nodeID {
+			continue
+		}
+		node := p.grafanaSeriesKey("node", "", ev.NodeID, "")
+		var title string
+		switch ev.EventType {
+		case store.NodeEventFailed:
+			title = "节点故障: " + node
+		case store.NodeEventRecovered:
+			title = "节点恢复: " + node
+		case store.NodeEventSwitched:
+			prev := "-"
+			if ev.FromNodeID != "" {
+				prev = p.grafanaSeriesKey("node", "", ev.FromNodeID, "")
+			}
+			title = fmt.Sprintf("节点切换: %s → %s", prev, node)
+		default:
+			title = ev.EventType + ": " + node
+		}
+		item := map[string]interface{}{
+			"time":  ev.CreatedAt.UnixMilli(),
+			"title": title,
+			"text":  ev.Message,
+			"tags":  []string{ev.EventType, node},
+		}
+		if req.Annotation != nil {
+			item["annotation"] = req.Annotation
+		}
+		out = append(out, item)
+	}
+	writeJSON(w, http.StatusOK, out)
+}
@@ -0,0 +1,99 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"qcc_plus/internal/store"
+)
+
+func grafanaRequest(t *testing.T, srv *Server, path, token, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	method := http.MethodPost
+	if body == "" {
+		method = http.MethodGet
+	}
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+// TestGrafanaDatasource 测试 Grafana 数据源的鉴权、时间序列、表格与节点切换标注
+func TestGrafanaDatasource(t *testing.T) {
+	st, err := store.OpenSQLite(filepath.Join(t.TempDir(), "grafana.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer st.Close()
+	srv := newClusterTestServer(t, NewLocalClusterBus(), "a")
+	srv.store = st
+
+	if rec := grafanaRequest(t, srv, "/api/grafana", "", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected disabled datasource, got %d", rec.Code)
+	}
+	srv.grafanaToken = "ro-token"
+	if rec := grafanaRequest(t, srv, "/api/grafana", "wrong", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for wrong token, got %d", rec.Code)
+	}
+	if rec := grafanaRequest(t, srv, "/api/grafana/", "ro-token", ""); rec.Code != http.StatusOK {
+		t.Fatalf("connection test failed: %d %s", rec.Code, rec.Body.String())
+	}
+
+	ctx := context.Background()
+	base := time.Now().UTC().Add(-time.Hour).Truncate(time.Minute)
+	for i, node := range []string{"n1", "n1", "n2"} {
+		if err := st.InsertMetrics(ctx, store.MetricsRecord{AccountID: "acc-1", NodeID: node, Timestamp: base.Add(time.Duration(i*10) * time.Second),
+			RequestsTotal: 1, RequestsSuccess: 1, ResponseTimeSumMs: 100, ResponseTimeCount: 1}); err != nil {
+			t.Fatalf("insert metrics: %v", err)
+		}
+	}
+	if err := st.InsertUsageLog(ctx, store.UsageLogRecord{AccountID: "acc-1", NodeID: "n1", ModelID: "claude-sonnet-4-5", InputTokens: 10, CostUSD: 0.2, Success: true, CreatedAt: base}); err != nil {
+		t.Fatalf("insert usage: %v", err)
+	}
+
+	rng := `"range":{"from":"` + base.Add(-time.Minute).Format(time.RFC3339) + `","to":"` + base.Add(10*time.Minute).Format(time.RFC3339) + `"}`
+	rec := grafanaRequest(t, srv, "/api/grafana/query", "ro-token", `{`+rng+`,"intervalMs":60000,"targets":[
+		{"refId":"A","target":"requests_total","payload":{"account_id":"acc-1","group_by":"node"}},
+		{"refId":"B","target":"usage_by_model","type":"table"}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("query failed: %d %s", rec.Code, rec.Body.String())
+	}
+	var resp []map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || len(resp) != 3 {
+		t.Fatalf("expected 2 series and 1 table, got %v %s", err, rec.Body.String())
+	}
+	if resp[0]["target"] != "requests_total {n1}" || resp[1]["target"] != "requests_total {n2}" {
+		t.Fatalf("unexpected series names: %v, %v", resp[0]["target"], resp[1]["target"])
+	}
+	points := resp[0]["datapoints"].([]interface{})
+	if len(points) != 1 || points[0].([]interface{})[0].(float64) != 2 || int64(points[0].([]interface{})[1].(float64)) != base.UnixMilli() {
+		t.Fatalf("unexpected n1 datapoints %v", points)
+	}
+	if rows := resp[2]["rows"].([]interface{}); resp[2]["type"] != "table" || len(rows) != 1 || rows[0].([]interface{})[0] != "claude-sonnet-4-5" {
+		t.Fatalf("unexpected table %v", resp[2])
+	}
+
+	if err := srv.activate("n2"); err != nil {
+		t.Fatalf("activate: %v", err)
+	}
+	now := time.Now().UTC()
+	rec = grafanaRequest(t, srv, "/api/grafana/annotations", "ro-token", `{"range":{"from":"`+now.Add(-time.Minute).Format(time.RFC3339)+
+		`","to":"`+now.Add(time.Minute).Format(time.RFC3339)+`"},"annotation":{"name":"switch","query":"account_id=acc-1&type=switched"}}`)
+	var anns []map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &anns); err != nil || len(anns) != 1 {
+		t.Fatalf("expected one annotation, got %v %s", err, rec.Body.String())
+	}
+	if title, _ := anns[0]["title"].(string); !strings.Contains(title, "n1 → n2") {
+		t.Fatalf("unexpected annotation %v", anns[0])
+	}
+}
@@ -145,6 +145,7 @@ func (p *Server) handleGetHealthHistory(w http.ResponseWriter, r *http.Request)
 			"success":          rec.Success,
 			"response_time_ms": rec.ResponseTimeMs,
 			"error_message":    rec.ErrorMessage,
+			"error_category":   rec.ErrorCategory,
 			"check_method":     rec.CheckMethod,
 			"check_source":     rec.CheckSource,
 		})
@@ -58,39 +58,29 @@ func (p *Server) handleGetNodeMetrics(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	byModel, err := parseMetricsGroupBy(r)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	// 同一时间桶按模型分行存储，由数据库按时间桶分页后再合并当前页
 	q := store.MetricsQuery{
 		AccountID:   node.AccountID,
 		NodeID:      nodeID,
+		ModelID:     r.URL.Query().Get("model_id"),
 		From:        from,
 		To:          to,
 		Granularity: gran,
 		Limit:       limit,
 		Offset:      offset,
 	}
-	records, err := p.store.QueryMetrics(r.Context(), q)
+	records, err := p.store.QueryMetricsPage(r.Context(), q, byModel)
 	if err != nil {
 		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
-
-	data := make([]map[string]interface{}, 0, len(records))
-	for _, rec := range records {
-		avgResp := safeDiv(rec.ResponseTimeSumMs, rec.ResponseTimeCount)
-		avgFirst := safeDiv(rec.FirstByteTimeSumMs, rec.ResponseTimeCount)
-		avgStream := safeDiv(rec.StreamDurationSumMs, rec.ResponseTimeCount)
-		data = append(data, map[string]interface{}{
-			"timestamp":              timeutil.FormatBeijingTime(rec.Timestamp),
-			"requests_total":         rec.RequestsTotal,
-			"requests_success":       rec.RequestsSuccess,
-			"requests_failed":        rec.RequestsFailed,
-			"avg_response_time_ms":   avgResp,
-			"bytes_total":            rec.BytesTotal,
-			"input_tokens":           rec.InputTokensTotal,
-			"output_tokens":          rec.OutputTokensTotal,
-			"avg_first_byte_ms":      avgFirst,
-			"avg_stream_duration_ms": avgStream,
-		})
-	}
+	data := metricsResponseData(mergeMetricsRecords(records, byModel), byModel)
 
 	respondJSON(w, http.StatusOK, map[string]interface{}{
 		"data":        data,
@@ -132,69 +122,27 @@ func (p *Server) handleGetAccountMetrics(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	byModel, err := parseMetricsGroupBy(r)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
 	q := store.MetricsQuery{
 		AccountID:   accountID,
+		ModelID:     r.URL.Query().Get("model_id"),
 		Granularity: gran,
 		From:        from,
 		To:          to,
-		Limit:       0, // 聚合账号需先取全量再分页
-		Offset:      0,
+		Limit:       limit, // 按时间桶分页，同一桶内各节点的记录合并输出
+		Offset:      offset,
 	}
-	records, err := p.store.QueryMetrics(r.Context(), q)
+	records, err := p.store.QueryMetricsPage(r.Context(), q, byModel)
 	if err != nil {
 		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
-
-	agg := make(map[time.Time]store.MetricsRecord)
-	for _, rec := range records {
-		ts := rec.Timestamp.UTC()
-		cur := agg[ts]
-		cur.Timestamp = ts
-		cur.RequestsTotal += rec.RequestsTotal
-		cur.RequestsSuccess += rec.RequestsSuccess
-		cur.RequestsFailed += rec.RequestsFailed
-		cur.ResponseTimeSumMs += rec.ResponseTimeSumMs
-		cur.ResponseTimeCount += rec.ResponseTimeCount
-		cur.BytesTotal += rec.BytesTotal
-		cur.InputTokensTotal += rec.InputTokensTotal
-		cur.OutputTokensTotal += rec.OutputTokensTotal
-		cur.FirstByteTimeSumMs += rec.FirstByteTimeSumMs
-		cur.StreamDurationSumMs += rec.StreamDurationSumMs
-		agg[ts] = cur
-	}
-
-	keys := make([]time.Time, 0, len(agg))
-	for ts := range agg {
-		keys = append(keys, ts)
-	}
-	sort.Slice(keys, func(i, j int) bool { return keys[i].Before(keys[j]) })
-
-	start := offset
-	if start > len(keys) {
-		start = len(keys)
-	}
-	end := len(keys)
-	if limit > 0 && start+limit < end {
-		end = start + limit
-	}
-
-	data := make([]map[string]interface{}, 0, end-start)
-	for _, ts := range keys[start:end] {
-		rec := agg[ts]
-		data = append(data, map[string]interface{}{
-			"timestamp":              timeutil.FormatBeijingTime(ts),
-			"requests_total":         rec.RequestsTotal,
-			"requests_success":       rec.RequestsSuccess,
-			"requests_failed":        rec.RequestsFailed,
-			"avg_response_time_ms":   safeDiv(rec.ResponseTimeSumMs, rec.ResponseTimeCount),
-			"bytes_total":            rec.BytesTotal,
-			"input_tokens":           rec.InputTokensTotal,
-			"output_tokens":          rec.OutputTokensTotal,
-			"avg_first_byte_ms":      safeDiv(rec.FirstByteTimeSumMs, rec.ResponseTimeCount),
-			"avg_stream_duration_ms": safeDiv(rec.StreamDurationSumMs, rec.ResponseTimeCount),
-		})
-	}
+	data := metricsResponseData(mergeMetricsRecords(records, byModel), byModel)
 
 	respondJSON(w, http.StatusOK, map[string]interface{}{
 		"data":        data,
@@ -415,9 +363,124 @@ func extractAccountIDFromPath(path string) (string, bool) {
 	return trimmed, true
 }
 
+// parseMetricsGroupBy 解析 group_by 参数，目前仅支持按模型拆分。
+func parseMetricsGroupBy(r *http.Request) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(r.URL.Query().Get("group_by"))) {
+	case "":
+		return false, nil
+	case "model":
+		return true, nil
+	default:
+		return false, fmt.Errorf("unsupported group_by")
+	}
+}
+
+// mergeMetricsRecords 将同一时间桶（byModel 时为同一时间桶与模型）的记录合并，按时间、模型升序返回。
+func mergeMetricsRecords(records []store.MetricsRecord, byModel bool) []store.MetricsRecord {
+	type key struct {
+		ts    time.Time
+		model string
+	}
+	agg := make(map[key]*store.MetricsRecord)
+	keys := make([]key, 0)
+	for _, rec := range records {
+		k := key{ts: rec.Timestamp.UTC()}
+		if byModel {
+			k.model = rec.ModelID
+		}
+		cur, ok := agg[k]
+		if !ok {
+			cur = &store.MetricsRecord{Timestamp: k.ts, ModelID: k.model}
+			agg[k] = cur
+			keys = append(keys, k)
+		}
+		accumulateMetrics(cur, rec)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].ts.Equal(keys[j].ts) {
+			return keys[i].ts.Before(keys[j].ts)
+		}
+		return keys[i].model < keys[j].model
+	})
+	out := make([]store.MetricsRecord, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, *agg[k])
+	}
+	return out
+}
+
+// accumulateMetrics 将 rec 的计数与直方图累加到 cur，不修改时间与维度字段。
+func accumulateMetrics(cur *store.MetricsRecord, rec store.MetricsRecord) {
+	cur.RequestsTotal += rec.RequestsTotal
+	cur.RequestsSuccess += rec.RequestsSuccess
+	cur.RequestsFailed += rec.RequestsFailed
+	cur.RetryAttemptsTotal += rec.RetryAttemptsTotal
+	cur.RetrySuccess += rec.RetrySuccess
+	cur.ResponseTimeSumMs += rec.ResponseTimeSumMs
+	cur.ResponseTimeCount += rec.ResponseTimeCount
+	cur.BytesTotal += rec.BytesTotal
+	cur.InputTokensTotal += rec.InputTokensTotal
+	cur.OutputTokensTotal += rec.OutputTokensTotal
+	cur.CacheCreationTokensTotal += rec.CacheCreationTokensTotal
+	cur.CacheReadTokensTotal += rec.CacheReadTokensTotal
+	cur.FirstByteTimeSumMs += rec.FirstByteTimeSumMs
+	cur.StreamDurationSumMs += rec.StreamDurationSumMs
+	cur.ResponseTimeHist.Merge(rec.ResponseTimeHist)
+	cur.FirstByteHist.Merge(rec.FirstByteHist)
+	cur.Failures.Add(rec.Failures)
+}
+
+// metricsResponseData 转换为接口输出格式；byModel 时每项带 model_id。
+func metricsResponseData(records []store.MetricsRecord, byModel bool) []map[string]interface{} {
+	data := make([]map[string]interface{}, 0, len(records))
+	for _, rec := range records {
+		item := map[string]interface{}{
+			"timestamp":              timeutil.FormatBeijingTime(rec.Timestamp),
+			"requests_total":         rec.RequestsTotal,
+			"requests_success":       rec.RequestsSuccess,
+			"requests_failed":        rec.RequestsFailed,
+			"avg_response_time_ms":   safeDiv(rec.ResponseTimeSumMs, rec.ResponseTimeCount),
+			"bytes_total":            rec.BytesTotal,
+			"input_tokens":           rec.InputTokensTotal,
+			"output_tokens":          rec.OutputTokensTotal,
+			"avg_first_byte_ms":      safeDiv(rec.FirstByteTimeSumMs, rec.ResponseTimeCount),
+			"avg_stream_duration_ms": safeDiv(rec.StreamDurationSumMs, rec.ResponseTimeCount),
+			"requests_canceled":      rec.Failures.Canceled,
+			"failed_4xx":             rec.Failures.Status4xx,
+			"failed_5xx":             rec.Failures.Status5xx,
+			"errors_by_category":     rec.Failures.ByCategory(),
+		}
+		if byModel {
+			item["model_id"] = rec.ModelID
+		}
+		addLatencyPercentiles(item, rec)
+		data = append(data, item)
+	}
+	return data
+}
+
 func safeDiv(sum int64, count int64) float64 {
 	if count <= 0 {
 		return 0
 	}
 	return float64(sum) / float64(count)
 }
+
+// latencyQuantiles 接口输出的分位数。
+var latencyQuantiles = []struct {
+	name string
+	q    float64
+}{{"p50", 0.5}, {"p90", 0.9}, {"p95", 0.95}, {"p99", 0.99}}
+
+// addLatencyPercentiles 按直方图写入 p50/p90/p95/p99 响应耗时与首字节时间（毫秒）。
+// 直方图为空（升级前的历史数据）时不输出，避免前端误显示为 0。
+func addLatencyPercentiles(item map[string]interface{}, rec store.MetricsRecord) {
+	for _, lq := range latencyQuantiles {
+		if rec.ResponseTimeHist.Count() > 0 {
+			item[lq.name+"_response_time_ms"] = rec.ResponseTimeHist.Quantile(lq.q)
+		}
+		if rec.FirstByteHist.Count() > 0 {
+			item[lq.name+"_first_byte_ms"] = rec.FirstByteHist.Quantile(lq.q)
+		}
+	}
+}
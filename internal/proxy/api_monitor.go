@@ -6,6 +6,7 @@ import (
 	"sort"
 	"time"
 
+	"qcc_plus/internal/logging"
 	"qcc_plus/internal/store"
 	"qcc_plus/internal/timeutil"
 )
@@ -15,6 +16,8 @@ type MonitorDashboardResponse struct {
 	AccountName string        `json:"account_name"`
 	Nodes       []MonitorNode `json:"nodes"`
 	UpdatedAt   string        `json:"updated_at"`
+	// Uptime 仅在分享链接开启 include_uptime 时返回
+	Uptime *UptimeReport `json:"uptime,omitempty"`
 }
 
 // ProxySummary 代理流量指标
@@ -35,18 +38,20 @@ type HealthSummary struct {
 }
 
 type MonitorNode struct {
-	ID          string        `json:"id"`
-	Name        string        `json:"name"`
-	URL         string        `json:"url"`
-	Status      string        `json:"status"` // 综合状态: online/degraded/offline/unknown/disabled
-	Weight      int           `json:"weight"`
-	IsActive    bool          `json:"is_active"`
-	CircuitOpen bool          `json:"circuit_open"` // 熔断器是否打开
-	Disabled    bool          `json:"disabled"`
-	LastError   string        `json:"last_error"`
-	Traffic     ProxySummary  `json:"traffic"` // 代理流量指标
-	Health      HealthSummary `json:"health"`  // 健康检查指标
-	Trend24h    []TrendPoint  `json:"trend_24h"`
+	ID           string        `json:"id"`
+	Name         string        `json:"name"`
+	URL          string        `json:"url"`
+	Status       string        `json:"status"` // 综合状态: online/degraded/offline/unknown/disabled
+	Weight       int           `json:"weight"`
+	IsActive     bool          `json:"is_active"`
+	CircuitOpen  bool          `json:"circuit_open"`  // 熔断器是否打开
+	CircuitState string        `json:"circuit_state"` // 熔断器状态: closed/open/half-open
+	Disabled     bool          `json:"disabled"`
+	LastError    string        `json:"last_error"`
+	ErrCategory  string        `json:"last_error_category,omitempty"`
+	Traffic      ProxySummary  `json:"traffic"` // 代理流量指标
+	Health       HealthSummary `json:"health"`  // 健康检查指标
+	Trend24h     []TrendPoint  `json:"trend_24h"`
 }
 
 type TrendPoint struct {
@@ -172,7 +177,7 @@ func (p *Server) buildMonitorDashboardResponse(ctx context.Context, target *Acco
 		recs, err := p.store.GetNodes24hTrend(ctx, accountID, nodeIDs)
 		if err != nil {
 			if p.logger != nil {
-				p.logger.Printf("get trend failed account=%s: %v", accountID, err)
+				p.logger.Component("api").Warn("get trend failed", logging.KeyAccountID, accountID, logging.KeyError, err)
 			}
 		} else {
 			trendRecords = recs
@@ -209,24 +214,24 @@ func (p *Server) buildMonitorDashboardResponse(ctx context.Context, target *Acco
 		}
 
 		// 检查熔断器状态
-		circuitOpen := false
-		if cb := p.getCircuitBreaker(snap.ID); cb != nil {
-			circuitOpen = cb.GetState() == StateOpen
-		}
+		circuitState := p.getCircuitBreaker(snap.ID).GetState()
+		circuitOpen := circuitState == StateOpen
 
 		nodes = append(nodes, MonitorNode{
-			ID:          snap.ID,
-			Name:        snap.Name,
-			URL:         snap.URL,
-			Status:      status,
-			Weight:      snap.Weight,
-			IsActive:    snap.ID == activeID,
-			CircuitOpen: circuitOpen,
-			Disabled:    snap.Disabled,
-			LastError:   lastError,
-			Traffic:     traffic,
-			Health:      health,
-			Trend24h:    buildTrendPoints(trendRecords[snap.ID]),
+			ID:           snap.ID,
+			Name:         snap.Name,
+			URL:          snap.URL,
+			Status:       status,
+			Weight:       snap.Weight,
+			IsActive:     snap.ID == activeID,
+			CircuitOpen:  circuitOpen,
+			CircuitState: circuitState.String(),
+			Disabled:     snap.Disabled,
+			LastError:    lastError,
+			ErrCategory:  snap.Metrics.LastErrorCategory,
+			Traffic:      traffic,
+			Health:       health,
+			Trend24h:     buildTrendPoints(trendRecords[snap.ID]),
 		})
 	}
 
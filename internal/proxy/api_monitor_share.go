@@ -16,6 +16,8 @@ import (
 type CreateMonitorShareRequest struct {
 	AccountID string `json:"account_id"` // 可选，管理员可指定，普通用户只能创建自己的
 	ExpireIn  string `json:"expire_in"`  // "1h", "24h", "168h"(7天), "permanent"
+	// IncludeUptime 为 true 时分享页附带可用性（SLA）报表
+	IncludeUptime bool `json:"include_uptime"`
 }
 
 type CreateMonitorShareResponse struct {
@@ -83,11 +85,12 @@ func (p *Server) handleCreateMonitorShare(w http.ResponseWriter, r *http.Request
 
 	now := time.Now().UTC()
 	rec := store.MonitorShareRecord{
-		ID:        fmt.Sprintf("share-%d", now.UnixNano()),
-		AccountID: target.ID,
-		Token:     token,
-		CreatedBy: caller.Name,
-		CreatedAt: now,
+		ID:            fmt.Sprintf("share-%d", now.UnixNano()),
+		AccountID:     target.ID,
+		Token:         token,
+		CreatedBy:     caller.Name,
+		CreatedAt:     now,
+		IncludeUptime: req.IncludeUptime,
 	}
 	if !expireAt.IsZero() {
 		rec.ExpireAt = expireAt
@@ -185,15 +188,16 @@ func (p *Server) handleListMonitorShares(w http.ResponseWriter, r *http.Request)
 			revokedAt = &s
 		}
 		resp = append(resp, map[string]interface{}{
-			"id":         rec.ID,
-			"account_id": rec.AccountID,
-			"token":      rec.Token,
-			"share_url":  buildShareURL(r, rec.Token),
-			"expire_at":  expireStr,
-			"created_at": timeutil.FormatBeijingTime(rec.CreatedAt),
-			"created_by": rec.CreatedBy,
-			"revoked":    rec.Revoked,
-			"revoked_at": revokedAt,
+			"id":             rec.ID,
+			"account_id":     rec.AccountID,
+			"token":          rec.Token,
+			"share_url":      buildShareURL(r, rec.Token),
+			"expire_at":      expireStr,
+			"created_at":     timeutil.FormatBeijingTime(rec.CreatedAt),
+			"created_by":     rec.CreatedBy,
+			"revoked":        rec.Revoked,
+			"revoked_at":     revokedAt,
+			"include_uptime": rec.IncludeUptime,
 		})
 	}
 	writeJSON(w, http.StatusOK, map[string]interface{}{"shares": resp})
@@ -286,6 +290,9 @@ func (p *Server) handleAccessMonitorShare(w http.ResponseWriter, r *http.Request
 		shareError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "build dashboard failed")
 		return
 	}
+	if rec.IncludeUptime {
+		resp.Uptime = p.shareUptimeReport(r, acc)
+	}
 	writeJSON(w, http.StatusOK, resp)
 }
 
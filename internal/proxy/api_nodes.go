@@ -139,6 +139,11 @@ func (p *Server) listNodes(acc *Account) []map[string]interface{} {
 				healthRate = 0
 			}
 		}
+		// 缓存命中率 = 缓存读取 / 全部输入（未命中 + 缓存写入 + 缓存读取）
+		cacheHitRatio := 0.0
+		if totalIn := n.Metrics.TotalInputTokens + n.Metrics.TotalCacheCreationTokens + n.Metrics.TotalCacheReadTokens; totalIn > 0 {
+			cacheHitRatio = float64(n.Metrics.TotalCacheReadTokens) / float64(totalIn)
+		}
 		lastHealthCheckAt := timeutil.FormatBeijingTime(n.Metrics.LastHealthCheckAt)
 		views = append(views, nodeView{
 			weight:    n.Weight,
@@ -163,6 +168,9 @@ func (p *Server) listNodes(acc *Account) []map[string]interface{} {
 				"last_health_check_at":  lastHealthCheckAt,
 				"input_tokens":          n.Metrics.TotalInputTokens,
 				"output_tokens":         n.Metrics.TotalOutputTokens,
+				"cache_creation_tokens": n.Metrics.TotalCacheCreationTokens,
+				"cache_read_tokens":     n.Metrics.TotalCacheReadTokens,
+				"cache_hit_ratio":       cacheHitRatio,
 				"total_bytes":           n.Metrics.TotalBytes,
 				"stream_dur_ms":         n.Metrics.StreamDur.Milliseconds(),
 				"first_byte_ms":         n.Metrics.FirstByteDur.Milliseconds(),
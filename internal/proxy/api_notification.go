@@ -10,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	"qcc_plus/internal/logging"
 	"qcc_plus/internal/notify"
 	"qcc_plus/internal/store"
 	"qcc_plus/internal/timeutil"
@@ -103,7 +104,7 @@ func (p *Server) listNotificationChannels(w http.ResponseWriter, r *http.Request
 	defer cancel()
 	recs, err := p.store.ListNotificationChannels(ctx, acc.ID)
 	if err != nil {
-		p.logger.Printf("list notification channels failed: %v", err)
+		p.logger.Component("notify").Warn("list notification channels failed", logging.KeyError, err)
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "list channels failed"})
 		return
 	}
@@ -162,7 +163,7 @@ func (p *Server) createNotificationChannel(w http.ResponseWriter, r *http.Reques
 		UpdatedAt:   time.Now(),
 	}
 	if err := p.store.CreateNotificationChannel(context.Background(), rec); err != nil {
-		p.logger.Printf("create channel failed: %v", err)
+		p.logger.Component("notify").Warn("create channel failed", logging.KeyError, err)
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "create channel failed"})
 		return
 	}
@@ -186,7 +187,7 @@ func (p *Server) updateNotificationChannel(w http.ResponseWriter, r *http.Reques
 			writeJSON(w, http.StatusNotFound, map[string]string{"error": "channel not found"})
 			return
 		}
-		p.logger.Printf("get channel failed: %v", err)
+		p.logger.Component("notify").Warn("get channel failed", logging.KeyError, err)
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "get channel failed"})
 		return
 	}
@@ -235,7 +236,7 @@ func (p *Server) updateNotificationChannel(w http.ResponseWriter, r *http.Reques
 			writeJSON(w, http.StatusNotFound, map[string]string{"error": "channel not found"})
 			return
 		}
-		p.logger.Printf("update channel failed: %v", err)
+		p.logger.Component("notify").Warn("update channel failed", logging.KeyError, err)
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "update failed"})
 		return
 	}
@@ -259,7 +260,7 @@ func (p *Server) deleteNotificationChannel(w http.ResponseWriter, r *http.Reques
 			writeJSON(w, http.StatusNotFound, map[string]string{"error": "channel not found"})
 			return
 		}
-		p.logger.Printf("get channel failed: %v", err)
+		p.logger.Component("notify").Warn("get channel failed", logging.KeyError, err)
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "get channel failed"})
 		return
 	}
@@ -272,7 +273,7 @@ func (p *Server) deleteNotificationChannel(w http.ResponseWriter, r *http.Reques
 			writeJSON(w, http.StatusNotFound, map[string]string{"error": "channel not found"})
 			return
 		}
-		p.logger.Printf("delete channel failed: %v", err)
+		p.logger.Component("notify").Warn("delete channel failed", logging.KeyError, err)
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "delete failed"})
 		return
 	}
@@ -328,7 +329,7 @@ func (p *Server) listNotificationSubscriptions(w http.ResponseWriter, r *http.Re
 	defer cancel()
 	recs, err := p.store.ListNotificationSubscriptions(ctx, acc.ID, channelID)
 	if err != nil {
-		p.logger.Printf("list notification subscriptions failed: %v", err)
+		p.logger.Component("notify").Warn("list notification subscriptions failed", logging.KeyError, err)
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "list subscriptions failed"})
 		return
 	}
@@ -379,7 +380,7 @@ func (p *Server) createNotificationSubscription(w http.ResponseWriter, r *http.R
 			writeJSON(w, http.StatusNotFound, map[string]string{"error": "channel not found"})
 			return
 		}
-		p.logger.Printf("get channel failed: %v", err)
+		p.logger.Component("notify").Warn("get channel failed", logging.KeyError, err)
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "get channel failed"})
 		return
 	}
@@ -404,7 +405,7 @@ func (p *Server) createNotificationSubscription(w http.ResponseWriter, r *http.R
 			CreatedAt: now,
 		}
 		if err := p.store.UpsertNotificationSubscription(context.Background(), rec); err != nil {
-			p.logger.Printf("create subscription failed: %v", err)
+			p.logger.Component("notify").Warn("create subscription failed", logging.KeyError, err)
 			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "create subscription failed"})
 			return
 		}
@@ -430,7 +431,7 @@ func (p *Server) updateNotificationSubscription(w http.ResponseWriter, r *http.R
 			writeJSON(w, http.StatusNotFound, map[string]string{"error": "subscription not found"})
 			return
 		}
-		p.logger.Printf("get subscription failed: %v", err)
+		p.logger.Component("notify").Warn("get subscription failed", logging.KeyError, err)
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "get subscription failed"})
 		return
 	}
@@ -452,7 +453,7 @@ func (p *Server) updateNotificationSubscription(w http.ResponseWriter, r *http.R
 	rec.Enabled = *req.Enabled
 	rec.UpdatedAt = time.Now()
 	if err := p.store.UpsertNotificationSubscription(context.Background(), *rec); err != nil {
-		p.logger.Printf("update subscription failed: %v", err)
+		p.logger.Component("notify").Warn("update subscription failed", logging.KeyError, err)
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "update failed"})
 		return
 	}
@@ -476,7 +477,7 @@ func (p *Server) deleteNotificationSubscription(w http.ResponseWriter, r *http.R
 			writeJSON(w, http.StatusNotFound, map[string]string{"error": "subscription not found"})
 			return
 		}
-		p.logger.Printf("get subscription failed: %v", err)
+		p.logger.Component("notify").Warn("get subscription failed", logging.KeyError, err)
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "get subscription failed"})
 		return
 	}
@@ -489,7 +490,7 @@ func (p *Server) deleteNotificationSubscription(w http.ResponseWriter, r *http.R
 			writeJSON(w, http.StatusNotFound, map[string]string{"error": "subscription not found"})
 			return
 		}
-		p.logger.Printf("delete subscription failed: %v", err)
+		p.logger.Component("notify").Warn("delete subscription failed", logging.KeyError, err)
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "delete failed"})
 		return
 	}
@@ -540,7 +541,7 @@ func (p *Server) testNotification(w http.ResponseWriter, r *http.Request) {
 			writeJSON(w, http.StatusNotFound, map[string]string{"error": "channel not found"})
 			return
 		}
-		p.logger.Printf("get channel failed: %v", err)
+		p.logger.Component("notify").Warn("get channel failed", logging.KeyError, err)
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "get channel failed"})
 		return
 	}
@@ -563,7 +564,7 @@ func (p *Server) testNotification(w http.ResponseWriter, r *http.Request) {
 		OccurredAt: timeutil.NowBeijing(),
 	}
 	if err := ch.Send(ctx, msg); err != nil {
-		p.logger.Printf("send test notification failed: %v", err)
+		p.logger.Component("notify").Warn("send test notification failed", logging.KeyError, err)
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
@@ -673,6 +674,11 @@ func eventTypeCatalog() []eventTypeInfo {
 		{notify.EventRequestProxyError, "request", "代理错误"},
 		{notify.EventAccountQuotaWarning, "account", "账号配额预警"},
 		{notify.EventAccountAuthFailed, "account", "账号认证失败"},
+		{notify.EventAccountStatement, "account", "月度账单已生成"},
+		{notify.EventAnomalyRequestRate, "anomaly", "请求速率异常"},
+		{notify.EventAnomalyErrorRate, "anomaly", "错误率异常"},
+		{notify.EventAnomalyTokenRate, "anomaly", "Token 速率异常"},
+		{notify.EventAnomalyCostRate, "anomaly", "费用速率异常"},
 		{notify.EventSystemTunnelStarted, "system", "隧道启动"},
 		{notify.EventSystemTunnelStopped, "system", "隧道停止"},
 		{notify.EventSystemTunnelError, "system", "隧道错误"},
@@ -10,9 +10,10 @@ import (
 )
 
 // handlePricing 处理模型定价管理 API
-// GET    /api/pricing       - 列出所有定价
-// GET    /api/pricing?id=xx - 获取指定模型定价
-// POST   /api/pricing       - 创建或更新定价
+// GET    /api/pricing            - 列出所有定价
+// GET    /api/pricing?id=xx      - 获取指定模型定价
+// GET    /api/pricing?resolve=xx - 按精确 ID、模式与同族规则解析模型实际使用的定价
+// POST   /api/pricing            - 创建或更新定价（model_id 可为 glob 或 re: 正则模式）
 // DELETE /api/pricing?id=xx - 删除定价
 func (p *Server) handlePricing(w http.ResponseWriter, r *http.Request) {
 	if p.store == nil {
@@ -22,6 +23,19 @@ func (p *Server) handlePricing(w http.ResponseWriter, r *http.Request) {
 
 	switch r.Method {
 	case http.MethodGet:
+		if resolve := r.URL.Query().Get("resolve"); resolve != "" {
+			pricing, err := p.store.ResolveModelPricing(r.Context(), resolve)
+			if err == store.ErrNotFound {
+				writeJSON(w, http.StatusNotFound, map[string]string{"error": "pricing not found"})
+				return
+			}
+			if err != nil {
+				writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusOK, pricing)
+			return
+		}
 		modelID := r.URL.Query().Get("id")
 		if modelID != "" {
 			// 获取单个模型定价
@@ -61,6 +75,10 @@ func (p *Server) handlePricing(w http.ResponseWriter, r *http.Request) {
 			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "model_id required"})
 			return
 		}
+		if err := store.ValidateModelPattern(req.ModelID); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid model_id pattern: " + err.Error()})
+			return
+		}
 		if req.ModelName == "" {
 			req.ModelName = req.ModelID
 		}
@@ -68,6 +86,7 @@ func (p *Server) handlePricing(w http.ResponseWriter, r *http.Request) {
 			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 			return
 		}
+		p.publishPricingChanged()
 		writeJSON(w, http.StatusOK, map[string]string{"model_id": req.ModelID})
 
 	case http.MethodDelete:
@@ -88,6 +107,7 @@ func (p *Server) handlePricing(w http.ResponseWriter, r *http.Request) {
 			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 			return
 		}
+		p.publishPricingChanged()
 		writeJSON(w, http.StatusOK, map[string]string{"deleted": modelID})
 
 	default:
@@ -95,6 +115,159 @@ func (p *Server) handlePricing(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handlePricingRules 处理分段/生效期定价规则 API
+// GET    /api/pricing/rules?model_id=xx - 列出规则（model_id 可选）
+// POST   /api/pricing/rules             - 创建或按 id 更新规则
+// DELETE /api/pricing/rules?id=xx       - 删除规则
+func (p *Server) handlePricingRules(w http.ResponseWriter, r *http.Request) {
+	if p.store == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		rules, err := p.store.ListPricingRules(r.Context(), r.URL.Query().Get("model_id"))
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"rules": rules})
+
+	case http.MethodPost:
+		// 仅管理员可以管理定价
+		if !isAdmin(r.Context()) {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "admin required"})
+			return
+		}
+		var req store.PricingRuleRecord
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+			return
+		}
+		if msg := validatePricingRule(req); msg != "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": msg})
+			return
+		}
+		id, err := p.store.UpsertPricingRule(r.Context(), req)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		p.publishPricingChanged()
+		writeJSON(w, http.StatusOK, map[string]string{"id": id})
+
+	case http.MethodDelete:
+		if !isAdmin(r.Context()) {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "admin required"})
+			return
+		}
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "id required"})
+			return
+		}
+		if err := p.store.DeletePricingRule(r.Context(), id); err == store.ErrNotFound {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "rule not found"})
+			return
+		} else if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		p.publishPricingChanged()
+		writeJSON(w, http.StatusOK, map[string]string{"deleted": id})
+
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+}
+
+// validatePricingRule 校验规则参数，返回错误信息，合法时返回空字符串。
+func validatePricingRule(rule store.PricingRuleRecord) string {
+	switch {
+	case rule.ModelID == "":
+		return "model_id required"
+	case rule.InputThreshold < 0:
+		return "input_threshold must be >= 0"
+	case rule.InputPriceMTok < 0 || rule.OutputPriceMTok < 0 || rule.CacheWritePriceMTok < 0 || rule.CacheReadPriceMTok < 0:
+		return "prices must be >= 0"
+	case rule.Multiplier < 0:
+		return "multiplier must be >= 0"
+	case rule.EffectiveFrom != nil && rule.EffectiveTo != nil && !rule.EffectiveTo.After(*rule.EffectiveFrom):
+		return "effective_to must be after effective_from"
+	}
+	return ""
+}
+
+// handleUnpricedModels 列出使用日志中未定价的模型（仅管理员）
+// GET /api/pricing/unpriced?from=&to=
+func (p *Server) handleUnpricedModels(w http.ResponseWriter, r *http.Request) {
+	if p.store == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+	if !isAdmin(r.Context()) {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "admin required"})
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	var from, to time.Time
+	if t, err := time.Parse(time.RFC3339, r.URL.Query().Get("from")); err == nil {
+		from = t
+	}
+	if t, err := time.Parse(time.RFC3339, r.URL.Query().Get("to")); err == nil {
+		to = t
+	}
+	models, err := p.store.ListUnpricedModels(r.Context(), from, to)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	for i := range models {
+		if pricing, err := p.store.ResolveModelPricing(r.Context(), models[i].ModelID); err == nil {
+			models[i].MatchedPricing = pricing.ModelID
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"models": models})
+}
+
+// handleRepriceUsage 按当前定价重新计算历史使用日志费用（仅管理员）
+// POST /api/pricing/reprice
+// 参数 (JSON body): model_id, from, to, include_priced
+func (p *Server) handleRepriceUsage(w http.ResponseWriter, r *http.Request) {
+	if p.store == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+	if !isAdmin(r.Context()) {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "admin required"})
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	var req store.RepriceParams
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+		return
+	}
+	if req.IncludePriced && req.ModelID == "" && req.From.IsZero() {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "model_id or from required when include_priced is set"})
+		return
+	}
+	result, err := p.store.RepriceUsageLogs(r.Context(), req)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	p.logger.Component("pricing").Info("usage logs repriced", "model", req.ModelID, "updated", result.Updated, "still_unpriced", result.StillUnpriced)
+	writeJSON(w, http.StatusOK, result)
+}
+
 // handleUsageLogs 处理使用日志查询 API
 // GET /api/usage/logs - 查询使用日志
 // 参数: account_id, node_id, model_id, from, to, limit, offset
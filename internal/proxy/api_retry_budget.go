@@ -0,0 +1,33 @@
+package proxy
+
+import "net/http"
+
+// GET /admin/api/retry-budget?account_id=xxx
+// 返回全局与各账号重试预算的令牌余量、存入/重试/拒绝计数（仅管理员）。
+func (p *Server) handleRetryBudget(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !isAdmin(r.Context()) {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "forbidden"})
+		return
+	}
+	if accountID := r.URL.Query().Get("account_id"); accountID != "" && p.retryBudget != nil {
+		p.mu.RLock()
+		_, ok := p.accountByID[accountID]
+		p.mu.RUnlock()
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "account not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"enabled":    p.retryBudget.cfg.Enabled,
+			"account_id": accountID,
+			"budget":     p.retryBudget.peek(accountID).Snapshot(),
+			"global":     p.retryBudget.global.Snapshot(),
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, p.retryBudget.snapshot())
+}
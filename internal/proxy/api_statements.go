@@ -0,0 +1,402 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"qcc_plus/internal/export"
+	"qcc_plus/internal/logging"
+	"qcc_plus/internal/store"
+)
+
+var statementCSVColumns = []export.Column{
+	{Name: "section", Type: export.String},
+	{Name: "key", Type: export.String},
+	{Name: "label", Type: export.String},
+	{Name: "requests", Type: export.Int64},
+	{Name: "input_tokens", Type: export.Int64},
+	{Name: "output_tokens", Type: export.Int64},
+	{Name: "cache_creation_tokens", Type: export.Int64},
+	{Name: "cache_read_tokens", Type: export.Int64},
+	{Name: "amount_usd", Type: export.Float64},
+}
+
+// statementAccountID 返回请求可访问的账号：非管理员固定为自身账号，管理员取 account_id 参数（可为空）。
+func statementAccountID(r *http.Request) (string, bool) {
+	if isAdmin(r.Context()) {
+		return r.URL.Query().Get("account_id"), true
+	}
+	acc := accountFromCtx(r)
+	if acc == nil {
+		return "", false
+	}
+	return acc.ID, true
+}
+
+// handleStatements 月度账单
+// GET  /api/statements?account_id=xx&period=2026-09 - 列出账单快照（不含明细，非管理员仅本账号）
+// POST /api/statements                             - 生成/重新生成账单（仅管理员），写入新版本
+func (p *Server) handleStatements(w http.ResponseWriter, r *http.Request) {
+	if p.store == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		accountID, ok := statementAccountID(r)
+		if !ok {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "account missing"})
+			return
+		}
+		list, err := p.store.ListStatements(r.Context(), accountID, r.URL.Query().Get("period"))
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		if list == nil {
+			list = []store.StatementRecord{}
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"statements": list})
+	case http.MethodPost:
+		if !isAdmin(r.Context()) {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "forbidden"})
+			return
+		}
+		var req struct {
+			AccountID string `json:"account_id"`
+			Period    string `json:"period"` // 为空时为上一个自然月
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+			return
+		}
+		loc := p.statementLocation()
+		if req.Period == "" {
+			req.Period, _ = previousStatementPeriod(time.Now(), loc)
+		}
+		if _, _, err := store.StatementPeriodRange(req.Period, loc); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		if req.AccountID == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "account_id required"})
+			return
+		}
+		if _, err := p.store.GetAccountByID(r.Context(), req.AccountID); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				writeJSON(w, http.StatusNotFound, map[string]string{"error": "account not found"})
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		rec, err := p.store.GenerateStatement(r.Context(), store.StatementParams{
+			AccountID:   req.AccountID,
+			Period:      req.Period,
+			Location:    loc,
+			GeneratedBy: "manual",
+		})
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		p.logger.Component("statement").Info("statement generated", logging.KeyAccountID, rec.AccountID, "period", rec.Period, "version", rec.Version)
+		writeJSON(w, http.StatusOK, rec)
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+}
+
+// handleStatementByID 账单详情
+// GET /api/statements/{id}?format=json|csv|html - JSON 明细、CSV 下载或可打印的 HTML
+func (p *Server) handleStatementByID(w http.ResponseWriter, r *http.Request) {
+	if p.store == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/statements/"), "/")
+	rec, err := p.store.GetStatement(r.Context(), id)
+	if err == nil && !isAdmin(r.Context()) {
+		// 非管理员访问其他账号的账单按不存在处理
+		if acc := accountFromCtx(r); acc == nil || acc.ID != rec.AccountID {
+			err = store.ErrNotFound
+		}
+	}
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "statement not found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	filename := fmt.Sprintf("statement-%s-%s-v%d", rec.AccountID, rec.Period, rec.Version)
+	switch strings.ToLower(r.URL.Query().Get("format")) {
+	case "", "json":
+		writeJSON(w, http.StatusOK, rec)
+	case "csv":
+		w.Header().Set("Content-Type", export.FormatCSV.ContentType())
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".csv"))
+		w.Header().Set("Cache-Control", "no-store")
+		if err := writeStatementCSV(w, rec); err != nil {
+			p.logger.Warn("statement csv failed", logging.KeyAccountID, rec.AccountID, "statement", rec.ID, logging.KeyError, err)
+		}
+	case "html":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		if err := statementHTML.Execute(w, newStatementView(rec, p.statementLocation())); err != nil {
+			p.logger.Warn("statement html failed", logging.KeyAccountID, rec.AccountID, "statement", rec.ID, logging.KeyError, err)
+		}
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported format"})
+	}
+}
+
+// handleBillingAdjustments 账单调整与抵扣（写操作仅管理员），生成或重新生成账单时计入
+// GET    /api/statements/adjustments?account_id=xx&period=2026-09
+// POST   /api/statements/adjustments - {account_id, period, kind: adjustment|credit, amount_usd, description}
+// DELETE /api/statements/adjustments?id=xx
+func (p *Server) handleBillingAdjustments(w http.ResponseWriter, r *http.Request) {
+	if p.store == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+	if r.Method != http.MethodGet && !isAdmin(r.Context()) {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "forbidden"})
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		accountID, ok := statementAccountID(r)
+		if !ok {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "account missing"})
+			return
+		}
+		list, err := p.store.ListBillingAdjustments(r.Context(), accountID, r.URL.Query().Get("period"))
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		if list == nil {
+			list = []store.BillingAdjustmentRecord{}
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"adjustments": list})
+	case http.MethodPost:
+		var req store.BillingAdjustmentRecord
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+			return
+		}
+		if msg := validateBillingAdjustment(req); msg != "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": msg})
+			return
+		}
+		req.ID = ""
+		id, err := p.store.CreateBillingAdjustment(r.Context(), req)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"id": id})
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "id required"})
+			return
+		}
+		if err := p.store.DeleteBillingAdjustment(r.Context(), id); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				writeJSON(w, http.StatusNotFound, map[string]string{"error": "adjustment not found"})
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+}
+
+func validateBillingAdjustment(a store.BillingAdjustmentRecord) string {
+	if a.AccountID == "" {
+		return "account_id required"
+	}
+	if _, _, err := store.StatementPeriodRange(a.Period, nil); err != nil {
+		return err.Error()
+	}
+	switch a.Kind {
+	case store.AdjustmentKindAdjustment:
+		if a.AmountUSD == 0 {
+			return "amount_usd must not be 0"
+		}
+	case store.AdjustmentKindCredit:
+		if a.AmountUSD <= 0 {
+			return "credit amount_usd must be > 0"
+		}
+	default:
+		return "kind must be adjustment or credit"
+	}
+	return ""
+}
+
+// statementLocation 返回账期时区，未加载配置时为 UTC。
+func (p *Server) statementLocation() *time.Location {
+	if p.statementCfg.Location != nil {
+		return p.statementCfg.Location
+	}
+	return time.UTC
+}
+
+// writeStatementCSV 以统一列写出账单：各维度明细、调整/抵扣（抵扣为负数）与合计行。
+func writeStatementCSV(w io.Writer, rec *store.StatementRecord) error {
+	ew, err := export.NewWriter(export.FormatCSV, w, statementCSVColumns)
+	if err != nil {
+		return err
+	}
+	line := func(section string, l store.StatementLine) error {
+		return ew.Write([]any{section, l.Key, l.Label, l.Requests, l.InputTokens, l.OutputTokens, l.CacheCreationTokens, l.CacheReadTokens, l.CostUSD})
+	}
+	amount := func(section, key, label string, usd float64) error {
+		return ew.Write([]any{section, key, label, int64(0), int64(0), int64(0), int64(0), int64(0), usd})
+	}
+	for _, group := range []struct {
+		section string
+		lines   []store.StatementLine
+	}{{"model", rec.ByModel}, {"node", rec.ByNode}, {"api_key", rec.ByAPIKey}} {
+		for _, l := range group.lines {
+			if err := line(group.section, l); err != nil {
+				return err
+			}
+		}
+	}
+	for _, a := range rec.Adjustments {
+		usd := a.AmountUSD
+		if a.Kind == store.AdjustmentKindCredit {
+			usd = -usd
+		}
+		if err := amount(a.Kind, a.ID, a.Description, usd); err != nil {
+			return err
+		}
+	}
+	if err := line("total", store.StatementLine{Key: "usage", Requests: rec.Totals.Requests, InputTokens: rec.Totals.InputTokens,
+		OutputTokens: rec.Totals.OutputTokens, CacheCreationTokens: rec.Totals.CacheCreationTokens, CacheReadTokens: rec.Totals.CacheReadTokens,
+		CostUSD: rec.Totals.CostUSD}); err != nil {
+		return err
+	}
+	if err := amount("total", "adjustments", "", rec.AdjustmentsUSD); err != nil {
+		return err
+	}
+	if err := amount("total", "credits", "", -rec.CreditsUSD); err != nil {
+		return err
+	}
+	if err := amount("total", "amount_due", "", rec.AmountDueUSD); err != nil {
+		return err
+	}
+	return ew.Close()
+}
+
+// statementView HTML 模板数据。
+type statementView struct {
+	*store.StatementRecord
+	Start, End, Generated string
+	Sections              []statementSection
+}
+
+type statementSection struct {
+	Title string
+	Lines []store.StatementLine
+}
+
+func newStatementView(rec *store.StatementRecord, fallback *time.Location) statementView {
+	loc, err := time.LoadLocation(rec.Timezone)
+	if err != nil {
+		loc = fallback
+	}
+	const layout = "2006-01-02 15:04"
+	return statementView{
+		StatementRecord: rec,
+		Start:           rec.PeriodStart.In(loc).Format(layout),
+		End:             rec.PeriodEnd.In(loc).Format(layout),
+		Generated:       rec.CreatedAt.In(loc).Format(layout),
+		Sections: []statementSection{
+			{Title: "按模型", Lines: rec.ByModel},
+			{Title: "按节点", Lines: rec.ByNode},
+			{Title: "按 API Key", Lines: rec.ByAPIKey},
+		},
+	}
+}
+
+var statementHTML = template.Must(template.New("statement").Funcs(template.FuncMap{
+	"usd": func(v float64) string { return fmt.Sprintf("$%.4f", v) },
+	"kind": func(k string) string {
+		if k == store.AdjustmentKindCredit {
+			return "抵扣"
+		}
+		return "调整"
+	},
+	"credit": func(k string) bool { return k == store.AdjustmentKindCredit },
+}).Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<title>账单 {{.AccountName}} {{.Period}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", "PingFang SC", "Microsoft YaHei", sans-serif; color: #222; margin: 32px; font-size: 13px; }
+h1 { font-size: 20px; margin: 0 0 4px; }
+h2 { font-size: 15px; margin: 24px 0 8px; border-bottom: 1px solid #ccc; padding-bottom: 4px; }
+.meta { color: #555; line-height: 1.7; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border: 1px solid #ddd; padding: 4px 8px; text-align: right; }
+th:first-child, td:first-child, td.text { text-align: left; }
+th { background: #f5f5f5; }
+.summary td { font-weight: 600; }
+.due td { font-size: 15px; }
+@media print { body { margin: 0; } button { display: none; } h2 { page-break-after: avoid; } tr { page-break-inside: avoid; } }
+</style>
+</head>
+<body>
+<button onclick="window.print()" style="float:right">打印</button>
+<h1>月度账单 · {{.Period}}</h1>
+<div class="meta">
+账号：{{.AccountName}}（{{.AccountID}}）<br>
+账期：{{.Start}} 至 {{.End}}（{{.Timezone}}，不含结束时间）<br>
+版本：v{{.Version}} · 生成于 {{.Generated}} · 账单编号 {{.ID}}
+</div>
+
+<h2>汇总</h2>
+<table>
+<tr><th>项目</th><th>请求数</th><th>输入 Tokens</th><th>输出 Tokens</th><th>缓存写入 Tokens</th><th>缓存读取 Tokens</th><th>金额 (USD)</th></tr>
+<tr><td>用量费用</td><td>{{.Totals.Requests}}</td><td>{{.Totals.InputTokens}}</td><td>{{.Totals.OutputTokens}}</td><td>{{.Totals.CacheCreationTokens}}</td><td>{{.Totals.CacheReadTokens}}</td><td>{{usd .Totals.CostUSD}}</td></tr>
+<tr><td>调整</td><td colspan="5"></td><td>{{usd .AdjustmentsUSD}}</td></tr>
+<tr><td>抵扣</td><td colspan="5"></td><td>-{{usd .CreditsUSD}}</td></tr>
+<tr class="summary due"><td>应付金额</td><td colspan="5"></td><td>{{usd .AmountDueUSD}}</td></tr>
+</table>
+{{range .Sections}}
+<h2>{{.Title}}</h2>
+{{if .Lines}}<table>
+<tr><th>项目</th><th>请求数</th><th>输入 Tokens</th><th>输出 Tokens</th><th>缓存写入 Tokens</th><th>缓存读取 Tokens</th><th>费用 (USD)</th></tr>
+{{range .Lines}}<tr><td>{{if .Key}}{{.Key}}{{else}}-{{end}}{{if .Label}}（{{.Label}}）{{end}}</td><td>{{.Requests}}</td><td>{{.InputTokens}}</td><td>{{.OutputTokens}}</td><td>{{.CacheCreationTokens}}</td><td>{{.CacheReadTokens}}</td><td>{{usd .CostUSD}}</td></tr>
+{{end}}</table>{{else}}<p class="meta">本账期无用量</p>{{end}}
+{{end}}
+{{if .Adjustments}}
+<h2>调整与抵扣</h2>
+<table>
+<tr><th>类型</th><th>说明</th><th>金额 (USD)</th></tr>
+{{range .Adjustments}}<tr><td>{{kind .Kind}}</td><td class="text">{{.Description}}</td><td>{{if credit .Kind}}-{{end}}{{usd .AmountUSD}}</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,302 @@
+package proxy
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"qcc_plus/internal/logging"
+	"qcc_plus/internal/store"
+	"qcc_plus/internal/timeutil"
+)
+
+// GET /api/uptime
+// 查询参数：
+// - window: day/week/month（默认 week）
+// - account_id: 管理员可指定账号
+// - node_id: 仅统计指定节点（可选）
+// - exclude_maintenance: true 时维护窗口不计入可用率
+// - format: json/csv（默认 json）；csv 时 detail=incidents 导出故障明细
+// - share_token: 分享 token（可选，需分享链接开启 include_uptime）
+func (p *Server) handleUptimeReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if p.store == nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": "store not enabled"})
+		return
+	}
+
+	q := r.URL.Query()
+	var target *Account
+	if caller := accountFromCtx(r); caller != nil {
+		target = caller
+		if aid := q.Get("account_id"); aid != "" && aid != caller.ID {
+			if !isAdmin(r.Context()) {
+				respondJSON(w, http.StatusForbidden, map[string]string{"error": "forbidden"})
+				return
+			}
+			target = p.getAccountByID(aid)
+		}
+	} else {
+		shareToken := q.Get("share_token")
+		if shareToken == "" {
+			respondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		share, err := p.store.GetMonitorShareByToken(r.Context(), shareToken)
+		if err != nil || share == nil {
+			respondJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid share token"})
+			return
+		}
+		if !share.IncludeUptime {
+			respondJSON(w, http.StatusForbidden, map[string]string{"error": "uptime not shared"})
+			return
+		}
+		target = p.getAccountByID(share.AccountID)
+	}
+	if target == nil {
+		respondJSON(w, http.StatusNotFound, map[string]string{"error": "account not found"})
+		return
+	}
+
+	window := strings.ToLower(strings.TrimSpace(q.Get("window")))
+	if window == "" {
+		window = UptimeWindowWeek
+	}
+	excludeMaintenance := parseBoolParam(q.Get("exclude_maintenance"))
+
+	report, err := p.buildUptimeReport(r.Context(), target, window, strings.TrimSpace(q.Get("node_id")), excludeMaintenance)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, errUptimeNodeNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, errInvalidUptimeWindow):
+			status = http.StatusBadRequest
+		}
+		respondJSON(w, status, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if strings.EqualFold(q.Get("format"), "csv") {
+		writeUptimeCSV(w, report, strings.EqualFold(q.Get("detail"), "incidents"))
+		return
+	}
+	respondJSON(w, http.StatusOK, report)
+}
+
+func writeUptimeCSV(w http.ResponseWriter, report *UptimeReport, incidents bool) {
+	kind := "summary"
+	if incidents {
+		kind = "incidents"
+	}
+	filename := fmt.Sprintf("uptime-%s-%s-%s.csv", report.AccountID, report.Window, kind)
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+
+	cw := csv.NewWriter(w)
+	defer cw.Flush()
+
+	if incidents {
+		_ = cw.Write([]string{"node_id", "node_name", "started_at", "ended_at", "duration_sec", "in_maintenance", "reason"})
+		for _, node := range report.Nodes {
+			for _, inc := range node.Incidents {
+				ended := ""
+				if inc.EndedAt != nil {
+					ended = *inc.EndedAt
+				}
+				_ = cw.Write([]string{inc.NodeID, inc.NodeName, inc.StartedAt, ended,
+					strconv.FormatInt(inc.DurationSec, 10), strconv.FormatBool(inc.InMaintenance), inc.Reason})
+			}
+		}
+		return
+	}
+
+	_ = cw.Write([]string{"scope", "node_id", "node_name", "window", "from", "to", "availability_pct",
+		"period_seconds", "down_seconds", "maintenance_seconds", "incidents", "mttr_seconds"})
+	rows := append([]UptimeSummary{report.Account}, report.Nodes...)
+	for _, s := range rows {
+		_ = cw.Write([]string{s.Scope, s.NodeID, s.NodeName, report.Window, report.From, report.To,
+			strconv.FormatFloat(s.Availability, 'f', 3, 64),
+			strconv.FormatInt(s.PeriodSeconds, 10), strconv.FormatInt(s.DownSeconds, 10),
+			strconv.FormatInt(s.MaintenanceSeconds, 10), strconv.Itoa(s.IncidentCount),
+			strconv.FormatInt(s.MTTRSeconds, 10)})
+	}
+}
+
+type maintenanceWindowRequest struct {
+	AccountID string `json:"account_id"`
+	NodeID    string `json:"node_id"`
+	StartsAt  string `json:"starts_at"` // RFC3339
+	EndsAt    string `json:"ends_at"`   // RFC3339
+	Reason    string `json:"reason"`
+}
+
+func maintenanceWindowView(rec store.MaintenanceWindowRecord) map[string]interface{} {
+	return map[string]interface{}{
+		"id":         rec.ID,
+		"account_id": rec.AccountID,
+		"node_id":    rec.NodeID,
+		"starts_at":  timeutil.FormatBeijingTime(rec.StartsAt),
+		"ends_at":    timeutil.FormatBeijingTime(rec.EndsAt),
+		"reason":     rec.Reason,
+		"created_by": rec.CreatedBy,
+		"created_at": timeutil.FormatBeijingTime(rec.CreatedAt),
+	}
+}
+
+// /api/uptime/maintenance
+// GET 列出维护窗口（from/to 可选），POST 创建维护窗口。
+func (p *Server) handleMaintenanceWindows(w http.ResponseWriter, r *http.Request) {
+	if p.store == nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": "store not enabled"})
+		return
+	}
+	caller := accountFromCtx(r)
+	if caller == nil {
+		respondJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		accountID := caller.ID
+		if aid := r.URL.Query().Get("account_id"); aid != "" {
+			if !canManageAccount(r.Context(), aid) {
+				respondJSON(w, http.StatusForbidden, map[string]string{"error": "forbidden"})
+				return
+			}
+			accountID = aid
+		}
+		from, err := parseTime(r.URL.Query().Get("from"))
+		if err != nil {
+			respondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid from time"})
+			return
+		}
+		to, err := parseTime(r.URL.Query().Get("to"))
+		if err != nil {
+			respondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid to time"})
+			return
+		}
+		recs, err := p.store.ListMaintenanceWindows(r.Context(), accountID, r.URL.Query().Get("node_id"), from, to)
+		if err != nil {
+			respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		items := make([]map[string]interface{}, 0, len(recs))
+		for _, rec := range recs {
+			items = append(items, maintenanceWindowView(rec))
+		}
+		respondJSON(w, http.StatusOK, map[string]interface{}{"windows": items})
+	case http.MethodPost:
+		var req maintenanceWindowRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+			return
+		}
+		if req.AccountID == "" {
+			req.AccountID = caller.ID
+		}
+		if !canManageAccount(r.Context(), req.AccountID) {
+			respondJSON(w, http.StatusForbidden, map[string]string{"error": "forbidden"})
+			return
+		}
+		if req.NodeID != "" {
+			node := p.getNode(req.NodeID)
+			if node == nil || node.AccountID != req.AccountID {
+				respondJSON(w, http.StatusNotFound, map[string]string{"error": "node not found"})
+				return
+			}
+		}
+		startsAt, err := parseTime(req.StartsAt)
+		if err != nil || startsAt.IsZero() {
+			respondJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid starts_at"})
+			return
+		}
+		endsAt, err := parseTime(req.EndsAt)
+		if err != nil || !endsAt.After(startsAt) {
+			respondJSON(w, http.StatusBadRequest, map[string]string{"error": "ends_at must be after starts_at"})
+			return
+		}
+		rec := store.MaintenanceWindowRecord{
+			AccountID: req.AccountID,
+			NodeID:    req.NodeID,
+			StartsAt:  startsAt,
+			EndsAt:    endsAt,
+			Reason:    strings.TrimSpace(req.Reason),
+			CreatedBy: caller.Name,
+		}
+		if err := p.store.CreateMaintenanceWindow(r.Context(), &rec); err != nil {
+			respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		respondJSON(w, http.StatusCreated, maintenanceWindowView(rec))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// DELETE /api/uptime/maintenance/:id
+func (p *Server) handleMaintenanceWindowByID(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if p.store == nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": "store not enabled"})
+		return
+	}
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/uptime/maintenance/"), "/")
+	if id == "" {
+		http.NotFound(w, r)
+		return
+	}
+	rec, err := p.store.GetMaintenanceWindow(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			respondJSON(w, http.StatusNotFound, map[string]string{"error": "maintenance window not found"})
+			return
+		}
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if !canManageAccount(r.Context(), rec.AccountID) {
+		respondJSON(w, http.StatusForbidden, map[string]string{"error": "forbidden"})
+		return
+	}
+	if err := p.store.DeleteMaintenanceWindow(r.Context(), id); err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func parseBoolParam(v string) bool {
+	return strings.EqualFold(v, "true") || v == "1"
+}
+
+// uptimeShareWindow 分享页默认展示的统计窗口。
+const uptimeShareWindow = UptimeWindowMonth
+
+func (p *Server) shareUptimeReport(r *http.Request, acc *Account) *UptimeReport {
+	window := strings.ToLower(r.URL.Query().Get("uptime_window"))
+	if _, _, _, err := uptimeRange(window, time.Now()); err != nil {
+		window = uptimeShareWindow
+	}
+	report, err := p.buildUptimeReport(r.Context(), acc, window, "", parseBoolParam(r.URL.Query().Get("exclude_maintenance")))
+	if err != nil {
+		if p.logger != nil {
+			p.logger.Component("api").Warn("build shared uptime report failed", logging.KeyAccountID, acc.ID, logging.KeyError, err)
+		}
+		return nil
+	}
+	return report
+}
@@ -5,6 +5,8 @@ import (
 	"net/http"
 
 	"github.com/gorilla/websocket"
+
+	"qcc_plus/internal/logging"
 )
 
 var upgrader = websocket.Upgrader{
@@ -31,7 +33,7 @@ func (p *Server) handleMonitorWebSocket(w http.ResponseWriter, r *http.Request)
 
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		p.logger.Printf("websocket upgrade failed: %v", err)
+		p.logger.Component("api").Warn("websocket upgrade failed", logging.KeyError, err)
 		return
 	}
 
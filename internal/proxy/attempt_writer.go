@@ -0,0 +1,87 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// attemptBufferLimit 失败响应的最大缓存字节数，超过后直接写给客户端，本次请求不再切换节点。
+const attemptBufferLimit = 1 << 20
+
+// attemptWriter 单次上游尝试的响应写入器。2xx 响应直接透传给客户端；失败响应先缓存，
+// 由请求循环决定切换节点重试（丢弃）还是作为最终响应写出（commit），
+// 保证切换节点前客户端没有收到任何字节。
+type attemptWriter struct {
+	w         http.ResponseWriter
+	header    http.Header
+	status    int
+	buf       bytes.Buffer
+	buffering bool // 已收到失败状态码，正在缓存
+	committed bool // 已写给客户端，不能再切换节点
+}
+
+func newAttemptWriter(w http.ResponseWriter) *attemptWriter {
+	return &attemptWriter{w: w, header: w.Header().Clone()}
+}
+
+func (a *attemptWriter) Header() http.Header {
+	if a.committed {
+		return a.w.Header()
+	}
+	return a.header
+}
+
+func (a *attemptWriter) WriteHeader(code int) {
+	if a.committed || a.buffering {
+		return
+	}
+	a.status = code
+	if code >= 200 && code < 300 {
+		a.commit()
+		return
+	}
+	a.buffering = true
+}
+
+func (a *attemptWriter) Write(b []byte) (int, error) {
+	if !a.committed && !a.buffering {
+		a.WriteHeader(http.StatusOK)
+	}
+	if a.buffering && a.buf.Len()+len(b) > attemptBufferLimit {
+		a.commit()
+	}
+	if a.committed {
+		return a.w.Write(b)
+	}
+	return a.buf.Write(b)
+}
+
+// Flush 仅在已写给客户端后生效，缓存中的失败响应不会提前发出。
+func (a *attemptWriter) Flush() {
+	if a.committed {
+		_ = http.NewResponseController(a.w).Flush()
+	}
+}
+
+// commit 将状态码、响应头与缓存内容写给客户端；未产生任何响应时不做处理。
+func (a *attemptWriter) commit() {
+	if a.committed || a.status == 0 {
+		return
+	}
+	a.committed = true
+	a.buffering = false
+	dst := a.w.Header()
+	for k := range dst {
+		if _, ok := a.header[k]; !ok {
+			delete(dst, k)
+		}
+	}
+	for k, v := range a.header {
+		dst[k] = v
+	}
+	a.w.WriteHeader(a.status)
+	if a.buf.Len() > 0 {
+		_, _ = a.w.Write(a.buf.Bytes())
+		a.buf.Reset()
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"crypto/tls"
 	"fmt"
-	"log"
 	"net/http"
 	"net/url"
 	"os"
@@ -13,8 +12,10 @@ import (
 	"strings"
 	"time"
 
+	"qcc_plus/internal/logging"
 	"qcc_plus/internal/notify"
 	"qcc_plus/internal/store"
+	"qcc_plus/internal/tracing"
 )
 
 const (
@@ -35,7 +36,7 @@ type Builder struct {
 	upstreamName              string
 	listenAddr                string
 	transport                 http.RoundTripper
-	logger                    *log.Logger
+	logger                    *logging.Logger
 	retries                   int
 	failLimit                 int
 	healthEvery               time.Duration
@@ -51,7 +52,7 @@ type Builder struct {
 
 // NewBuilder 构建带默认监听地址和日志的 Builder。
 func NewBuilder() *Builder {
-	return &Builder{listenAddr: ":8000", logger: log.Default(), upstreamName: "default", retries: 3, failLimit: 3, healthEvery: 30 * time.Second}
+	return &Builder{listenAddr: ":8000", logger: logging.Default(), upstreamName: "default", retries: 3, failLimit: 3, healthEvery: 30 * time.Second}
 }
 
 func chooseNonEmpty(vals ...string) string {
@@ -63,31 +64,43 @@ func chooseNonEmpty(vals ...string) string {
 	return ""
 }
 
-func parseEnvInt(key string, fallback int, logger *log.Logger) int {
+func parseEnvInt(key string, fallback int, logger *logging.Logger) int {
 	if v := os.Getenv(key); v != "" {
 		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
 			return n
 		}
 		if logger != nil {
-			logger.Printf("invalid %s=%s, fallback to %d", key, v, fallback)
+			logger.Warn("invalid environment variable, using default", "env", key, "value", v, "default", fallback)
 		}
 	}
 	return fallback
 }
 
-func parseEnvDuration(key string, fallback time.Duration, logger *log.Logger) time.Duration {
+func parseEnvDuration(key string, fallback time.Duration, logger *logging.Logger) time.Duration {
 	if v := os.Getenv(key); v != "" {
 		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
 			return d
 		}
 		if logger != nil {
-			logger.Printf("invalid %s=%s, fallback to %v", key, v, fallback)
+			logger.Warn("invalid environment variable, using default", "env", key, "value", v, "default", fallback)
 		}
 	}
 	return fallback
 }
 
-func parseEnvBool(key string, fallback bool, logger *log.Logger) bool {
+func parseEnvFloat(key string, fallback float64, logger *logging.Logger) float64 {
+	if v := os.Getenv(key); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f >= 0 {
+			return f
+		}
+		if logger != nil {
+			logger.Warn("invalid environment variable, using default", "env", key, "value", v, "default", fallback)
+		}
+	}
+	return fallback
+}
+
+func parseEnvBool(key string, fallback bool, logger *logging.Logger) bool {
 	if v := os.Getenv(key); v != "" {
 		switch strings.ToLower(v) {
 		case "1", "true", "yes", "on":
@@ -96,7 +109,7 @@ func parseEnvBool(key string, fallback bool, logger *log.Logger) bool {
 			return false
 		default:
 			if logger != nil {
-				logger.Printf("invalid %s=%s, fallback to %v", key, v, fallback)
+				logger.Warn("invalid environment variable, using default", "env", key, "value", v, "default", fallback)
 			}
 		}
 	}
@@ -105,7 +118,7 @@ func parseEnvBool(key string, fallback bool, logger *log.Logger) bool {
 
 // getDefaultSQLitePath returns the default SQLite database path.
 // Priority: PROXY_SQLITE_PATH env > ~/.qccplus/qccplus.db > ./qccplus.db
-func getDefaultSQLitePath(logger *log.Logger) string {
+func getDefaultSQLitePath(logger *logging.Logger) string {
 	// Check environment variable first
 	if p := os.Getenv("PROXY_SQLITE_PATH"); p != "" {
 		return p
@@ -120,7 +133,7 @@ func getDefaultSQLitePath(logger *log.Logger) string {
 
 	// Fallback to current directory
 	if logger != nil {
-		logger.Printf("cannot determine home directory, using current directory for SQLite database")
+		logger.Warn("cannot determine home directory, using current directory for SQLite database")
 	}
 	return defaultSQLiteDBName
 }
@@ -135,7 +148,7 @@ func maskDSN(dsn string) string {
 	return dsn
 }
 
-func buildTransportFromEnv(logger *log.Logger) http.RoundTripper {
+func buildTransportFromEnv(logger *logging.Logger) http.RoundTripper {
 	base, ok := http.DefaultTransport.(*http.Transport)
 	var transport *http.Transport
 	if ok && base != nil {
@@ -217,9 +230,9 @@ func (b *Builder) WithEnv() *Builder {
 		defaultHealthCheckMethod = method
 		logger := b.logger
 		if logger == nil {
-			logger = log.Default()
+			logger = logging.Default()
 		}
-		logger.Printf("using health check mode: %s", method)
+		logger.Info("using health check mode", "method", method)
 	}
 	return b
 }
@@ -293,8 +306,8 @@ func (b *Builder) WithRetry(times int) *Builder {
 	return b
 }
 
-// WithLogger 设置日志器；默认 log.Default()。
-func (b *Builder) WithLogger(l *log.Logger) *Builder {
+// WithLogger 设置日志器；默认 logging.Default()。
+func (b *Builder) WithLogger(l *logging.Logger) *Builder {
 	b.logger = l
 	return b
 }
@@ -310,7 +323,11 @@ func (b *Builder) Build() (*Server, error) {
 	}
 	logger := b.logger
 	if logger == nil {
-		logger = log.Default()
+		logger = logging.Default()
+	}
+	tracingCfg, err := loadTracingConfig(logger)
+	if err != nil {
+		return nil, err
 	}
 	transport := b.transport
 	if transport == nil {
@@ -322,7 +339,7 @@ func (b *Builder) Build() (*Server, error) {
 		if d, err := time.ParseDuration(v); err == nil && d > 0 {
 			aggregateInterval = d
 		} else {
-			logger.Printf("invalid METRICS_AGGREGATE_INTERVAL=%s, fallback to %v", v, defaultAggregateInterval)
+			logger.Warn("invalid environment variable, using default", "env", "METRICS_AGGREGATE_INTERVAL", "value", v, "default", defaultAggregateInterval)
 		}
 	}
 	cleanupInterval := defaultCleanupInterval
@@ -330,7 +347,7 @@ func (b *Builder) Build() (*Server, error) {
 		if d, err := time.ParseDuration(v); err == nil && d > 0 {
 			cleanupInterval = d
 		} else {
-			logger.Printf("invalid METRICS_CLEANUP_INTERVAL=%s, fallback to %v", v, defaultCleanupInterval)
+			logger.Warn("invalid environment variable, using default", "env", "METRICS_CLEANUP_INTERVAL", "value", v, "default", defaultCleanupInterval)
 		}
 	}
 	schedulerEnabled := true
@@ -344,14 +361,14 @@ func (b *Builder) Build() (*Server, error) {
 			if d, err := time.ParseDuration(raw); err == nil && d > 0 {
 				healthAllInterval = d
 			} else {
-				logger.Printf("invalid PROXY_HEALTH_CHECK_ALL_INTERVAL=%s, fallback to %v", raw, defaultHealthAllInterval)
+				logger.Warn("invalid environment variable, using default", "env", "PROXY_HEALTH_CHECK_ALL_INTERVAL", "value", raw, "default", defaultHealthAllInterval)
 				healthAllInterval = defaultHealthAllInterval
 			}
 		} else if raw := os.Getenv("HEALTH_ALL_INTERVAL_MIN"); raw != "" {
 			if n, err := strconv.Atoi(raw); err == nil && n > 0 {
 				healthAllInterval = time.Duration(n) * time.Minute
 			} else {
-				logger.Printf("invalid HEALTH_ALL_INTERVAL_MIN=%s, fallback to %v", raw, defaultHealthAllInterval)
+				logger.Warn("invalid environment variable, using default", "env", "HEALTH_ALL_INTERVAL_MIN", "value", raw, "default", defaultHealthAllInterval)
 				healthAllInterval = defaultHealthAllInterval
 			}
 		} else {
@@ -366,7 +383,7 @@ func (b *Builder) Build() (*Server, error) {
 			if n, err := strconv.Atoi(raw); err == nil && n > 0 {
 				healthCheckConcurrency = n
 			} else {
-				logger.Printf("invalid HEALTH_CHECK_CONCURRENCY=%s, fallback to %d", raw, defaultHealthCheckConcurrency)
+				logger.Warn("invalid environment variable, using default", "env", "HEALTH_CHECK_CONCURRENCY", "value", raw, "default", defaultHealthCheckConcurrency)
 			}
 		}
 	}
@@ -379,13 +396,13 @@ func (b *Builder) Build() (*Server, error) {
 			if n, err := strconv.Atoi(raw); err == nil && n > 0 {
 				healthCheckConcurrencyCLI = n
 			} else {
-				logger.Printf("invalid HEALTH_CHECK_CONCURRENCY_CLI=%s, fallback to %d", raw, defaultCLIHealthCheckConcurrency)
+				logger.Warn("invalid environment variable, using default", "env", "HEALTH_CHECK_CONCURRENCY_CLI", "value", raw, "default", defaultCLIHealthCheckConcurrency)
 			}
 		}
 	}
 	healthCheckConcurrencyCLI = normalizeCLIHealthCheckWorkers(healthCheckConcurrencyCLI, healthCheckConcurrency, logger)
 	healthRT := transport
-	transport = &retryTransport{base: transport, attempts: b.retries, logger: logger}
+	transport = &retryTransport{base: transport, attempts: b.retries, logger: logger.Component("proxy")}
 
 	var st *store.Store
 	if b.storeDSN != "" {
@@ -394,7 +411,7 @@ func (b *Builder) Build() (*Server, error) {
 		if err != nil {
 			return nil, err
 		}
-		logger.Printf("using MySQL storage: %s", maskDSN(b.storeDSN))
+		logger.Info("using MySQL storage", "dsn", maskDSN(b.storeDSN))
 	} else {
 		// Default to SQLite
 		sqlitePath := getDefaultSQLitePath(logger)
@@ -402,7 +419,7 @@ func (b *Builder) Build() (*Server, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to open SQLite database: %w", err)
 		}
-		logger.Printf("using SQLite storage: %s", sqlitePath)
+		logger.Info("using SQLite storage", "path", sqlitePath)
 	}
 
 	var metricsScheduler *MetricsScheduler
@@ -441,6 +458,9 @@ func (b *Builder) Build() (*Server, error) {
 		nodeIndex:        make(map[string]*Node),
 		nodeAccount:      make(map[string]*Account),
 		circuitBreakers:  make(map[string]*CircuitBreaker),
+		cbOverrides:      make(map[string]CircuitBreakerOverride),
+		nodeCosts:        make(map[string]*nodeCostState),
+		nodeSelection:    loadNodeSelectionStrategy(logger),
 		listenAddr:       b.listenAddr,
 		transport:        transport,
 		healthRT:         healthRT,
@@ -453,6 +473,14 @@ func (b *Builder) Build() (*Server, error) {
 		metricsScheduler: metricsScheduler,
 		wsHub:            hub,
 		retryConfig:      loadRetryConfig(),
+		retryBudget:      newRetryBudgets(loadRetryBudgetConfig(logger)),
+		traces:           newTraceBuffer(parseEnvInt("REQUEST_TRACE_BUFFER", defaultTraceBufferSize, logger)),
+		traceHeader:      parseEnvBool("PROXY_TRACE_HEADER", false, logger),
+		prom:             newPromMetrics(),
+		promConfig:       loadPrometheusConfig(logger),
+		grafanaToken:     loadGrafanaToken(),
+		sinks:            loadMetricSinks(logger),
+		tracer:           tracing.New(tracingCfg, logger.Component("tracing").Printf),
 		cbConfig:         loadCircuitBreakerConfig(),
 		warmupConfig:     loadWarmupConfig(),
 		warmupSem:        make(chan struct{}, warmupConcurrency),
@@ -462,6 +490,37 @@ func (b *Builder) Build() (*Server, error) {
 		srv.settingsCache = NewSettingsCache(st)
 	}
 
+	leaderCfg := loadLeaderConfig(logger)
+	srv.instanceID = leaderCfg.InstanceID
+	if leaderCfg.Enabled && st != nil {
+		srv.leader = NewLeaderElector(st, leaderCfg, logger)
+		if metricsScheduler != nil {
+			metricsScheduler.isLeader = srv.leader.IsLeader
+		}
+	}
+	requestLogCfg := loadRequestLogConfig(logger)
+	srv.requestLogRetentionDays.Store(int64(requestLogCfg.RetentionDays))
+	srv.requestLogs = newRequestLogWriter(st, requestLogCfg, logger)
+	srv.trustedProxies = requestLogCfg.TrustedProxies
+	srv.captureMaxBytes = parseEnvInt("CAPTURE_MAX_BODY_BYTES", defaultCaptureMaxBytes, logger)
+	if metricsScheduler != nil {
+		metricsScheduler.tracer = srv.tracer
+		metricsScheduler.requestLogRetention = srv.requestLogRetention
+		metricsScheduler.captureRetention = parseEnvDuration("CAPTURE_RETENTION", defaultCaptureRetention, logger)
+	}
+
+	switch clusterCfg := loadClusterConfig(logger); clusterCfg.Bus {
+	case ClusterBusDB:
+		if st != nil {
+			srv.cluster = newClusterSync(NewDBClusterBus(st, clusterCfg.PollInterval, logger), leaderCfg.InstanceID)
+		}
+	case ClusterBusLocal:
+		srv.cluster = newClusterSync(NewLocalClusterBus(), leaderCfg.InstanceID)
+	}
+	if srv.cluster != nil {
+		srv.cluster.bus.Subscribe(srv.applyClusterEvent)
+	}
+
 	if healthAllInterval > 0 {
 		srv.healthScheduler = NewHealthScheduler(srv, healthAllInterval, healthCheckConcurrency, healthCheckConcurrencyCLI, logger)
 	}
@@ -470,8 +529,31 @@ func (b *Builder) Build() (*Server, error) {
 		srv.notifyMgr = notify.NewManager(notify.NewStoreAdapter(st), notify.WithLogger(logger))
 	}
 
+	srv.errorClassifier = NewErrorClassifier()
 	if rt, ok := transport.(*retryTransport); ok {
 		rt.notifyMgr = srv.notifyMgr
+		rt.classifier = srv.errorClassifier
+		rt.budget = srv.retryBudget
+	}
+	srv.retryBudget.onExhausted = srv.observeRetryBudgetExhausted
+
+	if anomalyCfg := loadAnomalyConfig(logger); st != nil && anomalyCfg.Enabled {
+		srv.anomalyDetector = NewAnomalyDetector(st, anomalyCfg, logger)
+		srv.anomalyDetector.isLeader = srv.isLeader
+		srv.anomalyDetector.publish = srv.notifyMgr.Publish
+		srv.anomalyDetector.nodeName = func(id string) string {
+			if n := srv.getNode(id); n != nil {
+				return n.Name
+			}
+			return ""
+		}
+	}
+
+	srv.statementCfg = loadStatementConfig(logger)
+	if st != nil && srv.statementCfg.Enabled {
+		srv.statements = NewStatementScheduler(st, srv.statementCfg, logger)
+		srv.statements.isLeader = srv.isLeader
+		srv.statements.publish = srv.notifyMgr.Publish
 	}
 
 	defaultCfg := store.Config{Retries: b.retries, FailLimit: b.failLimit, HealthEvery: b.healthEvery}
@@ -480,37 +562,7 @@ func (b *Builder) Build() (*Server, error) {
 	srv.healthEvery = defaultCfg.HealthEvery
 
 	if srv.settingsCache != nil {
-		srv.settingsCache.OnChange(func(key string, value any) {
-			switch key {
-			case "health.check_interval_sec":
-				switch n := value.(type) {
-				case float64:
-					srv.updateHealthInterval(time.Duration(n) * time.Second)
-				case int:
-					srv.updateHealthInterval(time.Duration(n) * time.Second)
-				case int64:
-					srv.updateHealthInterval(time.Duration(n) * time.Second)
-				}
-			case "proxy.retry_max":
-				switch n := value.(type) {
-				case float64:
-					srv.updateRetryMax(int(n))
-				case int:
-					srv.updateRetryMax(n)
-				case int64:
-					srv.updateRetryMax(int(n))
-				}
-			case "health.fail_threshold":
-				switch n := value.(type) {
-				case float64:
-					srv.updateFailLimit(int(n))
-				case int:
-					srv.updateFailLimit(n)
-				case int64:
-					srv.updateFailLimit(int(n))
-				}
-			}
-		})
+		srv.settingsCache.OnChange(srv.applySettingChange)
 	}
 
 	// Initialize accounts from storage (st is always non-nil now: SQLite or MySQL)
@@ -574,6 +626,11 @@ func (b *Builder) Build() (*Server, error) {
 	if err := srv.loadAccountsFromStore(parsed, defaultCfg, b.upstreamKey); err != nil {
 		return nil, err
 	}
+	srv.restoreCircuitBreakers()
+	srv.restoreNodeCosts()
+	srv.cbEvents = make(chan nodeBreakerTransition, cbEventQueueSize)
+	srv.cbWake = make(chan struct{}, 1)
+	go srv.runBreakerEventWriter()
 
 	if srv.defaultAccount != nil && srv.defaultAccount.ActiveID == "" {
 		for id := range srv.defaultAccount.Nodes {
@@ -595,7 +652,7 @@ func (b *Builder) Build() (*Server, error) {
 			cancel()
 			if err == nil && cfg != nil && cfg.Enabled {
 				if err := srv.StartTunnel(); err != nil {
-					srv.logger.Printf("auto start tunnel failed: %v", err)
+					srv.logger.Warn("auto start tunnel failed", logging.KeyError, err)
 				}
 			}
 		}()
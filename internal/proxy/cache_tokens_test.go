@@ -0,0 +1,79 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"qcc_plus/internal/store"
+)
+
+const cacheSSE = "event: message_start\n" +
+	`data: {"type":"message_start","message":{"usage":{"input_tokens":20,"cache_creation_input_tokens":1000,"cache_read_input_tokens":3000,"output_tokens":1}}}` + "\n\n" +
+	"event: content_block_delta\n" +
+	`data: {"type":"content_block_delta","delta":{"type":"text_delta","text":"hi"}}` + "\n\n" +
+	"event: message_delta\n" +
+	`data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":42}}` + "\n\n"
+
+// TestParseTokenUsageFromSSE 测试 message_start 的缓存 tokens 与 message_delta 的输出 tokens 合并
+func TestParseTokenUsageFromSSE(t *testing.T) {
+	got := parseTokenUsage([]byte(cacheSSE))
+	want := store.TokenUsage{InputTokens: 20, OutputTokens: 42, CacheCreationTokens: 1000, CacheReadTokens: 3000}
+	if got != want {
+		t.Fatalf("unexpected usage %+v", got)
+	}
+}
+
+// TestUsageReaderKeepsTail 测试超过头部缓冲的长流仍能解析结尾 message_delta
+func TestUsageReaderKeepsTail(t *testing.T) {
+	start := cacheSSE[:strings.Index(cacheSSE, "event: content_block_delta")]
+	end := cacheSSE[strings.Index(cacheSSE, "event: message_delta"):]
+	filler := strings.Repeat("event: ping\ndata: {\"type\":\"ping\"}\n\n", usageBufLimit/30)
+	body := start + filler + end
+
+	u := &usage{}
+	r := &usageReader{ReadCloser: io.NopCloser(strings.NewReader(body)), tracker: u, buf: &bytes.Buffer{}}
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	r.Close()
+	if u.input != 20 || u.output != 42 || u.cacheCreation != 1000 || u.cacheRead != 3000 {
+		t.Fatalf("unexpected usage %+v", *u)
+	}
+}
+
+// TestCalculateCostWithCache 测试缓存写入/读取按各自价格计费，未配置时按默认倍率推导
+func TestCalculateCostWithCache(t *testing.T) {
+	st, err := store.OpenSQLite(filepath.Join(t.TempDir(), "pricing.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer st.Close()
+	ctx := context.Background()
+
+	usage := store.TokenUsage{InputTokens: 1_000_000, OutputTokens: 1_000_000, CacheCreationTokens: 1_000_000, CacheReadTokens: 1_000_000}
+	// 预置模型：3 + 15 + 3.75 + 0.3
+	cost, err := st.CalculateCost(ctx, "claude-sonnet-4-5-20250929", usage, time.Time{})
+	if err != nil || math.Abs(cost.CostUSD-22.05) > 1e-6 {
+		t.Fatalf("unexpected seeded cost %v (%v)", cost, err)
+	}
+
+	if err := st.UpsertModelPricing(ctx, store.ModelPricingRecord{ModelID: "custom", ModelName: "Custom", InputPriceMTok: 2, OutputPriceMTok: 10, CacheWritePriceMTok: 4, CacheReadPriceMTok: 1, IsActive: true}); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+	if cost, _ = st.CalculateCost(ctx, "custom", usage, time.Time{}); math.Abs(cost.CostUSD-17) > 1e-6 {
+		t.Fatalf("unexpected custom cost %v", cost)
+	}
+
+	if err := st.UpsertModelPricing(ctx, store.ModelPricingRecord{ModelID: "nocache", ModelName: "NoCache", InputPriceMTok: 2, OutputPriceMTok: 10, IsActive: true}); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+	if cost, _ = st.CalculateCost(ctx, "nocache", usage, time.Time{}); math.Abs(cost.CostUSD-14.7) > 1e-6 {
+		t.Fatalf("unexpected fallback cost %v", cost)
+	}
+}
@@ -0,0 +1,560 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"qcc_plus/internal/logging"
+	"qcc_plus/internal/store"
+)
+
+// captureSettingKey 账号级抓包模式在 settings 表中的 key（scope=account）。
+const captureSettingKey = "proxy.capture"
+
+const (
+	defaultCaptureMaxBytes  = 1 << 20 // 单个请求/响应体默认保存上限
+	defaultCaptureRetention = 24 * time.Hour
+	maxCaptureWindow        = 24 * time.Hour // 单次开启抓包的最长时长
+	captureWriteTimeout     = 10 * time.Second
+	replayTimeout           = 5 * time.Minute
+	captureRedacted         = "***"
+)
+
+// 抓包与重放时不保存、不转发的请求头。
+var captureDropHeaders = map[string]bool{
+	"Authorization":       true,
+	"X-Api-Key":           true,
+	"Cookie":              true,
+	"Proxy-Authorization": true,
+	"Content-Length":      true,
+}
+
+// CaptureConfig 账号级抓包模式，Until 之后自动失效。
+type CaptureConfig struct {
+	Until          time.Time `json:"until"`
+	Model          string    `json:"model,omitempty"`           // 模型 ID 正则，空表示全部
+	RedactPatterns []string  `json:"redact_patterns,omitempty"` // 保存前替换为 *** 的正则
+	RedactFields   []string  `json:"redact_fields,omitempty"`   // 任意层级同名 JSON 字段的值替换为 ***
+	MaxBodyBytes   int       `json:"max_body_bytes,omitempty"`  // 单个请求/响应体上限，受 CAPTURE_MAX_BODY_BYTES 约束
+}
+
+// captureRule 编译后的抓包规则。
+type captureRule struct {
+	CaptureConfig
+	model    *regexp.Regexp
+	patterns []*regexp.Regexp
+	fields   map[string]bool
+}
+
+// parseCaptureConfig 解析 settings 中的 JSON 对象值。
+func parseCaptureConfig(value any) (*captureRule, error) {
+	var cfg CaptureConfig
+	var raw []byte
+	switch v := value.(type) {
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		raw = b
+	}
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid capture config: %w", err)
+	}
+	if cfg.Until.IsZero() {
+		return nil, fmt.Errorf("invalid capture config: until required")
+	}
+	if cfg.MaxBodyBytes < 0 {
+		return nil, fmt.Errorf("invalid capture config: max_body_bytes must not be negative")
+	}
+	rule := &captureRule{CaptureConfig: cfg, fields: make(map[string]bool)}
+	if cfg.Model != "" {
+		re, err := regexp.Compile(cfg.Model)
+		if err != nil {
+			return nil, fmt.Errorf("invalid capture config: model: %w", err)
+		}
+		rule.model = re
+	}
+	for _, p := range cfg.RedactPatterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid capture config: redact pattern %q: %w", p, err)
+		}
+		rule.patterns = append(rule.patterns, re)
+	}
+	for _, f := range cfg.RedactFields {
+		if f = strings.TrimSpace(f); f != "" {
+			rule.fields[f] = true
+		}
+	}
+	return rule, nil
+}
+
+// validateCaptureSetting 写入配置时额外限制开启时长。
+func validateCaptureSetting(value any) error {
+	if value == nil {
+		return nil
+	}
+	rule, err := parseCaptureConfig(value)
+	if err != nil {
+		return err
+	}
+	if rule.Until.After(time.Now().Add(maxCaptureWindow)) {
+		return fmt.Errorf("invalid capture config: until must be within %s", maxCaptureWindow)
+	}
+	return nil
+}
+
+// applyAccountCapture 应用账号抓包配置；value 为 nil 表示关闭。
+func (p *Server) applyAccountCapture(accountID string, value any) {
+	if value == nil {
+		p.captureMu.Lock()
+		delete(p.captureRules, accountID)
+		p.captureMu.Unlock()
+		return
+	}
+	rule, err := parseCaptureConfig(value)
+	if err != nil {
+		p.logger.Warn("invalid capture setting", logging.KeyAccountID, accountID, logging.KeyError, err)
+		return
+	}
+	p.captureMu.Lock()
+	if p.captureRules == nil {
+		p.captureRules = make(map[string]*captureRule)
+	}
+	p.captureRules[accountID] = rule
+	p.captureMu.Unlock()
+}
+
+// captureRuleFor 返回账号当前生效且匹配模型的抓包规则。
+func (p *Server) captureRuleFor(accountID, modelID string) *captureRule {
+	p.captureMu.RLock()
+	rule := p.captureRules[accountID]
+	p.captureMu.RUnlock()
+	if rule == nil || !time.Now().Before(rule.Until) {
+		return nil
+	}
+	if rule.model != nil && !rule.model.MatchString(modelID) {
+		return nil
+	}
+	return rule
+}
+
+// captureMaxBytesFor 返回规则生效的大小上限。
+func (p *Server) captureMaxBytesFor(rule *captureRule) int {
+	limit := p.captureMaxBytes
+	if limit <= 0 {
+		limit = defaultCaptureMaxBytes
+	}
+	if rule.MaxBodyBytes > 0 && rule.MaxBodyBytes < limit {
+		limit = rule.MaxBodyBytes
+	}
+	return limit
+}
+
+// redact 依次执行字段脱敏、内置密钥脱敏与自定义正则脱敏。
+func (c *captureRule) redact(body []byte) []byte {
+	if len(body) == 0 {
+		return body
+	}
+	if len(c.fields) > 0 {
+		body = redactJSONFields(body, c.fields)
+	}
+	s := logging.Redact(string(body))
+	for _, re := range c.patterns {
+		s = re.ReplaceAllString(s, captureRedacted)
+	}
+	return []byte(s)
+}
+
+// redactJSONFields 脱敏 JSON 体；非 JSON 时按 SSE 的 data 行逐条处理。
+func redactJSONFields(body []byte, fields map[string]bool) []byte {
+	if out, ok := redactJSONDoc(body, fields); ok {
+		return out
+	}
+	lines := bytes.Split(body, []byte("\n"))
+	for i, line := range lines {
+		payload, ok := bytes.CutPrefix(line, []byte("data:"))
+		if !ok {
+			continue
+		}
+		if out, ok := redactJSONDoc(bytes.TrimSpace(payload), fields); ok {
+			lines[i] = append([]byte("data: "), out...)
+		}
+	}
+	return bytes.Join(lines, []byte("\n"))
+}
+
+func redactJSONDoc(doc []byte, fields map[string]bool) ([]byte, bool) {
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, false
+	}
+	redactJSONValue(v, fields)
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, false
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), true
+}
+
+func redactJSONValue(v any, fields map[string]bool) {
+	switch tv := v.(type) {
+	case map[string]any:
+		for k, child := range tv {
+			if fields[k] {
+				tv[k] = captureRedacted
+				continue
+			}
+			redactJSONValue(child, fields)
+		}
+	case []any:
+		for _, child := range tv {
+			redactJSONValue(child, fields)
+		}
+	}
+}
+
+// captureHeaders 序列化请求/响应头，去除认证信息。
+func captureHeaders(h http.Header) string {
+	out := make(map[string]string, len(h))
+	for k, v := range h {
+		if captureDropHeaders[http.CanonicalHeaderKey(k)] {
+			continue
+		}
+		out[k] = logging.Redact(strings.Join(v, ", "))
+	}
+	b, err := json.Marshal(out)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// captureWriter 在写给客户端的同时保留响应副本（超过上限的部分丢弃）。
+type captureWriter struct {
+	http.ResponseWriter
+	status int
+	limit  int
+	total  int64
+	buf    bytes.Buffer
+}
+
+func (cw *captureWriter) WriteHeader(code int) {
+	if cw.status == 0 {
+		cw.status = code
+	}
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *captureWriter) Write(b []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	cw.total += int64(len(b))
+	if room := cw.limit - cw.buf.Len(); room > 0 {
+		cw.buf.Write(b[:min(room, len(b))])
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+func (cw *captureWriter) Flush() {
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *captureWriter) Unwrap() http.ResponseWriter { return cw.ResponseWriter }
+
+// requestCapture 单个请求的抓包上下文。
+type requestCapture struct {
+	rule    *captureRule
+	writer  *captureWriter
+	method  string
+	path    string
+	headers http.Header
+	body    []byte
+}
+
+// startCapture 账号处于抓包模式且模型匹配时包装 ResponseWriter。
+func (p *Server) startCapture(w http.ResponseWriter, r *http.Request, accountID string, body []byte) (*requestCapture, http.ResponseWriter) {
+	if p.store == nil {
+		return nil, w
+	}
+	rule := p.captureRuleFor(accountID, parseModelFromRequest(body))
+	if rule == nil {
+		return nil, w
+	}
+	cw := &captureWriter{ResponseWriter: w, limit: p.captureMaxBytesFor(rule)}
+	return &requestCapture{
+		rule:    rule,
+		writer:  cw,
+		method:  r.Method,
+		path:    r.URL.RequestURI(),
+		headers: r.Header.Clone(),
+		body:    body,
+	}, cw
+}
+
+// finishCapture 脱敏后异步保存抓包，不阻塞请求返回。
+func (p *Server) finishCapture(t *RequestTrace, c *requestCapture) {
+	if c == nil || t == nil {
+		return
+	}
+	limit := p.captureMaxBytesFor(c.rule)
+	reqBody := c.rule.redact(c.body)
+	truncated := c.writer.total > int64(c.writer.buf.Len())
+	if len(reqBody) > limit {
+		reqBody = reqBody[:limit]
+		truncated = true
+	}
+	rec := store.RequestCaptureRecord{
+		RequestID:       t.RequestID,
+		AccountID:       t.AccountID,
+		ModelID:         t.audit.modelID,
+		Method:          c.method,
+		Path:            c.path,
+		Status:          c.writer.status,
+		Stream:          t.audit.stream,
+		RequestHeaders:  captureHeaders(c.headers),
+		ResponseHeaders: captureHeaders(c.writer.Header()),
+		RequestBody:     reqBody,
+		ResponseBody:    c.rule.redact(c.writer.buf.Bytes()),
+		RequestBytes:    int64(len(c.body)),
+		ResponseBytes:   c.writer.total,
+		Truncated:       truncated,
+		CreatedAt:       t.StartedAt,
+	}
+	for _, a := range t.Attempts {
+		if a.Skipped == "" {
+			rec.NodeID = a.NodeID
+		}
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), captureWriteTimeout)
+		defer cancel()
+		if _, err := p.store.InsertRequestCapture(ctx, rec); err != nil {
+			p.logger.Warn("save request capture failed", logging.KeyRequestID, rec.RequestID, logging.KeyAccountID, rec.AccountID, logging.KeyError, err)
+		}
+	}()
+}
+
+// GET /admin/api/captures?account_id=xxx&limit=50&offset=0
+// 列出抓包记录与当前开启抓包模式的账号（仅管理员）。
+func (p *Server) handleCaptures(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !isAdmin(r.Context()) {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "forbidden"})
+		return
+	}
+	if p.store == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+	recs, err := p.store.ListRequestCaptures(r.Context(), r.URL.Query().Get("account_id"), limit, offset)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if recs == nil {
+		recs = []store.RequestCaptureRecord{}
+	}
+
+	now := time.Now()
+	active := make(map[string]CaptureConfig)
+	p.captureMu.RLock()
+	for accountID, rule := range p.captureRules {
+		if now.Before(rule.Until) {
+			active[accountID] = rule.CaptureConfig
+		}
+	}
+	p.captureMu.RUnlock()
+	writeJSON(w, http.StatusOK, map[string]interface{}{"captures": recs, "active": active})
+}
+
+// /admin/api/captures/{id}
+//
+//	GET                 查看抓包详情，?download=1 时作为附件下载
+//	POST .../replay     将抓包请求重放到指定节点，请求体 {"node_id":"xxx"}
+func (p *Server) handleCaptureByID(w http.ResponseWriter, r *http.Request) {
+	if !isAdmin(r.Context()) {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "forbidden"})
+		return
+	}
+	if p.store == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/api/captures/"), "/")
+	idStr, action, _ := strings.Cut(rest, "/")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	rec, err := p.store.GetRequestCapture(r.Context(), id)
+	if err == store.ErrNotFound {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "capture not found"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	switch action {
+	case "":
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if boolLike(r.URL.Query().Get("download")) {
+			w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="capture-%d.json"`, rec.ID))
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"capture":       rec,
+			"request_body":  string(rec.RequestBody),
+			"response_body": string(rec.ResponseBody),
+		})
+	case "replay":
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var req struct {
+			NodeID        string `json:"node_id"`
+			AllowRedacted bool   `json:"allow_redacted"` // 允许以 *** 替换后的内容重放
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.NodeID == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "node_id required"})
+			return
+		}
+		truncated, redacted := captureRequestState(rec)
+		if truncated {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": "captured request body is truncated and cannot be replayed"})
+			return
+		}
+		if redacted && !req.AllowRedacted {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": "captured request body contains redacted values; set allow_redacted to replay with *** placeholders"})
+			return
+		}
+		node := p.getNode(req.NodeID)
+		if node == nil || node.URL == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "node not found"})
+			return
+		}
+		result, err := p.replayCapture(r.Context(), rec, node)
+		if err != nil {
+			writeJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+			return
+		}
+		result["redacted"] = redacted
+		writeJSON(w, http.StatusOK, result)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// captureRequestState 判断抓包请求体是否被截断或脱敏。未脱敏时按保存长度与原始长度比较；
+// 脱敏会改变长度，此时以 JSON 是否完整判断截断。
+func captureRequestState(rec *store.RequestCaptureRecord) (truncated, redacted bool) {
+	body := rec.RequestBody
+	redacted = bytes.Contains(body, []byte(captureRedacted))
+	if !redacted {
+		return int64(len(body)) != rec.RequestBytes, false
+	}
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		truncated = !json.Valid(trimmed)
+	}
+	return truncated, true
+}
+
+// replayCapture 使用节点凭证重放抓包请求，不经过重试、熔断与计费链路。
+// 调用方需先用 captureRequestState 排除截断的请求体；脱敏字段会以 *** 发送。
+func (p *Server) replayCapture(ctx context.Context, rec *store.RequestCaptureRecord, node *Node) (map[string]interface{}, error) {
+	target := *node.URL
+	path, query, _ := strings.Cut(rec.Path, "?")
+	target.Path = strings.TrimSuffix(target.Path, "/") + path
+	target.RawQuery = query
+
+	ctx, cancel := context.WithTimeout(ctx, replayTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, chooseNonEmpty(rec.Method, http.MethodPost), target.String(), bytes.NewReader(rec.RequestBody))
+	if err != nil {
+		return nil, err
+	}
+	if rec.RequestHeaders != "" {
+		var headers map[string]string
+		if err := json.Unmarshal([]byte(rec.RequestHeaders), &headers); err == nil {
+			for k, v := range headers {
+				if !captureDropHeaders[http.CanonicalHeaderKey(k)] && !strings.EqualFold(k, "Accept-Encoding") {
+					req.Header.Set(k, v)
+				}
+			}
+		}
+	}
+	if node.APIKey != "" {
+		req.Header.Set("x-api-key", node.APIKey)
+		req.Header.Set("Authorization", "Bearer "+node.APIKey)
+	}
+
+	rt := p.healthRT
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	start := time.Now()
+	resp, err := (&http.Client{Transport: rt}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	limit := p.captureMaxBytes
+	if limit <= 0 {
+		limit = defaultCaptureMaxBytes
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	truncated := len(body) > limit
+	if truncated {
+		body = body[:limit]
+	}
+	return map[string]interface{}{
+		"capture_id":       rec.ID,
+		"node_id":          node.ID,
+		"status":           resp.StatusCode,
+		"duration_ms":      time.Since(start).Milliseconds(),
+		"response_headers": json.RawMessage(captureHeaders(resp.Header)),
+		"response_body":    logging.Redact(string(body)),
+		"truncated":        truncated,
+	}, nil
+}
@@ -0,0 +1,170 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"qcc_plus/internal/store"
+)
+
+// TestCaptureRedaction 测试字段脱敏（JSON 与 SSE）、自定义正则与内置密钥脱敏
+func TestCaptureRedaction(t *testing.T) {
+	rule, err := parseCaptureConfig(map[string]any{
+		"until":           time.Now().Add(time.Hour).Format(time.RFC3339),
+		"redact_fields":   []string{"secret"},
+		"redact_patterns": []string{`\d{3}-\d{4}`},
+	})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	got := string(rule.redact([]byte(`{"a":{"secret":"x","n":12345678901234567890},"tel":"555-1234","k":"sk-ant-api03-abcdefghijkl"}`)))
+	want := `{"a":{"n":12345678901234567890,"secret":"***"},"k":"sk-ant-***","tel":"***"}`
+	if got != want {
+		t.Fatalf("json redaction:\n got %s\nwant %s", got, want)
+	}
+
+	sse := "event: x\ndata: {\"secret\":\"y\",\"text\":\"<b>\"}\n\n"
+	if got := string(rule.redact([]byte(sse))); got != "event: x\ndata: {\"secret\":\"***\",\"text\":\"<b>\"}\n\n" {
+		t.Fatalf("sse redaction: %q", got)
+	}
+
+	if err := validateCaptureSetting(map[string]any{"until": time.Now().Add(48 * time.Hour).Format(time.RFC3339)}); err == nil {
+		t.Fatalf("expected capture window over 24h rejected")
+	}
+	if _, err := parseCaptureConfig(map[string]any{"model": "opus"}); err == nil {
+		t.Fatalf("expected missing until rejected")
+	}
+}
+
+// TestCaptureAndReplay 测试抓包模式下保存请求与 SSE 响应，并重放到另一节点
+func TestCaptureAndReplay(t *testing.T) {
+	var replayed string
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Replay-Check") == "1" {
+			b := make([]byte, r.ContentLength)
+			r.Body.Read(b)
+			replayed = string(b)
+			if r.Header.Get("x-api-key") != "node-key" {
+				t.Errorf("expected node credentials on replay, got %q", r.Header.Get("x-api-key"))
+			}
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("event: message_start\ndata: {\"type\":\"message_start\",\"secret\":\"resp\"}\n\n"))
+	}))
+	defer up.Close()
+	u, _ := url.Parse(up.URL)
+
+	st, err := store.OpenSQLite(filepath.Join(t.TempDir(), "capture.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer st.Close()
+
+	srv := newClusterTestServer(t, NewLocalClusterBus(), "a")
+	srv.store = st
+	srv.transport = http.DefaultTransport
+	srv.errorClassifier = NewErrorClassifier()
+	srv.traces = newTraceBuffer(10)
+	srv.retryConfig = RetryConfig{MaxAttempts: 1, PerRequestTimeout: 5 * time.Second}
+	acc := srv.TestAccount("acc-1")
+	srv.defaultAccount = acc
+	for _, n := range acc.Nodes {
+		n.URL = u
+		n.APIKey = "node-key"
+	}
+	srv.applyAccountCapture(acc.ID, map[string]any{
+		"until":         time.Now().Add(time.Hour).Format(time.RFC3339),
+		"model":         "^claude-",
+		"redact_fields": []string{"secret"},
+	})
+
+	send := func(model string) {
+		body := `{"model":"` + model + `","stream":true,"secret":"req"}`
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("x-api-key", "client-key")
+		req.Header.Set("X-Replay-Check", "1")
+		rec := httptest.NewRecorder()
+		srv.handler().ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"secret":"resp"`) {
+			t.Fatalf("client should receive unredacted response, got %d %s", rec.Code, rec.Body.String())
+		}
+	}
+	send("other-model")
+	send("claude-sonnet-4-5")
+
+	ctx := context.Background()
+	var captures []store.RequestCaptureRecord
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if captures, _ = st.ListRequestCaptures(ctx, acc.ID, 10, 0); len(captures) > 0 {
+			break
+		}
+	}
+	if len(captures) != 1 || captures[0].ModelID != "claude-sonnet-4-5" || !captures[0].Stream {
+		t.Fatalf("expected one matching capture, got %+v", captures)
+	}
+	if strings.Contains(captures[0].RequestHeaders, "client-key") {
+		t.Fatalf("api key must not be captured: %s", captures[0].RequestHeaders)
+	}
+
+	adminCtx := context.WithValue(ctx, isAdminContextKey{}, true)
+	id := captures[0].ID
+	get := httptest.NewRequest(http.MethodGet, "/admin/api/captures/"+strconv.FormatInt(id, 10), nil).WithContext(adminCtx)
+	rec := httptest.NewRecorder()
+	srv.handleCaptureByID(rec, get)
+	var detail struct {
+		RequestBody  string `json:"request_body"`
+		ResponseBody string `json:"response_body"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &detail); err != nil {
+		t.Fatalf("decode detail %s: %v", rec.Body.String(), err)
+	}
+	if !strings.Contains(detail.RequestBody, `"secret":"***"`) || !strings.Contains(detail.ResponseBody, `"secret":"***"`) {
+		t.Fatalf("expected redacted bodies, got %+v", detail)
+	}
+
+	replayed = ""
+	replayPath := "/admin/api/captures/" + strconv.FormatInt(id, 10) + "/replay"
+	rec = httptest.NewRecorder()
+	srv.handleCaptureByID(rec, httptest.NewRequest(http.MethodPost, replayPath, strings.NewReader(`{"node_id":"n2"}`)).WithContext(adminCtx))
+	if rec.Code != http.StatusUnprocessableEntity || replayed != "" {
+		t.Fatalf("redacted capture must not be replayed without allow_redacted, got %d %s", rec.Code, rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	srv.handleCaptureByID(rec, httptest.NewRequest(http.MethodPost, replayPath, strings.NewReader(`{"node_id":"n2","allow_redacted":true}`)).WithContext(adminCtx))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"status":200`) || !strings.Contains(rec.Body.String(), `"redacted":true`) {
+		t.Fatalf("unexpected replay response %d %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(replayed, `"model":"claude-sonnet-4-5"`) {
+		t.Fatalf("unexpected replayed body %q", replayed)
+	}
+}
+
+// TestCaptureRequestState 测试抓包请求体截断与脱敏判断
+func TestCaptureRequestState(t *testing.T) {
+	cases := []struct {
+		body      string
+		bytes     int64
+		truncated bool
+		redacted  bool
+	}{
+		{`{"model":"m"}`, 13, false, false},
+		{`{"model":"m"`, 13, true, false},
+		{`{"model":"m","secret":"***"}`, 30, false, true},
+		{`{"model":"m","secret":"***"`, 300, true, true},
+	}
+	for _, c := range cases {
+		truncated, redacted := captureRequestState(&store.RequestCaptureRecord{RequestBody: []byte(c.body), RequestBytes: c.bytes})
+		if truncated != c.truncated || redacted != c.redacted {
+			t.Errorf("%s: got truncated=%v redacted=%v", c.body, truncated, redacted)
+		}
+	}
+}
@@ -1,11 +1,12 @@
 package proxy
 
 import (
-	"log"
 	"os"
 	"strconv"
 	"sync"
 	"time"
+
+	"qcc_plus/internal/logging"
 )
 
 // CircuitBreakerState 熔断器状态
@@ -32,12 +33,88 @@ func (s CircuitBreakerState) String() string {
 
 // CircuitBreakerConfig 熔断器配置
 type CircuitBreakerConfig struct {
-	Enabled          bool    // 是否启用熔断器，默认 true
-	WindowSeconds    int     // 滑动窗口大小（秒），默认 60
-	FailureRate      float64 // 失败率阈值（0-1），默认 0.5
-	ConsecutiveFails int     // 连续失败次数阈值，默认 5
-	CooldownSeconds  int     // 冷却时间（秒），默认 30
-	HalfOpenMaxCalls int     // 半开状态最大试探次数，默认 3
+	Enabled          bool    `json:"enabled"`             // 是否启用熔断器，默认 true
+	WindowSeconds    int     `json:"window_seconds"`      // 滑动窗口大小（秒），默认 60
+	FailureRate      float64 `json:"failure_rate"`        // 失败率阈值（0-1），默认 0.5
+	ConsecutiveFails int     `json:"consecutive_fails"`   // 连续失败次数阈值，默认 5
+	CooldownSeconds  int     `json:"cooldown_seconds"`    // 冷却时间（秒），默认 30
+	HalfOpenMaxCalls int     `json:"half_open_max_calls"` // 半开状态最大试探次数，默认 3
+}
+
+// CircuitBreakerOverride 节点级熔断参数覆盖，零值表示沿用全局配置。
+type CircuitBreakerOverride struct {
+	WindowSeconds    int     `json:"window_seconds,omitempty"`
+	FailureRate      float64 `json:"failure_rate,omitempty"`
+	ConsecutiveFails int     `json:"consecutive_fails,omitempty"`
+	CooldownSeconds  int     `json:"cooldown_seconds,omitempty"`
+	HalfOpenMaxCalls int     `json:"half_open_max_calls,omitempty"`
+}
+
+// IsZero 判断是否未覆盖任何参数。
+func (o CircuitBreakerOverride) IsZero() bool {
+	return o == CircuitBreakerOverride{}
+}
+
+// Apply 在全局配置上叠加节点覆盖。
+func (o CircuitBreakerOverride) Apply(cfg CircuitBreakerConfig) CircuitBreakerConfig {
+	if o.WindowSeconds > 0 {
+		cfg.WindowSeconds = o.WindowSeconds
+	}
+	if o.FailureRate > 0 && o.FailureRate <= 1 {
+		cfg.FailureRate = o.FailureRate
+	}
+	if o.ConsecutiveFails > 0 {
+		cfg.ConsecutiveFails = o.ConsecutiveFails
+	}
+	if o.CooldownSeconds > 0 {
+		cfg.CooldownSeconds = o.CooldownSeconds
+	}
+	if o.HalfOpenMaxCalls > 0 {
+		cfg.HalfOpenMaxCalls = o.HalfOpenMaxCalls
+	}
+	return applyCircuitBreakerDefaults(cfg)
+}
+
+// 熔断器状态转换原因。
+const (
+	CBReasonConsecutiveFails = "consecutive_failures"
+	CBReasonFailureRate      = "failure_rate"
+	CBReasonCooldownElapsed  = "cooldown_elapsed"
+	CBReasonHalfOpenSuccess  = "half_open_success"
+	CBReasonHalfOpenFailure  = "half_open_failure"
+	CBReasonManualTrip       = "manual_trip"
+	CBReasonManualReset      = "manual_reset"
+	CBReasonNodeRecovered    = "node_recovered"
+	CBReasonReset            = "reset"
+)
+
+// CircuitBreakerTransition 一次熔断器状态转换。
+type CircuitBreakerTransition struct {
+	From             CircuitBreakerState
+	To               CircuitBreakerState
+	Reason           string
+	ConsecutiveFails int
+	FailureRate      float64
+	At               time.Time
+	Forced           bool
+}
+
+// CircuitBreakerSnapshot 熔断器状态与计数器快照。
+type CircuitBreakerSnapshot struct {
+	State            string               `json:"state"`
+	Forced           bool                 `json:"forced"` // 手动熔断，冷却后不会自动进入半开
+	StateChangedAt   time.Time            `json:"state_changed_at"`
+	ConsecutiveFails int                  `json:"consecutive_fails"`
+	WindowRequests   int                  `json:"window_requests"`
+	WindowFailures   int                  `json:"window_failures"`
+	FailureRate      float64              `json:"failure_rate"`
+	HalfOpenCalls    int                  `json:"half_open_calls"`
+	HalfOpenSuccess  int                  `json:"half_open_success"`
+	TotalRequests    int64                `json:"total_requests"`
+	TotalFailures    int64                `json:"total_failures"`
+	Rejected         int64                `json:"rejected"`
+	Opens            int64                `json:"opens"`
+	Config           CircuitBreakerConfig `json:"config"`
 }
 
 // CircuitBreaker 熔断器
@@ -49,7 +126,18 @@ type CircuitBreaker struct {
 	stateChangedAt   time.Time       // 状态变更时间
 	halfOpenCalls    int             // 半开状态下的调用次数
 	halfOpenSuccess  int             // 半开状态下的成功次数
+	forced           bool            // 手动熔断，直到手动重置
 	config           CircuitBreakerConfig
+
+	// 累计计数器
+	totalRequests int64
+	totalFailures int64
+	rejected      int64
+	opens         int64
+
+	// onTransition 状态转换回调，在释放锁后按发生顺序调用。
+	onTransition func(CircuitBreakerTransition)
+	pending      []CircuitBreakerTransition
 }
 
 type requestRecord struct {
@@ -97,7 +185,7 @@ func loadCircuitBreakerConfig() CircuitBreakerConfig {
 		HalfOpenMaxCalls: 3,
 	}
 
-	logger := log.Default()
+	logger := logging.Default()
 
 	cfg.Enabled = parseEnvBool("CB_ENABLED", cfg.Enabled, logger)
 	cfg.WindowSeconds = parseEnvInt("CB_WINDOW_SECONDS", cfg.WindowSeconds, logger)
@@ -109,7 +197,7 @@ func loadCircuitBreakerConfig() CircuitBreakerConfig {
 		if f, err := strconv.ParseFloat(v, 64); err == nil && f >= 0 && f <= 1 {
 			cfg.FailureRate = f
 		} else if logger != nil {
-			logger.Printf("invalid CB_FAILURE_RATE=%s, fallback to %.2f", v, cfg.FailureRate)
+			logger.Warn("invalid environment variable, using default", "env", "CB_FAILURE_RATE", "value", v, "default", cfg.FailureRate)
 		}
 	}
 
@@ -122,21 +210,30 @@ func (cb *CircuitBreaker) AllowRequest() bool {
 		return true
 	}
 	cb.mu.Lock()
-	defer cb.mu.Unlock()
+	allowed := cb.allowLocked(time.Now())
+	if !allowed {
+		cb.rejected++
+	}
+	cb.unlockAndEmit()
+	return allowed
+}
 
+func (cb *CircuitBreaker) allowLocked(now time.Time) bool {
 	if !cb.config.Enabled {
 		return true
 	}
 
-	now := time.Now()
-
 	switch cb.state {
 	case StateClosed:
 		return true // 正常状态，允许所有请求
 	case StateOpen:
+		// 手动熔断不随冷却时间恢复
+		if cb.forced {
+			return false
+		}
 		// 检查是否到达冷却时间
 		if now.Sub(cb.stateChangedAt) >= time.Duration(cb.config.CooldownSeconds)*time.Second {
-			cb.transitionLocked(StateHalfOpen, now)
+			cb.transitionLocked(StateHalfOpen, now, CBReasonCooldownElapsed)
 			cb.halfOpenCalls = 1 // 将当前请求计为半开试探的第一次调用
 			cb.halfOpenSuccess = 0
 			return true
@@ -160,13 +257,17 @@ func (cb *CircuitBreaker) RecordResult(success bool) {
 		return
 	}
 	cb.mu.Lock()
-	defer cb.mu.Unlock()
+	defer cb.unlockAndEmit()
 
 	if !cb.config.Enabled {
 		return
 	}
 
 	now := time.Now()
+	cb.totalRequests++
+	if !success {
+		cb.totalFailures++
+	}
 
 	// 记录到滑动窗口
 	cb.requests = append(cb.requests, requestRecord{
@@ -200,8 +301,8 @@ func (cb *CircuitBreaker) RecordResult(success bool) {
 	switch cb.state {
 	case StateClosed:
 		// 检查是否需要熔断
-		if cb.shouldOpenLocked() {
-			cb.transitionLocked(StateOpen, now)
+		if reason := cb.openReasonLocked(); reason != "" {
+			cb.transitionLocked(StateOpen, now, reason)
 		}
 	case StateHalfOpen:
 		// 半开状态下，记录试探结果
@@ -212,12 +313,12 @@ func (cb *CircuitBreaker) RecordResult(success bool) {
 		if cb.halfOpenCalls >= cb.config.HalfOpenMaxCalls {
 			if cb.halfOpenSuccess >= cb.config.HalfOpenMaxCalls {
 				// 全部成功，恢复正常
-				cb.transitionLocked(StateClosed, now)
+				cb.transitionLocked(StateClosed, now, CBReasonHalfOpenSuccess)
 				cb.consecutiveFails = 0
 				cb.requests = nil
 			} else {
 				// 仍有失败，重新熔断
-				cb.transitionLocked(StateOpen, now)
+				cb.transitionLocked(StateOpen, now, CBReasonHalfOpenFailure)
 			}
 		}
 	}
@@ -231,24 +332,37 @@ func (cb *CircuitBreaker) shouldOpen() bool {
 }
 
 func (cb *CircuitBreaker) shouldOpenLocked() bool {
+	return cb.openReasonLocked() != ""
+}
+
+// openReasonLocked 返回触发熔断的原因，未达到阈值时返回空字符串。
+func (cb *CircuitBreaker) openReasonLocked() string {
 	// 条件1：连续失败次数达到阈值
 	if cb.consecutiveFails >= cb.config.ConsecutiveFails {
-		return true
+		return CBReasonConsecutiveFails
 	}
 
 	// 条件2：失败率达到阈值
 	if len(cb.requests) == 0 {
-		return false
+		return ""
+	}
+	if cb.windowFailureRateLocked() >= cb.config.FailureRate {
+		return CBReasonFailureRate
 	}
+	return ""
+}
 
+func (cb *CircuitBreaker) windowFailureRateLocked() float64 {
+	if len(cb.requests) == 0 {
+		return 0
+	}
 	failCount := 0
 	for _, r := range cb.requests {
 		if r.failed {
 			failCount++
 		}
 	}
-	failureRate := float64(failCount) / float64(len(cb.requests))
-	return failureRate >= cb.config.FailureRate
+	return float64(failCount) / float64(len(cb.requests))
 }
 
 // GetState 获取当前状态
@@ -263,25 +377,170 @@ func (cb *CircuitBreaker) GetState() CircuitBreakerState {
 
 // Reset 重置熔断器
 func (cb *CircuitBreaker) Reset() {
+	cb.ResetWithReason(CBReasonReset)
+}
+
+// ResetWithReason 重置熔断器并记录转换原因（包括解除手动熔断）。
+func (cb *CircuitBreaker) ResetWithReason(reason string) {
 	if cb == nil {
 		return
 	}
 	cb.mu.Lock()
-	defer cb.mu.Unlock()
-	cb.state = StateClosed
+	defer cb.unlockAndEmit()
+	cb.resetLocked(reason)
+}
+
+// ResetUnlessForced 节点恢复等自动路径使用：手动熔断时保持不变并返回 false，只能通过手动重置解除。
+func (cb *CircuitBreaker) ResetUnlessForced(reason string) bool {
+	if cb == nil {
+		return false
+	}
+	cb.mu.Lock()
+	defer cb.unlockAndEmit()
+	if cb.forced {
+		return false
+	}
+	cb.resetLocked(reason)
+	return true
+}
+
+func (cb *CircuitBreaker) resetLocked(reason string) {
+	now := time.Now()
+	cb.transitionLocked(StateClosed, now, reason)
+	cb.forced = false
 	cb.requests = nil
 	cb.consecutiveFails = 0
 	cb.halfOpenCalls = 0
 	cb.halfOpenSuccess = 0
-	cb.stateChangedAt = time.Now()
+	cb.stateChangedAt = now
 }
 
-func (cb *CircuitBreaker) transitionLocked(next CircuitBreakerState, now time.Time) {
+// Trip 手动熔断：保持 Open 直到手动重置，冷却时间到达后也不会进入半开。
+func (cb *CircuitBreaker) Trip() {
+	if cb == nil {
+		return
+	}
+	cb.mu.Lock()
+	defer cb.unlockAndEmit()
+	cb.forced = true
+	if cb.state == StateOpen {
+		// 已处于自动熔断时仅升级为手动熔断，仍记录一次转换便于审计
+		cb.pending = append(cb.pending, cb.transitionRecordLocked(StateOpen, StateOpen, CBReasonManualTrip, time.Now()))
+		return
+	}
+	cb.transitionLocked(StateOpen, time.Now(), CBReasonManualTrip)
+}
+
+// Restore 恢复持久化的状态（仅在启动时调用，不触发回调）。
+// 半开状态按冷却已结束的 Open 恢复，首个请求即进入半开试探。
+func (cb *CircuitBreaker) Restore(state CircuitBreakerState, changedAt time.Time, forced bool) {
+	if cb == nil {
+		return
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	switch state {
+	case StateOpen:
+		cb.state = StateOpen
+		cb.stateChangedAt = changedAt
+	case StateHalfOpen:
+		cb.state = StateOpen
+		cb.stateChangedAt = time.Now().Add(-time.Duration(cb.config.CooldownSeconds) * time.Second)
+	default:
+		return
+	}
+	cb.forced = forced
+}
+
+// SetConfig 运行时更新熔断参数（节点覆盖变更时调用），保留当前状态与计数。
+func (cb *CircuitBreaker) SetConfig(cfg CircuitBreakerConfig) {
+	if cb == nil {
+		return
+	}
+	cb.mu.Lock()
+	cb.config = applyCircuitBreakerDefaults(cfg)
+	cb.mu.Unlock()
+}
+
+// OnTransition 注册状态转换回调。
+func (cb *CircuitBreaker) OnTransition(fn func(CircuitBreakerTransition)) {
+	if cb == nil {
+		return
+	}
+	cb.mu.Lock()
+	cb.onTransition = fn
+	cb.mu.Unlock()
+}
+
+// Snapshot 返回当前状态与计数器。
+func (cb *CircuitBreaker) Snapshot() CircuitBreakerSnapshot {
+	if cb == nil {
+		return CircuitBreakerSnapshot{State: StateClosed.String()}
+	}
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+	failures := 0
+	for _, r := range cb.requests {
+		if r.failed {
+			failures++
+		}
+	}
+	return CircuitBreakerSnapshot{
+		State:            cb.state.String(),
+		Forced:           cb.forced,
+		StateChangedAt:   cb.stateChangedAt,
+		ConsecutiveFails: cb.consecutiveFails,
+		WindowRequests:   len(cb.requests),
+		WindowFailures:   failures,
+		FailureRate:      cb.windowFailureRateLocked(),
+		HalfOpenCalls:    cb.halfOpenCalls,
+		HalfOpenSuccess:  cb.halfOpenSuccess,
+		TotalRequests:    cb.totalRequests,
+		TotalFailures:    cb.totalFailures,
+		Rejected:         cb.rejected,
+		Opens:            cb.opens,
+		Config:           cb.config,
+	}
+}
+
+func (cb *CircuitBreaker) transitionLocked(next CircuitBreakerState, now time.Time, reason string) {
 	if cb.state == next {
 		return
 	}
 	prev := cb.state
 	cb.state = next
 	cb.stateChangedAt = now
-	log.Printf("circuit breaker state %s -> %s", prev.String(), next.String())
+	if next == StateOpen {
+		cb.opens++
+	}
+	if next != StateOpen {
+		cb.forced = false
+	}
+	cb.pending = append(cb.pending, cb.transitionRecordLocked(prev, next, reason, now))
+}
+
+func (cb *CircuitBreaker) transitionRecordLocked(from, to CircuitBreakerState, reason string, now time.Time) CircuitBreakerTransition {
+	return CircuitBreakerTransition{
+		From:             from,
+		To:               to,
+		Reason:           reason,
+		ConsecutiveFails: cb.consecutiveFails,
+		FailureRate:      cb.windowFailureRateLocked(),
+		At:               now,
+		Forced:           cb.forced,
+	}
+}
+
+// unlockAndEmit 释放锁后依次调用转换回调，避免回调内访问熔断器造成死锁。
+func (cb *CircuitBreaker) unlockAndEmit() {
+	pending := cb.pending
+	cb.pending = nil
+	fn := cb.onTransition
+	cb.mu.Unlock()
+	if fn == nil {
+		return
+	}
+	for _, t := range pending {
+		fn(t)
+	}
 }
@@ -0,0 +1,240 @@
+package proxy
+
+import (
+	"context"
+	"time"
+
+	"qcc_plus/internal/logging"
+	"qcc_plus/internal/store"
+)
+
+const (
+	// cbEventQueueSize 熔断器转换日志写入队列长度，队列满时每个节点只保留最新一次转换。
+	cbEventQueueSize = 256
+	// cbEventRetention 熔断器转换日志保留时长。
+	cbEventRetention = 30 * 24 * time.Hour
+)
+
+// 熔断器管理操作（集群广播）。
+const (
+	cbActionTrip     = "trip"
+	cbActionReset    = "reset"
+	cbActionOverride = "override"
+)
+
+type nodeBreakerTransition struct {
+	nodeID string
+	CircuitBreakerTransition
+}
+
+type clusterBreakerChanged struct {
+	Action   string                 `json:"action"`
+	Override CircuitBreakerOverride `json:"override,omitempty"`
+}
+
+// parseCircuitBreakerState 将持久化的状态字符串还原为枚举，未知值视为 closed。
+func parseCircuitBreakerState(s string) CircuitBreakerState {
+	switch s {
+	case StateOpen.String():
+		return StateOpen
+	case StateHalfOpen.String():
+		return StateHalfOpen
+	default:
+		return StateClosed
+	}
+}
+
+// newNodeCircuitBreaker 按全局配置叠加节点覆盖创建熔断器，并挂接转换日志。调用方需持有 cbMu。
+func (p *Server) newNodeCircuitBreaker(nodeID string) *CircuitBreaker {
+	cb := NewCircuitBreaker(p.cbOverrides[nodeID].Apply(p.cbConfig))
+	cb.OnTransition(func(t CircuitBreakerTransition) {
+		p.onBreakerTransition(nodeID, t)
+	})
+	return cb
+}
+
+// effectiveBreakerConfig 返回节点生效的熔断配置与覆盖项。
+func (p *Server) effectiveBreakerConfig(nodeID string) (CircuitBreakerConfig, CircuitBreakerOverride) {
+	p.cbMu.RLock()
+	defer p.cbMu.RUnlock()
+	o := p.cbOverrides[nodeID]
+	return o.Apply(p.cbConfig), o
+}
+
+// onBreakerTransition 转换回调可能在持有 p.mu 时触发，这里只入队，由后台协程落库。
+// 队列满时丢弃中间转换，但保留节点最新状态，保证持久化状态与内存一致。
+func (p *Server) onBreakerTransition(nodeID string, t CircuitBreakerTransition) {
+	p.logger.Component("breaker").Info("circuit breaker state changed", logging.KeyNodeID, nodeID, "from", t.From.String(), "to", t.To.String(), "reason", t.Reason)
+	if p.cbEvents == nil {
+		return
+	}
+	ev := nodeBreakerTransition{nodeID: nodeID, CircuitBreakerTransition: t}
+	p.cbPendingMu.Lock()
+	defer p.cbPendingMu.Unlock()
+	select {
+	case p.cbEvents <- ev:
+		// 新转换已入队，未写入的旧状态不再需要
+		delete(p.cbPending, nodeID)
+		return
+	default:
+	}
+	if _, ok := p.cbPending[nodeID]; ok {
+		p.logger.Component("breaker").Warn("event queue full, coalesce transition", logging.KeyNodeID, nodeID, "from", t.From.String(), "to", t.To.String())
+	}
+	if p.cbPending == nil {
+		p.cbPending = make(map[string]nodeBreakerTransition)
+	}
+	p.cbPending[nodeID] = ev
+	select {
+	case p.cbWake <- struct{}{}:
+	default:
+	}
+}
+
+// runBreakerEventWriter 顺序写入转换日志并持久化最新状态，保证重启后状态与最后一次转换一致。
+// 队列排空后补写队列满时保留的各节点最新转换。
+func (p *Server) runBreakerEventWriter() {
+	for {
+		select {
+		case ev, ok := <-p.cbEvents:
+			if !ok {
+				return
+			}
+			p.writeBreakerTransition(ev)
+		case <-p.cbWake:
+		}
+		if len(p.cbEvents) == 0 {
+			p.flushPendingBreakerTransitions()
+		}
+	}
+}
+
+// flushPendingBreakerTransitions 写入队列满时保留的转换。
+func (p *Server) flushPendingBreakerTransitions() {
+	p.cbPendingMu.Lock()
+	pending := p.cbPending
+	p.cbPending = nil
+	p.cbPendingMu.Unlock()
+	for _, ev := range pending {
+		p.writeBreakerTransition(ev)
+	}
+}
+
+func (p *Server) writeBreakerTransition(ev nodeBreakerTransition) {
+	accountID := ""
+	if n := p.getNode(ev.nodeID); n != nil {
+		accountID = n.AccountID
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.store.InsertCircuitBreakerEvent(ctx, store.CircuitBreakerEventRecord{
+		NodeID:           ev.nodeID,
+		AccountID:        accountID,
+		InstanceID:       p.instanceID,
+		FromState:        ev.From.String(),
+		ToState:          ev.To.String(),
+		Reason:           ev.Reason,
+		ConsecutiveFails: ev.ConsecutiveFails,
+		FailureRate:      ev.FailureRate,
+		CreatedAt:        ev.At,
+	}); err != nil {
+		p.logger.Component("breaker").Warn("record transition failed", logging.KeyNodeID, ev.nodeID, logging.KeyError, err)
+	}
+	if err := p.store.SaveCircuitBreakerState(ctx, store.CircuitBreakerRecord{
+		NodeID:         ev.nodeID,
+		AccountID:      accountID,
+		State:          ev.To.String(),
+		StateChangedAt: ev.At,
+		Forced:         ev.Forced,
+	}); err != nil {
+		p.logger.Component("breaker").Warn("persist state failed", logging.KeyNodeID, ev.nodeID, logging.KeyError, err)
+	}
+}
+
+// restoreCircuitBreakers 启动时加载节点覆盖与持久化状态，避免重启后立即向刚熔断的节点放行全部流量。
+func (p *Server) restoreCircuitBreakers() {
+	if p.store == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	recs, err := p.store.ListCircuitBreakers(ctx)
+	if err != nil {
+		p.logger.Component("breaker").Warn("load persisted state failed", logging.KeyError, err)
+		return
+	}
+	restored := 0
+	for _, rec := range recs {
+		if p.getNode(rec.NodeID) == nil {
+			continue
+		}
+		o := CircuitBreakerOverride{
+			WindowSeconds:    rec.WindowSeconds,
+			FailureRate:      rec.FailureRate,
+			ConsecutiveFails: rec.ConsecutiveFails,
+			CooldownSeconds:  rec.CooldownSeconds,
+			HalfOpenMaxCalls: rec.HalfOpenMaxCalls,
+		}
+		if !o.IsZero() {
+			p.cbMu.Lock()
+			p.cbOverrides[rec.NodeID] = o
+			p.cbMu.Unlock()
+		}
+		if state := parseCircuitBreakerState(rec.State); state != StateClosed {
+			p.getOrCreateCircuitBreaker(rec.NodeID).Restore(state, rec.StateChangedAt, rec.Forced)
+			restored++
+		}
+	}
+	if restored > 0 {
+		p.logger.Component("breaker").Info("restored non-closed breakers from store", "count", restored)
+	}
+}
+
+// tripBreaker 手动熔断节点。
+func (p *Server) tripBreaker(nodeID string) {
+	p.getOrCreateCircuitBreaker(nodeID).Trip()
+}
+
+// resetBreaker 手动重置节点熔断器。
+func (p *Server) resetBreaker(nodeID string) {
+	p.getOrCreateCircuitBreaker(nodeID).ResetWithReason(CBReasonManualReset)
+}
+
+// setBreakerOverride 更新节点熔断参数覆盖（零值表示清除），立即作用于现有熔断器。
+func (p *Server) setBreakerOverride(nodeID string, o CircuitBreakerOverride) {
+	p.cbMu.Lock()
+	if o.IsZero() {
+		delete(p.cbOverrides, nodeID)
+	} else {
+		p.cbOverrides[nodeID] = o
+	}
+	cfg := o.Apply(p.cbConfig)
+	cb := p.circuitBreakers[nodeID]
+	p.cbMu.Unlock()
+	if cb != nil {
+		cb.SetConfig(cfg)
+	}
+}
+
+// removeCircuitBreaker 节点删除后清理内存中的熔断器与覆盖。
+func (p *Server) removeCircuitBreaker(nodeID string) {
+	p.cbMu.Lock()
+	delete(p.circuitBreakers, nodeID)
+	delete(p.cbOverrides, nodeID)
+	p.cbMu.Unlock()
+}
+
+// applyRemoteBreakerChange 应用其他实例的熔断器管理操作。
+func (p *Server) applyRemoteBreakerChange(nodeID string, ch clusterBreakerChanged) {
+	if p.getNode(nodeID) == nil {
+		return
+	}
+	switch ch.Action {
+	case cbActionTrip:
+		p.tripBreaker(nodeID)
+	case cbActionReset:
+		p.resetBreaker(nodeID)
+	case cbActionOverride:
+		p.setBreakerOverride(nodeID, ch.Override)
+	}
+}
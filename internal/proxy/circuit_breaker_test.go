@@ -1,8 +1,12 @@
 package proxy
 
 import (
+	"context"
+	"path/filepath"
 	"testing"
 	"time"
+
+	"qcc_plus/internal/store"
 )
 
 // TestCircuitBreakerStateMachine 测试熔断器状态机
@@ -348,3 +352,108 @@ func TestCircuitBreakerNilSafety(t *testing.T) {
 		t.Errorf("nil circuit breaker should return Closed state, got %s", cb.GetState())
 	}
 }
+
+// TestCircuitBreakerManualTripAndTransitions 测试手动熔断不随冷却恢复，且转换回调按顺序触发
+func TestCircuitBreakerManualTripAndTransitions(t *testing.T) {
+	cb := NewCircuitBreaker(CircuitBreakerConfig{Enabled: true, ConsecutiveFails: 2, CooldownSeconds: 1, HalfOpenMaxCalls: 1})
+	var got []CircuitBreakerTransition
+	cb.OnTransition(func(tr CircuitBreakerTransition) {
+		// 回调中读取状态不应死锁
+		_ = cb.GetState()
+		got = append(got, tr)
+	})
+
+	// 窗口内仅一次失败，失败率 100% 触发熔断
+	cb.RecordResult(false)
+	cb.Trip()
+	cb.mu.Lock()
+	cb.stateChangedAt = time.Now().Add(-time.Minute)
+	cb.mu.Unlock()
+	if cb.AllowRequest() {
+		t.Fatal("manually tripped breaker must reject after cooldown")
+	}
+	cb.ResetWithReason(CBReasonManualReset)
+	if !cb.AllowRequest() {
+		t.Fatal("expected requests allowed after manual reset")
+	}
+
+	want := []string{CBReasonFailureRate, CBReasonManualTrip, CBReasonManualReset}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d transitions, got %+v", len(want), got)
+	}
+	for i, reason := range want {
+		if got[i].Reason != reason {
+			t.Errorf("transition %d reason = %s, want %s", i, got[i].Reason, reason)
+		}
+	}
+	if !got[1].Forced || got[2].To != StateClosed {
+		t.Errorf("unexpected transitions: %+v", got)
+	}
+	if snap := cb.Snapshot(); snap.Opens != 1 || snap.Rejected != 1 || snap.TotalFailures != 1 {
+		t.Errorf("unexpected counters: %+v", snap)
+	}
+}
+
+// TestCircuitBreakerRestoreAndOverride 测试持久化状态恢复与节点参数覆盖
+func TestCircuitBreakerRestoreAndOverride(t *testing.T) {
+	global := CircuitBreakerConfig{Enabled: true, ConsecutiveFails: 5, CooldownSeconds: 30, HalfOpenMaxCalls: 3}
+	cfg := CircuitBreakerOverride{CooldownSeconds: 60, HalfOpenMaxCalls: 1}.Apply(global)
+	if cfg.CooldownSeconds != 60 || cfg.HalfOpenMaxCalls != 1 || cfg.ConsecutiveFails != 5 {
+		t.Fatalf("unexpected effective config: %+v", cfg)
+	}
+
+	// 刚熔断几秒：重启后仍拒绝请求
+	cb := NewCircuitBreaker(cfg)
+	cb.Restore(StateOpen, time.Now().Add(-5*time.Second), false)
+	if cb.AllowRequest() {
+		t.Fatal("restored open breaker must reject during cooldown")
+	}
+
+	// 半开：重启后仅放行试探请求
+	cb = NewCircuitBreaker(cfg)
+	cb.Restore(StateHalfOpen, time.Now(), false)
+	if !cb.AllowRequest() {
+		t.Fatal("restored half-open breaker should allow a probe")
+	}
+	if cb.AllowRequest() {
+		t.Fatal("restored half-open breaker should limit probes")
+	}
+}
+
+// TestBreakerTransitionQueueKeepsLatestState 测试写入队列满时仍持久化节点最新状态
+func TestBreakerTransitionQueueKeepsLatestState(t *testing.T) {
+	st, err := store.OpenSQLite(filepath.Join(t.TempDir(), "cb.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer st.Close()
+	srv := newClusterTestServer(t, NewLocalClusterBus(), "a")
+	srv.store = st
+	srv.cbEvents = make(chan nodeBreakerTransition, 1)
+	srv.cbWake = make(chan struct{}, 1)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	srv.onBreakerTransition("n1", CircuitBreakerTransition{From: StateClosed, To: StateOpen, At: now})
+	srv.onBreakerTransition("n1", CircuitBreakerTransition{From: StateOpen, To: StateHalfOpen, At: now.Add(time.Second)})
+	srv.onBreakerTransition("n1", CircuitBreakerTransition{From: StateHalfOpen, To: StateOpen, At: now.Add(2 * time.Second)})
+	go srv.runBreakerEventWriter()
+	defer close(srv.cbEvents)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		recs, err := st.ListCircuitBreakers(context.Background())
+		if err != nil {
+			t.Fatalf("list breakers: %v", err)
+		}
+		if len(recs) == 1 && recs[0].StateChangedAt.Equal(now.Add(2*time.Second)) {
+			if recs[0].State != StateOpen.String() {
+				t.Fatalf("expected latest state open, got %+v", recs[0])
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("latest transition not persisted, got %+v", recs)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
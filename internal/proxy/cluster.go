@@ -0,0 +1,455 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"qcc_plus/internal/logging"
+	"qcc_plus/internal/store"
+	"qcc_plus/internal/timeutil"
+)
+
+const (
+	ClusterBusLocal = "local"
+	ClusterBusDB    = "db"
+	ClusterBusOff   = "off"
+
+	defaultClusterPollInterval = time.Second
+	clusterPollBatch           = 500
+	// clusterGapTimeout 事件 ID 空洞的等待时长：自增 ID 的分配顺序与提交顺序不一致，
+	// 较小 ID 可能晚于较大 ID 可见，轮询会在该时长内重新读取空洞；回滚产生的空洞到期后放弃。
+	clusterGapTimeout = time.Minute
+	// clusterMaxGaps 单次跳跃超过该数量的 ID 不再逐个跟踪（如清理后或长时间停机）。
+	clusterMaxGaps = 1000
+	// clusterEventRetention 集群事件保留时长，由主实例的清理任务删除过期事件。
+	clusterEventRetention = 24 * time.Hour
+)
+
+// ClusterConfig 集群事件通道配置。
+type ClusterConfig struct {
+	Bus          string
+	PollInterval time.Duration
+}
+
+// loadClusterConfig 从环境变量读取集群事件通道配置。
+func loadClusterConfig(logger *logging.Logger) ClusterConfig {
+	cfg := ClusterConfig{
+		Bus:          strings.ToLower(strings.TrimSpace(GetEnvString("CLUSTER_BUS", ClusterBusDB))),
+		PollInterval: parseEnvDuration("CLUSTER_POLL_INTERVAL", defaultClusterPollInterval, logger),
+	}
+	switch cfg.Bus {
+	case ClusterBusDB, ClusterBusLocal, ClusterBusOff:
+	default:
+		if logger != nil {
+			logger.Warn("invalid environment variable, using default", "env", "CLUSTER_BUS", "value", cfg.Bus, "default", ClusterBusDB)
+		}
+		cfg.Bus = ClusterBusDB
+	}
+	if cfg.PollInterval < 200*time.Millisecond {
+		cfg.PollInterval = defaultClusterPollInterval
+	}
+	return cfg
+}
+
+// clusterSync 负责发布本实例的状态变更并应用其他实例的事件。
+// versions 记录每个实体最近一次发布/应用的版本，版本更旧的事件直接丢弃。
+type clusterSync struct {
+	bus        ClusterBus
+	instanceID string
+
+	mu       sync.Mutex
+	versions map[string]int64
+
+	published atomic.Int64
+	applied   atomic.Int64
+	stale     atomic.Int64
+	failed    atomic.Int64
+}
+
+func newClusterSync(bus ClusterBus, instanceID string) *clusterSync {
+	return &clusterSync{
+		bus:        bus,
+		instanceID: instanceID,
+		versions:   make(map[string]int64),
+	}
+}
+
+// advance 在 version 新于实体当前版本时记录并返回 true。
+func (c *clusterSync) advance(key string, version int64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if version <= c.versions[key] {
+		return false
+	}
+	c.versions[key] = version
+	return true
+}
+
+// clusterEntityKey 返回事件对应的版本跟踪键；同一实体的事件按版本先后生效。
+func clusterEntityKey(ev ClusterEvent) string {
+	switch ev.Type {
+	case ClusterEventNodeState, ClusterEventNodeConfig, ClusterEventBreaker, ClusterEventNodeCost:
+		return ev.Type + ":" + ev.NodeID
+	case ClusterEventNodeActive, ClusterEventAccountConfig:
+		return ev.Type + ":" + ev.AccountID
+	default:
+		return ev.Type
+	}
+}
+
+type clusterNodeState struct {
+	Failed    bool   `json:"failed"`
+	Disabled  bool   `json:"disabled"`
+	LastError string `json:"last_error,omitempty"`
+}
+
+type clusterActiveNode struct {
+	ActiveID string `json:"active_id"`
+}
+
+type clusterNodeConfig struct {
+	Deleted           bool      `json:"deleted,omitempty"`
+	Name              string    `json:"name,omitempty"`
+	BaseURL           string    `json:"base_url,omitempty"`
+	Weight            int       `json:"weight,omitempty"`
+	HealthCheckMethod string    `json:"health_check_method,omitempty"`
+	HealthCheckModel  string    `json:"health_check_model,omitempty"`
+	CreatedAt         time.Time `json:"created_at,omitempty"`
+}
+
+type clusterAccountConfig struct {
+	Retries        int `json:"retries"`
+	FailLimit      int `json:"fail_limit"`
+	HealthEverySec int `json:"health_every_sec"`
+}
+
+type clusterSettingsChanged struct {
+	Keys []string `json:"keys,omitempty"`
+}
+
+// publishCluster 广播本实例的状态变更；未启用集群通道时为空操作。
+func (p *Server) publishCluster(eventType, accountID, nodeID string, payload any) {
+	if p == nil || p.cluster == nil {
+		return
+	}
+	c := p.cluster
+	ev := &ClusterEvent{
+		InstanceID: c.instanceID,
+		Type:       eventType,
+		AccountID:  accountID,
+		NodeID:     nodeID,
+		OccurredAt: time.Now().UTC(),
+	}
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			p.logger.Component("cluster").Warn("marshal event payload failed", "type", eventType, logging.KeyError, err)
+			return
+		}
+		ev.Payload = data
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := c.bus.Publish(ctx, ev); err != nil {
+		c.failed.Add(1)
+		p.logger.Component("cluster").Warn("publish event failed", "type", eventType, logging.KeyAccountID, accountID, logging.KeyNodeID, nodeID, logging.KeyError, err)
+		return
+	}
+	c.published.Add(1)
+	// 本实例的变更同样推进版本，避免随后轮询到的旧事件覆盖它。
+	c.advance(clusterEntityKey(*ev), ev.Version)
+}
+
+// publishNodeState 广播节点当前的故障/禁用状态。
+func (p *Server) publishNodeState(nodeID string) {
+	if p == nil || p.cluster == nil {
+		return
+	}
+	p.mu.RLock()
+	n := p.nodeIndex[nodeID]
+	if n == nil {
+		p.mu.RUnlock()
+		return
+	}
+	accID := n.AccountID
+	state := clusterNodeState{Failed: n.Failed, Disabled: n.Disabled, LastError: n.LastError}
+	p.mu.RUnlock()
+	p.publishCluster(ClusterEventNodeState, accID, nodeID, state)
+}
+
+// publishActiveNode 广播账号当前的活跃节点。
+func (p *Server) publishActiveNode(acc *Account) {
+	if p == nil || p.cluster == nil || acc == nil {
+		return
+	}
+	p.mu.RLock()
+	activeID := acc.ActiveID
+	p.mu.RUnlock()
+	p.publishCluster(ClusterEventNodeActive, acc.ID, activeID, clusterActiveNode{ActiveID: activeID})
+}
+
+// publishNodeConfig 广播节点新增/更新；API Key 不进入事件，接收方从数据库读取。
+func (p *Server) publishNodeConfig(nodeID string) {
+	if p == nil || p.cluster == nil {
+		return
+	}
+	p.mu.RLock()
+	n := p.nodeIndex[nodeID]
+	if n == nil {
+		p.mu.RUnlock()
+		return
+	}
+	accID := n.AccountID
+	cfg := clusterNodeConfig{
+		Name:              n.Name,
+		Weight:            n.Weight,
+		HealthCheckMethod: n.HealthCheckMethod,
+		HealthCheckModel:  n.HealthCheckModel,
+		CreatedAt:         n.CreatedAt,
+	}
+	if n.URL != nil {
+		cfg.BaseURL = n.URL.String()
+	}
+	p.mu.RUnlock()
+	p.publishCluster(ClusterEventNodeConfig, accID, nodeID, cfg)
+}
+
+// publishSettingsChanged 广播设置变更，其他实例收到后立即刷新设置缓存。
+func (p *Server) publishSettingsChanged(keys ...string) {
+	p.publishCluster(ClusterEventSettings, "", "", clusterSettingsChanged{Keys: keys})
+}
+
+// publishPricingChanged 广播定价变更，其他实例收到后丢弃定价快照；本实例与其他实例都重新计算节点成本系数。
+func (p *Server) publishPricingChanged() {
+	p.refreshNodeCostFactors()
+	p.publishCluster(ClusterEventPricing, "", "", nil)
+}
+
+// applyClusterEvent 应用其他实例的事件：忽略本实例事件与过期版本，只修改内存状态（发布方已持久化）。
+func (p *Server) applyClusterEvent(ev ClusterEvent) {
+	c := p.cluster
+	if c == nil || ev.InstanceID == c.instanceID {
+		return
+	}
+	if !c.advance(clusterEntityKey(ev), ev.Version) {
+		c.stale.Add(1)
+		return
+	}
+	var err error
+	switch ev.Type {
+	case ClusterEventNodeState:
+		var st clusterNodeState
+		if err = json.Unmarshal(ev.Payload, &st); err == nil {
+			p.applyRemoteNodeState(ev.NodeID, st)
+		}
+	case ClusterEventNodeActive:
+		var st clusterActiveNode
+		if err = json.Unmarshal(ev.Payload, &st); err == nil {
+			p.applyRemoteActiveNode(ev.AccountID, st.ActiveID)
+		}
+	case ClusterEventNodeConfig:
+		var cfg clusterNodeConfig
+		if err = json.Unmarshal(ev.Payload, &cfg); err == nil {
+			p.applyRemoteNodeConfig(ev.AccountID, ev.NodeID, cfg)
+		}
+	case ClusterEventAccountConfig:
+		var cfg clusterAccountConfig
+		if err = json.Unmarshal(ev.Payload, &cfg); err == nil {
+			if acc := p.getAccountByID(ev.AccountID); acc != nil {
+				p.setAccountConfig(acc, Config{Retries: cfg.Retries, FailLimit: cfg.FailLimit, HealthEvery: time.Duration(cfg.HealthEverySec) * time.Second})
+			}
+		}
+	case ClusterEventBreaker:
+		var ch clusterBreakerChanged
+		if err = json.Unmarshal(ev.Payload, &ch); err == nil {
+			p.applyRemoteBreakerChange(ev.NodeID, ch)
+		}
+	case ClusterEventNodeCost:
+		var rec store.NodeCostRecord
+		if err = json.Unmarshal(ev.Payload, &rec); err == nil && p.getNode(ev.NodeID) != nil {
+			rec.NodeID = ev.NodeID
+			p.setNodeCost(rec)
+		}
+	case ClusterEventSettings:
+		if p.settingsCache != nil {
+			p.settingsCache.Refresh()
+		}
+	case ClusterEventPricing:
+		if p.store != nil {
+			p.store.InvalidatePricingCache()
+		}
+		p.refreshNodeCostFactors()
+	default:
+		return
+	}
+	if err != nil {
+		p.logger.Component("cluster").Warn("invalid cluster event", "type", ev.Type, "version", ev.Version, "instance_id", ev.InstanceID, logging.KeyError, err)
+		return
+	}
+	c.applied.Add(1)
+}
+
+func (p *Server) applyRemoteNodeState(nodeID string, st clusterNodeState) {
+	p.mu.Lock()
+	n := p.nodeIndex[nodeID]
+	if n == nil {
+		p.mu.Unlock()
+		return
+	}
+	acc := p.nodeAccount[nodeID]
+	changed := n.Failed != st.Failed || n.Disabled != st.Disabled
+	n.Failed = st.Failed
+	n.Disabled = st.Disabled
+	n.LastError = st.LastError
+	if acc != nil {
+		if st.Failed {
+			acc.FailedSet[nodeID] = struct{}{}
+		} else {
+			delete(acc.FailedSet, nodeID)
+		}
+	}
+	if !st.Failed {
+		n.Metrics.FailStreak = 0
+	}
+	name := n.Name
+	status := p.resolveNodeStatus(n)
+	p.mu.Unlock()
+
+	// 手动熔断经 ClusterEventBreaker 同步，不随节点恢复/启用事件解除。
+	if !st.Failed && p.cbConfig.Enabled {
+		if cb := p.getCircuitBreaker(nodeID); cb != nil {
+			cb.ResetUnlessForced(CBReasonReset)
+		}
+	}
+	if changed && p.wsHub != nil && acc != nil {
+		p.wsHub.Broadcast(acc.ID, "node_status", map[string]interface{}{
+			"node_id":   nodeID,
+			"node_name": name,
+			"status":    status,
+			"error":     st.LastError,
+			"timestamp": timeutil.FormatBeijingTime(time.Now()),
+		})
+	}
+}
+
+func (p *Server) applyRemoteActiveNode(accountID, activeID string) {
+	acc := p.getAccountByID(accountID)
+	if acc == nil {
+		return
+	}
+	p.mu.Lock()
+	if activeID != "" {
+		if _, ok := acc.Nodes[activeID]; !ok {
+			p.mu.Unlock()
+			return
+		}
+	}
+	prevID := acc.ActiveID
+	acc.ActiveID = activeID
+	n := acc.Nodes[activeID]
+	p.mu.Unlock()
+
+	if p.wsHub != nil && n != nil && prevID != activeID {
+		p.wsHub.Broadcast(acc.ID, "node_status", map[string]interface{}{
+			"node_id":   n.ID,
+			"node_name": n.Name,
+			"status":    p.resolveNodeStatus(n),
+			"active":    true,
+			"timestamp": timeutil.FormatBeijingTime(time.Now()),
+		})
+	}
+}
+
+func (p *Server) applyRemoteNodeConfig(accountID, nodeID string, cfg clusterNodeConfig) {
+	if cfg.Deleted {
+		p.mu.Lock()
+		if acc := p.nodeAccount[nodeID]; acc != nil {
+			delete(acc.Nodes, nodeID)
+			delete(acc.FailedSet, nodeID)
+			if acc.ActiveID == nodeID {
+				acc.ActiveID = ""
+			}
+		}
+		delete(p.nodeIndex, nodeID)
+		delete(p.nodeAccount, nodeID)
+		p.mu.Unlock()
+		p.removeCircuitBreaker(nodeID)
+		p.prom.removeNode(nodeID)
+		return
+	}
+
+	acc := p.getAccountByID(accountID)
+	if acc == nil {
+		return
+	}
+	u, err := url.Parse(cfg.BaseURL)
+	if err != nil {
+		return
+	}
+	apiKey := p.loadNodeAPIKey(accountID, nodeID)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := p.nodeIndex[nodeID]
+	if n == nil {
+		n = &Node{ID: nodeID, AccountID: accountID, CreatedAt: cfg.CreatedAt}
+		acc.Nodes[nodeID] = n
+		p.nodeIndex[nodeID] = n
+		p.nodeAccount[nodeID] = acc
+	}
+	n.Name = cfg.Name
+	n.URL = u
+	n.Weight = cfg.Weight
+	n.HealthCheckMethod = normalizeHealthCheckMethod(cfg.HealthCheckMethod)
+	n.HealthCheckModel = chooseNonEmpty(cfg.HealthCheckModel, defaultHealthCheckModel)
+	if apiKey != nil {
+		n.APIKey = *apiKey
+	}
+}
+
+// loadNodeAPIKey 从数据库读取节点 API Key；读取失败返回 nil 以保留内存中的旧值。
+func (p *Server) loadNodeAPIKey(accountID, nodeID string) *string {
+	if p.store == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	recs, err := p.store.GetNodesByAccount(ctx, accountID)
+	if err != nil {
+		return nil
+	}
+	for _, r := range recs {
+		if r.ID == nodeID {
+			key := r.APIKey
+			return &key
+		}
+	}
+	return nil
+}
+
+// ClusterStatus 返回集群事件通道状态（供管理 API 使用）。
+func (p *Server) ClusterStatus() map[string]interface{} {
+	status := map[string]interface{}{
+		"enabled": p.cluster != nil,
+		"bus":     ClusterBusOff,
+	}
+	c := p.cluster
+	if c == nil {
+		return status
+	}
+	status["bus"] = c.bus.Name()
+	status["instance_id"] = c.instanceID
+	status["published"] = c.published.Load()
+	status["applied"] = c.applied.Load()
+	status["stale_dropped"] = c.stale.Load()
+	status["publish_failed"] = c.failed.Load()
+	if db, ok := c.bus.(*DBClusterBus); ok {
+		status["cursor"] = db.Cursor()
+	}
+	return status
+}
@@ -0,0 +1,259 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"qcc_plus/internal/logging"
+	"qcc_plus/internal/store"
+)
+
+// 集群事件类型。
+const (
+	ClusterEventNodeState     = "node.state"      // 节点故障/恢复/禁用/启用
+	ClusterEventNodeActive    = "node.active"     // 账号活跃节点切换
+	ClusterEventNodeConfig    = "node.config"     // 节点新增/更新/删除
+	ClusterEventAccountConfig = "account.config"  // 账号重试/失败阈值/探活间隔
+	ClusterEventBreaker       = "breaker.changed" // 熔断器手动熔断/重置/参数覆盖
+	ClusterEventNodeCost      = "node.cost"       // 节点成本倍率/价格覆盖
+	ClusterEventSettings      = "settings.changed"
+	ClusterEventPricing       = "pricing.changed" // 模型定价/价格历史/定价规则
+)
+
+// ClusterEvent 在实例间广播的状态变更。
+// Version 由总线分配且全局单调递增，接收方据此丢弃过期事件。
+type ClusterEvent struct {
+	Version    int64           `json:"version"`
+	InstanceID string          `json:"instance_id"`
+	Type       string          `json:"type"`
+	AccountID  string          `json:"account_id,omitempty"`
+	NodeID     string          `json:"node_id,omitempty"`
+	Payload    json.RawMessage `json:"payload,omitempty"`
+	OccurredAt time.Time       `json:"occurred_at"`
+}
+
+// ClusterBus 集群事件通道，可替换为外部 pubsub 实现。
+type ClusterBus interface {
+	// Name 返回实现名称（local/db）。
+	Name() string
+	// Publish 发布事件并回填 Version。
+	Publish(ctx context.Context, ev *ClusterEvent) error
+	// Subscribe 注册事件处理函数；包括本实例发布的事件，由订阅方自行过滤。
+	Subscribe(fn func(ClusterEvent))
+	Start() error
+	Stop()
+}
+
+// LocalClusterBus 进程内实现：同步分发给订阅者，适用于单实例或测试。
+type LocalClusterBus struct {
+	version atomic.Int64
+	mu      sync.RWMutex
+	subs    []func(ClusterEvent)
+}
+
+// NewLocalClusterBus 创建进程内集群总线。
+func NewLocalClusterBus() *LocalClusterBus {
+	return &LocalClusterBus{}
+}
+
+func (b *LocalClusterBus) Name() string { return ClusterBusLocal }
+
+func (b *LocalClusterBus) Publish(_ context.Context, ev *ClusterEvent) error {
+	ev.Version = b.version.Add(1)
+	if ev.OccurredAt.IsZero() {
+		ev.OccurredAt = time.Now().UTC()
+	}
+	b.mu.RLock()
+	subs := append([]func(ClusterEvent){}, b.subs...)
+	b.mu.RUnlock()
+	for _, fn := range subs {
+		fn(*ev)
+	}
+	return nil
+}
+
+func (b *LocalClusterBus) Subscribe(fn func(ClusterEvent)) {
+	b.mu.Lock()
+	b.subs = append(b.subs, fn)
+	b.mu.Unlock()
+}
+
+func (b *LocalClusterBus) Start() error { return nil }
+
+func (b *LocalClusterBus) Stop() {}
+
+// DBClusterBus 基于 cluster_events 表的实现：发布即写表，后台按自增 ID 轮询新事件。
+type DBClusterBus struct {
+	store    *store.Store
+	logger   *logging.Logger
+	interval time.Duration
+
+	cursor atomic.Int64
+	gaps   map[int64]time.Time // 小于 cursor 但尚未读到的事件 ID 及发现时间，仅轮询协程访问
+	mu     sync.RWMutex
+	subs   []func(ClusterEvent)
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewDBClusterBus 创建数据库轮询总线。
+func NewDBClusterBus(s *store.Store, interval time.Duration, logger *logging.Logger) *DBClusterBus {
+	if logger == nil {
+		logger = logging.Default()
+	}
+	if interval <= 0 {
+		interval = defaultClusterPollInterval
+	}
+	return &DBClusterBus{
+		store:    s,
+		logger:   logger.Component("cluster"),
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+func (b *DBClusterBus) Name() string { return ClusterBusDB }
+
+func (b *DBClusterBus) Publish(ctx context.Context, ev *ClusterEvent) error {
+	if ev.OccurredAt.IsZero() {
+		ev.OccurredAt = time.Now().UTC()
+	}
+	rec := store.ClusterEventRecord{
+		InstanceID: ev.InstanceID,
+		AccountID:  ev.AccountID,
+		NodeID:     ev.NodeID,
+		EventType:  ev.Type,
+		Payload:    string(ev.Payload),
+		CreatedAt:  ev.OccurredAt,
+	}
+	if err := b.store.AppendClusterEvent(ctx, &rec); err != nil {
+		return err
+	}
+	ev.Version = rec.ID
+	return nil
+}
+
+func (b *DBClusterBus) Subscribe(fn func(ClusterEvent)) {
+	b.mu.Lock()
+	b.subs = append(b.subs, fn)
+	b.mu.Unlock()
+}
+
+// Start 从当前最大事件 ID 开始轮询；启动时的状态已从数据库加载，无需回放历史事件。
+func (b *DBClusterBus) Start() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	latest, err := b.store.LatestClusterEventID(ctx)
+	cancel()
+	if err != nil {
+		return err
+	}
+	b.cursor.Store(latest)
+	b.wg.Add(1)
+	go b.loop()
+	return nil
+}
+
+func (b *DBClusterBus) Stop() {
+	b.stopOnce.Do(func() {
+		close(b.stopCh)
+	})
+	b.wg.Wait()
+}
+
+// Cursor 返回已分发的最大事件 ID。
+func (b *DBClusterBus) Cursor() int64 {
+	return b.cursor.Load()
+}
+
+func (b *DBClusterBus) loop() {
+	defer b.wg.Done()
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.stopCh:
+			return
+		case <-ticker.C:
+			b.poll()
+		}
+	}
+}
+
+// poll 读取 cursor 之后的新事件，并重新读取尚未过期的 ID 空洞，
+// 避免晚提交的较小 ID 被跳过；已分发的事件按 ID 去重。
+func (b *DBClusterBus) poll() {
+	now := time.Now()
+	after := b.cursor.Load()
+	for id, seen := range b.gaps {
+		if now.Sub(seen) > clusterGapTimeout {
+			delete(b.gaps, id)
+			continue
+		}
+		if id-1 < after {
+			after = id - 1
+		}
+	}
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		recs, err := b.store.ListClusterEventsAfter(ctx, after, clusterPollBatch)
+		cancel()
+		if err != nil {
+			b.logger.Warn("poll events failed", logging.KeyError, err)
+			return
+		}
+		if len(recs) == 0 {
+			return
+		}
+		b.mu.RLock()
+		subs := append([]func(ClusterEvent){}, b.subs...)
+		b.mu.RUnlock()
+		for _, rec := range recs {
+			after = rec.ID
+			cursor := b.cursor.Load()
+			if rec.ID <= cursor {
+				if _, ok := b.gaps[rec.ID]; !ok {
+					continue
+				}
+				delete(b.gaps, rec.ID)
+			} else {
+				b.trackGaps(cursor, rec.ID, now)
+				b.cursor.Store(rec.ID)
+			}
+			ev := ClusterEvent{
+				Version:    rec.ID,
+				InstanceID: rec.InstanceID,
+				Type:       rec.EventType,
+				AccountID:  rec.AccountID,
+				NodeID:     rec.NodeID,
+				OccurredAt: rec.CreatedAt,
+			}
+			if rec.Payload != "" {
+				ev.Payload = json.RawMessage(rec.Payload)
+			}
+			for _, fn := range subs {
+				fn(ev)
+			}
+		}
+		if len(recs) < clusterPollBatch {
+			return
+		}
+	}
+}
+
+// trackGaps 记录 cursor 与 id 之间未读到的事件 ID。
+func (b *DBClusterBus) trackGaps(cursor, id int64, now time.Time) {
+	if id-cursor-1 > clusterMaxGaps {
+		return
+	}
+	for missing := cursor + 1; missing < id; missing++ {
+		if b.gaps == nil {
+			b.gaps = make(map[int64]time.Time)
+		}
+		b.gaps[missing] = now
+	}
+}
@@ -0,0 +1,189 @@
+package proxy
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"qcc_plus/internal/logging"
+	"qcc_plus/internal/store"
+)
+
+func newClusterTestServer(t *testing.T, bus ClusterBus, instanceID string) *Server {
+	t.Helper()
+	u, _ := url.Parse("http://upstream.example")
+	acc := &Account{
+		ID:        "acc-1",
+		Name:      "acc",
+		Nodes:     make(map[string]*Node),
+		FailedSet: make(map[string]struct{}),
+	}
+	for i, id := range []string{"n1", "n2"} {
+		acc.Nodes[id] = &Node{ID: id, Name: id, URL: u, AccountID: acc.ID, Weight: i + 1, CreatedAt: time.Now()}
+	}
+	acc.ActiveID = "n1"
+	srv := &Server{
+		accounts:        make(map[string]*Account),
+		accountByID:     make(map[string]*Account),
+		nodeIndex:       make(map[string]*Node),
+		nodeAccount:     make(map[string]*Account),
+		circuitBreakers: make(map[string]*CircuitBreaker),
+		logger:          logging.New(logging.Config{Output: testWriter{t}}),
+		cluster:         newClusterSync(bus, instanceID),
+	}
+	srv.registerAccount(acc)
+	bus.Subscribe(srv.applyClusterEvent)
+	return srv
+}
+
+type testWriter struct{ t *testing.T }
+
+func (w testWriter) Write(b []byte) (int, error) {
+	w.t.Log(string(b))
+	return len(b), nil
+}
+
+// TestClusterPropagatesNodeState 测试节点故障与活跃节点切换在实例间同步
+func TestClusterPropagatesNodeState(t *testing.T) {
+	bus := NewLocalClusterBus()
+	a := newClusterTestServer(t, bus, "a")
+	b := newClusterTestServer(t, bus, "b")
+
+	a.handleFailure("n1", "status 502")
+
+	bn := b.getNode("n1")
+	if !bn.Failed || bn.LastError != "status 502" || !b.isInFailedSet(b.TestAccount("acc-1"), "n1") {
+		t.Fatalf("expected n1 failed on instance b, got failed=%v err=%q", bn.Failed, bn.LastError)
+	}
+	if got := b.TestAccount("acc-1").ActiveID; got != "n2" {
+		t.Fatalf("expected active node n2 on instance b, got %s", got)
+	}
+
+	if err := a.enableNode("n1"); err != nil {
+		t.Fatalf("enable node: %v", err)
+	}
+	if bn.Failed || b.isInFailedSet(b.TestAccount("acc-1"), "n1") {
+		t.Fatalf("expected n1 recovered on instance b")
+	}
+	if got := b.TestAccount("acc-1").ActiveID; got != "n1" {
+		t.Fatalf("expected active node n1 on instance b, got %s", got)
+	}
+}
+
+// TestClusterIgnoresStaleEvents 测试旧版本事件不会覆盖新状态，且忽略本实例事件
+func TestClusterIgnoresStaleEvents(t *testing.T) {
+	bus := NewLocalClusterBus()
+	srv := newClusterTestServer(t, bus, "self")
+
+	state := func(failed bool) json.RawMessage {
+		data, _ := json.Marshal(clusterNodeState{Failed: failed})
+		return data
+	}
+	srv.applyClusterEvent(ClusterEvent{Version: 5, InstanceID: "other", Type: ClusterEventNodeState, AccountID: "acc-1", NodeID: "n2", Payload: state(true)})
+	if !srv.getNode("n2").Failed {
+		t.Fatalf("expected n2 failed after v5")
+	}
+
+	srv.applyClusterEvent(ClusterEvent{Version: 3, InstanceID: "other", Type: ClusterEventNodeState, AccountID: "acc-1", NodeID: "n2", Payload: state(false)})
+	if !srv.getNode("n2").Failed {
+		t.Fatalf("stale v3 event must not override v5")
+	}
+	if got := srv.cluster.stale.Load(); got != 1 {
+		t.Fatalf("expected 1 stale event, got %d", got)
+	}
+
+	srv.applyClusterEvent(ClusterEvent{Version: 9, InstanceID: "self", Type: ClusterEventNodeState, AccountID: "acc-1", NodeID: "n2", Payload: state(false)})
+	if !srv.getNode("n2").Failed {
+		t.Fatalf("own events must be ignored")
+	}
+
+	srv.applyClusterEvent(ClusterEvent{Version: 6, InstanceID: "other", Type: ClusterEventNodeState, AccountID: "acc-1", NodeID: "n2", Payload: state(false)})
+	if srv.getNode("n2").Failed {
+		t.Fatalf("expected n2 recovered after v6")
+	}
+}
+
+// TestDBClusterBusReadsLateCommits 测试晚提交的较小事件 ID 不会被跳过，且事件不重复分发
+func TestDBClusterBusReadsLateCommits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bus.db")
+	st, err := store.OpenSQLite(path)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer st.Close()
+	raw, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("open raw db: %v", err)
+	}
+	defer raw.Close()
+
+	bus := NewDBClusterBus(st, time.Hour, nil)
+	if err := bus.Start(); err != nil {
+		t.Fatalf("start bus: %v", err)
+	}
+	defer bus.Stop()
+	var got []int64
+	bus.Subscribe(func(ev ClusterEvent) { got = append(got, ev.Version) })
+
+	if err := bus.Publish(context.Background(), &ClusterEvent{InstanceID: "b", Type: ClusterEventPricing}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	// 模拟另一实例先提交了 ID 3，ID 2 所在事务尚未提交
+	insert := func(id int64) {
+		t.Helper()
+		if _, err := raw.Exec(`INSERT INTO cluster_events (id, instance_id, event_type, node_id, created_at) VALUES (?, 'c', ?, 'n2', ?)`,
+			id, ClusterEventPricing, time.Now().UTC()); err != nil {
+			t.Fatalf("insert event %d: %v", id, err)
+		}
+	}
+	insert(3)
+	bus.poll()
+	insert(2)
+	bus.poll()
+	bus.poll()
+
+	want := []int64{1, 3, 2}
+	if len(got) != len(want) {
+		t.Fatalf("expected events %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected events %v, got %v", want, got)
+		}
+	}
+	if bus.Cursor() != 3 || len(bus.gaps) != 0 {
+		t.Fatalf("unexpected cursor %d gaps %v", bus.Cursor(), bus.gaps)
+	}
+}
+
+// TestRemoteHealthyStateKeepsManualTrip 测试手动熔断同步到其他实例后，不会被远端节点恢复事件解除
+func TestRemoteHealthyStateKeepsManualTrip(t *testing.T) {
+	bus := NewLocalClusterBus()
+	a := newClusterTestServer(t, bus, "a")
+	b := newClusterTestServer(t, bus, "b")
+	for _, srv := range []*Server{a, b} {
+		srv.cbConfig = loadCircuitBreakerConfig()
+		srv.cbConfig.Enabled = true
+	}
+
+	a.tripBreaker("n1")
+	a.publishCluster(ClusterEventBreaker, "acc-1", "n1", clusterBreakerChanged{Action: cbActionTrip})
+	if cb := b.getCircuitBreaker("n1"); cb == nil || cb.GetState() != StateOpen {
+		t.Fatalf("expected manual trip propagated to instance b")
+	}
+
+	a.publishCluster(ClusterEventNodeState, "acc-1", "n1", clusterNodeState{})
+	if cb := b.getCircuitBreaker("n1"); cb.GetState() != StateOpen || !cb.Snapshot().Forced {
+		t.Fatalf("expected manual trip kept after remote healthy state, got %v", cb.GetState())
+	}
+
+	a.resetBreaker("n1")
+	a.publishCluster(ClusterEventBreaker, "acc-1", "n1", clusterBreakerChanged{Action: cbActionReset})
+	if cb := b.getCircuitBreaker("n1"); cb.GetState() != StateClosed {
+		t.Fatalf("expected manual reset propagated, got %v", cb.GetState())
+	}
+}
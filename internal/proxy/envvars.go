@@ -21,6 +21,7 @@ const (
 	EnvCategoryMetrics     EnvVarCategory = "metrics"     // 指标调度
 	EnvCategoryMySQL       EnvVarCategory = "mysql"       // MySQL 持久化
 	EnvCategoryTunnel      EnvVarCategory = "tunnel"      // Cloudflare Tunnel
+	EnvCategoryCluster     EnvVarCategory = "cluster"     // 多实例部署
 )
 
 // EnvVarDefinition 环境变量定义
@@ -55,6 +56,7 @@ func GetEnvVarCategories() []EnvVarCategoryInfo {
 		{Key: EnvCategoryMetrics, Label: "指标调度", Description: "监控指标聚合和清理配置"},
 		{Key: EnvCategoryMySQL, Label: "MySQL 持久化", Description: "MySQL 数据库连接和连接池配置"},
 		{Key: EnvCategoryTunnel, Label: "Cloudflare Tunnel", Description: "内网穿透隧道配置"},
+		{Key: EnvCategoryCluster, Label: "多实例部署", Description: "多个实例共享同一数据库时的选主与同步配置"},
 	}
 }
 
@@ -67,6 +69,8 @@ func GetAllEnvVarDefinitions() []EnvVarDefinition {
 		{Name: "UPSTREAM_API_KEY", Category: EnvCategoryBasic, DefaultValue: "", Description: "默认上游 API Key（可选）", IsSecret: true},
 		{Name: "UPSTREAM_NAME", Category: EnvCategoryBasic, DefaultValue: "default", Description: "默认节点名称"},
 		{Name: "PROXY_HEALTH_CHECK_MODE", Category: EnvCategoryBasic, DefaultValue: "cli", Description: "全局健康检查方式（cli/api/head）"},
+		{Name: "LOG_FORMAT", Category: EnvCategoryBasic, DefaultValue: "logfmt", Description: "日志格式（logfmt/json）"},
+		{Name: "LOG_LEVEL", Category: EnvCategoryBasic, DefaultValue: "info", Description: "默认日志级别（debug/info/warn/error），设置项 logging.levels 可按组件覆盖"},
 
 		// ========== 多租户与安全 ==========
 		{Name: "ADMIN_API_KEY", Category: EnvCategoryMultiTenant, DefaultValue: "admin", Description: "管理员访问密钥（⚠️ 生产必改）", IsSecret: true},
@@ -92,6 +96,7 @@ func GetAllEnvVarDefinitions() []EnvVarDefinition {
 		{Name: "HEALTH_ALL_INTERVAL_MIN", Category: EnvCategoryHealth, DefaultValue: "10", Description: "全量健康检查间隔（分钟，备选）"},
 		{Name: "HEALTH_CHECK_CONCURRENCY", Category: EnvCategoryHealth, DefaultValue: "2", Description: "全量健康检查并发数（HEAD/API，自动限制 1~4）"},
 		{Name: "HEALTH_CHECK_CONCURRENCY_CLI", Category: EnvCategoryHealth, DefaultValue: "1", Description: "CLI 健康检查并发数（建议 1~2）"},
+		{Name: "NODE_SELECTION_STRATEGY", Category: EnvCategoryHealth, DefaultValue: "priority", Description: "节点选择策略（priority=按权重，cost=优先实际成本更低的节点）"},
 
 		// ========== 预热配置 ==========
 		{Name: "WARMUP_ENABLED", Category: EnvCategoryWarmup, DefaultValue: "1", Description: "预热开关（1=启用，0=关闭）"},
@@ -108,6 +113,21 @@ func GetAllEnvVarDefinitions() []EnvVarDefinition {
 		{Name: "RETRY_BACKOFF_MIN_MS", Category: EnvCategoryRetry, DefaultValue: "10", Description: "最小退避时间（毫秒）"},
 		{Name: "RETRY_BACKOFF_MAX_MS", Category: EnvCategoryRetry, DefaultValue: "100", Description: "最大退避时间（毫秒）"},
 		{Name: "RETRY_ON_STATUS", Category: EnvCategoryRetry, DefaultValue: "502,503,504", Description: "需要重试的 HTTP 状态码"},
+		{Name: "RETRY_BUDGET_ENABLED", Category: EnvCategoryRetry, DefaultValue: "1", Description: "启用重试预算（令牌桶，按账号与全局限制重试量）"},
+		{Name: "RETRY_BUDGET_RATIO", Category: EnvCategoryRetry, DefaultValue: "0.2", Description: "每次成功请求存入的令牌数，即允许的重试/成功比例"},
+		{Name: "RETRY_BUDGET_MIN_PER_SEC", Category: EnvCategoryRetry, DefaultValue: "1", Description: "每秒保底补充的重试令牌数"},
+		{Name: "RETRY_BUDGET_MAX_TOKENS", Category: EnvCategoryRetry, DefaultValue: "50", Description: "单账号重试令牌桶容量"},
+		{Name: "RETRY_BUDGET_GLOBAL_MAX_TOKENS", Category: EnvCategoryRetry, DefaultValue: "500", Description: "全局重试令牌桶容量"},
+		{Name: "PROXY_TRACE_HEADER", Category: EnvCategoryRetry, DefaultValue: "false", Description: "所有响应返回 X-Proxy-Trace 节点尝试轨迹（否则仅在请求头 X-Proxy-Debug: trace 时返回）"},
+		{Name: "REQUEST_TRACE_BUFFER", Category: EnvCategoryRetry, DefaultValue: "1000", Description: "内存中保留的最近请求轨迹条数"},
+		{Name: "REQUEST_LOG_ENABLED", Category: EnvCategoryMetrics, DefaultValue: "true", Description: "记录请求审计日志（需持久化）"},
+		{Name: "REQUEST_LOG_QUEUE_SIZE", Category: EnvCategoryMetrics, DefaultValue: "4096", Description: "审计日志内存队列容量，写满后丢弃新记录"},
+		{Name: "REQUEST_LOG_BATCH_SIZE", Category: EnvCategoryMetrics, DefaultValue: "200", Description: "审计日志单次批量写入条数"},
+		{Name: "REQUEST_LOG_FLUSH_INTERVAL", Category: EnvCategoryMetrics, DefaultValue: "2s", Description: "审计日志最长写入间隔"},
+		{Name: "REQUEST_LOG_TRUSTED_PROXIES", Category: EnvCategoryMetrics, DefaultValue: "", Description: "可信反向代理 IP/CIDR（逗号分隔），仅采信其传入的 X-Forwarded-For；为空时记录直连地址"},
+		{Name: "REQUEST_LOG_RETENTION_DAYS", Category: EnvCategoryMetrics, DefaultValue: "7", Description: "审计日志默认保留天数，设置项 request_log.retention_days 优先"},
+		{Name: "CAPTURE_MAX_BODY_BYTES", Category: EnvCategoryMetrics, DefaultValue: "1048576", Description: "调试抓包单个请求/响应体保存上限（字节）"},
+		{Name: "CAPTURE_RETENTION", Category: EnvCategoryMetrics, DefaultValue: "24h", Description: "调试抓包保留时长"},
 
 		// ========== 传输层连接池 ==========
 		{Name: "PROXY_TRANSPORT_MAX_IDLE_CONNS", Category: EnvCategoryTransport, DefaultValue: "200", Description: "最大空闲连接数"},
@@ -132,6 +152,38 @@ func GetAllEnvVarDefinitions() []EnvVarDefinition {
 		{Name: "METRICS_SCHEDULER_ENABLED", Category: EnvCategoryMetrics, DefaultValue: "1", Description: "启用指标调度器（需持久化）"},
 		{Name: "METRICS_AGGREGATE_INTERVAL", Category: EnvCategoryMetrics, DefaultValue: "1h", Description: "指标聚合间隔"},
 		{Name: "METRICS_CLEANUP_INTERVAL", Category: EnvCategoryMetrics, DefaultValue: "24h", Description: "指标清理间隔"},
+		{Name: "ANOMALY_DETECTION_ENABLED", Category: EnvCategoryMetrics, DefaultValue: "true", Description: "启用流量异常检测（需持久化）"},
+		{Name: "ANOMALY_INTERVAL", Category: EnvCategoryMetrics, DefaultValue: "1m", Description: "异常检测窗口与检测间隔"},
+		{Name: "ANOMALY_ALPHA", Category: EnvCategoryMetrics, DefaultValue: "0.1", Description: "EWMA 基线平滑系数（0-1）"},
+		{Name: "ANOMALY_THRESHOLD", Category: EnvCategoryMetrics, DefaultValue: "4", Description: "超过基线多少倍标准差视为异常"},
+		{Name: "ANOMALY_WARMUP", Category: EnvCategoryMetrics, DefaultValue: "30", Description: "基线预热窗口数，达到前不告警"},
+		{Name: "ANOMALY_MIN_REQUESTS", Category: EnvCategoryMetrics, DefaultValue: "20", Description: "错误率检测要求的窗口最少请求数"},
+		{Name: "ANOMALY_DRIFT_RATIO", Category: EnvCategoryMetrics, DefaultValue: "3", Description: "账号短期均值超过长期基线多少倍视为持续上升"},
+		{Name: "ANOMALY_LONG_ALPHA", Category: EnvCategoryMetrics, DefaultValue: "0.005", Description: "长期基线平滑系数，需小于 ANOMALY_ALPHA"},
+		{Name: "ANOMALY_DASHBOARD_URL", Category: EnvCategoryMetrics, DefaultValue: "", Description: "告警通知中管理后台链接的地址前缀（如 https://proxy.example.com）"},
+		{Name: "STATEMENT_ENABLED", Category: EnvCategoryMetrics, DefaultValue: "true", Description: "月末自动生成月度账单（需持久化）"},
+		{Name: "STATEMENT_TIMEZONE", Category: EnvCategoryMetrics, DefaultValue: "Asia/Shanghai", Description: "账期边界所在时区（IANA 名称，如 UTC、America/New_York）"},
+		{Name: "STATEMENT_DELAY", Category: EnvCategoryMetrics, DefaultValue: "1h", Description: "月末后延迟多久生成账单"},
+		{Name: "STATEMENT_DASHBOARD_URL", Category: EnvCategoryMetrics, DefaultValue: "", Description: "账单通知中链接的地址前缀（如 https://proxy.example.com）"},
+		{Name: "METRICS_ENABLED", Category: EnvCategoryMetrics, DefaultValue: "false", Description: "开启 Prometheus /metrics 端点（默认关闭，建议同时设置 METRICS_TOKEN）"},
+		{Name: "METRICS_TOKEN", Category: EnvCategoryMetrics, DefaultValue: "", Description: "/metrics 访问令牌（Bearer），为空时不鉴权", IsSecret: true},
+		{Name: "METRICS_SINK_STATSD_ADDR", Category: EnvCategoryMetrics, DefaultValue: "", Description: "StatsD UDP 地址（如 127.0.0.1:8125），设置后推送请求与探活指标"},
+		{Name: "METRICS_SINK_STATSD_TAGS", Category: EnvCategoryMetrics, DefaultValue: "dogstatsd", Description: "StatsD 标签格式：dogstatsd、influx（Telegraf）或 none"},
+		{Name: "METRICS_SINK_INFLUX_URL", Category: EnvCategoryMetrics, DefaultValue: "", Description: "InfluxDB 行协议写入地址（如 http://influxdb:8086/api/v2/write?org=o&bucket=b）"},
+		{Name: "METRICS_SINK_INFLUX_TOKEN", Category: EnvCategoryMetrics, DefaultValue: "", Description: "InfluxDB 写入令牌", IsSecret: true},
+		{Name: "METRICS_SINK_PREFIX", Category: EnvCategoryMetrics, DefaultValue: "qcc_plus", Description: "推送指标名前缀"},
+		{Name: "METRICS_SINK_QUEUE_SIZE", Category: EnvCategoryMetrics, DefaultValue: "10000", Description: "每个推送后端的内存队列容量，写满后丢弃新数据点"},
+		{Name: "METRICS_SINK_BATCH_SIZE", Category: EnvCategoryMetrics, DefaultValue: "500", Description: "推送后端单次批量发送的数据点数"},
+		{Name: "METRICS_SINK_FLUSH_INTERVAL", Category: EnvCategoryMetrics, DefaultValue: "5s", Description: "推送后端最长发送间隔"},
+		{Name: "METRICS_SINK_TIMEOUT", Category: EnvCategoryMetrics, DefaultValue: "10s", Description: "推送后端单次发送超时"},
+		{Name: "GRAFANA_TOKEN", Category: EnvCategoryMetrics, DefaultValue: "", Description: "Grafana JSON 数据源 /api/grafana 只读令牌（Bearer 或 Basic 密码），为空时关闭", IsSecret: true},
+		{Name: "TRACING_ENABLED", Category: EnvCategoryMetrics, DefaultValue: "false", Description: "开启链路追踪（配置 OTEL_EXPORTER_OTLP_ENDPOINT 时默认开启）"},
+		{Name: "TRACING_SAMPLE_RATIO", Category: EnvCategoryMetrics, DefaultValue: "0.1", Description: "初始采样比例，设置项 tracing.sample_ratio 优先"},
+		{Name: "OTEL_EXPORTER_OTLP_ENDPOINT", Category: EnvCategoryMetrics, DefaultValue: "", Description: "OTLP Collector 地址（HTTP 如 http://otel-collector:4318，gRPC 如 http://otel-collector:4317）"},
+		{Name: "OTEL_EXPORTER_OTLP_HEADERS", Category: EnvCategoryMetrics, DefaultValue: "", Description: "导出请求头（key=value,key2=value2）", IsSecret: true},
+		{Name: "OTEL_EXPORTER_OTLP_PROTOCOL", Category: EnvCategoryMetrics, DefaultValue: "", Description: "导出协议：grpc 或 http/json（http/protobuf 按 http/json 导出）；未设置时 grpc://、grpcs:// 地址或 4317 端口按 grpc，其余按 http/json"},
+		{Name: "OTEL_EXPORTER_OTLP_TIMEOUT", Category: EnvCategoryMetrics, DefaultValue: "10s", Description: "单次导出超时"},
+		{Name: "OTEL_SERVICE_NAME", Category: EnvCategoryMetrics, DefaultValue: "qcc_plus", Description: "上报的服务名"},
 
 		// ========== MySQL 持久化 ==========
 		{Name: "PROXY_MYSQL_DSN", Category: EnvCategoryMySQL, DefaultValue: "", Description: "MySQL 连接字符串（启用持久化）", IsSecret: true},
@@ -145,6 +197,13 @@ func GetAllEnvVarDefinitions() []EnvVarDefinition {
 		{Name: "TUNNEL_SUBDOMAIN", Category: EnvCategoryTunnel, DefaultValue: "", Description: "隧道子域名"},
 		{Name: "TUNNEL_ZONE", Category: EnvCategoryTunnel, DefaultValue: "", Description: "Cloudflare Zone（域名）"},
 		{Name: "TUNNEL_ENABLED", Category: EnvCategoryTunnel, DefaultValue: "0", Description: "启用隧道功能（1=启用，0=关闭）"},
+
+		// ========== 多实例部署 ==========
+		{Name: "LEADER_ELECTION_ENABLED", Category: EnvCategoryCluster, DefaultValue: "1", Description: "启用数据库租约选主（仅主实例运行全量健康检查、指标聚合/清理与预热）"},
+		{Name: "LEADER_LEASE_TTL", Category: EnvCategoryCluster, DefaultValue: "30s", Description: "主实例租约时长（每 1/3 时长续约一次）"},
+		{Name: "INSTANCE_ID", Category: EnvCategoryCluster, DefaultValue: "", Description: "实例标识（默认 主机名-进程号-随机后缀）"},
+		{Name: "CLUSTER_BUS", Category: EnvCategoryCluster, DefaultValue: "db", Description: "集群事件通道：db（数据库轮询）、local（仅进程内）、off（关闭）"},
+		{Name: "CLUSTER_POLL_INTERVAL", Category: EnvCategoryCluster, DefaultValue: "1s", Description: "db 通道轮询集群事件的间隔"},
 	}
 
 	// 填充当前值，并对敏感变量进行脱敏
@@ -0,0 +1,387 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"qcc_plus/internal/logging"
+	"qcc_plus/internal/notify"
+)
+
+// 上游错误分类。
+const (
+	ErrorCategoryAuth       = "auth"       // 401/403、authentication_error/permission_error
+	ErrorCategoryBilling    = "billing"    // 402、余额/额度不足
+	ErrorCategoryRateLimit  = "rate_limit" // 429、rate_limit_error
+	ErrorCategoryOverloaded = "overloaded" // 529、overloaded_error
+	ErrorCategoryClient     = "client"     // 400/404/413/422 等请求本身的问题
+	ErrorCategoryServer     = "server"     // 5xx、api_error
+	ErrorCategoryNetwork    = "network"    // 连接失败、DNS、TLS 等传输层错误
+	ErrorCategoryTimeout    = "timeout"    // 请求超时或客户端断开
+	ErrorCategoryUnknown    = "unknown"
+)
+
+// errorBodyPeekLimit 分类时最多读取的错误响应体字节数。
+const errorBodyPeekLimit = 4096
+
+// ErrorPolicy 分类对应的处理策略。
+type ErrorPolicy struct {
+	Retry       bool // 是否换节点重试（5xx 仍受 RetryOnStatus 约束，4xx 仅在允许时直接切换）
+	Penalize    bool // 是否计入熔断器、健康事件与节点失败计数
+	DisableNode bool // 是否自动禁用节点
+}
+
+var defaultErrorPolicies = map[string]ErrorPolicy{
+	ErrorCategoryAuth:       {Retry: true, Penalize: false, DisableNode: true},
+	ErrorCategoryBilling:    {Retry: true, Penalize: true},
+	ErrorCategoryRateLimit:  {Retry: true, Penalize: true},
+	ErrorCategoryOverloaded: {Retry: true, Penalize: true},
+	ErrorCategoryClient:     {Retry: false, Penalize: false},
+	ErrorCategoryServer:     {Retry: true, Penalize: true},
+	ErrorCategoryNetwork:    {Retry: true, Penalize: true},
+	ErrorCategoryTimeout:    {Retry: false, Penalize: false},
+	ErrorCategoryUnknown:    {Retry: false, Penalize: true},
+}
+
+// anthropicErrorTypes Anthropic 错误体 error.type 到分类的映射。
+var anthropicErrorTypes = map[string]string{
+	"authentication_error":  ErrorCategoryAuth,
+	"permission_error":      ErrorCategoryAuth,
+	"billing_error":         ErrorCategoryBilling,
+	"rate_limit_error":      ErrorCategoryRateLimit,
+	"overloaded_error":      ErrorCategoryOverloaded,
+	"invalid_request_error": ErrorCategoryClient,
+	"not_found_error":       ErrorCategoryClient,
+	"request_too_large":     ErrorCategoryClient,
+	"api_error":             ErrorCategoryServer,
+	"timeout_error":         ErrorCategoryTimeout,
+}
+
+// ErrorPattern 错误体匹配规则，用于识别中转站返回的非标准错误。
+// Pattern 以 "re:" 开头时按正则匹配，否则按不区分大小写的子串匹配。
+type ErrorPattern struct {
+	Pattern  string `json:"pattern"`
+	Category string `json:"category"`
+	// Status 非 0 时仅匹配该状态码。
+	Status int `json:"status,omitempty"`
+
+	re *regexp.Regexp
+}
+
+func (ep *ErrorPattern) match(status int, body string) bool {
+	if ep.Status != 0 && ep.Status != status {
+		return false
+	}
+	if ep.re != nil {
+		return ep.re.MatchString(body)
+	}
+	return strings.Contains(strings.ToLower(body), strings.ToLower(ep.Pattern))
+}
+
+// ErrorClassifier 将上游状态码与错误体映射为分类；规则可热更新。
+type ErrorClassifier struct {
+	mu       sync.RWMutex
+	patterns []ErrorPattern
+}
+
+// NewErrorClassifier 创建分类器。
+func NewErrorClassifier() *ErrorClassifier {
+	return &ErrorClassifier{}
+}
+
+func isErrorCategory(c string) bool {
+	_, ok := defaultErrorPolicies[c]
+	return ok
+}
+
+// SetPatterns 替换错误体匹配规则；任一规则非法时保持原规则不变。
+func (c *ErrorClassifier) SetPatterns(patterns []ErrorPattern) error {
+	compiled := make([]ErrorPattern, 0, len(patterns))
+	for _, p := range patterns {
+		p.Pattern = strings.TrimSpace(p.Pattern)
+		p.Category = strings.ToLower(strings.TrimSpace(p.Category))
+		if p.Pattern == "" {
+			continue
+		}
+		if !isErrorCategory(p.Category) {
+			return fmt.Errorf("unknown error category %q", p.Category)
+		}
+		if expr, ok := strings.CutPrefix(p.Pattern, "re:"); ok {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return fmt.Errorf("invalid pattern %q: %w", p.Pattern, err)
+			}
+			p.re = re
+		}
+		compiled = append(compiled, p)
+	}
+	c.mu.Lock()
+	c.patterns = compiled
+	c.mu.Unlock()
+	return nil
+}
+
+// Classify 按优先级分类：自定义错误体规则 > Anthropic error.type > 状态码。
+func (c *ErrorClassifier) Classify(status int, body []byte) string {
+	if status == http.StatusOK {
+		return ""
+	}
+	if c != nil && len(body) > 0 {
+		text := string(body)
+		c.mu.RLock()
+		for i := range c.patterns {
+			if c.patterns[i].match(status, text) {
+				cat := c.patterns[i].Category
+				c.mu.RUnlock()
+				return cat
+			}
+		}
+		c.mu.RUnlock()
+	}
+	if t := anthropicErrorType(body); t != "" {
+		if cat, ok := anthropicErrorTypes[t]; ok {
+			return cat
+		}
+	}
+	return classifyStatus(status)
+}
+
+// ClassifyMessage 对健康检查等只有错误文本的场景分类，文本形如 "status 401: {...}"。
+func (c *ErrorClassifier) ClassifyMessage(msg string) string {
+	msg = strings.TrimSpace(msg)
+	if msg == "" {
+		return ""
+	}
+	if status, body, ok := parseStatusMessage(msg); ok {
+		return c.Classify(status, []byte(body))
+	}
+	if cat := c.Classify(0, []byte(msg)); cat != ErrorCategoryUnknown {
+		return cat
+	}
+	lower := strings.ToLower(msg)
+	switch {
+	case strings.Contains(lower, "deadline exceeded"), strings.Contains(lower, "timeout"),
+		strings.Contains(lower, "context canceled"):
+		return ErrorCategoryTimeout
+	case strings.Contains(lower, "connection refused"), strings.Contains(lower, "no such host"),
+		strings.Contains(lower, "connection reset"), strings.HasSuffix(lower, "eof"),
+		strings.Contains(lower, "tls"), strings.Contains(lower, "dial tcp"):
+		return ErrorCategoryNetwork
+	}
+	return ErrorCategoryUnknown
+}
+
+// parseStatusMessage 解析 "status 401: body" / "upstream status 502: body"。
+func parseStatusMessage(msg string) (int, string, bool) {
+	rest := strings.TrimPrefix(msg, "upstream ")
+	rest, ok := strings.CutPrefix(rest, "status ")
+	if !ok {
+		return 0, "", false
+	}
+	code, body, _ := strings.Cut(rest, ":")
+	fields := strings.Fields(code)
+	if len(fields) == 0 {
+		return 0, "", false
+	}
+	status, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, "", false
+	}
+	return status, strings.TrimSpace(body), true
+}
+
+// anthropicErrorType 提取 {"type":"error","error":{"type":"..."}} 中的 error.type。
+func anthropicErrorType(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	var payload struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil || len(payload.Error) == 0 {
+		return ""
+	}
+	var inner struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(payload.Error, &inner); err != nil {
+		return ""
+	}
+	return strings.ToLower(inner.Type)
+}
+
+func classifyStatus(status int) string {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ErrorCategoryAuth
+	case status == http.StatusPaymentRequired:
+		return ErrorCategoryBilling
+	case status == http.StatusTooManyRequests:
+		return ErrorCategoryRateLimit
+	case status == 529:
+		return ErrorCategoryOverloaded
+	case status == 499 || status == http.StatusGatewayTimeout || status == http.StatusRequestTimeout:
+		return ErrorCategoryTimeout
+	case status >= 400 && status < 500:
+		return ErrorCategoryClient
+	case status >= 500:
+		return ErrorCategoryServer
+	default:
+		return ErrorCategoryUnknown
+	}
+}
+
+// errorPolicy 返回分类对应策略；认证失败自动禁用可通过 proxy.auth_error_disable 关闭。
+func (p *Server) errorPolicy(category string) ErrorPolicy {
+	policy, ok := defaultErrorPolicies[category]
+	if !ok {
+		policy = defaultErrorPolicies[ErrorCategoryUnknown]
+	}
+	if policy.DisableNode && p.settingsCache != nil && !p.settingsCache.GetBool("proxy.auth_error_disable", true) {
+		policy.DisableNode = false
+		policy.Penalize = true
+	}
+	return policy
+}
+
+// classifyErrorMessage 对错误文本分类，分类器未初始化时仅按状态码判断。
+func (p *Server) classifyErrorMessage(msg string) string {
+	return p.errorClassifier.ClassifyMessage(msg)
+}
+
+// parseErrorPatterns 解析设置项 proxy.error_patterns（JSON 数组）。
+func parseErrorPatterns(value any) ([]ErrorPattern, error) {
+	if value == nil {
+		return nil, nil
+	}
+	var raw []byte
+	switch v := value.(type) {
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		raw = b
+	}
+	if len(strings.TrimSpace(string(raw))) == 0 {
+		return nil, nil
+	}
+	var patterns []ErrorPattern
+	if err := json.Unmarshal(raw, &patterns); err != nil {
+		return nil, errors.New("proxy.error_patterns must be a JSON array of {pattern, category}")
+	}
+	return patterns, nil
+}
+
+// applyErrorPatterns 从设置加载错误体规则，非法配置保留原规则并记录日志。
+func (p *Server) applyErrorPatterns(value any) {
+	if p.errorClassifier == nil {
+		return
+	}
+	patterns, err := parseErrorPatterns(value)
+	if err == nil {
+		err = p.errorClassifier.SetPatterns(patterns)
+	}
+	if err != nil {
+		p.logger.Component("proxy").Warn("ignore invalid proxy.error_patterns", logging.KeyError, err)
+		return
+	}
+	p.logger.Component("proxy").Info("loaded error body patterns", "count", len(patterns))
+}
+
+// 认证失败自动禁用阈值：窗口内连续失败达到次数才禁用，避免上游偶发 401/403 误禁用节点。
+const (
+	authFailureThreshold = 3
+	authFailureWindow    = 10 * time.Minute
+)
+
+// authFailStreak 节点连续认证失败计数。
+type authFailStreak struct {
+	count int
+	first time.Time
+}
+
+// recordAuthFailure 记录一次认证失败，返回是否达到禁用阈值。超出窗口的旧计数重新开始。
+func (p *Server) recordAuthFailure(nodeID string, now time.Time) (int, bool) {
+	p.authFailMu.Lock()
+	defer p.authFailMu.Unlock()
+	if p.authFails == nil {
+		p.authFails = make(map[string]authFailStreak)
+	}
+	st := p.authFails[nodeID]
+	if st.count == 0 || now.Sub(st.first) > authFailureWindow {
+		st = authFailStreak{first: now}
+	}
+	st.count++
+	if st.count >= authFailureThreshold {
+		delete(p.authFails, nodeID)
+		return st.count, true
+	}
+	p.authFails[nodeID] = st
+	return st.count, false
+}
+
+// resetAuthFailures 节点请求或探活成功后清零认证失败计数。
+func (p *Server) resetAuthFailures(nodeID string) {
+	p.authFailMu.Lock()
+	defer p.authFailMu.Unlock()
+	delete(p.authFails, nodeID)
+}
+
+// handleAuthFailure 上游认证失败：窗口内连续失败达到阈值后自动禁用节点并发送账号认证失败通知。
+func (p *Server) handleAuthFailure(nodeID, errMsg string) {
+	p.mu.RLock()
+	n, ok := p.nodeIndex[nodeID]
+	if !ok || n.Disabled {
+		p.mu.RUnlock()
+		return
+	}
+	nodeName := n.Name
+	acc := p.nodeAccount[nodeID]
+	p.mu.RUnlock()
+
+	if count, reached := p.recordAuthFailure(nodeID, time.Now()); !reached {
+		p.logger.Component("node").Warn("upstream auth failure", logging.KeyNodeID, nodeID, "node", nodeName, "count", count, "threshold", authFailureThreshold, logging.KeyError, errMsg)
+		return
+	}
+
+	if err := p.setNodeDisabled(nodeID, "认证失败自动禁用"); err != nil {
+		p.logger.Component("node").Warn("disable node after auth failure failed", logging.KeyNodeID, nodeID, "node", nodeName, logging.KeyError, err)
+		return
+	}
+	p.logger.Component("node").Warn("node disabled after upstream auth failure", logging.KeyNodeID, nodeID, "node", nodeName, logging.KeyError, errMsg)
+	if p.notifyMgr != nil && acc != nil {
+		p.notifyMgr.Publish(notify.Event{
+			AccountID:  acc.ID,
+			EventType:  notify.EventAccountAuthFailed,
+			Title:      "上游认证失败",
+			Content:    fmt.Sprintf("**节点名称**: %s\n**错误信息**: %s\n**处理**: 节点已自动禁用，请检查 API Key 后手动启用", nodeName, errMsg),
+			DedupKey:   nodeID,
+			OccurredAt: time.Now(),
+		})
+	}
+}
+
+// upstreamErrorMessage 生成与健康检查一致的错误摘要，便于 ClassifyMessage 复用。
+func upstreamErrorMessage(status int, body []byte) string {
+	const maxLen = 500
+	text := strings.TrimSpace(string(body))
+	if text == "" {
+		return fmt.Sprintf("upstream status %d", status)
+	}
+	if len(text) > maxLen {
+		text = strings.ToValidUTF8(text[:maxLen], "") + "...(truncated)"
+	}
+	return fmt.Sprintf("upstream status %d: %s", status, text)
+}
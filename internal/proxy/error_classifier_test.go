@@ -0,0 +1,164 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestErrorClassifierStatusAndAnthropicTypes 测试状态码与 Anthropic 错误类型映射
+func TestErrorClassifierStatusAndAnthropicTypes(t *testing.T) {
+	c := NewErrorClassifier()
+	cases := []struct {
+		status int
+		body   string
+		want   string
+	}{
+		{200, "", ""},
+		{401, "", ErrorCategoryAuth},
+		{403, `{"type":"error","error":{"type":"permission_error","message":"denied"}}`, ErrorCategoryAuth},
+		{402, "", ErrorCategoryBilling},
+		{429, "", ErrorCategoryRateLimit},
+		{529, "", ErrorCategoryOverloaded},
+		{400, `{"type":"error","error":{"type":"invalid_request_error","message":"bad"}}`, ErrorCategoryClient},
+		{404, `{"type":"error","error":{"type":"not_found_error","message":"model"}}`, ErrorCategoryClient},
+		{500, `{"type":"error","error":{"type":"overloaded_error","message":"busy"}}`, ErrorCategoryOverloaded},
+		{400, `{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`, ErrorCategoryAuth},
+		{502, "bad gateway", ErrorCategoryServer},
+		{504, "", ErrorCategoryTimeout},
+	}
+	for _, tc := range cases {
+		if got := c.Classify(tc.status, []byte(tc.body)); got != tc.want {
+			t.Errorf("Classify(%d, %q) = %q, want %q", tc.status, tc.body, got, tc.want)
+		}
+	}
+}
+
+// TestErrorClassifierPatterns 测试中转站错误体规则优先于状态码
+func TestErrorClassifierPatterns(t *testing.T) {
+	c := NewErrorClassifier()
+	err := c.SetPatterns([]ErrorPattern{
+		{Pattern: "余额不足", Category: "billing"},
+		{Pattern: `re:token\s+(expired|invalid)`, Category: "auth", Status: 500},
+	})
+	if err != nil {
+		t.Fatalf("set patterns: %v", err)
+	}
+	if got := c.Classify(500, []byte(`{"error":{"message":"账户余额不足"}}`)); got != ErrorCategoryBilling {
+		t.Fatalf("expected billing, got %q", got)
+	}
+	if got := c.Classify(500, []byte(`token  expired`)); got != ErrorCategoryAuth {
+		t.Fatalf("expected auth, got %q", got)
+	}
+	// Status 限定不匹配时回落到状态码分类
+	if got := c.Classify(503, []byte(`token expired`)); got != ErrorCategoryServer {
+		t.Fatalf("expected server, got %q", got)
+	}
+
+	if err := c.SetPatterns([]ErrorPattern{{Pattern: "x", Category: "nope"}}); err == nil {
+		t.Fatalf("expected error for unknown category")
+	}
+	if got := c.Classify(500, []byte("余额不足")); got != ErrorCategoryBilling {
+		t.Fatalf("invalid patterns must keep previous rules, got %q", got)
+	}
+}
+
+// TestErrorClassifierMessage 测试健康检查错误文本分类
+func TestErrorClassifierMessage(t *testing.T) {
+	var c *ErrorClassifier
+	cases := map[string]string{
+		"":                               "",
+		"status 401: unauthorized":       ErrorCategoryAuth,
+		"upstream status 429: slow down": ErrorCategoryRateLimit,
+		"upstream status 503":            ErrorCategoryServer,
+		`status 400: {"error":{"type":"invalid_request_error"}}`:                           ErrorCategoryClient,
+		"Post \"http://x/v1/messages\": dial tcp 1.2.3.4:443: connect: connection refused": ErrorCategoryNetwork,
+		"context deadline exceeded (Client.Timeout exceeded while awaiting headers)":       ErrorCategoryTimeout,
+		"cli health check returned empty output":                                           ErrorCategoryUnknown,
+	}
+	for msg, want := range cases {
+		if got := c.ClassifyMessage(msg); got != want {
+			t.Errorf("ClassifyMessage(%q) = %q, want %q", msg, got, want)
+		}
+	}
+}
+
+// TestAuthFailureDisablesNode 测试认证失败自动禁用节点并切换活跃节点
+func TestAuthFailureDisablesNode(t *testing.T) {
+	srv := newClusterTestServer(t, NewLocalClusterBus(), "a")
+
+	policy := srv.errorPolicy(ErrorCategoryAuth)
+	if !policy.DisableNode || policy.Penalize {
+		t.Fatalf("unexpected auth policy: %+v", policy)
+	}
+	if p := srv.errorPolicy(ErrorCategoryClient); p.Retry || p.Penalize {
+		t.Fatalf("client errors must not retry or penalize: %+v", p)
+	}
+
+	// 窗口外的失败重新计数，成功后清零
+	srv.recordAuthFailure("n1", time.Now().Add(-2*authFailureWindow))
+	srv.handleAuthFailure("n1", "status 401: invalid api key")
+	srv.resetAuthFailures("n1")
+	for i := 1; i < authFailureThreshold; i++ {
+		srv.handleAuthFailure("n1", "status 401: invalid api key")
+		if srv.getNode("n1").Disabled {
+			t.Fatalf("node must not be disabled after %d auth failures", i)
+		}
+	}
+	srv.handleAuthFailure("n1", "status 401: invalid api key")
+	if !srv.getNode("n1").Disabled {
+		t.Fatalf("expected n1 disabled after %d auth failures", authFailureThreshold)
+	}
+	if got := srv.TestAccount("acc-1").ActiveID; got != "n2" {
+		t.Fatalf("expected active node n2, got %s", got)
+	}
+}
+
+// TestClientErrorFailoverWritesSingleResponse 测试 4xx 切换节点时客户端只收到最终响应
+func TestClientErrorFailoverWritesSingleResponse(t *testing.T) {
+	badStatus := http.StatusUnauthorized
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.Header().Set("X-Bad-Node", "1")
+		w.WriteHeader(badStatus)
+		if badStatus == http.StatusUnauthorized {
+			_, _ = w.Write([]byte(`{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"type":"error","error":{"type":"invalid_request_error","message":"bad"}}`))
+	}))
+	defer bad.Close()
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer good.Close()
+
+	srv := newClusterTestServer(t, NewLocalClusterBus(), "a")
+	srv.transport = http.DefaultTransport
+	srv.errorClassifier = NewErrorClassifier()
+	srv.retryConfig = RetryConfig{MaxAttempts: 3, PerRequestTimeout: 5 * time.Second}
+	acc := srv.TestAccount("acc-1")
+	srv.defaultAccount = acc
+	// n1 优先级更高，且 Key 失效
+	acc.Nodes["n1"].URL, _ = url.Parse(bad.URL)
+	acc.Nodes["n2"].URL, _ = url.Parse(good.URL)
+
+	rec := httptest.NewRecorder()
+	srv.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"m"}`)))
+	if rec.Code != http.StatusOK || rec.Body.String() != `{"ok":true}` || rec.Header().Get("X-Bad-Node") != "" {
+		t.Fatalf("expected only the failover response, got %d %q %v", rec.Code, rec.Body.String(), rec.Header())
+	}
+
+	// 不可重试的客户端错误原样返回
+	badStatus = http.StatusBadRequest
+	rec = httptest.NewRecorder()
+	srv.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"m"}`)))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "invalid_request_error") {
+		t.Fatalf("expected client error passed through, got %d %q", rec.Code, rec.Body.String())
+	}
+}
@@ -10,4 +10,7 @@ func (e *ConfigError) Error() string { return e.msg }
 var (
 	ErrUpstreamMissing = &ConfigError{"missing upstream base URL"}
 	ErrNoActiveNode    = errors.New("no active upstream node")
+
+	errInvalidUptimeWindow = errors.New("window must be day|week|month")
+	errUptimeNodeNotFound  = errors.New("node not found")
 )
@@ -13,6 +13,8 @@ import (
 	"strings"
 	"time"
 
+	"qcc_plus/internal/logging"
+	"qcc_plus/internal/tracing"
 	"qcc_plus/internal/version"
 	"qcc_plus/web"
 )
@@ -77,6 +79,19 @@ func (p *Server) handler() http.Handler {
 	apiMux.HandleFunc("/admin/api/tunnel/start", p.requireSession(p.handleTunnelStart))
 	apiMux.HandleFunc("/admin/api/tunnel/stop", p.requireSession(p.handleTunnelStop))
 	apiMux.HandleFunc("/admin/api/tunnel/zones", p.requireSession(p.handleTunnelZones))
+	apiMux.HandleFunc("/admin/api/cluster/leader", p.requireSession(p.handleClusterLeader))
+	apiMux.HandleFunc("/admin/api/cluster/status", p.requireSession(p.handleClusterStatus))
+	apiMux.HandleFunc("/admin/api/cluster/events", p.requireSession(p.handleClusterEvents))
+	apiMux.HandleFunc("/admin/api/retry-budget", p.requireSession(p.handleRetryBudget))
+	apiMux.HandleFunc("/admin/api/retry-policy", p.requireSession(p.handleRetryPolicy))
+	apiMux.HandleFunc("/admin/api/request-trace", p.requireSession(p.handleRequestTrace))
+	apiMux.HandleFunc("/admin/api/captures", p.requireSession(p.handleCaptures))
+	apiMux.HandleFunc("/admin/api/captures/", p.requireSession(p.handleCaptureByID))
+	apiMux.HandleFunc("/admin/api/circuit-breakers", p.requireSession(p.handleCircuitBreakers))
+	apiMux.HandleFunc("/admin/api/circuit-breakers/", p.requireSession(p.handleCircuitBreakerByNode))
+	apiMux.HandleFunc("/admin/api/node-costs", p.requireSession(p.handleNodeCosts))
+	apiMux.HandleFunc("/admin/api/node-costs/", p.requireSession(p.handleNodeCosts))
+	apiMux.HandleFunc("/admin/api/metric-sinks", p.requireSession(p.handleMetricSinks))
 	apiMux.HandleFunc("/api/notification/channels", p.requireSession(p.handleNotificationChannels))
 	apiMux.HandleFunc("/api/notification/channels/", p.requireSession(p.handleNotificationChannelByID))
 	apiMux.HandleFunc("/api/notification/subscriptions", p.requireSession(p.handleNotificationSubscriptions))
@@ -91,7 +106,11 @@ func (p *Server) handler() http.Handler {
 	apiMux.HandleFunc("/api/monitor/shares", p.requireSession(p.handleMonitorShares))
 	apiMux.HandleFunc("/api/monitor/shares/", p.requireSession(p.handleRevokeMonitorShare))
 	apiMux.HandleFunc("/api/monitor/share/", p.handleAccessMonitorShare)
-	settingsHandler := &SettingsHandler{store: p.store, cache: p.settingsCache}
+	// 可用性（SLA）报表与维护窗口 API
+	apiMux.HandleFunc("/api/uptime", p.requireSession(p.handleUptimeReport))
+	apiMux.HandleFunc("/api/uptime/maintenance", p.requireSession(p.handleMaintenanceWindows))
+	apiMux.HandleFunc("/api/uptime/maintenance/", p.requireSession(p.handleMaintenanceWindowByID))
+	settingsHandler := &SettingsHandler{store: p.store, cache: p.settingsCache, onMutate: p.publishSettingsChanged}
 	apiMux.HandleFunc("/api/settings/version", p.requireSession(settingsHandler.GetVersion))
 	apiMux.HandleFunc("/api/settings", p.requireSession(settingsHandler.ListSettings))
 	apiMux.HandleFunc("/api/settings/batch", p.requireSession(settingsHandler.BatchUpdate))
@@ -100,9 +119,19 @@ func (p *Server) handler() http.Handler {
 	apiMux.HandleFunc("/api/claude-config/download/", p.handleClaudeConfigDownload)
 	// 定价和使用统计 API
 	apiMux.HandleFunc("/api/pricing", p.requireSession(p.handlePricing))
+	apiMux.HandleFunc("/api/pricing/rules", p.requireSession(p.handlePricingRules))
+	apiMux.HandleFunc("/api/pricing/unpriced", p.requireSession(p.handleUnpricedModels))
+	apiMux.HandleFunc("/api/pricing/reprice", p.requireSession(p.handleRepriceUsage))
+	apiMux.HandleFunc("/api/statements", p.requireSession(p.handleStatements))
+	apiMux.HandleFunc("/api/statements/adjustments", p.requireSession(p.handleBillingAdjustments))
+	apiMux.HandleFunc("/api/statements/", p.requireSession(p.handleStatementByID))
 	apiMux.HandleFunc("/api/usage/logs", p.requireSession(p.handleUsageLogs))
 	apiMux.HandleFunc("/api/usage/summary", p.requireSession(p.handleUsageSummary))
 	apiMux.HandleFunc("/api/usage/cleanup", p.requireSession(p.handleUsageCleanup))
+	apiMux.HandleFunc("/api/request-logs", p.requireSession(p.handleRequestLogs))
+	apiMux.HandleFunc("/api/export/usage", p.requireSession(p.handleExportUsage))
+	apiMux.HandleFunc("/api/export/metrics", p.requireSession(p.handleExportMetrics))
+	apiMux.HandleFunc("/api/export/health", p.requireSession(p.handleExportHealth))
 	// 环境变量 API
 	apiMux.HandleFunc("/api/envvars", p.requireSession(p.handleEnvVars))
 	apiMux.HandleFunc("/api/envvars/categories", p.requireSession(p.handleEnvVarsCategories))
@@ -115,6 +144,17 @@ func (p *Server) handler() http.Handler {
 			return
 		}
 
+		if path == "/metrics" && p.promConfig.Enabled {
+			p.handlePrometheusMetrics(w, r)
+			return
+		}
+
+		// Grafana 数据源使用独立只读令牌，不走会话鉴权。
+		if path == "/api/grafana" || strings.HasPrefix(path, "/api/grafana/") {
+			p.handleGrafana(w, r)
+			return
+		}
+
 		if path == "/api/monitor/ws" {
 			p.handleMonitorWebSocket(w, r)
 			return
@@ -157,12 +197,23 @@ func (p *Server) handler() http.Handler {
 			return
 		}
 
+		// 分享页可通过 share_token 免登录访问可用性报表。
+		if path == "/api/uptime" && r.URL.Query().Get("share_token") != "" {
+			p.handleUptimeReport(w, r)
+			return
+		}
+		if path == "/api/uptime" || strings.HasPrefix(path, "/api/uptime/") {
+			apiMux.ServeHTTP(w, r)
+			return
+		}
+
 		if strings.HasPrefix(path, "/api/settings") {
 			apiMux.ServeHTTP(w, r)
 			return
 		}
 
-		if strings.HasPrefix(path, "/api/pricing") || strings.HasPrefix(path, "/api/usage/") {
+		if strings.HasPrefix(path, "/api/pricing") || strings.HasPrefix(path, "/api/usage/") || path == "/api/request-logs" ||
+			strings.HasPrefix(path, "/api/export/") || strings.HasPrefix(path, "/api/statements") {
 			apiMux.ServeHTTP(w, r)
 			return
 		}
@@ -198,33 +249,79 @@ func (p *Server) handler() http.Handler {
 		// 只代理 /v1/messages 接口，其他请求透传到上游
 		if path == "/v1/messages" {
 			// Proxy endpoints for /v1/messages
+			// 延续客户端 traceparent，未携带时新建追踪
+			ctx, reqSpan := p.tracer.Start(tracing.Extract(r.Context(), r.Header), "proxy.request", tracing.SpanKindServer)
+			defer reqSpan.End()
+			r = r.WithContext(ctx)
+			reqSpan.SetAttr("http.method", r.Method)
+			reqSpan.SetAttr("http.route", path)
+
+			_, authSpan := p.tracer.Start(ctx, "proxy.auth", tracing.SpanKindInternal)
 			proxyKey := extractAPIKey(r)
 			account := p.getAccountByProxyKey(proxyKey)
 			if account == nil {
 				account = p.defaultAccount
 			}
 			if account == nil {
+				authSpan.SetStatusError("account not found")
+				authSpan.End()
+				reqSpan.SetAttr("http.status_code", http.StatusUnauthorized)
 				http.Error(w, "account not found", http.StatusUnauthorized)
 				return
 			}
+			authSpan.End()
+			reqSpan.SetAttr("account.id", account.ID)
+
+			requestID, clientID := assignRequestID(w, r)
+			reqSpan.SetAttr("request.id", requestID)
+			trace := newRequestTrace(r, requestID, account.ID, p.wantTrace(r))
+			trace.ClientRequestID = clientID
+			trace.audit = newRequestAudit(r, proxyKey, p.trustedProxies)
+			reqLog := p.logger.Component("proxy").With(logging.KeyRequestID, requestID, logging.KeyAccountID, account.ID)
+			for _, skipped := range p.skippedNodeTraces(account) {
+				trace.add(skipped)
+			}
+			var lastUsage *usage
+			var capture *requestCapture
+			defer func() {
+				reqSpan.SetAttr("http.status_code", trace.Status)
+				reqSpan.SetAttr("proxy.attempts", len(trace.Attempts))
+				if !trace.Success {
+					reqSpan.SetStatusError("all attempts failed")
+				}
+				p.finishTrace(trace, lastUsage)
+				p.finishCapture(trace, capture)
+			}()
 
 			skipNodes := make(map[string]bool)
 			firstAttemptFailed := false
 			baseCtx := context.WithValue(r.Context(), accountContextKey{}, account)
 			baseCtx = context.WithValue(baseCtx, nodeContextKey{}, nil)
+			retryCfg := p.retryConfigFor(account.ID)
 			overallDeadline := time.Time{}
-			if p.retryConfig.TotalTimeout > 0 {
-				overallDeadline = time.Now().Add(p.retryConfig.TotalTimeout)
+			if retryCfg.TotalTimeout > 0 {
+				overallDeadline = time.Now().Add(retryCfg.TotalTimeout)
 			}
 			var bodyBytes []byte
 			if r.Body != nil {
-				bodyBytes, _ = io.ReadAll(r.Body)
+				_, bodySpan := p.tracer.Start(ctx, "proxy.read_body", tracing.SpanKindInternal)
+				var err error
+				bodyBytes, err = io.ReadAll(r.Body)
 				r.Body.Close()
+				bodySpan.SetAttr("body.bytes", len(bodyBytes))
+				bodySpan.SetError(err)
+				bodySpan.End()
+			}
+			capture, w = p.startCapture(w, r, account.ID, bodyBytes)
+			if p.store != nil || p.requestLogs != nil || capture != nil {
+				trace.audit.parseBody(bodyBytes)
 			}
 
 			// attempt 只计算真正发送请求的次数，maxLoops 防止无限循环
 			// maxLoops = 节点数量 * 2，确保即使有熔断器也能尝试所有节点
 			attempt := 0
+			// pending 上一次可重试的失败响应，切换节点前仅缓存；循环结束时写给客户端
+			var pending *attemptWriter
 			maxLoops := len(account.Nodes) * 2
 			if maxLoops < 20 {
 				maxLoops = 20 // 至少尝试 20 次循环
@@ -235,50 +332,78 @@ func (p *Server) handler() http.Handler {
 					reqForAttempt.Body = io.NopCloser(bytes.NewReader(bodyBytes))
 					reqForAttempt.ContentLength = int64(len(bodyBytes))
 				}
+				_, selectSpan := p.tracer.Start(ctx, "proxy.select_node", tracing.SpanKindInternal)
 				node := p.selectHealthyNodeExcluding(account, skipNodes)
 				if node == nil {
+					selectSpan.SetStatusError("no healthy node")
+					selectSpan.End()
 					break
 				}
+				selectSpan.SetAttr("node.id", node.ID)
 
 				// 检查熔断器
 				var cb *CircuitBreaker
 				if p.cbConfig.Enabled {
 					cb = p.getOrCreateCircuitBreaker(node.ID)
 					if !cb.AllowRequest() {
-						p.logger.Printf("node %s circuit breaker is open, skipping (loop %d/%d, tried %d nodes)", node.Name, loops+1, maxLoops, attempt)
+						reqLog.Info("circuit breaker open, skipping node", logging.KeyNodeID, node.ID, "node", node.Name, "loop", loops+1, "max_loops", maxLoops, "tried", attempt)
+						trace.add(AttemptTrace{NodeID: node.ID, NodeName: node.Name, Skipped: skipBreakerOpen})
+						selectSpan.SetAttr("skip", skipBreakerOpen)
+						selectSpan.End()
 						skipNodes[node.ID] = true
 						continue // 跳过此节点，不计入 attempt
 					}
 				}
+				selectSpan.End()
 
-				usage := &usage{}
+				usage := &usage{trace: trace}
+				lastUsage = usage
 				proxy, streamState := p.newReverseProxy(node, usage)
-				p.logger.Printf("%s %s via %s (account=%s, node %d/%d)", r.Method, r.URL.String(), node.Name, account.ID, attempt+1, len(account.Nodes))
+				nodeLog := reqLog.With(logging.KeyNodeID, node.ID, "node", node.Name)
+				nodeLog.Info("forwarding request", "method", r.Method, "url", r.URL.String(), "attempt", attempt+1, "nodes", len(account.Nodes))
 
 				start := time.Now()
-				mw := &metricsWriter{ResponseWriter: w, status: http.StatusOK}
+				trace.attemptStart = start
+				aw := newAttemptWriter(w)
+				mw := &metricsWriter{ResponseWriter: aw, status: http.StatusOK}
 
 				// 计算本次尝试的超时时间：按配置的 per-attempt 优先，其次单次超时，再受总超时约束
-				timeout := p.retryConfig.PerRequestTimeout
-				if len(p.retryConfig.PerAttemptTimeouts) > attempt {
-					timeout = p.retryConfig.PerAttemptTimeouts[attempt]
+				timeout := retryCfg.PerRequestTimeout
+				if len(retryCfg.PerAttemptTimeouts) > attempt {
+					timeout = retryCfg.PerAttemptTimeouts[attempt]
 				}
+				// clamped 表示本次超时受总超时约束，到期后不再切换节点
+				clamped := false
 				if !overallDeadline.IsZero() {
 					remaining := time.Until(overallDeadline)
 					if remaining <= 0 {
+						if pending != nil {
+							pending.commit()
+							return
+						}
 						http.Error(w, `{"error":{"type":"proxy_timeout","message":"request timeout after all retries"}}`, http.StatusServiceUnavailable)
 						return
 					}
 					if remaining < timeout {
 						timeout = remaining
+						clamped = true
 					}
 				}
 
 				attemptCtx := context.WithValue(baseCtx, nodeContextKey{}, node)
+				attemptCtx, attemptSpan := p.tracer.Start(attemptCtx, "proxy.attempt", tracing.SpanKindClient)
+				attemptSpan.SetAttr("node.id", node.ID)
+				attemptSpan.SetAttr("node.name", node.Name)
+				attemptSpan.SetAttr("proxy.attempt", attempt+1)
+				// 上游看到的父 Span 为本次尝试
+				tracing.Inject(attemptCtx, reqForAttempt.Header)
 				attemptCtx, cancel := context.WithTimeout(attemptCtx, timeout)
 				reqForAttempt = reqForAttempt.WithContext(attemptCtx)
 				proxy.ServeHTTP(wrapFirstByteFlush(mw, streamState), reqForAttempt)
+				// 单次尝试超时（非客户端断开、非总超时、且未向客户端写出）可切换到下一个节点
+				attemptTimedOut := attemptCtx.Err() == context.DeadlineExceeded && r.Context().Err() == nil && !clamped && !aw.committed
 				cancel()
+				pending = nil
 
 				// 真正发送了请求，计数器+1
 				attempt++
@@ -291,7 +416,7 @@ func (p *Server) handler() http.Handler {
 
 				// 判断是否是 context 错误（499=客户端关闭，504=网关超时）
 				// context 错误不应该触发熔断器和节点失败标记
-				isContextError := mw.status == 499 || mw.status == http.StatusGatewayTimeout
+				isContextError := !attemptTimedOut && (mw.status == 499 || mw.status == http.StatusGatewayTimeout)
 
 				failed := mw.status != http.StatusOK || statusForRetry >= http.StatusInternalServerError
 
@@ -299,17 +424,38 @@ func (p *Server) handler() http.Handler {
 					firstAttemptFailed = true
 				}
 
-				// context 错误不记录到熔断器
-				if cb != nil && !isContextError {
+				// 按上游错误分类决定是否重试、是否计入节点失败
+				var category string
+				policy := ErrorPolicy{Penalize: true}
+				if failed {
+					category = usage.errCategory
+					if category == "" {
+						category = p.errorClassifier.Classify(statusForRetry, nil)
+						usage.errCategory = category
+					}
+					policy = p.errorPolicy(category)
+					// 单次尝试超时说明节点响应慢，计入节点失败并切换节点
+					if attemptTimedOut {
+						policy.Retry, policy.Penalize = true, true
+					}
+				}
+
+				// context 错误与客户端错误不记录到熔断器
+				if cb != nil && !isContextError && (!failed || policy.Penalize) {
 					cb.RecordResult(!failed)
 				}
 
-				shouldRetry := failed && statusForRetry >= http.StatusInternalServerError && shouldRetryStatus(statusForRetry, p.retryConfig)
+				shouldRetry := failed && policy.Retry &&
+					(attemptTimedOut || statusForRetry < http.StatusInternalServerError || shouldRetryStatus(statusForRetry, retryCfg))
 				// context 错误不应该重试（客户端已断开或超时）
 				if isContextError {
 					shouldRetry = false
 				}
-				isLastAttempt := attempt >= p.retryConfig.MaxAttempts
+				// 失败响应已写给客户端（如超出缓存上限）时无法再切换节点
+				if aw.committed {
+					shouldRetry = false
+				}
+				isLastAttempt := attempt >= retryCfg.MaxAttempts
 				finalAttempt := !failed || !shouldRetry || isLastAttempt
 
 				var retryAttemptsTotal int64
@@ -321,44 +467,98 @@ func (p *Server) handler() http.Handler {
 					retrySuccess = 1
 				}
 
-				p.recordMetrics(r.Context(), node.ID, start, mw, usage, retryAttemptsTotal, retrySuccess, finalAttempt)
+				attemptTrace := AttemptTrace{
+					NodeID:        node.ID,
+					NodeName:      node.Name,
+					Status:        statusForRetry,
+					ErrorCategory: category,
+					LatencyMs:     time.Since(start).Milliseconds(),
+					At:            start,
+				}
+				if failed {
+					attemptTrace.Error = usage.errMessage
+				}
+				trace.add(attemptTrace)
+				trace.Status = mw.status
+				trace.audit.firstByteAt = mw.firstAt
+				trace.Success = !failed
+
+				attemptSpan.SetAttr("http.status_code", statusForRetry)
+				if !mw.firstAt.IsZero() {
+					attemptSpan.SetAttr("ttfb_ms", mw.firstAt.Sub(start).Milliseconds())
+				}
+				if failed {
+					attemptSpan.SetAttr("error.category", category)
+					attemptSpan.SetStatusError(chooseNonEmpty(usage.errMessage, category))
+				}
+				p.recordMetrics(tracing.ContextWithSpan(r.Context(), attemptSpan), node.ID, start, mw, usage, retryAttemptsTotal, retrySuccess, finalAttempt)
+				attemptSpan.End()
 
 				if !failed {
+					p.retryBudget.deposit(account.ID)
+					p.resetAuthFailures(node.ID)
 					return
 				}
 
 				// context 错误不记录健康事件和节点失败
 				if isContextError {
-					p.logger.Printf("[context] request canceled/timeout for node %s, not marking as failure", node.Name)
+					nodeLog.Info("request canceled or timed out, not marking node as failed")
+					aw.commit()
 					return
 				}
 
-				errMsg := extractErrorMessage(mw, statusForRetry)
-				if account != nil {
-					p.recordHealthEvent(account.ID, node.ID, HealthCheckMethodProxy, CheckSourceProxyFail, false, time.Since(start), errMsg, time.Now().UTC())
+				errMsg := usage.errMessage
+				if errMsg == "" {
+					errMsg = extractErrorMessage(mw, statusForRetry)
 				}
-				if p.shouldFail(node.ID, errMsg) {
+				if policy.DisableNode {
+					p.handleAuthFailure(node.ID, errMsg)
+				}
+				if !policy.Penalize && !policy.DisableNode {
+					nodeLog.Warn("upstream error, not penalizing node", "category", category, logging.KeyError, errMsg)
+				} else if account != nil {
+					p.recordHealthEvent(account.ID, node.ID, HealthCheckMethodProxy, CheckSourceProxyFail, false, time.Since(start), errMsg, category, time.Now().UTC())
+				}
+				if policy.Penalize && p.shouldFail(node.ID, errMsg) {
 					// 仅在最后一次尝试失败时才把节点标记为全局失败，避免单请求重试耗尽所有节点
 					if isLastAttempt {
 						p.handleFailure(node.ID, errMsg)
 					} else {
-						p.logger.Printf("[retry] node %s failed in attempt %d, will try other nodes", node.Name, attempt+1)
+						nodeLog.Warn("node failed, will try other nodes", "attempt", attempt+1)
 					}
 				}
 				skipNodes[node.ID] = true
 
 				if !shouldRetry {
+					aw.commit()
+					return
+				}
+				// 重试预算耗尽：以当前失败响应结束，不再切换节点；轨迹与响应头标记预算耗尽
+				if !p.retryBudget.allow(account.ID, retryStageFailover) {
+					nodeLog.Warn("retry budget exhausted, fail fast", "attempts", attempt, logging.KeyError, errMsg)
+					trace.add(AttemptTrace{Skipped: skipRetryBudget, Error: errRetryBudgetExhausted.Error(), At: time.Now()})
+					if !aw.committed {
+						aw.Header().Set(retryBudgetHeader, "exhausted")
+					}
+					aw.commit()
 					return
 				}
 
 				// 如果还有可尝试的节点，记录日志并继续
 				if shouldRetry {
-					p.logger.Printf("retrying with next node (tried %d/%d nodes), %s failed: %s", attempt, len(account.Nodes), node.Name, errMsg)
-					backoff := calculateBackoff(attempt-1, p.retryConfig)
+					pending = aw
+					p.prom.observeRetry(account.ID, node.ID)
+					nodeLog.Warn("retrying with next node", "tried", attempt, "nodes", len(account.Nodes), logging.KeyError, errMsg)
+					backoff := calculateBackoff(attempt-1, retryCfg)
 					time.Sleep(backoff)
 				}
 			}
 
+			// 最后一次失败响应尚未写出时，作为最终响应返回
+			if pending != nil {
+				pending.commit()
+				return
+			}
 			// 检查响应是否已写入（避免重复调用 WriteHeader）
 			if _, ok := w.(interface{ Header() http.Header }); ok {
 				if w.Header().Get("Content-Type") == "" {
@@ -385,9 +585,15 @@ func (p *Server) handler() http.Handler {
 			http.Error(w, "no active upstream node", http.StatusServiceUnavailable)
 			return
 		}
+		assignRequestID(w, r)
+		ctx, span := p.tracer.Start(tracing.Extract(r.Context(), r.Header), "proxy.passthrough", tracing.SpanKindServer)
+		defer span.End()
+		span.SetAttr("http.method", r.Method)
+		span.SetAttr("node.id", node.ID)
+		tracing.Inject(ctx, r.Header)
 		// 透传代理：不记录指标，不处理失败
 		proxy := p.newPassthroughProxy(node)
-		proxy.ServeHTTP(w, r)
+		proxy.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
@@ -406,10 +612,14 @@ func (p *Server) requireSession(next http.HandlerFunc) http.HandlerFunc {
 			strings.HasPrefix(r.URL.Path, "/api/accounts/") ||
 			strings.HasPrefix(r.URL.Path, "/api/metrics/") ||
 			strings.HasPrefix(r.URL.Path, "/api/monitor/") ||
+			strings.HasPrefix(r.URL.Path, "/api/uptime") ||
 			strings.HasPrefix(r.URL.Path, "/api/settings") ||
 			strings.HasPrefix(r.URL.Path, "/api/claude-config/") ||
 			strings.HasPrefix(r.URL.Path, "/api/pricing") ||
 			strings.HasPrefix(r.URL.Path, "/api/usage/") ||
+			r.URL.Path == "/api/request-logs" ||
+			strings.HasPrefix(r.URL.Path, "/api/export/") ||
+			strings.HasPrefix(r.URL.Path, "/api/statements") ||
 			strings.HasPrefix(r.URL.Path, "/api/envvars")
 
 		cookie, err := r.Cookie("session_token")
@@ -454,7 +664,7 @@ func (p *Server) requireSession(next http.HandlerFunc) http.HandlerFunc {
 }
 
 func extractUpstreamStatus(mw *metricsWriter) int {
-	if mw == nil {
+	if mw == nil || mw.ResponseWriter == nil {
 		return 0
 	}
 	if us := mw.Header().Get("X-Upstream-Status"); us != "" {
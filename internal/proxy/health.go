@@ -12,9 +12,11 @@ import (
 	"strings"
 	"time"
 
+	"qcc_plus/internal/logging"
 	"qcc_plus/internal/notify"
 	"qcc_plus/internal/store"
 	"qcc_plus/internal/timeutil"
+	"qcc_plus/internal/tracing"
 )
 
 const (
@@ -64,11 +66,11 @@ func (p *Server) shouldFail(nodeID, errMsg string) bool {
 	p.mu.Unlock()
 
 	if failStreak < failLimit {
-		p.logger.Printf("[health] node %s failed (%d/%d): %s", nodeName, failStreak, failLimit, errMsg)
+		p.logger.Component("health").Warn("node failed", logging.KeyNodeID, nodeID, "node", nodeName, "fail_streak", failStreak, "fail_limit", failLimit, logging.KeyError, errMsg)
 		return false
 	}
 
-	p.logger.Printf("[health] node %s reached failure threshold (%d/%d), switching...", nodeName, failStreak, failLimit)
+	p.logger.Component("health").Warn("node reached failure threshold, switching", logging.KeyNodeID, nodeID, "node", nodeName, "fail_streak", failStreak, "fail_limit", failLimit)
 	return true
 }
 
@@ -84,6 +86,7 @@ func (p *Server) handleFailure(nodeID string, errMsg string) {
 		return
 	}
 	acc := p.nodeAccount[nodeID]
+	wasFailed := node.Failed
 	node.LastError = errMsg
 	if node.Metrics.FailStreak == 0 {
 		node.Metrics.FailStreak = 1
@@ -106,8 +109,12 @@ func (p *Server) handleFailure(nodeID string, errMsg string) {
 		// 同步持久化，确保状态一致
 		_ = p.store.UpsertNode(context.Background(), rec)
 	}
+	p.publishNodeState(nodeID)
+	if !wasFailed && acc != nil {
+		p.recordNodeEvent(acc.ID, nodeID, store.NodeEventFailed, "", errMsg)
+	}
 
-	p.logger.Printf("node %s marked failed (fail_streak=%d, fail_limit=%d): %s", nodeName, failStreak, failLimit, errMsg)
+	p.logger.Component("health").Error("node marked failed", logging.KeyNodeID, nodeID, "node", nodeName, "fail_streak", failStreak, "fail_limit", failLimit, logging.KeyError, errMsg)
 	if p.notifyMgr != nil && acc != nil {
 		p.notifyMgr.Publish(notify.Event{
 			AccountID:  acc.ID,
@@ -222,7 +229,7 @@ func (p *Server) checkNodeHealth(acc *Account, id string, source string) {
 	method := normalizeHealthCheckMethod(nodeCopy.HealthCheckMethod)
 	if method == HealthCheckMethodCLI && nodeCopy.APIKey == "" {
 		// CLI 需要 API Key，缺失时自动降级为 HEAD，避免探活失败卡死。
-		p.logger.Printf("health check mode cli requires api key, fallback to head for node %s", nodeCopy.Name)
+		p.logger.Component("health").Warn("health check mode cli requires api key, fallback to head", logging.KeyAccountID, acc.ID, logging.KeyNodeID, nodeCopy.ID, "node", nodeCopy.Name)
 		method = HealthCheckMethodHEAD
 	}
 
@@ -234,6 +241,12 @@ func (p *Server) checkNodeHealth(acc *Account, id string, source string) {
 
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
+	ctx, span := p.tracer.Start(ctx, "health.check", tracing.SpanKindInternal)
+	defer span.End()
+	span.SetAttr("account.id", nodeCopy.AccountID)
+	span.SetAttr("node.id", nodeCopy.ID)
+	span.SetAttr("health.method", method)
+	span.SetAttr("health.source", source)
 
 	var (
 		ok      bool
@@ -253,7 +266,20 @@ func (p *Server) checkNodeHealth(acc *Account, id string, source string) {
 		ok, pingErr, latency = p.healthCheckViaAPI(ctx, nodeCopy)
 	}
 	checkedAt := time.Now().UTC()
-	p.recordHealthEvent(nodeCopy.AccountID, nodeCopy.ID, method, source, ok, latency, pingErr, checkedAt)
+	var category string
+	if !ok {
+		category = p.classifyErrorMessage(pingErr)
+		span.SetAttr("error.category", category)
+		span.SetStatusError(pingErr)
+	}
+	span.SetAttr("health.latency_ms", latency.Milliseconds())
+	p.recordHealthEvent(nodeCopy.AccountID, nodeCopy.ID, method, source, ok, latency, pingErr, category, checkedAt)
+	// 仅 API 探活携带节点 Key，HEAD 探活的 401/403 不代表 Key 失效
+	if !ok && method == HealthCheckMethodAPI && p.errorPolicy(category).DisableNode {
+		p.handleAuthFailure(nodeCopy.ID, pingErr)
+	} else if ok && method == HealthCheckMethodAPI {
+		p.resetAuthFailures(nodeCopy.ID)
+	}
 
 	var (
 		rec           store.NodeRecord
@@ -264,12 +290,9 @@ func (p *Server) checkNodeHealth(acc *Account, id string, source string) {
 		hasNode      bool
 		wasFailed    bool
 		activeID     string
-		activeWeight int
+		outranks     = true // 是否优于当前活跃节点，无活跃节点时视为更优
 	)
 
-	// 默认使用最大 int 作为哨兵值，表示“无活跃节点”
-	activeWeight = int(^uint(0) >> 1)
-
 	p.mu.Lock()
 	n := p.nodeIndex[id]
 	if n != nil {
@@ -277,7 +300,7 @@ func (p *Server) checkNodeHealth(acc *Account, id string, source string) {
 		if acc != nil {
 			activeID = acc.ActiveID
 			if active := acc.Nodes[activeID]; active != nil {
-				activeWeight = active.Weight
+				outranks = p.compareNodes(n, active) < 0
 			}
 		}
 		wasFailed = n.Failed
@@ -293,10 +316,10 @@ func (p *Server) checkNodeHealth(acc *Account, id string, source string) {
 			if acc != nil {
 				delete(acc.FailedSet, id)
 			}
-			// 恢复后同步清理熔断器状态，避免 Open/Half-Open 残留
+			// 恢复后同步清理熔断器状态，避免 Open/Half-Open 残留；手动熔断保持不变
 			if p.cbConfig.Enabled {
 				if cb := p.getOrCreateCircuitBreaker(id); cb != nil {
-					cb.Reset()
+					cb.ResetUnlessForced(CBReasonNodeRecovered)
 				}
 			}
 			if p.store != nil {
@@ -357,13 +380,22 @@ func (p *Server) checkNodeHealth(acc *Account, id string, source string) {
 	p.mu.Unlock()
 
 	if !ok && hasNode {
-		p.logger.Printf("health check failed for node %s: %s", nodeName, pingErr)
+		p.logger.Component("health").Warn("health check failed", logging.KeyAccountID, acc.ID, logging.KeyNodeID, id, "node", nodeName, logging.KeyError, pingErr)
 	}
 	if shouldPersist {
 		_ = p.store.UpsertNode(context.Background(), rec)
 	}
+	// 仅在故障/恢复状态切换时广播，避免每次探活都产生集群事件
+	if hasNode && ((ok && wasFailed) || (!ok && !wasFailed)) {
+		p.publishNodeState(id)
+		if ok {
+			p.recordNodeEvent(acc.ID, id, store.NodeEventRecovered, "", "")
+		} else {
+			p.recordNodeEvent(acc.ID, id, store.NodeEventFailed, "", pingErr)
+		}
+	}
 	shouldPromote := ok && n != nil && !nodeDisabled &&
-		(wasFailed || activeID == "" || n.Weight < activeWeight)
+		(wasFailed || activeID == "" || outranks)
 
 	if ok && wasFailed && !isWarmup {
 		// 恢复后重新在健康节点中选择最优的一个。
@@ -413,7 +445,7 @@ func (p *Server) maybePromoteRecovered(n *Node) {
 	}
 
 	if best.ID != prevActive {
-		p.logger.Printf("auto-switch to recovered node %s (weight %d)", best.Name, best.Weight)
+		p.logger.Component("health").Info("auto-switch to recovered node", logging.KeyAccountID, acc.ID, logging.KeyNodeID, best.ID, "node", best.Name, "weight", best.Weight)
 	}
 }
 
@@ -524,7 +556,7 @@ func (p *Server) healthCheckViaCLI(ctx context.Context, node Node) (bool, string
 	return true, "", latency
 }
 
-func (p *Server) recordHealthEvent(accountID, nodeID, method, source string, success bool, latency time.Duration, errMsg string, checkTime time.Time) {
+func (p *Server) recordHealthEvent(accountID, nodeID, method, source string, success bool, latency time.Duration, errMsg, category string, checkTime time.Time) {
 	if p == nil {
 		return
 	}
@@ -541,6 +573,10 @@ func (p *Server) recordHealthEvent(accountID, nodeID, method, source string, suc
 	if respMs < 0 {
 		respMs = 0
 	}
+	if method != HealthCheckMethodProxy {
+		p.prom.observeProbe(accountID, nodeID, method, latency)
+		p.emitProbePoint(accountID, nodeID, method, source, success, latency, category, checkTime)
+	}
 
 	rec := store.HealthCheckRecord{
 		AccountID:      accountID,
@@ -549,6 +585,7 @@ func (p *Server) recordHealthEvent(accountID, nodeID, method, source string, suc
 		Success:        success,
 		ResponseTimeMs: respMs,
 		ErrorMessage:   errMsg,
+		ErrorCategory:  category,
 		CheckMethod:    method,
 		CheckSource:    source,
 	}
@@ -587,8 +624,10 @@ func (p *Server) recordHealthEvent(accountID, nodeID, method, source string, suc
 		}
 		if success {
 			n.Metrics.LastPingErr = ""
+			n.Metrics.LastErrorCategory = ""
 		} else {
 			n.Metrics.LastPingErr = errMsg
+			n.Metrics.LastErrorCategory = category
 		}
 		metricsSnap = n.Metrics
 		acc = p.nodeAccount[nodeID]
@@ -603,6 +642,7 @@ func (p *Server) recordHealthEvent(accountID, nodeID, method, source string, suc
 			"success":          success,
 			"response_time_ms": respMs,
 			"error_message":    errMsg,
+			"error_category":   category,
 			"check_method":     method,
 			"check_source":     source,
 		}
@@ -659,9 +699,7 @@ func (p *Server) shouldInsertHealthRecord(ctx context.Context, accountID, nodeID
 
 	last, err := p.store.LatestHealthCheck(ctx, accountID, nodeID)
 	if err != nil {
-		if p.logger != nil {
-			p.logger.Printf("health history lookup failed for node %s: %v", nodeID, err)
-		}
+		p.logger.Component("health").Warn("health history lookup failed", logging.KeyAccountID, accountID, logging.KeyNodeID, nodeID, logging.KeyError, err)
 		return true // 出错时保守写入，避免数据缺失
 	}
 	if last == nil {
@@ -1,10 +1,11 @@
 package proxy
 
 import (
-	"log"
 	"runtime"
 	"sync"
 	"time"
+
+	"qcc_plus/internal/logging"
 )
 
 const (
@@ -18,7 +19,7 @@ const (
 // HealthScheduler 定期探活所有节点（包括健康节点），避免状态盲区。
 type HealthScheduler struct {
 	server     *Server
-	logger     *log.Logger
+	logger     *logging.Logger
 	stopCh     chan struct{}
 	wg         sync.WaitGroup
 	interval   time.Duration
@@ -28,9 +29,9 @@ type HealthScheduler struct {
 }
 
 // NewHealthScheduler 创建全量健康检查调度器。
-func NewHealthScheduler(server *Server, interval time.Duration, workers int, cliWorkers int, logger *log.Logger) *HealthScheduler {
+func NewHealthScheduler(server *Server, interval time.Duration, workers int, cliWorkers int, logger *logging.Logger) *HealthScheduler {
 	if logger == nil {
-		logger = log.Default()
+		logger = logging.Default()
 	}
 	if interval <= 0 {
 		interval = defaultHealthAllInterval
@@ -39,7 +40,7 @@ func NewHealthScheduler(server *Server, interval time.Duration, workers int, cli
 	cliWorkers = normalizeCLIHealthCheckWorkers(cliWorkers, workers, logger)
 	return &HealthScheduler{
 		server:     server,
-		logger:     logger,
+		logger:     logger.Component("health"),
 		stopCh:     make(chan struct{}),
 		interval:   interval,
 		workers:    workers,
@@ -60,7 +61,7 @@ func (h *HealthScheduler) Interval() time.Duration {
 //  1. 默认值 fallback 到 defaultHealthCheckConcurrency（2）。
 //  2. 上限 = min(4, runtime.NumCPU()*2)。在 2C 机器上最大 4，默认 2；在 1C 上最大 2。
 //  3. 低于 1 时修正为 1。
-func normalizeHealthCheckWorkers(workers int, logger *log.Logger) int {
+func normalizeHealthCheckWorkers(workers int, logger *logging.Logger) int {
 	if workers <= 0 {
 		workers = defaultHealthCheckConcurrency
 	}
@@ -75,7 +76,7 @@ func normalizeHealthCheckWorkers(workers int, logger *log.Logger) int {
 
 	if workers > max {
 		if logger != nil {
-			logger.Printf("[HealthScheduler] reduce health check concurrency from %d to %d to protect low-resource host", workers, max)
+			logger.Warn("reduce health check concurrency to protect low-resource host", "from", workers, "to", max)
 		}
 		workers = max
 	}
@@ -87,7 +88,7 @@ func normalizeHealthCheckWorkers(workers int, logger *log.Logger) int {
 }
 
 // normalizeCLIHealthCheckWorkers 将 CLI 并发限制在 [1, workers]，默认更保守（1）。
-func normalizeCLIHealthCheckWorkers(workers int, overall int, logger *log.Logger) int {
+func normalizeCLIHealthCheckWorkers(workers int, overall int, logger *logging.Logger) int {
 	if workers <= 0 {
 		workers = defaultCLIHealthCheckConcurrency
 	}
@@ -112,7 +113,7 @@ func (h *HealthScheduler) Start() error {
 		return nil
 	}
 
-	h.logger.Printf("[HealthScheduler] start full health checks every %v", h.interval)
+	h.logger.Info("start full health checks", "interval", h.interval)
 	h.wg.Add(1)
 	go h.checkLoop()
 	return nil
@@ -136,7 +137,7 @@ func (h *HealthScheduler) Stop() {
 	select {
 	case <-done:
 	case <-time.After(30 * time.Second):
-		h.logger.Printf("[HealthScheduler] stop timeout, exiting forcefully")
+		h.logger.Warn("health scheduler stop timeout, exiting forcefully")
 	}
 }
 
@@ -168,10 +169,14 @@ func (h *HealthScheduler) checkAllNodes() {
 		return
 	}
 
-	start := time.Now()
-	h.logger.Printf("[HealthScheduler] checking all nodes...")
-
 	p := h.server
+	if !p.isLeader() {
+		h.logger.Debug("not leader, skip full health check")
+		return
+	}
+
+	start := time.Now()
+	h.logger.Debug("checking all nodes")
 
 	// 检查是否跳过禁用节点
 	skipDisabled := true // 默认跳过
@@ -209,10 +214,10 @@ func (h *HealthScheduler) checkAllNodes() {
 	}
 
 	total := len(tasks)
-	h.logger.Printf("[HealthScheduler] total nodes to check: %d (concurrency=%d, cli_concurrency=%d)", total, h.workers, h.cliWorkers)
+	h.logger.Debug("full health check started", "nodes", total, "concurrency", h.workers, "cli_concurrency", h.cliWorkers)
 
 	if total == 0 {
-		h.logger.Printf("[HealthScheduler] full health check finished in %v (nodes=%d)", time.Since(start), total)
+		h.logger.Info("full health check finished", "duration", time.Since(start), "nodes", total)
 		return
 	}
 
@@ -229,7 +234,7 @@ func (h *HealthScheduler) checkAllNodes() {
 			defer wg.Done()
 			defer func() {
 				if r := recover(); r != nil {
-					h.logger.Printf("[HealthScheduler] panic in health check: %v", r)
+					h.logger.Error("panic in health check", "panic", r)
 				}
 			}()
 
@@ -271,12 +276,12 @@ func (h *HealthScheduler) checkAllNodes() {
 	}
 
 	wg.Wait()
-	h.logger.Printf("[HealthScheduler] full health check finished in %v (nodes=%d)", time.Since(start), total)
+	h.logger.Info("full health check finished", "duration", time.Since(start), "nodes", total)
 }
 
 // recoverPanic 防止调度器因 panic 退出。
 func (h *HealthScheduler) recoverPanic(where string) {
 	if r := recover(); r != nil {
-		h.logger.Printf("[HealthScheduler] panic recovered in %s: %v", where, r)
+		h.logger.Error("panic recovered", "where", where, "panic", r)
 	}
 }
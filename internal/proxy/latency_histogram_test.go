@@ -0,0 +1,84 @@
+package proxy
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"qcc_plus/internal/store"
+)
+
+// TestLatencyHistogramQuantile 测试分位数在桶内插值
+func TestLatencyHistogramQuantile(t *testing.T) {
+	var h store.LatencyHistogram
+	if h.Quantile(0.5) != 0 {
+		t.Fatalf("empty histogram should return 0")
+	}
+	for i := 0; i < 90; i++ {
+		h.Observe(80) // (50,100]
+	}
+	for i := 0; i < 10; i++ {
+		h.Observe(400000) // 溢出桶
+	}
+	if got := h.Quantile(0.5); math.Abs(got-(50+50*50.0/90)) > 0.01 {
+		t.Fatalf("unexpected p50 %v", got)
+	}
+	if got := h.Quantile(0.99); got != 120000 {
+		t.Fatalf("overflow bucket should report last bound, got %v", got)
+	}
+}
+
+// TestAggregateMetricsMergesHistograms 测试原始 -> 小时 -> 天聚合后分位数保持正确
+func TestAggregateMetricsMergesHistograms(t *testing.T) {
+	st, err := store.OpenSQLite(filepath.Join(t.TempDir(), "metrics.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer st.Close()
+
+	ctx := context.Background()
+	day := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 100; i++ {
+		start := day.Add(time.Duration(i%3) * time.Hour)
+		latency := 90 * time.Millisecond
+		if i >= 95 {
+			latency = 4 * time.Second
+		}
+		mw := &metricsWriter{status: http.StatusOK, firstWrite: true, firstAt: start.Add(latency / 2), lastAt: start.Add(latency)}
+		rec := buildMetricsRecord("acc", "n1", "", start, start.Add(latency), mw, nil, 0, 0)
+		if err := st.InsertMetrics(ctx, *rec); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+	if err := st.AggregateMetrics(ctx, "", store.MetricsGranularityHourly, day, day.Add(24*time.Hour)); err != nil {
+		t.Fatalf("aggregate hourly: %v", err)
+	}
+	if err := st.AggregateMetrics(ctx, "", store.MetricsGranularityDaily, day, day.Add(24*time.Hour)); err != nil {
+		t.Fatalf("aggregate daily: %v", err)
+	}
+	recs, err := st.QueryMetrics(ctx, store.MetricsQuery{AccountID: "acc", NodeID: "n1", Granularity: store.MetricsGranularityDaily, From: day, To: day.Add(24 * time.Hour)})
+	if err != nil || len(recs) != 1 {
+		t.Fatalf("query daily: %v (%d rows)", err, len(recs))
+	}
+	rec := recs[0]
+	if rec.ResponseTimeHist.Count() != 100 || rec.FirstByteHist.Count() != 100 {
+		t.Fatalf("unexpected histogram counts: %v", rec.ResponseTimeHist)
+	}
+	if p50 := rec.ResponseTimeHist.Quantile(0.5); p50 <= 50 || p50 > 100 {
+		t.Fatalf("unexpected p50 %v", p50)
+	}
+	if p99 := rec.ResponseTimeHist.Quantile(0.99); p99 <= 3000 || p99 > 5000 {
+		t.Fatalf("unexpected p99 %v", p99)
+	}
+
+	item := map[string]interface{}{}
+	addLatencyPercentiles(item, rec)
+	for _, key := range []string{"p50_response_time_ms", "p90_response_time_ms", "p95_first_byte_ms", "p99_first_byte_ms"} {
+		if _, ok := item[key]; !ok {
+			t.Errorf("missing %s", key)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package proxy
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"qcc_plus/internal/logging"
+	"qcc_plus/internal/store"
+	"qcc_plus/internal/timeutil"
+)
+
+const (
+	// schedulerLeaseName 单例任务（全量健康检查、指标聚合/清理、预热）共用的租约名。
+	schedulerLeaseName = "scheduler"
+
+	defaultLeaderLeaseTTL = 30 * time.Second
+)
+
+// LeaderConfig 选主配置。
+type LeaderConfig struct {
+	Enabled    bool
+	InstanceID string
+	LeaseTTL   time.Duration
+}
+
+// loadLeaderConfig 从环境变量读取选主配置。
+func loadLeaderConfig(logger *logging.Logger) LeaderConfig {
+	cfg := LeaderConfig{
+		Enabled:    parseEnvBool("LEADER_ELECTION_ENABLED", true, logger),
+		InstanceID: os.Getenv("INSTANCE_ID"),
+		LeaseTTL:   parseEnvDuration("LEADER_LEASE_TTL", defaultLeaderLeaseTTL, logger),
+	}
+	if cfg.LeaseTTL < 3*time.Second {
+		if logger != nil {
+			logger.Warn("LEADER_LEASE_TTL too small, using default", "value", cfg.LeaseTTL, "default", defaultLeaderLeaseTTL)
+		}
+		cfg.LeaseTTL = defaultLeaderLeaseTTL
+	}
+	if cfg.InstanceID == "" {
+		cfg.InstanceID = defaultInstanceID()
+	}
+	return cfg
+}
+
+// defaultInstanceID 生成 hostname-pid-随机后缀 形式的实例标识。
+func defaultInstanceID() string {
+	host, _ := os.Hostname()
+	if host == "" {
+		host = "qcc"
+	}
+	b := make([]byte, 3)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(b))
+}
+
+// LeaderElector 基于数据库租约的选主器：持有租约的实例运行单例任务，
+// 每 TTL/3 续约一次，主实例退出或失联后其他实例在租约过期时自动接管。
+type LeaderElector struct {
+	store  *store.Store
+	logger *logging.Logger
+	name   string
+	id     string
+	ttl    time.Duration
+
+	started  atomic.Bool
+	leader   atomic.Bool
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewLeaderElector 创建选主器；store 为空时视为单实例，始终为主。
+func NewLeaderElector(s *store.Store, cfg LeaderConfig, logger *logging.Logger) *LeaderElector {
+	if logger == nil {
+		logger = logging.Default()
+	}
+	ttl := cfg.LeaseTTL
+	if ttl <= 0 {
+		ttl = defaultLeaderLeaseTTL
+	}
+	id := cfg.InstanceID
+	if id == "" {
+		id = defaultInstanceID()
+	}
+	return &LeaderElector{
+		store:  s,
+		logger: logger.Component("leader"),
+		name:   schedulerLeaseName,
+		id:     id,
+		ttl:    ttl,
+		stopCh: make(chan struct{}),
+	}
+}
+
+// IsLeader 返回当前实例是否持有租约；未启用或尚未启动选主时按单实例处理，始终返回 true。
+func (e *LeaderElector) IsLeader() bool {
+	if e == nil || e.store == nil || !e.started.Load() {
+		return true
+	}
+	return e.leader.Load()
+}
+
+// InstanceID 返回当前实例标识。
+func (e *LeaderElector) InstanceID() string {
+	if e == nil {
+		return ""
+	}
+	return e.id
+}
+
+// Start 立即尝试获取租约并启动续约循环。
+func (e *LeaderElector) Start() {
+	if e == nil || e.store == nil {
+		return
+	}
+	e.started.Store(true)
+	e.tick()
+	e.wg.Add(1)
+	go e.loop()
+}
+
+// Stop 停止续约并释放租约，便于其他实例立即接管。
+func (e *LeaderElector) Stop() {
+	if e == nil || e.store == nil {
+		return
+	}
+	e.stopOnce.Do(func() {
+		close(e.stopCh)
+	})
+	e.wg.Wait()
+	if e.leader.Swap(false) {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		if err := e.store.ReleaseLease(ctx, e.name, e.id); err != nil {
+			e.logger.Warn("release lease failed", logging.KeyError, err)
+		}
+	}
+}
+
+func (e *LeaderElector) loop() {
+	defer e.wg.Done()
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.stopCh:
+			return
+		case <-ticker.C:
+			e.tick()
+		}
+	}
+}
+
+func (e *LeaderElector) tick() {
+	ctx, cancel := context.WithTimeout(context.Background(), e.ttl/3)
+	defer cancel()
+	ok, err := e.store.TryAcquireLease(ctx, e.name, e.id, e.ttl)
+	if err != nil {
+		// 续约失败时保守降级，避免与新主实例同时运行单例任务。
+		e.logger.Warn("lease heartbeat failed", logging.KeyError, err)
+		ok = false
+	}
+	was := e.leader.Swap(ok)
+	switch {
+	case ok && !was:
+		e.logger.Info("acquired lease", "instance", e.id, "lease", e.name, "ttl", e.ttl)
+	case !ok && was:
+		e.logger.Warn("lost lease", "instance", e.id, "lease", e.name)
+	}
+}
+
+// Status 返回选主状态（供管理 API 使用）。
+func (e *LeaderElector) Status(ctx context.Context) map[string]interface{} {
+	status := map[string]interface{}{
+		"enabled":     e != nil && e.store != nil,
+		"instance_id": e.InstanceID(),
+		"is_leader":   e.IsLeader(),
+		"leader":      nil,
+	}
+	if e == nil || e.store == nil {
+		return status
+	}
+	status["lease_ttl_sec"] = int(e.ttl.Seconds())
+	rec, err := e.store.GetLease(ctx, e.name)
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			status["error"] = err.Error()
+		}
+		return status
+	}
+	status["leader"] = map[string]interface{}{
+		"holder":      rec.Holder,
+		"acquired_at": timeutil.FormatBeijingTime(rec.AcquiredAt),
+		"renewed_at":  timeutil.FormatBeijingTime(rec.RenewedAt),
+		"expires_at":  timeutil.FormatBeijingTime(rec.ExpiresAt),
+		"expired":     time.Now().UTC().After(rec.ExpiresAt),
+	}
+	return status
+}
@@ -0,0 +1,43 @@
+package proxy
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"qcc_plus/internal/store"
+)
+
+// TestLeaderElectorTakeover 测试租约互斥与主实例退出后的接管
+func TestLeaderElectorTakeover(t *testing.T) {
+	st, err := store.OpenSQLite(filepath.Join(t.TempDir(), "leader.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer st.Close()
+
+	a := NewLeaderElector(st, LeaderConfig{InstanceID: "a", LeaseTTL: 3 * time.Second}, nil)
+	b := NewLeaderElector(st, LeaderConfig{InstanceID: "b", LeaseTTL: 3 * time.Second}, nil)
+
+	if !b.IsLeader() {
+		t.Fatalf("elector that has not started should behave as single instance")
+	}
+
+	a.Start()
+	b.Start()
+	defer b.Stop()
+
+	if !a.IsLeader() {
+		t.Fatalf("expected a to acquire lease first")
+	}
+	if b.IsLeader() {
+		t.Fatalf("expected b to stay follower while a holds the lease")
+	}
+
+	// a 主动释放后，b 下一次心跳即可接管。
+	a.Stop()
+	b.tick()
+	if !b.IsLeader() {
+		t.Fatalf("expected b to take over after a released the lease")
+	}
+}
@@ -0,0 +1,65 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"qcc_plus/internal/logging"
+)
+
+// loggingLevelsKey 按组件设置日志级别的配置项，如 {"default":"info","health":"debug"}。
+const loggingLevelsKey = "logging.levels"
+
+// requestLogger 返回附带请求 ID、账号与节点字段的 proxy 组件日志器。
+// node 为空时取请求上下文中的节点；调用方无需再重复传入 node_id。
+func (p *Server) requestLogger(r *http.Request, node *Node) *logging.Logger {
+	var args []any
+	if r != nil {
+		if id := r.Header.Get(requestIDHeader); id != "" {
+			args = append(args, logging.KeyRequestID, id)
+		}
+		if acc := accountFromCtx(r); acc != nil {
+			args = append(args, logging.KeyAccountID, acc.ID)
+		}
+		if node == nil {
+			node = nodeFromCtx(r)
+		}
+	}
+	if node != nil {
+		args = append(args, logging.KeyNodeID, node.ID)
+	}
+	return p.logger.Component("proxy").With(args...)
+}
+
+// parseLoggingLevels 将配置值解析为组件 -> 级别映射。
+func parseLoggingLevels(value any) (map[string]string, error) {
+	if value == nil {
+		return map[string]string{}, nil
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var levels map[string]string
+	if err := json.Unmarshal(raw, &levels); err != nil {
+		return nil, fmt.Errorf("%s must be an object of component to level", loggingLevelsKey)
+	}
+	for name, lvl := range levels {
+		if _, err := logging.ParseLevel(lvl); err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", loggingLevelsKey, name, err)
+		}
+	}
+	return levels, nil
+}
+
+// applyLoggingLevels 应用按组件的日志级别配置。
+func (p *Server) applyLoggingLevels(value any) {
+	levels, err := parseLoggingLevels(value)
+	if err == nil {
+		err = p.logger.SetLevels(levels)
+	}
+	if err != nil {
+		p.logger.Warn("invalid logging levels setting", logging.KeyError, err)
+	}
+}
@@ -0,0 +1,43 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"qcc_plus/internal/logging"
+)
+
+// TestRequestLoggerNodeField 测试请求日志器只输出一个 node_id：显式传入的节点优先于上下文中的节点
+func TestRequestLoggerNodeField(t *testing.T) {
+	var buf bytes.Buffer
+	srv := newClusterTestServer(t, NewLocalClusterBus(), "a")
+	srv.logger = logging.New(logging.Config{Format: logging.FormatJSON, Output: &buf})
+
+	req := httptest.NewRequest("POST", "/v1/messages", nil)
+	req.Header.Set(requestIDHeader, "req-1")
+	req = req.WithContext(context.WithValue(req.Context(), nodeContextKey{}, srv.getNode("n1")))
+
+	srv.requestLogger(req, srv.getNode("n2")).Warn("proxy error")
+	srv.requestLogger(req, nil).Warn("proxy error")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected two log lines, got %q", buf.String())
+	}
+	for i, want := range []string{"n2", "n1"} {
+		if n := strings.Count(lines[i], `"node_id"`); n != 1 {
+			t.Fatalf("expected one node_id field, got %d in %s", n, lines[i])
+		}
+		var rec map[string]any
+		if err := json.Unmarshal([]byte(lines[i]), &rec); err != nil {
+			t.Fatalf("decode log line: %v", err)
+		}
+		if rec["node_id"] != want || rec["request_id"] != "req-1" || rec["component"] != "proxy" {
+			t.Fatalf("unexpected log fields %v", rec)
+		}
+	}
+}
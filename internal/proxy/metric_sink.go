@@ -0,0 +1,174 @@
+package proxy
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"qcc_plus/internal/logging"
+	"qcc_plus/internal/metricsink"
+)
+
+// loadMetricSinks 根据环境变量创建推送式指标后端，未配置任何后端时返回 nil。
+func loadMetricSinks(logger *logging.Logger) *metricsink.Emitter {
+	sinkLog := logger.Component("metric_sink")
+	prefix := strings.TrimSpace(os.Getenv("METRICS_SINK_PREFIX"))
+	if prefix == "" {
+		prefix = "qcc_plus"
+	}
+	timeout := parseEnvDuration("METRICS_SINK_TIMEOUT", 10*time.Second, logger)
+
+	var sinks []metricsink.Sink
+	if addr := strings.TrimSpace(os.Getenv("METRICS_SINK_STATSD_ADDR")); addr != "" {
+		sink, err := metricsink.NewStatsD(addr, prefix, strings.ToLower(strings.TrimSpace(os.Getenv("METRICS_SINK_STATSD_TAGS"))))
+		if err != nil {
+			sinkLog.Warn("statsd sink disabled", logging.KeyError, err)
+		} else {
+			sinks = append(sinks, sink)
+		}
+	}
+	if writeURL := strings.TrimSpace(os.Getenv("METRICS_SINK_INFLUX_URL")); writeURL != "" {
+		sink, err := metricsink.NewInflux(writeURL, strings.TrimSpace(os.Getenv("METRICS_SINK_INFLUX_TOKEN")), prefix, timeout)
+		if err != nil {
+			sinkLog.Warn("influxdb sink disabled", logging.KeyError, err)
+		} else {
+			sinks = append(sinks, sink)
+		}
+	}
+	if len(sinks) == 0 {
+		return nil
+	}
+	cfg := metricsink.Config{
+		QueueSize:     parseEnvInt("METRICS_SINK_QUEUE_SIZE", 10000, logger),
+		BatchSize:     parseEnvInt("METRICS_SINK_BATCH_SIZE", 500, logger),
+		FlushInterval: parseEnvDuration("METRICS_SINK_FLUSH_INTERVAL", 5*time.Second, logger),
+		Timeout:       timeout,
+	}
+	for _, s := range sinks {
+		sinkLog.Info("pushing metrics", "sink", s.Type(), "target", s.Target())
+	}
+	return metricsink.New(cfg, sinks, sinkLog)
+}
+
+// emitRequestPoint 推送一次上游尝试，与 recordMetrics 写入的监控记录口径一致。
+func (p *Server) emitRequestPoint(accountID, nodeID, model string, start, end time.Time, mw *metricsWriter, u *usage) {
+	if p.sinks == nil {
+		return
+	}
+	status := http.StatusOK
+	var failed, canceled float64
+	fields := make([]metricsink.Field, 0, 10)
+	if mw != nil {
+		status = mw.status
+		if mw.firstWrite {
+			fields = append(fields, metricsink.Field{Name: "first_byte_ms", Value: float64(mw.firstAt.Sub(start).Milliseconds()), Kind: metricsink.Timing})
+			end = mw.lastAt
+		}
+	}
+	switch {
+	case status == 499:
+		canceled = 1
+	case status != http.StatusOK:
+		failed = 1
+	}
+	fields = append(fields,
+		metricsink.Field{Name: "count", Value: 1, Kind: metricsink.Counter},
+		metricsink.Field{Name: "failed", Value: failed, Kind: metricsink.Counter},
+		metricsink.Field{Name: "canceled", Value: canceled, Kind: metricsink.Counter},
+		metricsink.Field{Name: "duration_ms", Value: float64(end.Sub(start).Milliseconds()), Kind: metricsink.Timing},
+	)
+	if mw != nil {
+		fields = append(fields, metricsink.Field{Name: "bytes", Value: float64(mw.bytes), Kind: metricsink.Counter})
+	}
+	if u != nil {
+		fields = append(fields,
+			metricsink.Field{Name: "input_tokens", Value: float64(u.input), Kind: metricsink.Counter},
+			metricsink.Field{Name: "output_tokens", Value: float64(u.output), Kind: metricsink.Counter},
+			metricsink.Field{Name: "cache_creation_tokens", Value: float64(u.cacheCreation), Kind: metricsink.Counter},
+			metricsink.Field{Name: "cache_read_tokens", Value: float64(u.cacheRead), Kind: metricsink.Counter},
+		)
+	}
+	p.sinks.Emit(metricsink.Point{
+		Measurement: "request",
+		Tags: []metricsink.Tag{
+			{Key: "account", Value: accountID},
+			{Key: "node", Value: nodeID},
+			{Key: "model", Value: model},
+			{Key: "status", Value: statusClass(status)},
+		},
+		Fields: fields,
+		Time:   end,
+	})
+}
+
+// emitProbePoint 推送一次主动探活结果。
+func (p *Server) emitProbePoint(accountID, nodeID, method, source string, success bool, latency time.Duration, category string, checkTime time.Time) {
+	if p.sinks == nil {
+		return
+	}
+	result, failed := "success", 0.0
+	if !success {
+		result, failed = "failure", 1
+	}
+	p.sinks.Emit(metricsink.Point{
+		Measurement: "probe",
+		Tags: []metricsink.Tag{
+			{Key: "account", Value: accountID},
+			{Key: "node", Value: nodeID},
+			{Key: "method", Value: method},
+			{Key: "source", Value: source},
+			{Key: "result", Value: result},
+			{Key: "error_category", Value: category},
+		},
+		Fields: []metricsink.Field{
+			{Name: "count", Value: 1, Kind: metricsink.Counter},
+			{Name: "failed", Value: failed, Kind: metricsink.Counter},
+			{Name: "latency_ms", Value: float64(latency.Milliseconds()), Kind: metricsink.Timing},
+		},
+		Time: checkTime,
+	})
+}
+
+// observeRetryBudgetExhausted 记录一次因预算耗尽被拒绝的重试。
+func (p *Server) observeRetryBudgetExhausted(accountID, stage string) {
+	p.prom.observeBudgetExhausted(accountID, stage)
+	if p.sinks == nil {
+		return
+	}
+	accTokens, globalTokens := p.retryBudget.tokens(accountID)
+	p.sinks.Emit(metricsink.Point{
+		Measurement: "retry_budget",
+		Tags: []metricsink.Tag{
+			{Key: "account", Value: accountID},
+			{Key: "stage", Value: stage},
+		},
+		Fields: []metricsink.Field{
+			{Name: "exhausted", Value: 1, Kind: metricsink.Counter},
+			{Name: "account_tokens", Value: accTokens, Kind: metricsink.Gauge},
+			{Name: "global_tokens", Value: globalTokens, Kind: metricsink.Gauge},
+		},
+		Time: time.Now(),
+	})
+}
+
+// GET /admin/api/metric-sinks
+// 返回各推送后端的健康状态、发送与丢弃计数。
+func (p *Server) handleMetricSinks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !isAdmin(r.Context()) {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "forbidden"})
+		return
+	}
+	stats := p.sinks.Stats()
+	if stats == nil {
+		stats = []metricsink.Stats{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"enabled": p.sinks != nil,
+		"sinks":   stats,
+	})
+}
@@ -0,0 +1,98 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"qcc_plus/internal/metricsink"
+)
+
+type captureSink struct {
+	mu     sync.Mutex
+	points []metricsink.Point
+}
+
+func (s *captureSink) Type() string   { return "capture" }
+func (s *captureSink) Target() string { return "memory://" }
+func (s *captureSink) Close() error   { return nil }
+func (s *captureSink) Send(_ context.Context, points []metricsink.Point) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.points = append(s.points, points...)
+	return nil
+}
+
+func pointTag(p metricsink.Point, key string) string {
+	for _, t := range p.Tags {
+		if t.Key == key {
+			return t.Value
+		}
+	}
+	return ""
+}
+
+func pointField(p metricsink.Point, name string) (float64, bool) {
+	for _, f := range p.Fields {
+		if f.Name == name {
+			return f.Value, true
+		}
+	}
+	return 0, false
+}
+
+// TestMetricSinkEmitsRequestAndProbe 测试请求与探活数据点推送及管理接口状态
+func TestMetricSinkEmitsRequestAndProbe(t *testing.T) {
+	srv := newClusterTestServer(t, NewLocalClusterBus(), "a")
+	sink := &captureSink{}
+	srv.sinks = metricsink.New(metricsink.Config{FlushInterval: time.Hour}, []metricsink.Sink{sink}, nil)
+
+	start := time.Now().Add(-300 * time.Millisecond)
+	mw := &metricsWriter{status: http.StatusBadGateway, firstWrite: true, firstAt: start.Add(100 * time.Millisecond), lastAt: start.Add(250 * time.Millisecond), bytes: 42}
+	u := &usage{input: 10, output: 5, modelID: "claude-sonnet-4-5"}
+	srv.recordMetrics(context.Background(), "n1", start, mw, u, 0, 0, true)
+	srv.recordHealthEvent("acc-1", "n2", HealthCheckMethodAPI, CheckSourceScheduled, false, 80*time.Millisecond, "timeout", "timeout", time.Now())
+	srv.recordHealthEvent("acc-1", "n2", HealthCheckMethodProxy, CheckSourceScheduled, true, 0, "", "", time.Now())
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/admin/api/metric-sinks", nil).WithContext(context.WithValue(context.Background(), isAdminContextKey{}, true))
+	srv.handleMetricSinks(rec, req)
+	var resp struct {
+		Enabled bool               `json:"enabled"`
+		Sinks   []metricsink.Stats `json:"sinks"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || !resp.Enabled || len(resp.Sinks) != 1 || resp.Sinks[0].QueueLen != 2 {
+		t.Fatalf("unexpected sink status %v %s", err, rec.Body.String())
+	}
+
+	srv.sinks.Close()
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if len(sink.points) != 2 {
+		t.Fatalf("expected request and probe points (proxy-sourced health skipped), got %d", len(sink.points))
+	}
+	reqPoint, probe := sink.points[0], sink.points[1]
+	if reqPoint.Measurement != "request" || pointTag(reqPoint, "account") != "acc-1" || pointTag(reqPoint, "node") != "n1" ||
+		pointTag(reqPoint, "model") != "claude-sonnet-4-5" || pointTag(reqPoint, "status") != "5xx" {
+		t.Fatalf("unexpected request tags %+v", reqPoint.Tags)
+	}
+	if v, _ := pointField(reqPoint, "failed"); v != 1 {
+		t.Fatalf("expected failed=1, got %v", v)
+	}
+	if v, _ := pointField(reqPoint, "duration_ms"); v != 250 {
+		t.Fatalf("expected duration 250ms, got %v", v)
+	}
+	if v, _ := pointField(reqPoint, "input_tokens"); v != 10 {
+		t.Fatalf("expected input tokens, got %v", v)
+	}
+	if probe.Measurement != "probe" || pointTag(probe, "result") != "failure" || pointTag(probe, "error_category") != "timeout" {
+		t.Fatalf("unexpected probe point %+v", probe)
+	}
+	if v, _ := pointField(probe, "latency_ms"); v != 80 {
+		t.Fatalf("expected probe latency 80, got %v", v)
+	}
+}
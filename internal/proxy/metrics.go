@@ -5,8 +5,11 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
+	"qcc_plus/internal/logging"
 	"qcc_plus/internal/store"
 	"qcc_plus/internal/timeutil"
 )
@@ -47,6 +50,7 @@ func (mw *metricsWriter) Write(b []byte) (int, error) {
 
 func (p *Server) recordMetrics(ctx context.Context, nodeID string, start time.Time, mw *metricsWriter, u *usage, retryAttempts, retrySuccess int64, finalAttempt bool) {
 	end := time.Now()
+	model := p.metricsModel(u)
 	var (
 		nodeRec      store.NodeRecord
 		metricsRec   *store.MetricsRecord
@@ -88,6 +92,8 @@ func (p *Server) recordMetrics(ctx context.Context, nodeID string, start time.Ti
 	if u != nil {
 		node.Metrics.TotalInputTokens += u.input
 		node.Metrics.TotalOutputTokens += u.output
+		node.Metrics.TotalCacheCreationTokens += u.cacheCreation
+		node.Metrics.TotalCacheReadTokens += u.cacheRead
 	}
 	if mw != nil && mw.status != http.StatusOK {
 		node.Metrics.FailCount++
@@ -104,7 +110,7 @@ func (p *Server) recordMetrics(ctx context.Context, nodeID string, start time.Ti
 	}
 	if p.store != nil {
 		nodeRec = toRecord(node)
-		metricsRec = buildMetricsRecord(accountID, nodeID, start, end, mw, u, retryAttempts, retrySuccess)
+		metricsRec = buildMetricsRecord(accountID, nodeID, model, start, end, mw, u, retryAttempts, retrySuccess)
 	}
 	nodeName = node.Name
 	nodeIDCopy = node.ID
@@ -120,33 +126,60 @@ func (p *Server) recordMetrics(ctx context.Context, nodeID string, start time.Ti
 	method = node.HealthCheckMethod
 	p.mu.Unlock()
 
+	p.prom.observeAttempt(accountID, nodeIDCopy, start, end, mw, u)
+	p.emitRequestPoint(accountID, nodeIDCopy, model, start, end, mw, u)
+
 	if p.store != nil {
-		if err := p.store.UpsertNode(ctx, nodeRec); err != nil {
-			p.logger.Printf("[metrics] failed to upsert node %s: %v", nodeIDCopy, err)
+		spanCtx, done := p.dbSpan(ctx, "upsert_node")
+		err := p.store.UpsertNode(spanCtx, nodeRec)
+		done(err)
+		if err != nil {
+			p.logger.Component("metrics").Error("upsert node failed", logging.KeyAccountID, accountID, logging.KeyNodeID, nodeIDCopy, logging.KeyError, err)
 		}
 		if metricsRec != nil {
-			if err := p.store.InsertMetrics(ctx, *metricsRec); err != nil {
-				p.logger.Printf("[metrics] failed to insert metrics for node %s: %v", nodeIDCopy, err)
+			spanCtx, done := p.dbSpan(ctx, "insert_metrics")
+			err := p.store.InsertMetrics(spanCtx, *metricsRec)
+			done(err)
+			if err != nil {
+				p.logger.Component("metrics").Error("insert metrics failed", logging.KeyAccountID, accountID, logging.KeyNodeID, nodeIDCopy, logging.KeyError, err)
 			}
 		}
 		// 记录使用日志（计费）- 仅在最终尝试时记录，避免重试导致重复计费
-		if finalAttempt && u != nil && u.modelID != "" && (u.input > 0 || u.output > 0) {
-			costUSD, err := p.store.CalculateCost(ctx, u.modelID, u.input, u.output)
+		if finalAttempt && u != nil && u.modelID != "" && (u.input > 0 || u.output > 0 || u.cacheCreation > 0 || u.cacheRead > 0) {
+			cost, err := p.store.CalculateCost(ctx, u.modelID, u.tokenUsage(), start)
+			costUSD := cost.CostUSD
 			if err != nil {
-				p.logger.Printf("[metrics] failed to calculate cost for model %s: %v", u.modelID, err)
+				p.logger.Component("metrics").Warn("calculate cost failed", logging.KeyAccountID, accountID, logging.KeyNodeID, nodeIDCopy, "model", u.modelID, logging.KeyError, err)
 			}
+			actualCostUSD := p.nodeCostConfig(nodeIDCopy).ActualCost(u.modelID, u.tokenUsage(), costUSD)
+			p.observeNodeCost(nodeIDCopy, costUSD, actualCostUSD)
+			p.prom.observeCost(accountID, nodeIDCopy, costUSD)
 			usageLog := store.UsageLogRecord{
-				AccountID:    accountID,
-				NodeID:       nodeIDCopy,
-				ModelID:      u.modelID,
-				InputTokens:  u.input,
-				OutputTokens: u.output,
-				CostUSD:      costUSD,
-				Success:      mw == nil || mw.status == http.StatusOK,
-				RequestID:    u.requestID,
+				AccountID:           accountID,
+				NodeID:              nodeIDCopy,
+				ModelID:             u.modelID,
+				InputTokens:         u.input,
+				OutputTokens:        u.output,
+				CacheCreationTokens: u.cacheCreation,
+				CacheReadTokens:     u.cacheRead,
+				CostUSD:             costUSD,
+				ActualCostUSD:       actualCostUSD,
+				PricingRuleID:       cost.PricingRuleID,
+				Unpriced:            cost.Unpriced,
+				Success:             mw == nil || mw.status == http.StatusOK,
+				RequestID:           u.requestID,
+			}
+			if u.trace != nil {
+				usageLog.RequestID = u.trace.RequestID
+				usageLog.UpstreamRequestID = u.requestID
+				usageLog.AttemptTrace = u.trace.attemptsJSON()
+				usageLog.KeyHint = u.trace.audit.keyHint
 			}
-			if err := p.store.InsertUsageLog(ctx, usageLog); err != nil {
-				p.logger.Printf("[metrics] failed to insert usage log for account %s, model %s: %v", accountID, u.modelID, err)
+			spanCtx, done := p.dbSpan(ctx, "insert_usage_log")
+			err = p.store.InsertUsageLog(spanCtx, usageLog)
+			done(err)
+			if err != nil {
+				p.logger.Component("metrics").Error("insert usage log failed", logging.KeyAccountID, accountID, logging.KeyNodeID, nodeIDCopy, "model", u.modelID, logging.KeyError, err)
 			}
 		}
 	}
@@ -211,10 +244,11 @@ func (p *Server) recordMetrics(ctx context.Context, nodeID string, start time.Ti
 	}
 }
 
-func buildMetricsRecord(accountID, nodeID string, start, end time.Time, mw *metricsWriter, u *usage, retryAttempts, retrySuccess int64) *store.MetricsRecord {
+func buildMetricsRecord(accountID, nodeID, modelID string, start, end time.Time, mw *metricsWriter, u *usage, retryAttempts, retrySuccess int64) *store.MetricsRecord {
 	rec := &store.MetricsRecord{
 		AccountID:          accountID,
 		NodeID:             nodeID,
+		ModelID:            modelID,
 		Timestamp:          end.UTC(),
 		RequestsTotal:      1,
 		RequestsSuccess:    1,
@@ -226,35 +260,180 @@ func buildMetricsRecord(accountID, nodeID string, start, end time.Time, mw *metr
 	}
 	if mw != nil {
 		rec.BytesTotal = mw.bytes
-		if mw.status != http.StatusOK {
+		if mw.status == 499 {
+			// 客户端主动断开，单独计数，不算作失败
+			rec.RequestsSuccess = 0
+			rec.Failures.Canceled = 1
+		} else if mw.status != http.StatusOK {
 			rec.RequestsFailed = 1
 			rec.RequestsSuccess = 0
+			status := extractUpstreamStatus(mw)
+			if status == 0 {
+				status = mw.status
+			}
+			var category string
+			if u != nil {
+				category = u.errCategory
+			}
+			rec.Failures.RecordFailure(status, category)
 		}
 		if mw.firstWrite {
 			rec.FirstByteTimeSumMs = mw.firstAt.Sub(start).Milliseconds()
 			rec.StreamDurationSumMs = mw.lastAt.Sub(mw.firstAt).Milliseconds()
 			rec.ResponseTimeSumMs = mw.lastAt.Sub(start).Milliseconds()
+			rec.FirstByteHist.Observe(rec.FirstByteTimeSumMs)
 		}
 	}
+	rec.ResponseTimeHist.Observe(rec.ResponseTimeSumMs)
 	if u != nil {
 		rec.InputTokensTotal = u.input
 		rec.OutputTokensTotal = u.output
+		rec.CacheCreationTokensTotal = u.cacheCreation
+		rec.CacheReadTokensTotal = u.cacheRead
 	}
 	return rec
 }
 
+const (
+	metricsModelOther  = "other" // 未经上游确认或不合法的模型统一归入该桶
+	metricsModelMaxLen = 64
+	maxMetricsModels   = 256
+)
+
+// metricsModels 记录上游接受过的模型名，用于约束监控模型维度的基数。
+// 客户端可在请求体中填写任意模型名，只有被上游成功处理过的模型才作为独立维度。
+type metricsModels struct {
+	mu    sync.RWMutex
+	names map[string]struct{}
+}
+
+// observe 记录上游确认的模型，超过容量时不再接收新模型并返回 false。
+func (m *metricsModels) observe(name string) bool {
+	m.mu.RLock()
+	_, ok := m.names[name]
+	m.mu.RUnlock()
+	if ok {
+		return true
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.names == nil {
+		m.names = make(map[string]struct{})
+	}
+	if len(m.names) >= maxMetricsModels {
+		return false
+	}
+	m.names[name] = struct{}{}
+	return true
+}
+
+func (m *metricsModels) known(name string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.names[name]
+	return ok
+}
+
+// normalizeModelID 统一模型名大小写并校验字符集与长度，不合法时返回空字符串。
+func normalizeModelID(raw string) string {
+	m := strings.ToLower(strings.TrimSpace(raw))
+	if m == "" || len(m) > metricsModelMaxLen {
+		return ""
+	}
+	for _, c := range m {
+		switch {
+		case c >= 'a' && c <= 'z', c >= '0' && c <= '9':
+		case c == '-' || c == '_' || c == '.' || c == ':' || c == '@' || c == '/':
+		default:
+			return ""
+		}
+	}
+	return m
+}
+
+// metricsModel 返回监控与指标推送使用的模型维度：优先使用请求体中的模型，失败请求没有响应 usage
+// 也能归到同一模型下；上游返回 usage 视为接受了该模型。未被上游确认过的模型归入 other。
+func (p *Server) metricsModel(u *usage) string {
+	if u == nil {
+		return ""
+	}
+	raw := ""
+	if u.trace != nil {
+		raw = u.trace.audit.modelID
+	}
+	req := normalizeModelID(raw)
+	if resp := normalizeModelID(u.modelID); resp != "" {
+		if req == "" {
+			req = resp
+		}
+		if p.metricModels.observe(req) {
+			return req
+		}
+		return metricsModelOther
+	}
+	if raw == "" {
+		return ""
+	}
+	if req != "" && p.metricModels.known(req) {
+		return req
+	}
+	return metricsModelOther
+}
+
 // 从响应体或 SSE 数据中粗略提取 usage 字段（JSON 格式）。
 func parseUsage(b []byte) (int64, int64) {
+	tu := parseTokenUsage(b)
+	return tu.InputTokens, tu.OutputTokens
+}
+
+// parseTokenUsage 依次解析数据中全部 usage 对象并叠加：SSE 中 message_start 携带输入与缓存 tokens，
+// message_delta 携带累计输出（新版也会重复输入与缓存），后出现的非零值覆盖先前值。
+func parseTokenUsage(b []byte) store.TokenUsage {
+	var tu store.TokenUsage
 	key := []byte("\"usage\"")
-	idx := bytes.LastIndex(b, key)
-	if idx < 0 {
-		return 0, 0
+	for off := 0; off < len(b); {
+		idx := bytes.Index(b[off:], key)
+		if idx < 0 {
+			break
+		}
+		idx += off
+		off = idx + len(key)
+		obj := extractJSONObject(b[off:])
+		if obj == nil {
+			continue
+		}
+		var tmp struct {
+			InputTokens              int64 `json:"input_tokens"`
+			OutputTokens             int64 `json:"output_tokens"`
+			CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
+			CacheReadInputTokens     int64 `json:"cache_read_input_tokens"`
+		}
+		if err := json.Unmarshal(obj, &tmp); err != nil {
+			continue
+		}
+		if tmp.InputTokens > 0 {
+			tu.InputTokens = tmp.InputTokens
+		}
+		if tmp.OutputTokens > 0 {
+			tu.OutputTokens = tmp.OutputTokens
+		}
+		if tmp.CacheCreationInputTokens > 0 {
+			tu.CacheCreationTokens = tmp.CacheCreationInputTokens
+		}
+		if tmp.CacheReadInputTokens > 0 {
+			tu.CacheReadTokens = tmp.CacheReadInputTokens
+		}
+		off += len(obj)
 	}
-	braceStart := bytes.IndexByte(b[idx:], '{')
+	return tu
+}
+
+// extractJSONObject 返回 b 中第一个完整的 {...} 对象，不完整时返回 nil。
+func extractJSONObject(b []byte) []byte {
+	braceStart := bytes.IndexByte(b, '{')
 	if braceStart < 0 {
-		return 0, 0
+		return nil
 	}
-	braceStart += idx
 	depth := 0
 	for i := braceStart; i < len(b); i++ {
 		switch b[i] {
@@ -263,19 +442,21 @@ func parseUsage(b []byte) (int64, int64) {
 		case '}':
 			depth--
 			if depth == 0 {
-				usageObj := b[braceStart : i+1]
-				var tmp struct {
-					InputTokens  int64 `json:"input_tokens"`
-					OutputTokens int64 `json:"output_tokens"`
-				}
-				if err := json.Unmarshal(usageObj, &tmp); err == nil {
-					return tmp.InputTokens, tmp.OutputTokens
-				}
-				break
+				return b[braceStart : i+1]
 			}
 		}
 	}
-	return 0, 0
+	return nil
+}
+
+// tokenUsage 转换为计费使用的 token 用量。
+func (u *usage) tokenUsage() store.TokenUsage {
+	return store.TokenUsage{
+		InputTokens:         u.input,
+		OutputTokens:        u.output,
+		CacheCreationTokens: u.cacheCreation,
+		CacheReadTokens:     u.cacheRead,
+	}
 }
 
 // parseModelFromRequest 从请求体中提取模型 ID
@@ -0,0 +1,86 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"qcc_plus/internal/store"
+)
+
+// TestMetricsFailureBreakdown 测试失败按状态码类别与错误分类拆分、客户端取消单独计数，并经小时聚合后由节点接口输出
+func TestMetricsFailureBreakdown(t *testing.T) {
+	st, err := store.OpenSQLite(filepath.Join(t.TempDir(), "failures.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer st.Close()
+	srv := newClusterTestServer(t, NewLocalClusterBus(), "a")
+	srv.store = st
+	acc := srv.TestAccount("acc-1")
+	node := acc.Nodes["n1"]
+
+	ctx := context.Background()
+	hour := time.Now().UTC().Truncate(time.Hour).Add(-2 * time.Hour)
+	cases := []struct {
+		status   int
+		upstream string
+		category string
+	}{
+		{http.StatusOK, "", ""},
+		{529, "529", ErrorCategoryOverloaded},
+		{529, "529", ErrorCategoryOverloaded},
+		{http.StatusUnauthorized, "401", ErrorCategoryAuth},
+		{http.StatusServiceUnavailable, "", ErrorCategoryNetwork},
+		{499, "", ErrorCategoryTimeout},
+	}
+	for i, c := range cases {
+		mw := &metricsWriter{ResponseWriter: httptest.NewRecorder(), status: c.status}
+		if c.upstream != "" {
+			mw.Header().Set("X-Upstream-Status", c.upstream)
+		}
+		start := hour.Add(time.Duration(i) * time.Minute)
+		rec := buildMetricsRecord(node.AccountID, node.ID, "", start, start.Add(50*time.Millisecond), mw, &usage{errCategory: c.category}, 0, 0)
+		if err := st.InsertMetrics(ctx, *rec); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+	if err := st.AggregateMetrics(ctx, "", store.MetricsGranularityHourly, hour, hour.Add(time.Hour)); err != nil {
+		t.Fatalf("aggregate: %v", err)
+	}
+
+	adminCtx := context.WithValue(context.WithValue(ctx, isAdminContextKey{}, true), accountContextKey{}, acc)
+	req := httptest.NewRequest(http.MethodGet, "/api/nodes/n1/metrics?granularity=hour&from="+hour.Format(time.RFC3339), nil).WithContext(adminCtx)
+	rec := httptest.NewRecorder()
+	srv.handleGetNodeMetrics(rec, req)
+	var resp struct {
+		Data []struct {
+			RequestsTotal    int64            `json:"requests_total"`
+			RequestsFailed   int64            `json:"requests_failed"`
+			RequestsCanceled int64            `json:"requests_canceled"`
+			Failed4xx        int64            `json:"failed_4xx"`
+			Failed5xx        int64            `json:"failed_5xx"`
+			ByCategory       map[string]int64 `json:"errors_by_category"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || len(resp.Data) != 1 {
+		t.Fatalf("unexpected response %d %s", rec.Code, rec.Body.String())
+	}
+	got := resp.Data[0]
+	if got.RequestsTotal != 6 || got.RequestsFailed != 4 || got.RequestsCanceled != 1 || got.Failed4xx != 1 || got.Failed5xx != 3 {
+		t.Fatalf("unexpected counters %+v", got)
+	}
+	want := map[string]int64{"overloaded": 2, "auth": 1, "network": 1}
+	if len(got.ByCategory) != len(want) {
+		t.Fatalf("unexpected categories %v", got.ByCategory)
+	}
+	for k, v := range want {
+		if got.ByCategory[k] != v {
+			t.Fatalf("unexpected categories %v", got.ByCategory)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package proxy
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"qcc_plus/internal/store"
+)
+
+// TestMetricsModelDimension 测试模型维度的写入、逐级聚合，以及接口按模型过滤与分组
+func TestMetricsModelDimension(t *testing.T) {
+	st, err := store.OpenSQLite(filepath.Join(t.TempDir(), "metrics.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer st.Close()
+
+	ctx := context.Background()
+	day := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -1)
+	for i := 0; i < 6; i++ {
+		start := day.Add(time.Duration(i%2) * time.Hour)
+		mw := &metricsWriter{status: http.StatusOK}
+		if i >= 4 {
+			mw.status = http.StatusBadGateway
+		}
+		model := "claude-sonnet-4-5"
+		if i%3 == 0 {
+			model = "claude-opus-4-1"
+		}
+		trace := &RequestTrace{audit: requestAudit{modelID: model}}
+		rec := buildMetricsRecord("acc1", "n1", model, start, start.Add(100*time.Millisecond), mw, &usage{trace: trace}, 0, 0)
+		if err := st.InsertMetrics(ctx, *rec); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+	for _, target := range []store.MetricsGranularity{store.MetricsGranularityHourly, store.MetricsGranularityDaily} {
+		if err := st.AggregateMetrics(ctx, "", target, day, day.Add(24*time.Hour)); err != nil {
+			t.Fatalf("aggregate %s: %v", target, err)
+		}
+	}
+	recs, err := st.QueryMetrics(ctx, store.MetricsQuery{AccountID: "acc1", ModelID: "claude-opus-4-1", Granularity: store.MetricsGranularityDaily, From: day, To: day.Add(24 * time.Hour)})
+	if err != nil || len(recs) != 1 || recs[0].RequestsTotal != 2 || recs[0].ModelID != "claude-opus-4-1" {
+		t.Fatalf("unexpected opus daily rows %+v err=%v", recs, err)
+	}
+
+	srv := newClusterTestServer(t, NewLocalClusterBus(), "a")
+	srv.store = st
+	userCtx := context.WithValue(ctx, accountContextKey{}, &Account{ID: "acc1"})
+	query := func(params string) []map[string]any {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/accounts/acc1/metrics?granularity=day&from="+day.Format(time.RFC3339)+params, nil).WithContext(userCtx)
+		rec := httptest.NewRecorder()
+		srv.handleGetAccountMetrics(rec, req)
+		var resp struct {
+			Data []map[string]any `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode %s: %v", rec.Body.String(), err)
+		}
+		return resp.Data
+	}
+	if data := query(""); len(data) != 1 || data[0]["requests_total"] != 6.0 || data[0]["model_id"] != nil {
+		t.Fatalf("expected one merged bucket, got %v", data)
+	}
+	data := query("&group_by=model")
+	if len(data) != 2 || data[0]["model_id"] != "claude-opus-4-1" || data[1]["model_id"] != "claude-sonnet-4-5" || data[1]["requests_failed"] != 2.0 {
+		t.Fatalf("unexpected per-model buckets %v", data)
+	}
+	if data := query("&model_id=claude-sonnet-4-5"); len(data) != 1 || data[0]["requests_total"] != 4.0 {
+		t.Fatalf("unexpected filtered bucket %v", data)
+	}
+	if data := query("&group_by=model&limit=1&offset=1"); len(data) != 1 || data[0]["model_id"] != "claude-sonnet-4-5" {
+		t.Fatalf("unexpected paged per-model buckets %v", data)
+	}
+
+	// 数据库按时间桶分页，只返回第二个小时桶的全部模型记录
+	page, err := st.QueryMetricsPage(ctx, store.MetricsQuery{AccountID: "acc1", Granularity: store.MetricsGranularityHourly, From: day, To: day.Add(24 * time.Hour), Limit: 1, Offset: 1}, false)
+	if err != nil || len(page) == 0 {
+		t.Fatalf("query page: %+v err=%v", page, err)
+	}
+	var total int64
+	for _, r := range page {
+		if !r.Timestamp.Equal(day.Add(time.Hour)) {
+			t.Fatalf("unexpected bucket %v in page", r.Timestamp)
+		}
+		total += r.RequestsTotal
+	}
+	if total != 3 {
+		t.Fatalf("expected 3 requests in second hourly bucket, got %d", total)
+	}
+}
+
+// TestMetricsModelMigration 测试旧版聚合表迁移后保留历史数据，并允许同一时间桶按模型写入多行
+func TestMetricsModelMigration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "legacy.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("open legacy: %v", err)
+	}
+	bucket := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	for _, stmt := range []string{
+		`CREATE TABLE node_metrics_hourly (
+			account_id TEXT NOT NULL,
+			node_id TEXT NOT NULL,
+			bucket_start DATETIME NOT NULL,
+			requests_total INTEGER DEFAULT 0,
+			requests_success INTEGER DEFAULT 0,
+			requests_failed INTEGER DEFAULT 0,
+			response_time_sum_ms INTEGER DEFAULT 0,
+			response_time_count INTEGER DEFAULT 0,
+			bytes_total INTEGER DEFAULT 0,
+			input_tokens_total INTEGER DEFAULT 0,
+			output_tokens_total INTEGER DEFAULT 0,
+			first_byte_time_sum_ms INTEGER DEFAULT 0,
+			stream_duration_sum_ms INTEGER DEFAULT 0,
+			PRIMARY KEY (account_id, node_id, bucket_start)
+		)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("create legacy: %v", err)
+		}
+	}
+	if _, err := db.Exec(`INSERT INTO node_metrics_hourly (account_id, node_id, bucket_start, requests_total, requests_success) VALUES (?, ?, ?, 5, 5)`, "acc1", "n1", bucket); err != nil {
+		t.Fatalf("insert legacy: %v", err)
+	}
+	db.Close()
+
+	st, err := store.OpenSQLite(path)
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	defer st.Close()
+
+	ctx := context.Background()
+	raw := bucket.Add(10 * time.Minute)
+	if err := st.InsertMetrics(ctx, store.MetricsRecord{AccountID: "acc1", NodeID: "n1", ModelID: "claude-haiku-4-5", Timestamp: raw, RequestsTotal: 1}); err != nil {
+		t.Fatalf("insert raw: %v", err)
+	}
+	if err := st.AggregateMetrics(ctx, "acc1", store.MetricsGranularityHourly, bucket, bucket.Add(time.Hour)); err != nil {
+		t.Fatalf("aggregate: %v", err)
+	}
+	recs, err := st.QueryMetrics(ctx, store.MetricsQuery{AccountID: "acc1", Granularity: store.MetricsGranularityHourly, From: bucket, To: bucket.Add(time.Hour)})
+	if err != nil || len(recs) != 2 {
+		t.Fatalf("expected legacy and per-model rows, got %+v err=%v", recs, err)
+	}
+	models := map[string]int64{}
+	for _, r := range recs {
+		models[r.ModelID] = r.RequestsTotal
+	}
+	if models[""] != 5 || models["claude-haiku-4-5"] != 1 {
+		t.Fatalf("unexpected rows after migration %v", models)
+	}
+}
+
+// TestMetricsModelNormalization 测试模型维度归一化：仅上游确认过的模型独立统计，其余归入 other
+func TestMetricsModelNormalization(t *testing.T) {
+	srv := newClusterTestServer(t, NewLocalClusterBus(), "a")
+	withModel := func(reqModel, respModel string) *usage {
+		return &usage{modelID: respModel, trace: &RequestTrace{audit: requestAudit{modelID: reqModel}}}
+	}
+	if got := srv.metricsModel(withModel("bogus-model", "")); got != metricsModelOther {
+		t.Fatalf("unconfirmed model should be other, got %q", got)
+	}
+	if got := srv.metricsModel(withModel(" Claude-Sonnet-4-5 ", "claude-sonnet-4-5-20250929")); got != "claude-sonnet-4-5" {
+		t.Fatalf("expected normalized request model, got %q", got)
+	}
+	if got := srv.metricsModel(withModel("claude-sonnet-4-5", "")); got != "claude-sonnet-4-5" {
+		t.Fatalf("confirmed model should keep its own bucket on failure, got %q", got)
+	}
+	for _, bad := range []string{strings.Repeat("x", metricsModelMaxLen+1), "model with space", "模型"} {
+		if got := srv.metricsModel(withModel(bad, "")); got != metricsModelOther {
+			t.Fatalf("invalid model %q should be other, got %q", bad, got)
+		}
+	}
+	if got := srv.metricsModel(&usage{}); got != "" {
+		t.Fatalf("expected empty model without request body, got %q", got)
+	}
+}
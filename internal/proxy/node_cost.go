@@ -0,0 +1,321 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"qcc_plus/internal/logging"
+	"qcc_plus/internal/store"
+)
+
+// 节点选择策略。
+const (
+	NodeSelectionPriority = "priority" // 按权重（数值越小越优先），默认
+	NodeSelectionCost     = "cost"     // 先按实际成本系数从低到高，相同再按权重
+)
+
+// nodeCostState 节点成本配置、由配置得出的成本系数，及本实例在当前配置下累计的官方/实际费用。
+type nodeCostState struct {
+	cfg       store.NodeCostRecord
+	factor    float64
+	listUSD   float64
+	actualUSD float64
+}
+
+// loadNodeSelectionStrategy 读取 NODE_SELECTION_STRATEGY，未知值回退为 priority。
+func loadNodeSelectionStrategy(logger *logging.Logger) string {
+	v := strings.ToLower(strings.TrimSpace(os.Getenv("NODE_SELECTION_STRATEGY")))
+	switch v {
+	case "", NodeSelectionPriority:
+		return NodeSelectionPriority
+	case NodeSelectionCost:
+		return NodeSelectionCost
+	}
+	logger.Warn("invalid NODE_SELECTION_STRATEGY, fallback to priority", "value", v)
+	return NodeSelectionPriority
+}
+
+// restoreNodeCosts 启动时加载节点成本配置。
+func (p *Server) restoreNodeCosts() {
+	if p.store == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	recs, err := p.store.ListNodeCosts(ctx)
+	if err != nil {
+		p.logger.Component("pricing").Warn("load node costs failed", logging.KeyError, err)
+		return
+	}
+	for _, rec := range recs {
+		if p.getNode(rec.NodeID) != nil {
+			p.setNodeCost(rec)
+		}
+	}
+}
+
+// setNodeCost 更新内存中的节点成本配置并重新计算成本系数；累计费用按新配置重新统计。
+func (p *Server) setNodeCost(rec store.NodeCostRecord) {
+	factor := p.computeNodeCostFactor(rec)
+	p.nodeCostMu.Lock()
+	defer p.nodeCostMu.Unlock()
+	if p.nodeCosts == nil {
+		p.nodeCosts = make(map[string]*nodeCostState)
+	}
+	st := p.nodeCosts[rec.NodeID]
+	if st == nil {
+		st = &nodeCostState{}
+		p.nodeCosts[rec.NodeID] = st
+	}
+	st.cfg = rec
+	st.factor = factor
+	st.listUSD, st.actualUSD = 0, 0
+}
+
+// computeNodeCostFactor 按配置的成本倍率与价格覆盖计算成本系数；有价格覆盖时需要读取启用的定价条目。
+func (p *Server) computeNodeCostFactor(rec store.NodeCostRecord) float64 {
+	if len(rec.Overrides) == 0 || p.store == nil {
+		return rec.CostFactor(nil)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	prices, err := p.store.ListModelPricing(ctx, true)
+	if err != nil {
+		p.logger.Component("pricing").Warn("load pricing for node cost factor failed", logging.KeyNodeID, rec.NodeID, logging.KeyError, err)
+	}
+	return rec.CostFactor(prices)
+}
+
+// refreshNodeCostFactors 定价变更后重新计算配置了价格覆盖的节点的成本系数。
+func (p *Server) refreshNodeCostFactors() {
+	p.nodeCostMu.RLock()
+	var cfgs []store.NodeCostRecord
+	for _, st := range p.nodeCosts {
+		if len(st.cfg.Overrides) > 0 {
+			cfgs = append(cfgs, st.cfg)
+		}
+	}
+	p.nodeCostMu.RUnlock()
+	for _, cfg := range cfgs {
+		factor := p.computeNodeCostFactor(cfg)
+		p.nodeCostMu.Lock()
+		// 计算期间配置已被替换时以新配置的结果为准
+		if st := p.nodeCosts[cfg.NodeID]; st != nil && st.cfg.UpdatedAt.Equal(cfg.UpdatedAt) {
+			st.factor = factor
+		}
+		p.nodeCostMu.Unlock()
+	}
+}
+
+// removeNodeCost 节点删除后清理内存中的成本配置。
+func (p *Server) removeNodeCost(nodeID string) {
+	p.nodeCostMu.Lock()
+	delete(p.nodeCosts, nodeID)
+	p.nodeCostMu.Unlock()
+}
+
+// nodeCostConfig 返回节点成本配置副本，未配置时返回 nil（按官方价格采购）。
+func (p *Server) nodeCostConfig(nodeID string) *store.NodeCostRecord {
+	p.nodeCostMu.RLock()
+	defer p.nodeCostMu.RUnlock()
+	st := p.nodeCosts[nodeID]
+	if st == nil || st.cfg.NodeID == "" {
+		return nil
+	}
+	cfg := st.cfg
+	return &cfg
+}
+
+// observeNodeCost 累计节点的官方与实际费用，供按成本选择节点使用。
+func (p *Server) observeNodeCost(nodeID string, listUSD, actualUSD float64) {
+	if listUSD <= 0 && actualUSD <= 0 {
+		return
+	}
+	p.nodeCostMu.Lock()
+	defer p.nodeCostMu.Unlock()
+	if p.nodeCosts == nil {
+		p.nodeCosts = make(map[string]*nodeCostState)
+	}
+	st := p.nodeCosts[nodeID]
+	if st == nil {
+		st = &nodeCostState{}
+		p.nodeCosts[nodeID] = st
+	}
+	st.listUSD += listUSD
+	st.actualUSD += actualUSD
+}
+
+// nodeCostFactor 返回节点实际成本相对官方价格的系数，由配置的成本倍率与价格覆盖得出
+// （见 NodeCostRecord.CostFactor），不受本实例流量构成影响；未配置为 1。
+func (p *Server) nodeCostFactor(nodeID string) float64 {
+	p.nodeCostMu.RLock()
+	defer p.nodeCostMu.RUnlock()
+	st := p.nodeCosts[nodeID]
+	if st == nil || st.factor <= 0 {
+		return 1
+	}
+	return st.factor
+}
+
+// compareNodes 按节点选择策略比较两个节点，a 更优先返回负数，b 更优先返回正数。
+// 不比较创建时间，由调用方决定相同时的处理。
+func (p *Server) compareNodes(a, b *Node) int {
+	if p.nodeSelection == NodeSelectionCost {
+		ca, cb := p.nodeCostFactor(a.ID), p.nodeCostFactor(b.ID)
+		if ca < cb {
+			return -1
+		}
+		if ca > cb {
+			return 1
+		}
+	}
+	return a.Weight - b.Weight
+}
+
+// nodeBetter 判断 a 是否优先于 b，策略比较相同时创建较早的节点优先。
+func (p *Server) nodeBetter(a, b *Node) bool {
+	if c := p.compareNodes(a, b); c != 0 {
+		return c < 0
+	}
+	return a.CreatedAt.Before(b.CreatedAt)
+}
+
+type nodeCostView struct {
+	NodeID         string                    `json:"node_id"`
+	NodeName       string                    `json:"node_name"`
+	AccountID      string                    `json:"account_id"`
+	Weight         int                       `json:"weight"`
+	CostMultiplier float64                   `json:"cost_multiplier"`
+	Overrides      []store.NodePriceOverride `json:"overrides"`
+	CostFactor     float64                   `json:"cost_factor"`
+	ListCostUSD    float64                   `json:"list_cost_usd"`   // 本实例在当前配置下累计官方价格
+	ActualCostUSD  float64                   `json:"actual_cost_usd"` // 本实例在当前配置下累计实际成本
+}
+
+func (p *Server) nodeCostView(n *Node) nodeCostView {
+	view := nodeCostView{NodeID: n.ID, NodeName: n.Name, AccountID: n.AccountID, Weight: n.Weight, CostMultiplier: 1, Overrides: []store.NodePriceOverride{}}
+	p.nodeCostMu.RLock()
+	if st := p.nodeCosts[n.ID]; st != nil {
+		if st.cfg.CostMultiplier > 0 {
+			view.CostMultiplier = st.cfg.CostMultiplier
+		}
+		if len(st.cfg.Overrides) > 0 {
+			view.Overrides = append(view.Overrides, st.cfg.Overrides...)
+		}
+		view.ListCostUSD, view.ActualCostUSD = st.listUSD, st.actualUSD
+	}
+	p.nodeCostMu.RUnlock()
+	view.CostFactor = p.nodeCostFactor(n.ID)
+	return view
+}
+
+// handleNodeCosts 节点成本配置（仅管理员）
+// GET    /admin/api/node-costs?account_id=xx - 列出节点成本倍率、价格覆盖与当前成本系数
+// GET    /admin/api/node-costs/{node_id}     - 单个节点
+// PUT    /admin/api/node-costs/{node_id}     - 设置成本倍率与价格覆盖（整体替换）
+// DELETE /admin/api/node-costs/{node_id}     - 恢复为按官方价格采购
+func (p *Server) handleNodeCosts(w http.ResponseWriter, r *http.Request) {
+	if !isAdmin(r.Context()) {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "forbidden"})
+		return
+	}
+	nodeID := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/api/node-costs"), "/")
+	if nodeID == "" {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		accountID := r.URL.Query().Get("account_id")
+		p.mu.RLock()
+		nodes := make([]*Node, 0, len(p.nodeIndex))
+		for _, n := range p.nodeIndex {
+			if accountID == "" || n.AccountID == accountID {
+				nodes = append(nodes, n)
+			}
+		}
+		p.mu.RUnlock()
+		sort.Slice(nodes, func(i, j int) bool {
+			if nodes[i].AccountID != nodes[j].AccountID {
+				return nodes[i].AccountID < nodes[j].AccountID
+			}
+			return nodes[i].Weight < nodes[j].Weight
+		})
+		views := make([]nodeCostView, 0, len(nodes))
+		for _, n := range nodes {
+			views = append(views, p.nodeCostView(n))
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"strategy": p.nodeSelection, "nodes": views})
+		return
+	}
+
+	node := p.getNode(nodeID)
+	if node == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "node not found"})
+		return
+	}
+	rec := store.NodeCostRecord{NodeID: nodeID, AccountID: node.AccountID, CostMultiplier: 1}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, p.nodeCostView(node))
+		return
+	case http.MethodPut:
+		var req struct {
+			CostMultiplier float64                   `json:"cost_multiplier"`
+			Overrides      []store.NodePriceOverride `json:"overrides"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+			return
+		}
+		if req.CostMultiplier < 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "cost_multiplier must be >= 0"})
+			return
+		}
+		seen := make(map[string]bool)
+		for _, o := range req.Overrides {
+			if o.ModelID == "" || seen[o.ModelID] {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "override model_id required and must be unique"})
+				return
+			}
+			if err := store.ValidateModelPattern(o.ModelID); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid model_id pattern: " + err.Error()})
+				return
+			}
+			if o.InputPriceMTok < 0 || o.OutputPriceMTok < 0 || o.CacheWritePriceMTok < 0 || o.CacheReadPriceMTok < 0 {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "prices must be >= 0"})
+				return
+			}
+			seen[o.ModelID] = true
+		}
+		if req.CostMultiplier > 0 {
+			rec.CostMultiplier = req.CostMultiplier
+		}
+		rec.Overrides = req.Overrides
+	case http.MethodDelete:
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	rec.UpdatedAt = time.Now()
+	if p.store != nil {
+		var err error
+		if r.Method == http.MethodDelete {
+			err = p.store.DeleteNodeCost(r.Context(), nodeID)
+		} else {
+			err = p.store.SaveNodeCost(r.Context(), rec)
+		}
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+	p.setNodeCost(rec)
+	p.publishCluster(ClusterEventNodeCost, node.AccountID, nodeID, rec)
+	writeJSON(w, http.StatusOK, p.nodeCostView(node))
+}
@@ -0,0 +1,140 @@
+package proxy
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"qcc_plus/internal/store"
+)
+
+// TestNodeCostActualCostAndSelection 测试节点成本倍率/价格覆盖的实际成本记录、汇总、集群同步与按成本选择节点
+func TestNodeCostActualCostAndSelection(t *testing.T) {
+	st, err := store.OpenSQLite(filepath.Join(t.TempDir(), "node-cost.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer st.Close()
+	ctx := context.Background()
+	if err := st.UpsertModelPricing(ctx, store.ModelPricingRecord{ModelID: "cheap-model", ModelName: "Cheap", InputPriceMTok: 1, OutputPriceMTok: 2, IsActive: true}); err != nil {
+		t.Fatalf("upsert pricing: %v", err)
+	}
+
+	bus := NewLocalClusterBus()
+	a := newClusterTestServer(t, bus, "a")
+	b := newClusterTestServer(t, bus, "b")
+	a.store = st
+	adminCtx := context.WithValue(ctx, isAdminContextKey{}, true)
+
+	put := func(nodeID, body string) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		a.handleNodeCosts(rec, httptest.NewRequest(http.MethodPut, "/admin/api/node-costs/"+nodeID, strings.NewReader(body)).WithContext(adminCtx))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("put node cost %s failed: %d %s", nodeID, rec.Code, rec.Body.String())
+		}
+	}
+	put("n1", `{"cost_multiplier":0.5}`)
+	put("n2", `{"cost_multiplier":3,"overrides":[{"model_id":"cheap-*","input_price_mtok":0.2,"output_price_mtok":0.4}]}`)
+
+	if cfg := b.nodeCostConfig("n2"); cfg == nil || len(cfg.Overrides) != 1 || cfg.CostMultiplier != 3 {
+		t.Fatalf("expected node cost synced to peer, got %+v", cfg)
+	}
+
+	use := &usage{input: 1_000_000, output: 1_000_000, modelID: "cheap-model"}
+	a.recordMetrics(ctx, "n1", time.Now(), &metricsWriter{status: http.StatusOK}, use, 0, 0, true)
+	a.recordMetrics(ctx, "n2", time.Now(), &metricsWriter{status: http.StatusOK}, use, 0, 0, true)
+
+	summaries, err := st.GetUsageSummaryByNode(ctx, store.QueryUsageParams{AccountID: "acc-1"})
+	if err != nil || len(summaries) != 2 {
+		t.Fatalf("expected two node summaries, got %+v (%v)", summaries, err)
+	}
+	want := map[string]float64{"n1": 1.5, "n2": 0.6}
+	for _, s := range summaries {
+		if math.Abs(s.TotalCostUSD-3) > 1e-9 || math.Abs(s.TotalActualCostUSD-want[s.NodeID]) > 1e-9 {
+			t.Fatalf("unexpected summary %+v", s)
+		}
+	}
+	total, err := st.GetUsageSummary(ctx, store.QueryUsageParams{AccountID: "acc-1"})
+	if err != nil || math.Abs(total.TotalCostUSD-6) > 1e-9 || math.Abs(total.TotalActualCostUSD-2.1) > 1e-9 {
+		t.Fatalf("unexpected total summary %+v (%v)", total, err)
+	}
+
+	// 默认 priority 策略按权重选择 n1，cost 策略优先实际成本系数更低的 n2
+	acc := a.accountByID["acc-1"]
+	if best, err := a.selectBestAndActivate(acc); err != nil || best.ID != "n1" {
+		t.Fatalf("priority strategy should keep n1, got %v (%v)", best, err)
+	}
+	a.nodeSelection = NodeSelectionCost
+	if f := a.nodeCostFactor("n2"); math.Abs(f-0.2) > 1e-9 {
+		t.Fatalf("expected cost factor 0.2 from overrides, got %v", f)
+	}
+	if best, err := a.selectBestAndActivate(acc); err != nil || best.ID != "n2" {
+		t.Fatalf("cost strategy should prefer n2, got %v (%v)", best, err)
+	}
+
+	// 成本系数只由配置得出：未命中覆盖的流量（按倍率 3 计费）不改变 n2 的系数
+	other := &usage{input: 1_000_000, output: 1_000_000, modelID: "other-model"}
+	a.observeNodeCost("n2", 2, 6)
+	if f := a.nodeCostFactor("n2"); math.Abs(f-0.2) > 1e-9 {
+		t.Fatalf("traffic must not change the configured cost factor, got %v", f)
+	}
+	// 定价变更后重新计算：命中覆盖的两个定价条目之比分别为 0.2 与 0.3，平均 0.25
+	if err := st.UpsertModelPricing(ctx, store.ModelPricingRecord{ModelID: "cheap-model-2", ModelName: "Cheap 2", InputPriceMTok: 1, OutputPriceMTok: 1, IsActive: true}); err != nil {
+		t.Fatalf("upsert pricing: %v", err)
+	}
+	a.publishPricingChanged()
+	if f := a.nodeCostFactor("n2"); math.Abs(f-0.25) > 1e-9 {
+		t.Fatalf("expected cost factor 0.25 after pricing change, got %v", f)
+	}
+	a.recordMetrics(ctx, "n1", time.Now(), &metricsWriter{status: http.StatusOK}, other, 0, 0, true)
+	// 修改配置后累计费用重新统计，系数取新倍率
+	put("n1", `{"cost_multiplier":0.9}`)
+	if view := a.nodeCostView(a.getNode("n1")); view.CostFactor != 0.9 || view.ListCostUSD != 0 || view.ActualCostUSD != 0 {
+		t.Fatalf("expected totals reset and new multiplier applied, got %+v", view)
+	}
+
+	// 删除后恢复为按官方价格采购
+	rec := httptest.NewRecorder()
+	a.handleNodeCosts(rec, httptest.NewRequest(http.MethodDelete, "/admin/api/node-costs/n2", nil).WithContext(adminCtx))
+	if cfg, err := st.GetNodeCost(ctx, "n2"); rec.Code != http.StatusOK || err != nil || cfg.CostMultiplier != 1 || len(cfg.Overrides) != 0 {
+		t.Fatalf("unexpected node cost after delete: %d %+v (%v)", rec.Code, cfg, err)
+	}
+}
+
+// TestNodeCostAPIValidation 测试节点成本接口的权限与参数校验
+func TestNodeCostAPIValidation(t *testing.T) {
+	srv := newClusterTestServer(t, NewLocalClusterBus(), "a")
+	adminCtx := context.WithValue(context.Background(), isAdminContextKey{}, true)
+
+	cases := []struct {
+		name string
+		ctx  context.Context
+		path string
+		body string
+		code int
+	}{
+		{"non-admin", context.Background(), "/admin/api/node-costs/n1", `{"cost_multiplier":1}`, http.StatusForbidden},
+		{"unknown node", adminCtx, "/admin/api/node-costs/missing", `{"cost_multiplier":1}`, http.StatusNotFound},
+		{"negative multiplier", adminCtx, "/admin/api/node-costs/n1", `{"cost_multiplier":-1}`, http.StatusBadRequest},
+		{"duplicate override", adminCtx, "/admin/api/node-costs/n1", `{"overrides":[{"model_id":"m"},{"model_id":"m"}]}`, http.StatusBadRequest},
+		{"invalid regex", adminCtx, "/admin/api/node-costs/n1", `{"overrides":[{"model_id":"re:(bad"}]}`, http.StatusBadRequest},
+		{"negative price", adminCtx, "/admin/api/node-costs/n1", `{"overrides":[{"model_id":"m","input_price_mtok":-1}]}`, http.StatusBadRequest},
+		{"ok", adminCtx, "/admin/api/node-costs/n1", `{"cost_multiplier":0.8}`, http.StatusOK},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		srv.handleNodeCosts(rec, httptest.NewRequest(http.MethodPut, c.path, strings.NewReader(c.body)).WithContext(c.ctx))
+		if rec.Code != c.code {
+			t.Fatalf("%s: expected %d, got %d %s", c.name, c.code, rec.Code, rec.Body.String())
+		}
+	}
+	if f := srv.nodeCostFactor("n1"); math.Abs(f-0.8) > 1e-9 {
+		t.Fatalf("expected configured multiplier as cost factor, got %v", f)
+	}
+}
@@ -0,0 +1,32 @@
+package proxy
+
+import (
+	"context"
+	"time"
+
+	"qcc_plus/internal/logging"
+	"qcc_plus/internal/store"
+)
+
+// nodeEventRetention 节点事件日志保留时长。
+const nodeEventRetention = 90 * 24 * time.Hour
+
+// recordNodeEvent 持久化节点故障、恢复或切换，供 Grafana 标注等时间线展示；需在释放 p.mu 后调用。
+func (p *Server) recordNodeEvent(accountID, nodeID, eventType, fromNodeID, message string) {
+	if p.store == nil || nodeID == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := p.store.InsertNodeEvent(ctx, store.NodeEventRecord{
+		AccountID:  accountID,
+		NodeID:     nodeID,
+		EventType:  eventType,
+		FromNodeID: fromNodeID,
+		Message:    message,
+		CreatedAt:  time.Now(),
+	})
+	if err != nil {
+		p.logger.Warn("record node event failed", logging.KeyAccountID, accountID, logging.KeyNodeID, nodeID, "event", eventType, logging.KeyError, err)
+	}
+}
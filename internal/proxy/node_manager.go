@@ -7,6 +7,7 @@ import (
 	"net/url"
 	"time"
 
+	"qcc_plus/internal/logging"
 	"qcc_plus/internal/notify"
 	"qcc_plus/internal/store"
 	"qcc_plus/internal/timeutil"
@@ -48,7 +49,7 @@ func (p *Server) addNodeWithMethod(acc *Account, name, rawURL, apiKey string, we
 	model := chooseNonEmpty(healthModel, defaultHealthCheckModel)
 	if healthMethodRequiresAPIKey(healthMethod) && apiKey == "" {
 		// CLI/API 探活都需要密钥，缺失时统一降级到 HEAD，保证可用性。
-		p.logger.Printf("health check mode %s requires api key, fallback to head for node %s", healthMethod, name)
+		p.logger.Component("node").Warn("health check mode requires api key, fallback to head", logging.KeyAccountID, acc.ID, "node", name, "method", healthMethod)
 		healthMethod = HealthCheckMethodHEAD
 	}
 	id := fmt.Sprintf("n-%d", time.Now().UnixNano())
@@ -60,7 +61,7 @@ func (p *Server) addNodeWithMethod(acc *Account, name, rawURL, apiKey string, we
 	p.nodeAccount[id] = acc
 	cur := acc.Nodes[acc.ActiveID]
 	curFailed := cur != nil && (cur.Failed || cur.Disabled)
-	needSwitch := cur == nil || curFailed || node.Weight < cur.Weight
+	needSwitch := cur == nil || curFailed || p.compareNodes(node, cur) < 0
 	var rec store.NodeRecord
 	if p.store != nil {
 		rec = store.NodeRecord{ID: id, Name: name, BaseURL: rawURL, APIKey: apiKey, HealthCheckMethod: healthMethod, HealthCheckModel: model, AccountID: acc.ID, Weight: weight, CreatedAt: node.CreatedAt}
@@ -70,6 +71,7 @@ func (p *Server) addNodeWithMethod(acc *Account, name, rawURL, apiKey string, we
 	if p.store != nil {
 		_ = p.store.UpsertNode(context.Background(), rec)
 	}
+	p.publishNodeConfig(id)
 
 	if p.notifyMgr != nil {
 		p.notifyMgr.Publish(notify.Event{
@@ -84,7 +86,7 @@ func (p *Server) addNodeWithMethod(acc *Account, name, rawURL, apiKey string, we
 
 	// 新节点优先级更高或当前无有效节点时，触发一次重选
 	if needSwitch {
-		p.logger.Printf("auto-switch after adding node %s (weight %d)", node.Name, node.Weight)
+		p.logger.Component("node").Info("auto-switch after adding node", logging.KeyAccountID, acc.ID, logging.KeyNodeID, node.ID, "node", node.Name, "weight", node.Weight)
 		_, _ = p.selectBestAndActivate(acc, "新增节点")
 	}
 	return node, nil
@@ -123,7 +125,7 @@ func (p *Server) updateNode(id, name, rawURL string, apiKey *string, weight int,
 	desiredMethod = normalizeHealthCheckMethod(desiredMethod)
 	if healthMethodRequiresAPIKey(desiredMethod) && newAPIKey == "" {
 		// CLI/API 探活需要密钥，缺失时统一降级为 HEAD。
-		p.logger.Printf("health check mode %s requires api key, fallback to head for node %s", desiredMethod, n.Name)
+		p.logger.Component("node").Warn("health check mode requires api key, fallback to head", logging.KeyNodeID, n.ID, "node", n.Name, "method", desiredMethod)
 		desiredMethod = HealthCheckMethodHEAD
 	}
 	oldWeight := n.Weight
@@ -144,6 +146,7 @@ func (p *Server) updateNode(id, name, rawURL string, apiKey *string, weight int,
 			return err
 		}
 	}
+	p.publishNodeConfig(id)
 
 	if p.notifyMgr != nil && acc != nil {
 		p.notifyMgr.Publish(notify.Event{
@@ -158,7 +161,7 @@ func (p *Server) updateNode(id, name, rawURL string, apiKey *string, weight int,
 
 	// 权重变更可能影响优先级，事件驱动触发一次重选
 	if acc != nil && oldWeight != weight {
-		p.logger.Printf("node %s weight changed %d -> %d, reselecting active node", n.Name, oldWeight, weight)
+		p.logger.Component("node").Info("node weight changed, reselecting active node", logging.KeyNodeID, n.ID, "node", n.Name, "from", oldWeight, "to", weight)
 		_, _ = p.selectBestAndActivate(acc, "权重调整")
 	}
 	return nil
@@ -189,7 +192,13 @@ func (p *Server) deleteNode(id string) error {
 		if err := p.store.DeleteNode(context.Background(), id); err != nil {
 			return err
 		}
+		_ = p.store.DeleteCircuitBreaker(context.Background(), id)
+		_ = p.store.DeleteNodeCost(context.Background(), id)
 	}
+	p.removeCircuitBreaker(id)
+	p.removeNodeCost(id)
+	p.prom.removeNode(id)
+	p.publishCluster(ClusterEventNodeConfig, accID, id, clusterNodeConfig{Deleted: true})
 
 	if p.notifyMgr != nil && acc != nil {
 		baseURL := ""
@@ -212,18 +221,26 @@ func (p *Server) deleteNode(id string) error {
 // 激活指定节点。
 func (p *Server) activate(id string) error {
 	p.mu.Lock()
-	defer p.mu.Unlock()
 	acc := p.nodeAccount[id]
 	if acc == nil {
+		p.mu.Unlock()
 		return fmt.Errorf("node %s not found", id)
 	}
 	if _, ok := acc.Nodes[id]; !ok {
+		p.mu.Unlock()
 		return fmt.Errorf("node %s not found", id)
 	}
+	prevID := acc.ActiveID
 	acc.ActiveID = id
 	if p.store != nil {
 		_ = p.store.SetActive(context.Background(), acc.ID, id)
 	}
+	p.mu.Unlock()
+
+	p.publishActiveNode(acc)
+	if prevID != id {
+		p.recordNodeEvent(acc.ID, id, store.NodeEventSwitched, prevID, "手动切换")
+	}
 	return nil
 }
 
@@ -277,7 +294,7 @@ func (p *Server) selectHealthyNodeExcluding(acc *Account, skipNodes map[string]b
 		// 不在选择阶段过滤熔断器状态，交由请求阶段的 AllowRequest() 控制
 		// 这样熔断器可以在冷却后进入 Half-Open 状态进行试探
 
-		if bestNode == nil || n.Weight < bestNode.Weight {
+		if bestNode == nil || p.compareNodes(n, bestNode) < 0 {
 			bestNode = n
 		}
 	}
@@ -293,7 +310,7 @@ func (p *Server) isInFailedSet(acc *Account, nodeID string) bool {
 	return ok
 }
 
-// 选择最低权重（最高优先级）的健康节点并激活。
+// 按节点选择策略选择最优的健康节点并激活（默认为最低权重，即最高优先级）。
 func (p *Server) selectBestAndActivate(acc *Account, reason ...string) (*Node, error) {
 	if acc == nil {
 		return nil, ErrNoActiveNode
@@ -312,7 +329,7 @@ func (p *Server) selectBestAndActivate(acc *Account, reason ...string) (*Node, e
 		if n.Failed || n.Disabled || p.isInFailedSet(acc, id) {
 			continue
 		}
-		if bestNode == nil || n.Weight < bestNode.Weight || (n.Weight == bestNode.Weight && n.CreatedAt.Before(bestNode.CreatedAt)) {
+		if bestNode == nil || p.nodeBetter(n, bestNode) {
 			bestNode = n
 			bestID = id
 		}
@@ -322,24 +339,25 @@ func (p *Server) selectBestAndActivate(acc *Account, reason ...string) (*Node, e
 		return nil, ErrNoActiveNode
 	}
 
+	// 预热是本实例切换节点的前置步骤，不受选主限制，从实例同样执行。
 	if p.warmupConfig.Enabled {
 		p.mu.Unlock()
 
-		p.logger.Printf("warming up node %s before activation", bestNode.Name)
+		p.logger.Component("node").Info("warming up node before activation", logging.KeyAccountID, acc.ID, logging.KeyNodeID, bestNode.ID, "node", bestNode.Name)
 		successCount, err := p.warmupNode(bestNode)
 		if err != nil && p.logger != nil {
-			p.logger.Printf("warmup error for node %s: %v", bestNode.Name, err)
+			p.logger.Component("node").Warn("warmup error", logging.KeyAccountID, acc.ID, logging.KeyNodeID, bestNode.ID, "node", bestNode.Name, logging.KeyError, err)
 		}
 
 		if !isNodeWarmedUp(successCount, p.warmupConfig) {
-			p.logger.Printf("node %s warmup failed (%d/%d success), trying next node", bestNode.Name, successCount, p.warmupConfig.Attempts)
+			p.logger.Component("node").Warn("node warmup failed, trying next node", logging.KeyAccountID, acc.ID, logging.KeyNodeID, bestNode.ID, "node", bestNode.Name, "success", successCount, "attempts", p.warmupConfig.Attempts)
 
 			skipNodes := make(map[string]bool)
 			skipNodes[bestID] = true
 			return p.selectBestAndActivateExcluding(acc, skipNodes, reason...)
 		}
 
-		p.logger.Printf("node %s warmed up successfully (%d/%d)", bestNode.Name, successCount, p.warmupConfig.Attempts)
+		p.logger.Component("node").Info("node warmed up", logging.KeyAccountID, acc.ID, logging.KeyNodeID, bestNode.ID, "node", bestNode.Name, "success", successCount, "attempts", p.warmupConfig.Attempts)
 		p.mu.Lock()
 	}
 
@@ -349,6 +367,11 @@ func (p *Server) selectBestAndActivate(acc *Account, reason ...string) (*Node, e
 	}
 	p.mu.Unlock()
 
+	if prevID != bestID {
+		p.publishActiveNode(acc)
+		p.recordNodeEvent(acc.ID, bestID, store.NodeEventSwitched, prevID, switchReason)
+	}
+
 	if p.notifyMgr != nil && acc != nil && prevID != bestID {
 		fromName := "-"
 		if prevNode != nil {
@@ -411,7 +434,7 @@ func (p *Server) selectBestAndActivateExcluding(acc *Account, skipNodes map[stri
 		if n.Failed || n.Disabled || p.isInFailedSet(acc, id) || (skipNodes != nil && skipNodes[id]) {
 			continue
 		}
-		if bestNode == nil || n.Weight < bestNode.Weight || (n.Weight == bestNode.Weight && n.CreatedAt.Before(bestNode.CreatedAt)) {
+		if bestNode == nil || p.nodeBetter(n, bestNode) {
 			bestNode = n
 			bestID = id
 		}
@@ -425,11 +448,11 @@ func (p *Server) selectBestAndActivateExcluding(acc *Account, skipNodes map[stri
 	if p.warmupConfig.Enabled {
 		p.mu.Unlock()
 
-		p.logger.Printf("warming up node %s before activation", bestNode.Name)
+		p.logger.Component("node").Info("warming up node before activation", logging.KeyAccountID, acc.ID, logging.KeyNodeID, bestNode.ID, "node", bestNode.Name)
 		successCount, _ := p.warmupNode(bestNode)
 
 		if !isNodeWarmedUp(successCount, p.warmupConfig) {
-			p.logger.Printf("node %s warmup failed (%d/%d success), trying next node", bestNode.Name, successCount, p.warmupConfig.Attempts)
+			p.logger.Component("node").Warn("node warmup failed, trying next node", logging.KeyAccountID, acc.ID, logging.KeyNodeID, bestNode.ID, "node", bestNode.Name, "success", successCount, "attempts", p.warmupConfig.Attempts)
 
 			if skipNodes == nil {
 				skipNodes = make(map[string]bool)
@@ -438,7 +461,7 @@ func (p *Server) selectBestAndActivateExcluding(acc *Account, skipNodes map[stri
 			return p.selectBestAndActivateExcluding(acc, skipNodes, reason...)
 		}
 
-		p.logger.Printf("node %s warmed up successfully", bestNode.Name)
+		p.logger.Component("node").Info("node warmed up", logging.KeyAccountID, acc.ID, logging.KeyNodeID, bestNode.ID, "node", bestNode.Name)
 		p.mu.Lock()
 	}
 
@@ -448,6 +471,11 @@ func (p *Server) selectBestAndActivateExcluding(acc *Account, skipNodes map[stri
 	}
 	p.mu.Unlock()
 
+	if prevID != bestID {
+		p.publishActiveNode(acc)
+		p.recordNodeEvent(acc.ID, bestID, store.NodeEventSwitched, prevID, switchReason)
+	}
+
 	if p.notifyMgr != nil && acc != nil && prevID != bestID {
 		fromName := "-"
 		if prevNode != nil {
@@ -489,6 +517,11 @@ func (p *Server) selectBestAndActivateExcluding(acc *Account, skipNodes map[stri
 
 // disableNode 手动禁用节点，如果是当前活跃节点则立即切换
 func (p *Server) disableNode(id string) error {
+	return p.setNodeDisabled(id, "手动禁用")
+}
+
+// setNodeDisabled 禁用节点并通知，reason 写入通知内容。
+func (p *Server) setNodeDisabled(id, reason string) error {
 	p.mu.Lock()
 	n, ok := p.nodeIndex[id]
 	if !ok {
@@ -506,13 +539,14 @@ func (p *Server) disableNode(id string) error {
 			return err
 		}
 	}
+	p.publishNodeState(id)
 
 	if p.notifyMgr != nil && acc != nil {
 		p.notifyMgr.Publish(notify.Event{
 			AccountID:  acc.ID,
 			EventType:  notify.EventNodeDisabled,
 			Title:      "节点已禁用",
-			Content:    fmt.Sprintf("**节点名称**: %s\n**操作**: 手动禁用", n.Name),
+			Content:    fmt.Sprintf("**节点名称**: %s\n**操作**: %s", n.Name, reason),
 			DedupKey:   n.ID,
 			OccurredAt: time.Now(),
 		})
@@ -530,7 +564,7 @@ func (p *Server) disableNode(id string) error {
 
 	// 如果禁用的是当前活跃节点，立即切换到下一个可用节点
 	if wasActive {
-		p.logger.Printf("disabled active node %s, switching to next available", n.Name)
+		p.logger.Component("node").Info("disabled active node, switching to next available", logging.KeyNodeID, n.ID, "node", n.Name)
 		p.selectBestAndActivate(acc, "节点禁用")
 	}
 	return nil
@@ -559,6 +593,7 @@ func (p *Server) enableNode(id string) error {
 			return err
 		}
 	}
+	p.publishNodeState(id)
 
 	if p.notifyMgr != nil && acc != nil {
 		p.notifyMgr.Publish(notify.Event{
@@ -583,16 +618,22 @@ func (p *Server) enableNode(id string) error {
 
 	// 检查是否需要切换到刚启用的节点（如果其优先级更高）
 	cur, _ := p.getActiveNodeForAccount(acc)
-	if cur == nil || cur.Failed || n.Weight < cur.Weight {
+	if cur == nil || cur.Failed || p.compareNodes(n, cur) < 0 {
+		prevID := ""
 		p.mu.Lock()
 		if acc != nil {
+			prevID = acc.ActiveID
 			acc.ActiveID = id
 		}
 		if p.store != nil {
 			_ = p.store.SetActive(context.Background(), acc.ID, id)
 		}
 		p.mu.Unlock()
-		p.logger.Printf("auto-switch to enabled node %s (weight %d)", n.Name, n.Weight)
+		p.publishActiveNode(acc)
+		if acc != nil && prevID != id {
+			p.recordNodeEvent(acc.ID, id, store.NodeEventSwitched, prevID, "启用高优先级节点")
+		}
+		p.logger.Component("node").Info("auto-switch to enabled node", logging.KeyNodeID, n.ID, "node", n.Name, "weight", n.Weight)
 	}
 	return nil
 }
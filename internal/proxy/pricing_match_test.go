@@ -0,0 +1,176 @@
+package proxy
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"qcc_plus/internal/store"
+)
+
+// TestResolveModelPricingPatterns 测试精确、glob、正则与同族匹配的优先顺序
+func TestResolveModelPricingPatterns(t *testing.T) {
+	st, err := store.OpenSQLite(filepath.Join(t.TempDir(), "match.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer st.Close()
+	ctx := context.Background()
+
+	for _, p := range []store.ModelPricingRecord{
+		{ModelID: "claude-sonnet-4-5*", InputPriceMTok: 9, IsActive: true},
+		{ModelID: "claude-*", InputPriceMTok: 1, IsActive: true},
+		{ModelID: "re:^my-(opus|haiku)$", InputPriceMTok: 2, IsActive: true},
+		{ModelID: "reseller-*", InputPriceMTok: 7, IsActive: false},
+	} {
+		p.ModelName = p.ModelID
+		if err := st.UpsertModelPricing(ctx, p); err != nil {
+			t.Fatalf("upsert %s: %v", p.ModelID, err)
+		}
+	}
+
+	cases := []struct {
+		model string
+		want  string
+	}{
+		{"claude-sonnet-4-5-20250929", "claude-sonnet-4-5-20250929"},
+		{"claude-sonnet-4-5-20260101", "claude-sonnet-4-5*"},
+		{"claude-opus-9", "claude-*"},
+		{"MY-OPUS", "re:^my-(opus|haiku)$"},
+		{"anthropic/claude-haiku-4-5-latest", "claude-haiku-4-5-20251001"},
+		{"anthropic.claude-3-5-sonnet-20241022-v2:0", "claude-3-5-sonnet-20241022"},
+		{"reseller-x", ""},
+		{"gpt-4o", ""},
+	}
+	for _, c := range cases {
+		got, err := st.ResolveModelPricing(ctx, c.model)
+		if c.want == "" {
+			if err != store.ErrNotFound {
+				t.Fatalf("%s: expected not found, got %+v %v", c.model, got, err)
+			}
+			continue
+		}
+		if err != nil || got.ModelID != c.want {
+			t.Fatalf("%s: got %+v (%v), want %s", c.model, got, err, c.want)
+		}
+	}
+
+	cost, err := st.CalculateCost(ctx, "claude-sonnet-4-5-20260101", store.TokenUsage{InputTokens: 1_000_000}, time.Time{})
+	if err != nil || math.Abs(cost.CostUSD-9) > 1e-9 || cost.PricingModelID != "claude-sonnet-4-5*" || cost.Unpriced {
+		t.Fatalf("unexpected pattern cost %+v (%v)", cost, err)
+	}
+	if cost, _ = st.CalculateCost(ctx, "gpt-4o", store.TokenUsage{InputTokens: 1}, time.Time{}); !cost.Unpriced {
+		t.Fatalf("expected unpriced result, got %+v", cost)
+	}
+}
+
+// TestUnpricedModelsAndReprice 测试未定价标记、未定价模型列表与历史日志重新计费
+func TestUnpricedModelsAndReprice(t *testing.T) {
+	st, err := store.OpenSQLite(filepath.Join(t.TempDir(), "reprice.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer st.Close()
+	srv := newClusterTestServer(t, NewLocalClusterBus(), "a")
+	srv.store = st
+	adminCtx := context.WithValue(context.Background(), isAdminContextKey{}, true)
+
+	srv.recordMetrics(context.Background(), "n1", time.Now(), &metricsWriter{status: http.StatusOK}, &usage{input: 2_000_000, output: 500_000, modelID: "new-model-1"}, 0, 0, true)
+
+	listUnpriced := func() []store.UnpricedModel {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		srv.handleUnpricedModels(rec, httptest.NewRequest(http.MethodGet, "/api/pricing/unpriced", nil).WithContext(adminCtx))
+		var resp struct {
+			Models []store.UnpricedModel `json:"models"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); rec.Code != http.StatusOK || err != nil {
+			t.Fatalf("list unpriced failed: %d %s", rec.Code, rec.Body.String())
+		}
+		return resp.Models
+	}
+	models := listUnpriced()
+	if len(models) != 1 || models[0].ModelID != "new-model-1" || models[0].Requests != 1 || models[0].InputTokens != 2_000_000 ||
+		models[0].MatchedPricing != "" || time.Since(models[0].LastSeen).Abs() > time.Minute {
+		t.Fatalf("unexpected unpriced models %+v", models)
+	}
+
+	rec := httptest.NewRecorder()
+	srv.handlePricing(rec, httptest.NewRequest(http.MethodPost, "/api/pricing", strings.NewReader(`{"model_id":"re:new-(model"}`)).WithContext(adminCtx))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid regex, got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	srv.handlePricing(rec, httptest.NewRequest(http.MethodPost, "/api/pricing", strings.NewReader(`{"model_id":"new-model-*","input_price_mtok":1,"output_price_mtok":2,"is_active":true}`)).WithContext(adminCtx))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("create pattern pricing failed: %d %s", rec.Code, rec.Body.String())
+	}
+	if models = listUnpriced(); len(models) != 1 || models[0].MatchedPricing != "new-model-*" {
+		t.Fatalf("expected matched pricing hint, got %+v", models)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.handleRepriceUsage(rec, httptest.NewRequest(http.MethodPost, "/api/pricing/reprice", strings.NewReader(`{}`)).WithContext(context.Background()))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for non-admin reprice, got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	srv.handleRepriceUsage(rec, httptest.NewRequest(http.MethodPost, "/api/pricing/reprice", strings.NewReader(`{"model_id":"new-model-1"}`)).WithContext(adminCtx))
+	var result store.RepriceResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil || result.Updated != 1 || result.StillUnpriced != 0 {
+		t.Fatalf("unexpected reprice result %d %s", rec.Code, rec.Body.String())
+	}
+	logs, err := st.QueryUsageLogs(context.Background(), store.QueryUsageParams{AccountID: "acc-1"})
+	if err != nil || len(logs) != 1 || logs[0].Unpriced || math.Abs(logs[0].CostUSD-3) > 1e-9 {
+		t.Fatalf("unexpected repriced log %+v (%v)", logs, err)
+	}
+	if models = listUnpriced(); len(models) != 0 {
+		t.Fatalf("expected no unpriced models after reprice, got %+v", models)
+	}
+}
+
+// TestUnpricedMigrationSkipsPricedModels 测试补充未定价标记列时仅标记没有定价条目的模型
+func TestUnpricedMigrationSkipsPricedModels(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "migrate.db")
+	st, err := store.OpenSQLite(path)
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	ctx := context.Background()
+	for _, model := range []string{"claude-sonnet-4-5", "free-model", "mystery-model"} {
+		if err := st.InsertUsageLog(ctx, store.UsageLogRecord{AccountID: "acc-1", NodeID: "n1", ModelID: model, InputTokens: 10}); err != nil {
+			t.Fatalf("insert usage log: %v", err)
+		}
+	}
+	if err := st.UpsertModelPricing(ctx, store.ModelPricingRecord{ModelID: "free-model", IsActive: true}); err != nil {
+		t.Fatalf("upsert pricing: %v", err)
+	}
+	st.Close()
+
+	// 模拟升级前的表结构：去掉标记列后重新打开触发迁移。
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("open raw sqlite: %v", err)
+	}
+	if _, err := db.Exec(`ALTER TABLE usage_logs DROP COLUMN unpriced`); err != nil {
+		t.Fatalf("drop column: %v", err)
+	}
+	db.Close()
+
+	st, err = store.OpenSQLite(path)
+	if err != nil {
+		t.Fatalf("reopen sqlite: %v", err)
+	}
+	defer st.Close()
+	models, err := st.ListUnpricedModels(ctx, time.Time{}, time.Time{})
+	if err != nil || len(models) != 1 || models[0].ModelID != "mystery-model" {
+		t.Fatalf("expected only mystery-model flagged, got %+v %v", models, err)
+	}
+}
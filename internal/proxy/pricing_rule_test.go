@@ -0,0 +1,207 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"qcc_plus/internal/store"
+)
+
+// TestCalculateCostPricingRules 测试长上下文分段、生效期与倍率规则的命中顺序
+func TestCalculateCostPricingRules(t *testing.T) {
+	st, err := store.OpenSQLite(filepath.Join(t.TempDir(), "rules.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer st.Close()
+	ctx := context.Background()
+
+	if err := st.UpsertModelPricing(ctx, store.ModelPricingRecord{ModelID: "tiered", ModelName: "Tiered", InputPriceMTok: 3, OutputPriceMTok: 15, IsActive: true}); err != nil {
+		t.Fatalf("upsert pricing: %v", err)
+	}
+	changeAt := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	batchFrom, batchTo := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	rules := []store.PricingRuleRecord{
+		{ID: "long", ModelID: "tiered", InputThreshold: 200_000, InputPriceMTok: 6, OutputPriceMTok: 22.5, IsActive: true},
+		{ID: "price-change", ModelID: "tiered", InputPriceMTok: 4, EffectiveFrom: &changeAt, IsActive: true},
+		{ID: "batch", ModelID: "tiered", Multiplier: 0.5, Priority: 10, EffectiveFrom: &batchFrom, EffectiveTo: &batchTo, IsActive: true},
+		{ID: "disabled", ModelID: "tiered", Multiplier: 100, Priority: 100, IsActive: false},
+	}
+	for _, r := range rules {
+		if _, err := st.UpsertPricingRule(ctx, r); err != nil {
+			t.Fatalf("upsert rule %s: %v", r.ID, err)
+		}
+	}
+
+	before := changeAt.Add(-time.Hour)
+	after := changeAt.Add(time.Hour)
+	cases := []struct {
+		name string
+		at   time.Time
+		use  store.TokenUsage
+		cost float64
+		rule string
+	}{
+		{"base price", before, store.TokenUsage{InputTokens: 100_000}, 0.3, ""},
+		// 200K 输入 + 100K 缓存读取超过阈值，缓存读取按长上下文输入价格 0.1 倍推导
+		{"long context", before, store.TokenUsage{InputTokens: 200_000, CacheReadTokens: 100_000}, 1.26, "long"},
+		{"price change keeps history", after, store.TokenUsage{InputTokens: 100_000}, 0.4, "price-change"},
+		{"higher threshold wins", after, store.TokenUsage{InputTokens: 300_000}, 1.8, "long"},
+		// 批量倍率与生效期价格逐字段叠加：(0.1M×4 + 0.1M×15) × 0.5
+		{"priority multiplier", batchFrom, store.TokenUsage{InputTokens: 100_000, OutputTokens: 100_000}, 0.95, "batch"},
+		{"effective_to exclusive", batchTo, store.TokenUsage{InputTokens: 100_000}, 0.4, "price-change"},
+	}
+	for _, c := range cases {
+		got, err := st.CalculateCost(ctx, "tiered", c.use, c.at)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if math.Abs(got.CostUSD-c.cost) > 1e-9 || got.PricingRuleID != c.rule {
+			t.Fatalf("%s: got %+v, want cost %v rule %q", c.name, got, c.cost, c.rule)
+		}
+	}
+
+	// 无基础价格时仅凭规则价格计费
+	if _, err := st.UpsertPricingRule(ctx, store.PricingRuleRecord{ID: "only-rule", ModelID: "rule-only", InputPriceMTok: 1, IsActive: true}); err != nil {
+		t.Fatalf("upsert rule: %v", err)
+	}
+	if got, _ := st.CalculateCost(ctx, "rule-only", store.TokenUsage{InputTokens: 1_000_000}, time.Time{}); math.Abs(got.CostUSD-1) > 1e-9 || got.PricingRuleID != "only-rule" {
+		t.Fatalf("unexpected rule-only cost %+v", got)
+	}
+}
+
+// TestPricingRulesAPIAndUsageLog 测试规则管理接口校验与使用日志记录命中规则
+func TestPricingRulesAPIAndUsageLog(t *testing.T) {
+	st, err := store.OpenSQLite(filepath.Join(t.TempDir(), "rules-api.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer st.Close()
+	srv := newClusterTestServer(t, NewLocalClusterBus(), "a")
+	srv.store = st
+	adminCtx := context.WithValue(context.Background(), isAdminContextKey{}, true)
+
+	post := func(ctx context.Context, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.handlePricingRules(rec, httptest.NewRequest(http.MethodPost, "/api/pricing/rules", strings.NewReader(body)).WithContext(ctx))
+		return rec
+	}
+	if rec := post(context.Background(), `{"model_id":"claude-sonnet-4-5-20250929"}`); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for non-admin, got %d", rec.Code)
+	}
+	if rec := post(adminCtx, `{"model_id":"claude-sonnet-4-5-20250929","effective_from":"2026-02-01T00:00:00Z","effective_to":"2026-01-01T00:00:00Z"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for inverted range, got %d", rec.Code)
+	}
+	rec := post(adminCtx, `{"model_id":"claude-sonnet-4-5-20250929","name":"Long context","input_threshold":200000,"input_price_mtok":6,"output_price_mtok":22.5,"is_active":true}`)
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); rec.Code != http.StatusOK || err != nil || created.ID == "" {
+		t.Fatalf("create rule failed: %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	srv.handlePricingRules(rec, httptest.NewRequest(http.MethodGet, "/api/pricing/rules?model_id=claude-sonnet-4-5-20250929", nil))
+	var listed struct {
+		Rules []store.PricingRuleRecord `json:"rules"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &listed); err != nil || len(listed.Rules) != 1 || listed.Rules[0].InputThreshold != 200_000 {
+		t.Fatalf("unexpected rule list %v %s", err, rec.Body.String())
+	}
+
+	mw := &metricsWriter{status: http.StatusOK}
+	srv.recordMetrics(context.Background(), "n1", time.Now(), mw, &usage{input: 250_000, output: 1000, modelID: "claude-sonnet-4-5-20250929"}, 0, 0, true)
+	logs, err := st.QueryUsageLogs(context.Background(), store.QueryUsageParams{AccountID: "acc-1"})
+	if err != nil || len(logs) != 1 {
+		t.Fatalf("expected one usage log, got %d (%v)", len(logs), err)
+	}
+	if logs[0].PricingRuleID != created.ID || math.Abs(logs[0].CostUSD-(1.5+0.0225)) > 1e-9 {
+		t.Fatalf("unexpected usage log %+v", logs[0])
+	}
+
+	rec = httptest.NewRecorder()
+	srv.handlePricingRules(rec, httptest.NewRequest(http.MethodDelete, "/api/pricing/rules?id="+created.ID, nil).WithContext(adminCtx))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("delete rule failed: %d", rec.Code)
+	}
+}
+
+// TestModelPricingHistory 测试基础价格按生效时间计费，改价后历史用量仍按旧价格计算
+func TestModelPricingHistory(t *testing.T) {
+	st, err := store.OpenSQLite(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer st.Close()
+	ctx := context.Background()
+
+	if err := st.UpsertModelPricing(ctx, store.ModelPricingRecord{ModelID: "dated", InputPriceMTok: 3, OutputPriceMTok: 15, IsActive: true}); err != nil {
+		t.Fatalf("upsert pricing: %v", err)
+	}
+	changeAt := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	if err := st.UpsertModelPricing(ctx, store.ModelPricingRecord{ModelID: "dated", InputPriceMTok: 5, OutputPriceMTok: 25, IsActive: true, EffectiveFrom: &changeAt}); err != nil {
+		t.Fatalf("upsert new price: %v", err)
+	}
+	use := store.TokenUsage{InputTokens: 1_000_000}
+	for _, c := range []struct {
+		at   time.Time
+		cost float64
+	}{{changeAt.Add(-time.Hour), 3}, {changeAt, 5}, {changeAt.AddDate(0, 1, 0), 5}} {
+		got, err := st.CalculateCost(ctx, "dated", use, c.at)
+		if err != nil || math.Abs(got.CostUSD-c.cost) > 1e-9 {
+			t.Fatalf("cost at %s: got %+v err %v, want %v", c.at, got, err, c.cost)
+		}
+	}
+
+	// 仅修改名称不产生新的价格版本
+	if err := st.UpsertModelPricing(ctx, store.ModelPricingRecord{ModelID: "dated", ModelName: "Dated", InputPriceMTok: 5, OutputPriceMTok: 25, IsActive: true}); err != nil {
+		t.Fatalf("rename pricing: %v", err)
+	}
+	if got, _ := st.CalculateCost(ctx, "dated", use, changeAt.Add(-time.Hour)); math.Abs(got.CostUSD-3) > 1e-9 {
+		t.Fatalf("rename changed historical cost: %+v", got)
+	}
+}
+
+// TestPricingCacheClusterInvalidation 测试其他实例修改定价后通过集群事件失效本实例的定价快照
+func TestPricingCacheClusterInvalidation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pricing-cluster.db")
+	stA, err := store.OpenSQLite(path)
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer stA.Close()
+	stB, err := store.OpenSQLite(path)
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer stB.Close()
+
+	bus := NewLocalClusterBus()
+	a := newClusterTestServer(t, bus, "a")
+	a.store = stA
+	b := newClusterTestServer(t, bus, "b")
+	b.store = stB
+	ctx := context.Background()
+	use := store.TokenUsage{InputTokens: 1_000_000}
+
+	if got, _ := stB.CalculateCost(ctx, "cached-model", use, time.Time{}); !got.Unpriced {
+		t.Fatalf("expected unpriced before create, got %+v", got)
+	}
+	adminCtx := context.WithValue(ctx, isAdminContextKey{}, true)
+	rec := httptest.NewRecorder()
+	a.handlePricing(rec, httptest.NewRequest(http.MethodPost, "/api/pricing",
+		strings.NewReader(`{"model_id":"cached-model","input_price_mtok":2,"output_price_mtok":10,"is_active":true}`)).WithContext(adminCtx))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("create pricing failed: %d %s", rec.Code, rec.Body.String())
+	}
+	if got, _ := stB.CalculateCost(ctx, "cached-model", use, time.Time{}); got.Unpriced || math.Abs(got.CostUSD-2) > 1e-9 {
+		t.Fatalf("remote instance kept stale pricing: %+v", got)
+	}
+}
@@ -0,0 +1,443 @@
+package proxy
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"qcc_plus/internal/logging"
+)
+
+// 默认延迟桶（秒），覆盖首字节与长流式响应。
+var defaultLatencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+// 健康探测延迟桶（秒）。
+var probeLatencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// PrometheusConfig /metrics 端点配置。
+type PrometheusConfig struct {
+	Enabled bool
+	Token   string // 非空时要求 Authorization: Bearer <token>
+}
+
+// loadPrometheusConfig 读取 /metrics 配置；端点会暴露节点与账号信息，默认关闭，需显式开启。
+func loadPrometheusConfig(logger *logging.Logger) PrometheusConfig {
+	cfg := PrometheusConfig{
+		Enabled: parseEnvBool("METRICS_ENABLED", false, logger),
+		Token:   strings.TrimSpace(os.Getenv("METRICS_TOKEN")),
+	}
+	if cfg.Enabled && cfg.Token == "" {
+		logger.Warn("METRICS_ENABLED without METRICS_TOKEN, /metrics is publicly readable")
+	}
+	return cfg
+}
+
+// promVec 带标签的计数器/直方图集合，仅保存在进程内。
+type promVec struct {
+	name    string
+	help    string
+	kind    string // counter | histogram
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*promSeries
+}
+
+type promSeries struct {
+	values []string
+	value  float64  // counter
+	counts []uint64 // histogram 各桶（非累计）计数，最后一个为 +Inf
+	sum    float64
+	count  uint64
+}
+
+func newPromCounter(name, help string, labels ...string) *promVec {
+	return &promVec{name: name, help: help, kind: "counter", labels: labels, series: make(map[string]*promSeries)}
+}
+
+func newPromHistogram(name, help string, buckets []float64, labels ...string) *promVec {
+	return &promVec{name: name, help: help, kind: "histogram", labels: labels, buckets: buckets, series: make(map[string]*promSeries)}
+}
+
+func (v *promVec) get(values []string) *promSeries {
+	key := strings.Join(values, "\xff")
+	s, ok := v.series[key]
+	if !ok {
+		s = &promSeries{values: append([]string(nil), values...)}
+		if v.kind == "histogram" {
+			s.counts = make([]uint64, len(v.buckets)+1)
+		}
+		v.series[key] = s
+	}
+	return s
+}
+
+// Add 计数器累加。
+func (v *promVec) Add(delta float64, values ...string) {
+	if v == nil || delta <= 0 {
+		return
+	}
+	v.mu.Lock()
+	v.get(values).value += delta
+	v.mu.Unlock()
+}
+
+// Inc 计数器加一。
+func (v *promVec) Inc(values ...string) { v.Add(1, values...) }
+
+// Observe 直方图记录一次观测值（秒）。
+func (v *promVec) Observe(val float64, values ...string) {
+	if v == nil || math.IsNaN(val) || val < 0 {
+		return
+	}
+	v.mu.Lock()
+	s := v.get(values)
+	idx := sort.SearchFloat64s(v.buckets, val)
+	s.counts[idx]++
+	s.sum += val
+	s.count++
+	v.mu.Unlock()
+}
+
+// deleteLabel 删除指定标签取该值的全部序列；向量没有该标签时不做处理。
+func (v *promVec) deleteLabel(label, value string) {
+	if v == nil {
+		return
+	}
+	idx := -1
+	for i, l := range v.labels {
+		if l == label {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return
+	}
+	v.mu.Lock()
+	for k, s := range v.series {
+		if s.values[idx] == value {
+			delete(v.series, k)
+		}
+	}
+	v.mu.Unlock()
+}
+
+func (v *promVec) write(w io.Writer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	writePromHeader(w, v.name, v.help, v.kind)
+	keys := make([]string, 0, len(v.series))
+	for k := range v.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s := v.series[k]
+		if v.kind == "counter" {
+			fmt.Fprintf(w, "%s%s %s\n", v.name, promLabels(v.labels, s.values), formatPromValue(s.value))
+			continue
+		}
+		names := appendLabel(v.labels, "le")
+		var cum uint64
+		for i, b := range v.buckets {
+			cum += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", v.name, promLabels(names, appendLabel(s.values, formatPromValue(b))), cum)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", v.name, promLabels(names, appendLabel(s.values, "+Inf")), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", v.name, promLabels(v.labels, s.values), formatPromValue(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", v.name, promLabels(v.labels, s.values), s.count)
+	}
+}
+
+func appendLabel(xs []string, v string) []string {
+	out := make([]string, 0, len(xs)+1)
+	return append(append(out, xs...), v)
+}
+
+func writePromHeader(w io.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func promLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, n := range names {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		val := ""
+		if i < len(values) {
+			val = values[i]
+		}
+		sb.WriteString(n)
+		sb.WriteString(`="`)
+		sb.WriteString(promEscape(val))
+		sb.WriteByte('"')
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+var promEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func promEscape(s string) string { return promEscaper.Replace(s) }
+
+func formatPromValue(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// promGauge 采集时计算的瞬时值。
+type promGauge struct {
+	labels []string
+	value  float64
+}
+
+func writePromGauge(w io.Writer, name, help string, labelNames []string, samples []promGauge) {
+	writePromHeader(w, name, help, "gauge")
+	for _, s := range samples {
+		fmt.Fprintf(w, "%s%s %s\n", name, promLabels(labelNames, s.labels), formatPromValue(s.value))
+	}
+}
+
+// promMetrics 代理运行时指标（进程内注册表，不查询数据库）。
+type promMetrics struct {
+	requests      *promVec
+	tokens        *promVec
+	cost          *promVec
+	retries       *promVec
+	ttfb          *promVec
+	duration      *promVec
+	healthLatency *promVec
+	budget        *promVec
+}
+
+func newPromMetrics() *promMetrics {
+	return &promMetrics{
+		requests:      newPromCounter("qcc_requests_total", "Proxied upstream attempts by status class.", "account", "node", "status_class"),
+		tokens:        newPromCounter("qcc_tokens_total", "Tokens reported by upstream.", "account", "node", "direction"),
+		cost:          newPromCounter("qcc_cost_usd_total", "Billed cost in USD.", "account", "node"),
+		retries:       newPromCounter("qcc_retries_total", "Retries to another node after a failed attempt.", "account", "node"),
+		ttfb:          newPromHistogram("qcc_ttfb_seconds", "Time to first byte of upstream responses.", defaultLatencyBuckets, "account", "node"),
+		duration:      newPromHistogram("qcc_request_duration_seconds", "Upstream attempt duration including streaming.", defaultLatencyBuckets, "account", "node"),
+		healthLatency: newPromHistogram("qcc_health_probe_duration_seconds", "Health probe latency.", probeLatencyBuckets, "account", "node", "method"),
+		budget:        newPromCounter("qcc_retry_budget_exhausted_total", "Retries rejected because the retry budget was exhausted.", "account", "stage"),
+	}
+}
+
+func (m *promMetrics) vecs() []*promVec {
+	return []*promVec{m.requests, m.tokens, m.cost, m.retries, m.ttfb, m.duration, m.healthLatency, m.budget}
+}
+
+// removeNode 节点删除后清理其标签序列，避免已删除节点的指标一直导出。
+func (m *promMetrics) removeNode(nodeID string) {
+	if m == nil {
+		return
+	}
+	for _, v := range m.vecs() {
+		v.deleteLabel("node", nodeID)
+	}
+}
+
+// removeAccount 账号删除后清理其标签序列。
+func (m *promMetrics) removeAccount(accountID string) {
+	if m == nil {
+		return
+	}
+	for _, v := range m.vecs() {
+		v.deleteLabel("account", accountID)
+	}
+}
+
+// observeAttempt 记录一次上游尝试。
+func (m *promMetrics) observeAttempt(accountID, nodeID string, start, end time.Time, mw *metricsWriter, u *usage) {
+	if m == nil {
+		return
+	}
+	status := http.StatusOK
+	if mw != nil {
+		status = mw.status
+	}
+	m.requests.Inc(accountID, nodeID, statusClass(status))
+	if mw != nil && mw.firstWrite {
+		m.ttfb.Observe(mw.firstAt.Sub(start).Seconds(), accountID, nodeID)
+	}
+	m.duration.Observe(end.Sub(start).Seconds(), accountID, nodeID)
+	if u != nil {
+		m.tokens.Add(float64(u.input), accountID, nodeID, "input")
+		m.tokens.Add(float64(u.output), accountID, nodeID, "output")
+		m.tokens.Add(float64(u.cacheCreation), accountID, nodeID, "cache_creation")
+		m.tokens.Add(float64(u.cacheRead), accountID, nodeID, "cache_read")
+	}
+}
+
+func (m *promMetrics) observeRetry(accountID, nodeID string) {
+	if m != nil {
+		m.retries.Inc(accountID, nodeID)
+	}
+}
+
+func (m *promMetrics) observeBudgetExhausted(accountID, stage string) {
+	if m != nil {
+		m.budget.Inc(accountID, stage)
+	}
+}
+
+func (m *promMetrics) observeCost(accountID, nodeID string, costUSD float64) {
+	if m != nil {
+		m.cost.Add(costUSD, accountID, nodeID)
+	}
+}
+
+func (m *promMetrics) observeProbe(accountID, nodeID, method string, latency time.Duration) {
+	if m != nil && latency > 0 {
+		m.healthLatency.Observe(latency.Seconds(), accountID, nodeID, method)
+	}
+}
+
+func statusClass(status int) string {
+	switch {
+	case status <= 0:
+		return "unknown"
+	case status == 499:
+		return "canceled"
+	default:
+		return fmt.Sprintf("%dxx", status/100)
+	}
+}
+
+// GET /metrics
+// Prometheus 文本格式指标；配置 METRICS_TOKEN 时需携带 Bearer Token。
+func (p *Server) handlePrometheusMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if token := p.promConfig.Token; token != "" {
+		got := strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	p.writePrometheus(bw)
+	bw.Flush()
+}
+
+func (p *Server) writePrometheus(w io.Writer) {
+	if p.prom != nil {
+		for _, v := range p.prom.vecs() {
+			v.write(w)
+		}
+	}
+
+	// 节点状态与熔断器状态在采集时从内存读取
+	type nodeRef struct {
+		accountID, nodeID, name string
+		failed, disabled        bool
+		weight                  int
+	}
+	p.mu.RLock()
+	nodes := make([]nodeRef, 0, len(p.nodeIndex))
+	for _, n := range p.nodeIndex {
+		accID := n.AccountID
+		if acc := p.nodeAccount[n.ID]; acc != nil {
+			accID = acc.ID
+		}
+		nodes = append(nodes, nodeRef{accountID: accID, nodeID: n.ID, name: n.Name, failed: n.Failed || p.isInFailedSet(p.nodeAccount[n.ID], n.ID), disabled: n.Disabled, weight: n.Weight})
+	}
+	p.mu.RUnlock()
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].accountID != nodes[j].accountID {
+			return nodes[i].accountID < nodes[j].accountID
+		}
+		return nodes[i].nodeID < nodes[j].nodeID
+	})
+
+	labels := []string{"account", "node"}
+	var info, up, failed, disabled, breaker []promGauge
+	for _, n := range nodes {
+		lv := []string{n.accountID, n.nodeID}
+		info = append(info, promGauge{labels: []string{n.accountID, n.nodeID, n.name, strconv.Itoa(n.weight)}, value: 1})
+		up = append(up, promGauge{labels: lv, value: boolGauge(!n.failed && !n.disabled)})
+		failed = append(failed, promGauge{labels: lv, value: boolGauge(n.failed)})
+		disabled = append(disabled, promGauge{labels: lv, value: boolGauge(n.disabled)})
+		if p.cbConfig.Enabled {
+			p.cbMu.RLock()
+			cb := p.circuitBreakers[n.nodeID]
+			p.cbMu.RUnlock()
+			state := StateClosed
+			if cb != nil {
+				state = cb.GetState()
+			}
+			breaker = append(breaker, promGauge{labels: lv, value: float64(state)})
+		}
+	}
+	writePromGauge(w, "qcc_node_info", "Node metadata.", []string{"account", "node", "name", "weight"}, info)
+	writePromGauge(w, "qcc_node_up", "1 if the node is neither failed nor disabled.", labels, up)
+	writePromGauge(w, "qcc_node_failed", "1 if the node is marked failed.", labels, failed)
+	writePromGauge(w, "qcc_node_disabled", "1 if the node is disabled.", labels, disabled)
+	writePromGauge(w, "qcc_circuit_breaker_state", "Circuit breaker state (0=closed, 1=open, 2=half-open).", labels, breaker)
+
+	if p.notifyMgr != nil {
+		depth, capacity, dropped := p.notifyMgr.QueueStats()
+		writePromGauge(w, "qcc_notify_queue_depth", "Pending notification events.", nil, []promGauge{{value: float64(depth)}})
+		writePromGauge(w, "qcc_notify_queue_capacity", "Notification queue capacity.", nil, []promGauge{{value: float64(capacity)}})
+		writePromHeader(w, "qcc_notify_dropped_total", "Notification events dropped because the queue was full.", "counter")
+		fmt.Fprintf(w, "qcc_notify_dropped_total %d\n", dropped)
+	}
+
+	if rb := p.retryBudget; rb != nil && rb.cfg.Enabled {
+		rb.mu.Lock()
+		ids := make([]string, 0, len(rb.accounts))
+		for id := range rb.accounts {
+			ids = append(ids, id)
+		}
+		rb.mu.Unlock()
+		sort.Strings(ids)
+		tokens := make([]promGauge, 0, len(ids))
+		for _, id := range ids {
+			tokens = append(tokens, promGauge{labels: []string{id}, value: rb.account(id).Snapshot().Tokens})
+		}
+		writePromGauge(w, "qcc_retry_budget_tokens", "Retry budget tokens available per account.", []string{"account"}, tokens)
+		writePromGauge(w, "qcc_retry_budget_global_tokens", "Retry budget tokens available globally.", nil, []promGauge{{value: rb.global.Snapshot().Tokens}})
+	}
+
+	if p.store != nil {
+		st := p.store.Stats()
+		writePromGauge(w, "qcc_db_open_connections", "Open DB connections.", nil, []promGauge{{value: float64(st.OpenConnections)}})
+		writePromGauge(w, "qcc_db_in_use_connections", "DB connections in use.", nil, []promGauge{{value: float64(st.InUse)}})
+		writePromGauge(w, "qcc_db_idle_connections", "Idle DB connections.", nil, []promGauge{{value: float64(st.Idle)}})
+		writePromGauge(w, "qcc_db_max_open_connections", "Maximum open DB connections.", nil, []promGauge{{value: float64(st.MaxOpenConnections)}})
+		writePromHeader(w, "qcc_db_wait_count_total", "Connections waited for.", "counter")
+		fmt.Fprintf(w, "qcc_db_wait_count_total %d\n", st.WaitCount)
+		writePromHeader(w, "qcc_db_wait_seconds_total", "Total time blocked waiting for a connection.", "counter")
+		fmt.Fprintf(w, "qcc_db_wait_seconds_total %s\n", formatPromValue(st.WaitDuration.Seconds()))
+	}
+}
+
+func boolGauge(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,117 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"qcc_plus/internal/logging"
+)
+
+// TestPromHistogramExposition 测试直方图按累计桶输出
+func TestPromHistogramExposition(t *testing.T) {
+	h := newPromHistogram("x_seconds", "test", []float64{0.1, 1}, "node")
+	h.Observe(0.05, "n1")
+	h.Observe(0.5, "n1")
+	h.Observe(5, "n1")
+
+	var buf bytes.Buffer
+	h.write(&buf)
+	out := buf.String()
+	for _, want := range []string{
+		`x_seconds_bucket{node="n1",le="0.1"} 1`,
+		`x_seconds_bucket{node="n1",le="1"} 2`,
+		`x_seconds_bucket{node="n1",le="+Inf"} 3`,
+		`x_seconds_sum{node="n1"} 5.55`,
+		`x_seconds_count{node="n1"} 3`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+}
+
+// TestPromMetricsRemoveNodeAndAccount 测试删除节点与账号后清理对应标签序列
+func TestPromMetricsRemoveNodeAndAccount(t *testing.T) {
+	m := newPromMetrics()
+	now := time.Now()
+	m.observeAttempt("acc1", "n1", now, now.Add(time.Second), nil, &usage{input: 1})
+	m.observeAttempt("acc1", "n2", now, now.Add(time.Second), nil, nil)
+	m.observeAttempt("acc2", "n3", now, now.Add(time.Second), nil, nil)
+	m.observeBudgetExhausted("acc2", retryStageFailover)
+
+	m.removeNode("n1")
+	m.removeAccount("acc2")
+
+	var buf bytes.Buffer
+	for _, v := range m.vecs() {
+		v.write(&buf)
+	}
+	out := buf.String()
+	for _, gone := range []string{`node="n1"`, `account="acc2"`} {
+		if strings.Contains(out, gone) {
+			t.Errorf("expected %s pruned:\n%s", gone, out)
+		}
+	}
+	if !strings.Contains(out, `qcc_requests_total{account="acc1",node="n2",status_class="2xx"} 1`) {
+		t.Errorf("expected n2 series kept:\n%s", out)
+	}
+}
+
+// TestPrometheusEndpoint 测试 /metrics 鉴权与节点、请求指标输出
+func TestPrometheusEndpoint(t *testing.T) {
+	srv := newClusterTestServer(t, NewLocalClusterBus(), "a")
+	srv.prom = newPromMetrics()
+	srv.promConfig = PrometheusConfig{Enabled: true, Token: "secret"}
+	srv.cbConfig.Enabled = true
+	srv.getNode("n2").Disabled = true
+
+	start := time.Now().Add(-2 * time.Second)
+	mw := &metricsWriter{status: http.StatusBadGateway, firstWrite: true, firstAt: start.Add(300 * time.Millisecond)}
+	srv.prom.observeAttempt("acc-1", "n1", start, time.Now(), mw, &usage{input: 10, output: 5})
+	srv.prom.observeRetry("acc-1", "n1")
+
+	rec := httptest.NewRecorder()
+	srv.handlePrometheusMetrics(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without token, got %d", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	srv.handlePrometheusMetrics(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d", rec.Code)
+	}
+	out := rec.Body.String()
+	for _, want := range []string{
+		`qcc_requests_total{account="acc-1",node="n1",status_class="5xx"} 1`,
+		`qcc_tokens_total{account="acc-1",node="n1",direction="input"} 10`,
+		`qcc_retries_total{account="acc-1",node="n1"} 1`,
+		`qcc_ttfb_seconds_bucket{account="acc-1",node="n1",le="0.5"} 1`,
+		`qcc_node_up{account="acc-1",node="n1"} 1`,
+		`qcc_node_disabled{account="acc-1",node="n2"} 1`,
+		`qcc_circuit_breaker_state{account="acc-1",node="n1"} 0`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+}
+
+// TestPrometheusConfigDefaultOff 测试 /metrics 默认关闭，需显式开启
+func TestPrometheusConfigDefaultOff(t *testing.T) {
+	t.Setenv("METRICS_ENABLED", "")
+	if cfg := loadPrometheusConfig(logging.Discard()); cfg.Enabled {
+		t.Fatalf("expected /metrics disabled by default")
+	}
+	t.Setenv("METRICS_ENABLED", "true")
+	t.Setenv("METRICS_TOKEN", "secret")
+	if cfg := loadPrometheusConfig(logging.Discard()); !cfg.Enabled || cfg.Token != "secret" {
+		t.Fatalf("unexpected config %+v", cfg)
+	}
+}
@@ -0,0 +1,423 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"qcc_plus/internal/batcher"
+	"qcc_plus/internal/logging"
+	"qcc_plus/internal/store"
+)
+
+const (
+	// requestLogRetentionKey 请求审计日志保留天数配置项。
+	requestLogRetentionKey = "request_log.retention_days"
+
+	sessionIDHeader        = "X-Claude-Code-Session-Id"
+	errorTypeNoNode        = "no_available_node"
+	requestLogWriteTimeout = 10 * time.Second
+)
+
+// RequestLogConfig 请求审计日志配置。
+type RequestLogConfig struct {
+	Enabled       bool
+	QueueSize     int           // 内存队列容量，写满后丢弃新记录
+	BatchSize     int           // 单次批量写入条数
+	FlushInterval time.Duration // 未满批次的最长等待时间
+	RetentionDays int           // 默认保留天数，设置项 request_log.retention_days 优先
+	// TrustedProxies 可信反向代理网段，仅来自这些地址的 X-Forwarded-For / X-Real-IP 会被采信。
+	TrustedProxies []*net.IPNet
+}
+
+func loadRequestLogConfig(logger *logging.Logger) RequestLogConfig {
+	cfg := RequestLogConfig{
+		Enabled:       parseEnvBool("REQUEST_LOG_ENABLED", true, logger),
+		QueueSize:     parseEnvInt("REQUEST_LOG_QUEUE_SIZE", 4096, logger),
+		BatchSize:     parseEnvInt("REQUEST_LOG_BATCH_SIZE", 200, logger),
+		FlushInterval: parseEnvDuration("REQUEST_LOG_FLUSH_INTERVAL", 2*time.Second, logger),
+		RetentionDays: parseEnvInt("REQUEST_LOG_RETENTION_DAYS", 7, logger),
+	}
+	cfg.TrustedProxies = parseTrustedProxies(os.Getenv("REQUEST_LOG_TRUSTED_PROXIES"), logger)
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 4096
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 200
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 2 * time.Second
+	}
+	return cfg
+}
+
+// requestAudit 审计日志需要的请求侧信息，随 RequestTrace 传递。
+type requestAudit struct {
+	keyHint     string
+	clientIP    string
+	userAgent   string
+	sessionID   string
+	modelID     string
+	stream      bool
+	firstByteAt time.Time // 最后一次尝试写出首字节的时间
+}
+
+// parseTrustedProxies 解析逗号分隔的 IP 或 CIDR 列表，非法项忽略并记录日志。
+func parseTrustedProxies(v string, logger *logging.Logger) []*net.IPNet {
+	var out []*net.IPNet
+	for _, item := range strings.Split(v, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			if ip := net.ParseIP(item); ip != nil {
+				bits := 8 * net.IPv6len
+				if ip.To4() != nil {
+					ip, bits = ip.To4(), 8*net.IPv4len
+				}
+				out = append(out, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+				continue
+			}
+		} else if _, n, err := net.ParseCIDR(item); err == nil {
+			out = append(out, n)
+			continue
+		}
+		logger.Warn("invalid trusted proxy, ignored", "value", item)
+	}
+	return out
+}
+
+// newRequestAudit 记录请求头中的审计信息，代理 Key 仅保留脱敏提示。
+func newRequestAudit(r *http.Request, proxyKey string, trusted []*net.IPNet) requestAudit {
+	a := requestAudit{
+		clientIP:  clientIP(r, trusted),
+		userAgent: r.UserAgent(),
+		sessionID: strings.TrimSpace(r.Header.Get(sessionIDHeader)),
+		stream:    isStreamRequest(r),
+	}
+	if proxyKey != "" {
+		a.keyHint = maskSecret(proxyKey)
+	}
+	return a
+}
+
+// parseBody 从请求体提取模型、流式标记与 Claude Code 会话 ID。
+func (a *requestAudit) parseBody(body []byte) {
+	if len(body) == 0 {
+		return
+	}
+	var payload struct {
+		Model    string `json:"model"`
+		Stream   any    `json:"stream"`
+		Metadata struct {
+			UserID string `json:"user_id"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return
+	}
+	a.modelID = payload.Model
+	if streamFlagEnabled(payload.Stream) {
+		a.stream = true
+	}
+	if sid := sessionFromUserID(payload.Metadata.UserID); sid != "" {
+		a.sessionID = sid
+	}
+}
+
+// sessionFromUserID 解析 Claude Code 的 metadata.user_id，支持
+// "user_<hash>_account_<uuid>_session_<uuid>" 与 JSON 字符串（含 session_id 字段）两种格式。
+func sessionFromUserID(userID string) string {
+	userID = strings.TrimSpace(userID)
+	if userID == "" {
+		return ""
+	}
+	if strings.HasPrefix(userID, "{") {
+		var v struct {
+			SessionID string `json:"session_id"`
+		}
+		if err := json.Unmarshal([]byte(userID), &v); err == nil {
+			return v.SessionID
+		}
+		return ""
+	}
+	if i := strings.LastIndex(userID, "_session_"); i >= 0 {
+		return userID[i+len("_session_"):]
+	}
+	return ""
+}
+
+// clientIP 返回客户端地址。仅当直连地址属于可信代理时才采信 X-Forwarded-For：
+// 从右向左跳过可信代理，取第一个不可信的地址；其次使用 X-Real-IP。
+func clientIP(r *http.Request, trusted []*net.IPNet) string {
+	remote := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+	if !ipTrusted(remote, trusted) {
+		return remote
+	}
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		hops := strings.Split(xff, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := strings.TrimSpace(hops[i])
+			if ip == "" {
+				continue
+			}
+			if i == 0 || !ipTrusted(ip, trusted) {
+				return ip
+			}
+		}
+	}
+	if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); ip != "" {
+		return ip
+	}
+	return remote
+}
+
+func ipTrusted(addr string, trusted []*net.IPNet) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, n := range trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestLogRecord 由完成的请求轨迹生成审计日志。
+func requestLogRecord(t *RequestTrace, last *usage) store.RequestLogRecord {
+	rec := store.RequestLogRecord{
+		RequestID:       t.RequestID,
+		ClientRequestID: t.ClientRequestID,
+		AccountID:       t.AccountID,
+		KeyHint:         t.audit.keyHint,
+		ClientIP:        t.audit.clientIP,
+		UserAgent:       t.audit.userAgent,
+		SessionID:       t.audit.sessionID,
+		ModelID:         t.audit.modelID,
+		Stream:          t.audit.stream,
+		Status:          t.Status,
+		AttemptTrace:    t.attemptsJSON(),
+		DurationMs:      t.DurationMs,
+		CreatedAt:       t.StartedAt,
+	}
+	var lastAttempt *AttemptTrace
+	for i := range t.Attempts {
+		if t.Attempts[i].Skipped == "" {
+			rec.Attempts++
+			lastAttempt = &t.Attempts[i]
+		}
+	}
+	if lastAttempt != nil {
+		rec.NodeID = lastAttempt.NodeID
+		if !t.Success {
+			rec.ErrorType = lastAttempt.ErrorCategory
+		}
+	} else {
+		rec.ErrorType = errorTypeNoNode
+		if rec.Status == 0 {
+			rec.Status = http.StatusServiceUnavailable
+		}
+	}
+	if !t.audit.firstByteAt.IsZero() {
+		rec.TTFBMs = t.audit.firstByteAt.Sub(t.StartedAt).Milliseconds()
+	}
+	if last != nil {
+		if last.modelID != "" {
+			rec.ModelID = last.modelID
+		}
+		tok := last.tokenUsage()
+		rec.InputTokens = tok.InputTokens
+		rec.OutputTokens = tok.OutputTokens
+		rec.CacheCreationTokens = tok.CacheCreationTokens
+		rec.CacheReadTokens = tok.CacheReadTokens
+	}
+	return rec
+}
+
+// requestLogWriter 异步批量写入审计日志，队列满时丢弃以保护请求热路径。
+type requestLogWriter struct {
+	store  *store.Store
+	logger *logging.Logger
+	batch  *batcher.Batcher[store.RequestLogRecord]
+
+	reported int64 // 已记录日志的丢弃条数，仅写入协程访问
+}
+
+func newRequestLogWriter(st *store.Store, cfg RequestLogConfig, logger *logging.Logger) *requestLogWriter {
+	if st == nil || !cfg.Enabled {
+		return nil
+	}
+	w := &requestLogWriter{
+		store:  st,
+		logger: logger.Component("request_log"),
+	}
+	w.batch = batcher.New(batcher.Config{
+		QueueSize:     cfg.QueueSize,
+		BatchSize:     cfg.BatchSize,
+		FlushInterval: cfg.FlushInterval,
+	}, w.flush)
+	return w
+}
+
+// enqueue 非阻塞入队。
+func (w *requestLogWriter) enqueue(rec store.RequestLogRecord) {
+	if w == nil {
+		return
+	}
+	w.batch.Add(rec)
+}
+
+// flush 写入当前批次。整批失败时逐条重试，只丢弃无法写入的记录。
+func (w *requestLogWriter) flush(batch []store.RequestLogRecord) {
+	if dropped := w.batch.Dropped(); dropped > w.reported {
+		w.logger.Warn("request log queue full, records dropped", "dropped", dropped-w.reported)
+		w.reported = dropped
+	}
+	if len(batch) == 0 {
+		return
+	}
+	// 每次写入使用独立的超时，逐条重试不受整批写入耗时影响。
+	insert := func(recs []store.RequestLogRecord) error {
+		ctx, cancel := context.WithTimeout(context.Background(), requestLogWriteTimeout)
+		defer cancel()
+		return w.store.InsertRequestLogs(ctx, recs)
+	}
+	err := insert(batch)
+	if err == nil {
+		return
+	}
+	failed := len(batch)
+	// 整批超时说明数据库不可用，不再逐条重试
+	if len(batch) > 1 && !errors.Is(err, context.DeadlineExceeded) {
+		failed = 0
+		for i := range batch {
+			if e := insert(batch[i : i+1]); e != nil {
+				failed++
+				err = e
+			}
+		}
+	}
+	if failed > 0 {
+		w.logger.Error("write request logs failed", "count", failed, logging.KeyError, err)
+	}
+}
+
+// Stop 写入队列中剩余的记录后返回。
+func (w *requestLogWriter) Stop() {
+	if w == nil {
+		return
+	}
+	w.batch.Close()
+}
+
+// requestLogRetention 返回审计日志保留时长，0 表示不清理。
+func (p *Server) requestLogRetention() time.Duration {
+	return time.Duration(p.requestLogRetentionDays.Load()) * 24 * time.Hour
+}
+
+// applyRequestLogRetention 应用 request_log.retention_days 配置。
+func (p *Server) applyRequestLogRetention(value any) {
+	switch n := value.(type) {
+	case float64:
+		p.requestLogRetentionDays.Store(int64(n))
+	case int:
+		p.requestLogRetentionDays.Store(int64(n))
+	case int64:
+		p.requestLogRetentionDays.Store(n)
+	}
+}
+
+// GET /api/request-logs
+// 分页检索请求审计日志，非管理员只能查询自己账号。
+func (p *Server) handleRequestLogs(w http.ResponseWriter, r *http.Request) {
+	if p.store == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	q := r.URL.Query()
+	params := store.RequestLogQuery{
+		NodeID:          q.Get("node_id"),
+		ModelID:         q.Get("model_id"),
+		SessionID:       q.Get("session_id"),
+		RequestID:       q.Get("request_id"),
+		ClientRequestID: q.Get("client_request_id"),
+		ClientIP:        q.Get("client_ip"),
+		ErrorType:       q.Get("error_type"),
+		FailedOnly:      boolLike(q.Get("failed")),
+	}
+	if !isAdmin(r.Context()) {
+		acc := accountFromCtx(r)
+		if acc == nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "account missing"})
+			return
+		}
+		params.AccountID = acc.ID
+	} else {
+		params.AccountID = q.Get("account_id")
+	}
+	if v := q.Get("status"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid status"})
+			return
+		}
+		params.Status = n
+	}
+	for _, f := range []struct {
+		name string
+		dst  *time.Time
+	}{{"from", &params.From}, {"to", &params.To}} {
+		if v := q.Get(f.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid " + f.name})
+				return
+			}
+			*f.dst = t
+		}
+	}
+	if l, err := strconv.Atoi(q.Get("limit")); err == nil && l > 0 {
+		params.Limit = l
+	}
+	if params.Limit == 0 {
+		params.Limit = 100
+	}
+	if params.Limit > 1000 {
+		params.Limit = 1000
+	}
+	if o, err := strconv.Atoi(q.Get("offset")); err == nil && o >= 0 {
+		params.Offset = o
+	}
+
+	logs, total, err := p.store.QueryRequestLogs(r.Context(), params)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if logs == nil {
+		logs = []store.RequestLogRecord{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"logs":   logs,
+		"total":  total,
+		"limit":  params.Limit,
+		"offset": params.Offset,
+	})
+}
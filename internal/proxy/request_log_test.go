@@ -0,0 +1,173 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"qcc_plus/internal/logging"
+	"qcc_plus/internal/store"
+)
+
+// TestSessionFromUserID 测试从 metadata.user_id 解析 Claude Code 会话 ID
+func TestSessionFromUserID(t *testing.T) {
+	cases := map[string]string{
+		"user_abc123_account_11111111-2222_session_3f2a-9c": "3f2a-9c",
+		`{"device_id":"d1","session_id":"s-42"}`:            "s-42",
+		"user_abc123":                                       "",
+		"":                                                  "",
+	}
+	for in, want := range cases {
+		if got := sessionFromUserID(in); got != want {
+			t.Errorf("sessionFromUserID(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// TestRequestAuditFromRequest 测试审计信息采集：客户端 IP、Key 脱敏、请求体中的模型与流式标记
+func TestRequestAuditFromRequest(t *testing.T) {
+	r := httptest.NewRequest("POST", "/v1/messages", nil)
+	r.RemoteAddr = "10.0.0.9:5123"
+	r.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.1")
+	r.Header.Set("User-Agent", "claude-cli/2.0.0")
+	trusted := parseTrustedProxies("10.0.0.0/8, 192.168.1.1, bad", logging.Discard())
+	if len(trusted) != 2 {
+		t.Fatalf("expected 2 trusted proxies, got %d", len(trusted))
+	}
+	a := newRequestAudit(r, "sk-proxy-0123456789", trusted)
+	a.parseBody([]byte(`{"model":"claude-sonnet-4-5","stream":true,"metadata":{"user_id":"user_x_account_y_session_abc"}}`))
+
+	if a.clientIP != "203.0.113.7" || a.keyHint != "sk-p****6789" || a.userAgent != "claude-cli/2.0.0" {
+		t.Fatalf("unexpected request fields %+v", a)
+	}
+	if a.modelID != "claude-sonnet-4-5" || !a.stream || a.sessionID != "abc" {
+		t.Fatalf("unexpected body fields %+v", a)
+	}
+
+	// 客户端自带的伪造地址位于可信代理链左侧，只取最右侧的不可信地址
+	r.Header.Set("X-Forwarded-For", "1.1.1.1, 203.0.113.7, 10.0.0.1")
+	if ip := clientIP(r, trusted); ip != "203.0.113.7" {
+		t.Fatalf("expected rightmost untrusted hop, got %s", ip)
+	}
+	// 直连地址不可信时忽略转发头
+	r.RemoteAddr = "198.51.100.2:5123"
+	if ip := clientIP(r, trusted); ip != "198.51.100.2" {
+		t.Fatalf("expected spoofed forwarded header ignored, got %s", ip)
+	}
+
+	r = httptest.NewRequest("POST", "/v1/messages", nil)
+	r.RemoteAddr = "10.0.0.9:5123"
+	if ip := clientIP(r, nil); ip != "10.0.0.9" {
+		t.Fatalf("expected remote addr host, got %s", ip)
+	}
+}
+
+// TestRequestLogWriterFlushAndQuery 测试异步批量写入在停止时落盘，并按条件分页检索
+func TestRequestLogWriterFlushAndQuery(t *testing.T) {
+	st, err := store.OpenSQLite(filepath.Join(t.TempDir(), "audit.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer st.Close()
+
+	w := newRequestLogWriter(st, RequestLogConfig{Enabled: true, QueueSize: 100, BatchSize: 3, FlushInterval: time.Hour}, logging.Discard())
+	base := time.Now().UTC().Add(-time.Minute)
+	for i := 0; i < 5; i++ {
+		trace := &RequestTrace{
+			RequestID: "req-" + string(rune('a'+i)),
+			AccountID: "acc1",
+			StartedAt: base.Add(time.Duration(i) * time.Second),
+			Status:    200,
+			Success:   true,
+			Attempts:  []AttemptTrace{{NodeID: "n1", Status: 200}},
+			audit:     requestAudit{sessionID: "s1", modelID: "claude-sonnet-4-5"},
+		}
+		if i == 4 {
+			trace.Status = 529
+			trace.Success = false
+			trace.Attempts = []AttemptTrace{{NodeID: "n1", Skipped: skipBreakerOpen}, {NodeID: "n2", Status: 529, ErrorCategory: "overloaded"}}
+			trace.audit.sessionID = "s2"
+			trace.ClientRequestID = "client-1"
+			trace.audit.clientIP = strings.Repeat("f", 100)
+			trace.audit.modelID = strings.Repeat("m", 300)
+		}
+		w.enqueue(requestLogRecord(trace, &usage{input: 10, output: 5, cacheRead: 100}))
+	}
+	w.Stop()
+
+	ctx := context.Background()
+	logs, total, err := st.QueryRequestLogs(ctx, store.RequestLogQuery{AccountID: "acc1", Limit: 2})
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if total != 5 || len(logs) != 2 || logs[0].RequestID != "req-e" {
+		t.Fatalf("unexpected page total=%d logs=%+v", total, logs)
+	}
+	failed := logs[0]
+	if failed.Attempts != 1 || failed.NodeID != "n2" || failed.ErrorType != "overloaded" || failed.Status != 529 || failed.CacheReadTokens != 100 {
+		t.Fatalf("unexpected failed record %+v", failed)
+	}
+	if len(failed.ClientIP) != 64 || len(failed.ModelID) != 128 {
+		t.Fatalf("expected oversized fields truncated to column width, got ip=%d model=%d", len(failed.ClientIP), len(failed.ModelID))
+	}
+
+	if _, total, _ = st.QueryRequestLogs(ctx, store.RequestLogQuery{SessionID: "s1"}); total != 4 {
+		t.Fatalf("expected 4 logs for session s1, got %d", total)
+	}
+	if got, _, _ := st.QueryRequestLogs(ctx, store.RequestLogQuery{ClientRequestID: "client-1"}); len(got) != 1 || got[0].RequestID != "req-e" {
+		t.Fatalf("expected lookup by client request id, got %+v", got)
+	}
+	if _, total, _ = st.QueryRequestLogs(ctx, store.RequestLogQuery{FailedOnly: true}); total != 1 {
+		t.Fatalf("expected 1 failed log, got %d", total)
+	}
+
+	deleted, err := st.CleanupRequestLogs(ctx, base.Add(2500*time.Millisecond))
+	if err != nil || deleted != 3 {
+		t.Fatalf("cleanup deleted=%d err=%v", deleted, err)
+	}
+}
+
+// TestRequestLogsRoutedThroughMux 测试 /api/request-logs 经完整路由分发到会话校验与查询处理
+func TestRequestLogsRoutedThroughMux(t *testing.T) {
+	st, err := store.OpenSQLite(filepath.Join(t.TempDir(), "request-log-mux.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer st.Close()
+	srv := newClusterTestServer(t, NewLocalClusterBus(), "a")
+	srv.store = st
+	srv.sessionMgr = NewSessionManager(time.Hour)
+	handler := srv.handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/request-logs", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without session, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	sess := srv.sessionMgr.Create("acc-1", true)
+	req := httptest.NewRequest(http.MethodGet, "/api/request-logs", nil)
+	req.AddCookie(&http.Cookie{Name: "session_token", Value: sess.Token})
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 through mux, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	// 超过上限的 limit 截断为 1000，而不是回退到默认值
+	req = httptest.NewRequest(http.MethodGet, "/api/request-logs?limit=5000", nil)
+	req.AddCookie(&http.Cookie{Name: "session_token", Value: sess.Token})
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	var page struct {
+		Limit int `json:"limit"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil || page.Limit != 1000 {
+		t.Fatalf("expected limit clamped to 1000, got %d (%v) %s", page.Limit, err, rec.Body.String())
+	}
+}
@@ -0,0 +1,352 @@
+package proxy
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"qcc_plus/internal/store"
+)
+
+const (
+	requestIDHeader       = "X-Request-ID"
+	clientRequestIDHeader = "X-Client-Request-ID" // 回显客户端传入的 X-Request-ID
+	traceHeader           = "X-Proxy-Trace"       // 调试响应头：节点尝试轨迹
+	traceDebugHeader      = "X-Proxy-Debug"       // 请求头包含 trace 时返回 X-Proxy-Trace
+
+	maxRequestIDLen         = 128
+	defaultTraceBufferSize  = 1000
+	traceHeaderMaxLen       = 2048
+	traceErrorMessageMaxLen = 300
+)
+
+// 节点被跳过的原因。
+const (
+	skipBreakerOpen = "breaker_open" // 熔断器打开
+	skipCooling     = "cooling"      // 节点已标记失败，等待健康检查恢复
+	skipExcluded    = "excluded"     // 节点已禁用，不参与轮转
+	skipRetryBudget = "retry_budget" // 重试预算耗尽，不再切换节点
+)
+
+// AttemptTrace 单个节点的尝试记录；Skipped 非空表示未发送请求。
+type AttemptTrace struct {
+	NodeID        string    `json:"node_id"`
+	NodeName      string    `json:"node_name"`
+	Status        int       `json:"status,omitempty"`
+	ErrorCategory string    `json:"error_category,omitempty"`
+	Error         string    `json:"error,omitempty"`
+	LatencyMs     int64     `json:"latency_ms"`
+	Skipped       string    `json:"skipped,omitempty"`
+	At            time.Time `json:"at"`
+}
+
+// RequestTrace 一次代理请求的完整尝试轨迹。
+// 仅由处理该请求的 goroutine 修改，完成后放入 traceBuffer 只读共享。
+type RequestTrace struct {
+	RequestID       string         `json:"request_id"`                  // 代理生成的请求 ID
+	ClientRequestID string         `json:"client_request_id,omitempty"` // 客户端传入的 X-Request-ID，仅作记录
+	AccountID       string         `json:"account_id"`
+	Method          string         `json:"method"`
+	Path            string         `json:"path"`
+	StartedAt       time.Time      `json:"started_at"`
+	DurationMs      int64          `json:"duration_ms"`
+	Status          int            `json:"status"`
+	Success         bool           `json:"success"`
+	Attempts        []AttemptTrace `json:"attempts"`
+
+	debug        bool      // 是否返回调试响应头
+	attemptStart time.Time // 当前尝试开始时间
+	audit        requestAudit
+}
+
+func newRequestTrace(r *http.Request, requestID, accountID string, debug bool) *RequestTrace {
+	return &RequestTrace{
+		RequestID: requestID,
+		AccountID: accountID,
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		StartedAt: time.Now(),
+		debug:     debug,
+	}
+}
+
+func (t *RequestTrace) add(a AttemptTrace) {
+	if t == nil {
+		return
+	}
+	if a.At.IsZero() {
+		a.At = time.Now()
+	}
+	if len(a.Error) > traceErrorMessageMaxLen {
+		a.Error = strings.ToValidUTF8(a.Error[:traceErrorMessageMaxLen], "")
+	}
+	t.Attempts = append(t.Attempts, a)
+}
+
+// headerValue 生成 X-Proxy-Trace 头，格式：node;status=502;class=server;ms=120, node;skip=breaker_open。
+// 使用节点 ID 以保证头部为 ASCII，current 为正在写出响应的尝试。
+func (t *RequestTrace) headerValue(current *AttemptTrace) string {
+	attempts := t.Attempts
+	if current != nil {
+		attempts = append(attempts[:len(attempts):len(attempts)], *current)
+	}
+	parts := make([]string, 0, len(attempts))
+	for _, a := range attempts {
+		if a.Skipped != "" {
+			parts = append(parts, fmt.Sprintf("%s;skip=%s", a.NodeID, a.Skipped))
+			continue
+		}
+		part := fmt.Sprintf("%s;status=%d", a.NodeID, a.Status)
+		if a.ErrorCategory != "" {
+			part += ";class=" + a.ErrorCategory
+		}
+		parts = append(parts, fmt.Sprintf("%s;ms=%d", part, a.LatencyMs))
+	}
+	v := strings.Join(parts, ", ")
+	if len(v) > traceHeaderMaxLen {
+		v = v[:traceHeaderMaxLen]
+	}
+	return v
+}
+
+func (t *RequestTrace) attemptsJSON() string {
+	if t == nil || len(t.Attempts) == 0 {
+		return ""
+	}
+	b, err := json.Marshal(t.Attempts)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// clientRequestID 返回客户端传入的合法 X-Request-ID，非法或缺失时返回空。
+// 客户端 ID 不可信（可能重复或伪造），轨迹、审计与使用日志一律以代理生成的 ID 为键。
+func clientRequestID(r *http.Request) string {
+	if id := strings.TrimSpace(r.Header.Get(requestIDHeader)); validRequestID(id) {
+		return id
+	}
+	return ""
+}
+
+// assignRequestID 为请求生成代理请求 ID，替换转发给上游的 X-Request-ID 并写入响应头；
+// 客户端传入的合法 ID 通过 X-Client-Request-ID 回显。返回代理请求 ID 与客户端 ID。
+func assignRequestID(w http.ResponseWriter, r *http.Request) (requestID, clientID string) {
+	clientID = clientRequestID(r)
+	requestID = newRequestID()
+	r.Header.Set(requestIDHeader, requestID)
+	w.Header().Set(requestIDHeader, requestID)
+	if clientID != "" {
+		w.Header().Set(clientRequestIDHeader, clientID)
+	}
+	return requestID, clientID
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-' || c == '_' || c == '.' || c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("req-%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// wantTrace 判断是否返回调试响应头：全局开启，或请求头 X-Proxy-Debug 包含 trace。
+func (p *Server) wantTrace(r *http.Request) bool {
+	if p.traceHeader {
+		return true
+	}
+	v := strings.ToLower(r.Header.Get(traceDebugHeader))
+	return strings.Contains(v, "trace") || v == "1" || v == "true"
+}
+
+// skippedNodeTraces 记录请求开始时不参与选择的节点（禁用/冷却中），按权重排序。
+func (p *Server) skippedNodeTraces(acc *Account) []AttemptTrace {
+	if acc == nil {
+		return nil
+	}
+	now := time.Now()
+	p.mu.RLock()
+	var nodes []*Node
+	for id, n := range acc.Nodes {
+		if n.Disabled || n.Failed || p.isInFailedSet(acc, id) {
+			nodes = append(nodes, n)
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Weight < nodes[j].Weight })
+	out := make([]AttemptTrace, 0, len(nodes))
+	for _, n := range nodes {
+		reason := skipCooling
+		if n.Disabled {
+			reason = skipExcluded
+		}
+		out = append(out, AttemptTrace{NodeID: n.ID, NodeName: n.Name, Skipped: reason, At: now})
+	}
+	p.mu.RUnlock()
+	return out
+}
+
+// finishTrace 请求结束时保存轨迹：放入内存缓冲并写入请求审计日志。
+// 计费使用日志只由 recordMetrics 写入真实用量，失败或未计费的请求不再补写零用量记录。
+func (p *Server) finishTrace(t *RequestTrace, last *usage) {
+	if t == nil {
+		return
+	}
+	t.DurationMs = time.Since(t.StartedAt).Milliseconds()
+	p.traces.put(t)
+	if p.requestLogs != nil {
+		p.requestLogs.enqueue(requestLogRecord(t, last))
+	}
+}
+
+// traceBuffer 保存最近的请求轨迹，超出容量时淘汰最早的记录。
+type traceBuffer struct {
+	mu    sync.RWMutex
+	size  int
+	order []string
+	next  int
+	items map[string]*RequestTrace
+}
+
+func newTraceBuffer(size int) *traceBuffer {
+	if size <= 0 {
+		size = defaultTraceBufferSize
+	}
+	return &traceBuffer{size: size, items: make(map[string]*RequestTrace)}
+}
+
+func (b *traceBuffer) put(t *RequestTrace) {
+	if b == nil || t == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.items[t.RequestID]; ok {
+		b.items[t.RequestID] = t
+		return
+	}
+	if len(b.order) < b.size {
+		b.order = append(b.order, t.RequestID)
+	} else {
+		delete(b.items, b.order[b.next])
+		b.order[b.next] = t.RequestID
+		b.next = (b.next + 1) % b.size
+	}
+	b.items[t.RequestID] = t
+}
+
+func (b *traceBuffer) get(id string) (*RequestTrace, bool) {
+	if b == nil {
+		return nil, false
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	t, ok := b.items[id]
+	return t, ok
+}
+
+// GET /admin/api/request-trace?request_id=xxx
+// 返回请求的节点尝试轨迹：优先读取内存中的最近记录，其次查询请求审计日志与使用日志（仅管理员）。
+func (p *Server) handleRequestTrace(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !isAdmin(r.Context()) {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "forbidden"})
+		return
+	}
+	id := strings.TrimSpace(r.URL.Query().Get("request_id"))
+	if id == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "request_id required"})
+		return
+	}
+	if t, ok := p.traces.get(id); ok {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"source": "memory", "trace": t})
+		return
+	}
+	if p.store == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "trace not found"})
+		return
+	}
+	// 请求审计日志覆盖失败与未计费的请求，优先查询；未开启审计日志时回退到使用日志。
+	logs, _, err := p.store.QueryRequestLogs(r.Context(), store.RequestLogQuery{RequestID: id, Limit: 1})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if len(logs) > 0 {
+		rec := logs[0]
+		trace := RequestTrace{
+			RequestID:       rec.RequestID,
+			ClientRequestID: rec.ClientRequestID,
+			AccountID:       rec.AccountID,
+			StartedAt:       rec.CreatedAt,
+			DurationMs:      rec.DurationMs,
+			Status:          rec.Status,
+			Success:         rec.Status == http.StatusOK,
+		}
+		if !decodeStoredAttempts(w, rec.AttemptTrace, &trace) {
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"source": "request_log", "trace": trace, "model_id": rec.ModelID})
+		return
+	}
+	rec, err := p.store.GetUsageLogByRequestID(r.Context(), id)
+	if err == store.ErrNotFound {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "trace not found"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	trace := RequestTrace{
+		RequestID: rec.RequestID,
+		AccountID: rec.AccountID,
+		StartedAt: rec.CreatedAt,
+		Success:   rec.Success,
+	}
+	if !decodeStoredAttempts(w, rec.AttemptTrace, &trace) {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"source":              "usage_log",
+		"trace":               trace,
+		"model_id":            rec.ModelID,
+		"upstream_request_id": rec.UpstreamRequestID,
+	})
+}
+
+// decodeStoredAttempts 解析持久化的尝试轨迹 JSON，失败时写出 500 并返回 false。
+func decodeStoredAttempts(w http.ResponseWriter, raw string, trace *RequestTrace) bool {
+	trace.Attempts = []AttemptTrace{}
+	if raw == "" {
+		return true
+	}
+	if err := json.Unmarshal([]byte(raw), &trace.Attempts); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "invalid stored trace"})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,165 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"qcc_plus/internal/logging"
+	"qcc_plus/internal/store"
+)
+
+// TestAssignRequestID 测试始终生成代理请求 ID，合法的客户端 ID 仅回显，非法时丢弃
+func TestAssignRequestID(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+	r.Header.Set(requestIDHeader, "client-req_1.2:3")
+	w := httptest.NewRecorder()
+	id, clientID := assignRequestID(w, r)
+	if clientID != "client-req_1.2:3" || id == clientID || len(id) != 32 {
+		t.Fatalf("expected generated id and client id kept, got %q %q", id, clientID)
+	}
+	if r.Header.Get(requestIDHeader) != id || w.Header().Get(requestIDHeader) != id || w.Header().Get(clientRequestIDHeader) != clientID {
+		t.Fatalf("unexpected headers: upstream %q, response %v", r.Header.Get(requestIDHeader), w.Header())
+	}
+	for _, bad := range []string{"", "has space", "换行", strings.Repeat("a", maxRequestIDLen+1)} {
+		r.Header.Set(requestIDHeader, bad)
+		if got := clientRequestID(r); got != "" {
+			t.Errorf("expected invalid client id %q dropped, got %q", bad, got)
+		}
+	}
+}
+
+// TestTraceBufferEvictsOldest 测试轨迹缓冲区超出容量时淘汰最早记录
+func TestTraceBufferEvictsOldest(t *testing.T) {
+	b := newTraceBuffer(2)
+	for _, id := range []string{"a", "b", "c"} {
+		b.put(&RequestTrace{RequestID: id})
+	}
+	if _, ok := b.get("a"); ok {
+		t.Fatalf("expected a evicted")
+	}
+	for _, id := range []string{"b", "c"} {
+		if _, ok := b.get(id); !ok {
+			t.Fatalf("expected %s kept", id)
+		}
+	}
+}
+
+// TestRequestTraceHeaderAndSkips 测试请求 ID 回显、调试头与跳过原因
+func TestRequestTraceHeaderAndSkips(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get(requestIDHeader); got == "trace-1" || len(got) != 32 {
+			t.Errorf("expected proxy request id forwarded upstream, got %q", got)
+		}
+		w.Header().Set("X-Request-ID", "upstream-id")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{}`))
+	}))
+	defer up.Close()
+	u, _ := url.Parse(up.URL)
+
+	srv := newClusterTestServer(t, NewLocalClusterBus(), "a")
+	srv.transport = http.DefaultTransport
+	srv.errorClassifier = NewErrorClassifier()
+	srv.traces = newTraceBuffer(10)
+	srv.retryConfig = RetryConfig{MaxAttempts: 3, PerRequestTimeout: 5 * time.Second}
+	acc := srv.TestAccount("acc-1")
+	srv.defaultAccount = acc
+	for _, n := range acc.Nodes {
+		n.URL = u
+	}
+	srv.getNode("n1").Disabled = true
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"m"}`))
+	req.Header.Set(requestIDHeader, "trace-1")
+	req.Header.Set(traceDebugHeader, "trace")
+	rec := httptest.NewRecorder()
+	srv.handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	ids := rec.Header().Values(requestIDHeader)
+	if len(ids) != 1 || ids[0] == "trace-1" {
+		t.Fatalf("expected single proxy request id, got %v", ids)
+	}
+	if got := rec.Header().Get(clientRequestIDHeader); got != "trace-1" {
+		t.Fatalf("expected client request id echoed, got %q", got)
+	}
+	h := rec.Header().Get(traceHeader)
+	if !strings.Contains(h, "n1;skip=excluded") || !strings.Contains(h, "n2;status=200") {
+		t.Fatalf("unexpected trace header %q", h)
+	}
+
+	if _, ok := srv.traces.get("trace-1"); ok {
+		t.Fatalf("client request id must not key the trace buffer")
+	}
+	trace, ok := srv.traces.get(ids[0])
+	if !ok {
+		t.Fatalf("expected trace stored")
+	}
+	if trace.ClientRequestID != "trace-1" || !trace.Success || len(trace.Attempts) != 2 || trace.Attempts[1].NodeID != "n2" {
+		t.Fatalf("unexpected trace: %+v", trace)
+	}
+}
+
+// TestFailedRequestTraceFromRequestLog 测试失败请求不写入零用量使用日志，轨迹从请求审计日志读取
+func TestFailedRequestTraceFromRequestLog(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte(`{"error":"bad gateway"}`))
+	}))
+	defer up.Close()
+	u, _ := url.Parse(up.URL)
+
+	st, err := store.OpenSQLite(filepath.Join(t.TempDir(), "trace.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer st.Close()
+	srv := newClusterTestServer(t, NewLocalClusterBus(), "a")
+	srv.store = st
+	srv.transport = http.DefaultTransport
+	srv.errorClassifier = NewErrorClassifier()
+	srv.traces = newTraceBuffer(10)
+	srv.retryConfig = RetryConfig{MaxAttempts: 2, PerRequestTimeout: 5 * time.Second}
+	srv.requestLogs = newRequestLogWriter(st, RequestLogConfig{Enabled: true, QueueSize: 10, BatchSize: 10, FlushInterval: time.Hour}, logging.Discard())
+	acc := srv.TestAccount("acc-1")
+	srv.defaultAccount = acc
+	for _, n := range acc.Nodes {
+		n.URL = u
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"m"}`))
+	req.Header.Set(requestIDHeader, "failed-1")
+	resp := httptest.NewRecorder()
+	srv.handler().ServeHTTP(resp, req)
+	srv.requestLogs.Stop()
+	requestID := resp.Header().Get(requestIDHeader)
+
+	logs, err := st.QueryUsageLogs(context.Background(), store.QueryUsageParams{AccountID: "acc-1"})
+	if err != nil || len(logs) != 0 {
+		t.Fatalf("expected no usage logs for failed request, got %+v (%v)", logs, err)
+	}
+
+	srv.traces = newTraceBuffer(10)
+	rec := httptest.NewRecorder()
+	adminCtx := context.WithValue(context.Background(), isAdminContextKey{}, true)
+	srv.handleRequestTrace(rec, httptest.NewRequest(http.MethodGet, "/admin/api/request-trace?request_id="+requestID, nil).WithContext(adminCtx))
+	var out struct {
+		Source string       `json:"source"`
+		Trace  RequestTrace `json:"trace"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("unexpected response %d %s", rec.Code, rec.Body.String())
+	}
+	if out.Source != "request_log" || out.Trace.Success || len(out.Trace.Attempts) == 0 || out.Trace.ClientRequestID != "failed-1" {
+		t.Fatalf("unexpected stored trace %+v", out)
+	}
+}
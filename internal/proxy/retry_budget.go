@@ -0,0 +1,273 @@
+package proxy
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"qcc_plus/internal/logging"
+)
+
+const (
+	defaultRetryBudgetRatio        = 0.2
+	defaultRetryBudgetMinPerSec    = 1.0
+	defaultRetryBudgetMaxTokens    = 50.0
+	defaultRetryBudgetGlobalTokens = 500.0
+)
+
+// errRetryBudgetExhausted 重试预算耗尽，停止重试直接返回。
+var errRetryBudgetExhausted = errors.New("retry budget exhausted")
+
+// retryBudgetHeader 预算耗尽时在响应头中标记，便于客户端区分。
+const retryBudgetHeader = "X-Retry-Budget"
+
+// 预算耗尽发生的位置：同节点重试（retryTransport）或切换节点（请求循环）。
+const (
+	retryStageTransport = "transport"
+	retryStageFailover  = "failover"
+)
+
+// RetryBudgetConfig 重试预算配置：成功请求按比例存入令牌，每次重试消耗 1 个令牌。
+type RetryBudgetConfig struct {
+	Enabled         bool    `json:"enabled"`
+	Ratio           float64 `json:"ratio"`             // 每次成功请求存入的令牌数，即允许的重试/成功比例
+	MinPerSecond    float64 `json:"min_per_second"`    // 每秒保底补充的令牌数，保证低流量时仍可少量重试
+	MaxTokens       float64 `json:"max_tokens"`        // 单账号令牌桶容量
+	GlobalMaxTokens float64 `json:"global_max_tokens"` // 全局令牌桶容量
+}
+
+func loadRetryBudgetConfig(logger *logging.Logger) RetryBudgetConfig {
+	cfg := RetryBudgetConfig{
+		Enabled:         parseEnvBool("RETRY_BUDGET_ENABLED", true, logger),
+		Ratio:           parseEnvFloat("RETRY_BUDGET_RATIO", defaultRetryBudgetRatio, logger),
+		MinPerSecond:    parseEnvFloat("RETRY_BUDGET_MIN_PER_SEC", defaultRetryBudgetMinPerSec, logger),
+		MaxTokens:       parseEnvFloat("RETRY_BUDGET_MAX_TOKENS", defaultRetryBudgetMaxTokens, logger),
+		GlobalMaxTokens: parseEnvFloat("RETRY_BUDGET_GLOBAL_MAX_TOKENS", defaultRetryBudgetGlobalTokens, logger),
+	}
+	if cfg.MaxTokens < 1 {
+		cfg.MaxTokens = defaultRetryBudgetMaxTokens
+	}
+	if cfg.GlobalMaxTokens < 1 {
+		cfg.GlobalMaxTokens = defaultRetryBudgetGlobalTokens
+	}
+	return cfg
+}
+
+// RetryBudget 令牌桶：成功请求存入 ratio 个令牌，重试取出 1 个，另按时间保底补充。
+type RetryBudget struct {
+	mu        sync.Mutex
+	ratio     float64
+	minPerSec float64
+	capacity  float64
+	tokens    float64
+	last      time.Time
+
+	deposits    int64
+	retries     int64
+	rejected    int64
+	exhaustedAt time.Time
+
+	now func() time.Time
+}
+
+// RetryBudgetSnapshot 令牌桶状态与累计计数。
+type RetryBudgetSnapshot struct {
+	Tokens      float64 `json:"tokens"`
+	Capacity    float64 `json:"capacity"`
+	Deposits    int64   `json:"deposits"` // 成功请求数（存入次数）
+	Retries     int64   `json:"retries"`  // 已放行的重试次数
+	Rejected    int64   `json:"rejected"` // 因预算耗尽被拒绝的重试次数
+	ExhaustedAt string  `json:"exhausted_at,omitempty"`
+}
+
+// NewRetryBudget 创建令牌桶，初始为满桶，避免启动阶段无法重试。
+func NewRetryBudget(ratio, minPerSec, capacity float64) *RetryBudget {
+	return &RetryBudget{
+		ratio:     ratio,
+		minPerSec: minPerSec,
+		capacity:  capacity,
+		tokens:    capacity,
+		now:       time.Now,
+	}
+}
+
+func (b *RetryBudget) refillLocked() {
+	now := b.now()
+	if !b.last.IsZero() && b.minPerSec > 0 {
+		b.tokens += now.Sub(b.last).Seconds() * b.minPerSec
+	}
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.last = now
+}
+
+// Deposit 记录一次成功请求。
+func (b *RetryBudget) Deposit() {
+	b.mu.Lock()
+	b.refillLocked()
+	b.deposits++
+	b.tokens += b.ratio
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.mu.Unlock()
+}
+
+// TryWithdraw 尝试为一次重试取出令牌。
+func (b *RetryBudget) TryWithdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refillLocked()
+	if b.tokens < 1 {
+		b.rejected++
+		b.exhaustedAt = b.now()
+		return false
+	}
+	b.tokens--
+	b.retries++
+	return true
+}
+
+// refund 归还令牌（全局预算不足时回滚账号预算）。
+func (b *RetryBudget) refund() {
+	b.mu.Lock()
+	b.tokens++
+	b.retries--
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.mu.Unlock()
+}
+
+// Snapshot 返回当前状态。
+func (b *RetryBudget) Snapshot() RetryBudgetSnapshot {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refillLocked()
+	snap := RetryBudgetSnapshot{
+		Tokens:   b.tokens,
+		Capacity: b.capacity,
+		Deposits: b.deposits,
+		Retries:  b.retries,
+		Rejected: b.rejected,
+	}
+	if !b.exhaustedAt.IsZero() {
+		snap.ExhaustedAt = b.exhaustedAt.UTC().Format(time.RFC3339)
+	}
+	return snap
+}
+
+// retryBudgets 全局与按账号的重试预算。nil 或未启用时不做限制。
+type retryBudgets struct {
+	cfg    RetryBudgetConfig
+	global *RetryBudget
+	// onExhausted 预算耗尽拒绝重试时回调，用于导出指标
+	onExhausted func(accountID, stage string)
+
+	mu       sync.Mutex
+	accounts map[string]*RetryBudget
+}
+
+func newRetryBudgets(cfg RetryBudgetConfig) *retryBudgets {
+	return &retryBudgets{
+		cfg:      cfg,
+		global:   NewRetryBudget(cfg.Ratio, cfg.MinPerSecond, cfg.GlobalMaxTokens),
+		accounts: make(map[string]*RetryBudget),
+	}
+}
+
+func (rb *retryBudgets) account(accountID string) *RetryBudget {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	b, ok := rb.accounts[accountID]
+	if !ok {
+		b = NewRetryBudget(rb.cfg.Ratio, rb.cfg.MinPerSecond, rb.cfg.MaxTokens)
+		rb.accounts[accountID] = b
+	}
+	return b
+}
+
+// peek 只读查询账号预算；账号尚未产生流量时返回未登记的初始预算，不写入 accounts。
+func (rb *retryBudgets) peek(accountID string) *RetryBudget {
+	rb.mu.Lock()
+	b, ok := rb.accounts[accountID]
+	rb.mu.Unlock()
+	if !ok {
+		b = NewRetryBudget(rb.cfg.Ratio, rb.cfg.MinPerSecond, rb.cfg.MaxTokens)
+	}
+	return b
+}
+
+// remove 账号删除后清理其预算。
+func (rb *retryBudgets) remove(accountID string) {
+	if rb == nil {
+		return
+	}
+	rb.mu.Lock()
+	delete(rb.accounts, accountID)
+	rb.mu.Unlock()
+}
+
+// deposit 记录账号的一次成功请求。
+func (rb *retryBudgets) deposit(accountID string) {
+	if rb == nil || !rb.cfg.Enabled {
+		return
+	}
+	rb.account(accountID).Deposit()
+	rb.global.Deposit()
+}
+
+// allow 判断账号是否还可以重试；需同时满足账号与全局预算。stage 标记重试发生的位置。
+func (rb *retryBudgets) allow(accountID, stage string) bool {
+	if rb == nil || !rb.cfg.Enabled {
+		return true
+	}
+	acc := rb.account(accountID)
+	ok := acc.TryWithdraw()
+	if ok && !rb.global.TryWithdraw() {
+		acc.refund()
+		ok = false
+	}
+	if !ok && rb.onExhausted != nil {
+		rb.onExhausted(accountID, stage)
+	}
+	return ok
+}
+
+// tokens 返回账号与全局预算当前的令牌数。
+func (rb *retryBudgets) tokens(accountID string) (account, global float64) {
+	if rb == nil {
+		return 0, 0
+	}
+	return rb.peek(accountID).Snapshot().Tokens, rb.global.Snapshot().Tokens
+}
+
+// snapshot 返回全局与各账号预算状态。
+func (rb *retryBudgets) snapshot() map[string]interface{} {
+	if rb == nil {
+		return map[string]interface{}{"enabled": false}
+	}
+	rb.mu.Lock()
+	ids := make([]string, 0, len(rb.accounts))
+	for id := range rb.accounts {
+		ids = append(ids, id)
+	}
+	rb.mu.Unlock()
+	sort.Strings(ids)
+
+	accounts := make([]map[string]interface{}, 0, len(ids))
+	for _, id := range ids {
+		accounts = append(accounts, map[string]interface{}{
+			"account_id": id,
+			"budget":     rb.peek(id).Snapshot(),
+		})
+	}
+	return map[string]interface{}{
+		"enabled":  rb.cfg.Enabled,
+		"config":   rb.cfg,
+		"global":   rb.global.Snapshot(),
+		"accounts": accounts,
+	}
+}
@@ -0,0 +1,204 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"qcc_plus/internal/logging"
+)
+
+// TestRetryBudgetTokenBucket 测试令牌存取、容量上限与保底补充
+func TestRetryBudgetTokenBucket(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	b := NewRetryBudget(0.5, 1, 2)
+	b.now = func() time.Time { return now }
+
+	if !b.TryWithdraw() || !b.TryWithdraw() {
+		t.Fatalf("expected full bucket to allow 2 retries")
+	}
+	if b.TryWithdraw() {
+		t.Fatalf("expected empty bucket to reject retry")
+	}
+
+	// 两次成功存入 1 个令牌
+	b.Deposit()
+	b.Deposit()
+	if !b.TryWithdraw() {
+		t.Fatalf("expected retry allowed after deposits")
+	}
+	if b.TryWithdraw() {
+		t.Fatalf("expected bucket empty again")
+	}
+
+	// 保底补充：1 秒 1 个令牌，且不超过容量
+	now = now.Add(10 * time.Second)
+	snap := b.Snapshot()
+	if snap.Tokens != 2 {
+		t.Fatalf("expected refill capped at capacity 2, got %v", snap.Tokens)
+	}
+	if snap.Deposits != 2 || snap.Retries != 3 || snap.Rejected != 2 {
+		t.Fatalf("unexpected counters: %+v", snap)
+	}
+}
+
+// TestRetryBudgetsAccountAndGlobal 测试账号预算与全局预算需同时满足
+func TestRetryBudgetsAccountAndGlobal(t *testing.T) {
+	rb := newRetryBudgets(RetryBudgetConfig{Enabled: true, Ratio: 0, MinPerSecond: 0, MaxTokens: 2, GlobalMaxTokens: 3})
+
+	if !rb.allow("a", retryStageFailover) || !rb.allow("a", retryStageFailover) {
+		t.Fatalf("expected account a to retry twice")
+	}
+	if rb.allow("a", retryStageFailover) {
+		t.Fatalf("expected account a budget exhausted")
+	}
+	if !rb.allow("b", retryStageFailover) {
+		t.Fatalf("expected account b to use remaining global token")
+	}
+	if rb.allow("b", retryStageFailover) {
+		t.Fatalf("expected global budget exhausted")
+	}
+	// 全局不足时账号令牌应回滚
+	if got := rb.account("b").Snapshot(); got.Tokens != 1 || got.Retries != 1 {
+		t.Fatalf("expected account b refunded, got %+v", got)
+	}
+
+	disabled := newRetryBudgets(RetryBudgetConfig{Enabled: false, MaxTokens: 1, GlobalMaxTokens: 1})
+	for i := 0; i < 5; i++ {
+		if !disabled.allow("a", retryStageFailover) {
+			t.Fatalf("disabled budget must not limit retries")
+		}
+	}
+	var nilBudget *retryBudgets
+	if !nilBudget.allow("a", retryStageFailover) {
+		t.Fatalf("nil budget must not limit retries")
+	}
+}
+
+// TestRetryTransportStopsWhenBudgetExhausted 测试预算耗尽时传输层不再重试并标记响应头
+func TestRetryTransportStopsWhenBudgetExhausted(t *testing.T) {
+	var calls int
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer upstream.Close()
+
+	budget := newRetryBudgets(RetryBudgetConfig{Enabled: true, MaxTokens: 1, GlobalMaxTokens: 10})
+	rt := &retryTransport{base: http.DefaultTransport, attempts: 3, logger: logging.New(logging.Config{Output: testWriter{t}}), budget: budget}
+
+	do := func() *http.Response {
+		ctx := context.WithValue(context.Background(), accountContextKey{}, &Account{ID: "acc-1"})
+		req, _ := http.NewRequestWithContext(ctx, http.MethodPost, upstream.URL, nil)
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("round trip: %v", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	// 首个请求：1 个令牌允许 1 次重试，第二次重试被拒绝
+	resp := do()
+	if calls != 2 || resp.Header.Get(retryBudgetHeader) != "exhausted" {
+		t.Fatalf("expected 2 upstream calls and exhausted header, got calls=%d header=%q", calls, resp.Header.Get(retryBudgetHeader))
+	}
+
+	calls = 0
+	resp = do()
+	if calls != 1 || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected fail fast with single upstream call, got calls=%d status=%d", calls, resp.StatusCode)
+	}
+	if snap := budget.account("acc-1").Snapshot(); snap.Rejected != 2 {
+		t.Fatalf("expected 2 rejected retries, got %+v", snap)
+	}
+}
+
+// TestFailoverStopsWhenBudgetExhausted 测试请求循环在预算耗尽时停止切换节点，并记录轨迹与指标
+func TestFailoverStopsWhenBudgetExhausted(t *testing.T) {
+	var calls int
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer up.Close()
+	u, _ := url.Parse(up.URL)
+
+	srv := newClusterTestServer(t, NewLocalClusterBus(), "a")
+	srv.transport = http.DefaultTransport
+	srv.errorClassifier = NewErrorClassifier()
+	srv.traces = newTraceBuffer(10)
+	srv.retryConfig = RetryConfig{MaxAttempts: 3, PerRequestTimeout: 5 * time.Second, RetryOnStatus: []int{http.StatusServiceUnavailable}}
+	srv.prom = newPromMetrics()
+	srv.retryBudget = newRetryBudgets(RetryBudgetConfig{Enabled: true, MaxTokens: 0, GlobalMaxTokens: 10})
+	srv.retryBudget.onExhausted = srv.observeRetryBudgetExhausted
+	acc := srv.TestAccount("acc-1")
+	srv.defaultAccount = acc
+	for _, n := range acc.Nodes {
+		n.URL = u
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"m"}`))
+	resp := httptest.NewRecorder()
+	srv.handler().ServeHTTP(resp, req)
+
+	if calls != 1 {
+		t.Fatalf("expected no failover after budget exhausted, got %d upstream calls", calls)
+	}
+	trace, ok := srv.traces.get(resp.Header().Get(requestIDHeader))
+	if !ok {
+		t.Fatalf("expected trace stored")
+	}
+	if last := trace.Attempts[len(trace.Attempts)-1]; last.Skipped != skipRetryBudget {
+		t.Fatalf("expected retry budget marker in trace, got %+v", trace.Attempts)
+	}
+	var buf bytes.Buffer
+	srv.writePrometheus(&buf)
+	for _, want := range []string{
+		`qcc_retry_budget_exhausted_total{account="acc-1",stage="failover"} 1`,
+		`qcc_retry_budget_tokens{account="acc-1"} 0`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("missing %q in metrics:\n%s", want, buf.String())
+		}
+	}
+}
+
+// TestRetryBudgetAPIDoesNotCreateBudgets 测试查询接口不为未知账号创建预算，删除账号后清理预算
+func TestRetryBudgetAPIDoesNotCreateBudgets(t *testing.T) {
+	srv := newClusterTestServer(t, NewLocalClusterBus(), "a")
+	srv.retryBudget = newRetryBudgets(RetryBudgetConfig{Enabled: true, Ratio: 0.1, MaxTokens: 2, GlobalMaxTokens: 4})
+	adminCtx := context.WithValue(context.Background(), isAdminContextKey{}, true)
+
+	get := func(accountID string) int {
+		req := httptest.NewRequest(http.MethodGet, "/admin/api/retry-budget?account_id="+url.QueryEscape(accountID), nil).WithContext(adminCtx)
+		rec := httptest.NewRecorder()
+		srv.handleRetryBudget(rec, req)
+		return rec.Code
+	}
+	if code := get("no-such-account"); code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown account, got %d", code)
+	}
+	if code := get("acc-1"); code != http.StatusOK {
+		t.Fatalf("expected 200 for known account, got %d", code)
+	}
+	if n := len(srv.retryBudget.accounts); n != 0 {
+		t.Fatalf("expected lookups not to create budgets, got %d", n)
+	}
+
+	srv.retryBudget.deposit("acc-1")
+	req := httptest.NewRequest(http.MethodDelete, "/admin/api/accounts?id=acc-1", nil).WithContext(adminCtx)
+	rec := httptest.NewRecorder()
+	srv.handleAccounts(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("delete account: %d %s", rec.Code, rec.Body.String())
+	}
+	if _, ok := srv.retryBudget.accounts["acc-1"]; ok {
+		t.Fatalf("expected budget removed with account")
+	}
+}
@@ -0,0 +1,197 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"qcc_plus/internal/logging"
+)
+
+// retryPolicySettingKey 账号级重试策略在 settings 表中的 key（scope=account）。
+const retryPolicySettingKey = "proxy.retry_policy"
+
+// RetryPolicyOverride 账号级重试/超时覆盖项，零值或缺省字段继承全局 RetryConfig。
+type RetryPolicyOverride struct {
+	MaxAttempts           int   `json:"max_attempts,omitempty"`
+	BackoffMinMs          int   `json:"backoff_min_ms,omitempty"`
+	BackoffMaxMs          int   `json:"backoff_max_ms,omitempty"`
+	RetryOnStatus         []int `json:"retry_on_status,omitempty"`
+	PerRequestTimeoutSec  int   `json:"per_request_timeout_sec,omitempty"`
+	PerAttemptTimeoutsSec []int `json:"per_attempt_timeouts_sec,omitempty"`
+	TotalTimeoutSec       int   `json:"total_timeout_sec,omitempty"`
+}
+
+// parseRetryPolicyOverride 解析 settings 中的 JSON 对象值。
+func parseRetryPolicyOverride(value any) (RetryPolicyOverride, error) {
+	var o RetryPolicyOverride
+	var raw []byte
+	switch v := value.(type) {
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return o, err
+		}
+		raw = b
+	}
+	if err := json.Unmarshal(raw, &o); err != nil {
+		return o, fmt.Errorf("invalid retry policy: %w", err)
+	}
+	return o, o.validate()
+}
+
+func (o RetryPolicyOverride) validate() error {
+	if o.MaxAttempts < 0 || o.BackoffMinMs < 0 || o.BackoffMaxMs < 0 || o.PerRequestTimeoutSec < 0 || o.TotalTimeoutSec < 0 {
+		return fmt.Errorf("invalid retry policy: values must not be negative")
+	}
+	if o.BackoffMinMs > 0 && o.BackoffMaxMs > 0 && o.BackoffMaxMs < o.BackoffMinMs {
+		return fmt.Errorf("invalid retry policy: backoff_max_ms < backoff_min_ms")
+	}
+	for _, code := range o.RetryOnStatus {
+		if code < http.StatusBadRequest || code > 599 {
+			return fmt.Errorf("invalid retry policy: status %d out of range", code)
+		}
+	}
+	for _, sec := range o.PerAttemptTimeoutsSec {
+		if sec <= 0 {
+			return fmt.Errorf("invalid retry policy: per_attempt_timeouts_sec must be positive")
+		}
+	}
+	return nil
+}
+
+// Apply 在全局配置基础上叠加覆盖项。
+func (o RetryPolicyOverride) Apply(cfg RetryConfig) RetryConfig {
+	if o.MaxAttempts > 0 {
+		cfg.MaxAttempts = o.MaxAttempts
+	}
+	if o.BackoffMinMs > 0 {
+		cfg.BackoffMin = time.Duration(o.BackoffMinMs) * time.Millisecond
+	}
+	if o.BackoffMaxMs > 0 {
+		cfg.BackoffMax = time.Duration(o.BackoffMaxMs) * time.Millisecond
+	}
+	if cfg.BackoffMax < cfg.BackoffMin {
+		cfg.BackoffMax = cfg.BackoffMin
+	}
+	if len(o.RetryOnStatus) > 0 {
+		cfg.RetryOnStatus = append([]int(nil), o.RetryOnStatus...)
+	}
+	if o.PerRequestTimeoutSec > 0 {
+		cfg.PerRequestTimeout = time.Duration(o.PerRequestTimeoutSec) * time.Second
+	}
+	if len(o.PerAttemptTimeoutsSec) > 0 {
+		cfg.PerAttemptTimeouts = make([]time.Duration, 0, len(o.PerAttemptTimeoutsSec))
+		for _, sec := range o.PerAttemptTimeoutsSec {
+			cfg.PerAttemptTimeouts = append(cfg.PerAttemptTimeouts, time.Duration(sec)*time.Second)
+		}
+	}
+	if o.TotalTimeoutSec > 0 {
+		cfg.TotalTimeout = time.Duration(o.TotalTimeoutSec) * time.Second
+	}
+	return cfg
+}
+
+// retryConfigFor 返回账号生效的重试配置（全局配置叠加账号覆盖）。
+func (p *Server) retryConfigFor(accountID string) RetryConfig {
+	p.retryPolicyMu.RLock()
+	o, ok := p.retryPolicies[accountID]
+	p.retryPolicyMu.RUnlock()
+	if !ok {
+		return p.retryConfig
+	}
+	return o.Apply(p.retryConfig)
+}
+
+// retryPolicyOverride 返回账号的覆盖项。
+func (p *Server) retryPolicyOverride(accountID string) (RetryPolicyOverride, bool) {
+	p.retryPolicyMu.RLock()
+	defer p.retryPolicyMu.RUnlock()
+	o, ok := p.retryPolicies[accountID]
+	return o, ok
+}
+
+// applyAccountRetryPolicy 应用账号级策略变更；value 为 nil 表示删除覆盖。
+// 解析失败时保留原有策略。
+func (p *Server) applyAccountRetryPolicy(accountID string, value any) {
+	if value == nil {
+		p.retryPolicyMu.Lock()
+		delete(p.retryPolicies, accountID)
+		p.retryPolicyMu.Unlock()
+		return
+	}
+	o, err := parseRetryPolicyOverride(value)
+	if err != nil {
+		if p.logger != nil {
+			p.logger.Component("proxy").Warn("invalid retry policy setting", logging.KeyAccountID, accountID, logging.KeyError, err)
+		}
+		return
+	}
+	p.retryPolicyMu.Lock()
+	if p.retryPolicies == nil {
+		p.retryPolicies = make(map[string]RetryPolicyOverride)
+	}
+	p.retryPolicies[accountID] = o
+	p.retryPolicyMu.Unlock()
+}
+
+// applyAccountSetting 处理 scope=account 的配置变更。
+func (p *Server) applyAccountSetting(accountID, key string, value any) {
+	switch key {
+	case retryPolicySettingKey:
+		p.applyAccountRetryPolicy(accountID, value)
+	case captureSettingKey:
+		p.applyAccountCapture(accountID, value)
+	}
+}
+
+// GET /admin/api/retry-policy?account_id=xxx
+// 返回账号的重试覆盖项与生效配置；非管理员仅能查看自己的账号。
+func (p *Server) handleRetryPolicy(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	accountID := r.URL.Query().Get("account_id")
+	if !isAdmin(r.Context()) {
+		acc := accountFromCtx(r)
+		if acc == nil {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "forbidden"})
+			return
+		}
+		if accountID != "" && accountID != acc.ID {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "forbidden"})
+			return
+		}
+		accountID = acc.ID
+	}
+	if accountID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "account_id required"})
+		return
+	}
+	override, ok := p.retryPolicyOverride(accountID)
+	cfg := p.retryConfigFor(accountID)
+	timeouts := make([]int, 0, len(cfg.PerAttemptTimeouts))
+	for _, d := range cfg.PerAttemptTimeouts {
+		timeouts = append(timeouts, int(d/time.Second))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"account_id":   accountID,
+		"has_override": ok,
+		"override":     override,
+		"effective": RetryPolicyOverride{
+			MaxAttempts:           cfg.MaxAttempts,
+			BackoffMinMs:          int(cfg.BackoffMin / time.Millisecond),
+			BackoffMaxMs:          int(cfg.BackoffMax / time.Millisecond),
+			RetryOnStatus:         cfg.RetryOnStatus,
+			PerRequestTimeoutSec:  int(cfg.PerRequestTimeout / time.Second),
+			PerAttemptTimeoutsSec: timeouts,
+			TotalTimeoutSec:       int(cfg.TotalTimeout / time.Second),
+		},
+	})
+}
@@ -0,0 +1,177 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"qcc_plus/internal/store"
+)
+
+// TestRetryPolicyOverrideApply 测试账号覆盖项叠加到全局配置
+func TestRetryPolicyOverrideApply(t *testing.T) {
+	base := RetryConfig{
+		MaxAttempts:       3,
+		BackoffMin:        10 * time.Millisecond,
+		BackoffMax:        100 * time.Millisecond,
+		RetryOnStatus:     []int{502, 503},
+		PerRequestTimeout: 30 * time.Second,
+	}
+	o, err := parseRetryPolicyOverride(map[string]any{
+		"max_attempts":             float64(1),
+		"backoff_min_ms":           float64(200),
+		"retry_on_status":          []any{float64(429), float64(529)},
+		"per_attempt_timeouts_sec": []any{float64(5), float64(20)},
+		"total_timeout_sec":        float64(60),
+	})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	cfg := o.Apply(base)
+	if cfg.MaxAttempts != 1 || cfg.BackoffMin != 200*time.Millisecond || cfg.BackoffMax != 200*time.Millisecond {
+		t.Fatalf("unexpected attempts/backoff: %+v", cfg)
+	}
+	if !shouldRetryStatus(529, cfg) || shouldRetryStatus(502, cfg) {
+		t.Fatalf("unexpected retry statuses: %v", cfg.RetryOnStatus)
+	}
+	if cfg.PerRequestTimeout != 30*time.Second || len(cfg.PerAttemptTimeouts) != 2 || cfg.PerAttemptTimeouts[1] != 20*time.Second {
+		t.Fatalf("unexpected timeouts: %+v", cfg)
+	}
+	if cfg.TotalTimeout != time.Minute {
+		t.Fatalf("unexpected total timeout: %v", cfg.TotalTimeout)
+	}
+	if base.MaxAttempts != 3 || len(base.RetryOnStatus) != 2 {
+		t.Fatalf("base config must not be modified: %+v", base)
+	}
+
+	for _, bad := range []any{
+		map[string]any{"max_attempts": float64(-1)},
+		map[string]any{"retry_on_status": []any{float64(200)}},
+		map[string]any{"backoff_min_ms": float64(100), "backoff_max_ms": float64(10)},
+		"not json",
+	} {
+		if _, err := parseRetryPolicyOverride(bad); err == nil {
+			t.Errorf("expected error for %v", bad)
+		}
+	}
+}
+
+// TestAccountRetryPolicyLiveReload 测试账号级配置经 Builder 装配的 SettingsCache 刷新实时生效
+func TestAccountRetryPolicyLiveReload(t *testing.T) {
+	t.Setenv("PROXY_SQLITE_PATH", filepath.Join(t.TempDir(), "retry.db"))
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer up.Close()
+	srv := buildServerNoWarmup(t, NewBuilder().WithUpstream(up.URL))
+	if srv.store == nil || srv.settingsCache == nil {
+		t.Fatalf("expected builder to wire store and settings cache")
+	}
+	defer srv.store.Close()
+	accounts, err := srv.store.ListAccounts(context.Background())
+	if err != nil || len(accounts) == 0 {
+		t.Fatalf("list accounts: %v", err)
+	}
+	accID := accounts[0].ID
+	base := srv.retryConfig.MaxAttempts
+
+	for _, s := range []*store.Setting{
+		{Key: retryPolicySettingKey, Scope: "system", Value: map[string]any{"max_attempts": float64(9)}},
+		{Key: retryPolicySettingKey, Scope: "account", AccountID: &accID, Value: map[string]any{"max_attempts": float64(1)}},
+	} {
+		if err := srv.store.UpsertSetting(s); err != nil {
+			t.Fatalf("upsert setting: %v", err)
+		}
+	}
+	srv.settingsCache.Refresh()
+	if got := srv.retryConfigFor(accID).MaxAttempts; got != 1 {
+		t.Fatalf("expected account override 1, got %d", got)
+	}
+	if got := srv.retryConfigFor("other").MaxAttempts; got != base {
+		t.Fatalf("system-scope key must not apply to accounts, got %d", got)
+	}
+
+	override := &store.Setting{Key: retryPolicySettingKey, Scope: "account", AccountID: &accID,
+		Value: map[string]any{"max_attempts": float64(5), "total_timeout_sec": float64(10)}}
+	if err := srv.store.UpsertSetting(override); err != nil {
+		t.Fatalf("update setting: %v", err)
+	}
+	srv.settingsCache.Refresh()
+	if cfg := srv.retryConfigFor(accID); cfg.MaxAttempts != 5 || cfg.TotalTimeout != 10*time.Second {
+		t.Fatalf("expected refreshed override, got %+v", cfg)
+	}
+
+	if err := srv.store.DeleteSetting(retryPolicySettingKey, "account", accID); err != nil {
+		t.Fatalf("delete setting: %v", err)
+	}
+	srv.settingsCache.Refresh()
+	if got := srv.retryConfigFor(accID).MaxAttempts; got != base {
+		t.Fatalf("expected override removed, got %d", got)
+	}
+}
+
+// TestPerAttemptTimeoutFailover 测试单次尝试超时后切换节点，且客户端只收到最终响应
+func TestPerAttemptTimeoutFailover(t *testing.T) {
+	var calls atomic.Int32
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		if calls.Add(1) == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(2 * time.Second):
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer up.Close()
+	u, _ := url.Parse(up.URL)
+
+	srv := newClusterTestServer(t, NewLocalClusterBus(), "a")
+	srv.transport = http.DefaultTransport
+	srv.errorClassifier = NewErrorClassifier()
+	srv.traces = newTraceBuffer(10)
+	srv.retryConfig = RetryConfig{
+		MaxAttempts:        3,
+		PerRequestTimeout:  5 * time.Second,
+		PerAttemptTimeouts: []time.Duration{100 * time.Millisecond},
+	}
+	acc := srv.TestAccount("acc-1")
+	srv.defaultAccount = acc
+	for _, n := range acc.Nodes {
+		n.URL = u
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"m"}`))
+	rec := httptest.NewRecorder()
+	srv.handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || rec.Body.String() != `{"ok":true}` {
+		t.Fatalf("expected failover to succeed with a single response, got %d %q", rec.Code, rec.Body.String())
+	}
+	if got := calls.Load(); got != 2 {
+		t.Fatalf("expected 2 upstream calls, got %d", got)
+	}
+	trace, ok := srv.traces.get(rec.Header().Get(requestIDHeader))
+	if !ok || len(trace.Attempts) != 2 || trace.Attempts[0].ErrorCategory != ErrorCategoryTimeout || !trace.Success {
+		t.Fatalf("unexpected trace: %+v", trace)
+	}
+}
+
+// TestAccountSettingKey 测试账号级缓存 key 的编解码
+func TestAccountSettingKey(t *testing.T) {
+	k := AccountSettingKey("a:b", "proxy.retry_policy")
+	id, key, ok := parseAccountSettingKey(k)
+	if !ok || id != "a:b" || key != "proxy.retry_policy" {
+		t.Fatalf("round trip failed: %q %q %v", id, key, ok)
+	}
+	if _, _, ok := parseAccountSettingKey("proxy.retry_max"); ok {
+		t.Fatalf("system key must not parse as account key")
+	}
+}
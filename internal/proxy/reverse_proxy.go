@@ -7,7 +7,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"net/http/httputil"
 	"strconv"
@@ -15,14 +14,17 @@ import (
 	"sync/atomic"
 	"time"
 
+	"qcc_plus/internal/logging"
 	"qcc_plus/internal/notify"
 )
 
 type retryTransport struct {
-	base      http.RoundTripper
-	attempts  int
-	logger    *log.Logger
-	notifyMgr *notify.Manager
+	base       http.RoundTripper
+	attempts   int
+	logger     *logging.Logger
+	notifyMgr  *notify.Manager
+	classifier *ErrorClassifier
+	budget     *retryBudgets
 }
 
 func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
@@ -42,6 +44,7 @@ func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	var lastResp *http.Response
 	var lastErr error
 	var lastRespBody []byte
+	budgetExhausted := false
 	for i := 0; i < attempts; i++ {
 		// 在重试前检查 context 是否已取消，避免无效请求
 		if err := req.Context().Err(); err != nil {
@@ -79,9 +82,23 @@ func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 			} else {
 				lastErr = fmt.Errorf("upstream status %d", resp.StatusCode)
 			}
+			// 客户端、认证与计费错误在同一节点重试无意义，直接返回
+			switch t.classifier.Classify(resp.StatusCode, bodyBytes) {
+			case ErrorCategoryClient, ErrorCategoryAuth, ErrorCategoryBilling:
+				attempts = i + 1
+			}
 		}
 		if i < attempts-1 {
-			t.logger.Printf("retry %d/%d for %s: %v", i+1, attempts, req.URL.String(), lastErr)
+			// 重试预算耗尽时立即返回，避免上游故障期间放大请求量
+			if acc := accountFromCtx(req); acc != nil && !t.budget.allow(acc.ID, retryStageTransport) {
+				t.logger.Warn("retry budget exhausted, stop retrying", logging.KeyRequestID, req.Header.Get(requestIDHeader), logging.KeyAccountID, acc.ID,
+					"url", req.URL.String(), "attempts", i+1, logging.KeyError, lastErr)
+				attempts = i + 1
+				budgetExhausted = true
+				break
+			}
+			t.logger.Warn("retrying upstream request", logging.KeyRequestID, req.Header.Get(requestIDHeader),
+				"attempt", i+1, "max_attempts", attempts, "url", req.URL.String(), logging.KeyError, lastErr)
 			time.Sleep(time.Duration(150*(i+1)) * time.Millisecond)
 		}
 	}
@@ -111,39 +128,60 @@ func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 		} else {
 			bodyBytes = []byte(fmt.Sprintf(`{"error":{"type":"upstream_error","message":"%s"}}`, lastErr.Error()))
 		}
+		header := http.Header{
+			"Content-Type":  []string{"application/json"},
+			"X-Proxy-Error": []string{fmt.Sprintf("retries exhausted after %d attempts", attempts)},
+		}
+		if budgetExhausted {
+			header.Set("X-Proxy-Error", fmt.Sprintf("%v after %d attempts", errRetryBudgetExhausted, attempts))
+			header.Set(retryBudgetHeader, "exhausted")
+		}
 		return &http.Response{
 			StatusCode: lastResp.StatusCode,
 			Body:       io.NopCloser(bytes.NewBuffer(bodyBytes)),
-			Header: http.Header{
-				"Content-Type":  []string{"application/json"},
-				"X-Proxy-Error": []string{fmt.Sprintf("retries exhausted after %d attempts", attempts)},
-			},
-			Request: req,
+			Header:     header,
+			Request:    req,
 		}, nil
 	}
+	if budgetExhausted {
+		return nil, fmt.Errorf("%w: %v", errRetryBudgetExhausted, lastErr)
+	}
 	return nil, lastErr
 }
 
 // usageReader 在转发时截取部分响应体，用于提取 usage。
+// 除头部 256KB 外额外保留末尾一段数据，长流式响应结尾的 message_delta 也能被解析。
 type usageReader struct {
 	io.ReadCloser
 	buf     *bytes.Buffer
+	tail    []byte
 	tracker *usage
 }
 
-const usageBufLimit = 256 * 1024 // 256KB 足够找到 usage 字段
+const (
+	usageBufLimit  = 256 * 1024 // 256KB 足够找到 usage 字段
+	usageTailLimit = 16 * 1024  // 尾部保留 16KB，覆盖最后的 message_delta
+)
 
 func (u *usageReader) Read(p []byte) (int, error) {
 	n, err := u.ReadCloser.Read(p)
 	if n > 0 && u.buf != nil {
+		slice := p[:n]
 		if u.buf.Len() < usageBufLimit {
 			// 仅保存前 256KB，避免占用过多内存。
 			remain := usageBufLimit - u.buf.Len()
-			slice := p[:n]
-			if len(slice) > remain {
-				slice = slice[:remain]
+			head := slice
+			if len(head) > remain {
+				head = head[:remain]
+			}
+			u.buf.Write(head)
+			slice = slice[len(head):]
+		}
+		if len(slice) > 0 {
+			u.tail = append(u.tail, slice...)
+			if len(u.tail) > usageTailLimit {
+				u.tail = append(u.tail[:0], u.tail[len(u.tail)-usageTailLimit:]...)
 			}
-			u.buf.Write(slice)
 		}
 	}
 	return n, err
@@ -152,10 +190,18 @@ func (u *usageReader) Read(p []byte) (int, error) {
 func (u *usageReader) Close() error {
 	err := u.ReadCloser.Close()
 	if u.tracker != nil && u.buf != nil {
-		if in, out := parseUsage(u.buf.Bytes()); in > 0 || out > 0 {
-			u.tracker.input = in
-			u.tracker.output = out
+		data := u.buf.Bytes()
+		if len(u.tail) > 0 {
+			data = append(append(data[:len(data):len(data)], '\n'), u.tail...)
+		}
+		// 响应体中的 usage 优先于 x-usage-* 响应头
+		tu := parseTokenUsage(data)
+		if tu.InputTokens > 0 || tu.OutputTokens > 0 {
+			u.tracker.input = tu.InputTokens
+			u.tracker.output = tu.OutputTokens
 		}
+		u.tracker.cacheCreation = tu.CacheCreationTokens
+		u.tracker.cacheRead = tu.CacheReadTokens
 	}
 	return err
 }
@@ -301,7 +347,7 @@ func (p *Server) newReverseProxy(node *Node, u *usage) (*httputil.ReverseProxy,
 			resp.Request = resp.Request.WithContext(context.WithValue(resp.Request.Context(), usageContextKey{}, u))
 		}
 
-		// 捕获请求 ID（用于追踪计费）
+		// 捕获上游请求 ID（用于追踪计费）；X-Request-ID 统一返回代理生成的 ID
 		if u != nil {
 			if reqID := resp.Header.Get("x-request-id"); reqID != "" {
 				u.requestID = reqID
@@ -309,6 +355,7 @@ func (p *Server) newReverseProxy(node *Node, u *usage) (*httputil.ReverseProxy,
 				u.requestID = reqID
 			}
 		}
+		resp.Header.Del(requestIDHeader)
 
 		inputTokens := headerInt(resp.Header.Get("x-usage-input-tokens"))
 		outputTokens := headerInt(resp.Header.Get("x-usage-output-tokens"))
@@ -324,6 +371,25 @@ func (p *Server) newReverseProxy(node *Node, u *usage) (*httputil.ReverseProxy,
 		}
 		resp.Header.Set("X-Proxy-Node", node.Name)
 
+		// 非 200 响应预读错误体用于分类，读取的部分原样拼回，不影响客户端。
+		if u != nil && resp.StatusCode != http.StatusOK {
+			peek, _ := io.ReadAll(io.LimitReader(resp.Body, errorBodyPeekLimit))
+			resp.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(peek), resp.Body), resp.Body}
+			u.errCategory = p.errorClassifier.Classify(resp.StatusCode, peek)
+			u.errMessage = upstreamErrorMessage(resp.StatusCode, peek)
+		}
+		if u != nil && u.trace != nil && u.trace.debug {
+			resp.Header.Set(traceHeader, u.trace.headerValue(&AttemptTrace{
+				NodeID:        node.ID,
+				Status:        resp.StatusCode,
+				ErrorCategory: u.errCategory,
+				LatencyMs:     time.Since(u.trace.attemptStart).Milliseconds(),
+			}))
+		}
+
 		// 包装 body，捕获 SSE/JSON 中的 usage。
 		resp.Body = &usageReader{ReadCloser: resp.Body, tracker: u, buf: &bytes.Buffer{}}
 		return nil
@@ -345,7 +411,7 @@ func (p *Server) newReverseProxy(node *Node, u *usage) (*httputil.ReverseProxy,
 			if strings.Contains(ct, "application/json") {
 				bodyBytes, err := io.ReadAll(req.Body)
 				if err != nil {
-					p.logger.Printf("proxy: read request body failed: %v", err)
+					p.requestLogger(req, node).Warn("read request body failed", logging.KeyError, err)
 					req.Body = io.NopCloser(bytes.NewReader(bodyBytes))
 					return
 				}
@@ -405,7 +471,22 @@ func (p *Server) newReverseProxy(node *Node, u *usage) (*httputil.ReverseProxy,
 			}
 		}
 
-		p.logger.Printf("proxy error %s %s: %v", r.Method, r.URL.String(), err)
+		p.requestLogger(r, node).Warn("proxy error", "method", r.Method, "url", r.URL.String(), logging.KeyError, err)
+		if u != nil {
+			u.errMessage = err.Error()
+			if isContextError {
+				u.errCategory = ErrorCategoryTimeout
+			} else {
+				u.errCategory = ErrorCategoryNetwork
+			}
+			if u.trace != nil && u.trace.debug {
+				w.Header().Set(traceHeader, u.trace.headerValue(&AttemptTrace{
+					NodeID:        node.ID,
+					ErrorCategory: u.errCategory,
+					LatencyMs:     time.Since(u.trace.attemptStart).Milliseconds(),
+				}))
+			}
+		}
 
 		// context 取消返回 499 (Client Closed Request) 或 504 (Gateway Timeout)
 		// 而不是 502，避免误判为上游错误
@@ -421,6 +502,11 @@ func (p *Server) newReverseProxy(node *Node, u *usage) (*httputil.ReverseProxy,
 		}
 		// 返回 503 而非 502，避免触发客户端重试
 		w.Header().Set("Content-Type", "application/json")
+		if errors.Is(err, errRetryBudgetExhausted) {
+			w.Header().Set(retryBudgetHeader, "exhausted")
+			http.Error(w, fmt.Sprintf(`{"error":{"type":"retry_budget_exhausted","message":"%s"}}`, err.Error()), http.StatusServiceUnavailable)
+			return
+		}
 		http.Error(w, fmt.Sprintf(`{"error":{"type":"upstream_connection_error","message":"%s"}}`, err.Error()), http.StatusServiceUnavailable)
 	}
 
@@ -443,8 +529,12 @@ func (p *Server) newPassthroughProxy(node *Node) *httputil.ReverseProxy {
 		}
 	}
 
+	proxy.ModifyResponse = func(resp *http.Response) error {
+		resp.Header.Del(requestIDHeader)
+		return nil
+	}
 	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
-		p.logger.Printf("passthrough proxy error %s %s: %v", r.Method, r.URL.String(), err)
+		p.requestLogger(r, node).Warn("passthrough proxy error", "method", r.Method, "url", r.URL.String(), logging.KeyError, err)
 		// 返回 503 而非 502，避免触发客户端重试
 		w.Header().Set("Content-Type", "application/json")
 		http.Error(w, fmt.Sprintf(`{"error":{"type":"upstream_connection_error","message":"%s"}}`, err.Error()), http.StatusServiceUnavailable)
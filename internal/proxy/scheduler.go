@@ -2,11 +2,12 @@ package proxy
 
 import (
 	"context"
-	"log"
 	"sync"
 	"time"
 
+	"qcc_plus/internal/logging"
 	"qcc_plus/internal/store"
+	"qcc_plus/internal/tracing"
 )
 
 const (
@@ -18,23 +19,35 @@ const (
 // MetricsScheduler 负责周期性聚合与清理监控数据。
 type MetricsScheduler struct {
 	store  *store.Store
-	logger *log.Logger
+	logger *logging.Logger
 	stopCh chan struct{}
 	wg     sync.WaitGroup
 
 	aggregateInterval time.Duration
 	cleanupInterval   time.Duration
 	stopOnce          sync.Once
+
+	// isLeader 多实例部署时仅主实例执行任务；为空表示单实例。
+	isLeader func() bool
+
+	// tracer 为每次聚合/清理创建根 Span，nil 表示不追踪。
+	tracer *tracing.Tracer
+
+	// requestLogRetention 返回请求审计日志保留时长，为空或 0 表示不清理。
+	requestLogRetention func() time.Duration
+
+	// captureRetention 调试抓包保留时长，0 表示不清理。
+	captureRetention time.Duration
 }
 
 // NewMetricsScheduler 创建调度器，默认每小时聚合、每天清理一次。
-func NewMetricsScheduler(s *store.Store, logger *log.Logger) *MetricsScheduler {
+func NewMetricsScheduler(s *store.Store, logger *logging.Logger) *MetricsScheduler {
 	if logger == nil {
-		logger = log.Default()
+		logger = logging.Default()
 	}
 	return &MetricsScheduler{
 		store:             s,
-		logger:            logger,
+		logger:            logger.Component("metrics"),
 		stopCh:            make(chan struct{}),
 		aggregateInterval: defaultAggregateInterval,
 		cleanupInterval:   defaultCleanupInterval,
@@ -56,6 +69,10 @@ func (m *MetricsScheduler) Start() error {
 	m.wg.Add(2)
 	go m.aggregateLoop()
 	go m.cleanupLoop()
+	if m.captureRetention > 0 {
+		m.wg.Add(1)
+		go m.captureCleanupLoop()
+	}
 	return nil
 }
 
@@ -77,7 +94,7 @@ func (m *MetricsScheduler) Stop() {
 	select {
 	case <-done:
 	case <-time.After(30 * time.Second):
-		m.logger.Printf("[MetricsScheduler] stop timeout, exiting forcefully")
+		m.logger.Warn("metrics scheduler stop timeout, exiting forcefully")
 	}
 }
 
@@ -136,52 +153,147 @@ func (m *MetricsScheduler) cleanupLoop() {
 }
 
 func (m *MetricsScheduler) runAggregation() {
+	if !m.leading() {
+		m.logger.Debug("not leader, skip aggregation")
+		return
+	}
 	start := time.Now()
-	m.logger.Printf("[MetricsScheduler] Starting hourly aggregation...")
+	m.logger.Debug("starting hourly aggregation")
 
 	ctx, cancel := m.taskContext(30 * time.Second)
 	defer cancel()
+	ctx, span := m.tracer.Start(ctx, "metrics.aggregate", tracing.SpanKindInternal)
+	defer span.End()
 
 	now := time.Now().UTC()
 
 	// 原始 -> 小时，过去 2 小时的数据。
 	if err := m.store.AggregateMetrics(ctx, "", store.MetricsGranularityHourly, now.Add(-2*time.Hour), now); err != nil {
-		m.logger.Printf("[MetricsScheduler] Aggregation failed (raw->hour): %v", err)
+		m.logger.Error("aggregation failed", "stage", "raw->hour", logging.KeyError, err)
+		span.SetError(err)
 	}
 
 	// 小时 -> 天，昨天的数据。
 	yesterdayStart := startOfDay(now).Add(-24 * time.Hour)
 	todayStart := startOfDay(now)
 	if err := m.store.AggregateMetrics(ctx, "", store.MetricsGranularityDaily, yesterdayStart, todayStart); err != nil {
-		m.logger.Printf("[MetricsScheduler] Aggregation failed (hour->day): %v", err)
+		m.logger.Error("aggregation failed", "stage", "hour->day", logging.KeyError, err)
+		span.SetError(err)
 	}
 
 	// 天 -> 月，上个月的数据。
 	currentMonthStart := startOfMonth(now)
 	lastMonthStart := currentMonthStart.AddDate(0, -1, 0)
 	if err := m.store.AggregateMetrics(ctx, "", store.MetricsGranularityMonthly, lastMonthStart, currentMonthStart); err != nil {
-		m.logger.Printf("[MetricsScheduler] Aggregation failed (day->month): %v", err)
+		m.logger.Error("aggregation failed", "stage", "day->month", logging.KeyError, err)
+		span.SetError(err)
+	}
+
+	// 健康历史 -> 可用性日汇总，昨天的数据（重复执行会覆盖，保证幂等）。
+	if err := rollupUptimeDay(ctx, m.store, yesterdayStart); err != nil {
+		m.logger.Error("aggregation failed", "stage", "uptime daily", logging.KeyError, err)
+		span.SetError(err)
 	}
 
-	m.logger.Printf("[MetricsScheduler] Aggregation completed in %v", time.Since(start))
+	m.logger.Info("aggregation completed", "duration", time.Since(start))
 }
 
 func (m *MetricsScheduler) runCleanup() {
+	if !m.leading() {
+		m.logger.Debug("not leader, skip cleanup")
+		return
+	}
 	start := time.Now()
-	m.logger.Printf("[MetricsScheduler] Starting daily cleanup...")
+	m.logger.Debug("starting daily cleanup")
 
 	ctx, cancel := m.taskContext(30 * time.Second)
 	defer cancel()
+	ctx, span := m.tracer.Start(ctx, "metrics.cleanup", tracing.SpanKindInternal)
+	defer span.End()
 
 	if err := m.store.CleanupMetrics(ctx, "", time.Now().UTC()); err != nil {
-		m.logger.Printf("[MetricsScheduler] Cleanup failed: %v", err)
+		m.logger.Error("cleanup failed", "target", "metrics", logging.KeyError, err)
+		span.SetError(err)
 	} else {
-		m.logger.Printf("[MetricsScheduler] Cleanup completed in %v", time.Since(start))
+		m.logger.Info("cleanup completed", "duration", time.Since(start))
 	}
 
 	if err := m.store.CleanupHealthChecks(ctx, time.Time{}); err != nil {
-		m.logger.Printf("[MetricsScheduler] Health history cleanup failed: %v", err)
+		m.logger.Error("cleanup failed", "target", "health_history", logging.KeyError, err)
+		span.SetError(err)
+	}
+
+	if _, err := m.store.CleanupClusterEvents(ctx, time.Now().Add(-clusterEventRetention)); err != nil {
+		m.logger.Error("cleanup failed", "target", "cluster_events", logging.KeyError, err)
+		span.SetError(err)
+	}
+
+	if _, err := m.store.CleanupCircuitBreakerEvents(ctx, time.Now().Add(-cbEventRetention)); err != nil {
+		m.logger.Error("cleanup failed", "target", "circuit_breaker_events", logging.KeyError, err)
+		span.SetError(err)
+	}
+
+	if _, err := m.store.CleanupNodeEvents(ctx, time.Now().Add(-nodeEventRetention)); err != nil {
+		m.logger.Error("cleanup failed", "target", "node_events", logging.KeyError, err)
+		span.SetError(err)
+	}
+
+	if m.requestLogRetention != nil {
+		if retention := m.requestLogRetention(); retention > 0 {
+			if n, err := m.store.CleanupRequestLogs(ctx, time.Now().Add(-retention)); err != nil {
+				m.logger.Error("cleanup failed", "target", "request_logs", logging.KeyError, err)
+				span.SetError(err)
+			} else if n > 0 {
+				m.logger.Info("request logs cleaned up", "deleted", n)
+			}
+		}
+	}
+}
+
+// capturePurgeInterval 抓包清理间隔：保留时长的 1/4，限定在 1 分钟到 1 小时之间，
+// 避免含敏感内容的抓包在过期后还要等到每日清理才删除。
+func capturePurgeInterval(retention time.Duration) time.Duration {
+	interval := retention / 4
+	if interval < time.Minute {
+		interval = time.Minute
 	}
+	if interval > time.Hour {
+		interval = time.Hour
+	}
+	return interval
+}
+
+func (m *MetricsScheduler) captureCleanupLoop() {
+	defer m.wg.Done()
+	defer m.recoverPanic("capture cleanup loop")
+
+	ticker := time.NewTicker(capturePurgeInterval(m.captureRetention))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stopCh:
+			return
+		case <-ticker.C:
+			m.runCaptureCleanup()
+		}
+	}
+}
+
+func (m *MetricsScheduler) runCaptureCleanup() {
+	if !m.leading() {
+		return
+	}
+	ctx, cancel := m.taskContext(30 * time.Second)
+	defer cancel()
+	if n, err := m.store.CleanupRequestCaptures(ctx, time.Now().Add(-m.captureRetention)); err != nil {
+		m.logger.Error("cleanup failed", "target", "request_captures", logging.KeyError, err)
+	} else if n > 0 {
+		m.logger.Info("request captures cleaned up", "deleted", n)
+	}
+}
+
+func (m *MetricsScheduler) leading() bool {
+	return m.isLeader == nil || m.isLeader()
 }
 
 func (m *MetricsScheduler) nextAggregateDelay(now time.Time) time.Duration {
@@ -223,7 +335,7 @@ func (m *MetricsScheduler) taskContext(timeout time.Duration) (context.Context,
 
 func (m *MetricsScheduler) recoverPanic(where string) {
 	if r := recover(); r != nil {
-		m.logger.Printf("[MetricsScheduler] panic recovered in %s: %v", where, r)
+		m.logger.Error("panic recovered", "where", where, "panic", r)
 	}
 }
 
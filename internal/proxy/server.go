@@ -4,15 +4,19 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
+	"net"
 	"net/http"
 	"net/url"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"qcc_plus/internal/logging"
+	"qcc_plus/internal/metricsink"
 	"qcc_plus/internal/notify"
 	"qcc_plus/internal/store"
+	"qcc_plus/internal/tracing"
 	"qcc_plus/internal/tunnel"
 )
 
@@ -34,7 +38,7 @@ type Server struct {
 
 	listenAddr       string
 	transport        http.RoundTripper
-	logger           *log.Logger
+	logger           *logging.Logger
 	retries          int
 	failLimit        int
 	healthEvery      time.Duration
@@ -45,6 +49,9 @@ type Server struct {
 	notifyMgr        *notify.Manager
 	metricsScheduler *MetricsScheduler
 	healthScheduler  *HealthScheduler
+	anomalyDetector  *AnomalyDetector
+	statementCfg     StatementConfig
+	statements       *StatementScheduler
 	settingsCache    *SettingsCache
 	settingsStopCh   chan struct{}
 	settingsWg       sync.WaitGroup
@@ -60,15 +67,67 @@ type Server struct {
 
 	wsHub *WSHub
 
-	retryConfig RetryConfig
-
-	circuitBreakers map[string]*CircuitBreaker // 每个节点一个熔断器
-	cbMu            sync.RWMutex               // 保护 circuitBreakers
-	cbConfig        CircuitBreakerConfig       // 熔断器配置
+	retryConfig   RetryConfig
+	retryBudget   *retryBudgets                  // 重试预算（全局 + 按账号），nil 表示不限制
+	retryPolicies map[string]RetryPolicyOverride // 账号级重试策略覆盖（settings scope=account）
+	retryPolicyMu sync.RWMutex
+
+	traces      *traceBuffer // 最近请求的节点尝试轨迹
+	traceHeader bool         // 始终返回 X-Proxy-Trace 调试头
+
+	requestLogs             *requestLogWriter // 请求审计日志异步写入，nil 表示未启用
+	requestLogRetentionDays atomic.Int64      // 审计日志保留天数，0 表示不清理
+	trustedProxies          []*net.IPNet      // 可信反向代理，用于解析客户端 IP
+
+	captureRules    map[string]*captureRule // 账号级抓包模式（settings scope=account）
+	captureMu       sync.RWMutex
+	captureMaxBytes int // 单个请求/响应体抓包上限
+
+	prom       *promMetrics // Prometheus 进程内指标
+	promConfig PrometheusConfig
+	// metricModels 上游确认过的模型，限制监控模型维度基数。
+	metricModels metricsModels
+	// sinks StatsD/InfluxDB 推送后端，nil 表示未配置。
+	sinks *metricsink.Emitter
+	// grafanaToken Grafana 数据源只读令牌，为空时关闭 /api/grafana。
+	grafanaToken string
+
+	tracer *tracing.Tracer // OTLP 链路追踪，nil 表示未启用
+
+	circuitBreakers map[string]*CircuitBreaker        // 每个节点一个熔断器
+	cbMu            sync.RWMutex                      // 保护 circuitBreakers 与 cbOverrides
+	cbConfig        CircuitBreakerConfig              // 熔断器全局配置
+	cbOverrides     map[string]CircuitBreakerOverride // 节点级熔断参数覆盖
+	cbEvents        chan nodeBreakerTransition        // 熔断器转换日志写入队列，nil 表示不持久化
+	cbPending       map[string]nodeBreakerTransition  // 队列满时各节点最新的未写入转换，队列排空后补写
+	cbPendingMu     sync.Mutex
+	cbWake          chan struct{} // 记录未写入转换后唤醒写入协程
+
+	errorClassifier *ErrorClassifier // 上游错误分类，nil 时仅按状态码与 Anthropic 错误类型分类
+	authFailMu      sync.Mutex
+	authFails       map[string]authFailStreak // 节点连续认证失败，达到阈值才自动禁用
+
+	nodeSelection string                    // 节点选择策略（priority/cost）
+	nodeCostMu    sync.RWMutex              // 保护 nodeCosts
+	nodeCosts     map[string]*nodeCostState // 节点成本配置与累计费用
+
+	instanceID string         // 实例标识（多实例部署时区分事件来源）
+	leader     *LeaderElector // 多实例选主，nil 表示单实例
+	cluster    *clusterSync   // 集群事件通道，nil 表示不广播
 }
 
 // Start 运行反向代理并阻塞直到关闭。
 func (p *Server) Start() error {
+	if p.leader != nil {
+		p.leader.Start()
+		defer p.leader.Stop()
+	}
+	if p.cluster != nil {
+		if err := p.cluster.bus.Start(); err != nil {
+			return err
+		}
+		defer p.cluster.bus.Stop()
+	}
 	if p.healthScheduler != nil {
 		if err := p.healthScheduler.Start(); err != nil {
 			return err
@@ -81,6 +140,18 @@ func (p *Server) Start() error {
 		}
 		defer p.metricsScheduler.Stop()
 	}
+	if p.anomalyDetector != nil {
+		if err := p.anomalyDetector.Start(); err != nil {
+			return err
+		}
+		defer p.anomalyDetector.Stop()
+	}
+	if p.statements != nil {
+		if err := p.statements.Start(); err != nil {
+			return err
+		}
+		defer p.statements.Stop()
+	}
 
 	go p.healthLoop()
 	server := &http.Server{
@@ -90,18 +161,15 @@ func (p *Server) Start() error {
 		WriteTimeout: 0, // 支持流式响应
 	}
 
-	p.logger.Printf("Claude Code proxy listening on %s", p.listenAddr)
-	p.logger.Printf("Admin panel: http://%s/admin", p.listenAddr)
-	p.logger.Printf("默认登录凭证:")
-	p.logger.Printf("  - 管理员: username=admin, password=admin123")
-	p.logger.Printf("  - 默认账号: username=%s, password=default123", chooseNonEmpty(p.defaultAccName, "default"))
-	p.logger.Printf("⚠️  生产环境请立即修改默认密码！")
-	p.logger.Printf("API Keys:")
-	p.logger.Printf("  - Admin API Key: %s", p.adminKey)
+	p.logger.Info("Claude Code proxy listening", "addr", p.listenAddr, "admin_panel", "http://"+p.listenAddr+"/admin")
+	p.logger.Warn("默认登录凭证，生产环境请立即修改默认密码",
+		"admin", "username=admin, password=admin123",
+		"default_account", "username="+chooseNonEmpty(p.defaultAccName, "default")+", password=default123")
+	p.logger.Info("admin api key", "key_hint", maskSecret(p.adminKey))
 
 	p.mu.RLock()
 	for _, acc := range p.accounts {
-		p.logger.Printf("  - Account '%s': proxy_api_key=%s", acc.Name, acc.ProxyAPIKey)
+		p.logger.Info("account proxy api key", "account", acc.Name, "key_hint", maskSecret(acc.ProxyAPIKey))
 	}
 	p.mu.RUnlock()
 
@@ -116,10 +184,33 @@ func (p *Server) Stop() {
 	if p.metricsScheduler != nil {
 		p.metricsScheduler.Stop()
 	}
+	if p.anomalyDetector != nil {
+		p.anomalyDetector.Stop()
+	}
+	if p.statements != nil {
+		p.statements.Stop()
+	}
+	if p.leader != nil {
+		p.leader.Stop()
+	}
+	if p.cluster != nil {
+		p.cluster.bus.Stop()
+	}
 	if p.settingsStopCh != nil {
 		close(p.settingsStopCh)
 		p.settingsWg.Wait()
 	}
+	p.requestLogs.Stop()
+	p.sinks.Close()
+	p.tracer.Shutdown()
+}
+
+// isLeader 返回当前实例是否应运行单例任务（全量健康检查、指标聚合/清理、预热）。
+func (p *Server) isLeader() bool {
+	if p == nil {
+		return false
+	}
+	return p.leader.IsLeader()
 }
 
 // Handler 暴露 HTTP 处理器，便于测试或自定义服务器。
@@ -145,7 +236,7 @@ func (p *Server) getOrCreateCircuitBreaker(nodeID string) *CircuitBreaker {
 		return cb
 	}
 
-	cb = NewCircuitBreaker(p.cbConfig)
+	cb = p.newNodeCircuitBreaker(nodeID)
 	p.circuitBreakers[nodeID] = cb
 	return cb
 }
@@ -182,6 +273,51 @@ func (p *Server) startSettingsWatcher(interval time.Duration) {
 	}()
 }
 
+// applySettingChange 处理配置缓存的单项变更（SettingsCache.OnChange 回调）。
+func (p *Server) applySettingChange(key string, value any) {
+	if accountID, k, ok := parseAccountSettingKey(key); ok {
+		p.applyAccountSetting(accountID, k, value)
+		return
+	}
+	switch key {
+	case "health.check_interval_sec":
+		switch n := value.(type) {
+		case float64:
+			p.updateHealthInterval(time.Duration(n) * time.Second)
+		case int:
+			p.updateHealthInterval(time.Duration(n) * time.Second)
+		case int64:
+			p.updateHealthInterval(time.Duration(n) * time.Second)
+		}
+	case "proxy.error_patterns":
+		p.applyErrorPatterns(value)
+	case tracingSampleRatioKey:
+		p.applyTracingSampleRatio(value)
+	case loggingLevelsKey:
+		p.applyLoggingLevels(value)
+	case requestLogRetentionKey:
+		p.applyRequestLogRetention(value)
+	case "proxy.retry_max":
+		switch n := value.(type) {
+		case float64:
+			p.updateRetryMax(int(n))
+		case int:
+			p.updateRetryMax(n)
+		case int64:
+			p.updateRetryMax(int(n))
+		}
+	case "health.fail_threshold":
+		switch n := value.(type) {
+		case float64:
+			p.updateFailLimit(int(n))
+		case int:
+			p.updateFailLimit(n)
+		case int64:
+			p.updateFailLimit(int(n))
+		}
+	}
+}
+
 // applySettingsFromCache 将缓存中的关键配置应用到运行时。
 func (p *Server) applySettingsFromCache() {
 	if p == nil || p.settingsCache == nil {
@@ -207,6 +343,9 @@ func (p *Server) applySettingsFromCache() {
 			p.updateRetryMax(int(n))
 		}
 	}
+	if v, ok := p.settingsCache.Get("proxy.error_patterns"); ok {
+		p.applyErrorPatterns(v)
+	}
 	if v, ok := p.settingsCache.Get("health.fail_threshold"); ok {
 		switch n := v.(type) {
 		case float64:
@@ -217,6 +356,21 @@ func (p *Server) applySettingsFromCache() {
 			p.updateFailLimit(int(n))
 		}
 	}
+	if v, ok := p.settingsCache.Get(tracingSampleRatioKey); ok {
+		p.applyTracingSampleRatio(v)
+	}
+	if v, ok := p.settingsCache.Get(loggingLevelsKey); ok {
+		p.applyLoggingLevels(v)
+	}
+	if v, ok := p.settingsCache.Get(requestLogRetentionKey); ok {
+		p.applyRequestLogRetention(v)
+	}
+	for accountID, v := range p.settingsCache.AccountValues(retryPolicySettingKey) {
+		p.applyAccountSetting(accountID, retryPolicySettingKey, v)
+	}
+	for accountID, v := range p.settingsCache.AccountValues(captureSettingKey) {
+		p.applyAccountSetting(accountID, captureSettingKey, v)
+	}
 }
 
 // 创建默认账号及默认节点（如必要）。
@@ -236,7 +390,7 @@ func (p *Server) createDefaultAccount(defaultUpstream *url.URL, defaultCfg store
 	}
 	method := normalizeHealthCheckMethod(defaultHealthCheckMethod)
 	if healthMethodRequiresAPIKey(method) && upstreamKey == "" {
-		p.logger.Printf("health check mode %s requires api key, fallback to head for default node", method)
+		p.logger.Warn("health check mode requires api key, fallback to head for default node", "method", method)
 		method = HealthCheckMethodHEAD
 	}
 	node := &Node{
@@ -321,7 +475,7 @@ func (p *Server) loadAccountsFromStore(defaultUpstream *url.URL, defaultCfg stor
 		if len(recs) == 0 && a.ID == store.DefaultAccountID && defaultUpstream != nil {
 			method := normalizeHealthCheckMethod(defaultHealthCheckMethod)
 			if healthMethodRequiresAPIKey(method) && defaultUpstreamKey == "" {
-				p.logger.Printf("health check mode %s requires api key, fallback to head for default node", method)
+				p.logger.Warn("health check mode requires api key, fallback to head for default node", "method", method)
 				method = HealthCheckMethodHEAD
 			}
 			node := &Node{
@@ -345,7 +499,7 @@ func (p *Server) loadAccountsFromStore(defaultUpstream *url.URL, defaultCfg stor
 				hcMethod := normalizeHealthCheckMethod(chooseNonEmpty(r.HealthCheckMethod, defaultHealthCheckMethod))
 				hcModel := chooseNonEmpty(r.HealthCheckModel, defaultHealthCheckModel)
 				if healthMethodRequiresAPIKey(hcMethod) && r.APIKey == "" {
-					p.logger.Printf("health check mode %s requires api key, fallback to head for node %s", hcMethod, r.Name)
+					p.logger.Warn("health check mode requires api key, fallback to head", "method", hcMethod, logging.KeyNodeID, r.ID, "node", r.Name)
 					hcMethod = HealthCheckMethodHEAD
 				}
 				n := &Node{
@@ -362,17 +516,19 @@ func (p *Server) loadAccountsFromStore(defaultUpstream *url.URL, defaultCfg stor
 					Disabled:          r.Disabled,
 					LastError:         r.LastError,
 					Metrics: metrics{
-						Requests:          r.Requests,
-						FailCount:         r.FailCount,
-						FailStreak:        r.FailStreak,
-						TotalBytes:        r.TotalBytes,
-						TotalInputTokens:  r.TotalInput,
-						TotalOutputTokens: r.TotalOutput,
-						StreamDur:         time.Duration(r.StreamDurMs) * time.Millisecond,
-						FirstByteDur:      time.Duration(r.FirstByteMs) * time.Millisecond,
-						LastPingMS:        r.LastPingMs,
-						LastPingErr:       r.LastPingErr,
-						LastHealthCheckAt: r.LastHealthCheckAt,
+						Requests:                 r.Requests,
+						FailCount:                r.FailCount,
+						FailStreak:               r.FailStreak,
+						TotalBytes:               r.TotalBytes,
+						TotalInputTokens:         r.TotalInput,
+						TotalOutputTokens:        r.TotalOutput,
+						TotalCacheCreationTokens: r.TotalCacheCreation,
+						TotalCacheReadTokens:     r.TotalCacheRead,
+						StreamDur:                time.Duration(r.StreamDurMs) * time.Millisecond,
+						FirstByteDur:             time.Duration(r.FirstByteMs) * time.Millisecond,
+						LastPingMS:               r.LastPingMs,
+						LastPingErr:              r.LastPingErr,
+						LastHealthCheckAt:        r.LastHealthCheckAt,
 					},
 				}
 				acc.Nodes[n.ID] = n
@@ -2,13 +2,36 @@ package proxy
 
 import (
 	"reflect"
+	"strings"
 	"sync"
 
 	"qcc_plus/internal/store"
 )
 
+// accountSettingPrefix 账号级配置在缓存中的 key 前缀，完整格式为 account:<account_id>:<key>。
+const accountSettingPrefix = "account:"
+
+// AccountSettingKey 返回账号级配置的缓存 key，OnChange 回调中以该 key 通知变更。
+func AccountSettingKey(accountID, key string) string {
+	return accountSettingPrefix + accountID + ":" + key
+}
+
+// parseAccountSettingKey 解析账号级缓存 key；配置 key 本身不含冒号。
+func parseAccountSettingKey(cacheKey string) (accountID, key string, ok bool) {
+	rest, found := strings.CutPrefix(cacheKey, accountSettingPrefix)
+	if !found {
+		return "", "", false
+	}
+	idx := strings.LastIndex(rest, ":")
+	if idx <= 0 || idx == len(rest)-1 {
+		return "", "", false
+	}
+	return rest[:idx], rest[idx+1:], true
+}
+
 // SettingsCache 配置缓存
 // 负责从存储加载配置并在变更时触发回调。
+// 同时缓存 scope=system 与 scope=account 的配置，后者以 AccountSettingKey 为 key。
 type SettingsCache struct {
 	mu       sync.RWMutex
 	data     map[string]any // key -> value
@@ -34,6 +57,24 @@ func (c *SettingsCache) Get(key string) (any, bool) {
 	return v, ok
 }
 
+// GetAccount 获取账号级配置值（不回落到系统配置）。
+func (c *SettingsCache) GetAccount(accountID, key string) (any, bool) {
+	return c.Get(AccountSettingKey(accountID, key))
+}
+
+// AccountValues 返回所有账号对某配置项的取值：account_id -> value。
+func (c *SettingsCache) AccountValues(key string) map[string]any {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := make(map[string]any)
+	for k, v := range c.data {
+		if accountID, kk, ok := parseAccountSettingKey(k); ok && kk == key {
+			out[accountID] = v
+		}
+	}
+	return out
+}
+
 // GetInt 获取整数配置
 func (c *SettingsCache) GetInt(key string, defaultVal int) int {
 	if v, ok := c.Get(key); ok {
@@ -131,8 +172,12 @@ func (c *SettingsCache) reload(notify bool) {
 	if err != nil {
 		return
 	}
+	accountSettings, err := c.store.ListSettings("account", "", "")
+	if err != nil {
+		return
+	}
 
-	newData := make(map[string]any, len(settings))
+	newData := make(map[string]any, len(settings)+len(accountSettings))
 	var maxVer int64
 	for _, s := range settings {
 		newData[s.Key] = s.Value
@@ -140,6 +185,15 @@ func (c *SettingsCache) reload(notify bool) {
 			maxVer = v
 		}
 	}
+	for _, s := range accountSettings {
+		if s.AccountID == nil || *s.AccountID == "" {
+			continue
+		}
+		newData[AccountSettingKey(*s.AccountID, s.Key)] = s.Value
+		if v := int64(s.Version); v > maxVer {
+			maxVer = v
+		}
+	}
 
 	var changed []struct {
 		key string
@@ -2,6 +2,7 @@ package proxy
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strings"
 
@@ -12,6 +13,8 @@ import (
 type SettingsHandler struct {
 	store store.SettingsStore
 	cache *SettingsCache
+	// onMutate 配置写入成功后回调（用于向其他实例广播），可为空。
+	onMutate func(keys ...string)
 }
 
 // ListSettings GET /api/settings?scope=system&category=monitor&account_id=xxx
@@ -149,6 +152,14 @@ func (h *SettingsHandler) UpdateSetting(w http.ResponseWriter, r *http.Request,
 	if req.AccountID != nil {
 		accountID = *req.AccountID
 	}
+	if scope == "account" && accountID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "account_id required for account scope"})
+		return
+	}
+	if err := validateSettingValue(key, req.Value); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
 
 	existing, err := h.store.GetSetting(key, scope, accountID)
 	if err != nil && err != store.ErrNotFound {
@@ -177,8 +188,11 @@ func (h *SettingsHandler) UpdateSetting(w http.ResponseWriter, r *http.Request,
 			return
 		}
 		if h.cache != nil {
-			h.cache.UpdateLocal(key, req.Value, int64(setting.Version))
+			if ck, ok := settingCacheKey(key, scope, accountID); ok {
+				h.cache.UpdateLocal(ck, req.Value, int64(setting.Version))
+			}
 		}
+		h.mutated(key)
 		writeJSON(w, http.StatusOK, map[string]any{"success": true, "new_version": setting.Version})
 		return
 	}
@@ -231,8 +245,11 @@ func (h *SettingsHandler) UpdateSetting(w http.ResponseWriter, r *http.Request,
 		return
 	}
 	if h.cache != nil {
-		h.cache.UpdateLocal(key, req.Value, int64(setting.Version))
+		if ck, ok := settingCacheKey(key, scope, accountID); ok {
+			h.cache.UpdateLocal(ck, req.Value, int64(setting.Version))
+		}
 	}
+	h.mutated(key)
 	writeJSON(w, http.StatusOK, map[string]any{"success": true, "new_version": setting.Version})
 }
 
@@ -263,6 +280,10 @@ func (h *SettingsHandler) BatchUpdate(w http.ResponseWriter, r *http.Request) {
 		if req.Settings[i].Scope == "" {
 			req.Settings[i].Scope = "system"
 		}
+		if err := validateSettingValue(req.Settings[i].Key, req.Settings[i].Value); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
 	}
 
 	if err := h.store.BatchUpdateSettings(req.Settings); err != nil {
@@ -280,6 +301,11 @@ func (h *SettingsHandler) BatchUpdate(w http.ResponseWriter, r *http.Request) {
 	if h.cache != nil {
 		h.cache.Refresh()
 	}
+	keys := make([]string, 0, len(req.Settings))
+	for _, st := range req.Settings {
+		keys = append(keys, st.Key)
+	}
+	h.mutated(keys...)
 	writeJSON(w, http.StatusOK, map[string]any{"success": true, "version": h.getGlobalVersion()})
 }
 
@@ -307,9 +333,63 @@ func (h *SettingsHandler) DeleteSetting(w http.ResponseWriter, r *http.Request,
 	if h.cache != nil {
 		h.cache.Refresh()
 	}
+	h.mutated(key)
 	writeJSON(w, http.StatusOK, map[string]string{"deleted": key})
 }
 
+// settingCacheKey 返回配置在 SettingsCache 中的 key；user 级配置不进入缓存。
+func settingCacheKey(key, scope, accountID string) (string, bool) {
+	switch scope {
+	case "", "system":
+		return key, true
+	case "account":
+		if accountID == "" {
+			return "", false
+		}
+		return AccountSettingKey(accountID, key), true
+	default:
+		return "", false
+	}
+}
+
+// validateSettingValue 校验需要运行时解析的配置项，避免写入无法生效的值。
+func validateSettingValue(key string, value any) error {
+	switch key {
+	case retryPolicySettingKey:
+		if value == nil {
+			return nil
+		}
+		_, err := parseRetryPolicyOverride(value)
+		return err
+	case "proxy.error_patterns":
+		patterns, err := parseErrorPatterns(value)
+		if err != nil {
+			return err
+		}
+		return NewErrorClassifier().SetPatterns(patterns)
+	case loggingLevelsKey:
+		_, err := parseLoggingLevels(value)
+		return err
+	case tracingSampleRatioKey:
+		if r, ok := value.(float64); !ok || r < 0 || r > 1 {
+			return fmt.Errorf("%s must be a number between 0 and 1", key)
+		}
+	case captureSettingKey:
+		return validateCaptureSetting(value)
+	case requestLogRetentionKey:
+		if n, ok := value.(float64); !ok || n < 0 || n != float64(int64(n)) {
+			return fmt.Errorf("%s must be a non-negative integer", key)
+		}
+	}
+	return nil
+}
+
+func (h *SettingsHandler) mutated(keys ...string) {
+	if h.onMutate != nil {
+		h.onMutate(keys...)
+	}
+}
+
 func (h *SettingsHandler) getGlobalVersion() int64 {
 	if h.store == nil {
 		return 0
@@ -0,0 +1,220 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"qcc_plus/internal/logging"
+	"qcc_plus/internal/notify"
+	"qcc_plus/internal/store"
+	"qcc_plus/internal/timeutil"
+)
+
+const (
+	defaultStatementTimezone = "Asia/Shanghai"
+	defaultStatementDelay    = time.Hour
+	statementCheckInterval   = 10 * time.Minute
+)
+
+// StatementConfig 月度账单定时生成参数。
+type StatementConfig struct {
+	Enabled  bool
+	Location *time.Location // 账期边界所在时区
+	// Delay 月末后等待多久再生成，给异步写入的使用日志留出余量。
+	Delay time.Duration
+	// DashboardURL 通知中附带的账单链接地址前缀，为空时仅给出相对路径。
+	DashboardURL string
+}
+
+func loadStatementConfig(logger *logging.Logger) StatementConfig {
+	cfg := StatementConfig{
+		Enabled:      parseEnvBool("STATEMENT_ENABLED", true, logger),
+		Location:     timeutil.BeijingLocation,
+		Delay:        parseEnvDuration("STATEMENT_DELAY", defaultStatementDelay, logger),
+		DashboardURL: strings.TrimRight(os.Getenv("STATEMENT_DASHBOARD_URL"), "/"),
+	}
+	tz := strings.TrimSpace(os.Getenv("STATEMENT_TIMEZONE"))
+	if tz == "" {
+		tz = defaultStatementTimezone
+	}
+	if loc, err := time.LoadLocation(tz); err == nil {
+		cfg.Location = loc
+	} else if tz != defaultStatementTimezone {
+		logger.Warn("invalid STATEMENT_TIMEZONE, fallback to Asia/Shanghai", "value", tz, logging.KeyError, err)
+	}
+	if cfg.Delay < 0 {
+		cfg.Delay = 0
+	}
+	return cfg
+}
+
+// previousStatementPeriod 返回 now 所在月份（按 loc）的上一个账期及本月起点。
+func previousStatementPeriod(now time.Time, loc *time.Location) (string, time.Time) {
+	local := now.In(loc)
+	monthStart := time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, loc)
+	return monthStart.AddDate(0, -1, 0).Format(store.StatementPeriodLayout), monthStart
+}
+
+// StatementScheduler 在每月结束后为所有账号生成上月账单快照，并通知账号订阅的渠道。
+type StatementScheduler struct {
+	store  *store.Store
+	cfg    StatementConfig
+	logger *logging.Logger
+
+	// isLeader 多实例部署时仅主实例生成；为空表示单实例。
+	isLeader func() bool
+	// publish 发送通知，为空时仅记录日志。
+	publish func(notify.Event)
+
+	mu   sync.Mutex
+	done string // 已全部生成的账期
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewStatementScheduler 创建月度账单调度器。
+func NewStatementScheduler(s *store.Store, cfg StatementConfig, logger *logging.Logger) *StatementScheduler {
+	if logger == nil {
+		logger = logging.Default()
+	}
+	if cfg.Location == nil {
+		cfg.Location = time.UTC
+	}
+	return &StatementScheduler{
+		store:  s,
+		cfg:    cfg,
+		logger: logger.Component("statement"),
+		stopCh: make(chan struct{}),
+	}
+}
+
+// Start 启动调度循环。
+func (s *StatementScheduler) Start() error {
+	if s == nil || s.store == nil {
+		return nil
+	}
+	s.wg.Add(1)
+	go s.loop()
+	return nil
+}
+
+// Stop 停止调度循环并等待退出。
+func (s *StatementScheduler) Stop() {
+	if s == nil {
+		return
+	}
+	s.stopOnce.Do(func() {
+		close(s.stopCh)
+	})
+	s.wg.Wait()
+}
+
+func (s *StatementScheduler) loop() {
+	defer s.wg.Done()
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.Error("statement scheduler panic recovered", "panic", r)
+		}
+	}()
+
+	// 启动时立即检查一次，补齐停机期间错过的月末任务。
+	s.tick(time.Now())
+	ticker := time.NewTicker(statementCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case now := <-ticker.C:
+			s.tick(now)
+		}
+	}
+}
+
+// tick 月末延迟到达后为尚无上月账单的账号生成账单；已有快照（含手动生成）的账号跳过。
+func (s *StatementScheduler) tick(now time.Time) {
+	if s.isLeader != nil && !s.isLeader() {
+		return
+	}
+	period, monthStart := previousStatementPeriod(now, s.cfg.Location)
+	if now.Before(monthStart.Add(s.cfg.Delay)) {
+		return
+	}
+	s.mu.Lock()
+	done := s.done == period
+	s.mu.Unlock()
+	if done {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	accounts, err := s.store.ListAccounts(ctx)
+	if err != nil {
+		s.logger.Error("list accounts failed", logging.KeyError, err)
+		return
+	}
+	failed := false
+	for _, acc := range accounts {
+		exists, err := s.store.HasStatement(ctx, acc.ID, period)
+		if err != nil {
+			s.logger.Error("check statement failed", logging.KeyAccountID, acc.ID, "period", period, logging.KeyError, err)
+			failed = true
+			continue
+		}
+		if exists {
+			continue
+		}
+		rec, err := s.store.GenerateStatement(ctx, store.StatementParams{
+			AccountID:   acc.ID,
+			Period:      period,
+			Location:    s.cfg.Location,
+			GeneratedBy: "scheduler",
+		})
+		if err != nil {
+			s.logger.Error("generate statement failed", logging.KeyAccountID, acc.ID, "period", period, logging.KeyError, err)
+			failed = true
+			continue
+		}
+		s.logger.Info("statement generated", logging.KeyAccountID, acc.ID, "period", period, "amount_due_usd", rec.AmountDueUSD)
+		s.notify(rec)
+	}
+	if !failed {
+		s.mu.Lock()
+		s.done = period
+		s.mu.Unlock()
+	}
+}
+
+func (s *StatementScheduler) notify(rec *store.StatementRecord) {
+	if s.publish == nil {
+		return
+	}
+	name := chooseNonEmpty(rec.AccountName, rec.AccountID)
+	link := s.cfg.DashboardURL + "/api/statements/" + rec.ID + "?format=html"
+	content := fmt.Sprintf("**账号**: %s\n**账期**: %s（%s）\n**请求数**: %d\n**用量费用**: $%.4f\n**调整**: $%.4f\n**抵扣**: $%.4f\n**应付金额**: $%.4f\n**查看**: %s",
+		name,
+		rec.Period,
+		rec.Timezone,
+		rec.Totals.Requests,
+		rec.Totals.CostUSD,
+		rec.AdjustmentsUSD,
+		rec.CreditsUSD,
+		rec.AmountDueUSD,
+		link,
+	)
+	s.publish(notify.Event{
+		AccountID:  rec.AccountID,
+		EventType:  notify.EventAccountStatement,
+		Title:      fmt.Sprintf("月度账单已生成：%s %s", name, rec.Period),
+		Content:    content,
+		DedupKey:   "statement|" + rec.AccountID + "|" + rec.Period,
+		OccurredAt: rec.CreatedAt,
+	})
+}
@@ -0,0 +1,201 @@
+package proxy
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"qcc_plus/internal/logging"
+	"qcc_plus/internal/notify"
+	"qcc_plus/internal/store"
+)
+
+// seedStatementUsage 在 UTC+8 时区的 2026-09 账期内外写入使用日志。
+func seedStatementUsage(t *testing.T, st *store.Store) *time.Location {
+	t.Helper()
+	ctx := context.Background()
+	loc := time.FixedZone("UTC+8", 8*3600)
+	if err := st.CreateAccount(ctx, store.AccountRecord{ID: "acc-1", Name: "Tenant One", ProxyAPIKey: "sk-tenant-one"}); err != nil {
+		t.Fatalf("create account: %v", err)
+	}
+	logs := []store.UsageLogRecord{
+		// UTC 8 月 31 日 20:00 即 UTC+8 的 9 月 1 日 04:00，属于 9 月账期
+		{NodeID: "n1", ModelID: "model-a", KeyHint: "sk-a****1111", InputTokens: 1000, OutputTokens: 200, CacheCreationTokens: 300, CacheReadTokens: 400, CostUSD: 1,
+			CreatedAt: time.Date(2026, 8, 31, 20, 0, 0, 0, time.UTC)},
+		{NodeID: "n2", ModelID: "model-b", KeyHint: "sk-b****2222", InputTokens: 2000, OutputTokens: 100, CostUSD: 2,
+			CreatedAt: time.Date(2026, 9, 15, 0, 0, 0, 0, loc)},
+		// 10 月 1 日 00:00（UTC+8）不属于 9 月账期
+		{NodeID: "n1", ModelID: "model-a", KeyHint: "sk-a****1111", InputTokens: 5000, CostUSD: 50,
+			CreatedAt: time.Date(2026, 10, 1, 0, 0, 0, 0, loc)},
+	}
+	for _, l := range logs {
+		l.AccountID = "acc-1"
+		l.Success = true
+		if err := st.InsertUsageLog(ctx, l); err != nil {
+			t.Fatalf("insert usage log: %v", err)
+		}
+	}
+	return loc
+}
+
+// TestStatementGenerateAndRender 测试账单生成、调整与抵扣、版本快照不可变以及 CSV/HTML 输出
+func TestStatementGenerateAndRender(t *testing.T) {
+	st, err := store.OpenSQLite(filepath.Join(t.TempDir(), "statement.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer st.Close()
+	srv := newClusterTestServer(t, NewLocalClusterBus(), "a")
+	srv.store = st
+	srv.statementCfg.Location = seedStatementUsage(t, st)
+	adminCtx := context.WithValue(context.Background(), isAdminContextKey{}, true)
+	ownerCtx := context.WithValue(context.Background(), accountContextKey{}, &Account{ID: "acc-1"})
+	otherCtx := context.WithValue(context.Background(), accountContextKey{}, &Account{ID: "acc-2"})
+
+	adjust := func(ctx context.Context, body string) int {
+		rec := httptest.NewRecorder()
+		srv.handleBillingAdjustments(rec, httptest.NewRequest(http.MethodPost, "/api/statements/adjustments", strings.NewReader(body)).WithContext(ctx))
+		return rec.Code
+	}
+	if code := adjust(ownerCtx, `{"account_id":"acc-1","period":"2026-09","kind":"credit","amount_usd":1}`); code != http.StatusForbidden {
+		t.Fatalf("expected 403 for non-admin adjustment, got %d", code)
+	}
+	for _, body := range []string{
+		`{"account_id":"acc-1","period":"2026-9","kind":"credit","amount_usd":1}`,
+		`{"account_id":"acc-1","period":"2026-09","kind":"refund","amount_usd":1}`,
+		`{"account_id":"acc-1","period":"2026-09","kind":"credit","amount_usd":-1}`,
+	} {
+		if code := adjust(adminCtx, body); code != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %s, got %d", body, code)
+		}
+	}
+	if code := adjust(adminCtx, `{"account_id":"acc-1","period":"2026-09","kind":"adjustment","amount_usd":0.5,"description":"support fee"}`); code != http.StatusOK {
+		t.Fatalf("create adjustment failed: %d", code)
+	}
+	if code := adjust(adminCtx, `{"account_id":"acc-1","period":"2026-09","kind":"credit","amount_usd":1,"description":"promo"}`); code != http.StatusOK {
+		t.Fatalf("create credit failed: %d", code)
+	}
+
+	generate := func() store.StatementRecord {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		srv.handleStatements(rec, httptest.NewRequest(http.MethodPost, "/api/statements", strings.NewReader(`{"account_id":"acc-1","period":"2026-09"}`)).WithContext(adminCtx))
+		var out store.StatementRecord
+		if err := json.Unmarshal(rec.Body.Bytes(), &out); rec.Code != http.StatusOK || err != nil {
+			t.Fatalf("generate statement failed: %d %s", rec.Code, rec.Body.String())
+		}
+		return out
+	}
+	first := generate()
+	if first.Version != 1 || first.AccountName != "Tenant One" || first.Totals.Requests != 2 || math.Abs(first.Totals.CostUSD-3) > 1e-9 ||
+		first.Totals.CacheCreationTokens != 300 || first.Totals.CacheReadTokens != 400 || math.Abs(first.AmountDueUSD-2.5) > 1e-9 {
+		t.Fatalf("unexpected statement %+v", first)
+	}
+	if len(first.ByModel) != 2 || first.ByModel[0].Key != "model-b" || len(first.ByNode) != 2 || len(first.ByAPIKey) != 2 || first.ByAPIKey[1].Key != "sk-a****1111" {
+		t.Fatalf("unexpected breakdown %+v", first)
+	}
+
+	// 新增调整后重新生成写入新版本，原快照保持不变
+	if code := adjust(adminCtx, `{"account_id":"acc-1","period":"2026-09","kind":"adjustment","amount_usd":-0.25}`); code != http.StatusOK {
+		t.Fatalf("create adjustment failed: %d", code)
+	}
+	second := generate()
+	if second.Version != 2 || math.Abs(second.AmountDueUSD-2.25) > 1e-9 {
+		t.Fatalf("unexpected regenerated statement %+v", second)
+	}
+	stored, err := st.GetStatement(context.Background(), first.ID)
+	if err != nil || stored.Version != 1 || math.Abs(stored.AmountDueUSD-2.5) > 1e-9 || len(stored.Adjustments) != 2 {
+		t.Fatalf("first snapshot changed: %+v (%v)", stored, err)
+	}
+
+	get := func(ctx context.Context, target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, target, nil).WithContext(ctx)
+		if strings.HasPrefix(req.URL.Path, "/api/statements/") {
+			srv.handleStatementByID(rec, req)
+		} else {
+			srv.handleStatements(rec, req)
+		}
+		return rec
+	}
+	var listed struct {
+		Statements []store.StatementRecord `json:"statements"`
+	}
+	if err := json.Unmarshal(get(ownerCtx, "/api/statements?account_id=acc-2").Body.Bytes(), &listed); err != nil || len(listed.Statements) != 2 || listed.Statements[0].Version != 2 {
+		t.Fatalf("owner should list own statements, got %+v (%v)", listed, err)
+	}
+	if rec := get(otherCtx, "/api/statements/"+first.ID); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for other account, got %d", rec.Code)
+	}
+
+	rec := get(ownerCtx, "/api/statements/"+second.ID+"?format=csv")
+	rows, err := csv.NewReader(rec.Body).ReadAll()
+	if rec.Code != http.StatusOK || err != nil || len(rows) < 2 {
+		t.Fatalf("unexpected csv %d %v", rec.Code, err)
+	}
+	last := rows[len(rows)-1]
+	if due, _ := strconv.ParseFloat(last[8], 64); last[0] != "total" || last[1] != "amount_due" || math.Abs(due-2.25) > 1e-9 {
+		t.Fatalf("unexpected csv total row %v", last)
+	}
+	if !strings.Contains(rec.Header().Get("Content-Disposition"), "statement-acc-1-2026-09-v2.csv") {
+		t.Fatalf("unexpected csv filename %q", rec.Header().Get("Content-Disposition"))
+	}
+
+	rec = get(adminCtx, "/api/statements/"+first.ID+"?format=html")
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.Contains(body, "Tenant One") || !strings.Contains(body, "$2.5000") ||
+		!strings.Contains(body, "2026-09-01 00:00") || !strings.Contains(body, "support fee") {
+		t.Fatalf("unexpected html %d %s", rec.Code, body)
+	}
+}
+
+// TestStatementSchedulerMonthEnd 测试月末延迟到达后为账号生成上月账单并发送通知，且不重复生成
+func TestStatementSchedulerMonthEnd(t *testing.T) {
+	st, err := store.OpenSQLite(filepath.Join(t.TempDir(), "statement-scheduler.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer st.Close()
+	loc := seedStatementUsage(t, st)
+
+	var events []notify.Event
+	newScheduler := func() *StatementScheduler {
+		s := NewStatementScheduler(st, StatementConfig{Location: loc, Delay: time.Hour}, logging.New(logging.Config{Output: testWriter{t}}))
+		s.publish = func(ev notify.Event) { events = append(events, ev) }
+		return s
+	}
+	sched := newScheduler()
+	monthEnd := time.Date(2026, 10, 1, 0, 0, 0, 0, loc)
+
+	sched.tick(monthEnd.Add(30 * time.Minute))
+	if len(events) != 0 {
+		t.Fatalf("expected no statements before delay, got %d events", len(events))
+	}
+	sched.tick(monthEnd.Add(2 * time.Hour))
+	var found *notify.Event
+	for i := range events {
+		if events[i].AccountID == "acc-1" {
+			found = &events[i]
+		}
+	}
+	if found == nil || found.EventType != notify.EventAccountStatement || !strings.Contains(found.Content, "$3.0000") || !strings.Contains(found.Title, "2026-09") {
+		t.Fatalf("unexpected statement events %+v", events)
+	}
+	generated := len(events)
+
+	// 重复检查及新调度器实例（如主实例切换）都不会重复生成
+	sched.tick(monthEnd.Add(3 * time.Hour))
+	newScheduler().tick(monthEnd.Add(3 * time.Hour))
+	list, err := st.ListStatements(context.Background(), "acc-1", "2026-09")
+	if len(events) != generated || err != nil || len(list) != 1 || list[0].GeneratedBy != "scheduler" {
+		t.Fatalf("statement generated more than once: %d events, %+v (%v)", len(events), list, err)
+	}
+}
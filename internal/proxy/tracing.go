@@ -0,0 +1,113 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"qcc_plus/internal/logging"
+	"qcc_plus/internal/tracing"
+)
+
+// tracingSampleRatioKey 采样比例配置项（settings），优先于环境变量。
+const tracingSampleRatioKey = "tracing.sample_ratio"
+
+// loadTracingConfig 读取 OTLP 导出配置；设置了 OTEL_EXPORTER_OTLP_ENDPOINT 时默认开启。
+// OTEL_EXPORTER_OTLP_PROTOCOL 未设置时，grpc:// / grpcs:// 地址或 4317 端口按 gRPC 导出，其余按 http/json。
+func loadTracingConfig(logger *logging.Logger) (tracing.Config, error) {
+	endpoint := strings.TrimSpace(os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"))
+	cfg := tracing.Config{
+		Enabled:     parseEnvBool("TRACING_ENABLED", endpoint != "", logger),
+		Endpoint:    endpoint,
+		Protocol:    tracing.ProtocolHTTPJSON,
+		ServiceName: strings.TrimSpace(os.Getenv("OTEL_SERVICE_NAME")),
+		SampleRatio: parseEnvFloat("TRACING_SAMPLE_RATIO", 0.1, logger),
+		Headers:     parseOTLPHeaders(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")),
+		Timeout:     parseEnvDuration("OTEL_EXPORTER_OTLP_TIMEOUT", 10*time.Second, logger),
+	}
+	if !cfg.Enabled {
+		return cfg, nil
+	}
+	var u *url.URL
+	if cfg.Endpoint != "" {
+		var err error
+		if u, err = parseOTLPEndpoint(cfg.Endpoint); err != nil {
+			return cfg, err
+		}
+	}
+	switch proto := strings.ToLower(strings.TrimSpace(os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL"))); proto {
+	case "":
+		if u != nil && (u.Scheme == "grpc" || u.Scheme == "grpcs" || u.Port() == "4317") {
+			cfg.Protocol = tracing.ProtocolGRPC
+		}
+	case "http/json":
+	case "grpc":
+		cfg.Protocol = tracing.ProtocolGRPC
+	case "http/protobuf":
+		// Collector 的 OTLP/HTTP 接收端同时接受 JSON，按 http/json 导出。
+		logger.Component("tracing").Warn("OTEL_EXPORTER_OTLP_PROTOCOL not supported, using http/json", "value", proto)
+	default:
+		return cfg, fmt.Errorf("invalid OTEL_EXPORTER_OTLP_PROTOCOL %q: expected grpc, http/json or http/protobuf", proto)
+	}
+	if u == nil {
+		logger.Component("tracing").Info("OTEL_EXPORTER_OTLP_ENDPOINT not set, spans only propagate and are not exported")
+		return cfg, nil
+	}
+	if cfg.Protocol != tracing.ProtocolGRPC && (u.Scheme == "grpc" || u.Scheme == "grpcs") {
+		return cfg, fmt.Errorf("OTEL_EXPORTER_OTLP_ENDPOINT uses %s:// but OTEL_EXPORTER_OTLP_PROTOCOL is %s", u.Scheme, cfg.Protocol)
+	}
+	return cfg, nil
+}
+
+// parseOTLPEndpoint 校验导出地址：http/https 用于两种协议，grpc/grpcs 仅用于 gRPC。
+func parseOTLPEndpoint(endpoint string) (*url.URL, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid OTEL_EXPORTER_OTLP_ENDPOINT: expected a URL such as http://otel-collector:4318")
+	}
+	switch u.Scheme {
+	case "http", "https", "grpc", "grpcs":
+		return u, nil
+	default:
+		return nil, fmt.Errorf("invalid OTEL_EXPORTER_OTLP_ENDPOINT scheme %q: expected http, https, grpc or grpcs", u.Scheme)
+	}
+}
+
+// parseOTLPHeaders 解析 key1=value1,key2=value2 格式的导出请求头。
+func parseOTLPHeaders(raw string) map[string]string {
+	headers := make(map[string]string)
+	for _, part := range strings.Split(raw, ",") {
+		k, v, ok := strings.Cut(part, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			continue
+		}
+		headers[k] = strings.TrimSpace(v)
+	}
+	return headers
+}
+
+// applyTracingSampleRatio 应用 settings 中的采样比例。
+func (p *Server) applyTracingSampleRatio(value any) {
+	switch n := value.(type) {
+	case float64:
+		p.tracer.SetSampleRatio(n)
+	case int:
+		p.tracer.SetSampleRatio(float64(n))
+	case int64:
+		p.tracer.SetSampleRatio(float64(n))
+	}
+}
+
+// dbSpan 为一次数据库写入创建子 Span，返回的函数记录错误并结束 Span。
+func (p *Server) dbSpan(ctx context.Context, op string) (context.Context, func(error)) {
+	ctx, span := p.tracer.Start(ctx, "db."+op, tracing.SpanKindClient)
+	span.SetAttr("db.operation", op)
+	return ctx, func(err error) {
+		span.SetError(err)
+		span.End()
+	}
+}
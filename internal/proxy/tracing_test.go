@@ -0,0 +1,91 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"qcc_plus/internal/logging"
+	"qcc_plus/internal/tracing"
+)
+
+// TestTraceparentPropagatedUpstream 测试客户端 traceparent 延续到上游，且父 Span 为本次尝试
+func TestTraceparentPropagatedUpstream(t *testing.T) {
+	const incoming = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	var forwarded string
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = r.Header.Get(tracing.TraceparentHeader)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{}`))
+	}))
+	defer up.Close()
+	u, _ := url.Parse(up.URL)
+
+	srv := newClusterTestServer(t, NewLocalClusterBus(), "a")
+	srv.transport = http.DefaultTransport
+	srv.errorClassifier = NewErrorClassifier()
+	srv.retryConfig = RetryConfig{MaxAttempts: 1, PerRequestTimeout: 5 * time.Second}
+	srv.tracer = tracing.New(tracing.Config{Enabled: true}, nil)
+	srv.applyTracingSampleRatio(float64(0))
+	acc := srv.TestAccount("acc-1")
+	srv.defaultAccount = acc
+	for _, n := range acc.Nodes {
+		n.URL = u
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"m"}`))
+	req.Header.Set(tracing.TraceparentHeader, incoming)
+	rec := httptest.NewRecorder()
+	srv.handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d", rec.Code)
+	}
+
+	in, _ := tracing.ParseTraceparent(incoming)
+	out, ok := tracing.ParseTraceparent(forwarded)
+	if !ok {
+		t.Fatalf("expected traceparent forwarded upstream, got %q", forwarded)
+	}
+	if out.TraceID != in.TraceID || out.SpanID == in.SpanID || !out.Sampled {
+		t.Fatalf("expected same trace with new parent span, got %q", forwarded)
+	}
+}
+
+// TestLoadTracingConfigProtocol 测试按协议变量与端点选择 OTLP/HTTP 或 OTLP/gRPC，非法配置启动时报错
+func TestLoadTracingConfigProtocol(t *testing.T) {
+	logger := logging.New(logging.Config{Output: testWriter{t}})
+	cases := []struct {
+		endpoint, protocol string
+		want               string // 空表示应报错
+	}{
+		{"http://otel-collector:4318", "", tracing.ProtocolHTTPJSON},
+		{"https://otel.example.com", "http/json", tracing.ProtocolHTTPJSON},
+		{"http://otel-collector:4318", "http/protobuf", tracing.ProtocolHTTPJSON},
+		{"http://otel-collector:4317", "grpc", tracing.ProtocolGRPC},
+		{"http://otel-collector:4317", "", tracing.ProtocolGRPC},
+		{"grpcs://otel.example.com", "", tracing.ProtocolGRPC},
+		{"grpc://otel-collector:4317", "http/json", ""},
+		{"http://otel-collector:4318", "thrift", ""},
+		{"otel-collector:4318", "", ""},
+	}
+	for _, tc := range cases {
+		t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", tc.endpoint)
+		t.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", tc.protocol)
+		cfg, err := loadTracingConfig(logger)
+		if tc.want != "" && (err != nil || !cfg.Enabled || cfg.Endpoint != tc.endpoint || cfg.Protocol != tc.want) {
+			t.Fatalf("%s %s: expected %s config, got %+v %v", tc.endpoint, tc.protocol, tc.want, cfg, err)
+		}
+		if tc.want == "" && (err == nil || !strings.Contains(err.Error(), "OTEL_EXPORTER_OTLP_")) {
+			t.Fatalf("%s %s: expected configuration error, got %v", tc.endpoint, tc.protocol, err)
+		}
+	}
+
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "ftp://otel-collector:4318")
+	t.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", "")
+	if _, err := NewBuilder().WithUpstream("http://upstream.example").Build(); err == nil || !strings.Contains(err.Error(), "OTEL_EXPORTER_OTLP_ENDPOINT") {
+		t.Fatalf("expected builder to reject invalid endpoint, got %v", err)
+	}
+}
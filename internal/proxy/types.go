@@ -24,25 +24,35 @@ type Node struct {
 
 // metrics 记录节点请求与健康状况统计。
 type metrics struct {
-	Requests          int64
-	StreamDur         time.Duration // 累计（首字节到末字节）
-	FirstByteDur      time.Duration // 累计首字节延时
-	TotalInputTokens  int64
-	TotalOutputTokens int64
-	TotalBytes        int64
-	LastPingMS        int64
-	LastPingErr       string
-	LastHealthCheckAt time.Time
-	FailCount         int64 // 总失败次数（非200）
-	FailStreak        int64 // 连续失败次数
+	Requests                 int64
+	StreamDur                time.Duration // 累计（首字节到末字节）
+	FirstByteDur             time.Duration // 累计首字节延时
+	TotalInputTokens         int64
+	TotalOutputTokens        int64
+	TotalCacheCreationTokens int64 // 累计缓存写入 tokens
+	TotalCacheReadTokens     int64 // 累计缓存读取 tokens
+	TotalBytes               int64
+	LastPingMS               int64
+	LastPingErr              string
+	LastErrorCategory        string // 最近一次失败的上游错误分类
+	LastHealthCheckAt        time.Time
+	FailCount                int64 // 总失败次数（非200）
+	FailStreak               int64 // 连续失败次数
 }
 
 // usage 描述一次请求的 token 统计。
 type usage struct {
-	input     int64
-	output    int64
-	modelID   string // 使用的模型 ID
-	requestID string // 请求 ID（用于追踪）
+	input         int64
+	output        int64
+	cacheCreation int64  // 缓存写入 tokens（cache_creation_input_tokens）
+	cacheRead     int64  // 缓存读取 tokens（cache_read_input_tokens）
+	modelID       string // 使用的模型 ID
+	requestID     string // 请求 ID（用于追踪）
+
+	errCategory string // 上游错误分类（非 200 时由代理填充）
+	errMessage  string // 上游错误摘要（状态码与响应体片段）
+
+	trace *RequestTrace // 所属请求的尝试轨迹
 }
 
 // Config 描述可运行时调整的系统配置。
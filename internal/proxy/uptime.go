@@ -0,0 +1,590 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"qcc_plus/internal/logging"
+	"qcc_plus/internal/store"
+	"qcc_plus/internal/timeutil"
+)
+
+// 可用性统计窗口。
+const (
+	UptimeWindowDay   = "day"
+	UptimeWindowWeek  = "week"
+	UptimeWindowMonth = "month"
+)
+
+// UptimeBucket 单个时间桶的可用性。
+type UptimeBucket struct {
+	Start              string  `json:"start"`
+	Availability       float64 `json:"availability"` // 百分比 0-100
+	DownSeconds        int64   `json:"down_seconds"`
+	MaintenanceSeconds int64   `json:"maintenance_seconds"`
+}
+
+// UptimeIncident 一次不可用事件（节点从故障到恢复）。
+type UptimeIncident struct {
+	NodeID        string  `json:"node_id"`
+	NodeName      string  `json:"node_name"`
+	StartedAt     string  `json:"started_at"`
+	EndedAt       *string `json:"ended_at"` // 未恢复时为 null
+	DurationSec   int64   `json:"duration_sec"`
+	Reason        string  `json:"reason"`
+	InMaintenance bool    `json:"in_maintenance"` // 是否完全落在维护窗口内
+}
+
+// UptimeSummary 节点或账号在统计窗口内的可用性汇总。
+type UptimeSummary struct {
+	Scope              string           `json:"scope"` // node/account
+	NodeID             string           `json:"node_id,omitempty"`
+	NodeName           string           `json:"node_name,omitempty"`
+	Availability       float64          `json:"availability"`
+	PeriodSeconds      int64            `json:"period_seconds"`
+	DownSeconds        int64            `json:"down_seconds"`
+	MaintenanceSeconds int64            `json:"maintenance_seconds"`
+	IncidentCount      int              `json:"incident_count"`
+	MTTRSeconds        int64            `json:"mttr_seconds"` // 平均恢复时间（仅统计已恢复事件）
+	Buckets            []UptimeBucket   `json:"buckets"`
+	Incidents          []UptimeIncident `json:"incidents,omitempty"`
+}
+
+// UptimeReport 账号级可用性（SLA）报表。
+type UptimeReport struct {
+	AccountID          string          `json:"account_id"`
+	AccountName        string          `json:"account_name"`
+	Window             string          `json:"window"`
+	From               string          `json:"from"`
+	To                 string          `json:"to"`
+	BucketSize         string          `json:"bucket_size"` // hour/day
+	ExcludeMaintenance bool            `json:"exclude_maintenance"`
+	Account            UptimeSummary   `json:"account"`
+	Nodes              []UptimeSummary `json:"nodes"`
+	GeneratedAt        string          `json:"generated_at"`
+}
+
+type timeInterval struct {
+	start time.Time
+	end   time.Time
+}
+
+type downInterval struct {
+	timeInterval
+	reason string
+	open   bool // 在统计区间结束时仍未恢复
+}
+
+// uptimeRange 根据窗口返回对齐后的统计区间与桶大小。
+func uptimeRange(window string, now time.Time) (time.Time, time.Time, time.Duration, error) {
+	now = now.UTC()
+	switch window {
+	case UptimeWindowDay:
+		return now.Truncate(time.Hour).Add(-23 * time.Hour), now, time.Hour, nil
+	case UptimeWindowWeek:
+		return startOfDay(now).AddDate(0, 0, -6), now, 24 * time.Hour, nil
+	case UptimeWindowMonth:
+		return startOfDay(now).AddDate(0, 0, -29), now, 24 * time.Hour, nil
+	default:
+		return time.Time{}, time.Time{}, 0, errInvalidUptimeWindow
+	}
+}
+
+// buildDownIntervals 根据健康检查时间线还原 [from, to) 内的故障区间。
+// prev 为 from 之前的最后一条记录，用于确定起始状态；无记录时视为正常。
+func buildDownIntervals(prev *store.HealthCheckRecord, recs []store.HealthCheckRecord, from, to time.Time) []downInterval {
+	var (
+		res    []downInterval
+		down   bool
+		start  time.Time
+		reason string
+	)
+	if prev != nil && !prev.Success {
+		down = true
+		start = from
+		reason = prev.ErrorMessage
+	}
+	for _, rec := range recs {
+		t := rec.CheckTime
+		if t.Before(from) {
+			t = from
+		}
+		if !t.Before(to) {
+			break
+		}
+		if !rec.Success && !down {
+			down = true
+			start = t
+			reason = rec.ErrorMessage
+		} else if rec.Success && down {
+			if t.After(start) {
+				res = append(res, downInterval{timeInterval: timeInterval{start: start, end: t}, reason: reason})
+			}
+			down = false
+		}
+	}
+	if down && to.After(start) {
+		res = append(res, downInterval{timeInterval: timeInterval{start: start, end: to}, reason: reason, open: true})
+	}
+	return res
+}
+
+// mergeIntervals 合并重叠区间，返回按起始时间排序的结果。
+func mergeIntervals(in []timeInterval) []timeInterval {
+	if len(in) == 0 {
+		return nil
+	}
+	sorted := make([]timeInterval, len(in))
+	copy(sorted, in)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].start.Before(sorted[j].start) })
+	res := []timeInterval{sorted[0]}
+	for _, iv := range sorted[1:] {
+		last := &res[len(res)-1]
+		if !iv.start.After(last.end) {
+			if iv.end.After(last.end) {
+				last.end = iv.end
+			}
+			continue
+		}
+		res = append(res, iv)
+	}
+	return res
+}
+
+// intersectIntervals 计算两组（已合并）区间的交集。
+func intersectIntervals(a, b []timeInterval) []timeInterval {
+	var res []timeInterval
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		start := a[i].start
+		if b[j].start.After(start) {
+			start = b[j].start
+		}
+		end := a[i].end
+		if b[j].end.Before(end) {
+			end = b[j].end
+		}
+		if end.After(start) {
+			res = append(res, timeInterval{start: start, end: end})
+		}
+		if a[i].end.Before(b[j].end) {
+			i++
+		} else {
+			j++
+		}
+	}
+	return res
+}
+
+// clippedDuration 返回区间集合落在 [from, to) 内的总时长。
+func clippedDuration(in []timeInterval, from, to time.Time) time.Duration {
+	var total time.Duration
+	for _, iv := range in {
+		start, end := iv.start, iv.end
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if end.After(start) {
+			total += end.Sub(start)
+		}
+	}
+	return total
+}
+
+// availabilityPercent 计算可用率；排除维护时，维护期内的故障不计入，分母同时扣除维护时长。
+func availabilityPercent(period, down, maint, downInMaint time.Duration, excludeMaintenance bool) float64 {
+	effPeriod := period
+	effDown := down
+	if excludeMaintenance {
+		effPeriod -= maint
+		effDown -= downInMaint
+	}
+	if effPeriod <= 0 {
+		return 100
+	}
+	if effDown < 0 {
+		effDown = 0
+	}
+	pct := 100 * (1 - float64(effDown)/float64(effPeriod))
+	return math.Round(pct*1000) / 1000
+}
+
+func downSpans(in []downInterval) []timeInterval {
+	res := make([]timeInterval, 0, len(in))
+	for _, d := range in {
+		res = append(res, d.timeInterval)
+	}
+	return res
+}
+
+func maintenanceSpans(windows []store.MaintenanceWindowRecord, nodeID string) []timeInterval {
+	var res []timeInterval
+	for _, w := range windows {
+		if w.NodeID != "" && w.NodeID != nodeID {
+			continue
+		}
+		res = append(res, timeInterval{start: w.StartsAt, end: w.EndsAt})
+	}
+	return mergeIntervals(res)
+}
+
+// uptimeDay 单日可用性原始数据，供日汇总与报表复用。
+type uptimeDay struct {
+	period      time.Duration
+	down        time.Duration
+	maint       time.Duration
+	downInMaint time.Duration
+	incidents   int64
+}
+
+func measureUptime(down, maint []timeInterval, incidents int64, from, to time.Time) uptimeDay {
+	return uptimeDay{
+		period:      to.Sub(from),
+		down:        clippedDuration(down, from, to),
+		maint:       clippedDuration(maint, from, to),
+		downInMaint: clippedDuration(intersectIntervals(down, maint), from, to),
+		incidents:   incidents,
+	}
+}
+
+func countIncidentsStarting(down []timeInterval, from, to time.Time) int64 {
+	var n int64
+	for _, iv := range down {
+		if !iv.start.Before(from) && iv.start.Before(to) {
+			n++
+		}
+	}
+	return n
+}
+
+// summarizeUptime 按桶汇总区间数据。维护时长在此按当前维护窗口计算，
+// 事后新增或修改的维护窗口同样对已汇总的历史日期生效。
+func summarizeUptime(down, maint []timeInterval, from, to time.Time, bucket time.Duration, excludeMaintenance bool) UptimeSummary {
+	var (
+		summary UptimeSummary
+		total   uptimeDay
+	)
+	for start := from; start.Before(to); start = start.Add(bucket) {
+		end := start.Add(bucket)
+		if end.After(to) {
+			end = to
+		}
+		day := measureUptime(down, maint, countIncidentsStarting(down, start, end), start, end)
+		total.period += day.period
+		total.down += day.down
+		total.maint += day.maint
+		total.downInMaint += day.downInMaint
+		total.incidents += day.incidents
+		summary.Buckets = append(summary.Buckets, UptimeBucket{
+			Start:              timeutil.FormatBeijingTime(start),
+			Availability:       availabilityPercent(day.period, day.down, day.maint, day.downInMaint, excludeMaintenance),
+			DownSeconds:        int64(day.down.Seconds()),
+			MaintenanceSeconds: int64(day.maint.Seconds()),
+		})
+	}
+	summary.Availability = availabilityPercent(total.period, total.down, total.maint, total.downInMaint, excludeMaintenance)
+	summary.PeriodSeconds = int64(total.period.Seconds())
+	summary.DownSeconds = int64(total.down.Seconds())
+	if excludeMaintenance {
+		summary.DownSeconds = int64((total.down - total.downInMaint).Seconds())
+	}
+	summary.MaintenanceSeconds = int64(total.maint.Seconds())
+	return summary
+}
+
+func buildIncidents(nodeID, nodeName string, down []downInterval, maint []timeInterval) []UptimeIncident {
+	res := make([]UptimeIncident, 0, len(down))
+	for _, d := range down {
+		inc := UptimeIncident{
+			NodeID:      nodeID,
+			NodeName:    nodeName,
+			StartedAt:   timeutil.FormatBeijingTime(d.start),
+			DurationSec: int64(d.end.Sub(d.start).Seconds()),
+			Reason:      d.reason,
+		}
+		if !d.open {
+			ended := timeutil.FormatBeijingTime(d.end)
+			inc.EndedAt = &ended
+		}
+		covered := clippedDuration(maint, d.start, d.end)
+		inc.InMaintenance = covered >= d.end.Sub(d.start)
+		res = append(res, inc)
+	}
+	return res
+}
+
+func applyIncidentStats(summary *UptimeSummary, incidents []UptimeIncident, excludeMaintenance bool) {
+	var (
+		count     int
+		recovered int64
+		totalSec  int64
+	)
+	kept := make([]UptimeIncident, 0, len(incidents))
+	for _, inc := range incidents {
+		if excludeMaintenance && inc.InMaintenance {
+			continue
+		}
+		kept = append(kept, inc)
+		count++
+		if inc.EndedAt != nil {
+			recovered++
+			totalSec += inc.DurationSec
+		}
+	}
+	summary.IncidentCount = count
+	summary.Incidents = kept
+	if recovered > 0 {
+		summary.MTTRSeconds = totalSec / recovered
+	}
+}
+
+type uptimeNodeRef struct {
+	ID   string
+	Name string
+}
+
+// buildUptimeReport 生成账号在指定窗口内的可用性报表；nodeID 非空时仅统计该节点。
+// 账号级可用性按“所有启用节点同时不可用”计算，即报表内各节点故障区间的交集；
+// 日汇总只提供节点故障区间，账号级结果与维护时长均在生成报表时计算。
+func (p *Server) buildUptimeReport(ctx context.Context, acc *Account, window, nodeID string, excludeMaintenance bool) (*UptimeReport, error) {
+	if p.store == nil {
+		return nil, fmt.Errorf("store not enabled")
+	}
+	if acc == nil {
+		return nil, fmt.Errorf("account not found")
+	}
+	from, to, bucket, err := uptimeRange(window, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	var nodes []uptimeNodeRef
+	p.mu.RLock()
+	accountName := acc.Name
+	for _, n := range acc.Nodes {
+		if nodeID != "" && n.ID != nodeID {
+			continue
+		}
+		if nodeID == "" && n.Disabled {
+			continue
+		}
+		nodes = append(nodes, uptimeNodeRef{ID: n.ID, Name: n.Name})
+	}
+	p.mu.RUnlock()
+	if nodeID != "" && len(nodes) == 0 {
+		return nil, errUptimeNodeNotFound
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+
+	windows, err := p.store.ListMaintenanceWindows(ctx, acc.ID, "", from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &UptimeReport{
+		AccountID:          acc.ID,
+		AccountName:        accountName,
+		Window:             window,
+		From:               timeutil.FormatBeijingTime(from),
+		To:                 timeutil.FormatBeijingTime(to),
+		BucketSize:         "day",
+		ExcludeMaintenance: excludeMaintenance,
+		Nodes:              make([]UptimeSummary, 0, len(nodes)),
+		GeneratedAt:        timeutil.FormatBeijingTime(time.Now()),
+	}
+	if bucket == time.Hour {
+		report.BucketSize = "hour"
+	}
+	if report.AccountName == "" {
+		report.AccountName = acc.ID
+	}
+
+	var (
+		accountDown  []timeInterval
+		accountMaint []timeInterval
+		allIncidents []UptimeIncident
+	)
+	for i, n := range nodes {
+		var rollups map[int64]store.UptimeDailyRecord
+		if bucket == 24*time.Hour {
+			rollups = p.loadUptimeRollups(ctx, acc.ID, n.ID, from, to)
+		}
+		down, err := p.nodeDownIntervals(ctx, acc.ID, n.ID, from, to, rollups)
+		if err != nil {
+			return nil, err
+		}
+		spans := mergeIntervals(downSpans(down))
+		maint := maintenanceSpans(windows, n.ID)
+
+		summary := summarizeUptime(spans, maint, from, to, bucket, excludeMaintenance)
+		summary.Scope = "node"
+		summary.NodeID = n.ID
+		summary.NodeName = n.Name
+		incidents := buildIncidents(n.ID, n.Name, down, maint)
+		applyIncidentStats(&summary, incidents, excludeMaintenance)
+		report.Nodes = append(report.Nodes, summary)
+		allIncidents = append(allIncidents, summary.Incidents...)
+
+		if i == 0 {
+			accountDown = spans
+			accountMaint = maint
+		} else {
+			accountDown = intersectIntervals(accountDown, spans)
+			accountMaint = intersectIntervals(accountMaint, maint)
+		}
+	}
+
+	accountSummary := summarizeUptime(accountDown, accountMaint, from, to, bucket, excludeMaintenance)
+	accountSummary.Scope = "account"
+	accountIncidents := make([]UptimeIncident, 0, len(accountDown))
+	for _, iv := range accountDown {
+		accountIncidents = append(accountIncidents, buildIncidents("", "", []downInterval{{timeInterval: iv, reason: "all nodes unavailable", open: !iv.end.Before(to)}}, accountMaint)...)
+	}
+	applyIncidentStats(&accountSummary, accountIncidents, excludeMaintenance)
+	report.Account = accountSummary
+	return report, nil
+}
+
+// nodeDownIntervals 还原节点在 [from, to) 内的故障区间：带故障区间的整天日汇总直接使用，
+// 其余时段（缺少汇总的日期与尚未结束的当天）才读取原始健康检查记录。
+func (p *Server) nodeDownIntervals(ctx context.Context, accountID, nodeID string, from, to time.Time, rollups map[int64]store.UptimeDailyRecord) ([]downInterval, error) {
+	var (
+		res     []downInterval
+		rawFrom time.Time
+	)
+	flushRaw := func(end time.Time) error {
+		if rawFrom.IsZero() || !end.After(rawFrom) {
+			return nil
+		}
+		prev, recs, err := p.store.HealthTimeline(ctx, accountID, nodeID, rawFrom, end)
+		if err != nil {
+			return err
+		}
+		seg := buildDownIntervals(prev, recs, rawFrom, end)
+		if n := len(seg); n > 0 && end.Before(to) {
+			// 分段末尾的故障由后续日汇总或原始记录接续
+			seg[n-1].open = false
+		}
+		res = append(res, seg...)
+		rawFrom = time.Time{}
+		return nil
+	}
+	for day := from; day.Before(to); day = day.Add(24 * time.Hour) {
+		rec, ok := rollups[day.Unix()]
+		if ok && rec.DownSpans != nil && !day.Add(24*time.Hour).After(to) {
+			if err := flushRaw(day); err != nil {
+				return nil, err
+			}
+			for _, sp := range rec.DownSpans {
+				res = append(res, downInterval{timeInterval: timeInterval{start: sp.Start.UTC(), end: sp.End.UTC()}, reason: sp.Reason})
+			}
+			continue
+		}
+		if rawFrom.IsZero() {
+			rawFrom = day
+		}
+	}
+	if err := flushRaw(to); err != nil {
+		return nil, err
+	}
+	return joinDownIntervals(res), nil
+}
+
+// joinDownIntervals 连接按时间排序且首尾相接的故障区间（跨天的同一次故障），保留最早的原因。
+func joinDownIntervals(in []downInterval) []downInterval {
+	var res []downInterval
+	for _, d := range in {
+		if n := len(res); n > 0 && !d.start.After(res[n-1].end) {
+			if d.end.After(res[n-1].end) {
+				res[n-1].end = d.end
+				res[n-1].open = d.open
+			}
+			continue
+		}
+		res = append(res, d)
+	}
+	return res
+}
+
+func (p *Server) loadUptimeRollups(ctx context.Context, accountID, nodeID string, from, to time.Time) map[int64]store.UptimeDailyRecord {
+	// 当天数据尚未汇总，只读取已完成的整天。
+	recs, err := p.store.QueryUptimeDaily(ctx, accountID, nodeID, from, startOfDay(to))
+	if err != nil {
+		if p.logger != nil {
+			p.logger.Component("api").Warn("load uptime rollups failed", logging.KeyAccountID, accountID, logging.KeyNodeID, nodeID, logging.KeyError, err)
+		}
+		return nil
+	}
+	res := make(map[int64]store.UptimeDailyRecord, len(recs))
+	for _, rec := range recs {
+		res[rec.BucketStart.Unix()] = rec
+	}
+	return res
+}
+
+// rollupUptimeDay 汇总指定 UTC 日期内各节点的故障区间，写入 node_uptime_daily。
+// 只保存节点级数据：维护窗口可能事后调整，账号级结果取决于报表时的启用节点，二者都在生成报表时计算。
+func rollupUptimeDay(ctx context.Context, st *store.Store, day time.Time) error {
+	from := startOfDay(day)
+	to := from.Add(24 * time.Hour)
+	// 向前多取一段时间，覆盖当天没有新记录但持续故障的节点。
+	pairs, err := st.ListHealthCheckNodes(ctx, from.AddDate(0, 0, -7), to)
+	if err != nil {
+		return err
+	}
+
+	var errs []string
+	for _, pair := range pairs {
+		accountID, nodeID := pair[0], pair[1]
+		prev, recs, err := st.HealthTimeline(ctx, accountID, nodeID, from, to)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		down := buildDownIntervals(prev, recs, from, to)
+		spans := mergeIntervals(downSpans(down))
+		rec := uptimeDailyRecord(accountID, nodeID, from, measureUptime(spans, nil, countIncidentsStarting(spans, from, to), from, to))
+		rec.DownSpans = uptimeRollupSpans(down)
+		if err := st.UpsertUptimeDaily(ctx, rec); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("uptime rollup: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// maxUptimeRollupSpans 单日汇总最多保存的故障区间数，超过时不保存，报表回退到原始记录。
+const maxUptimeRollupSpans = 200
+
+func uptimeRollupSpans(down []downInterval) []store.UptimeDownSpan {
+	if len(down) > maxUptimeRollupSpans {
+		return nil
+	}
+	spans := make([]store.UptimeDownSpan, 0, len(down))
+	for _, d := range down {
+		spans = append(spans, store.UptimeDownSpan{Start: d.start, End: d.end, Reason: d.reason})
+	}
+	return spans
+}
+
+func uptimeDailyRecord(accountID, nodeID string, bucket time.Time, day uptimeDay) store.UptimeDailyRecord {
+	return store.UptimeDailyRecord{
+		AccountID:                accountID,
+		NodeID:                   nodeID,
+		BucketStart:              bucket,
+		PeriodSeconds:            int64(day.period.Seconds()),
+		DownSeconds:              int64(day.down.Seconds()),
+		MaintenanceSeconds:       int64(day.maint.Seconds()),
+		DownInMaintenanceSeconds: int64(day.downInMaint.Seconds()),
+		Incidents:                day.incidents,
+	}
+}
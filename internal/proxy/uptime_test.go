@@ -0,0 +1,198 @@
+package proxy
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"qcc_plus/internal/store"
+)
+
+// TestBuildDownIntervals 测试从健康检查时间线还原故障区间
+func TestBuildDownIntervals(t *testing.T) {
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+
+	prev := &store.HealthCheckRecord{CheckTime: from.Add(-time.Hour), Success: false, ErrorMessage: "timeout"}
+	recs := []store.HealthCheckRecord{
+		{CheckTime: from.Add(30 * time.Minute), Success: true},
+		{CheckTime: from.Add(2 * time.Hour), Success: false, ErrorMessage: "status 502"},
+		{CheckTime: from.Add(2*time.Hour + 5*time.Minute), Success: false, ErrorMessage: "status 502"},
+		{CheckTime: from.Add(3 * time.Hour), Success: true},
+		{CheckTime: from.Add(23 * time.Hour), Success: false, ErrorMessage: "refused"},
+	}
+
+	got := buildDownIntervals(prev, recs, from, to)
+	if len(got) != 3 {
+		t.Fatalf("expected 3 intervals, got %d", len(got))
+	}
+	if !got[0].start.Equal(from) || got[0].end.Sub(got[0].start) != 30*time.Minute || got[0].reason != "timeout" {
+		t.Errorf("unexpected first interval: %+v", got[0])
+	}
+	if got[1].end.Sub(got[1].start) != time.Hour || got[1].reason != "status 502" || got[1].open {
+		t.Errorf("unexpected second interval: %+v", got[1])
+	}
+	if !got[2].open || !got[2].end.Equal(to) {
+		t.Errorf("expected last interval to stay open until range end: %+v", got[2])
+	}
+}
+
+// TestAvailabilityExcludeMaintenance 测试维护窗口对可用率的影响
+func TestAvailabilityExcludeMaintenance(t *testing.T) {
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(10 * time.Hour)
+	down := []timeInterval{{start: from.Add(time.Hour), end: from.Add(3 * time.Hour)}}
+	maint := []timeInterval{{start: from.Add(2 * time.Hour), end: from.Add(4 * time.Hour)}}
+
+	day := measureUptime(down, maint, 1, from, to)
+	if day.down != 2*time.Hour || day.maint != 2*time.Hour || day.downInMaint != time.Hour {
+		t.Fatalf("unexpected measurement: %+v", day)
+	}
+	if got := availabilityPercent(day.period, day.down, day.maint, day.downInMaint, false); got != 80 {
+		t.Errorf("availability without exclusion = %v, want 80", got)
+	}
+	// 排除维护：故障 1h / 有效时长 8h
+	if got := availabilityPercent(day.period, day.down, day.maint, day.downInMaint, true); got != 87.5 {
+		t.Errorf("availability with exclusion = %v, want 87.5", got)
+	}
+}
+
+// TestAccountDowntimeIntersection 测试账号级不可用取所有节点故障区间交集
+func TestAccountDowntimeIntersection(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	a := mergeIntervals([]timeInterval{
+		{start: base, end: base.Add(2 * time.Hour)},
+		{start: base.Add(time.Hour), end: base.Add(3 * time.Hour)},
+	})
+	if len(a) != 1 || a[0].end != base.Add(3*time.Hour) {
+		t.Fatalf("expected merged interval, got %+v", a)
+	}
+	b := []timeInterval{{start: base.Add(2 * time.Hour), end: base.Add(5 * time.Hour)}}
+	got := intersectIntervals(a, b)
+	if len(got) != 1 || got[0].end.Sub(got[0].start) != time.Hour {
+		t.Fatalf("unexpected intersection: %+v", got)
+	}
+}
+
+// TestUptimeReportUsesRollups 测试周报表对已汇总的整天使用日汇总中的故障区间，不再读取原始记录
+func TestUptimeReportUsesRollups(t *testing.T) {
+	st, err := store.OpenSQLite(filepath.Join(t.TempDir(), "uptime.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer st.Close()
+	srv := newClusterTestServer(t, NewLocalClusterBus(), "a")
+	srv.store = st
+	ctx := context.Background()
+
+	from, to, _, err := uptimeRange(UptimeWindowWeek, time.Now())
+	if err != nil {
+		t.Fatalf("uptime range: %v", err)
+	}
+	// 第 2 天 23:00 故障，跨过零点到第 3 天 01:00 恢复
+	midnight := from.Add(2 * 24 * time.Hour)
+	checks := []store.HealthCheckRecord{
+		{CheckTime: from.Add(time.Minute), Success: true},
+		{CheckTime: midnight.Add(-time.Hour), Success: false, ErrorMessage: "status 502"},
+		{CheckTime: midnight.Add(time.Hour), Success: true},
+	}
+	for i := range checks {
+		checks[i].AccountID, checks[i].NodeID = "acc-1", "n1"
+		if err := st.InsertHealthCheck(ctx, &checks[i]); err != nil {
+			t.Fatalf("insert health check: %v", err)
+		}
+	}
+	for day := from; day.Before(startOfDay(to)); day = day.Add(24 * time.Hour) {
+		if err := rollupUptimeDay(ctx, st, day); err != nil {
+			t.Fatalf("rollup %s: %v", day, err)
+		}
+	}
+	// 汇总后写入的原始故障记录落在已汇总的日期内，报表不应读取
+	for i, ok := range []bool{false, true} {
+		late := store.HealthCheckRecord{AccountID: "acc-1", NodeID: "n1", CheckTime: from.Add(4*24*time.Hour + time.Duration(i+1)*time.Hour), Success: ok, ErrorMessage: "late"}
+		if err := st.InsertHealthCheck(ctx, &late); err != nil {
+			t.Fatalf("insert health check: %v", err)
+		}
+	}
+
+	report, err := srv.buildUptimeReport(ctx, srv.TestAccount("acc-1"), UptimeWindowWeek, "n1", false)
+	if err != nil {
+		t.Fatalf("build report: %v", err)
+	}
+	node := report.Nodes[0]
+	if len(node.Incidents) != 1 {
+		t.Fatalf("expected one incident from rollups, got %+v", node.Incidents)
+	}
+	inc := node.Incidents[0]
+	if inc.DurationSec != 2*3600 || inc.Reason != "status 502" || inc.EndedAt == nil {
+		t.Fatalf("cross-midnight incident not joined: %+v", inc)
+	}
+	if node.DownSeconds != 2*3600 {
+		t.Fatalf("down seconds = %d, want 7200", node.DownSeconds)
+	}
+}
+
+// TestUptimeReportRecomputesAccountAndMaintenance 测试账号级可用性只按启用节点求交集，
+// 且汇总之后新增的维护窗口对已汇总日期生效
+func TestUptimeReportRecomputesAccountAndMaintenance(t *testing.T) {
+	st, err := store.OpenSQLite(filepath.Join(t.TempDir(), "uptime.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer st.Close()
+	srv := newClusterTestServer(t, NewLocalClusterBus(), "a")
+	srv.store = st
+	acc := srv.TestAccount("acc-1")
+	srv.mu.Lock()
+	acc.Nodes["n3"] = &Node{ID: "n3", Name: "n3", AccountID: acc.ID, Disabled: true}
+	srv.mu.Unlock()
+	ctx := context.Background()
+
+	from, to, _, err := uptimeRange(UptimeWindowWeek, time.Now())
+	if err != nil {
+		t.Fatalf("uptime range: %v", err)
+	}
+	// 第 2 天全天 n1、n2 故障，已禁用的 n3 探测正常
+	day := from.Add(2 * 24 * time.Hour)
+	for _, c := range []store.HealthCheckRecord{
+		{NodeID: "n1", CheckTime: day, Success: false, ErrorMessage: "status 502"},
+		{NodeID: "n2", CheckTime: day, Success: false, ErrorMessage: "status 502"},
+		{NodeID: "n3", CheckTime: day, Success: true},
+		{NodeID: "n1", CheckTime: day.Add(24 * time.Hour), Success: true},
+		{NodeID: "n2", CheckTime: day.Add(24 * time.Hour), Success: true},
+	} {
+		c.AccountID = "acc-1"
+		if err := st.InsertHealthCheck(ctx, &c); err != nil {
+			t.Fatalf("insert health check: %v", err)
+		}
+	}
+	for d := from; d.Before(startOfDay(to)); d = d.Add(24 * time.Hour) {
+		if err := rollupUptimeDay(ctx, st, d); err != nil {
+			t.Fatalf("rollup %s: %v", d, err)
+		}
+	}
+
+	report, err := srv.buildUptimeReport(ctx, acc, UptimeWindowWeek, "", false)
+	if err != nil {
+		t.Fatalf("build report: %v", err)
+	}
+	if report.Account.DownSeconds != 24*3600 || report.Account.IncidentCount != 1 {
+		t.Fatalf("expected account down for the whole day, got down=%d incidents=%d", report.Account.DownSeconds, report.Account.IncidentCount)
+	}
+
+	// 汇总之后补录覆盖故障日的维护窗口
+	if err := st.CreateMaintenanceWindow(ctx, &store.MaintenanceWindowRecord{AccountID: "acc-1", StartsAt: day, EndsAt: day.Add(24 * time.Hour), Reason: "upgrade"}); err != nil {
+		t.Fatalf("create maintenance window: %v", err)
+	}
+	report, err = srv.buildUptimeReport(ctx, acc, UptimeWindowWeek, "", true)
+	if err != nil {
+		t.Fatalf("build report: %v", err)
+	}
+	if report.Account.MaintenanceSeconds != 24*3600 || report.Account.DownSeconds != 0 || report.Account.Availability != 100 {
+		t.Fatalf("expected late maintenance window applied, got %+v", report.Account)
+	}
+	if n := report.Nodes[0]; n.MaintenanceSeconds != 24*3600 || n.DownSeconds != 0 {
+		t.Fatalf("expected node maintenance applied, got %+v", n)
+	}
+}
@@ -43,28 +43,30 @@ func errString(err error) string {
 
 func toRecord(n *Node) store.NodeRecord {
 	return store.NodeRecord{
-		ID:                n.ID,
-		Name:              n.Name,
-		BaseURL:           n.URL.String(),
-		APIKey:            n.APIKey,
-		HealthCheckMethod: n.HealthCheckMethod,
-		HealthCheckModel:  n.HealthCheckModel,
-		AccountID:         chooseNonEmpty(n.AccountID, store.DefaultAccountID),
-		Weight:            n.Weight,
-		Failed:            n.Failed,
-		Disabled:          n.Disabled,
-		LastError:         n.LastError,
-		CreatedAt:         n.CreatedAt,
-		Requests:          n.Metrics.Requests,
-		FailCount:         n.Metrics.FailCount,
-		FailStreak:        n.Metrics.FailStreak,
-		TotalBytes:        n.Metrics.TotalBytes,
-		TotalInput:        n.Metrics.TotalInputTokens,
-		TotalOutput:       n.Metrics.TotalOutputTokens,
-		StreamDurMs:       n.Metrics.StreamDur.Milliseconds(),
-		FirstByteMs:       n.Metrics.FirstByteDur.Milliseconds(),
-		LastPingMs:        n.Metrics.LastPingMS,
-		LastPingErr:       n.Metrics.LastPingErr,
-		LastHealthCheckAt: n.Metrics.LastHealthCheckAt,
+		ID:                 n.ID,
+		Name:               n.Name,
+		BaseURL:            n.URL.String(),
+		APIKey:             n.APIKey,
+		HealthCheckMethod:  n.HealthCheckMethod,
+		HealthCheckModel:   n.HealthCheckModel,
+		AccountID:          chooseNonEmpty(n.AccountID, store.DefaultAccountID),
+		Weight:             n.Weight,
+		Failed:             n.Failed,
+		Disabled:           n.Disabled,
+		LastError:          n.LastError,
+		CreatedAt:          n.CreatedAt,
+		Requests:           n.Metrics.Requests,
+		FailCount:          n.Metrics.FailCount,
+		FailStreak:         n.Metrics.FailStreak,
+		TotalBytes:         n.Metrics.TotalBytes,
+		TotalInput:         n.Metrics.TotalInputTokens,
+		TotalOutput:        n.Metrics.TotalOutputTokens,
+		TotalCacheCreation: n.Metrics.TotalCacheCreationTokens,
+		TotalCacheRead:     n.Metrics.TotalCacheReadTokens,
+		StreamDurMs:        n.Metrics.StreamDur.Milliseconds(),
+		FirstByteMs:        n.Metrics.FirstByteDur.Milliseconds(),
+		LastPingMs:         n.Metrics.LastPingMS,
+		LastPingErr:        n.Metrics.LastPingErr,
+		LastHealthCheckAt:  n.Metrics.LastHealthCheckAt,
 	}
 }
@@ -3,8 +3,9 @@ package proxy
 import (
 	"context"
 	"fmt"
-	"log"
 	"time"
+
+	"qcc_plus/internal/logging"
 )
 
 const (
@@ -54,13 +55,13 @@ func loadWarmupConfig() WarmupConfig {
 }
 
 // normalizeWarmupConcurrency 将并发度限制在 [1, maxWarmupConcurrency] 区间。
-func normalizeWarmupConcurrency(n int, logger *log.Logger) int {
+func normalizeWarmupConcurrency(n int, logger *logging.Logger) int {
 	if n <= 0 {
 		n = defaultWarmupConcurrency
 	}
 	if n > maxWarmupConcurrency {
 		if logger != nil {
-			logger.Printf("reduce warmup concurrency from %d to %d to protect low-resource host", n, maxWarmupConcurrency)
+			logger.Warn("reduce warmup concurrency to protect low-resource host", "value", n, "max", maxWarmupConcurrency)
 		}
 		n = maxWarmupConcurrency
 	}
@@ -120,7 +121,7 @@ func (p *Server) warmupNode(node *Node) (int, error) {
 			}
 		case <-ctx.Done():
 			if p.logger != nil {
-				p.logger.Printf("warmup attempt %d for node %s timed out after %v", i+1, node.Name, timeout)
+				p.logger.Component("node").Warn("warmup attempt timed out", logging.KeyAccountID, acc.ID, logging.KeyNodeID, node.ID, "node", node.Name, "attempt", i+1, "timeout", timeout)
 			}
 		}
 		cancel()
@@ -1,8 +1,11 @@
 package proxy
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -384,3 +387,56 @@ func TestSelectBestAndActivateWithWarmup(t *testing.T) {
 		t.Errorf("expected node2 ID %s to be active, got %s", node2.ID, activeID)
 	}
 }
+
+// TestWarmupRunsOnFollower 测试从实例切换节点时同样先预热，预热不受选主限制
+func TestWarmupRunsOnFollower(t *testing.T) {
+	t.Setenv("PROXY_SQLITE_PATH", filepath.Join(t.TempDir(), "warmup.db"))
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer up.Close()
+	var calls atomic.Int32
+	up2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Write([]byte(`{"id":"msg-123","type":"message","role":"assistant","content":[{"type":"text","text":"ok"}]}`))
+	}))
+	defer up2.Close()
+
+	srv := buildServerNoWarmup(t, NewBuilder().WithUpstream(up.URL))
+	defer srv.store.Close()
+	srv.warmupConfig = WarmupConfig{Enabled: true, Attempts: 1, Timeout: 2 * time.Second, RequiredSuccess: 1}
+
+	holder := NewLeaderElector(srv.store, LeaderConfig{InstanceID: "other", LeaseTTL: time.Minute}, nil)
+	holder.Start()
+	defer holder.Stop()
+	srv.leader = NewLeaderElector(srv.store, LeaderConfig{InstanceID: "self", LeaseTTL: time.Minute}, nil)
+	srv.leader.Start()
+	defer srv.leader.Stop()
+	if srv.isLeader() {
+		t.Fatalf("expected follower while another instance holds the lease")
+	}
+
+	accounts, err := srv.store.ListAccounts(context.Background())
+	if err != nil || len(accounts) == 0 {
+		t.Fatalf("list accounts: %v", err)
+	}
+	acc := srv.TestAccount(accounts[0].ID)
+	node2, err := srv.addNodeWithMethod(acc, "node2", up2.URL, "key2", 99, HealthCheckMethodAPI, "")
+	if err != nil {
+		t.Fatalf("add node2: %v", err)
+	}
+	srv.mu.Lock()
+	for id, n := range acc.Nodes {
+		if id != node2.ID {
+			n.Failed = true
+			acc.FailedSet[id] = struct{}{}
+		}
+	}
+	srv.mu.Unlock()
+
+	got, err := srv.selectBestAndActivate(acc, "测试切换")
+	if err != nil || got.ID != node2.ID {
+		t.Fatalf("expected node2 activated, got %v %v", got, err)
+	}
+	if calls.Load() == 0 {
+		t.Fatalf("expected follower to warm up node2 before activation")
+	}
+}
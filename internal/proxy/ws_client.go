@@ -1,10 +1,11 @@
 package proxy
 
 import (
-	"log"
 	"time"
 
 	"github.com/gorilla/websocket"
+
+	"qcc_plus/internal/logging"
 )
 
 const (
@@ -31,7 +32,7 @@ func (c *WSClient) readPump() {
 	for {
 		if _, _, err := c.conn.ReadMessage(); err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("websocket error: %v", err)
+				logging.Default().Component("api").Warn("websocket read failed", logging.KeyError, err)
 			}
 			break
 		}
@@ -0,0 +1,76 @@
+package store
+
+import (
+	"context"
+	"fmt"
+)
+
+// TokenUsage 一次请求的 token 用量，缓存写入/读取与普通输入分开计价。
+type TokenUsage struct {
+	InputTokens         int64 // 未命中缓存的输入 tokens（input_tokens）
+	OutputTokens        int64
+	CacheCreationTokens int64 // 写入缓存的输入 tokens（cache_creation_input_tokens）
+	CacheReadTokens     int64 // 命中缓存的输入 tokens（cache_read_input_tokens）
+}
+
+// 未单独配置缓存价格时按官方倍率推导：写入 1.25 倍、读取 0.1 倍输入价格。
+const (
+	defaultCacheWriteMultiplier = 1.25
+	defaultCacheReadMultiplier  = 0.1
+)
+
+// migrateCacheTokens 为节点、监控、使用日志与定价表补齐提示缓存相关列。
+// 定价表新增列时按默认倍率回填已有模型的缓存价格。
+func (s *Store) migrateCacheTokens(ctx context.Context) error {
+	columns := []struct {
+		table  string
+		name   string
+		sqlite string
+		mysql  string
+	}{
+		{"nodes", "total_cache_creation", "INTEGER DEFAULT 0", "BIGINT DEFAULT 0 AFTER total_output"},
+		{"nodes", "total_cache_read", "INTEGER DEFAULT 0", "BIGINT DEFAULT 0 AFTER total_cache_creation"},
+		{"usage_logs", "cache_creation_tokens", "INTEGER NOT NULL DEFAULT 0", "BIGINT NOT NULL DEFAULT 0 AFTER output_tokens"},
+		{"usage_logs", "cache_read_tokens", "INTEGER NOT NULL DEFAULT 0", "BIGINT NOT NULL DEFAULT 0 AFTER cache_creation_tokens"},
+		{"model_pricing", "cache_write_price_mtok", "REAL NOT NULL DEFAULT 0", "DECIMAL(10,6) NOT NULL DEFAULT 0 AFTER output_price_mtok"},
+		{"model_pricing", "cache_read_price_mtok", "REAL NOT NULL DEFAULT 0", "DECIMAL(10,6) NOT NULL DEFAULT 0 AFTER cache_write_price_mtok"},
+	}
+	for _, tbl := range []string{"node_metrics_raw", "node_metrics_hourly", "node_metrics_daily", "node_metrics_monthly"} {
+		columns = append(columns,
+			struct{ table, name, sqlite, mysql string }{tbl, "cache_creation_tokens_total", "INTEGER DEFAULT 0", "BIGINT DEFAULT 0 AFTER output_tokens_total"},
+			struct{ table, name, sqlite, mysql string }{tbl, "cache_read_tokens_total", "INTEGER DEFAULT 0", "BIGINT DEFAULT 0 AFTER cache_creation_tokens_total"},
+		)
+	}
+
+	pricingAdded := false
+	for _, col := range columns {
+		exists, err := s.columnExists(ctx, col.table, col.name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		def := col.mysql
+		if s.IsSQLite() {
+			def = col.sqlite
+		}
+		alterCtx, cancel := withTimeout(ctx)
+		_, err = s.db.ExecContext(alterCtx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", col.table, col.name, def))
+		cancel()
+		if err != nil {
+			return err
+		}
+		if col.table == "model_pricing" {
+			pricingAdded = true
+		}
+	}
+	if !pricingAdded {
+		return nil
+	}
+	updCtx, cancel := withTimeout(ctx)
+	defer cancel()
+	_, err := s.db.ExecContext(updCtx, `UPDATE model_pricing SET cache_write_price_mtok = input_price_mtok * ?, cache_read_price_mtok = input_price_mtok * ?`,
+		defaultCacheWriteMultiplier, defaultCacheReadMultiplier)
+	return err
+}
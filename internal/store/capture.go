@@ -0,0 +1,210 @@
+package store
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"time"
+)
+
+// ensureRequestCapturesTable 创建请求抓包表，请求/响应体以 gzip 压缩保存。
+func (s *Store) ensureRequestCapturesTable(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	if s.IsSQLite() {
+		if _, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS request_captures (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			request_id TEXT NOT NULL DEFAULT '',
+			account_id TEXT NOT NULL,
+			node_id TEXT NOT NULL DEFAULT '',
+			model_id TEXT NOT NULL DEFAULT '',
+			method TEXT NOT NULL DEFAULT '',
+			path TEXT NOT NULL DEFAULT '',
+			status INTEGER NOT NULL DEFAULT 0,
+			stream INTEGER NOT NULL DEFAULT 0,
+			request_headers TEXT,
+			response_headers TEXT,
+			request_body BLOB,
+			response_body BLOB,
+			request_bytes INTEGER NOT NULL DEFAULT 0,
+			response_bytes INTEGER NOT NULL DEFAULT 0,
+			truncated INTEGER NOT NULL DEFAULT 0,
+			created_at DATETIME NOT NULL
+		)`); err != nil {
+			return err
+		}
+		_, err := s.db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_request_captures_account_time ON request_captures(account_id, created_at)`)
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS request_captures (
+		id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+		request_id VARCHAR(128) NOT NULL DEFAULT '',
+		account_id VARCHAR(64) NOT NULL,
+		node_id VARCHAR(64) NOT NULL DEFAULT '',
+		model_id VARCHAR(128) NOT NULL DEFAULT '',
+		method VARCHAR(16) NOT NULL DEFAULT '',
+		path VARCHAR(255) NOT NULL DEFAULT '',
+		status INT NOT NULL DEFAULT 0,
+		stream BOOLEAN NOT NULL DEFAULT FALSE,
+		request_headers TEXT,
+		response_headers TEXT,
+		request_body MEDIUMBLOB,
+		response_body MEDIUMBLOB,
+		request_bytes BIGINT NOT NULL DEFAULT 0,
+		response_bytes BIGINT NOT NULL DEFAULT 0,
+		truncated BOOLEAN NOT NULL DEFAULT FALSE,
+		created_at DATETIME(3) NOT NULL,
+		KEY idx_request_captures_account_time (account_id, created_at)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`)
+	return err
+}
+
+func gzipBytes(b []byte) ([]byte, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(b); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func gunzipBytes(b []byte) ([]byte, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+// InsertRequestCapture 压缩并保存一次抓包，返回记录 ID。
+func (s *Store) InsertRequestCapture(ctx context.Context, rec RequestCaptureRecord) (int64, error) {
+	if s == nil || s.db == nil {
+		return 0, errors.New("store not initialized")
+	}
+	reqBody, err := gzipBytes(rec.RequestBody)
+	if err != nil {
+		return 0, err
+	}
+	respBody, err := gzipBytes(rec.ResponseBody)
+	if err != nil {
+		return 0, err
+	}
+	if rec.CreatedAt.IsZero() {
+		rec.CreatedAt = time.Now()
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	res, err := s.db.ExecContext(ctx, `INSERT INTO request_captures (request_id, account_id, node_id, model_id, method, path, status, stream,
+		request_headers, response_headers, request_body, response_body, request_bytes, response_bytes, truncated, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		rec.RequestID, normalizeAccount(rec.AccountID), rec.NodeID, rec.ModelID, rec.Method, rec.Path, rec.Status, rec.Stream,
+		rec.RequestHeaders, rec.ResponseHeaders, reqBody, respBody, rec.RequestBytes, rec.ResponseBytes, rec.Truncated, rec.CreatedAt.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+const captureMetaColumns = `id, request_id, account_id, node_id, model_id, method, path, status, stream,
+	request_headers, response_headers, request_bytes, response_bytes, truncated, created_at`
+
+func scanCaptureMeta(scan func(dest ...any) error, rec *RequestCaptureRecord, extra ...any) error {
+	var reqHeaders, respHeaders sql.NullString
+	dest := []any{&rec.ID, &rec.RequestID, &rec.AccountID, &rec.NodeID, &rec.ModelID, &rec.Method, &rec.Path, &rec.Status, &rec.Stream,
+		&reqHeaders, &respHeaders, &rec.RequestBytes, &rec.ResponseBytes, &rec.Truncated, &rec.CreatedAt}
+	if err := scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+	rec.RequestHeaders = reqHeaders.String
+	rec.ResponseHeaders = respHeaders.String
+	rec.CreatedAt = rec.CreatedAt.UTC()
+	return nil
+}
+
+// ListRequestCaptures 按时间倒序列出抓包（不含请求/响应体），accountID 为空表示全部账号。
+func (s *Store) ListRequestCaptures(ctx context.Context, accountID string, limit, offset int) ([]RequestCaptureRecord, error) {
+	if s == nil || s.db == nil {
+		return nil, errors.New("store not initialized")
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+	query := `SELECT ` + captureMetaColumns + ` FROM request_captures`
+	var args []any
+	if accountID != "" {
+		query += ` WHERE account_id = ?`
+		args = append(args, normalizeAccount(accountID))
+	}
+	query += ` ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?`
+	args = append(args, limit, offset)
+
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []RequestCaptureRecord
+	for rows.Next() {
+		var rec RequestCaptureRecord
+		if err := scanCaptureMeta(rows.Scan, &rec); err != nil {
+			return nil, err
+		}
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
+
+// GetRequestCapture 读取并解压单条抓包。
+func (s *Store) GetRequestCapture(ctx context.Context, id int64) (*RequestCaptureRecord, error) {
+	if s == nil || s.db == nil {
+		return nil, errors.New("store not initialized")
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	row := s.db.QueryRowContext(ctx, `SELECT `+captureMetaColumns+`, request_body, response_body FROM request_captures WHERE id = ?`, id)
+	var rec RequestCaptureRecord
+	var reqBody, respBody []byte
+	if err := scanCaptureMeta(row.Scan, &rec, &reqBody, &respBody); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	var err error
+	if rec.RequestBody, err = gunzipBytes(reqBody); err != nil {
+		return nil, err
+	}
+	if rec.ResponseBody, err = gunzipBytes(respBody); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// CleanupRequestCaptures 删除 before 之前的抓包，返回删除条数。
+func (s *Store) CleanupRequestCaptures(ctx context.Context, before time.Time) (int64, error) {
+	if s == nil || s.db == nil {
+		return 0, errors.New("store not initialized")
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	res, err := s.db.ExecContext(ctx, `DELETE FROM request_captures WHERE created_at < ?`, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,273 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ensureCircuitBreakerTables 创建熔断器状态/覆盖表与状态转换日志表。
+func (s *Store) ensureCircuitBreakerTables(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	if s.IsSQLite() {
+		if _, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS node_circuit_breakers (
+			node_id TEXT PRIMARY KEY,
+			account_id TEXT NOT NULL DEFAULT '',
+			state TEXT NOT NULL DEFAULT 'closed',
+			state_changed_at DATETIME,
+			forced INTEGER NOT NULL DEFAULT 0,
+			window_seconds INTEGER NOT NULL DEFAULT 0,
+			failure_rate REAL NOT NULL DEFAULT 0,
+			consecutive_fails INTEGER NOT NULL DEFAULT 0,
+			cooldown_seconds INTEGER NOT NULL DEFAULT 0,
+			half_open_max_calls INTEGER NOT NULL DEFAULT 0,
+			updated_at DATETIME NOT NULL
+		)`); err != nil {
+			return err
+		}
+		if _, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS circuit_breaker_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			node_id TEXT NOT NULL,
+			account_id TEXT NOT NULL DEFAULT '',
+			instance_id TEXT NOT NULL DEFAULT '',
+			from_state TEXT NOT NULL,
+			to_state TEXT NOT NULL,
+			reason TEXT NOT NULL DEFAULT '',
+			consecutive_fails INTEGER NOT NULL DEFAULT 0,
+			failure_rate REAL NOT NULL DEFAULT 0,
+			created_at DATETIME NOT NULL
+		)`); err != nil {
+			return err
+		}
+		_, _ = s.db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_cb_events_node_time ON circuit_breaker_events(node_id, created_at)`)
+		_, _ = s.db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_cb_events_time ON circuit_breaker_events(created_at)`)
+		return nil
+	}
+	if _, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS node_circuit_breakers (
+		node_id VARCHAR(64) PRIMARY KEY,
+		account_id VARCHAR(64) NOT NULL DEFAULT '',
+		state VARCHAR(16) NOT NULL DEFAULT 'closed',
+		state_changed_at DATETIME(3) NULL,
+		forced TINYINT(1) NOT NULL DEFAULT 0,
+		window_seconds INT NOT NULL DEFAULT 0,
+		failure_rate DOUBLE NOT NULL DEFAULT 0,
+		consecutive_fails INT NOT NULL DEFAULT 0,
+		cooldown_seconds INT NOT NULL DEFAULT 0,
+		half_open_max_calls INT NOT NULL DEFAULT 0,
+		updated_at DATETIME(3) NOT NULL
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS circuit_breaker_events (
+		id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+		node_id VARCHAR(64) NOT NULL,
+		account_id VARCHAR(64) NOT NULL DEFAULT '',
+		instance_id VARCHAR(255) NOT NULL DEFAULT '',
+		from_state VARCHAR(16) NOT NULL,
+		to_state VARCHAR(16) NOT NULL,
+		reason VARCHAR(64) NOT NULL DEFAULT '',
+		consecutive_fails INT NOT NULL DEFAULT 0,
+		failure_rate DOUBLE NOT NULL DEFAULT 0,
+		created_at DATETIME(3) NOT NULL,
+		KEY idx_cb_events_node_time (node_id, created_at),
+		KEY idx_cb_events_time (created_at)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`)
+	return err
+}
+
+// SaveCircuitBreakerState 写入节点熔断器当前状态，不影响参数覆盖字段。
+func (s *Store) SaveCircuitBreakerState(ctx context.Context, rec CircuitBreakerRecord) error {
+	if s == nil || s.db == nil {
+		return errors.New("store not initialized")
+	}
+	if rec.NodeID == "" {
+		return errors.New("node_id required")
+	}
+	now := time.Now().UTC()
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	var stmt string
+	if s.IsSQLite() {
+		stmt = `INSERT INTO node_circuit_breakers (node_id, account_id, state, state_changed_at, forced, updated_at)
+			VALUES (?,?,?,?,?,?)
+			ON CONFLICT(node_id) DO UPDATE SET
+				account_id=excluded.account_id,
+				state=excluded.state,
+				state_changed_at=excluded.state_changed_at,
+				forced=excluded.forced,
+				updated_at=excluded.updated_at`
+	} else {
+		stmt = `INSERT INTO node_circuit_breakers (node_id, account_id, state, state_changed_at, forced, updated_at)
+			VALUES (?,?,?,?,?,?)
+			ON DUPLICATE KEY UPDATE
+				account_id=VALUES(account_id),
+				state=VALUES(state),
+				state_changed_at=VALUES(state_changed_at),
+				forced=VALUES(forced),
+				updated_at=VALUES(updated_at)`
+	}
+	_, err := s.db.ExecContext(ctx, stmt, rec.NodeID, rec.AccountID, rec.State, rec.StateChangedAt.UTC(), rec.Forced, now)
+	return err
+}
+
+// SaveCircuitBreakerOverride 写入节点熔断参数覆盖，不影响状态字段。
+func (s *Store) SaveCircuitBreakerOverride(ctx context.Context, rec CircuitBreakerRecord) error {
+	if s == nil || s.db == nil {
+		return errors.New("store not initialized")
+	}
+	if rec.NodeID == "" {
+		return errors.New("node_id required")
+	}
+	now := time.Now().UTC()
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	var stmt string
+	if s.IsSQLite() {
+		stmt = `INSERT INTO node_circuit_breakers (node_id, account_id, window_seconds, failure_rate, consecutive_fails, cooldown_seconds, half_open_max_calls, updated_at)
+			VALUES (?,?,?,?,?,?,?,?)
+			ON CONFLICT(node_id) DO UPDATE SET
+				account_id=excluded.account_id,
+				window_seconds=excluded.window_seconds,
+				failure_rate=excluded.failure_rate,
+				consecutive_fails=excluded.consecutive_fails,
+				cooldown_seconds=excluded.cooldown_seconds,
+				half_open_max_calls=excluded.half_open_max_calls,
+				updated_at=excluded.updated_at`
+	} else {
+		stmt = `INSERT INTO node_circuit_breakers (node_id, account_id, window_seconds, failure_rate, consecutive_fails, cooldown_seconds, half_open_max_calls, updated_at)
+			VALUES (?,?,?,?,?,?,?,?)
+			ON DUPLICATE KEY UPDATE
+				account_id=VALUES(account_id),
+				window_seconds=VALUES(window_seconds),
+				failure_rate=VALUES(failure_rate),
+				consecutive_fails=VALUES(consecutive_fails),
+				cooldown_seconds=VALUES(cooldown_seconds),
+				half_open_max_calls=VALUES(half_open_max_calls),
+				updated_at=VALUES(updated_at)`
+	}
+	_, err := s.db.ExecContext(ctx, stmt, rec.NodeID, rec.AccountID, rec.WindowSeconds, rec.FailureRate,
+		rec.ConsecutiveFails, rec.CooldownSeconds, rec.HalfOpenMaxCalls, now)
+	return err
+}
+
+// ListCircuitBreakers 返回所有节点的熔断器持久化记录。
+func (s *Store) ListCircuitBreakers(ctx context.Context) ([]CircuitBreakerRecord, error) {
+	if s == nil || s.db == nil {
+		return nil, errors.New("store not initialized")
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, `SELECT node_id, account_id, state, state_changed_at, forced, window_seconds, failure_rate,
+		consecutive_fails, cooldown_seconds, half_open_max_calls, updated_at FROM node_circuit_breakers`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []CircuitBreakerRecord
+	for rows.Next() {
+		var rec CircuitBreakerRecord
+		var changedAt sql.NullTime
+		if err := rows.Scan(&rec.NodeID, &rec.AccountID, &rec.State, &changedAt, &rec.Forced, &rec.WindowSeconds, &rec.FailureRate,
+			&rec.ConsecutiveFails, &rec.CooldownSeconds, &rec.HalfOpenMaxCalls, &rec.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if changedAt.Valid {
+			rec.StateChangedAt = changedAt.Time.UTC()
+		}
+		rec.UpdatedAt = rec.UpdatedAt.UTC()
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
+
+// DeleteCircuitBreaker 删除节点的熔断器记录（节点删除时调用）。
+func (s *Store) DeleteCircuitBreaker(ctx context.Context, nodeID string) error {
+	if s == nil || s.db == nil {
+		return errors.New("store not initialized")
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	_, err := s.db.ExecContext(ctx, `DELETE FROM node_circuit_breakers WHERE node_id=?`, nodeID)
+	return err
+}
+
+// InsertCircuitBreakerEvent 记录一次熔断器状态转换。
+func (s *Store) InsertCircuitBreakerEvent(ctx context.Context, rec CircuitBreakerEventRecord) error {
+	if s == nil || s.db == nil {
+		return errors.New("store not initialized")
+	}
+	if rec.CreatedAt.IsZero() {
+		rec.CreatedAt = time.Now()
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	_, err := s.db.ExecContext(ctx, `INSERT INTO circuit_breaker_events (node_id, account_id, instance_id, from_state, to_state, reason, consecutive_fails, failure_rate, created_at)
+		VALUES (?,?,?,?,?,?,?,?,?)`,
+		rec.NodeID, rec.AccountID, rec.InstanceID, rec.FromState, rec.ToState, rec.Reason, rec.ConsecutiveFails, rec.FailureRate, rec.CreatedAt.UTC())
+	return err
+}
+
+// ListCircuitBreakerEvents 按时间倒序返回状态转换日志；nodeID/accountID 为空时不过滤，limit<=0 时默认 100。
+func (s *Store) ListCircuitBreakerEvents(ctx context.Context, accountID, nodeID string, from, to time.Time, limit int) ([]CircuitBreakerEventRecord, error) {
+	if s == nil || s.db == nil {
+		return nil, errors.New("store not initialized")
+	}
+	if limit <= 0 {
+		limit = 100
+	}
+	query := `SELECT id, node_id, account_id, instance_id, from_state, to_state, reason, consecutive_fails, failure_rate, created_at FROM circuit_breaker_events WHERE 1=1`
+	var args []interface{}
+	if accountID != "" {
+		query += ` AND account_id=?`
+		args = append(args, accountID)
+	}
+	if nodeID != "" {
+		query += ` AND node_id=?`
+		args = append(args, nodeID)
+	}
+	if !from.IsZero() {
+		query += ` AND created_at >= ?`
+		args = append(args, from.UTC())
+	}
+	if !to.IsZero() {
+		query += ` AND created_at < ?`
+		args = append(args, to.UTC())
+	}
+	query += ` ORDER BY created_at DESC, id DESC LIMIT ?`
+	args = append(args, limit)
+
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []CircuitBreakerEventRecord
+	for rows.Next() {
+		var rec CircuitBreakerEventRecord
+		if err := rows.Scan(&rec.ID, &rec.NodeID, &rec.AccountID, &rec.InstanceID, &rec.FromState, &rec.ToState, &rec.Reason,
+			&rec.ConsecutiveFails, &rec.FailureRate, &rec.CreatedAt); err != nil {
+			return nil, err
+		}
+		rec.CreatedAt = rec.CreatedAt.UTC()
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
+
+// CleanupCircuitBreakerEvents 删除 before 之前的状态转换日志。
+func (s *Store) CleanupCircuitBreakerEvents(ctx context.Context, before time.Time) (int64, error) {
+	if s == nil || s.db == nil {
+		return 0, errors.New("store not initialized")
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	res, err := s.db.ExecContext(ctx, `DELETE FROM circuit_breaker_events WHERE created_at < ?`, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
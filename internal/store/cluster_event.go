@@ -0,0 +1,119 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ensureClusterEventsTable 创建集群事件表（多实例间广播节点状态与配置变更）。
+func (s *Store) ensureClusterEventsTable(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	if s.IsSQLite() {
+		if _, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS cluster_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			instance_id TEXT NOT NULL,
+			account_id TEXT NOT NULL DEFAULT '',
+			node_id TEXT NOT NULL DEFAULT '',
+			event_type TEXT NOT NULL,
+			payload TEXT,
+			created_at DATETIME NOT NULL
+		)`); err != nil {
+			return err
+		}
+		_, _ = s.db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_cluster_events_created ON cluster_events(created_at)`)
+		return nil
+	}
+	_, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS cluster_events (
+		id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+		instance_id VARCHAR(255) NOT NULL,
+		account_id VARCHAR(64) NOT NULL DEFAULT '',
+		node_id VARCHAR(64) NOT NULL DEFAULT '',
+		event_type VARCHAR(64) NOT NULL,
+		payload TEXT,
+		created_at DATETIME(3) NOT NULL,
+		KEY idx_cluster_events_created (created_at)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`)
+	return err
+}
+
+// AppendClusterEvent 写入集群事件，并回填自增 ID（即事件版本号）。
+func (s *Store) AppendClusterEvent(ctx context.Context, rec *ClusterEventRecord) error {
+	if s == nil || s.db == nil {
+		return errors.New("store not initialized")
+	}
+	if rec == nil || rec.EventType == "" {
+		return errors.New("event_type required")
+	}
+	if rec.CreatedAt.IsZero() {
+		rec.CreatedAt = time.Now().UTC()
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	res, err := s.db.ExecContext(ctx, `INSERT INTO cluster_events (instance_id, account_id, node_id, event_type, payload, created_at) VALUES (?,?,?,?,?,?)`,
+		rec.InstanceID, rec.AccountID, rec.NodeID, rec.EventType, rec.Payload, rec.CreatedAt)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	rec.ID = id
+	return nil
+}
+
+// ListClusterEventsAfter 按 ID 升序返回 afterID 之后的事件，limit<=0 时默认 500。
+func (s *Store) ListClusterEventsAfter(ctx context.Context, afterID int64, limit int) ([]ClusterEventRecord, error) {
+	if s == nil || s.db == nil {
+		return nil, errors.New("store not initialized")
+	}
+	if limit <= 0 {
+		limit = 500
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, `SELECT id, instance_id, account_id, node_id, event_type, COALESCE(payload, ''), created_at
+		FROM cluster_events WHERE id > ? ORDER BY id ASC LIMIT ?`, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ClusterEventRecord
+	for rows.Next() {
+		var rec ClusterEventRecord
+		if err := rows.Scan(&rec.ID, &rec.InstanceID, &rec.AccountID, &rec.NodeID, &rec.EventType, &rec.Payload, &rec.CreatedAt); err != nil {
+			return nil, err
+		}
+		rec.CreatedAt = rec.CreatedAt.UTC()
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
+
+// LatestClusterEventID 返回当前最大事件 ID，无事件时返回 0。
+func (s *Store) LatestClusterEventID(ctx context.Context) (int64, error) {
+	if s == nil || s.db == nil {
+		return 0, errors.New("store not initialized")
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	var id int64
+	err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(id), 0) FROM cluster_events`).Scan(&id)
+	return id, err
+}
+
+// CleanupClusterEvents 删除 before 之前的事件，返回删除条数。
+func (s *Store) CleanupClusterEvents(ctx context.Context, before time.Time) (int64, error) {
+	if s == nil || s.db == nil {
+		return 0, errors.New("store not initialized")
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	res, err := s.db.ExecContext(ctx, `DELETE FROM cluster_events WHERE created_at < ?`, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,135 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+// 导出接口逐行读取游标并回调，不设整体超时、不缓存结果集，由调用方通过 ctx 取消。
+
+// StreamUsageLogs 按时间升序遍历使用日志，忽略 Limit/Offset。
+func (s *Store) StreamUsageLogs(ctx context.Context, params QueryUsageParams, fn func(*UsageLogRecord) error) error {
+	if s == nil || s.db == nil {
+		return errors.New("store not initialized")
+	}
+	query := `SELECT id, account_id, node_id, model_id, input_tokens, output_tokens, cache_creation_tokens, cache_read_tokens, cost_usd, actual_cost_usd, pricing_rule_id, unpriced, key_hint, request_id, upstream_request_id, attempt_trace, success, created_at
+		FROM usage_logs WHERE 1=1`
+	var args []interface{}
+	if params.AccountID != "" {
+		query += " AND account_id = ?"
+		args = append(args, normalizeAccount(params.AccountID))
+	}
+	if params.NodeID != "" {
+		query += " AND node_id = ?"
+		args = append(args, params.NodeID)
+	}
+	if params.ModelID != "" {
+		query += " AND model_id = ?"
+		args = append(args, params.ModelID)
+	}
+	if !params.From.IsZero() {
+		query += " AND created_at >= ?"
+		args = append(args, params.From.UTC())
+	}
+	if !params.To.IsZero() {
+		query += " AND created_at < ?"
+		args = append(args, params.To.UTC())
+	}
+	query += " ORDER BY created_at ASC, id ASC"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		log, err := scanUsageLog(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(log); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// StreamMetrics 按时间升序遍历指定粒度的监控数据；未指定账号时导出全部账号。
+func (s *Store) StreamMetrics(ctx context.Context, q MetricsQuery, fn func(*MetricsRecord) error) error {
+	if s == nil || s.db == nil {
+		return errors.New("store not initialized")
+	}
+	q.Limit, q.Offset = 0, 0
+	query, args, err := metricsSelect(q, true)
+	if err != nil {
+		return err
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		r, err := scanMetricsRecord(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(&r); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// StreamHealthChecks 按检查时间升序遍历健康检查历史；账号、节点为空表示不过滤。
+func (s *Store) StreamHealthChecks(ctx context.Context, params QueryHealthCheckParams, fn func(*HealthCheckRecord) error) error {
+	if s == nil || s.db == nil {
+		return errors.New("store not initialized")
+	}
+	conds := []string{"1=1"}
+	var args []interface{}
+	if params.AccountID != "" {
+		conds = append(conds, "account_id=?")
+		args = append(args, normalizeAccount(params.AccountID))
+	}
+	if params.NodeID != "" {
+		conds = append(conds, "node_id=?")
+		args = append(args, params.NodeID)
+	}
+	if params.CheckSource != "" {
+		conds = append(conds, "check_source=?")
+		args = append(args, params.CheckSource)
+	}
+	if !params.From.IsZero() {
+		conds = append(conds, "check_time >= ?")
+		args = append(args, params.From.UTC())
+	}
+	if !params.To.IsZero() {
+		conds = append(conds, "check_time < ?")
+		args = append(args, params.To.UTC())
+	}
+	query := `SELECT id, account_id, node_id, check_time, success, response_time_ms, error_message, error_category, check_method, check_source, created_at
+		FROM health_check_history WHERE ` + strings.Join(conds, " AND ") + ` ORDER BY check_time ASC, id ASC`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var rec HealthCheckRecord
+		var resp sql.NullInt64
+		if err := rows.Scan(&rec.ID, &rec.AccountID, &rec.NodeID, &rec.CheckTime, &rec.Success, &resp, &rec.ErrorMessage, &rec.ErrorCategory, &rec.CheckMethod, &rec.CheckSource, &rec.CreatedAt); err != nil {
+			return err
+		}
+		if resp.Valid {
+			rec.ResponseTimeMs = int(resp.Int64)
+		}
+		if err := fn(&rec); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -46,9 +46,9 @@ func (s *Store) InsertHealthCheck(ctx context.Context, record *HealthCheckRecord
 	}
 
 	_, err := s.db.ExecContext(ctx, `INSERT INTO health_check_history (
-		account_id, node_id, check_time, success, response_time_ms, error_message, check_method, check_source, created_at)
-		VALUES (?,?,?,?,?,?,?,?,?)`,
-		record.AccountID, record.NodeID, record.CheckTime, record.Success, resp, record.ErrorMessage, record.CheckMethod, record.CheckSource, record.CreatedAt)
+		account_id, node_id, check_time, success, response_time_ms, error_message, error_category, check_method, check_source, created_at)
+		VALUES (?,?,?,?,?,?,?,?,?,?)`,
+		record.AccountID, record.NodeID, record.CheckTime, record.Success, resp, record.ErrorMessage, record.ErrorCategory, record.CheckMethod, record.CheckSource, record.CreatedAt)
 	return err
 }
 
@@ -65,7 +65,7 @@ func (s *Store) LatestHealthCheck(ctx context.Context, accountID, nodeID string)
 	ctx, cancel := withTimeout(ctx)
 	defer cancel()
 
-	row := s.db.QueryRowContext(ctx, `SELECT id, account_id, node_id, check_time, success, response_time_ms, error_message, check_method, check_source, created_at
+	row := s.db.QueryRowContext(ctx, `SELECT id, account_id, node_id, check_time, success, response_time_ms, error_message, error_category, check_method, check_source, created_at
 		FROM health_check_history
 		WHERE account_id=? AND node_id=?
 		ORDER BY check_time DESC
@@ -73,7 +73,7 @@ func (s *Store) LatestHealthCheck(ctx context.Context, accountID, nodeID string)
 
 	var rec HealthCheckRecord
 	var resp sql.NullInt64
-	switch err := row.Scan(&rec.ID, &rec.AccountID, &rec.NodeID, &rec.CheckTime, &rec.Success, &resp, &rec.ErrorMessage, &rec.CheckMethod, &rec.CheckSource, &rec.CreatedAt); err {
+	switch err := row.Scan(&rec.ID, &rec.AccountID, &rec.NodeID, &rec.CheckTime, &rec.Success, &resp, &rec.ErrorMessage, &rec.ErrorCategory, &rec.CheckMethod, &rec.CheckSource, &rec.CreatedAt); err {
 	case nil:
 		if resp.Valid {
 			rec.ResponseTimeMs = int(resp.Int64)
@@ -130,9 +130,9 @@ func (s *Store) QueryHealthChecks(ctx context.Context, params QueryHealthCheckPa
 	}
 	where := strings.Join(conds, " AND ")
 
-	query := `SELECT id, account_id, node_id, check_time, success, response_time_ms, error_message, check_method, check_source, created_at
+	query := `SELECT id, account_id, node_id, check_time, success, response_time_ms, error_message, error_category, check_method, check_source, created_at
 		FROM (
-			SELECT id, account_id, node_id, check_time, success, response_time_ms, error_message, check_method, check_source, created_at
+			SELECT id, account_id, node_id, check_time, success, response_time_ms, error_message, error_category, check_method, check_source, created_at
 			FROM health_check_history
 			WHERE ` + where + `
 			ORDER BY check_time DESC
@@ -151,7 +151,7 @@ func (s *Store) QueryHealthChecks(ctx context.Context, params QueryHealthCheckPa
 	for rows.Next() {
 		var rec HealthCheckRecord
 		var resp sql.NullInt64
-		if err := rows.Scan(&rec.ID, &rec.AccountID, &rec.NodeID, &rec.CheckTime, &rec.Success, &resp, &rec.ErrorMessage, &rec.CheckMethod, &rec.CheckSource, &rec.CreatedAt); err != nil {
+		if err := rows.Scan(&rec.ID, &rec.AccountID, &rec.NodeID, &rec.CheckTime, &rec.Success, &resp, &rec.ErrorMessage, &rec.ErrorCategory, &rec.CheckMethod, &rec.CheckSource, &rec.CreatedAt); err != nil {
 			return nil, err
 		}
 		if resp.Valid {
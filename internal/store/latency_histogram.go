@@ -0,0 +1,164 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// LatencyBucketBoundsMs 延迟直方图各桶上界（毫秒，含），超出最后上界的样本计入溢出桶。
+// 桶边界固定，按列求和即可合并，聚合时无需解析原始样本。
+var LatencyBucketBoundsMs = [...]int64{50, 100, 200, 300, 500, 750, 1000, 1500, 2000, 3000, 5000, 7500, 10000, 15000, 20000, 30000, 60000, 120000}
+
+// LatencyBucketCount 桶数量（含溢出桶）。
+const LatencyBucketCount = len(LatencyBucketBoundsMs) + 1
+
+// LatencyHistogram 固定桶延迟直方图，元素为各桶样本数。
+type LatencyHistogram [LatencyBucketCount]int64
+
+// Observe 记录一个样本（毫秒）。
+func (h *LatencyHistogram) Observe(ms int64) {
+	for i, bound := range LatencyBucketBoundsMs {
+		if ms <= bound {
+			h[i]++
+			return
+		}
+	}
+	h[LatencyBucketCount-1]++
+}
+
+// Merge 合并另一个直方图。
+func (h *LatencyHistogram) Merge(o LatencyHistogram) {
+	for i := range h {
+		h[i] += o[i]
+	}
+}
+
+// Count 返回样本总数。
+func (h LatencyHistogram) Count() int64 {
+	var n int64
+	for _, c := range h {
+		n += c
+	}
+	return n
+}
+
+// Quantile 返回分位数估计值（毫秒），在命中桶内线性插值；溢出桶返回最后上界。
+func (h LatencyHistogram) Quantile(q float64) float64 {
+	total := h.Count()
+	if total == 0 {
+		return 0
+	}
+	if q < 0 {
+		q = 0
+	}
+	if q > 1 {
+		q = 1
+	}
+	rank := q * float64(total)
+	var cum int64
+	for i, c := range h {
+		if c == 0 {
+			continue
+		}
+		if float64(cum+c) >= rank {
+			if i == LatencyBucketCount-1 {
+				return float64(LatencyBucketBoundsMs[i-1])
+			}
+			var lower float64
+			if i > 0 {
+				lower = float64(LatencyBucketBoundsMs[i-1])
+			}
+			upper := float64(LatencyBucketBoundsMs[i])
+			return lower + (upper-lower)*(rank-float64(cum))/float64(c)
+		}
+		cum += c
+	}
+	return float64(LatencyBucketBoundsMs[len(LatencyBucketBoundsMs)-1])
+}
+
+// 直方图列前缀：response_time_le_50 … response_time_le_inf。
+const (
+	responseTimeHistPrefix = "response_time_le_"
+	firstByteHistPrefix    = "first_byte_le_"
+)
+
+// latencyHistColumns 返回指定前缀的直方图列名，顺序与 LatencyHistogram 下标一致。
+func latencyHistColumns(prefix string) []string {
+	cols := make([]string, 0, LatencyBucketCount)
+	for _, bound := range LatencyBucketBoundsMs {
+		cols = append(cols, prefix+strconv.FormatInt(bound, 10))
+	}
+	return append(cols, prefix+"inf")
+}
+
+// metricsHistColumns 返回响应时间与首字节直方图的全部列名。
+func metricsHistColumns() []string {
+	return append(latencyHistColumns(responseTimeHistPrefix), latencyHistColumns(firstByteHistPrefix)...)
+}
+
+// histArgs 按 metricsHistColumns 顺序展开直方图取值。
+func histArgs(rt, fb *LatencyHistogram) []interface{} {
+	args := make([]interface{}, 0, 2*LatencyBucketCount)
+	for i := range rt {
+		args = append(args, rt[i])
+	}
+	for i := range fb {
+		args = append(args, fb[i])
+	}
+	return args
+}
+
+// histDest 按 metricsHistColumns 顺序返回 Scan 目标。
+func histDest(rt, fb *LatencyHistogram) []interface{} {
+	dest := make([]interface{}, 0, 2*LatencyBucketCount)
+	for i := range rt {
+		dest = append(dest, &rt[i])
+	}
+	for i := range fb {
+		dest = append(dest, &fb[i])
+	}
+	return dest
+}
+
+// migrateMetricsHistograms 为监控表补齐直方图列。
+func (s *Store) migrateMetricsHistograms(ctx context.Context, table string) error {
+	return s.addMetricsCounterColumns(ctx, table, metricsHistColumns())
+}
+
+// addMetricsCounterColumns 为监控表补齐默认 0 的计数列，MySQL 一次 ALTER 添加全部缺失列。
+func (s *Store) addMetricsCounterColumns(ctx context.Context, table string, columns []string) error {
+	var missing []string
+	for _, col := range columns {
+		exists, err := s.columnExists(ctx, table, col)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			missing = append(missing, col)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	if s.IsSQLite() {
+		for _, col := range missing {
+			alterCtx, cancel := withTimeout(ctx)
+			_, err := s.db.ExecContext(alterCtx, fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s INTEGER DEFAULT 0`, table, col))
+			cancel()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	clauses := make([]string, 0, len(missing))
+	for _, col := range missing {
+		clauses = append(clauses, fmt.Sprintf("ADD COLUMN %s BIGINT DEFAULT 0", col))
+	}
+	alterCtx, cancel := withTimeout(ctx)
+	defer cancel()
+	_, err := s.db.ExecContext(alterCtx, fmt.Sprintf(`ALTER TABLE %s %s`, table, strings.Join(clauses, ", ")))
+	return err
+}
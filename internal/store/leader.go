@@ -0,0 +1,115 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ensureLeaderLeaseTable 创建主节点租约表（多实例共享同一数据库时用于选主）。
+func (s *Store) ensureLeaderLeaseTable(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	var stmt string
+	if s.IsSQLite() {
+		stmt = `CREATE TABLE IF NOT EXISTS leader_leases (
+			name TEXT PRIMARY KEY,
+			holder TEXT NOT NULL,
+			acquired_at DATETIME NOT NULL,
+			renewed_at DATETIME NOT NULL,
+			expires_at DATETIME NOT NULL
+		)`
+	} else {
+		stmt = `CREATE TABLE IF NOT EXISTS leader_leases (
+			name VARCHAR(64) PRIMARY KEY,
+			holder VARCHAR(255) NOT NULL,
+			acquired_at DATETIME(3) NOT NULL,
+			renewed_at DATETIME(3) NOT NULL,
+			expires_at DATETIME(3) NOT NULL
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`
+	}
+	_, err := s.db.ExecContext(ctx, stmt)
+	return err
+}
+
+// TryAcquireLease 尝试获取或续约租约：当前持有者续约，或租约已过期时抢占。
+// 返回 true 表示调用方持有租约直到 now+ttl。
+// 注意：过期判断使用各实例本地时钟，实例间时钟偏差应远小于 ttl。
+func (s *Store) TryAcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	if s == nil || s.db == nil {
+		return false, errors.New("store not initialized")
+	}
+	if name == "" || holder == "" {
+		return false, errors.New("name and holder required")
+	}
+	if ttl <= 0 {
+		return false, errors.New("ttl must be positive")
+	}
+	now := time.Now().UTC()
+	expires := now.Add(ttl)
+
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	// 续约或抢占过期租约；持有者变化时刷新 acquired_at。
+	res, err := s.db.ExecContext(ctx, `UPDATE leader_leases
+		SET acquired_at = CASE WHEN holder=? THEN acquired_at ELSE ? END,
+			holder=?, renewed_at=?, expires_at=?
+		WHERE name=? AND (holder=? OR expires_at < ?)`,
+		holder, now, holder, now, expires, name, holder, now)
+	if err != nil {
+		return false, err
+	}
+	if rows, _ := res.RowsAffected(); rows > 0 {
+		return true, nil
+	}
+
+	// 租约不存在时插入；并发插入只有一个会成功。
+	var stmt string
+	if s.IsSQLite() {
+		stmt = `INSERT OR IGNORE INTO leader_leases (name, holder, acquired_at, renewed_at, expires_at) VALUES (?,?,?,?,?)`
+	} else {
+		stmt = `INSERT IGNORE INTO leader_leases (name, holder, acquired_at, renewed_at, expires_at) VALUES (?,?,?,?,?)`
+	}
+	res, err = s.db.ExecContext(ctx, stmt, name, holder, now, now, expires)
+	if err != nil {
+		return false, err
+	}
+	rows, _ := res.RowsAffected()
+	return rows > 0, nil
+}
+
+// ReleaseLease 主动释放租约（仅当前持有者有效），便于其他实例立即接管。
+func (s *Store) ReleaseLease(ctx context.Context, name, holder string) error {
+	if s == nil || s.db == nil {
+		return errors.New("store not initialized")
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	_, err := s.db.ExecContext(ctx, `UPDATE leader_leases SET expires_at=? WHERE name=? AND holder=?`,
+		time.Unix(0, 0).UTC(), name, holder)
+	return err
+}
+
+// GetLease 返回租约当前状态；不存在时返回 ErrNotFound。
+func (s *Store) GetLease(ctx context.Context, name string) (*LeaseRecord, error) {
+	if s == nil || s.db == nil {
+		return nil, errors.New("store not initialized")
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	var rec LeaseRecord
+	err := s.db.QueryRowContext(ctx, `SELECT name, holder, acquired_at, renewed_at, expires_at FROM leader_leases WHERE name=?`, name).
+		Scan(&rec.Name, &rec.Holder, &rec.AcquiredAt, &rec.RenewedAt, &rec.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	rec.AcquiredAt = rec.AcquiredAt.UTC()
+	rec.RenewedAt = rec.RenewedAt.UTC()
+	rec.ExpiresAt = rec.ExpiresAt.UTC()
+	return &rec, nil
+}
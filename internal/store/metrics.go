@@ -2,6 +2,7 @@ package store
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"strings"
 	"time"
@@ -29,32 +30,180 @@ func (s *Store) InsertMetrics(ctx context.Context, rec MetricsRecord) error {
 	if rec.ResponseTimeCount == 0 && rec.RequestsTotal > 0 {
 		rec.ResponseTimeCount = rec.RequestsTotal
 	}
+	counterCols := append(metricsHistColumns(), metricsFailureColumns()...)
+	args := []interface{}{
+		rec.AccountID, rec.NodeID, rec.ModelID, rec.Timestamp, rec.RequestsTotal, rec.RequestsSuccess, rec.RequestsFailed,
+		rec.RetryAttemptsTotal, rec.RetrySuccess,
+		rec.ResponseTimeSumMs, rec.ResponseTimeCount, rec.BytesTotal,
+		rec.InputTokensTotal, rec.OutputTokensTotal, rec.FirstByteTimeSumMs, rec.StreamDurationSumMs,
+		rec.CacheCreationTokensTotal, rec.CacheReadTokensTotal,
+	}
+	args = append(args, histArgs(&rec.ResponseTimeHist, &rec.FirstByteHist)...)
+	args = append(args, failureArgs(&rec.Failures)...)
 	ctx, cancel := withTimeout(ctx)
 	defer cancel()
 	_, err := s.db.ExecContext(ctx, `INSERT INTO node_metrics_raw (
-		account_id, node_id, ts, requests_total, requests_success, requests_failed,
+		account_id, node_id, model_id, ts, requests_total, requests_success, requests_failed,
 		retry_attempts_total, retry_success,
 		response_time_sum_ms, response_time_count, bytes_total,
-		input_tokens_total, output_tokens_total, first_byte_time_sum_ms, stream_duration_sum_ms)
-		VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`,
-		rec.AccountID, rec.NodeID, rec.Timestamp, rec.RequestsTotal, rec.RequestsSuccess, rec.RequestsFailed,
-		rec.RetryAttemptsTotal, rec.RetrySuccess,
-		rec.ResponseTimeSumMs, rec.ResponseTimeCount, rec.BytesTotal,
-		rec.InputTokensTotal, rec.OutputTokensTotal, rec.FirstByteTimeSumMs, rec.StreamDurationSumMs)
+		input_tokens_total, output_tokens_total, first_byte_time_sum_ms, stream_duration_sum_ms,
+		cache_creation_tokens_total, cache_read_tokens_total,
+		`+strings.Join(counterCols, ", ")+`)
+		VALUES (`+strings.TrimSuffix(strings.Repeat("?,", len(args)), ",")+`)`, args...)
 	return err
 }
 
 // QueryMetrics 按时间范围和粒度获取监控数据，默认返回最近 24 小时的原始数据。
 // Granularity 支持 raw/hour/day/month，对应不同表；Timestamp 字段表示所在桶的起始时间。
 func (s *Store) QueryMetrics(ctx context.Context, q MetricsQuery) ([]MetricsRecord, error) {
+	query, args, err := metricsSelect(q, false)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []MetricsRecord
+	for rows.Next() {
+		r, err := scanMetricsRecord(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+	return res, rows.Err()
+}
+
+// QueryMetricsPage 按时间桶（byModel 时为时间桶+模型）在数据库中分页，只读取当前页涉及的记录。
+// 同一时间桶可能有多个节点、模型的记录，返回值需由调用方合并；分页键为 (ts[, model_id]) 升序。
+func (s *Store) QueryMetricsPage(ctx context.Context, q MetricsQuery, byModel bool) ([]MetricsRecord, error) {
 	gran := q.Granularity
 	if gran == "" {
 		gran = MetricsGranularityRaw
 	}
-	table, timeCol, createdCol, err := metricsTableInfo(gran)
+	table, timeCol, _, err := metricsTableInfo(gran)
 	if err != nil {
 		return nil, err
 	}
+	where, keyArgs := metricsWhere(q, gran, timeCol, false)
+	keyCols, order := timeCol, timeCol
+	if byModel {
+		keyCols, order = timeCol+", model_id", timeCol+" ASC, model_id ASC"
+	}
+	keyQuery := "SELECT DISTINCT " + keyCols + " FROM " + table + where + " ORDER BY " + order
+	if q.Limit > 0 {
+		keyQuery += " LIMIT ? OFFSET ?"
+		keyArgs = append(keyArgs, q.Limit, q.Offset)
+	}
+
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	type pageKey struct {
+		ts    time.Time
+		model string
+	}
+	keys := make(map[pageKey]struct{})
+	var first, last time.Time
+	rows, err := s.db.QueryContext(ctx, keyQuery, keyArgs...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var k pageKey
+		dest := []interface{}{&k.ts}
+		if byModel {
+			dest = append(dest, &k.model)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		k.ts = k.ts.UTC()
+		if len(keys) == 0 {
+			first = k.ts
+		}
+		last = k.ts
+		keys[k] = struct{}{}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	q.From, q.To = first, last.Add(time.Nanosecond)
+	q.Limit, q.Offset = 0, 0
+	query, args, err := metricsSelect(q, false)
+	if err != nil {
+		return nil, err
+	}
+	rows, err = s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []MetricsRecord
+	for rows.Next() {
+		r, err := scanMetricsRecord(rows)
+		if err != nil {
+			return nil, err
+		}
+		k := pageKey{ts: r.Timestamp.UTC()}
+		if byModel {
+			k.model = r.ModelID
+		}
+		if _, ok := keys[k]; ok {
+			res = append(res, r)
+		}
+	}
+	return res, rows.Err()
+}
+
+// metricsSelect 构建监控查询语句；allAccounts 为 true 且未指定账号时不按账号过滤。
+func metricsSelect(q MetricsQuery, allAccounts bool) (string, []interface{}, error) {
+	gran := q.Granularity
+	if gran == "" {
+		gran = MetricsGranularityRaw
+	}
+	table, timeCol, createdCol, err := metricsTableInfo(gran)
+	if err != nil {
+		return "", nil, err
+	}
+	limit := q.Limit
+	if q.Offset > 0 && limit == 0 {
+		limit = 500
+	}
+
+	where, args := metricsWhere(q, gran, timeCol, allAccounts)
+	b := &strings.Builder{}
+	fmt.Fprintf(b, `SELECT account_id, node_id, model_id, %s AS ts, requests_total, requests_success, requests_failed,
+		retry_attempts_total, retry_success,
+		response_time_sum_ms, response_time_count, bytes_total, input_tokens_total, output_tokens_total,
+		first_byte_time_sum_ms, stream_duration_sum_ms, cache_creation_tokens_total, cache_read_tokens_total,
+		%s, %s AS created_at
+		FROM %s`, timeCol, strings.Join(append(metricsHistColumns(), metricsFailureColumns()...), ", "), createdCol, table)
+	b.WriteString(where)
+	b.WriteString(" ORDER BY " + timeCol + " ASC")
+	if limit > 0 {
+		b.WriteString(" LIMIT ?")
+		args = append(args, limit)
+	}
+	if q.Offset > 0 {
+		b.WriteString(" OFFSET ?")
+		args = append(args, q.Offset)
+	}
+	return b.String(), args, nil
+}
+
+// metricsWhere 构建监控查询的过滤条件，未指定时间窗口时按粒度使用默认窗口。
+func metricsWhere(q MetricsQuery, gran MetricsGranularity, timeCol string, allAccounts bool) (string, []interface{}) {
 	if q.To.IsZero() {
 		q.To = time.Now().UTC()
 	}
@@ -71,61 +220,44 @@ func (s *Store) QueryMetrics(ctx context.Context, q MetricsQuery) ([]MetricsReco
 			q.From = q.To.AddDate(-1, 0, 0)
 		}
 	}
-	limit := q.Limit
-	if q.Offset > 0 && limit == 0 {
-		limit = 500
-	}
 
-	q.AccountID = normalizeAccount(q.AccountID)
 	var args []interface{}
 	b := &strings.Builder{}
-	fmt.Fprintf(b, `SELECT account_id, node_id, %s AS ts, requests_total, requests_success, requests_failed,
-		retry_attempts_total, retry_success,
-		response_time_sum_ms, response_time_count, bytes_total, input_tokens_total, output_tokens_total,
-		first_byte_time_sum_ms, stream_duration_sum_ms, %s AS created_at
-		FROM %s WHERE account_id=?`, timeCol, createdCol, table)
-	args = append(args, q.AccountID)
+	b.WriteString(" WHERE 1=1")
+	if !allAccounts || q.AccountID != "" {
+		b.WriteString(" AND account_id=?")
+		args = append(args, normalizeAccount(q.AccountID))
+	}
 	if q.NodeID != "" {
 		b.WriteString(" AND node_id=?")
 		args = append(args, q.NodeID)
 	}
-	if !q.From.IsZero() {
-		fmt.Fprintf(b, " AND %s >= ?", timeCol)
-		args = append(args, q.From.UTC())
-	}
-	if !q.To.IsZero() {
-		fmt.Fprintf(b, " AND %s < ?", timeCol)
-		args = append(args, q.To.UTC())
-	}
-	b.WriteString(" ORDER BY " + timeCol + " ASC")
-	if limit > 0 {
-		b.WriteString(" LIMIT ?")
-		args = append(args, limit)
-	}
-	if q.Offset > 0 {
-		b.WriteString(" OFFSET ?")
-		args = append(args, q.Offset)
+	if q.ModelID != "" {
+		b.WriteString(" AND model_id=?")
+		args = append(args, q.ModelID)
 	}
+	fmt.Fprintf(b, " AND %s >= ? AND %s < ?", timeCol, timeCol)
+	args = append(args, q.From.UTC(), q.To.UTC())
+	return b.String(), args
+}
 
-	ctx, cancel := withTimeout(ctx)
-	defer cancel()
-	rows, err := s.db.QueryContext(ctx, b.String(), args...)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var res []MetricsRecord
-	for rows.Next() {
-		var r MetricsRecord
-		if err := rows.Scan(&r.AccountID, &r.NodeID, &r.Timestamp, &r.RequestsTotal, &r.RequestsSuccess, &r.RequestsFailed,
-			&r.RetryAttemptsTotal, &r.RetrySuccess,
-			&r.ResponseTimeSumMs, &r.ResponseTimeCount, &r.BytesTotal, &r.InputTokensTotal, &r.OutputTokensTotal,
-			&r.FirstByteTimeSumMs, &r.StreamDurationSumMs, &r.CreatedAt); err != nil {
-			return nil, err
-		}
-		res = append(res, r)
-	}
-	return res, rows.Err()
+// scanMetricsRecord 按 metricsSelect 的列顺序读取一行。
+func scanMetricsRecord(rows *sql.Rows) (MetricsRecord, error) {
+	var r MetricsRecord
+	dest := []interface{}{&r.AccountID, &r.NodeID, &r.ModelID, &r.Timestamp, &r.RequestsTotal, &r.RequestsSuccess, &r.RequestsFailed,
+		&r.RetryAttemptsTotal, &r.RetrySuccess,
+		&r.ResponseTimeSumMs, &r.ResponseTimeCount, &r.BytesTotal, &r.InputTokensTotal, &r.OutputTokensTotal,
+		&r.FirstByteTimeSumMs, &r.StreamDurationSumMs, &r.CacheCreationTokensTotal, &r.CacheReadTokensTotal}
+	dest = append(dest, histDest(&r.ResponseTimeHist, &r.FirstByteHist)...)
+	dest = append(dest, failureDest(&r.Failures)...)
+	// 聚合表无 created_at，查询结果为 NULL。
+	var createdAt sql.NullTime
+	dest = append(dest, &createdAt)
+	if err := rows.Scan(dest...); err != nil {
+		return r, err
+	}
+	r.CreatedAt = createdAt.Time
+	return r, nil
 }
 
 // GetNode24hTrend 获取指定节点最近 24 小时的小时级聚合数据，按时间升序返回。
@@ -139,10 +271,11 @@ func (s *Store) GetNode24hTrend(ctx context.Context, accountID, nodeID string) (
 
 	// 1. 查询已聚合的小时数据（不包含当前小时）
 	hourlyQuery := `
-        SELECT bucket_start, requests_total, requests_success, requests_failed,
-               response_time_sum_ms, response_time_count
+        SELECT bucket_start, SUM(requests_total), SUM(requests_success), SUM(requests_failed),
+               SUM(response_time_sum_ms), SUM(response_time_count)
         FROM node_metrics_hourly
         WHERE account_id = ? AND node_id = ? AND bucket_start >= ? AND bucket_start < ?
+        GROUP BY bucket_start
         ORDER BY bucket_start ASC
     `
 
@@ -208,10 +341,11 @@ func (s *Store) GetNodes24hTrend(ctx context.Context, accountID string, nodeIDs
 
 	// 1. 查询已聚合的小时数据（不包含当前小时）
 	hourlyQuery := fmt.Sprintf(`
-        SELECT node_id, bucket_start, requests_total, requests_success, requests_failed,
-               response_time_sum_ms, response_time_count
+        SELECT node_id, bucket_start, SUM(requests_total), SUM(requests_success), SUM(requests_failed),
+               SUM(response_time_sum_ms), SUM(response_time_count)
         FROM node_metrics_hourly
         WHERE account_id = ? AND node_id IN (%s) AND bucket_start >= ? AND bucket_start < ?
+        GROUP BY node_id, bucket_start
         ORDER BY node_id ASC, bucket_start ASC
     `, placeholders)
 
@@ -291,7 +425,7 @@ func (s *Store) GetNodes24hTrend(ctx context.Context, accountID string, nodeIDs
 	return result, nil
 }
 
-// AggregateMetrics 将低粒度数据聚合到更高粒度。
+// AggregateMetrics 将低粒度数据按账号、节点、模型聚合到更高粒度。
 // target 取值：hour(原始->小时)、day(小时->天)、month(天->月)。
 func (s *Store) AggregateMetrics(ctx context.Context, accountID string, target MetricsGranularity, from, to time.Time) error {
 	srcTable, srcTimeCol, dstTable, bucketExpr, err := s.aggregationPlan(target)
@@ -311,40 +445,57 @@ func (s *Store) AggregateMetrics(ctx context.Context, accountID string, target M
 			from = to.AddDate(0, -1, 0)
 		}
 	}
+	// 直方图桶边界固定，逐列求和即为合并后的直方图；失败拆分计数同样逐列求和。
+	counterCols := append(metricsHistColumns(), metricsFailureColumns()...)
+	counterSums := make([]string, len(counterCols))
+	for i, col := range counterCols {
+		counterSums[i] = "SUM(" + col + ")"
+	}
 	var args []interface{}
 	b := &strings.Builder{}
 	fmt.Fprintf(b, `INSERT INTO %s (
-		account_id, node_id, bucket_start, requests_total, requests_success, requests_failed,
+		account_id, node_id, model_id, bucket_start, requests_total, requests_success, requests_failed,
 		retry_attempts_total, retry_success,
 		response_time_sum_ms, response_time_count, bytes_total, input_tokens_total, output_tokens_total,
-		first_byte_time_sum_ms, stream_duration_sum_ms)
-		SELECT account_id, node_id, %s AS bucket_start,
+		first_byte_time_sum_ms, stream_duration_sum_ms, cache_creation_tokens_total, cache_read_tokens_total, %s)
+		SELECT account_id, node_id, model_id, %s AS bucket_start,
 			SUM(requests_total), SUM(requests_success), SUM(requests_failed),
 			SUM(retry_attempts_total), SUM(retry_success),
 			SUM(response_time_sum_ms), SUM(response_time_count), SUM(bytes_total),
-			SUM(input_tokens_total), SUM(output_tokens_total), SUM(first_byte_time_sum_ms), SUM(stream_duration_sum_ms)
-		FROM %s WHERE %s >= ? AND %s < ?`, dstTable, bucketExpr, srcTable, srcTimeCol, srcTimeCol)
+			SUM(input_tokens_total), SUM(output_tokens_total), SUM(first_byte_time_sum_ms), SUM(stream_duration_sum_ms),
+			SUM(cache_creation_tokens_total), SUM(cache_read_tokens_total),
+			%s
+		FROM %s WHERE %s >= ? AND %s < ?`, dstTable, strings.Join(counterCols, ", "), bucketExpr, strings.Join(counterSums, ", "), srcTable, srcTimeCol, srcTimeCol)
 	args = append(args, from.UTC(), to.UTC())
 	if accountID != "" {
 		accountID = normalizeAccount(accountID)
 		b.WriteString(" AND account_id=?")
 		args = append(args, accountID)
 	}
-	b.WriteString(" GROUP BY account_id, node_id, bucket_start")
+	// 按桶表达式分组：源表（小时/天）自身也有 bucket_start 列，直接写别名会按源列分组导致覆盖而非累加。
+	fmt.Fprintf(b, " GROUP BY account_id, node_id, model_id, %s", bucketExpr)
 	if s.IsSQLite() {
-		b.WriteString(" ON CONFLICT(account_id, node_id, bucket_start) DO UPDATE SET ")
+		b.WriteString(" ON CONFLICT(account_id, node_id, model_id, bucket_start) DO UPDATE SET ")
 		b.WriteString("requests_total=excluded.requests_total, requests_success=excluded.requests_success, requests_failed=excluded.requests_failed, ")
 		b.WriteString("retry_attempts_total=excluded.retry_attempts_total, retry_success=excluded.retry_success, ")
 		b.WriteString("response_time_sum_ms=excluded.response_time_sum_ms, response_time_count=excluded.response_time_count, ")
 		b.WriteString("bytes_total=excluded.bytes_total, input_tokens_total=excluded.input_tokens_total, output_tokens_total=excluded.output_tokens_total, ")
-		b.WriteString("first_byte_time_sum_ms=excluded.first_byte_time_sum_ms, stream_duration_sum_ms=excluded.stream_duration_sum_ms")
+		b.WriteString("first_byte_time_sum_ms=excluded.first_byte_time_sum_ms, stream_duration_sum_ms=excluded.stream_duration_sum_ms, ")
+		b.WriteString("cache_creation_tokens_total=excluded.cache_creation_tokens_total, cache_read_tokens_total=excluded.cache_read_tokens_total")
+		for _, col := range counterCols {
+			fmt.Fprintf(b, ", %s=excluded.%s", col, col)
+		}
 	} else {
 		b.WriteString(" ON DUPLICATE KEY UPDATE ")
 		b.WriteString("requests_total=VALUES(requests_total), requests_success=VALUES(requests_success), requests_failed=VALUES(requests_failed), ")
 		b.WriteString("retry_attempts_total=VALUES(retry_attempts_total), retry_success=VALUES(retry_success), ")
 		b.WriteString("response_time_sum_ms=VALUES(response_time_sum_ms), response_time_count=VALUES(response_time_count), ")
 		b.WriteString("bytes_total=VALUES(bytes_total), input_tokens_total=VALUES(input_tokens_total), output_tokens_total=VALUES(output_tokens_total), ")
-		b.WriteString("first_byte_time_sum_ms=VALUES(first_byte_time_sum_ms), stream_duration_sum_ms=VALUES(stream_duration_sum_ms)")
+		b.WriteString("first_byte_time_sum_ms=VALUES(first_byte_time_sum_ms), stream_duration_sum_ms=VALUES(stream_duration_sum_ms), ")
+		b.WriteString("cache_creation_tokens_total=VALUES(cache_creation_tokens_total), cache_read_tokens_total=VALUES(cache_read_tokens_total)")
+		for _, col := range counterCols {
+			fmt.Fprintf(b, ", %s=VALUES(%s)", col, col)
+		}
 	}
 
 	ctx, cancel := withTimeout(ctx)
@@ -408,21 +559,23 @@ func metricsTableInfo(gr MetricsGranularity) (table, timeCol, createdCol string,
 }
 
 // aggregationPlan 定义从低粒度到目标粒度的聚合路径。
+// SQLite 驱动以 "2006-01-02 15:04:05.999999999 -0700 MST" 文本保存时间，strftime 无法直接解析，
+// 先截取前 19 位再计算桶，并补回 UTC 后缀，使桶时间与驱动写入的参数格式一致，范围比较才正确。
 func (s *Store) aggregationPlan(target MetricsGranularity) (srcTable, srcTimeCol, dstTable, bucketExpr string, err error) {
 	switch target {
 	case MetricsGranularityHourly:
 		if s.IsSQLite() {
-			return "node_metrics_raw", "ts", "node_metrics_hourly", "strftime('%Y-%m-%d %H:00:00', ts)", nil
+			return "node_metrics_raw", "ts", "node_metrics_hourly", "strftime('%Y-%m-%d %H:00:00', substr(ts, 1, 19)) || ' +0000 UTC'", nil
 		}
 		return "node_metrics_raw", "ts", "node_metrics_hourly", "DATE_FORMAT(ts, '%Y-%m-%d %H:00:00')", nil
 	case MetricsGranularityDaily:
 		if s.IsSQLite() {
-			return "node_metrics_hourly", "bucket_start", "node_metrics_daily", "date(bucket_start)", nil
+			return "node_metrics_hourly", "bucket_start", "node_metrics_daily", "date(substr(bucket_start, 1, 10)) || ' 00:00:00 +0000 UTC'", nil
 		}
 		return "node_metrics_hourly", "bucket_start", "node_metrics_daily", "DATE(bucket_start)", nil
 	case MetricsGranularityMonthly:
 		if s.IsSQLite() {
-			return "node_metrics_daily", "bucket_start", "node_metrics_monthly", "strftime('%Y-%m-01 00:00:00', bucket_start)", nil
+			return "node_metrics_daily", "bucket_start", "node_metrics_monthly", "strftime('%Y-%m-01 00:00:00', substr(bucket_start, 1, 19)) || ' +0000 UTC'", nil
 		}
 		return "node_metrics_daily", "bucket_start", "node_metrics_monthly", "DATE_FORMAT(bucket_start, '%Y-%m-01 00:00:00')", nil
 	default:
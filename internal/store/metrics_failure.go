@@ -0,0 +1,105 @@
+package store
+
+// FailureBreakdown 失败请求按状态码类别与上游错误分类拆分的计数。
+// 客户端断开（499）计入 Canceled，不计入 RequestsFailed 及下列任何失败计数。
+type FailureBreakdown struct {
+	Canceled   int64
+	Status4xx  int64
+	Status5xx  int64
+	Auth       int64
+	Billing    int64
+	RateLimit  int64
+	Overloaded int64
+	Client     int64
+	Server     int64
+	Network    int64
+	Timeout    int64
+	Unknown    int64
+}
+
+// failureCategoryColumns 错误分类与列名的对应关系，分类取值与代理的 ErrorClassifier 一致。
+var failureCategoryColumns = []struct {
+	category string
+	column   string
+}{
+	{"auth", "errors_auth"},
+	{"billing", "errors_billing"},
+	{"rate_limit", "errors_rate_limit"},
+	{"overloaded", "errors_overloaded"},
+	{"client", "errors_client"},
+	{"server", "errors_server"},
+	{"network", "errors_network"},
+	{"timeout", "errors_timeout"},
+	{"unknown", "errors_unknown"},
+}
+
+// metricsFailureColumns 返回失败拆分的全部列名，顺序与 failureFields 一致。
+func metricsFailureColumns() []string {
+	cols := []string{"requests_canceled", "failed_4xx", "failed_5xx"}
+	for _, c := range failureCategoryColumns {
+		cols = append(cols, c.column)
+	}
+	return cols
+}
+
+func (b *FailureBreakdown) fields() []*int64 {
+	return []*int64{&b.Canceled, &b.Status4xx, &b.Status5xx,
+		&b.Auth, &b.Billing, &b.RateLimit, &b.Overloaded, &b.Client, &b.Server, &b.Network, &b.Timeout, &b.Unknown}
+}
+
+// failureArgs 按 metricsFailureColumns 顺序展开取值。
+func failureArgs(b *FailureBreakdown) []interface{} {
+	fields := b.fields()
+	args := make([]interface{}, len(fields))
+	for i, f := range fields {
+		args[i] = *f
+	}
+	return args
+}
+
+// failureDest 按 metricsFailureColumns 顺序返回 Scan 目标。
+func failureDest(b *FailureBreakdown) []interface{} {
+	fields := b.fields()
+	dest := make([]interface{}, len(fields))
+	for i, f := range fields {
+		dest[i] = f
+	}
+	return dest
+}
+
+// Add 累加另一组计数。
+func (b *FailureBreakdown) Add(o FailureBreakdown) {
+	dst, src := b.fields(), o.fields()
+	for i := range dst {
+		*dst[i] += *src[i]
+	}
+}
+
+// RecordFailure 按状态码与错误分类记一次失败；分类为空或未知时计入 unknown。
+func (b *FailureBreakdown) RecordFailure(status int, category string) {
+	switch {
+	case status >= 500:
+		b.Status5xx++
+	case status >= 400:
+		b.Status4xx++
+	}
+	for i, c := range failureCategoryColumns {
+		if c.category == category {
+			*b.fields()[3+i]++
+			return
+		}
+	}
+	b.Unknown++
+}
+
+// ByCategory 返回非零的错误分类计数。
+func (b FailureBreakdown) ByCategory() map[string]int64 {
+	out := make(map[string]int64)
+	fields := b.fields()
+	for i, c := range failureCategoryColumns {
+		if v := *fields[3+i]; v > 0 {
+			out[c.category] = v
+		}
+	}
+	return out
+}
@@ -0,0 +1,116 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// metricsRollupIndexes 聚合表的时间索引，SQLite 重建表后需重新创建。
+var metricsRollupIndexes = map[string]string{
+	"node_metrics_hourly":  "idx_metrics_hour_time",
+	"node_metrics_daily":   "idx_metrics_day_time",
+	"node_metrics_monthly": "idx_metrics_month_time",
+}
+
+// migrateMetricsModel 为监控表补齐 model_id 维度，并把聚合表主键扩展为 (account_id, node_id, model_id, bucket_start)。
+// 历史数据的 model_id 为空字符串，表示未区分模型。
+func (s *Store) migrateMetricsModel(ctx context.Context) error {
+	exists, err := s.columnExists(ctx, "node_metrics_raw", "model_id")
+	if err != nil {
+		return err
+	}
+	if !exists {
+		def := "VARCHAR(128) NOT NULL DEFAULT '' AFTER node_id"
+		if s.IsSQLite() {
+			def = "TEXT NOT NULL DEFAULT ''"
+		}
+		alterCtx, cancel := withTimeout(ctx)
+		_, err := s.db.ExecContext(alterCtx, "ALTER TABLE node_metrics_raw ADD COLUMN model_id "+def)
+		cancel()
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, tbl := range []string{"node_metrics_hourly", "node_metrics_daily", "node_metrics_monthly"} {
+		exists, err := s.columnExists(ctx, tbl, "model_id")
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		if s.IsSQLite() {
+			err = s.rebuildSQLiteRollupWithModel(ctx, tbl)
+		} else {
+			alterCtx, cancel := withTimeout(ctx)
+			_, err = s.db.ExecContext(alterCtx, fmt.Sprintf(`ALTER TABLE %s ADD COLUMN model_id VARCHAR(128) NOT NULL DEFAULT '' AFTER node_id,
+				DROP PRIMARY KEY, ADD PRIMARY KEY (account_id, node_id, model_id, bucket_start)`, tbl))
+			cancel()
+		}
+		if err != nil {
+			return fmt.Errorf("migrate %s model_id: %w", tbl, err)
+		}
+	}
+	return nil
+}
+
+// rebuildSQLiteRollupWithModel SQLite 无法修改主键，按现有列定义重建表并拷贝数据。
+func (s *Store) rebuildSQLiteRollupWithModel(ctx context.Context, table string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf(`PRAGMA table_info(%s)`, table))
+	if err != nil {
+		return err
+	}
+	var cols, defs []string
+	for rows.Next() {
+		var cid, notnull, pk int
+		var name, ctype string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &ctype, &notnull, &dflt, &pk); err != nil {
+			rows.Close()
+			return err
+		}
+		def := name + " " + ctype
+		if notnull == 1 {
+			def += " NOT NULL"
+		}
+		if dflt.Valid {
+			def += " DEFAULT " + dflt.String
+		}
+		cols = append(cols, name)
+		defs = append(defs, def)
+		if name == "node_id" {
+			defs = append(defs, "model_id TEXT NOT NULL DEFAULT ''")
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	tmp := table + "_model_tmp"
+	colList := strings.Join(cols, ", ")
+	stmts := []string{
+		fmt.Sprintf(`CREATE TABLE %s (%s, PRIMARY KEY (account_id, node_id, model_id, bucket_start))`, tmp, strings.Join(defs, ", ")),
+		fmt.Sprintf(`INSERT INTO %s (%s) SELECT %s FROM %s`, tmp, colList, colList, table),
+		fmt.Sprintf(`DROP TABLE %s`, table),
+		fmt.Sprintf(`ALTER TABLE %s RENAME TO %s`, tmp, table),
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s(bucket_start)`, metricsRollupIndexes[table], table),
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -290,6 +290,7 @@ func (s *Store) ensureHealthHistoryTable(ctx context.Context) error {
 		  success INTEGER NOT NULL,
 		  response_time_ms INTEGER,
 		  error_message TEXT,
+		  error_category TEXT NOT NULL DEFAULT '',
 		  check_method TEXT NOT NULL,
 		  check_source TEXT NOT NULL DEFAULT 'scheduled',
 		  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
@@ -303,6 +304,7 @@ func (s *Store) ensureHealthHistoryTable(ctx context.Context) error {
 		  success BOOLEAN NOT NULL,
 		  response_time_ms INT,
 		  error_message TEXT,
+		  error_category VARCHAR(32) NOT NULL DEFAULT '',
 		  check_method VARCHAR(20) NOT NULL,
 		  check_source VARCHAR(20) NOT NULL DEFAULT 'scheduled',
 		  created_at DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
@@ -340,6 +342,24 @@ func (s *Store) ensureHealthHistoryTable(ctx context.Context) error {
 		}
 	}
 
+	hasErrorCategory, err := s.columnExists(context.Background(), "health_check_history", "error_category")
+	if err != nil {
+		return err
+	}
+	if !hasErrorCategory {
+		alterCtx, cancel := withTimeout(context.Background())
+		defer cancel()
+		var alterStmt string
+		if s.IsSQLite() {
+			alterStmt = `ALTER TABLE health_check_history ADD COLUMN error_category TEXT NOT NULL DEFAULT ''`
+		} else {
+			alterStmt = `ALTER TABLE health_check_history ADD COLUMN error_category VARCHAR(32) NOT NULL DEFAULT '' AFTER error_message`
+		}
+		if _, err := s.db.ExecContext(alterCtx, alterStmt); err != nil {
+			return err
+		}
+	}
+
 	if !s.IsSQLite() {
 		hasIndex, err := s.indexExists(context.Background(), "health_check_history", "idx_account_node_source_time")
 		if err != nil {
@@ -417,6 +437,7 @@ func (s *Store) ensureMetricsTables(ctx context.Context) error {
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			account_id TEXT NOT NULL,
 			node_id TEXT NOT NULL,
+			model_id TEXT NOT NULL DEFAULT '',
 			ts DATETIME NOT NULL,
 			requests_total INTEGER DEFAULT 0,
 			requests_success INTEGER DEFAULT 0,
@@ -436,6 +457,7 @@ func (s *Store) ensureMetricsTables(ctx context.Context) error {
 		createHourly = `CREATE TABLE IF NOT EXISTS node_metrics_hourly (
 			account_id TEXT NOT NULL,
 			node_id TEXT NOT NULL,
+			model_id TEXT NOT NULL DEFAULT '',
 			bucket_start DATETIME NOT NULL,
 			requests_total INTEGER DEFAULT 0,
 			requests_success INTEGER DEFAULT 0,
@@ -449,12 +471,13 @@ func (s *Store) ensureMetricsTables(ctx context.Context) error {
 			output_tokens_total INTEGER DEFAULT 0,
 			first_byte_time_sum_ms INTEGER DEFAULT 0,
 			stream_duration_sum_ms INTEGER DEFAULT 0,
-			PRIMARY KEY (account_id, node_id, bucket_start)
+			PRIMARY KEY (account_id, node_id, model_id, bucket_start)
 		)`
 
 		createDaily = `CREATE TABLE IF NOT EXISTS node_metrics_daily (
 			account_id TEXT NOT NULL,
 			node_id TEXT NOT NULL,
+			model_id TEXT NOT NULL DEFAULT '',
 			bucket_start DATETIME NOT NULL,
 			requests_total INTEGER DEFAULT 0,
 			requests_success INTEGER DEFAULT 0,
@@ -468,12 +491,13 @@ func (s *Store) ensureMetricsTables(ctx context.Context) error {
 			output_tokens_total INTEGER DEFAULT 0,
 			first_byte_time_sum_ms INTEGER DEFAULT 0,
 			stream_duration_sum_ms INTEGER DEFAULT 0,
-			PRIMARY KEY (account_id, node_id, bucket_start)
+			PRIMARY KEY (account_id, node_id, model_id, bucket_start)
 		)`
 
 		createMonthly = `CREATE TABLE IF NOT EXISTS node_metrics_monthly (
 			account_id TEXT NOT NULL,
 			node_id TEXT NOT NULL,
+			model_id TEXT NOT NULL DEFAULT '',
 			bucket_start DATETIME NOT NULL,
 			requests_total INTEGER DEFAULT 0,
 			requests_success INTEGER DEFAULT 0,
@@ -487,13 +511,14 @@ func (s *Store) ensureMetricsTables(ctx context.Context) error {
 			output_tokens_total INTEGER DEFAULT 0,
 			first_byte_time_sum_ms INTEGER DEFAULT 0,
 			stream_duration_sum_ms INTEGER DEFAULT 0,
-			PRIMARY KEY (account_id, node_id, bucket_start)
+			PRIMARY KEY (account_id, node_id, model_id, bucket_start)
 		)`
 	} else {
 		createRaw = `CREATE TABLE IF NOT EXISTS node_metrics_raw (
 			id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
 			account_id VARCHAR(64) NOT NULL,
 			node_id VARCHAR(64) NOT NULL,
+			model_id VARCHAR(128) NOT NULL DEFAULT '',
 			ts DATETIME NOT NULL,
 			requests_total BIGINT DEFAULT 0,
 			requests_success BIGINT DEFAULT 0,
@@ -515,6 +540,7 @@ func (s *Store) ensureMetricsTables(ctx context.Context) error {
 		createHourly = `CREATE TABLE IF NOT EXISTS node_metrics_hourly (
 			account_id VARCHAR(64) NOT NULL,
 			node_id VARCHAR(64) NOT NULL,
+			model_id VARCHAR(128) NOT NULL DEFAULT '',
 			bucket_start DATETIME NOT NULL,
 			requests_total BIGINT DEFAULT 0,
 			requests_success BIGINT DEFAULT 0,
@@ -528,13 +554,14 @@ func (s *Store) ensureMetricsTables(ctx context.Context) error {
 			output_tokens_total BIGINT DEFAULT 0,
 			first_byte_time_sum_ms BIGINT DEFAULT 0,
 			stream_duration_sum_ms BIGINT DEFAULT 0,
-			PRIMARY KEY (account_id, node_id, bucket_start),
+			PRIMARY KEY (account_id, node_id, model_id, bucket_start),
 			KEY idx_metrics_hour_time (bucket_start)
 		)`
 
 		createDaily = `CREATE TABLE IF NOT EXISTS node_metrics_daily (
 			account_id VARCHAR(64) NOT NULL,
 			node_id VARCHAR(64) NOT NULL,
+			model_id VARCHAR(128) NOT NULL DEFAULT '',
 			bucket_start DATETIME NOT NULL,
 			requests_total BIGINT DEFAULT 0,
 			requests_success BIGINT DEFAULT 0,
@@ -548,13 +575,14 @@ func (s *Store) ensureMetricsTables(ctx context.Context) error {
 			output_tokens_total BIGINT DEFAULT 0,
 			first_byte_time_sum_ms BIGINT DEFAULT 0,
 			stream_duration_sum_ms BIGINT DEFAULT 0,
-			PRIMARY KEY (account_id, node_id, bucket_start),
+			PRIMARY KEY (account_id, node_id, model_id, bucket_start),
 			KEY idx_metrics_day_time (bucket_start)
 		)`
 
 		createMonthly = `CREATE TABLE IF NOT EXISTS node_metrics_monthly (
 			account_id VARCHAR(64) NOT NULL,
 			node_id VARCHAR(64) NOT NULL,
+			model_id VARCHAR(128) NOT NULL DEFAULT '',
 			bucket_start DATETIME NOT NULL,
 			requests_total BIGINT DEFAULT 0,
 			requests_success BIGINT DEFAULT 0,
@@ -568,7 +596,7 @@ func (s *Store) ensureMetricsTables(ctx context.Context) error {
 			output_tokens_total BIGINT DEFAULT 0,
 			first_byte_time_sum_ms BIGINT DEFAULT 0,
 			stream_duration_sum_ms BIGINT DEFAULT 0,
-			PRIMARY KEY (account_id, node_id, bucket_start),
+			PRIMARY KEY (account_id, node_id, model_id, bucket_start),
 			KEY idx_metrics_month_time (bucket_start)
 		)`
 	}
@@ -629,6 +657,13 @@ func (s *Store) ensureMetricsTables(ctx context.Context) error {
 			}
 			cancel()
 		}
+
+		if err := s.migrateMetricsHistograms(context.Background(), tbl); err != nil {
+			return err
+		}
+		if err := s.addMetricsCounterColumns(context.Background(), tbl, metricsFailureColumns()); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -39,9 +39,9 @@ func (s *Store) CreateMonitorShare(ctx context.Context, rec MonitorShareRecord)
 	}
 	ctx, cancel := withTimeout(ctx)
 	defer cancel()
-	_, err := s.db.ExecContext(ctx, `INSERT INTO monitor_shares (id,account_id,token,expire_at,created_by,created_at,revoked,revoked_at)
-		VALUES (?,?,?,?,?,?,?,?)`,
-		rec.ID, rec.AccountID, rec.Token, expire, rec.CreatedBy, rec.CreatedAt, rec.Revoked, revokedAt)
+	_, err := s.db.ExecContext(ctx, `INSERT INTO monitor_shares (id,account_id,token,expire_at,created_by,created_at,revoked,revoked_at,include_uptime)
+		VALUES (?,?,?,?,?,?,?,?,?)`,
+		rec.ID, rec.AccountID, rec.Token, expire, rec.CreatedBy, rec.CreatedAt, rec.Revoked, revokedAt, rec.IncludeUptime)
 	return err
 }
 
@@ -58,10 +58,10 @@ func (s *Store) GetMonitorShareByToken(ctx context.Context, token string) (*Moni
 		revokedAt sql.NullTime
 	)
 	// 使用 Go 时间比较替代 UTC_TIMESTAMP()，兼容 MySQL 和 SQLite
-	err := s.db.QueryRowContext(ctx, `SELECT id,account_id,token,expire_at,created_by,created_at,revoked,revoked_at
+	err := s.db.QueryRowContext(ctx, `SELECT id,account_id,token,expire_at,created_by,created_at,revoked,revoked_at,include_uptime
 		FROM monitor_shares
 		WHERE token=? AND revoked=FALSE AND (expire_at IS NULL OR expire_at>?)`,
-		token, time.Now().UTC()).Scan(&rec.ID, &rec.AccountID, &rec.Token, &expire, &rec.CreatedBy, &rec.CreatedAt, &rec.Revoked, &revokedAt)
+		token, time.Now().UTC()).Scan(&rec.ID, &rec.AccountID, &rec.Token, &expire, &rec.CreatedBy, &rec.CreatedAt, &rec.Revoked, &revokedAt, &rec.IncludeUptime)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
@@ -94,9 +94,9 @@ func (s *Store) GetMonitorShareByID(ctx context.Context, id string) (*MonitorSha
 		expire    sql.NullTime
 		revokedAt sql.NullTime
 	)
-	err := s.db.QueryRowContext(ctx, `SELECT id,account_id,token,expire_at,created_by,created_at,revoked,revoked_at
+	err := s.db.QueryRowContext(ctx, `SELECT id,account_id,token,expire_at,created_by,created_at,revoked,revoked_at,include_uptime
 		FROM monitor_shares WHERE id=?`, id).
-		Scan(&rec.ID, &rec.AccountID, &rec.Token, &expire, &rec.CreatedBy, &rec.CreatedAt, &rec.Revoked, &revokedAt)
+		Scan(&rec.ID, &rec.AccountID, &rec.Token, &expire, &rec.CreatedBy, &rec.CreatedAt, &rec.Revoked, &revokedAt, &rec.IncludeUptime)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNotFound
@@ -119,7 +119,7 @@ func (s *Store) GetMonitorShareByID(ctx context.Context, id string) (*MonitorSha
 func (s *Store) ListMonitorShares(ctx context.Context, params QueryMonitorSharesParams) ([]MonitorShareRecord, error) {
 	ctx, cancel := withTimeout(ctx)
 	defer cancel()
-	query := `SELECT id,account_id,token,expire_at,created_by,created_at,revoked,revoked_at FROM monitor_shares`
+	query := `SELECT id,account_id,token,expire_at,created_by,created_at,revoked,revoked_at,include_uptime FROM monitor_shares`
 	conds := make([]string, 0, 2)
 	args := make([]interface{}, 0, 4)
 	if params.AccountID != "" {
@@ -164,7 +164,7 @@ func (s *Store) ListMonitorShares(ctx context.Context, params QueryMonitorShares
 			expire    sql.NullTime
 			revokedAt sql.NullTime
 		)
-		if err := rows.Scan(&rec.ID, &rec.AccountID, &rec.Token, &expire, &rec.CreatedBy, &rec.CreatedAt, &rec.Revoked, &revokedAt); err != nil {
+		if err := rows.Scan(&rec.ID, &rec.AccountID, &rec.Token, &expire, &rec.CreatedBy, &rec.CreatedAt, &rec.Revoked, &revokedAt, &rec.IncludeUptime); err != nil {
 			return nil, err
 		}
 		if expire.Valid {
@@ -87,6 +87,11 @@ func (s *Store) SeedDefaultSettings() error {
 		{Key: "health.fail_threshold", Scope: "system", Value: 3, DataType: "number", Category: "health", Description: strPtr("失败阈值")},
 		{Key: "health.skip_disabled_nodes", Scope: "system", Value: true, DataType: "boolean", Category: "health", Description: strPtr("禁用节点不进行健康检查")},
 		{Key: "proxy.retry_max", Scope: "system", Value: 3, DataType: "number", Category: "performance", Description: strPtr("最大重试次数")},
+		{Key: "proxy.auth_error_disable", Scope: "system", Value: true, DataType: "boolean", Category: "performance", Description: strPtr("上游认证失败（401/403）时自动禁用节点")},
+		{Key: "proxy.error_patterns", Scope: "system", Value: []map[string]string{}, DataType: "array", Category: "performance", Description: strPtr("上游错误体匹配规则（[{\"pattern\":\"余额不足\",\"category\":\"billing\"}]），用于识别中转站自定义错误")},
+		{Key: "tracing.sample_ratio", Scope: "system", Value: 0.1, DataType: "number", Category: "performance", Description: strPtr("链路追踪根 Span 采样比例（0~1），需配置 OTEL_EXPORTER_OTLP_ENDPOINT")},
+		{Key: "request_log.retention_days", Scope: "system", Value: 7, DataType: "number", Category: "performance", Description: strPtr("请求审计日志保留天数，0 表示不清理")},
+		{Key: "logging.levels", Scope: "system", Value: map[string]string{}, DataType: "object", Category: "performance", Description: strPtr("按组件设置日志级别（{\"default\":\"info\",\"health\":\"debug\"}），组件：proxy/health/metrics/node/breaker/notify/api/leader/cluster/tracing")},
 	}
 
 	for _, d := range defaults {
@@ -27,8 +27,8 @@ func (s *Store) UpsertNode(ctx context.Context, r NodeRecord) error {
 
 	var err error
 	if s.IsSQLite() {
-		_, err = s.db.ExecContext(ctx, `INSERT INTO nodes (id,name,base_url,api_key,health_check_method,health_check_model,account_id,weight,failed,disabled,last_error,created_at,requests,fail_count,fail_streak,total_bytes,total_input,total_output,stream_dur_ms,first_byte_ms,last_ping_ms,last_ping_err,last_health_check_at)
-			VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)
+		_, err = s.db.ExecContext(ctx, `INSERT INTO nodes (id,name,base_url,api_key,health_check_method,health_check_model,account_id,weight,failed,disabled,last_error,created_at,requests,fail_count,fail_streak,total_bytes,total_input,total_output,total_cache_creation,total_cache_read,stream_dur_ms,first_byte_ms,last_ping_ms,last_ping_err,last_health_check_at)
+			VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)
 			ON CONFLICT(id) DO UPDATE SET
 				name=excluded.name,
 				base_url=excluded.base_url,
@@ -46,15 +46,17 @@ func (s *Store) UpsertNode(ctx context.Context, r NodeRecord) error {
 				total_bytes=excluded.total_bytes,
 				total_input=excluded.total_input,
 				total_output=excluded.total_output,
+				total_cache_creation=excluded.total_cache_creation,
+				total_cache_read=excluded.total_cache_read,
 				stream_dur_ms=excluded.stream_dur_ms,
 				first_byte_ms=excluded.first_byte_ms,
 				last_ping_ms=excluded.last_ping_ms,
 				last_ping_err=excluded.last_ping_err,
 				last_health_check_at=excluded.last_health_check_at`,
-			r.ID, r.Name, r.BaseURL, r.APIKey, r.HealthCheckMethod, r.HealthCheckModel, r.AccountID, r.Weight, r.Failed, r.Disabled, r.LastError, r.CreatedAt, r.Requests, r.FailCount, r.FailStreak, r.TotalBytes, r.TotalInput, r.TotalOutput, r.StreamDurMs, r.FirstByteMs, r.LastPingMs, r.LastPingErr, healthAt)
+			r.ID, r.Name, r.BaseURL, r.APIKey, r.HealthCheckMethod, r.HealthCheckModel, r.AccountID, r.Weight, r.Failed, r.Disabled, r.LastError, r.CreatedAt, r.Requests, r.FailCount, r.FailStreak, r.TotalBytes, r.TotalInput, r.TotalOutput, r.TotalCacheCreation, r.TotalCacheRead, r.StreamDurMs, r.FirstByteMs, r.LastPingMs, r.LastPingErr, healthAt)
 	} else {
-		_, err = s.db.ExecContext(ctx, `INSERT INTO nodes (id,name,base_url,api_key,health_check_method,health_check_model,account_id,weight,failed,disabled,last_error,created_at,requests,fail_count,fail_streak,total_bytes,total_input,total_output,stream_dur_ms,first_byte_ms,last_ping_ms,last_ping_err,last_health_check_at)
-			VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)
+		_, err = s.db.ExecContext(ctx, `INSERT INTO nodes (id,name,base_url,api_key,health_check_method,health_check_model,account_id,weight,failed,disabled,last_error,created_at,requests,fail_count,fail_streak,total_bytes,total_input,total_output,total_cache_creation,total_cache_read,stream_dur_ms,first_byte_ms,last_ping_ms,last_ping_err,last_health_check_at)
+			VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)
 			ON DUPLICATE KEY UPDATE
 				name=VALUES(name),
 				base_url=VALUES(base_url),
@@ -72,12 +74,14 @@ func (s *Store) UpsertNode(ctx context.Context, r NodeRecord) error {
 				total_bytes=VALUES(total_bytes),
 				total_input=VALUES(total_input),
 				total_output=VALUES(total_output),
+				total_cache_creation=VALUES(total_cache_creation),
+				total_cache_read=VALUES(total_cache_read),
 				stream_dur_ms=VALUES(stream_dur_ms),
 				first_byte_ms=VALUES(first_byte_ms),
 				last_ping_ms=VALUES(last_ping_ms),
 				last_ping_err=VALUES(last_ping_err),
 				last_health_check_at=VALUES(last_health_check_at)`,
-			r.ID, r.Name, r.BaseURL, r.APIKey, r.HealthCheckMethod, r.HealthCheckModel, r.AccountID, r.Weight, r.Failed, r.Disabled, r.LastError, r.CreatedAt, r.Requests, r.FailCount, r.FailStreak, r.TotalBytes, r.TotalInput, r.TotalOutput, r.StreamDurMs, r.FirstByteMs, r.LastPingMs, r.LastPingErr, healthAt)
+			r.ID, r.Name, r.BaseURL, r.APIKey, r.HealthCheckMethod, r.HealthCheckModel, r.AccountID, r.Weight, r.Failed, r.Disabled, r.LastError, r.CreatedAt, r.Requests, r.FailCount, r.FailStreak, r.TotalBytes, r.TotalInput, r.TotalOutput, r.TotalCacheCreation, r.TotalCacheRead, r.StreamDurMs, r.FirstByteMs, r.LastPingMs, r.LastPingErr, healthAt)
 	}
 	return err
 }
@@ -86,7 +90,7 @@ func (s *Store) GetNodesByAccount(ctx context.Context, accountID string) ([]Node
 	accountID = normalizeAccount(accountID)
 	ctx, cancel := withTimeout(ctx)
 	defer cancel()
-	rows, err := s.db.QueryContext(ctx, `SELECT id,name,base_url,api_key,health_check_method,health_check_model,account_id,weight,failed,disabled,last_error,created_at,requests,fail_count,fail_streak,total_bytes,total_input,total_output,stream_dur_ms,first_byte_ms,last_ping_ms,last_ping_err,last_health_check_at FROM nodes WHERE account_id=? ORDER BY weight ASC, created_at ASC`, accountID)
+	rows, err := s.db.QueryContext(ctx, `SELECT id,name,base_url,api_key,health_check_method,health_check_model,account_id,weight,failed,disabled,last_error,created_at,requests,fail_count,fail_streak,total_bytes,total_input,total_output,total_cache_creation,total_cache_read,stream_dur_ms,first_byte_ms,last_ping_ms,last_ping_err,last_health_check_at FROM nodes WHERE account_id=? ORDER BY weight ASC, created_at ASC`, accountID)
 	if err != nil {
 		return nil, err
 	}
@@ -95,7 +99,7 @@ func (s *Store) GetNodesByAccount(ctx context.Context, accountID string) ([]Node
 	for rows.Next() {
 		var r NodeRecord
 		var lastHealthAt sql.NullTime
-		if err := rows.Scan(&r.ID, &r.Name, &r.BaseURL, &r.APIKey, &r.HealthCheckMethod, &r.HealthCheckModel, &r.AccountID, &r.Weight, &r.Failed, &r.Disabled, &r.LastError, &r.CreatedAt, &r.Requests, &r.FailCount, &r.FailStreak, &r.TotalBytes, &r.TotalInput, &r.TotalOutput, &r.StreamDurMs, &r.FirstByteMs, &r.LastPingMs, &r.LastPingErr, &lastHealthAt); err != nil {
+		if err := rows.Scan(&r.ID, &r.Name, &r.BaseURL, &r.APIKey, &r.HealthCheckMethod, &r.HealthCheckModel, &r.AccountID, &r.Weight, &r.Failed, &r.Disabled, &r.LastError, &r.CreatedAt, &r.Requests, &r.FailCount, &r.FailStreak, &r.TotalBytes, &r.TotalInput, &r.TotalOutput, &r.TotalCacheCreation, &r.TotalCacheRead, &r.StreamDurMs, &r.FirstByteMs, &r.LastPingMs, &r.LastPingErr, &lastHealthAt); err != nil {
 			return nil, err
 		}
 		if r.HealthCheckMethod == "" {
@@ -0,0 +1,299 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ensureNodeCostTables 创建节点成本倍率与价格覆盖表，并为 usage_logs 补充实际成本列。
+// 新增列时以原 cost_usd 回填，历史记录视为按官方价格采购。
+func (s *Store) ensureNodeCostTables(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	var stmts []string
+	if s.IsSQLite() {
+		stmts = []string{
+			`CREATE TABLE IF NOT EXISTS node_costs (
+				node_id TEXT PRIMARY KEY,
+				account_id TEXT NOT NULL DEFAULT '',
+				cost_multiplier REAL NOT NULL DEFAULT 1,
+				updated_at DATETIME NOT NULL
+			)`,
+			`CREATE TABLE IF NOT EXISTS node_price_overrides (
+				node_id TEXT NOT NULL,
+				model_id TEXT NOT NULL,
+				input_price_mtok REAL NOT NULL DEFAULT 0,
+				output_price_mtok REAL NOT NULL DEFAULT 0,
+				cache_write_price_mtok REAL NOT NULL DEFAULT 0,
+				cache_read_price_mtok REAL NOT NULL DEFAULT 0,
+				PRIMARY KEY (node_id, model_id)
+			)`,
+		}
+	} else {
+		stmts = []string{
+			`CREATE TABLE IF NOT EXISTS node_costs (
+				node_id VARCHAR(64) PRIMARY KEY,
+				account_id VARCHAR(64) NOT NULL DEFAULT '',
+				cost_multiplier DECIMAL(10,6) NOT NULL DEFAULT 1,
+				updated_at DATETIME(3) NOT NULL
+			) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+			`CREATE TABLE IF NOT EXISTS node_price_overrides (
+				node_id VARCHAR(64) NOT NULL,
+				model_id VARCHAR(128) NOT NULL,
+				input_price_mtok DECIMAL(10,6) NOT NULL DEFAULT 0,
+				output_price_mtok DECIMAL(10,6) NOT NULL DEFAULT 0,
+				cache_write_price_mtok DECIMAL(10,6) NOT NULL DEFAULT 0,
+				cache_read_price_mtok DECIMAL(10,6) NOT NULL DEFAULT 0,
+				PRIMARY KEY (node_id, model_id)
+			) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+		}
+	}
+	for _, stmt := range stmts {
+		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	exists, err := s.columnExists(ctx, "usage_logs", "actual_cost_usd")
+	if err != nil || exists {
+		return err
+	}
+	stmt := `ALTER TABLE usage_logs ADD COLUMN actual_cost_usd DECIMAL(16,8) NOT NULL DEFAULT 0 AFTER cost_usd`
+	if s.IsSQLite() {
+		stmt = `ALTER TABLE usage_logs ADD COLUMN actual_cost_usd REAL NOT NULL DEFAULT 0`
+	}
+	if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `UPDATE usage_logs SET actual_cost_usd = cost_usd`)
+	return err
+}
+
+// GetNodeCost 返回节点成本配置；未配置时返回倍率为 1、无价格覆盖的默认值。
+func (s *Store) GetNodeCost(ctx context.Context, nodeID string) (*NodeCostRecord, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	rec := &NodeCostRecord{NodeID: nodeID, CostMultiplier: 1}
+	err := s.db.QueryRowContext(ctx, `SELECT account_id, cost_multiplier, updated_at FROM node_costs WHERE node_id = ?`, nodeID).
+		Scan(&rec.AccountID, &rec.CostMultiplier, &rec.UpdatedAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	overrides, err := s.listNodePriceOverrides(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	rec.Overrides = overrides[nodeID]
+	return rec, nil
+}
+
+// ListNodeCosts 列出所有已配置成本倍率或价格覆盖的节点。
+func (s *Store) ListNodeCosts(ctx context.Context) ([]NodeCostRecord, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, `SELECT node_id, account_id, cost_multiplier, updated_at FROM node_costs ORDER BY node_id`)
+	if err != nil {
+		return nil, err
+	}
+	var results []NodeCostRecord
+	seen := make(map[string]bool)
+	for rows.Next() {
+		var rec NodeCostRecord
+		if err := rows.Scan(&rec.NodeID, &rec.AccountID, &rec.CostMultiplier, &rec.UpdatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		seen[rec.NodeID] = true
+		results = append(results, rec)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	overrides, err := s.listNodePriceOverrides(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		results[i].Overrides = overrides[results[i].NodeID]
+	}
+	for nodeID, list := range overrides {
+		if !seen[nodeID] {
+			results = append(results, NodeCostRecord{NodeID: nodeID, CostMultiplier: 1, Overrides: list})
+		}
+	}
+	return results, nil
+}
+
+func (s *Store) listNodePriceOverrides(ctx context.Context, nodeID string) (map[string][]NodePriceOverride, error) {
+	query := `SELECT node_id, model_id, input_price_mtok, output_price_mtok, cache_write_price_mtok, cache_read_price_mtok FROM node_price_overrides`
+	var args []interface{}
+	if nodeID != "" {
+		query += " WHERE node_id = ?"
+		args = append(args, nodeID)
+	}
+	query += " ORDER BY node_id, model_id"
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make(map[string][]NodePriceOverride)
+	for rows.Next() {
+		var id string
+		var o NodePriceOverride
+		if err := rows.Scan(&id, &o.ModelID, &o.InputPriceMTok, &o.OutputPriceMTok, &o.CacheWritePriceMTok, &o.CacheReadPriceMTok); err != nil {
+			return nil, err
+		}
+		out[id] = append(out[id], o)
+	}
+	return out, rows.Err()
+}
+
+// SaveNodeCost 写入节点成本倍率，并以 rec.Overrides 整体替换该节点的价格覆盖。
+func (s *Store) SaveNodeCost(ctx context.Context, rec NodeCostRecord) error {
+	if rec.NodeID == "" {
+		return errors.New("node_id required")
+	}
+	if rec.CostMultiplier <= 0 {
+		rec.CostMultiplier = 1
+	}
+	if rec.UpdatedAt.IsZero() {
+		rec.UpdatedAt = time.Now()
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	upsert := `INSERT INTO node_costs (node_id, account_id, cost_multiplier, updated_at) VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE account_id = VALUES(account_id), cost_multiplier = VALUES(cost_multiplier), updated_at = VALUES(updated_at)`
+	if s.IsSQLite() {
+		upsert = `INSERT INTO node_costs (node_id, account_id, cost_multiplier, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(node_id) DO UPDATE SET account_id = excluded.account_id, cost_multiplier = excluded.cost_multiplier, updated_at = excluded.updated_at`
+	}
+	if _, err := tx.ExecContext(ctx, upsert, rec.NodeID, normalizeAccount(rec.AccountID), rec.CostMultiplier, rec.UpdatedAt.UTC()); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM node_price_overrides WHERE node_id = ?`, rec.NodeID); err != nil {
+		return err
+	}
+	for _, o := range rec.Overrides {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO node_price_overrides (node_id, model_id, input_price_mtok, output_price_mtok, cache_write_price_mtok, cache_read_price_mtok)
+			VALUES (?, ?, ?, ?, ?, ?)`, rec.NodeID, o.ModelID, o.InputPriceMTok, o.OutputPriceMTok, o.CacheWritePriceMTok, o.CacheReadPriceMTok); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteNodeCost 删除节点成本配置（节点删除时调用）。
+func (s *Store) DeleteNodeCost(ctx context.Context, nodeID string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM node_costs WHERE node_id = ?`, nodeID); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, `DELETE FROM node_price_overrides WHERE node_id = ?`, nodeID)
+	return err
+}
+
+// ActualCost 按节点配置计算实际成本：命中价格覆盖（匹配规则同 ResolveModelPricing）时按覆盖价格计费，
+// 否则为 listCost 乘以成本倍率。nil 配置视为按官方价格采购。
+func (c *NodeCostRecord) ActualCost(modelID string, usage TokenUsage, listCost float64) float64 {
+	if c == nil {
+		return listCost
+	}
+	if o := c.matchOverride(modelID); o != nil {
+		writePrice := o.CacheWritePriceMTok
+		if writePrice <= 0 {
+			writePrice = o.InputPriceMTok * defaultCacheWriteMultiplier
+		}
+		readPrice := o.CacheReadPriceMTok
+		if readPrice <= 0 {
+			readPrice = o.InputPriceMTok * defaultCacheReadMultiplier
+		}
+		return float64(usage.InputTokens)/1_000_000*o.InputPriceMTok +
+			float64(usage.OutputTokens)/1_000_000*o.OutputPriceMTok +
+			float64(usage.CacheCreationTokens)/1_000_000*writePrice +
+			float64(usage.CacheReadTokens)/1_000_000*readPrice
+	}
+	multiplier := c.CostMultiplier
+	if multiplier <= 0 {
+		multiplier = 1
+	}
+	return listCost * multiplier
+}
+
+// CostFactor 返回节点实际成本相对官方价格的系数，用于按成本选择节点：有价格覆盖时，对命中
+// 覆盖的定价条目以输入、输出各 100 万 token 计算覆盖价格与官方价格之比并取平均；没有价格覆盖
+// 或没有命中的定价条目时即为成本倍率。nil 配置为 1。
+func (c *NodeCostRecord) CostFactor(prices []ModelPricingRecord) float64 {
+	if c == nil {
+		return 1
+	}
+	multiplier := c.CostMultiplier
+	if multiplier <= 0 {
+		multiplier = 1
+	}
+	if len(c.Overrides) == 0 {
+		return multiplier
+	}
+	var sum float64
+	var n int
+	usage := TokenUsage{InputTokens: 1_000_000, OutputTokens: 1_000_000}
+	for _, p := range prices {
+		list := p.InputPriceMTok + p.OutputPriceMTok
+		if list <= 0 || c.matchOverride(p.ModelID) == nil {
+			continue
+		}
+		sum += c.ActualCost(p.ModelID, usage, list) / list
+		n++
+	}
+	if n == 0 {
+		return multiplier
+	}
+	return sum / float64(n)
+}
+
+func (c *NodeCostRecord) matchOverride(modelID string) *NodePriceOverride {
+	if len(c.Overrides) == 0 || modelID == "" {
+		return nil
+	}
+	entries := make([]ModelPricingRecord, 0, len(c.Overrides))
+	for i, o := range c.Overrides {
+		if o.ModelID == modelID {
+			return &c.Overrides[i]
+		}
+		entries = append(entries, ModelPricingRecord{ModelID: o.ModelID, IsActive: true})
+	}
+	m := matchModelPricing(entries, modelID)
+	if m == nil {
+		return nil
+	}
+	for i := range c.Overrides {
+		if c.Overrides[i].ModelID == m.ModelID {
+			return &c.Overrides[i]
+		}
+	}
+	return nil
+}
+
+// CalculateNodeCost 计算经由指定节点的请求费用：CostUSD 为官方价格（见 CalculateCost），
+// ActualCostUSD 为按节点成本配置折算后的实际成本。
+func (s *Store) CalculateNodeCost(ctx context.Context, nodeID, modelID string, usage TokenUsage, at time.Time) (CostResult, error) {
+	result, err := s.CalculateCost(ctx, modelID, usage, at)
+	if err != nil || nodeID == "" {
+		return result, err
+	}
+	cfg, err := s.GetNodeCost(ctx, nodeID)
+	if err != nil {
+		return result, err
+	}
+	result.ActualCostUSD = cfg.ActualCost(modelID, usage, result.CostUSD)
+	return result, nil
+}
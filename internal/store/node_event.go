@@ -0,0 +1,128 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ensureNodeEventsTable 创建节点事件日志表。
+func (s *Store) ensureNodeEventsTable(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	if s.IsSQLite() {
+		if _, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS node_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			account_id TEXT NOT NULL DEFAULT '',
+			node_id TEXT NOT NULL DEFAULT '',
+			event_type TEXT NOT NULL,
+			from_node_id TEXT NOT NULL DEFAULT '',
+			message TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL
+		)`); err != nil {
+			return err
+		}
+		_, _ = s.db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_node_events_account_time ON node_events(account_id, created_at)`)
+		_, _ = s.db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_node_events_time ON node_events(created_at)`)
+		return nil
+	}
+	_, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS node_events (
+		id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+		account_id VARCHAR(64) NOT NULL DEFAULT '',
+		node_id VARCHAR(64) NOT NULL DEFAULT '',
+		event_type VARCHAR(16) NOT NULL,
+		from_node_id VARCHAR(64) NOT NULL DEFAULT '',
+		message VARCHAR(1024) NOT NULL DEFAULT '',
+		created_at DATETIME(3) NOT NULL,
+		KEY idx_node_events_account_time (account_id, created_at),
+		KEY idx_node_events_time (created_at)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`)
+	return err
+}
+
+// InsertNodeEvent 记录一次节点故障、恢复或切换。
+func (s *Store) InsertNodeEvent(ctx context.Context, rec NodeEventRecord) error {
+	if s == nil || s.db == nil {
+		return errors.New("store not initialized")
+	}
+	if rec.EventType == "" {
+		return errors.New("event_type required")
+	}
+	if rec.CreatedAt.IsZero() {
+		rec.CreatedAt = time.Now()
+	}
+	if len(rec.Message) > 1024 {
+		rec.Message = rec.Message[:1024]
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	_, err := s.db.ExecContext(ctx, `INSERT INTO node_events (account_id, node_id, event_type, from_node_id, message, created_at)
+		VALUES (?,?,?,?,?,?)`,
+		normalizeAccount(rec.AccountID), rec.NodeID, rec.EventType, rec.FromNodeID, rec.Message, rec.CreatedAt.UTC())
+	return err
+}
+
+// ListNodeEvents 按时间倒序返回 [from, to) 内的节点事件；accountID 为空时不过滤，types 为空时返回全部类型，limit<=0 时默认 100。
+func (s *Store) ListNodeEvents(ctx context.Context, accountID string, types []string, from, to time.Time, limit int) ([]NodeEventRecord, error) {
+	if s == nil || s.db == nil {
+		return nil, errors.New("store not initialized")
+	}
+	if limit <= 0 {
+		limit = 100
+	}
+	query := `SELECT id, account_id, node_id, event_type, from_node_id, message, created_at FROM node_events WHERE 1=1`
+	var args []interface{}
+	if accountID != "" {
+		query += ` AND account_id=?`
+		args = append(args, accountID)
+	}
+	if len(types) > 0 {
+		query += ` AND event_type IN (?` + strings.Repeat(",?", len(types)-1) + `)`
+		for _, t := range types {
+			args = append(args, t)
+		}
+	}
+	if !from.IsZero() {
+		query += ` AND created_at >= ?`
+		args = append(args, from.UTC())
+	}
+	if !to.IsZero() {
+		query += ` AND created_at < ?`
+		args = append(args, to.UTC())
+	}
+	query += ` ORDER BY created_at DESC, id DESC LIMIT ?`
+	args = append(args, limit)
+
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []NodeEventRecord
+	for rows.Next() {
+		var rec NodeEventRecord
+		if err := rows.Scan(&rec.ID, &rec.AccountID, &rec.NodeID, &rec.EventType, &rec.FromNodeID, &rec.Message, &rec.CreatedAt); err != nil {
+			return nil, err
+		}
+		rec.CreatedAt = rec.CreatedAt.UTC()
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
+
+// CleanupNodeEvents 删除 before 之前的节点事件。
+func (s *Store) CleanupNodeEvents(ctx context.Context, before time.Time) (int64, error) {
+	if s == nil || s.db == nil {
+		return 0, errors.New("store not initialized")
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	res, err := s.db.ExecContext(ctx, `DELETE FROM node_events WHERE created_at < ?`, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
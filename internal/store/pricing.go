@@ -45,6 +45,8 @@ func (s *Store) ensurePricingTables(ctx context.Context) error {
 			output_tokens INTEGER NOT NULL DEFAULT 0,
 			cost_usd REAL NOT NULL DEFAULT 0,
 			request_id TEXT,
+			upstream_request_id TEXT,
+			attempt_trace TEXT,
 			success INTEGER DEFAULT 1,
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 		)`
@@ -70,8 +72,11 @@ func (s *Store) ensurePricingTables(ctx context.Context) error {
 			output_tokens BIGINT NOT NULL DEFAULT 0,
 			cost_usd DECIMAL(16,8) NOT NULL DEFAULT 0,
 			request_id VARCHAR(128),
+			upstream_request_id VARCHAR(128),
+			attempt_trace TEXT,
 			success BOOLEAN DEFAULT TRUE,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			KEY idx_request_id (request_id),
 			KEY idx_account_time (account_id, created_at),
 			KEY idx_node_time (node_id, created_at),
 			KEY idx_model_time (model_id, created_at),
@@ -97,6 +102,55 @@ func (s *Store) ensurePricingTables(ctx context.Context) error {
 		s.db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_created_at ON usage_logs(created_at)`)
 	}
 
+	return s.migrateUsageLogTrace(ctx)
+}
+
+// migrateUsageLogTrace 为旧版 usage_logs 补充上游请求 ID 与尝试轨迹列。
+func (s *Store) migrateUsageLogTrace(ctx context.Context) error {
+	columns := []struct {
+		name   string
+		sqlite string
+		mysql  string
+	}{
+		{"upstream_request_id", `ALTER TABLE usage_logs ADD COLUMN upstream_request_id TEXT`, `ALTER TABLE usage_logs ADD COLUMN upstream_request_id VARCHAR(128) AFTER request_id`},
+		{"attempt_trace", `ALTER TABLE usage_logs ADD COLUMN attempt_trace TEXT`, `ALTER TABLE usage_logs ADD COLUMN attempt_trace TEXT AFTER upstream_request_id`},
+	}
+	for _, col := range columns {
+		exists, err := s.columnExists(ctx, "usage_logs", col.name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		stmt := col.mysql
+		if s.IsSQLite() {
+			stmt = col.sqlite
+		}
+		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+		if col.name == "upstream_request_id" {
+			// 旧版 request_id 保存的是上游请求 ID，迁移到新列，避免与代理请求 ID 混淆。
+			if _, err := s.db.ExecContext(ctx, `UPDATE usage_logs SET upstream_request_id = request_id, request_id = NULL
+				WHERE request_id IS NOT NULL AND request_id <> ''`); err != nil {
+				return err
+			}
+		}
+	}
+	if s.IsSQLite() {
+		s.db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_request_id ON usage_logs(request_id)`)
+		return nil
+	}
+	hasIndex, err := s.indexExists(ctx, "usage_logs", "idx_request_id")
+	if err != nil {
+		return err
+	}
+	if !hasIndex {
+		if _, err := s.db.ExecContext(ctx, `CREATE INDEX idx_request_id ON usage_logs(request_id)`); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -134,19 +188,23 @@ func (s *Store) SeedDefaultPricing(ctx context.Context) error {
 
 	var stmt string
 	if s.IsSQLite() {
-		stmt = `INSERT OR IGNORE INTO model_pricing (id, model_id, model_name, input_price_mtok, output_price_mtok, is_active) VALUES (?, ?, ?, ?, ?, ?)`
+		stmt = `INSERT OR IGNORE INTO model_pricing (id, model_id, model_name, input_price_mtok, output_price_mtok, cache_write_price_mtok, cache_read_price_mtok, is_active) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
 	} else {
-		stmt = `INSERT IGNORE INTO model_pricing (id, model_id, model_name, input_price_mtok, output_price_mtok, is_active) VALUES (?, ?, ?, ?, ?, ?)`
+		stmt = `INSERT IGNORE INTO model_pricing (id, model_id, model_name, input_price_mtok, output_price_mtok, cache_write_price_mtok, cache_read_price_mtok, is_active) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
 	}
 
 	for _, p := range defaultPricing {
 		p.ID = genUUID()
+		// 缓存写入/读取价格按官方倍率由输入价格推导
+		p.CacheWritePriceMTok = p.InputPriceMTok * defaultCacheWriteMultiplier
+		p.CacheReadPriceMTok = p.InputPriceMTok * defaultCacheReadMultiplier
 		_, err := s.db.ExecContext(ctx, stmt,
-			p.ID, p.ModelID, p.ModelName, p.InputPriceMTok, p.OutputPriceMTok, p.IsActive)
+			p.ID, p.ModelID, p.ModelName, p.InputPriceMTok, p.OutputPriceMTok, p.CacheWritePriceMTok, p.CacheReadPriceMTok, p.IsActive)
 		if err != nil {
 			return err
 		}
 	}
+	s.InvalidatePricingCache()
 	return nil
 }
 
@@ -156,11 +214,11 @@ func (s *Store) GetModelPricing(ctx context.Context, modelID string) (*ModelPric
 	defer cancel()
 
 	row := s.db.QueryRowContext(ctx,
-		`SELECT id, model_id, model_name, input_price_mtok, output_price_mtok, is_active, created_at, updated_at
+		`SELECT id, model_id, model_name, input_price_mtok, output_price_mtok, cache_write_price_mtok, cache_read_price_mtok, is_active, created_at, updated_at
 		FROM model_pricing WHERE model_id = ?`, modelID)
 
 	var p ModelPricingRecord
-	err := row.Scan(&p.ID, &p.ModelID, &p.ModelName, &p.InputPriceMTok, &p.OutputPriceMTok, &p.IsActive, &p.CreatedAt, &p.UpdatedAt)
+	err := row.Scan(&p.ID, &p.ModelID, &p.ModelName, &p.InputPriceMTok, &p.OutputPriceMTok, &p.CacheWritePriceMTok, &p.CacheReadPriceMTok, &p.IsActive, &p.CreatedAt, &p.UpdatedAt)
 	if err == sql.ErrNoRows {
 		return nil, ErrNotFound
 	}
@@ -175,7 +233,7 @@ func (s *Store) ListModelPricing(ctx context.Context, activeOnly bool) ([]ModelP
 	ctx, cancel := withTimeout(ctx)
 	defer cancel()
 
-	query := `SELECT id, model_id, model_name, input_price_mtok, output_price_mtok, is_active, created_at, updated_at FROM model_pricing`
+	query := `SELECT id, model_id, model_name, input_price_mtok, output_price_mtok, cache_write_price_mtok, cache_read_price_mtok, is_active, created_at, updated_at FROM model_pricing`
 	if activeOnly {
 		if s.IsSQLite() {
 			query += " WHERE is_active = 1"
@@ -194,7 +252,7 @@ func (s *Store) ListModelPricing(ctx context.Context, activeOnly bool) ([]ModelP
 	var results []ModelPricingRecord
 	for rows.Next() {
 		var p ModelPricingRecord
-		if err := rows.Scan(&p.ID, &p.ModelID, &p.ModelName, &p.InputPriceMTok, &p.OutputPriceMTok, &p.IsActive, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		if err := rows.Scan(&p.ID, &p.ModelID, &p.ModelName, &p.InputPriceMTok, &p.OutputPriceMTok, &p.CacheWritePriceMTok, &p.CacheReadPriceMTok, &p.IsActive, &p.CreatedAt, &p.UpdatedAt); err != nil {
 			return nil, err
 		}
 		results = append(results, p)
@@ -202,8 +260,22 @@ func (s *Store) ListModelPricing(ctx context.Context, activeOnly bool) ([]ModelP
 	return results, rows.Err()
 }
 
-// UpsertModelPricing 创建或更新模型定价
+// UpsertModelPricing 创建或更新模型定价。价格变化时写入价格历史，新价格自
+// p.EffectiveFrom（为空时取当前时间）起生效，之前的用量重新计费时仍按旧价格。
 func (s *Store) UpsertModelPricing(ctx context.Context, p ModelPricingRecord) error {
+	prev, err := s.GetModelPricing(ctx, p.ModelID)
+	if err != nil && err != ErrNotFound {
+		return err
+	}
+	at := time.Now()
+	if p.EffectiveFrom != nil {
+		at = *p.EffectiveFrom
+	}
+	defer s.InvalidatePricingCache()
+	if err := s.recordPriceVersion(ctx, prev, p, at); err != nil {
+		return err
+	}
+
 	ctx, cancel := withTimeout(ctx)
 	defer cancel()
 
@@ -211,36 +283,40 @@ func (s *Store) UpsertModelPricing(ctx context.Context, p ModelPricingRecord) er
 		p.ID = genUUID()
 	}
 
-	var err error
 	if s.IsSQLite() {
 		_, err = s.db.ExecContext(ctx,
-			`INSERT INTO model_pricing (id, model_id, model_name, input_price_mtok, output_price_mtok, is_active)
-			VALUES (?, ?, ?, ?, ?, ?)
+			`INSERT INTO model_pricing (id, model_id, model_name, input_price_mtok, output_price_mtok, cache_write_price_mtok, cache_read_price_mtok, is_active)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
 			ON CONFLICT(model_id) DO UPDATE SET
 				model_name = excluded.model_name,
 				input_price_mtok = excluded.input_price_mtok,
 				output_price_mtok = excluded.output_price_mtok,
+				cache_write_price_mtok = excluded.cache_write_price_mtok,
+				cache_read_price_mtok = excluded.cache_read_price_mtok,
 				is_active = excluded.is_active,
 				updated_at = CURRENT_TIMESTAMP`,
-			p.ID, p.ModelID, p.ModelName, p.InputPriceMTok, p.OutputPriceMTok, p.IsActive)
+			p.ID, p.ModelID, p.ModelName, p.InputPriceMTok, p.OutputPriceMTok, p.CacheWritePriceMTok, p.CacheReadPriceMTok, p.IsActive)
 	} else {
 		_, err = s.db.ExecContext(ctx,
-			`INSERT INTO model_pricing (id, model_id, model_name, input_price_mtok, output_price_mtok, is_active)
-			VALUES (?, ?, ?, ?, ?, ?)
+			`INSERT INTO model_pricing (id, model_id, model_name, input_price_mtok, output_price_mtok, cache_write_price_mtok, cache_read_price_mtok, is_active)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
 			ON DUPLICATE KEY UPDATE
 				model_name = VALUES(model_name),
 				input_price_mtok = VALUES(input_price_mtok),
 				output_price_mtok = VALUES(output_price_mtok),
+				cache_write_price_mtok = VALUES(cache_write_price_mtok),
+				cache_read_price_mtok = VALUES(cache_read_price_mtok),
 				is_active = VALUES(is_active)`,
-			p.ID, p.ModelID, p.ModelName, p.InputPriceMTok, p.OutputPriceMTok, p.IsActive)
+			p.ID, p.ModelID, p.ModelName, p.InputPriceMTok, p.OutputPriceMTok, p.CacheWritePriceMTok, p.CacheReadPriceMTok, p.IsActive)
 	}
 	return err
 }
 
-// DeleteModelPricing 删除模型定价
+// DeleteModelPricing 删除模型定价及其价格历史
 func (s *Store) DeleteModelPricing(ctx context.Context, modelID string) error {
 	ctx, cancel := withTimeout(ctx)
 	defer cancel()
+	defer s.InvalidatePricingCache()
 
 	result, err := s.db.ExecContext(ctx, "DELETE FROM model_pricing WHERE model_id = ?", modelID)
 	if err != nil {
@@ -250,25 +326,87 @@ func (s *Store) DeleteModelPricing(ctx context.Context, modelID string) error {
 	if affected == 0 {
 		return ErrNotFound
 	}
-	return nil
+	_, err = s.db.ExecContext(ctx, "DELETE FROM model_pricing_history WHERE model_id = ?", modelID)
+	return err
 }
 
-// CalculateCost 计算指定模型的费用（美元）
-func (s *Store) CalculateCost(ctx context.Context, modelID string, inputTokens, outputTokens int64) (float64, error) {
-	pricing, err := s.GetModelPricing(ctx, modelID)
+// CalculateCost 计算指定模型在 at 时刻的费用（美元）。先按 ResolveModelPricing 取模型条目，
+// 并按价格历史取 at 时刻生效的基础价格，再叠加 at 时刻按输入规模命中的定价规则
+// （见 PricingRuleRecord）；缓存写入/读取按各自价格计费，未配置缓存价格时按生效输入
+// 价格的默认倍率推导。at 为零值时取当前时间，没有任何价格时返回 Unpriced。
+// 定价数据读取自内存快照，写入后立即失效，见 InvalidatePricingCache。
+func (s *Store) CalculateCost(ctx context.Context, modelID string, usage TokenUsage, at time.Time) (CostResult, error) {
+	if at.IsZero() {
+		at = time.Now()
+	}
+	at = at.UTC()
+	snap, err := s.pricingSnapshot(ctx)
 	if err != nil {
-		if err == ErrNotFound {
-			// 未知模型返回 0 费用，记录警告便于追踪
-			log.Printf("[pricing] unknown model %q, cost calculated as $0 (input=%d, output=%d tokens)", modelID, inputTokens, outputTokens)
-			return 0, nil
+		return CostResult{}, err
+	}
+	pricing := snap.resolve(modelID)
+	rules := snap.rules[modelID]
+	if pricing != nil && pricing.ModelID != modelID {
+		// 通过模式或同族命中时，挂在该定价条目上的规则同样生效
+		rules = append(append([]PricingRuleRecord(nil), rules...), snap.rules[pricing.ModelID]...)
+	}
+	// 输入规模按请求实际处理的全部输入计算（含缓存写入与读取）
+	matched := matchPricingRules(rules, at, usage.InputTokens+usage.CacheCreationTokens+usage.CacheReadTokens)
+	if pricing == nil && len(matched) == 0 {
+		// 未知模型返回 0 费用，记录警告便于追踪
+		log.Printf("[pricing] unknown model %q, cost calculated as $0 (input=%d, output=%d, cache_write=%d, cache_read=%d tokens)",
+			modelID, usage.InputTokens, usage.OutputTokens, usage.CacheCreationTokens, usage.CacheReadTokens)
+		return CostResult{Unpriced: true}, nil
+	}
+
+	var inputPrice, outputPrice, writePrice, readPrice float64
+	var result CostResult
+	if pricing != nil {
+		base := snap.priceAt(*pricing, at)
+		inputPrice, outputPrice = base.InputPriceMTok, base.OutputPriceMTok
+		writePrice, readPrice = base.CacheWritePriceMTok, base.CacheReadPriceMTok
+		result.PricingModelID = pricing.ModelID
+	}
+	multiplier := 1.0
+	if len(matched) > 0 {
+		result.PricingRuleID = matched[0].ID
+	}
+	// 规则按优先级从低到高逐字段叠加，高优先级规则只覆盖自己设置的字段，
+	// 例如长上下文分段价格与限时折扣倍率可同时生效
+	for i := len(matched) - 1; i >= 0; i-- {
+		rule := matched[i]
+		if rule.InputPriceMTok > 0 {
+			// 输入价格被覆盖时缓存价格随之按倍率重新推导，除非规则单独指定
+			inputPrice, writePrice, readPrice = rule.InputPriceMTok, 0, 0
+		}
+		if rule.OutputPriceMTok > 0 {
+			outputPrice = rule.OutputPriceMTok
+		}
+		if rule.CacheWritePriceMTok > 0 {
+			writePrice = rule.CacheWritePriceMTok
+		}
+		if rule.CacheReadPriceMTok > 0 {
+			readPrice = rule.CacheReadPriceMTok
+		}
+		if rule.Multiplier > 0 {
+			multiplier = rule.Multiplier
 		}
-		return 0, err
 	}
 
 	// 计算费用：tokens / 1,000,000 * price_per_mtok
-	inputCost := float64(inputTokens) / 1_000_000 * pricing.InputPriceMTok
-	outputCost := float64(outputTokens) / 1_000_000 * pricing.OutputPriceMTok
-	return inputCost + outputCost, nil
+	if writePrice <= 0 {
+		writePrice = inputPrice * defaultCacheWriteMultiplier
+	}
+	if readPrice <= 0 {
+		readPrice = inputPrice * defaultCacheReadMultiplier
+	}
+	inputCost := float64(usage.InputTokens) / 1_000_000 * inputPrice
+	outputCost := float64(usage.OutputTokens) / 1_000_000 * outputPrice
+	cacheWriteCost := float64(usage.CacheCreationTokens) / 1_000_000 * writePrice
+	cacheReadCost := float64(usage.CacheReadTokens) / 1_000_000 * readPrice
+	result.CostUSD = (inputCost + outputCost + cacheWriteCost + cacheReadCost) * multiplier
+	result.ActualCostUSD = result.CostUSD
+	return result, nil
 }
 
 // InsertUsageLog 插入使用日志
@@ -282,18 +420,46 @@ func (s *Store) InsertUsageLog(ctx context.Context, log UsageLogRecord) error {
 	}
 
 	_, err := s.db.ExecContext(ctx,
-		`INSERT INTO usage_logs (account_id, node_id, model_id, input_tokens, output_tokens, cost_usd, request_id, success, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		log.AccountID, log.NodeID, log.ModelID, log.InputTokens, log.OutputTokens, log.CostUSD, log.RequestID, log.Success, log.CreatedAt)
+		`INSERT INTO usage_logs (account_id, node_id, model_id, input_tokens, output_tokens, cache_creation_tokens, cache_read_tokens, cost_usd, actual_cost_usd, pricing_rule_id, unpriced, key_hint, request_id, upstream_request_id, attempt_trace, success, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		log.AccountID, log.NodeID, log.ModelID, log.InputTokens, log.OutputTokens, log.CacheCreationTokens, log.CacheReadTokens, log.CostUSD, log.ActualCostUSD, log.PricingRuleID, log.Unpriced, log.KeyHint, log.RequestID, log.UpstreamRequestID, log.AttemptTrace, log.Success, log.CreatedAt)
 	return err
 }
 
+// GetUsageLogByRequestID 按代理请求 ID 查询最近一条使用日志。
+func (s *Store) GetUsageLogByRequestID(ctx context.Context, requestID string) (*UsageLogRecord, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	row := s.db.QueryRowContext(ctx, `SELECT id, account_id, node_id, model_id, input_tokens, output_tokens, cache_creation_tokens, cache_read_tokens, cost_usd, actual_cost_usd, pricing_rule_id, unpriced, key_hint, request_id, upstream_request_id, attempt_trace, success, created_at
+		FROM usage_logs WHERE request_id = ? ORDER BY id DESC LIMIT 1`, requestID)
+	log, err := scanUsageLog(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return log, err
+}
+
+func scanUsageLog(row interface{ Scan(dest ...any) error }) (*UsageLogRecord, error) {
+	var log UsageLogRecord
+	var ruleID, keyHint, reqID, upstreamID, trace sql.NullString
+	if err := row.Scan(&log.ID, &log.AccountID, &log.NodeID, &log.ModelID, &log.InputTokens, &log.OutputTokens, &log.CacheCreationTokens, &log.CacheReadTokens, &log.CostUSD, &log.ActualCostUSD, &ruleID, &log.Unpriced, &keyHint, &reqID, &upstreamID, &trace, &log.Success, &log.CreatedAt); err != nil {
+		return nil, err
+	}
+	log.PricingRuleID = ruleID.String
+	log.KeyHint = keyHint.String
+	log.RequestID = reqID.String
+	log.UpstreamRequestID = upstreamID.String
+	log.AttemptTrace = trace.String
+	return &log, nil
+}
+
 // QueryUsageLogs 查询使用日志
 func (s *Store) QueryUsageLogs(ctx context.Context, params QueryUsageParams) ([]UsageLogRecord, error) {
 	ctx, cancel := withTimeout(ctx)
 	defer cancel()
 
-	query := `SELECT id, account_id, node_id, model_id, input_tokens, output_tokens, cost_usd, request_id, success, created_at
+	query := `SELECT id, account_id, node_id, model_id, input_tokens, output_tokens, cache_creation_tokens, cache_read_tokens, cost_usd, actual_cost_usd, pricing_rule_id, unpriced, key_hint, request_id, upstream_request_id, attempt_trace, success, created_at
 		FROM usage_logs WHERE 1=1`
 	var args []interface{}
 
@@ -337,15 +503,11 @@ func (s *Store) QueryUsageLogs(ctx context.Context, params QueryUsageParams) ([]
 
 	var results []UsageLogRecord
 	for rows.Next() {
-		var log UsageLogRecord
-		var reqID sql.NullString
-		if err := rows.Scan(&log.ID, &log.AccountID, &log.NodeID, &log.ModelID, &log.InputTokens, &log.OutputTokens, &log.CostUSD, &reqID, &log.Success, &log.CreatedAt); err != nil {
+		log, err := scanUsageLog(rows)
+		if err != nil {
 			return nil, err
 		}
-		if reqID.Valid {
-			log.RequestID = reqID.String
-		}
-		results = append(results, log)
+		results = append(results, *log)
 	}
 	return results, rows.Err()
 }
@@ -361,7 +523,10 @@ func (s *Store) GetUsageSummary(ctx context.Context, params QueryUsageParams) (*
 		COALESCE(SUM(CASE WHEN success = 1 THEN 1 ELSE 0 END), 0) as success_requests,
 		COALESCE(SUM(input_tokens), 0) as total_input_tokens,
 		COALESCE(SUM(output_tokens), 0) as total_output_tokens,
-		COALESCE(SUM(cost_usd), 0) as total_cost_usd
+		COALESCE(SUM(cache_creation_tokens), 0) as total_cache_creation_tokens,
+		COALESCE(SUM(cache_read_tokens), 0) as total_cache_read_tokens,
+		COALESCE(SUM(cost_usd), 0) as total_cost_usd,
+		COALESCE(SUM(actual_cost_usd), 0) as total_actual_cost_usd
 		FROM usage_logs WHERE 1=1`
 	var args []interface{}
 
@@ -388,7 +553,7 @@ func (s *Store) GetUsageSummary(ctx context.Context, params QueryUsageParams) (*
 
 	row := s.db.QueryRowContext(ctx, query, args...)
 	var summary UsageSummary
-	if err := row.Scan(&summary.TotalRequests, &summary.SuccessRequests, &summary.TotalInputTokens, &summary.TotalOutputTokens, &summary.TotalCostUSD); err != nil {
+	if err := row.Scan(&summary.TotalRequests, &summary.SuccessRequests, &summary.TotalInputTokens, &summary.TotalOutputTokens, &summary.TotalCacheCreationTokens, &summary.TotalCacheReadTokens, &summary.TotalCostUSD, &summary.TotalActualCostUSD); err != nil {
 		return nil, err
 	}
 	summary.AccountID = params.AccountID
@@ -409,7 +574,10 @@ func (s *Store) GetUsageSummaryByModel(ctx context.Context, params QueryUsagePar
 		COALESCE(SUM(CASE WHEN success = 1 THEN 1 ELSE 0 END), 0) as success_requests,
 		COALESCE(SUM(input_tokens), 0) as total_input_tokens,
 		COALESCE(SUM(output_tokens), 0) as total_output_tokens,
-		COALESCE(SUM(cost_usd), 0) as total_cost_usd
+		COALESCE(SUM(cache_creation_tokens), 0) as total_cache_creation_tokens,
+		COALESCE(SUM(cache_read_tokens), 0) as total_cache_read_tokens,
+		COALESCE(SUM(cost_usd), 0) as total_cost_usd,
+		COALESCE(SUM(actual_cost_usd), 0) as total_actual_cost_usd
 		FROM usage_logs WHERE 1=1`
 	var args []interface{}
 
@@ -441,7 +609,7 @@ func (s *Store) GetUsageSummaryByModel(ctx context.Context, params QueryUsagePar
 	var results []UsageSummary
 	for rows.Next() {
 		var summary UsageSummary
-		if err := rows.Scan(&summary.ModelID, &summary.TotalRequests, &summary.SuccessRequests, &summary.TotalInputTokens, &summary.TotalOutputTokens, &summary.TotalCostUSD); err != nil {
+		if err := rows.Scan(&summary.ModelID, &summary.TotalRequests, &summary.SuccessRequests, &summary.TotalInputTokens, &summary.TotalOutputTokens, &summary.TotalCacheCreationTokens, &summary.TotalCacheReadTokens, &summary.TotalCostUSD, &summary.TotalActualCostUSD); err != nil {
 			return nil, err
 		}
 		summary.AccountID = params.AccountID
@@ -463,7 +631,10 @@ func (s *Store) GetUsageSummaryByNode(ctx context.Context, params QueryUsagePara
 		COALESCE(SUM(CASE WHEN success = 1 THEN 1 ELSE 0 END), 0) as success_requests,
 		COALESCE(SUM(input_tokens), 0) as total_input_tokens,
 		COALESCE(SUM(output_tokens), 0) as total_output_tokens,
-		COALESCE(SUM(cost_usd), 0) as total_cost_usd
+		COALESCE(SUM(cache_creation_tokens), 0) as total_cache_creation_tokens,
+		COALESCE(SUM(cache_read_tokens), 0) as total_cache_read_tokens,
+		COALESCE(SUM(cost_usd), 0) as total_cost_usd,
+		COALESCE(SUM(actual_cost_usd), 0) as total_actual_cost_usd
 		FROM usage_logs WHERE 1=1`
 	var args []interface{}
 
@@ -495,7 +666,7 @@ func (s *Store) GetUsageSummaryByNode(ctx context.Context, params QueryUsagePara
 	var results []UsageSummary
 	for rows.Next() {
 		var summary UsageSummary
-		if err := rows.Scan(&summary.NodeID, &summary.TotalRequests, &summary.SuccessRequests, &summary.TotalInputTokens, &summary.TotalOutputTokens, &summary.TotalCostUSD); err != nil {
+		if err := rows.Scan(&summary.NodeID, &summary.TotalRequests, &summary.SuccessRequests, &summary.TotalInputTokens, &summary.TotalOutputTokens, &summary.TotalCacheCreationTokens, &summary.TotalCacheReadTokens, &summary.TotalCostUSD, &summary.TotalActualCostUSD); err != nil {
 			return nil, err
 		}
 		summary.AccountID = params.AccountID
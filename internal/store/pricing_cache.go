@@ -0,0 +1,192 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// pricingCacheTTL 定价快照的最长有效期。本实例的写入会立即失效快照，其他实例的修改
+// 通过集群事件调用 InvalidatePricingCache，TTL 仅作为未启用集群总线时的兜底。
+const pricingCacheTTL = time.Minute
+
+// pricingHistoryEpoch 价格历史的最早生效时间，表示在此之前的用量同样按该价格计费。
+var pricingHistoryEpoch = time.Unix(0, 0).UTC()
+
+// modelPriceVersion 模型基础价格的一个生效版本。
+type modelPriceVersion struct {
+	EffectiveFrom       time.Time
+	InputPriceMTok      float64
+	OutputPriceMTok     float64
+	CacheWritePriceMTok float64
+	CacheReadPriceMTok  float64
+}
+
+// pricingSnapshot 计费所需的定价数据快照，加载后只读共享。
+type pricingSnapshot struct {
+	loadedAt time.Time
+	byID     map[string]ModelPricingRecord  // 全部定价条目（精确匹配不要求启用）
+	active   []ModelPricingRecord           // 启用的条目，用于模式与同族匹配
+	history  map[string][]modelPriceVersion // 按 model_id 的价格版本，生效时间升序
+	rules    map[string][]PricingRuleRecord // 按 model_id 的定价规则
+}
+
+// ensurePricingHistoryTable 创建模型基础价格历史表。
+func (s *Store) ensurePricingHistoryTable(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	if s.IsSQLite() {
+		if _, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS model_pricing_history (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			model_id TEXT NOT NULL,
+			input_price_mtok REAL NOT NULL DEFAULT 0,
+			output_price_mtok REAL NOT NULL DEFAULT 0,
+			cache_write_price_mtok REAL NOT NULL DEFAULT 0,
+			cache_read_price_mtok REAL NOT NULL DEFAULT 0,
+			effective_from DATETIME NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`); err != nil {
+			return err
+		}
+		_, err := s.db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_pricing_history_model ON model_pricing_history(model_id, effective_from)`)
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS model_pricing_history (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		model_id VARCHAR(128) NOT NULL,
+		input_price_mtok DECIMAL(10,6) NOT NULL DEFAULT 0,
+		output_price_mtok DECIMAL(10,6) NOT NULL DEFAULT 0,
+		cache_write_price_mtok DECIMAL(10,6) NOT NULL DEFAULT 0,
+		cache_read_price_mtok DECIMAL(10,6) NOT NULL DEFAULT 0,
+		effective_from DATETIME NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		KEY idx_pricing_history_model (model_id, effective_from)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`)
+	return err
+}
+
+// recordPriceVersion 价格变化时追加价格版本。模型首次记录历史时，先以最早时间补记
+// 变更前的价格（新模型则为本次价格），使早于变更的用量仍按当时价格重新计费。
+func (s *Store) recordPriceVersion(ctx context.Context, prev *ModelPricingRecord, p ModelPricingRecord, at time.Time) error {
+	if prev != nil && prev.InputPriceMTok == p.InputPriceMTok && prev.OutputPriceMTok == p.OutputPriceMTok &&
+		prev.CacheWritePriceMTok == p.CacheWritePriceMTok && prev.CacheReadPriceMTok == p.CacheReadPriceMTok {
+		return nil
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	var count int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM model_pricing_history WHERE model_id = ?`, p.ModelID).Scan(&count); err != nil {
+		return err
+	}
+	const insert = `INSERT INTO model_pricing_history (model_id, input_price_mtok, output_price_mtok, cache_write_price_mtok, cache_read_price_mtok, effective_from)
+		VALUES (?, ?, ?, ?, ?, ?)`
+	if count == 0 {
+		base := p
+		if prev != nil {
+			base = *prev
+		}
+		if _, err := s.db.ExecContext(ctx, insert, p.ModelID, base.InputPriceMTok, base.OutputPriceMTok, base.CacheWritePriceMTok, base.CacheReadPriceMTok, pricingHistoryEpoch); err != nil {
+			return err
+		}
+		if prev == nil {
+			return nil
+		}
+	}
+	_, err := s.db.ExecContext(ctx, insert, p.ModelID, p.InputPriceMTok, p.OutputPriceMTok, p.CacheWritePriceMTok, p.CacheReadPriceMTok, at.UTC())
+	return err
+}
+
+// InvalidatePricingCache 丢弃定价快照，下次计费时重新加载。
+func (s *Store) InvalidatePricingCache() {
+	s.pricingGen.Add(1)
+	s.pricingCache.Store(nil)
+}
+
+// pricingSnapshot 返回当前定价快照，过期或失效时重新加载。
+func (s *Store) pricingSnapshot(ctx context.Context) (*pricingSnapshot, error) {
+	if snap := s.pricingCache.Load(); snap != nil && time.Since(snap.loadedAt) < pricingCacheTTL {
+		return snap, nil
+	}
+	s.pricingMu.Lock()
+	defer s.pricingMu.Unlock()
+	if snap := s.pricingCache.Load(); snap != nil && time.Since(snap.loadedAt) < pricingCacheTTL {
+		return snap, nil
+	}
+	gen := s.pricingGen.Load()
+	snap, err := s.loadPricingSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// 加载期间发生写入时不缓存，避免保存旧数据
+	if s.pricingGen.Load() == gen {
+		s.pricingCache.Store(snap)
+	}
+	return snap, nil
+}
+
+func (s *Store) loadPricingSnapshot(ctx context.Context) (*pricingSnapshot, error) {
+	snap := &pricingSnapshot{
+		loadedAt: time.Now(),
+		byID:     make(map[string]ModelPricingRecord),
+		history:  make(map[string][]modelPriceVersion),
+		rules:    make(map[string][]PricingRuleRecord),
+	}
+	entries, err := s.ListModelPricing(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		snap.byID[e.ModelID] = e
+		if e.IsActive {
+			snap.active = append(snap.active, e)
+		}
+	}
+	rules, err := s.ListPricingRules(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rules {
+		snap.rules[r.ModelID] = append(snap.rules[r.ModelID], r)
+	}
+
+	qctx, cancel := withTimeout(ctx)
+	defer cancel()
+	rows, err := s.db.QueryContext(qctx, `SELECT model_id, input_price_mtok, output_price_mtok, cache_write_price_mtok, cache_read_price_mtok, effective_from
+		FROM model_pricing_history ORDER BY model_id ASC, effective_from ASC, id ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			modelID string
+			v       modelPriceVersion
+		)
+		if err := rows.Scan(&modelID, &v.InputPriceMTok, &v.OutputPriceMTok, &v.CacheWritePriceMTok, &v.CacheReadPriceMTok, &v.EffectiveFrom); err != nil {
+			return nil, err
+		}
+		snap.history[modelID] = append(snap.history[modelID], v)
+	}
+	return snap, rows.Err()
+}
+
+// resolve 按精确 ID、glob、正则、同族的顺序查找定价条目。
+func (snap *pricingSnapshot) resolve(modelID string) *ModelPricingRecord {
+	if p, ok := snap.byID[modelID]; ok {
+		return &p
+	}
+	return matchModelPricing(snap.active, modelID)
+}
+
+// priceAt 返回定价条目在 at 时刻生效的基础价格；没有价格历史时使用条目当前价格。
+func (snap *pricingSnapshot) priceAt(p ModelPricingRecord, at time.Time) ModelPricingRecord {
+	versions := snap.history[p.ModelID]
+	i := sort.Search(len(versions), func(i int) bool { return versions[i].EffectiveFrom.After(at) })
+	if i == 0 {
+		return p
+	}
+	v := versions[i-1]
+	p.InputPriceMTok, p.OutputPriceMTok = v.InputPriceMTok, v.OutputPriceMTok
+	p.CacheWritePriceMTok, p.CacheReadPriceMTok = v.CacheWritePriceMTok, v.CacheReadPriceMTok
+	return p
+}
@@ -0,0 +1,341 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 定价条目的 model_id 除精确 ID 外还支持两种模式：
+//   - glob：含 * 或 ? 的 ID，如 claude-sonnet-4-5*（* 匹配任意字符串，? 匹配单个字符）
+//   - 正则：以 re: 开头，如 re:^claude-(opus|sonnet)-4-5
+//
+// 匹配均不区分大小写。ResolveModelPricing 按以下顺序取第一个命中的条目：
+//  1. 精确 ID
+//  2. glob 模式，字面字符（不含通配符）越多越优先
+//  3. 正则模式，表达式越长越优先
+//  4. 同族模型：去掉供应商前缀、日期/latest 与版本后缀后相同的精确 ID，取 ID 最大（通常最新）者
+//
+// 同一层级仍相同时按 model_id 字典序，保证结果确定。模式与同族匹配只考虑启用的条目。
+const modelRegexPrefix = "re:"
+
+// modelFamilyRe 去掉日期（-20250929、@20250929）或 -latest、版本（-v1）与 :0 后缀。
+var modelFamilyRe = regexp.MustCompile(`^(.*?)(?:[-@]\d{8}|-latest)?(?:-v\d+)?(?::\d+)?$`)
+
+var (
+	modelPatternMu    sync.Mutex
+	modelPatternCache = map[string]*regexp.Regexp{}
+)
+
+// IsModelPattern 判断定价条目的 model_id 是否为 glob 或正则模式。
+func IsModelPattern(modelID string) bool {
+	return strings.HasPrefix(modelID, modelRegexPrefix) || strings.ContainsAny(modelID, "*?")
+}
+
+// compileModelPattern 将模式编译为正则，结果按模式缓存；非法正则返回 nil。
+func compileModelPattern(pattern string) *regexp.Regexp {
+	modelPatternMu.Lock()
+	defer modelPatternMu.Unlock()
+	if re, ok := modelPatternCache[pattern]; ok {
+		return re
+	}
+	var expr string
+	if strings.HasPrefix(pattern, modelRegexPrefix) {
+		expr = "(?i)" + strings.TrimPrefix(pattern, modelRegexPrefix)
+	} else {
+		quoted := regexp.QuoteMeta(pattern)
+		quoted = strings.ReplaceAll(quoted, `\*`, ".*")
+		quoted = strings.ReplaceAll(quoted, `\?`, ".")
+		expr = "(?i)^" + quoted + "$"
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		re = nil
+	}
+	modelPatternCache[pattern] = re
+	return re
+}
+
+// ValidateModelPattern 校验模式 model_id，正则非法时返回错误。
+func ValidateModelPattern(modelID string) error {
+	if !strings.HasPrefix(modelID, modelRegexPrefix) {
+		return nil
+	}
+	_, err := regexp.Compile(strings.TrimPrefix(modelID, modelRegexPrefix))
+	return err
+}
+
+// ModelFamily 返回模型族标识，如 anthropic/claude-sonnet-4-5-20250929 → claude-sonnet-4-5。
+func ModelFamily(modelID string) string {
+	id := strings.ToLower(strings.TrimSpace(modelID))
+	if i := strings.LastIndex(id, "/"); i >= 0 {
+		id = id[i+1:]
+	}
+	id = strings.TrimPrefix(id, "anthropic.")
+	if m := modelFamilyRe.FindStringSubmatch(id); m != nil {
+		id = m[1]
+	}
+	return id
+}
+
+// matchModelPricing 在已启用的定价条目中按模式与同族规则查找，未命中返回 nil。
+func matchModelPricing(entries []ModelPricingRecord, modelID string) *ModelPricingRecord {
+	type candidate struct {
+		rec  ModelPricingRecord
+		tier int
+		spec int
+	}
+	var candidates []candidate
+	family := ModelFamily(modelID)
+	for _, e := range entries {
+		switch {
+		case strings.HasPrefix(e.ModelID, modelRegexPrefix):
+			if re := compileModelPattern(e.ModelID); re != nil && re.MatchString(modelID) {
+				candidates = append(candidates, candidate{e, 1, len(e.ModelID)})
+			}
+		case IsModelPattern(e.ModelID):
+			if re := compileModelPattern(e.ModelID); re != nil && re.MatchString(modelID) {
+				literal := len(e.ModelID) - strings.Count(e.ModelID, "*") - strings.Count(e.ModelID, "?")
+				candidates = append(candidates, candidate{e, 0, literal})
+			}
+		case family != "" && ModelFamily(e.ModelID) == family:
+			candidates = append(candidates, candidate{e, 2, 0})
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.tier != b.tier {
+			return a.tier < b.tier
+		}
+		if a.spec != b.spec {
+			return a.spec > b.spec
+		}
+		if a.tier == 2 {
+			return a.rec.ModelID > b.rec.ModelID
+		}
+		return a.rec.ModelID < b.rec.ModelID
+	})
+	return &candidates[0].rec
+}
+
+// ResolveModelPricing 按精确 ID、glob、正则、同族的顺序查找模型定价，均未命中返回 ErrNotFound。
+func (s *Store) ResolveModelPricing(ctx context.Context, modelID string) (*ModelPricingRecord, error) {
+	snap, err := s.pricingSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if m := snap.resolve(modelID); m != nil {
+		return m, nil
+	}
+	return nil, ErrNotFound
+}
+
+// ensureUsageLogUnpriced 为 usage_logs 补充未定价标记列。新增列时将有 token 用量、费用为 0
+// 且模型没有任何定价条目（含模式与同族匹配）的历史记录标记为未定价，便于补录价格后重新计费；
+// 已有定价但费用为 0 的记录（如定价为 0 的模型）不做标记。
+func (s *Store) ensureUsageLogUnpriced(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	exists, err := s.columnExists(ctx, "usage_logs", "unpriced")
+	if err != nil || exists {
+		return err
+	}
+	stmt := `ALTER TABLE usage_logs ADD COLUMN unpriced BOOLEAN NOT NULL DEFAULT FALSE AFTER pricing_rule_id`
+	if s.IsSQLite() {
+		stmt = `ALTER TABLE usage_logs ADD COLUMN unpriced INTEGER NOT NULL DEFAULT 0`
+	}
+	if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+		return err
+	}
+
+	const zeroCost = ` WHERE cost_usd = 0 AND model_id <> '' AND (input_tokens + output_tokens + cache_creation_tokens + cache_read_tokens) > 0`
+	rows, err := s.db.QueryContext(ctx, `SELECT DISTINCT model_id FROM usage_logs`+zeroCost)
+	if err != nil {
+		return err
+	}
+	var models []string
+	for rows.Next() {
+		var model string
+		if err := rows.Scan(&model); err != nil {
+			rows.Close()
+			return err
+		}
+		models = append(models, model)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(models) == 0 {
+		return err
+	}
+	snap, err := s.loadPricingSnapshot(ctx)
+	if err != nil {
+		return err
+	}
+	for _, model := range models {
+		if snap.resolve(model) != nil {
+			continue
+		}
+		if _, err := s.db.ExecContext(ctx, `UPDATE usage_logs SET unpriced = 1`+zeroCost+` AND model_id = ?`, model); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListUnpricedModels 汇总 [from, to) 内被标记为未定价的使用日志（按模型），零值时间表示不限。
+func (s *Store) ListUnpricedModels(ctx context.Context, from, to time.Time) ([]UnpricedModel, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	where := " WHERE unpriced = 1"
+	var args []interface{}
+	if !from.IsZero() {
+		where += " AND created_at >= ?"
+		args = append(args, from.UTC())
+	}
+	if !to.IsZero() {
+		where += " AND created_at < ?"
+		args = append(args, to.UTC())
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT model_id, COUNT(*), MAX(created_at),
+		COALESCE(SUM(input_tokens), 0), COALESCE(SUM(output_tokens), 0),
+		COALESCE(SUM(cache_creation_tokens), 0), COALESCE(SUM(cache_read_tokens), 0)
+		FROM usage_logs`+where+` GROUP BY model_id ORDER BY COUNT(*) DESC, model_id ASC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var results []UnpricedModel
+	for rows.Next() {
+		var m UnpricedModel
+		var lastSeen any
+		if err := rows.Scan(&m.ModelID, &m.Requests, &lastSeen, &m.InputTokens, &m.OutputTokens, &m.CacheCreationTokens, &m.CacheReadTokens); err != nil {
+			return nil, err
+		}
+		if m.LastSeen, err = parseAggregateTime(lastSeen); err != nil {
+			return nil, err
+		}
+		results = append(results, m)
+	}
+	return results, rows.Err()
+}
+
+// aggregateTimeLayouts 聚合函数返回的时间文本格式：SQLite 驱动写入 time.Time 的格式（time.Time.String）、
+// RFC3339，以及未开启 parseTime 的 MySQL DATETIME。
+var aggregateTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999 -0700 MST",
+	"2006-01-02 15:04:05.999999999-07:00",
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999",
+}
+
+// parseAggregateTime 解析 MAX(created_at) 等聚合结果。聚合后的列没有声明类型，
+// SQLite 返回文本，MySQL（parseTime=true）返回 time.Time，两者统一为 UTC 时间。
+func parseAggregateTime(v any) (time.Time, error) {
+	var text string
+	switch t := v.(type) {
+	case time.Time:
+		return t.UTC(), nil
+	case string:
+		text = t
+	case []byte:
+		text = string(t)
+	case nil:
+		return time.Time{}, nil
+	default:
+		return time.Time{}, fmt.Errorf("unexpected aggregate time type %T", v)
+	}
+	for _, layout := range aggregateTimeLayouts {
+		if t, err := time.Parse(layout, text); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("parse aggregate time %q", text)
+}
+
+// RepriceUsageLogs 按日志自身的创建时间重新计算官方价格与节点实际成本，并更新命中规则与未定价标记。
+// 默认只处理未定价记录，IncludePriced 为 true 时处理范围内全部记录。
+func (s *Store) RepriceUsageLogs(ctx context.Context, params RepriceParams) (RepriceResult, error) {
+	const batch = 500
+	var result RepriceResult
+	var lastID int64
+	for {
+		query := `SELECT id, node_id, model_id, input_tokens, output_tokens, cache_creation_tokens, cache_read_tokens, created_at
+			FROM usage_logs WHERE id > ? AND model_id <> ''`
+		args := []interface{}{lastID}
+		if !params.IncludePriced {
+			query += " AND unpriced = 1"
+		}
+		if params.ModelID != "" {
+			query += " AND model_id = ?"
+			args = append(args, params.ModelID)
+		}
+		if !params.From.IsZero() {
+			query += " AND created_at >= ?"
+			args = append(args, params.From.UTC())
+		}
+		if !params.To.IsZero() {
+			query += " AND created_at < ?"
+			args = append(args, params.To.UTC())
+		}
+		query += " ORDER BY id ASC LIMIT ?"
+		args = append(args, batch)
+
+		type pending struct {
+			id      int64
+			nodeID  string
+			modelID string
+			usage   TokenUsage
+			at      time.Time
+		}
+		qctx, cancel := withTimeout(ctx)
+		rows, err := s.db.QueryContext(qctx, query, args...)
+		if err != nil {
+			cancel()
+			return result, err
+		}
+		var items []pending
+		for rows.Next() {
+			var p pending
+			if err := rows.Scan(&p.id, &p.nodeID, &p.modelID, &p.usage.InputTokens, &p.usage.OutputTokens, &p.usage.CacheCreationTokens, &p.usage.CacheReadTokens, &p.at); err != nil {
+				rows.Close()
+				cancel()
+				return result, err
+			}
+			items = append(items, p)
+		}
+		rows.Close()
+		cancel()
+		if err := rows.Err(); err != nil {
+			return result, err
+		}
+
+		for _, p := range items {
+			cost, err := s.CalculateNodeCost(ctx, p.nodeID, p.modelID, p.usage, p.at)
+			if err != nil {
+				return result, err
+			}
+			uctx, cancel := withTimeout(ctx)
+			_, err = s.db.ExecContext(uctx, `UPDATE usage_logs SET cost_usd = ?, actual_cost_usd = ?, pricing_rule_id = ?, unpriced = ? WHERE id = ?`,
+				cost.CostUSD, cost.ActualCostUSD, cost.PricingRuleID, cost.Unpriced, p.id)
+			cancel()
+			if err != nil {
+				return result, err
+			}
+			result.Updated++
+			if cost.Unpriced {
+				result.StillUnpriced++
+			}
+			lastID = p.id
+		}
+		if len(items) < batch {
+			return result, nil
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+)
+
+// ensurePricingRulesTable 创建定价规则表，并为 usage_logs 补充命中规则列。
+func (s *Store) ensurePricingRulesTable(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	var table, column string
+	if s.IsSQLite() {
+		table = `CREATE TABLE IF NOT EXISTS pricing_rules (
+			id TEXT PRIMARY KEY,
+			model_id TEXT NOT NULL,
+			name TEXT NOT NULL DEFAULT '',
+			input_threshold INTEGER NOT NULL DEFAULT 0,
+			input_price_mtok REAL NOT NULL DEFAULT 0,
+			output_price_mtok REAL NOT NULL DEFAULT 0,
+			cache_write_price_mtok REAL NOT NULL DEFAULT 0,
+			cache_read_price_mtok REAL NOT NULL DEFAULT 0,
+			multiplier REAL NOT NULL DEFAULT 1,
+			priority INTEGER NOT NULL DEFAULT 0,
+			effective_from DATETIME NULL,
+			effective_to DATETIME NULL,
+			is_active INTEGER DEFAULT 1,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`
+		column = `ALTER TABLE usage_logs ADD COLUMN pricing_rule_id TEXT`
+	} else {
+		table = `CREATE TABLE IF NOT EXISTS pricing_rules (
+			id VARCHAR(64) PRIMARY KEY,
+			model_id VARCHAR(128) NOT NULL,
+			name VARCHAR(255) NOT NULL DEFAULT '',
+			input_threshold BIGINT NOT NULL DEFAULT 0,
+			input_price_mtok DECIMAL(10,6) NOT NULL DEFAULT 0,
+			output_price_mtok DECIMAL(10,6) NOT NULL DEFAULT 0,
+			cache_write_price_mtok DECIMAL(10,6) NOT NULL DEFAULT 0,
+			cache_read_price_mtok DECIMAL(10,6) NOT NULL DEFAULT 0,
+			multiplier DECIMAL(10,6) NOT NULL DEFAULT 1,
+			priority INT NOT NULL DEFAULT 0,
+			effective_from DATETIME NULL,
+			effective_to DATETIME NULL,
+			is_active BOOLEAN DEFAULT TRUE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			KEY idx_pricing_rules_model (model_id)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`
+		column = `ALTER TABLE usage_logs ADD COLUMN pricing_rule_id VARCHAR(64) AFTER cost_usd`
+	}
+	if _, err := s.db.ExecContext(ctx, table); err != nil {
+		return err
+	}
+	if s.IsSQLite() {
+		_, _ = s.db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_pricing_rules_model ON pricing_rules(model_id)`)
+	}
+	exists, err := s.columnExists(ctx, "usage_logs", "pricing_rule_id")
+	if err != nil || exists {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, column)
+	return err
+}
+
+// ListPricingRules 列出定价规则，modelID 为空时返回全部模型的规则。
+func (s *Store) ListPricingRules(ctx context.Context, modelID string) ([]PricingRuleRecord, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT id, model_id, name, input_threshold, input_price_mtok, output_price_mtok, cache_write_price_mtok, cache_read_price_mtok,
+		multiplier, priority, effective_from, effective_to, is_active, created_at, updated_at FROM pricing_rules`
+	var args []interface{}
+	if modelID != "" {
+		query += " WHERE model_id = ?"
+		args = append(args, modelID)
+	}
+	query += " ORDER BY model_id ASC, priority DESC, input_threshold DESC"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []PricingRuleRecord
+	for rows.Next() {
+		var (
+			r        PricingRuleRecord
+			from, to sql.NullTime
+		)
+		if err := rows.Scan(&r.ID, &r.ModelID, &r.Name, &r.InputThreshold, &r.InputPriceMTok, &r.OutputPriceMTok, &r.CacheWritePriceMTok, &r.CacheReadPriceMTok,
+			&r.Multiplier, &r.Priority, &from, &to, &r.IsActive, &r.CreatedAt, &r.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if from.Valid {
+			t := from.Time.UTC()
+			r.EffectiveFrom = &t
+		}
+		if to.Valid {
+			t := to.Time.UTC()
+			r.EffectiveTo = &t
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// UpsertPricingRule 创建或按 ID 更新定价规则，返回规则 ID。
+func (s *Store) UpsertPricingRule(ctx context.Context, r PricingRuleRecord) (string, error) {
+	if r.ModelID == "" {
+		return "", errors.New("model_id required")
+	}
+	if r.ID == "" {
+		r.ID = genUUID()
+	}
+	var from, to interface{}
+	if r.EffectiveFrom != nil {
+		from = r.EffectiveFrom.UTC()
+	}
+	if r.EffectiveTo != nil {
+		to = r.EffectiveTo.UTC()
+	}
+
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	stmt := `INSERT INTO pricing_rules (id, model_id, name, input_threshold, input_price_mtok, output_price_mtok, cache_write_price_mtok, cache_read_price_mtok,
+			multiplier, priority, effective_from, effective_to, is_active)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	if s.IsSQLite() {
+		stmt += `
+		ON CONFLICT(id) DO UPDATE SET
+			model_id = excluded.model_id,
+			name = excluded.name,
+			input_threshold = excluded.input_threshold,
+			input_price_mtok = excluded.input_price_mtok,
+			output_price_mtok = excluded.output_price_mtok,
+			cache_write_price_mtok = excluded.cache_write_price_mtok,
+			cache_read_price_mtok = excluded.cache_read_price_mtok,
+			multiplier = excluded.multiplier,
+			priority = excluded.priority,
+			effective_from = excluded.effective_from,
+			effective_to = excluded.effective_to,
+			is_active = excluded.is_active,
+			updated_at = CURRENT_TIMESTAMP`
+	} else {
+		stmt += `
+		ON DUPLICATE KEY UPDATE
+			model_id = VALUES(model_id),
+			name = VALUES(name),
+			input_threshold = VALUES(input_threshold),
+			input_price_mtok = VALUES(input_price_mtok),
+			output_price_mtok = VALUES(output_price_mtok),
+			cache_write_price_mtok = VALUES(cache_write_price_mtok),
+			cache_read_price_mtok = VALUES(cache_read_price_mtok),
+			multiplier = VALUES(multiplier),
+			priority = VALUES(priority),
+			effective_from = VALUES(effective_from),
+			effective_to = VALUES(effective_to),
+			is_active = VALUES(is_active)`
+	}
+	defer s.InvalidatePricingCache()
+	_, err := s.db.ExecContext(ctx, stmt,
+		r.ID, r.ModelID, r.Name, r.InputThreshold, r.InputPriceMTok, r.OutputPriceMTok, r.CacheWritePriceMTok, r.CacheReadPriceMTok,
+		r.Multiplier, r.Priority, from, to, r.IsActive)
+	if err != nil {
+		return "", err
+	}
+	return r.ID, nil
+}
+
+// DeletePricingRule 删除定价规则。
+func (s *Store) DeletePricingRule(ctx context.Context, id string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	defer s.InvalidatePricingCache()
+	result, err := s.db.ExecContext(ctx, "DELETE FROM pricing_rules WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Matches 判断规则在 at 时刻、输入 tokens 总数为 inputTokens 时是否生效。
+func (r PricingRuleRecord) Matches(at time.Time, inputTokens int64) bool {
+	if !r.IsActive || (r.InputThreshold > 0 && inputTokens <= r.InputThreshold) {
+		return false
+	}
+	if r.EffectiveFrom != nil && at.Before(*r.EffectiveFrom) {
+		return false
+	}
+	if r.EffectiveTo != nil && !at.Before(*r.EffectiveTo) {
+		return false
+	}
+	return true
+}
+
+// matchPricingRules 返回命中的规则，按优先级、输入阈值、生效起点从高到低排序，
+// 仍相同时按 ID 排序保证结果确定。
+func matchPricingRules(rules []PricingRuleRecord, at time.Time, inputTokens int64) []PricingRuleRecord {
+	var matched []PricingRuleRecord
+	for _, r := range rules {
+		if r.Matches(at, inputTokens) {
+			matched = append(matched, r)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		if a.InputThreshold != b.InputThreshold {
+			return a.InputThreshold > b.InputThreshold
+		}
+		af, bf := effectiveFrom(a), effectiveFrom(b)
+		if !af.Equal(bf) {
+			return af.After(bf)
+		}
+		return a.ID < b.ID
+	})
+	return matched
+}
+
+func effectiveFrom(r PricingRuleRecord) time.Time {
+	if r.EffectiveFrom == nil {
+		return time.Time{}
+	}
+	return *r.EffectiveFrom
+}
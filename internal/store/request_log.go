@@ -0,0 +1,288 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// requestLogColumns 写入顺序与 requestLogArgs 一致。
+const requestLogColumns = `request_id, client_request_id, account_id, key_hint, client_ip, user_agent, session_id, model_id, stream, status, error_type,
+	node_id, attempts, attempt_trace, ttfb_ms, duration_ms, input_tokens, output_tokens, cache_creation_tokens, cache_read_tokens, created_at`
+
+const requestLogColumnCount = 21
+
+// requestLogInsertChunk 单条 INSERT 的最大行数，避免超出 SQLite 占位符上限。
+const requestLogInsertChunk = 40
+
+// ensureRequestLogsTable 创建请求审计日志表。
+func (s *Store) ensureRequestLogsTable(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	if s.IsSQLite() {
+		if _, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS request_logs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			request_id TEXT NOT NULL DEFAULT '',
+			client_request_id TEXT NOT NULL DEFAULT '',
+			account_id TEXT NOT NULL,
+			key_hint TEXT NOT NULL DEFAULT '',
+			client_ip TEXT NOT NULL DEFAULT '',
+			user_agent TEXT NOT NULL DEFAULT '',
+			session_id TEXT NOT NULL DEFAULT '',
+			model_id TEXT NOT NULL DEFAULT '',
+			stream INTEGER NOT NULL DEFAULT 0,
+			status INTEGER NOT NULL DEFAULT 0,
+			error_type TEXT NOT NULL DEFAULT '',
+			node_id TEXT NOT NULL DEFAULT '',
+			attempts INTEGER NOT NULL DEFAULT 0,
+			attempt_trace TEXT,
+			ttfb_ms INTEGER NOT NULL DEFAULT 0,
+			duration_ms INTEGER NOT NULL DEFAULT 0,
+			input_tokens INTEGER NOT NULL DEFAULT 0,
+			output_tokens INTEGER NOT NULL DEFAULT 0,
+			cache_creation_tokens INTEGER NOT NULL DEFAULT 0,
+			cache_read_tokens INTEGER NOT NULL DEFAULT 0,
+			created_at DATETIME NOT NULL
+		)`); err != nil {
+			return err
+		}
+		for _, stmt := range []string{
+			`CREATE INDEX IF NOT EXISTS idx_request_logs_account_time ON request_logs(account_id, created_at)`,
+			`CREATE INDEX IF NOT EXISTS idx_request_logs_created ON request_logs(created_at)`,
+			`CREATE INDEX IF NOT EXISTS idx_request_logs_request ON request_logs(request_id)`,
+			`CREATE INDEX IF NOT EXISTS idx_request_logs_session ON request_logs(session_id)`,
+		} {
+			if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+				return err
+			}
+		}
+		return s.migrateRequestLogClientID(ctx)
+	}
+	if _, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS request_logs (
+		id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+		request_id VARCHAR(128) NOT NULL DEFAULT '',
+		client_request_id VARCHAR(128) NOT NULL DEFAULT '',
+		account_id VARCHAR(64) NOT NULL,
+		key_hint VARCHAR(32) NOT NULL DEFAULT '',
+		client_ip VARCHAR(64) NOT NULL DEFAULT '',
+		user_agent VARCHAR(512) NOT NULL DEFAULT '',
+		session_id VARCHAR(128) NOT NULL DEFAULT '',
+		model_id VARCHAR(128) NOT NULL DEFAULT '',
+		stream BOOLEAN NOT NULL DEFAULT FALSE,
+		status INT NOT NULL DEFAULT 0,
+		error_type VARCHAR(32) NOT NULL DEFAULT '',
+		node_id VARCHAR(64) NOT NULL DEFAULT '',
+		attempts INT NOT NULL DEFAULT 0,
+		attempt_trace TEXT,
+		ttfb_ms BIGINT NOT NULL DEFAULT 0,
+		duration_ms BIGINT NOT NULL DEFAULT 0,
+		input_tokens BIGINT NOT NULL DEFAULT 0,
+		output_tokens BIGINT NOT NULL DEFAULT 0,
+		cache_creation_tokens BIGINT NOT NULL DEFAULT 0,
+		cache_read_tokens BIGINT NOT NULL DEFAULT 0,
+		created_at DATETIME(3) NOT NULL,
+		KEY idx_request_logs_account_time (account_id, created_at),
+		KEY idx_request_logs_created (created_at),
+		KEY idx_request_logs_request (request_id),
+		KEY idx_request_logs_client_request (client_request_id),
+		KEY idx_request_logs_session (session_id)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`); err != nil {
+		return err
+	}
+	return s.migrateRequestLogClientID(ctx)
+}
+
+// migrateRequestLogClientID 为旧表补齐 client_request_id 列及索引。
+func (s *Store) migrateRequestLogClientID(ctx context.Context) error {
+	exists, err := s.columnExists(ctx, "request_logs", "client_request_id")
+	if err != nil {
+		return err
+	}
+	stmts := []string{
+		`ALTER TABLE request_logs ADD COLUMN client_request_id VARCHAR(128) NOT NULL DEFAULT ''`,
+		`CREATE INDEX idx_request_logs_client_request ON request_logs(client_request_id)`,
+	}
+	if s.IsSQLite() {
+		stmts = []string{`CREATE INDEX IF NOT EXISTS idx_request_logs_client_request ON request_logs(client_request_id)`}
+		if !exists {
+			stmts = append([]string{`ALTER TABLE request_logs ADD COLUMN client_request_id TEXT NOT NULL DEFAULT ''`}, stmts...)
+		}
+	} else if exists {
+		return nil
+	}
+	for _, stmt := range stmts {
+		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// requestLogArgs 按 MySQL 列宽截断字符串字段，避免严格模式下单条超长值导致整批写入失败。
+func requestLogArgs(rec RequestLogRecord) []interface{} {
+	return []interface{}{
+		truncateRunes(rec.RequestID, 128), truncateRunes(rec.ClientRequestID, 128), truncateRunes(normalizeAccount(rec.AccountID), 64), truncateRunes(rec.KeyHint, 32),
+		truncateRunes(rec.ClientIP, 64), truncateRunes(rec.UserAgent, 512), truncateRunes(rec.SessionID, 128),
+		truncateRunes(rec.ModelID, 128), rec.Stream, rec.Status, truncateRunes(rec.ErrorType, 32), truncateRunes(rec.NodeID, 64),
+		rec.Attempts, rec.AttemptTrace,
+		rec.TTFBMs, rec.DurationMs, rec.InputTokens, rec.OutputTokens, rec.CacheCreationTokens, rec.CacheReadTokens,
+		rec.CreatedAt.UTC(),
+	}
+}
+
+// InsertRequestLogs 在一个事务内批量写入请求审计日志。
+func (s *Store) InsertRequestLogs(ctx context.Context, recs []RequestLogRecord) error {
+	if s == nil || s.db == nil {
+		return errors.New("store not initialized")
+	}
+	if len(recs) == 0 {
+		return nil
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	row := "(" + strings.TrimSuffix(strings.Repeat("?,", requestLogColumnCount), ",") + ")"
+	now := time.Now().UTC()
+	for start := 0; start < len(recs); start += requestLogInsertChunk {
+		end := start + requestLogInsertChunk
+		if end > len(recs) {
+			end = len(recs)
+		}
+		chunk := recs[start:end]
+		args := make([]interface{}, 0, len(chunk)*requestLogColumnCount)
+		for _, rec := range chunk {
+			if rec.CreatedAt.IsZero() {
+				rec.CreatedAt = now
+			}
+			args = append(args, requestLogArgs(rec)...)
+		}
+		query := `INSERT INTO request_logs (` + requestLogColumns + `) VALUES ` +
+			strings.TrimSuffix(strings.Repeat(row+",", len(chunk)), ",")
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// requestLogWhere 构建查询条件（不含 LIMIT/OFFSET）。
+func requestLogWhere(q RequestLogQuery) (string, []interface{}) {
+	b := &strings.Builder{}
+	b.WriteString(" WHERE 1=1")
+	var args []interface{}
+	add := func(cond string, v interface{}) {
+		b.WriteString(" AND " + cond)
+		args = append(args, v)
+	}
+	if q.AccountID != "" {
+		add("account_id = ?", normalizeAccount(q.AccountID))
+	}
+	if q.NodeID != "" {
+		add("node_id = ?", q.NodeID)
+	}
+	if q.ModelID != "" {
+		add("model_id = ?", q.ModelID)
+	}
+	if q.SessionID != "" {
+		add("session_id = ?", q.SessionID)
+	}
+	if q.RequestID != "" {
+		add("request_id = ?", q.RequestID)
+	}
+	if q.ClientRequestID != "" {
+		add("client_request_id = ?", q.ClientRequestID)
+	}
+	if q.ClientIP != "" {
+		add("client_ip = ?", q.ClientIP)
+	}
+	if q.ErrorType != "" {
+		add("error_type = ?", q.ErrorType)
+	}
+	if q.Status != 0 {
+		add("status = ?", q.Status)
+	}
+	if q.FailedOnly {
+		b.WriteString(" AND status <> 200")
+	}
+	if !q.From.IsZero() {
+		add("created_at >= ?", q.From.UTC())
+	}
+	if !q.To.IsZero() {
+		add("created_at < ?", q.To.UTC())
+	}
+	return b.String(), args
+}
+
+// QueryRequestLogs 按条件分页查询请求审计日志（按时间倒序），同时返回符合条件的总数。
+func (s *Store) QueryRequestLogs(ctx context.Context, q RequestLogQuery) ([]RequestLogRecord, int64, error) {
+	if s == nil || s.db == nil {
+		return nil, 0, errors.New("store not initialized")
+	}
+	if q.Limit <= 0 {
+		q.Limit = 100
+	}
+	where, args := requestLogWhere(q)
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var total int64
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM request_logs`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT id, ` + requestLogColumns + ` FROM request_logs` + where + ` ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?`
+	rows, err := s.db.QueryContext(ctx, query, append(args, q.Limit, q.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	var out []RequestLogRecord
+	for rows.Next() {
+		var rec RequestLogRecord
+		var trace *string
+		if err := rows.Scan(&rec.ID, &rec.RequestID, &rec.ClientRequestID, &rec.AccountID, &rec.KeyHint, &rec.ClientIP, &rec.UserAgent, &rec.SessionID,
+			&rec.ModelID, &rec.Stream, &rec.Status, &rec.ErrorType, &rec.NodeID, &rec.Attempts, &trace,
+			&rec.TTFBMs, &rec.DurationMs, &rec.InputTokens, &rec.OutputTokens, &rec.CacheCreationTokens, &rec.CacheReadTokens,
+			&rec.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		if trace != nil {
+			rec.AttemptTrace = *trace
+		}
+		rec.CreatedAt = rec.CreatedAt.UTC()
+		out = append(out, rec)
+	}
+	return out, total, rows.Err()
+}
+
+// CleanupRequestLogs 删除 before 之前的审计日志，返回删除条数。
+func (s *Store) CleanupRequestLogs(ctx context.Context, before time.Time) (int64, error) {
+	if s == nil || s.db == nil {
+		return 0, errors.New("store not initialized")
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	res, err := s.db.ExecContext(ctx, `DELETE FROM request_logs WHERE created_at < ?`, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// truncateRunes 按字符截断字符串，避免超出列宽。
+func truncateRunes(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	r := []rune(s)
+	if len(r) <= max {
+		return s
+	}
+	return string(r[:max])
+}
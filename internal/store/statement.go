@@ -0,0 +1,414 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// StatementPeriodLayout 账期格式。
+const StatementPeriodLayout = "2006-01"
+
+// ensureStatementTables 创建月度账单快照与账单调整表，并为 usage_logs 补充代理 Key 提示列。
+func (s *Store) ensureStatementTables(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	var stmts []string
+	if s.IsSQLite() {
+		stmts = []string{
+			`CREATE TABLE IF NOT EXISTS statements (
+				id TEXT PRIMARY KEY,
+				account_id TEXT NOT NULL,
+				period TEXT NOT NULL,
+				version INTEGER NOT NULL,
+				timezone TEXT NOT NULL,
+				period_start DATETIME NOT NULL,
+				period_end DATETIME NOT NULL,
+				total_requests INTEGER NOT NULL DEFAULT 0,
+				input_tokens INTEGER NOT NULL DEFAULT 0,
+				output_tokens INTEGER NOT NULL DEFAULT 0,
+				cache_creation_tokens INTEGER NOT NULL DEFAULT 0,
+				cache_read_tokens INTEGER NOT NULL DEFAULT 0,
+				usage_cost_usd REAL NOT NULL DEFAULT 0,
+				adjustments_usd REAL NOT NULL DEFAULT 0,
+				credits_usd REAL NOT NULL DEFAULT 0,
+				amount_due_usd REAL NOT NULL DEFAULT 0,
+				detail TEXT NOT NULL,
+				generated_by TEXT NOT NULL DEFAULT '',
+				created_at DATETIME NOT NULL,
+				UNIQUE (account_id, period, version)
+			)`,
+			`CREATE TABLE IF NOT EXISTS billing_adjustments (
+				id TEXT PRIMARY KEY,
+				account_id TEXT NOT NULL,
+				period TEXT NOT NULL,
+				kind TEXT NOT NULL,
+				amount_usd REAL NOT NULL DEFAULT 0,
+				description TEXT NOT NULL DEFAULT '',
+				created_at DATETIME NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_billing_adjustments_account_period ON billing_adjustments(account_id, period)`,
+		}
+	} else {
+		stmts = []string{
+			`CREATE TABLE IF NOT EXISTS statements (
+				id VARCHAR(64) PRIMARY KEY,
+				account_id VARCHAR(64) NOT NULL,
+				period CHAR(7) NOT NULL,
+				version INT NOT NULL,
+				timezone VARCHAR(64) NOT NULL,
+				period_start DATETIME(3) NOT NULL,
+				period_end DATETIME(3) NOT NULL,
+				total_requests BIGINT NOT NULL DEFAULT 0,
+				input_tokens BIGINT NOT NULL DEFAULT 0,
+				output_tokens BIGINT NOT NULL DEFAULT 0,
+				cache_creation_tokens BIGINT NOT NULL DEFAULT 0,
+				cache_read_tokens BIGINT NOT NULL DEFAULT 0,
+				usage_cost_usd DECIMAL(16,8) NOT NULL DEFAULT 0,
+				adjustments_usd DECIMAL(16,8) NOT NULL DEFAULT 0,
+				credits_usd DECIMAL(16,8) NOT NULL DEFAULT 0,
+				amount_due_usd DECIMAL(16,8) NOT NULL DEFAULT 0,
+				detail MEDIUMTEXT NOT NULL,
+				generated_by VARCHAR(32) NOT NULL DEFAULT '',
+				created_at DATETIME(3) NOT NULL,
+				UNIQUE KEY uniq_statements_account_period_version (account_id, period, version)
+			) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+			`CREATE TABLE IF NOT EXISTS billing_adjustments (
+				id VARCHAR(64) PRIMARY KEY,
+				account_id VARCHAR(64) NOT NULL,
+				period CHAR(7) NOT NULL,
+				kind VARCHAR(16) NOT NULL,
+				amount_usd DECIMAL(16,8) NOT NULL DEFAULT 0,
+				description VARCHAR(512) NOT NULL DEFAULT '',
+				created_at DATETIME(3) NOT NULL,
+				KEY idx_billing_adjustments_account_period (account_id, period)
+			) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+		}
+	}
+	for _, stmt := range stmts {
+		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	exists, err := s.columnExists(ctx, "usage_logs", "key_hint")
+	if err != nil || exists {
+		return err
+	}
+	stmt := `ALTER TABLE usage_logs ADD COLUMN key_hint VARCHAR(32) NOT NULL DEFAULT '' AFTER unpriced`
+	if s.IsSQLite() {
+		stmt = `ALTER TABLE usage_logs ADD COLUMN key_hint TEXT NOT NULL DEFAULT ''`
+	}
+	_, err = s.db.ExecContext(ctx, stmt)
+	return err
+}
+
+// StatementPeriodRange 解析账期（2006-01），返回 loc 时区下该月的起止时间 [start, end)。
+func StatementPeriodRange(period string, loc *time.Location) (time.Time, time.Time, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	t, err := time.ParseInLocation(StatementPeriodLayout, period, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid period %q, want YYYY-MM", period)
+	}
+	return t, t.AddDate(0, 1, 0), nil
+}
+
+// ListBillingAdjustments 列出账号的账单调整，period 为空时返回全部账期。
+func (s *Store) ListBillingAdjustments(ctx context.Context, accountID, period string) ([]BillingAdjustmentRecord, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	query := `SELECT id, account_id, period, kind, amount_usd, description, created_at FROM billing_adjustments WHERE 1=1`
+	var args []interface{}
+	if accountID != "" {
+		query += " AND account_id = ?"
+		args = append(args, normalizeAccount(accountID))
+	}
+	if period != "" {
+		query += " AND period = ?"
+		args = append(args, period)
+	}
+	query += " ORDER BY period DESC, created_at ASC, id ASC"
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var results []BillingAdjustmentRecord
+	for rows.Next() {
+		var a BillingAdjustmentRecord
+		if err := rows.Scan(&a.ID, &a.AccountID, &a.Period, &a.Kind, &a.AmountUSD, &a.Description, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		results = append(results, a)
+	}
+	return results, rows.Err()
+}
+
+// CreateBillingAdjustment 新增账单调整，返回记录 ID。已生成的账单不受影响，需重新生成才会计入。
+func (s *Store) CreateBillingAdjustment(ctx context.Context, a BillingAdjustmentRecord) (string, error) {
+	if a.AccountID == "" {
+		return "", errors.New("account_id required")
+	}
+	if _, _, err := StatementPeriodRange(a.Period, nil); err != nil {
+		return "", err
+	}
+	if a.Kind != AdjustmentKindAdjustment && a.Kind != AdjustmentKindCredit {
+		return "", fmt.Errorf("invalid kind %q", a.Kind)
+	}
+	if a.ID == "" {
+		a.ID = genUUID()
+	}
+	if a.CreatedAt.IsZero() {
+		a.CreatedAt = time.Now()
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	_, err := s.db.ExecContext(ctx, `INSERT INTO billing_adjustments (id, account_id, period, kind, amount_usd, description, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		a.ID, normalizeAccount(a.AccountID), a.Period, a.Kind, a.AmountUSD, truncateRunes(a.Description, 512), a.CreatedAt.UTC())
+	return a.ID, err
+}
+
+// DeleteBillingAdjustment 删除账单调整。
+func (s *Store) DeleteBillingAdjustment(ctx context.Context, id string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	res, err := s.db.ExecContext(ctx, `DELETE FROM billing_adjustments WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// statementDetail 账单快照中以 JSON 保存的明细部分。
+type statementDetail struct {
+	AccountName string                    `json:"account_name"`
+	ByModel     []StatementLine           `json:"by_model"`
+	ByNode      []StatementLine           `json:"by_node"`
+	ByAPIKey    []StatementLine           `json:"by_api_key"`
+	Adjustments []BillingAdjustmentRecord `json:"adjustments"`
+}
+
+// GenerateStatement 汇总账期内的使用日志与账单调整，写入新版本的账单快照并返回。
+// 已有快照不会被修改，同一账期重复生成时版本号递增。
+func (s *Store) GenerateStatement(ctx context.Context, params StatementParams) (*StatementRecord, error) {
+	if params.AccountID == "" {
+		return nil, errors.New("account_id required")
+	}
+	loc := params.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	start, end, err := StatementPeriodRange(params.Period, loc)
+	if err != nil {
+		return nil, err
+	}
+	accountID := normalizeAccount(params.AccountID)
+	rec := &StatementRecord{
+		ID:          genUUID(),
+		AccountID:   accountID,
+		Period:      params.Period,
+		Timezone:    loc.String(),
+		PeriodStart: start,
+		PeriodEnd:   end,
+		GeneratedBy: params.GeneratedBy,
+		CreatedAt:   time.Now().UTC(),
+	}
+	if acc, err := s.GetAccountByID(ctx, accountID); err == nil {
+		rec.AccountName = acc.Name
+	} else if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	if rec.ByModel, err = s.statementLines(ctx, "model_id", accountID, start, end); err != nil {
+		return nil, err
+	}
+	if rec.ByNode, err = s.statementLines(ctx, "node_id", accountID, start, end); err != nil {
+		return nil, err
+	}
+	if rec.ByAPIKey, err = s.statementLines(ctx, "key_hint", accountID, start, end); err != nil {
+		return nil, err
+	}
+	if err := s.labelStatementNodes(ctx, rec.ByNode); err != nil {
+		return nil, err
+	}
+	rec.Totals.Key = "total"
+	for _, l := range rec.ByModel {
+		rec.Totals.Requests += l.Requests
+		rec.Totals.InputTokens += l.InputTokens
+		rec.Totals.OutputTokens += l.OutputTokens
+		rec.Totals.CacheCreationTokens += l.CacheCreationTokens
+		rec.Totals.CacheReadTokens += l.CacheReadTokens
+		rec.Totals.CostUSD += l.CostUSD
+	}
+	if rec.Adjustments, err = s.ListBillingAdjustments(ctx, accountID, params.Period); err != nil {
+		return nil, err
+	}
+	for _, a := range rec.Adjustments {
+		if a.Kind == AdjustmentKindCredit {
+			rec.CreditsUSD += a.AmountUSD
+		} else {
+			rec.AdjustmentsUSD += a.AmountUSD
+		}
+	}
+	rec.AmountDueUSD = rec.Totals.CostUSD + rec.AdjustmentsUSD - rec.CreditsUSD
+
+	detail, err := json.Marshal(statementDetail{
+		AccountName: rec.AccountName,
+		ByModel:     rec.ByModel,
+		ByNode:      rec.ByNode,
+		ByAPIKey:    rec.ByAPIKey,
+		Adjustments: rec.Adjustments,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if err := tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) + 1 FROM statements WHERE account_id = ? AND period = ?`,
+		accountID, params.Period).Scan(&rec.Version); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO statements (id, account_id, period, version, timezone, period_start, period_end,
+			total_requests, input_tokens, output_tokens, cache_creation_tokens, cache_read_tokens,
+			usage_cost_usd, adjustments_usd, credits_usd, amount_due_usd, detail, generated_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		rec.ID, rec.AccountID, rec.Period, rec.Version, rec.Timezone, start.UTC(), end.UTC(),
+		rec.Totals.Requests, rec.Totals.InputTokens, rec.Totals.OutputTokens, rec.Totals.CacheCreationTokens, rec.Totals.CacheReadTokens,
+		rec.Totals.CostUSD, rec.AdjustmentsUSD, rec.CreditsUSD, rec.AmountDueUSD, string(detail), rec.GeneratedBy, rec.CreatedAt); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// statementLines 按 column 分组汇总账期内的使用日志，按费用降序。
+func (s *Store) statementLines(ctx context.Context, column, accountID string, start, end time.Time) ([]StatementLine, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, `SELECT `+column+`, COUNT(*),
+		COALESCE(SUM(input_tokens), 0), COALESCE(SUM(output_tokens), 0),
+		COALESCE(SUM(cache_creation_tokens), 0), COALESCE(SUM(cache_read_tokens), 0),
+		COALESCE(SUM(cost_usd), 0) AS total_cost
+		FROM usage_logs WHERE account_id = ? AND created_at >= ? AND created_at < ?
+		GROUP BY `+column+` ORDER BY total_cost DESC, `+column+` ASC`, accountID, start.UTC(), end.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	lines := []StatementLine{}
+	for rows.Next() {
+		var l StatementLine
+		if err := rows.Scan(&l.Key, &l.Requests, &l.InputTokens, &l.OutputTokens, &l.CacheCreationTokens, &l.CacheReadTokens, &l.CostUSD); err != nil {
+			return nil, err
+		}
+		lines = append(lines, l)
+	}
+	return lines, rows.Err()
+}
+
+// labelStatementNodes 以当前节点名称填充节点明细的展示名，已删除的节点保留 ID。
+func (s *Store) labelStatementNodes(ctx context.Context, lines []StatementLine) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	for i := range lines {
+		var name sql.NullString
+		err := s.db.QueryRowContext(ctx, `SELECT name FROM nodes WHERE id = ?`, lines[i].Key).Scan(&name)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		lines[i].Label = name.String
+	}
+	return nil
+}
+
+const statementColumns = `id, account_id, period, version, timezone, period_start, period_end,
+	total_requests, input_tokens, output_tokens, cache_creation_tokens, cache_read_tokens,
+	usage_cost_usd, adjustments_usd, credits_usd, amount_due_usd, generated_by, created_at`
+
+func scanStatement(row interface{ Scan(dest ...any) error }, extra ...any) (*StatementRecord, error) {
+	var rec StatementRecord
+	dest := []any{&rec.ID, &rec.AccountID, &rec.Period, &rec.Version, &rec.Timezone, &rec.PeriodStart, &rec.PeriodEnd,
+		&rec.Totals.Requests, &rec.Totals.InputTokens, &rec.Totals.OutputTokens, &rec.Totals.CacheCreationTokens, &rec.Totals.CacheReadTokens,
+		&rec.Totals.CostUSD, &rec.AdjustmentsUSD, &rec.CreditsUSD, &rec.AmountDueUSD, &rec.GeneratedBy, &rec.CreatedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	rec.Totals.Key = "total"
+	return &rec, nil
+}
+
+// ListStatements 列出账单快照（不含明细），按账期与版本倒序。accountID、period 为空表示不限。
+func (s *Store) ListStatements(ctx context.Context, accountID, period string) ([]StatementRecord, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	query := `SELECT ` + statementColumns + ` FROM statements WHERE 1=1`
+	var args []interface{}
+	if accountID != "" {
+		query += " AND account_id = ?"
+		args = append(args, normalizeAccount(accountID))
+	}
+	if period != "" {
+		query += " AND period = ?"
+		args = append(args, period)
+	}
+	query += " ORDER BY period DESC, account_id ASC, version DESC"
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var results []StatementRecord
+	for rows.Next() {
+		rec, err := scanStatement(rows)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, *rec)
+	}
+	return results, rows.Err()
+}
+
+// GetStatement 按 ID 获取包含明细的账单快照。
+func (s *Store) GetStatement(ctx context.Context, id string) (*StatementRecord, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	var detail string
+	rec, err := scanStatement(s.db.QueryRowContext(ctx, `SELECT `+statementColumns+`, detail FROM statements WHERE id = ?`, id), &detail)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	var d statementDetail
+	if err := json.Unmarshal([]byte(detail), &d); err != nil {
+		return nil, err
+	}
+	rec.AccountName = d.AccountName
+	rec.ByModel, rec.ByNode, rec.ByAPIKey, rec.Adjustments = d.ByModel, d.ByNode, d.ByAPIKey, d.Adjustments
+	return rec, nil
+}
+
+// HasStatement 判断账号在账期内是否已有账单快照。
+func (s *Store) HasStatement(ctx context.Context, accountID, period string) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	var n int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM statements WHERE account_id = ? AND period = ?`, normalizeAccount(accountID), period).Scan(&n)
+	return n > 0, err
+}
@@ -7,6 +7,8 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
@@ -24,6 +26,11 @@ const (
 type Store struct {
 	db      *sql.DB
 	dialect Dialect
+
+	// 计费定价快照，见 pricing_cache.go
+	pricingMu    sync.Mutex
+	pricingCache atomic.Pointer[pricingSnapshot]
+	pricingGen   atomic.Int64
 }
 
 // Dialect returns the current database dialect.
@@ -97,13 +104,68 @@ func (s *Store) migrate(ctx context.Context) error {
 	if err := s.ensureMonitorSharesTable(ctx); err != nil {
 		return err
 	}
+	// 维护窗口与可用性汇总表
+	if err := s.ensureUptimeTables(ctx); err != nil {
+		return err
+	}
+	// 多实例选主租约表
+	if err := s.ensureLeaderLeaseTable(ctx); err != nil {
+		return err
+	}
+	// 多实例集群事件表
+	if err := s.ensureClusterEventsTable(ctx); err != nil {
+		return err
+	}
+	// 熔断器状态、参数覆盖与状态转换日志
+	if err := s.ensureCircuitBreakerTables(ctx); err != nil {
+		return err
+	}
+	// 节点故障/恢复/切换日志
+	if err := s.ensureNodeEventsTable(ctx); err != nil {
+		return err
+	}
 	// 模型定价和使用日志表
 	if err := s.ensurePricingTables(ctx); err != nil {
 		return err
 	}
+	// 提示缓存 token 与缓存价格列（依赖节点、监控与定价表）
+	if err := s.migrateCacheTokens(ctx); err != nil {
+		return err
+	}
+	// 监控表模型维度（依赖监控表全部列已补齐）
+	if err := s.migrateMetricsModel(ctx); err != nil {
+		return err
+	}
 	if err := s.SeedDefaultPricing(ctx); err != nil {
 		return err
 	}
+	// 分段/生效期定价规则（依赖使用日志表）
+	if err := s.ensurePricingHistoryTable(ctx); err != nil {
+		return err
+	}
+	if err := s.ensurePricingRulesTable(ctx); err != nil {
+		return err
+	}
+	// 未定价标记（依赖 pricing_rule_id 列）
+	if err := s.ensureUsageLogUnpriced(ctx); err != nil {
+		return err
+	}
+	// 节点成本倍率/价格覆盖与实际成本列
+	if err := s.ensureNodeCostTables(ctx); err != nil {
+		return err
+	}
+	// 月度账单快照、账单调整与使用日志代理 Key 提示列
+	if err := s.ensureStatementTables(ctx); err != nil {
+		return err
+	}
+	// 请求审计日志表
+	if err := s.ensureRequestLogsTable(ctx); err != nil {
+		return err
+	}
+	// 调试抓包表
+	if err := s.ensureRequestCapturesTable(ctx); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -0,0 +1,74 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// QueryTrafficWindow 汇总 [from, to) 内各账号、节点的请求数、失败数、tokens（监控原始表）与费用（使用日志）。
+func (s *Store) QueryTrafficWindow(ctx context.Context, from, to time.Time) ([]TrafficSample, error) {
+	if s == nil || s.db == nil {
+		return nil, errors.New("store not initialized")
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	type key struct{ account, node string }
+	samples := make(map[key]*TrafficSample)
+	var order []key
+	get := func(account, node string) *TrafficSample {
+		k := key{account, node}
+		if s, ok := samples[k]; ok {
+			return s
+		}
+		order = append(order, k)
+		samples[k] = &TrafficSample{AccountID: account, NodeID: node}
+		return samples[k]
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT account_id, node_id, SUM(requests_total), SUM(requests_failed),
+		SUM(input_tokens_total + output_tokens_total + cache_creation_tokens_total + cache_read_tokens_total)
+		FROM node_metrics_raw WHERE ts >= ? AND ts < ? GROUP BY account_id, node_id`, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var account, node string
+		var total, failed, tokens int64
+		if err := rows.Scan(&account, &node, &total, &failed, &tokens); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		sample := get(account, node)
+		sample.RequestsTotal, sample.RequestsFailed, sample.Tokens = total, failed, tokens
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = s.db.QueryContext(ctx, `SELECT account_id, node_id, SUM(cost_usd)
+		FROM usage_logs WHERE created_at >= ? AND created_at < ? GROUP BY account_id, node_id`, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var account, node string
+		var cost float64
+		if err := rows.Scan(&account, &node, &cost); err != nil {
+			return nil, err
+		}
+		get(account, node).CostUSD = cost
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	out := make([]TrafficSample, 0, len(order))
+	for _, k := range order {
+		out = append(out, *samples[k])
+	}
+	return out, nil
+}
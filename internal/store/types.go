@@ -15,29 +15,31 @@ const (
 
 // NodeRecord mirrors persistent fields for a proxy node.
 type NodeRecord struct {
-	ID                string
-	Name              string
-	BaseURL           string
-	APIKey            string
-	HealthCheckMethod string
-	HealthCheckModel  string
-	AccountID         string
-	Weight            int
-	Failed            bool
-	Disabled          bool
-	LastError         string
-	CreatedAt         time.Time
-	Requests          int64
-	FailCount         int64
-	FailStreak        int64
-	TotalBytes        int64
-	TotalInput        int64
-	TotalOutput       int64
-	StreamDurMs       int64
-	FirstByteMs       int64
-	LastPingMs        int64
-	LastPingErr       string
-	LastHealthCheckAt time.Time
+	ID                 string
+	Name               string
+	BaseURL            string
+	APIKey             string
+	HealthCheckMethod  string
+	HealthCheckModel   string
+	AccountID          string
+	Weight             int
+	Failed             bool
+	Disabled           bool
+	LastError          string
+	CreatedAt          time.Time
+	Requests           int64
+	FailCount          int64
+	FailStreak         int64
+	TotalBytes         int64
+	TotalInput         int64
+	TotalOutput        int64
+	TotalCacheCreation int64
+	TotalCacheRead     int64
+	StreamDurMs        int64
+	FirstByteMs        int64
+	LastPingMs         int64
+	LastPingErr        string
+	LastHealthCheckAt  time.Time
 }
 
 // HealthCheckRecord 健康检查历史记录
@@ -49,6 +51,7 @@ type HealthCheckRecord struct {
 	Success        bool
 	ResponseTimeMs int
 	ErrorMessage   string
+	ErrorCategory  string // 上游错误分类（auth/rate_limit/client 等），成功时为空
 	CheckMethod    string
 	CheckSource    string
 	CreatedAt      time.Time
@@ -78,86 +81,115 @@ const (
 // MetricsRecord 表示单次请求或采样点的原始监控数据。
 // 所有时间相关字段均使用 UTC 存储，便于跨地域查询。
 type MetricsRecord struct {
-	ID                  int64
-	AccountID           string
-	NodeID              string
-	Timestamp           time.Time
-	RequestsTotal       int64
-	RequestsSuccess     int64
-	RequestsFailed      int64
-	RetryAttemptsTotal  int64
-	RetrySuccess        int64
-	ResponseTimeSumMs   int64 // 总响应耗时（毫秒），配合 ResponseTimeCount 计算平均值
-	ResponseTimeCount   int64
-	BytesTotal          int64
-	InputTokensTotal    int64
-	OutputTokensTotal   int64
-	FirstByteTimeSumMs  int64 // 首字节时间总和（毫秒）
-	StreamDurationSumMs int64 // 流式持续时间总和（毫秒）
-	CreatedAt           time.Time
+	ID                       int64
+	AccountID                string
+	NodeID                   string
+	ModelID                  string // 请求的模型，历史数据为空
+	Timestamp                time.Time
+	RequestsTotal            int64
+	RequestsSuccess          int64
+	RequestsFailed           int64
+	RetryAttemptsTotal       int64
+	RetrySuccess             int64
+	ResponseTimeSumMs        int64 // 总响应耗时（毫秒），配合 ResponseTimeCount 计算平均值
+	ResponseTimeCount        int64
+	BytesTotal               int64
+	InputTokensTotal         int64
+	OutputTokensTotal        int64
+	CacheCreationTokensTotal int64
+	CacheReadTokensTotal     int64
+	FirstByteTimeSumMs       int64            // 首字节时间总和（毫秒）
+	StreamDurationSumMs      int64            // 流式持续时间总和（毫秒）
+	ResponseTimeHist         LatencyHistogram // 响应耗时直方图，用于计算 p50/p90/p95/p99
+	FirstByteHist            LatencyHistogram // 首字节时间直方图
+	Failures                 FailureBreakdown // 失败按状态码类别与错误分类拆分，含客户端取消数
+	CreatedAt                time.Time
+}
+
+// TrafficSample 某时间窗口内单个节点的流量汇总，供异常检测使用。
+type TrafficSample struct {
+	AccountID      string
+	NodeID         string
+	RequestsTotal  int64
+	RequestsFailed int64
+	Tokens         int64   // 输入、输出与缓存 tokens 之和
+	CostUSD        float64 // 来自使用日志
 }
 
 // MetricsHourly 表示小时级聚合数据（半开区间 [BucketStart, BucketStart+1h)）。
 type MetricsHourly struct {
-	AccountID           string
-	NodeID              string
-	BucketStart         time.Time
-	RequestsTotal       int64
-	RequestsSuccess     int64
-	RequestsFailed      int64
-	RetryAttemptsTotal  int64
-	RetrySuccess        int64
-	ResponseTimeSumMs   int64
-	ResponseTimeCount   int64
-	BytesTotal          int64
-	InputTokensTotal    int64
-	OutputTokensTotal   int64
-	FirstByteTimeSumMs  int64
-	StreamDurationSumMs int64
+	AccountID                string
+	NodeID                   string
+	BucketStart              time.Time
+	RequestsTotal            int64
+	RequestsSuccess          int64
+	RequestsFailed           int64
+	RetryAttemptsTotal       int64
+	RetrySuccess             int64
+	ResponseTimeSumMs        int64
+	ResponseTimeCount        int64
+	BytesTotal               int64
+	InputTokensTotal         int64
+	OutputTokensTotal        int64
+	CacheCreationTokensTotal int64
+	CacheReadTokensTotal     int64
+	FirstByteTimeSumMs       int64
+	StreamDurationSumMs      int64
+	ResponseTimeHist         LatencyHistogram
+	FirstByteHist            LatencyHistogram
 }
 
 // MetricsDaily 表示天级聚合数据（UTC 零点对齐）。
 type MetricsDaily struct {
-	AccountID           string
-	NodeID              string
-	BucketStart         time.Time
-	RequestsTotal       int64
-	RequestsSuccess     int64
-	RequestsFailed      int64
-	RetryAttemptsTotal  int64
-	RetrySuccess        int64
-	ResponseTimeSumMs   int64
-	ResponseTimeCount   int64
-	BytesTotal          int64
-	InputTokensTotal    int64
-	OutputTokensTotal   int64
-	FirstByteTimeSumMs  int64
-	StreamDurationSumMs int64
+	AccountID                string
+	NodeID                   string
+	BucketStart              time.Time
+	RequestsTotal            int64
+	RequestsSuccess          int64
+	RequestsFailed           int64
+	RetryAttemptsTotal       int64
+	RetrySuccess             int64
+	ResponseTimeSumMs        int64
+	ResponseTimeCount        int64
+	BytesTotal               int64
+	InputTokensTotal         int64
+	OutputTokensTotal        int64
+	CacheCreationTokensTotal int64
+	CacheReadTokensTotal     int64
+	FirstByteTimeSumMs       int64
+	StreamDurationSumMs      int64
+	ResponseTimeHist         LatencyHistogram
+	FirstByteHist            LatencyHistogram
 }
 
 // MetricsMonthly 表示月级聚合数据（UTC 月初对齐）。
 type MetricsMonthly struct {
-	AccountID           string
-	NodeID              string
-	BucketStart         time.Time
-	RequestsTotal       int64
-	RequestsSuccess     int64
-	RequestsFailed      int64
-	RetryAttemptsTotal  int64
-	RetrySuccess        int64
-	ResponseTimeSumMs   int64
-	ResponseTimeCount   int64
-	BytesTotal          int64
-	InputTokensTotal    int64
-	OutputTokensTotal   int64
-	FirstByteTimeSumMs  int64
-	StreamDurationSumMs int64
+	AccountID                string
+	NodeID                   string
+	BucketStart              time.Time
+	RequestsTotal            int64
+	RequestsSuccess          int64
+	RequestsFailed           int64
+	RetryAttemptsTotal       int64
+	RetrySuccess             int64
+	ResponseTimeSumMs        int64
+	ResponseTimeCount        int64
+	BytesTotal               int64
+	InputTokensTotal         int64
+	OutputTokensTotal        int64
+	CacheCreationTokensTotal int64
+	CacheReadTokensTotal     int64
+	FirstByteTimeSumMs       int64
+	StreamDurationSumMs      int64
+	ResponseTimeHist         LatencyHistogram
+	FirstByteHist            LatencyHistogram
 }
 
 // MetricsQuery 描述监控数据查询参数。
 type MetricsQuery struct {
 	AccountID   string
 	NodeID      string
+	ModelID     string
 	From        time.Time
 	To          time.Time
 	Granularity MetricsGranularity
@@ -240,6 +272,8 @@ type MonitorShareRecord struct {
 	CreatedAt time.Time  `json:"created_at"`
 	Revoked   bool       `json:"revoked"`
 	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	// IncludeUptime 分享页是否附带可用性（SLA）报表
+	IncludeUptime bool `json:"include_uptime"`
 }
 
 // QueryMonitorSharesParams 查询参数
@@ -252,40 +286,191 @@ type QueryMonitorSharesParams struct {
 
 // ModelPricingRecord 模型定价记录（按 MTok 计费，美元）
 type ModelPricingRecord struct {
-	ID              string    `json:"id"`
-	ModelID         string    `json:"model_id"`          // 模型 ID（如 claude-opus-4-5-20251101）
-	ModelName       string    `json:"model_name"`        // 显示名称（如 Claude Opus 4.5）
-	InputPriceMTok  float64   `json:"input_price_mtok"`  // 输入价格 $/MTok
-	OutputPriceMTok float64   `json:"output_price_mtok"` // 输出价格 $/MTok
-	IsActive        bool      `json:"is_active"`         // 是否启用
-	CreatedAt       time.Time `json:"created_at"`
-	UpdatedAt       time.Time `json:"updated_at"`
+	ID                  string    `json:"id"`
+	ModelID             string    `json:"model_id"`               // 模型 ID（如 claude-opus-4-5-20251101）
+	ModelName           string    `json:"model_name"`             // 显示名称（如 Claude Opus 4.5）
+	InputPriceMTok      float64   `json:"input_price_mtok"`       // 输入价格 $/MTok
+	OutputPriceMTok     float64   `json:"output_price_mtok"`      // 输出价格 $/MTok
+	CacheWritePriceMTok float64   `json:"cache_write_price_mtok"` // 缓存写入价格 $/MTok
+	CacheReadPriceMTok  float64   `json:"cache_read_price_mtok"`  // 缓存读取价格 $/MTok
+	IsActive            bool      `json:"is_active"`              // 是否启用
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+	// EffectiveFrom 仅在写入时使用：新价格的生效时间，空表示立即生效
+	EffectiveFrom *time.Time `json:"effective_from,omitempty"`
+}
+
+// PricingRuleRecord 模型定价规则，在基础价格之上按生效时间与输入规模覆盖价格或整体倍率。
+// 同一时刻命中多条规则时按 Priority、InputThreshold、EffectiveFrom 从低到高逐字段叠加，
+// 高者只覆盖自己设置的字段（如分段价格与限时倍率可同时生效）。
+type PricingRuleRecord struct {
+	ID                  string     `json:"id"`
+	ModelID             string     `json:"model_id"`
+	Name                string     `json:"name"`                   // 规则说明（如 Long context >200K）
+	InputThreshold      int64      `json:"input_threshold"`        // 输入 tokens 总数（含缓存）超过该值时命中，0 表示不限
+	InputPriceMTok      float64    `json:"input_price_mtok"`       // 0 表示沿用基础价格
+	OutputPriceMTok     float64    `json:"output_price_mtok"`      // 0 表示沿用基础价格
+	CacheWritePriceMTok float64    `json:"cache_write_price_mtok"` // 0 表示按生效输入价格的默认倍率推导
+	CacheReadPriceMTok  float64    `json:"cache_read_price_mtok"`  // 0 表示按生效输入价格的默认倍率推导
+	Multiplier          float64    `json:"multiplier"`             // 最终费用倍率（批量折扣、转售加价），0 视为 1
+	Priority            int        `json:"priority"`
+	EffectiveFrom       *time.Time `json:"effective_from,omitempty"` // 生效起点（含），空表示不限
+	EffectiveTo         *time.Time `json:"effective_to,omitempty"`   // 生效终点（不含），空表示不限
+	IsActive            bool       `json:"is_active"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+// CostResult 一次计费的结果。
+type CostResult struct {
+	CostUSD        float64 // 官方价格
+	ActualCostUSD  float64 // 按节点成本配置折算后的实际成本，未指定节点时等于 CostUSD
+	PricingRuleID  string  // 命中的定价规则，空表示仅使用基础价格
+	PricingModelID string  // 命中的定价条目 model_id（可能为模式或同族模型）
+	Unpriced       bool    // 未找到任何价格，费用按 0 计
+}
+
+// NodePriceOverride 节点级模型价格覆盖，ModelID 支持与定价条目相同的模式语法。
+type NodePriceOverride struct {
+	ModelID             string  `json:"model_id"`
+	InputPriceMTok      float64 `json:"input_price_mtok"`
+	OutputPriceMTok     float64 `json:"output_price_mtok"`
+	CacheWritePriceMTok float64 `json:"cache_write_price_mtok"` // 0 表示按输入价格的默认倍率推导
+	CacheReadPriceMTok  float64 `json:"cache_read_price_mtok"`  // 0 表示按输入价格的默认倍率推导
+}
+
+// NodeCostRecord 节点采购成本配置：未命中价格覆盖时实际成本 = 官方价格 × CostMultiplier。
+type NodeCostRecord struct {
+	NodeID         string              `json:"node_id"`
+	AccountID      string              `json:"account_id"`
+	CostMultiplier float64             `json:"cost_multiplier"`
+	Overrides      []NodePriceOverride `json:"overrides,omitempty"`
+	UpdatedAt      time.Time           `json:"updated_at"`
+}
+
+// UnpricedModel 使用日志中未定价模型的汇总。
+type UnpricedModel struct {
+	ModelID             string    `json:"model_id"`
+	Requests            int64     `json:"requests"`
+	InputTokens         int64     `json:"input_tokens"`
+	OutputTokens        int64     `json:"output_tokens"`
+	CacheCreationTokens int64     `json:"cache_creation_tokens"`
+	CacheReadTokens     int64     `json:"cache_read_tokens"`
+	LastSeen            time.Time `json:"last_seen"`
+	MatchedPricing      string    `json:"matched_pricing,omitempty"` // 当前可命中的定价条目，非空时可重新计费
+}
+
+// RepriceParams 重新计费的范围。
+type RepriceParams struct {
+	ModelID       string    `json:"model_id"`
+	From          time.Time `json:"from"`
+	To            time.Time `json:"to"`
+	IncludePriced bool      `json:"include_priced"` // 同时重算已定价的记录（如修正历史价格后）
+}
+
+// RepriceResult 重新计费结果。
+type RepriceResult struct {
+	Updated       int64 `json:"updated"`
+	StillUnpriced int64 `json:"still_unpriced"`
+}
+
+// 账单调整类型。
+const (
+	AdjustmentKindAdjustment = "adjustment" // 调整，金额可正可负，计入应付
+	AdjustmentKindCredit     = "credit"     // 抵扣额度，金额为正，从应付中扣除
+)
+
+// BillingAdjustmentRecord 账号某一账期的手工调整或抵扣，生成账单时计入快照。
+type BillingAdjustmentRecord struct {
+	ID          string    `json:"id"`
+	AccountID   string    `json:"account_id"`
+	Period      string    `json:"period"` // 账期，格式 2006-01
+	Kind        string    `json:"kind"`
+	AmountUSD   float64   `json:"amount_usd"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// StatementLine 账单中按模型、节点或 API Key 汇总的一行。
+type StatementLine struct {
+	Key                 string  `json:"key"`
+	Label               string  `json:"label,omitempty"` // 节点名称等展示名，生成时的快照
+	Requests            int64   `json:"requests"`
+	InputTokens         int64   `json:"input_tokens"`
+	OutputTokens        int64   `json:"output_tokens"`
+	CacheCreationTokens int64   `json:"cache_creation_tokens"`
+	CacheReadTokens     int64   `json:"cache_read_tokens"`
+	CostUSD             float64 `json:"cost_usd"`
+}
+
+// StatementRecord 账号月度账单快照。生成后不再修改，重新生成时写入新版本。
+type StatementRecord struct {
+	ID             string        `json:"id"`
+	AccountID      string        `json:"account_id"`
+	AccountName    string        `json:"account_name"`
+	Period         string        `json:"period"`
+	Version        int           `json:"version"`
+	Timezone       string        `json:"timezone"`
+	PeriodStart    time.Time     `json:"period_start"`
+	PeriodEnd      time.Time     `json:"period_end"` // 不含
+	Totals         StatementLine `json:"totals"`
+	AdjustmentsUSD float64       `json:"adjustments_usd"`
+	CreditsUSD     float64       `json:"credits_usd"`
+	AmountDueUSD   float64       `json:"amount_due_usd"` // 用量费用 + 调整 - 抵扣
+	GeneratedBy    string        `json:"generated_by"`
+	CreatedAt      time.Time     `json:"created_at"`
+
+	// 明细，列表接口不返回
+	ByModel     []StatementLine           `json:"by_model,omitempty"`
+	ByNode      []StatementLine           `json:"by_node,omitempty"`
+	ByAPIKey    []StatementLine           `json:"by_api_key,omitempty"`
+	Adjustments []BillingAdjustmentRecord `json:"adjustments,omitempty"`
+}
+
+// StatementParams 生成账单的参数。
+type StatementParams struct {
+	AccountID   string
+	Period      string         // 2006-01
+	Location    *time.Location // 账期边界所在时区，nil 为 UTC
+	GeneratedBy string
 }
 
 // UsageLogRecord 使用日志记录
 type UsageLogRecord struct {
-	ID           int64     `json:"id"`
-	AccountID    string    `json:"account_id"`
-	NodeID       string    `json:"node_id"`
-	ModelID      string    `json:"model_id"`       // 使用的模型
-	InputTokens  int64     `json:"input_tokens"`   // 输入 tokens
-	OutputTokens int64     `json:"output_tokens"`  // 输出 tokens
-	CostUSD      float64   `json:"cost_usd"`       // 费用（美元）
-	RequestID    string    `json:"request_id"`     // 请求 ID（可选，用于追踪）
-	Success      bool      `json:"success"`        // 请求是否成功
-	CreatedAt    time.Time `json:"created_at"`
+	ID                  int64     `json:"id"`
+	AccountID           string    `json:"account_id"`
+	NodeID              string    `json:"node_id"`
+	ModelID             string    `json:"model_id"`              // 使用的模型
+	InputTokens         int64     `json:"input_tokens"`          // 输入 tokens
+	OutputTokens        int64     `json:"output_tokens"`         // 输出 tokens
+	CacheCreationTokens int64     `json:"cache_creation_tokens"` // 缓存写入 tokens
+	CacheReadTokens     int64     `json:"cache_read_tokens"`     // 缓存读取 tokens
+	CostUSD             float64   `json:"cost_usd"`              // 费用（美元，官方价格）
+	ActualCostUSD       float64   `json:"actual_cost_usd"`       // 实际成本（美元，按节点成本配置折算）
+	RequestID           string    `json:"request_id"`            // 代理请求 ID（X-Request-ID）
+	Success             bool      `json:"success"`               // 请求是否成功
+	CreatedAt           time.Time `json:"created_at"`
+
+	UpstreamRequestID string `json:"upstream_request_id,omitempty"` // 上游返回的请求 ID
+	AttemptTrace      string `json:"attempt_trace,omitempty"`       // 节点尝试轨迹（JSON）
+	PricingRuleID     string `json:"pricing_rule_id,omitempty"`     // 计费时命中的定价规则
+	Unpriced          bool   `json:"unpriced,omitempty"`            // 计费时未找到模型价格
+	KeyHint           string `json:"key_hint,omitempty"`            // 代理 Key 脱敏提示，用于按 Key 出账
 }
 
 // UsageSummary 使用汇总统计
 type UsageSummary struct {
-	AccountID         string  `json:"account_id"`
-	NodeID            string  `json:"node_id,omitempty"`
-	ModelID           string  `json:"model_id,omitempty"`
-	TotalRequests     int64   `json:"total_requests"`
-	SuccessRequests   int64   `json:"success_requests"`
-	TotalInputTokens  int64   `json:"total_input_tokens"`
-	TotalOutputTokens int64   `json:"total_output_tokens"`
-	TotalCostUSD      float64 `json:"total_cost_usd"`
+	AccountID                string  `json:"account_id"`
+	NodeID                   string  `json:"node_id,omitempty"`
+	ModelID                  string  `json:"model_id,omitempty"`
+	TotalRequests            int64   `json:"total_requests"`
+	SuccessRequests          int64   `json:"success_requests"`
+	TotalInputTokens         int64   `json:"total_input_tokens"`
+	TotalOutputTokens        int64   `json:"total_output_tokens"`
+	TotalCacheCreationTokens int64   `json:"total_cache_creation_tokens"`
+	TotalCacheReadTokens     int64   `json:"total_cache_read_tokens"`
+	TotalCostUSD             float64 `json:"total_cost_usd"`        // 按官方价格计算的费用
+	TotalActualCostUSD       float64 `json:"total_actual_cost_usd"` // 按节点成本配置折算后的实际成本
 }
 
 // QueryUsageParams 查询使用日志参数
@@ -298,3 +483,170 @@ type QueryUsageParams struct {
 	Limit     int
 	Offset    int
 }
+
+// MaintenanceWindowRecord 维护窗口；NodeID 为空表示作用于账号下全部节点。
+type MaintenanceWindowRecord struct {
+	ID        string    `json:"id"`
+	AccountID string    `json:"account_id"`
+	NodeID    string    `json:"node_id"`
+	StartsAt  time.Time `json:"starts_at"`
+	EndsAt    time.Time `json:"ends_at"`
+	Reason    string    `json:"reason"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UptimeDailyRecord 按天汇总的节点可用性数据（秒）。维护时长由报表按当前维护窗口计算，
+// 新汇总的维护列为 0；NodeID 为空的账号级记录为旧版本写入，报表不再读取。
+type UptimeDailyRecord struct {
+	AccountID                string
+	NodeID                   string
+	BucketStart              time.Time
+	PeriodSeconds            int64
+	DownSeconds              int64
+	MaintenanceSeconds       int64
+	DownInMaintenanceSeconds int64
+	Incidents                int64
+	// DownSpans 当天的故障区间，供报表还原故障事件而无需扫描原始记录；
+	// nil 表示未记录（旧数据或区间过多），此时报表回退到原始健康检查记录。
+	DownSpans []UptimeDownSpan
+}
+
+// UptimeDownSpan 日汇总中的一段故障区间（已裁剪到当天范围内）。
+type UptimeDownSpan struct {
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// LeaseRecord 选主租约；ExpiresAt 之后其他实例可接管。
+type LeaseRecord struct {
+	Name       string
+	Holder     string
+	AcquiredAt time.Time
+	RenewedAt  time.Time
+	ExpiresAt  time.Time
+}
+
+// ClusterEventRecord 集群事件；ID 自增，同时作为全局单调版本号。
+type ClusterEventRecord struct {
+	ID         int64
+	InstanceID string
+	AccountID  string
+	NodeID     string
+	EventType  string
+	Payload    string // JSON
+	CreatedAt  time.Time
+}
+
+// RequestLogRecord 单次代理请求的审计日志，用于排查租户问题。
+type RequestLogRecord struct {
+	ID                  int64     `json:"id"`
+	RequestID           string    `json:"request_id"`                  // 代理生成的请求 ID
+	ClientRequestID     string    `json:"client_request_id,omitempty"` // 客户端传入的 X-Request-ID
+	AccountID           string    `json:"account_id"`
+	KeyHint             string    `json:"key_hint"` // 代理 Key 脱敏提示（前 4 位 + 后 4 位）
+	ClientIP            string    `json:"client_ip"`
+	UserAgent           string    `json:"user_agent"`
+	SessionID           string    `json:"session_id"` // Claude Code 会话 ID（metadata.user_id 中的 session 段）
+	ModelID             string    `json:"model_id"`
+	Stream              bool      `json:"stream"`
+	Status              int       `json:"status"`
+	ErrorType           string    `json:"error_type"` // 最后一次尝试的错误分类
+	NodeID              string    `json:"node_id"`    // 最后一次尝试的节点
+	Attempts            int       `json:"attempts"`   // 实际发送的尝试次数
+	AttemptTrace        string    `json:"attempt_trace,omitempty"`
+	TTFBMs              int64     `json:"ttfb_ms"`
+	DurationMs          int64     `json:"duration_ms"`
+	InputTokens         int64     `json:"input_tokens"`
+	OutputTokens        int64     `json:"output_tokens"`
+	CacheCreationTokens int64     `json:"cache_creation_tokens"`
+	CacheReadTokens     int64     `json:"cache_read_tokens"`
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+// RequestCaptureRecord 调试抓包：脱敏后的请求体与客户端实际收到的响应（含 SSE 事件）。
+type RequestCaptureRecord struct {
+	ID              int64     `json:"id"`
+	RequestID       string    `json:"request_id"`
+	AccountID       string    `json:"account_id"`
+	NodeID          string    `json:"node_id"`
+	ModelID         string    `json:"model_id"`
+	Method          string    `json:"method"`
+	Path            string    `json:"path"`
+	Status          int       `json:"status"`
+	Stream          bool      `json:"stream"`
+	RequestHeaders  string    `json:"request_headers,omitempty"`  // JSON 对象
+	ResponseHeaders string    `json:"response_headers,omitempty"` // JSON 对象
+	RequestBody     []byte    `json:"-"`
+	ResponseBody    []byte    `json:"-"`
+	RequestBytes    int64     `json:"request_bytes"`  // 原始请求体大小
+	ResponseBytes   int64     `json:"response_bytes"` // 原始响应体大小
+	Truncated       bool      `json:"truncated"`      // 请求或响应超过大小上限被截断
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// RequestLogQuery 请求审计日志查询条件，字段为空表示不过滤。
+type RequestLogQuery struct {
+	AccountID       string
+	NodeID          string
+	ModelID         string
+	SessionID       string
+	RequestID       string
+	ClientRequestID string
+	ClientIP        string
+	ErrorType       string
+	Status          int  // 精确匹配状态码
+	FailedOnly      bool // 仅返回非 200 请求
+	From            time.Time
+	To              time.Time
+	Limit           int
+	Offset          int
+}
+
+// CircuitBreakerRecord 节点熔断器的持久化状态与参数覆盖（覆盖字段为 0 表示沿用全局配置）。
+type CircuitBreakerRecord struct {
+	NodeID           string
+	AccountID        string
+	State            string
+	StateChangedAt   time.Time
+	Forced           bool
+	WindowSeconds    int
+	FailureRate      float64
+	ConsecutiveFails int
+	CooldownSeconds  int
+	HalfOpenMaxCalls int
+	UpdatedAt        time.Time
+}
+
+// CircuitBreakerEventRecord 熔断器状态转换日志。
+type CircuitBreakerEventRecord struct {
+	ID               int64
+	NodeID           string
+	AccountID        string
+	InstanceID       string // 发生转换的实例（各实例熔断器独立计数）
+	FromState        string
+	ToState          string
+	Reason           string
+	ConsecutiveFails int
+	FailureRate      float64
+	CreatedAt        time.Time
+}
+
+// 节点事件类型。
+const (
+	NodeEventFailed    = "failed"
+	NodeEventRecovered = "recovered"
+	NodeEventSwitched  = "switched"
+)
+
+// NodeEventRecord 节点故障、恢复与切换日志，用于时间线标注。
+type NodeEventRecord struct {
+	ID         int64
+	AccountID  string
+	NodeID     string // 切换事件为切换后的节点
+	EventType  string
+	FromNodeID string // 仅切换事件：切换前的节点
+	Message    string
+	CreatedAt  time.Time
+}
@@ -0,0 +1,411 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ensureUptimeTables 创建维护窗口与可用性日汇总表。
+func (s *Store) ensureUptimeTables(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var windowTable, dailyTable string
+	if s.IsSQLite() {
+		windowTable = `CREATE TABLE IF NOT EXISTS maintenance_windows (
+			id TEXT PRIMARY KEY,
+			account_id TEXT NOT NULL,
+			node_id TEXT NOT NULL DEFAULT '',
+			starts_at DATETIME NOT NULL,
+			ends_at DATETIME NOT NULL,
+			reason TEXT,
+			created_by TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`
+		dailyTable = `CREATE TABLE IF NOT EXISTS node_uptime_daily (
+			account_id TEXT NOT NULL,
+			node_id TEXT NOT NULL,
+			bucket_start DATETIME NOT NULL,
+			period_seconds INTEGER NOT NULL DEFAULT 0,
+			down_seconds INTEGER NOT NULL DEFAULT 0,
+			maintenance_seconds INTEGER NOT NULL DEFAULT 0,
+			down_in_maintenance_seconds INTEGER NOT NULL DEFAULT 0,
+			incidents INTEGER NOT NULL DEFAULT 0,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (account_id, node_id, bucket_start)
+		)`
+	} else {
+		windowTable = `CREATE TABLE IF NOT EXISTS maintenance_windows (
+			id VARCHAR(64) PRIMARY KEY,
+			account_id VARCHAR(64) NOT NULL,
+			node_id VARCHAR(64) NOT NULL DEFAULT '',
+			starts_at DATETIME(3) NOT NULL,
+			ends_at DATETIME(3) NOT NULL,
+			reason TEXT,
+			created_by VARCHAR(255) NOT NULL DEFAULT '',
+			created_at DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+			KEY idx_maint_account_time (account_id, starts_at, ends_at)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`
+		dailyTable = `CREATE TABLE IF NOT EXISTS node_uptime_daily (
+			account_id VARCHAR(64) NOT NULL,
+			node_id VARCHAR(64) NOT NULL,
+			bucket_start DATETIME NOT NULL,
+			period_seconds BIGINT NOT NULL DEFAULT 0,
+			down_seconds BIGINT NOT NULL DEFAULT 0,
+			maintenance_seconds BIGINT NOT NULL DEFAULT 0,
+			down_in_maintenance_seconds BIGINT NOT NULL DEFAULT 0,
+			incidents INT NOT NULL DEFAULT 0,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (account_id, node_id, bucket_start),
+			KEY idx_uptime_daily_bucket (bucket_start)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`
+	}
+
+	if _, err := s.db.ExecContext(ctx, windowTable); err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx, dailyTable); err != nil {
+		return err
+	}
+	if s.IsSQLite() {
+		s.db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_maint_account_time ON maintenance_windows(account_id, starts_at, ends_at)`)
+		s.db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_uptime_daily_bucket ON node_uptime_daily(bucket_start)`)
+	}
+
+	// 日汇总增加当天故障区间，报表据此列出故障事件。
+	hasDownSpans, err := s.columnExists(context.Background(), "node_uptime_daily", "down_spans")
+	if err != nil {
+		return err
+	}
+	if !hasDownSpans {
+		alterCtx, cancel := withTimeout(context.Background())
+		defer cancel()
+		alterStmt := `ALTER TABLE node_uptime_daily ADD COLUMN down_spans TEXT`
+		if !s.IsSQLite() {
+			alterStmt = `ALTER TABLE node_uptime_daily ADD COLUMN down_spans MEDIUMTEXT AFTER incidents`
+		}
+		if _, err := s.db.ExecContext(alterCtx, alterStmt); err != nil {
+			return err
+		}
+	}
+
+	// 分享链接增加 include_uptime 开关，旧记录默认不包含可用性报表。
+	hasIncludeUptime, err := s.columnExists(context.Background(), "monitor_shares", "include_uptime")
+	if err != nil {
+		return err
+	}
+	if !hasIncludeUptime {
+		alterCtx, cancel := withTimeout(context.Background())
+		defer cancel()
+		var alterStmt string
+		if s.IsSQLite() {
+			alterStmt = `ALTER TABLE monitor_shares ADD COLUMN include_uptime INTEGER NOT NULL DEFAULT 0`
+		} else {
+			alterStmt = `ALTER TABLE monitor_shares ADD COLUMN include_uptime BOOLEAN NOT NULL DEFAULT FALSE AFTER revoked_at`
+		}
+		if _, err := s.db.ExecContext(alterCtx, alterStmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateMaintenanceWindow 创建维护窗口；NodeID 为空表示作用于账号下全部节点。
+func (s *Store) CreateMaintenanceWindow(ctx context.Context, rec *MaintenanceWindowRecord) error {
+	if s == nil || s.db == nil {
+		return errors.New("store not initialized")
+	}
+	if rec == nil {
+		return errors.New("record is nil")
+	}
+	if rec.StartsAt.IsZero() || rec.EndsAt.IsZero() || !rec.EndsAt.After(rec.StartsAt) {
+		return errors.New("invalid maintenance window range")
+	}
+	if rec.ID == "" {
+		rec.ID = genUUID()
+	}
+	rec.AccountID = normalizeAccount(rec.AccountID)
+	rec.NodeID = strings.TrimSpace(rec.NodeID)
+	rec.StartsAt = rec.StartsAt.UTC()
+	rec.EndsAt = rec.EndsAt.UTC()
+	if rec.CreatedAt.IsZero() {
+		rec.CreatedAt = time.Now().UTC()
+	} else {
+		rec.CreatedAt = rec.CreatedAt.UTC()
+	}
+
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	_, err := s.db.ExecContext(ctx, `INSERT INTO maintenance_windows (id, account_id, node_id, starts_at, ends_at, reason, created_by, created_at)
+		VALUES (?,?,?,?,?,?,?,?)`,
+		rec.ID, rec.AccountID, rec.NodeID, rec.StartsAt, rec.EndsAt, rec.Reason, rec.CreatedBy, rec.CreatedAt)
+	return err
+}
+
+// GetMaintenanceWindow 根据 ID 获取维护窗口。
+func (s *Store) GetMaintenanceWindow(ctx context.Context, id string) (*MaintenanceWindowRecord, error) {
+	if s == nil || s.db == nil {
+		return nil, errors.New("store not initialized")
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	row := s.db.QueryRowContext(ctx, `SELECT id, account_id, node_id, starts_at, ends_at, reason, created_by, created_at
+		FROM maintenance_windows WHERE id=?`, id)
+	rec, err := scanMaintenanceWindow(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return rec, err
+}
+
+// ListMaintenanceWindows 返回与 [from, to) 有交集的维护窗口；nodeID 非空时包含账号级窗口。
+func (s *Store) ListMaintenanceWindows(ctx context.Context, accountID, nodeID string, from, to time.Time) ([]MaintenanceWindowRecord, error) {
+	if s == nil || s.db == nil {
+		return nil, errors.New("store not initialized")
+	}
+	conds := []string{"account_id=?"}
+	args := []interface{}{normalizeAccount(accountID)}
+	if nodeID != "" {
+		conds = append(conds, "(node_id=? OR node_id='')")
+		args = append(args, nodeID)
+	}
+	if !to.IsZero() {
+		conds = append(conds, "starts_at < ?")
+		args = append(args, to.UTC())
+	}
+	if !from.IsZero() {
+		conds = append(conds, "ends_at > ?")
+		args = append(args, from.UTC())
+	}
+
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, `SELECT id, account_id, node_id, starts_at, ends_at, reason, created_by, created_at
+		FROM maintenance_windows WHERE `+strings.Join(conds, " AND ")+` ORDER BY starts_at ASC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []MaintenanceWindowRecord
+	for rows.Next() {
+		rec, err := scanMaintenanceWindow(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, *rec)
+	}
+	return res, rows.Err()
+}
+
+// DeleteMaintenanceWindow 删除维护窗口。
+func (s *Store) DeleteMaintenanceWindow(ctx context.Context, id string) error {
+	if s == nil || s.db == nil {
+		return errors.New("store not initialized")
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	res, err := s.db.ExecContext(ctx, `DELETE FROM maintenance_windows WHERE id=?`, id)
+	if err != nil {
+		return err
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func scanMaintenanceWindow(row rowScanner) (*MaintenanceWindowRecord, error) {
+	var (
+		rec    MaintenanceWindowRecord
+		reason sql.NullString
+	)
+	if err := row.Scan(&rec.ID, &rec.AccountID, &rec.NodeID, &rec.StartsAt, &rec.EndsAt, &reason, &rec.CreatedBy, &rec.CreatedAt); err != nil {
+		return nil, err
+	}
+	rec.Reason = reason.String
+	rec.StartsAt = rec.StartsAt.UTC()
+	rec.EndsAt = rec.EndsAt.UTC()
+	rec.CreatedAt = rec.CreatedAt.UTC()
+	return &rec, nil
+}
+
+// HealthTimeline 返回 [from, to) 内的健康检查记录（正序），以及 from 之前的最后一条记录用于确定起始状态。
+// 历史记录仅在状态变化或间隔超过 5 分钟时写入，因此记录序列即可还原节点的上下线区间。
+func (s *Store) HealthTimeline(ctx context.Context, accountID, nodeID string, from, to time.Time) (*HealthCheckRecord, []HealthCheckRecord, error) {
+	if s == nil || s.db == nil {
+		return nil, nil, errors.New("store not initialized")
+	}
+	if nodeID == "" {
+		return nil, nil, errors.New("node_id required")
+	}
+	accountID = normalizeAccount(accountID)
+	from = from.UTC()
+	to = to.UTC()
+
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var prev *HealthCheckRecord
+	row := s.db.QueryRowContext(ctx, `SELECT check_time, success, error_message
+		FROM health_check_history
+		WHERE account_id=? AND node_id=? AND check_time < ?
+		ORDER BY check_time DESC LIMIT 1`, accountID, nodeID, from)
+	var (
+		rec    HealthCheckRecord
+		errMsg sql.NullString
+	)
+	switch err := row.Scan(&rec.CheckTime, &rec.Success, &errMsg); err {
+	case nil:
+		rec.AccountID = accountID
+		rec.NodeID = nodeID
+		rec.ErrorMessage = errMsg.String
+		prev = &rec
+	case sql.ErrNoRows:
+	default:
+		return nil, nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT check_time, success, error_message
+		FROM health_check_history
+		WHERE account_id=? AND node_id=? AND check_time >= ? AND check_time < ?
+		ORDER BY check_time ASC`, accountID, nodeID, from, to)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var res []HealthCheckRecord
+	for rows.Next() {
+		var (
+			r   HealthCheckRecord
+			msg sql.NullString
+		)
+		if err := rows.Scan(&r.CheckTime, &r.Success, &msg); err != nil {
+			return nil, nil, err
+		}
+		r.AccountID = accountID
+		r.NodeID = nodeID
+		r.ErrorMessage = msg.String
+		r.CheckTime = r.CheckTime.UTC()
+		res = append(res, r)
+	}
+	return prev, res, rows.Err()
+}
+
+// ListHealthCheckNodes 返回 [from, to) 内存在健康检查记录的账号/节点组合。
+func (s *Store) ListHealthCheckNodes(ctx context.Context, from, to time.Time) ([][2]string, error) {
+	if s == nil || s.db == nil {
+		return nil, errors.New("store not initialized")
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, `SELECT DISTINCT account_id, node_id FROM health_check_history
+		WHERE check_time >= ? AND check_time < ?`, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res [][2]string
+	for rows.Next() {
+		var pair [2]string
+		if err := rows.Scan(&pair[0], &pair[1]); err != nil {
+			return nil, err
+		}
+		res = append(res, pair)
+	}
+	return res, rows.Err()
+}
+
+// UpsertUptimeDaily 写入（或覆盖）按天汇总的可用性数据；NodeID 为空表示账号级汇总。
+func (s *Store) UpsertUptimeDaily(ctx context.Context, rec UptimeDailyRecord) error {
+	if s == nil || s.db == nil {
+		return errors.New("store not initialized")
+	}
+	rec.AccountID = normalizeAccount(rec.AccountID)
+	rec.BucketStart = rec.BucketStart.UTC()
+	now := time.Now().UTC()
+	var spans interface{}
+	if rec.DownSpans != nil {
+		for i := range rec.DownSpans {
+			rec.DownSpans[i].Reason = truncateRunes(rec.DownSpans[i].Reason, 200)
+		}
+		data, err := json.Marshal(rec.DownSpans)
+		if err != nil {
+			return err
+		}
+		spans = string(data)
+	}
+
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	var stmt string
+	if s.IsSQLite() {
+		stmt = `INSERT INTO node_uptime_daily (account_id, node_id, bucket_start, period_seconds, down_seconds, maintenance_seconds, down_in_maintenance_seconds, incidents, down_spans, updated_at)
+			VALUES (?,?,?,?,?,?,?,?,?,?)
+			ON CONFLICT(account_id, node_id, bucket_start) DO UPDATE SET
+				period_seconds=excluded.period_seconds,
+				down_seconds=excluded.down_seconds,
+				maintenance_seconds=excluded.maintenance_seconds,
+				down_in_maintenance_seconds=excluded.down_in_maintenance_seconds,
+				incidents=excluded.incidents,
+				down_spans=excluded.down_spans,
+				updated_at=excluded.updated_at`
+	} else {
+		stmt = `INSERT INTO node_uptime_daily (account_id, node_id, bucket_start, period_seconds, down_seconds, maintenance_seconds, down_in_maintenance_seconds, incidents, down_spans, updated_at)
+			VALUES (?,?,?,?,?,?,?,?,?,?)
+			ON DUPLICATE KEY UPDATE
+				period_seconds=VALUES(period_seconds),
+				down_seconds=VALUES(down_seconds),
+				maintenance_seconds=VALUES(maintenance_seconds),
+				down_in_maintenance_seconds=VALUES(down_in_maintenance_seconds),
+				incidents=VALUES(incidents),
+				down_spans=VALUES(down_spans),
+				updated_at=VALUES(updated_at)`
+	}
+	_, err := s.db.ExecContext(ctx, stmt, rec.AccountID, rec.NodeID, rec.BucketStart, rec.PeriodSeconds, rec.DownSeconds,
+		rec.MaintenanceSeconds, rec.DownInMaintenanceSeconds, rec.Incidents, spans, now)
+	return err
+}
+
+// QueryUptimeDaily 查询 [from, to) 内的日汇总记录，按时间正序。
+func (s *Store) QueryUptimeDaily(ctx context.Context, accountID, nodeID string, from, to time.Time) ([]UptimeDailyRecord, error) {
+	if s == nil || s.db == nil {
+		return nil, errors.New("store not initialized")
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, `SELECT account_id, node_id, bucket_start, period_seconds, down_seconds, maintenance_seconds, down_in_maintenance_seconds, incidents, down_spans
+		FROM node_uptime_daily
+		WHERE account_id=? AND node_id=? AND bucket_start >= ? AND bucket_start < ?
+		ORDER BY bucket_start ASC`, normalizeAccount(accountID), nodeID, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []UptimeDailyRecord
+	for rows.Next() {
+		var (
+			rec   UptimeDailyRecord
+			spans sql.NullString
+		)
+		if err := rows.Scan(&rec.AccountID, &rec.NodeID, &rec.BucketStart, &rec.PeriodSeconds, &rec.DownSeconds,
+			&rec.MaintenanceSeconds, &rec.DownInMaintenanceSeconds, &rec.Incidents, &spans); err != nil {
+			return nil, err
+		}
+		rec.BucketStart = rec.BucketStart.UTC()
+		if spans.Valid {
+			// 解析失败时保持 nil，报表回退到原始记录
+			decoded := make([]UptimeDownSpan, 0)
+			if json.Unmarshal([]byte(spans.String), &decoded) == nil {
+				rec.DownSpans = decoded
+			}
+		}
+		res = append(res, rec)
+	}
+	return res, rows.Err()
+}
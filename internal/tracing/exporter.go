@@ -0,0 +1,261 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	exportQueueSize = 2048
+	exportBatchSize = 512
+	exportInterval  = 5 * time.Second
+)
+
+// exporter 以 OTLP/HTTP JSON 或 OTLP/gRPC 批量发送 Span；队列满时丢弃，避免阻塞请求路径。
+type exporter struct {
+	url     string
+	grpc    bool
+	headers map[string]string
+	service string
+	client  *http.Client
+	logf    func(format string, v ...any)
+
+	queue    chan *Span
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+func newExporter(cfg Config, logf func(format string, v ...any)) *exporter {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	if logf == nil {
+		logf = func(string, ...any) {}
+	}
+	e := &exporter{
+		url:     tracesURL(cfg.Endpoint),
+		headers: cfg.Headers,
+		service: cfg.ServiceName,
+		client:  &http.Client{Timeout: timeout},
+		logf:    logf,
+		queue:   make(chan *Span, exportQueueSize),
+		stopCh:  make(chan struct{}),
+	}
+	if cfg.Protocol == ProtocolGRPC {
+		e.grpc = true
+		e.url = grpcExportURL(cfg.Endpoint)
+		e.client.Transport = newGRPCTransport()
+	}
+	e.wg.Add(1)
+	go e.loop()
+	return e
+}
+
+// tracesURL 端点未包含路径时补全 /v1/traces。
+func tracesURL(endpoint string) string {
+	endpoint = strings.TrimRight(strings.TrimSpace(endpoint), "/")
+	if strings.HasSuffix(endpoint, "/v1/traces") {
+		return endpoint
+	}
+	return endpoint + "/v1/traces"
+}
+
+func (e *exporter) enqueue(s *Span) {
+	select {
+	case e.queue <- s:
+	default:
+	}
+}
+
+func (e *exporter) stop() {
+	e.stopOnce.Do(func() { close(e.stopCh) })
+	e.wg.Wait()
+}
+
+func (e *exporter) loop() {
+	defer e.wg.Done()
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+	batch := make([]*Span, 0, exportBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.send(batch); err != nil {
+			e.logf("[Tracing] export %d spans failed: %v", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case s := <-e.queue:
+			batch = append(batch, s)
+			if len(batch) >= exportBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-e.stopCh:
+			for {
+				select {
+				case s := <-e.queue:
+					batch = append(batch, s)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (e *exporter) send(spans []*Span) error {
+	ctx, cancel := context.WithTimeout(context.Background(), e.client.Timeout)
+	defer cancel()
+	payload := encodeOTLP(e.service, spans)
+	if e.grpc {
+		return e.sendGRPC(ctx, payload)
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("collector returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// OTLP JSON 编码（trace/span ID 使用十六进制字符串，int64 使用十进制字符串）。
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+func encodeOTLP(service string, spans []*Span) otlpRequest {
+	encoded := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		encoded = append(encoded, encodeSpan(s))
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: []otlpKeyValue{attr("service.name", service)}},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "qcc_plus/internal/tracing"}, Spans: encoded}},
+	}}}
+}
+
+func encodeSpan(s *Span) otlpSpan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := otlpSpan{
+		TraceID:           s.sc.TraceID.String(),
+		SpanID:            s.sc.SpanID.String(),
+		Name:              s.name,
+		Kind:              int(s.kind),
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+		Status:            otlpStatus{Code: s.statusCode, Message: s.statusMsg},
+	}
+	if s.parent.IsValid() {
+		out.ParentSpanID = s.parent.String()
+	}
+	keys := make([]string, 0, len(s.attrs))
+	for k := range s.attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		out.Attributes = append(out.Attributes, attr(k, s.attrs[k]))
+	}
+	return out
+}
+
+func attr(key string, v any) otlpKeyValue {
+	kv := otlpKeyValue{Key: key}
+	switch val := v.(type) {
+	case string:
+		kv.Value.StringValue = &val
+	case bool:
+		kv.Value.BoolValue = &val
+	case int:
+		s := strconv.Itoa(val)
+		kv.Value.IntValue = &s
+	case int64:
+		s := strconv.FormatInt(val, 10)
+		kv.Value.IntValue = &s
+	case float64:
+		kv.Value.DoubleValue = &val
+	default:
+		s := fmt.Sprint(val)
+		kv.Value.StringValue = &s
+	}
+	return kv
+}
@@ -0,0 +1,205 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// grpcExportMethod OTLP TraceService/Export 的 gRPC 方法路径。
+const grpcExportMethod = "/opentelemetry.proto.collector.trace.v1.TraceService/Export"
+
+// grpcExportURL 将端点转换为 gRPC 方法 URL：grpc:// 按明文 HTTP/2（h2c），grpcs:// 按 TLS，
+// gRPC 不使用端点中的路径。
+func grpcExportURL(endpoint string) string {
+	u, err := url.Parse(strings.TrimSpace(endpoint))
+	if err != nil || u.Host == "" {
+		return strings.TrimRight(strings.TrimSpace(endpoint), "/") + grpcExportMethod
+	}
+	scheme := u.Scheme
+	switch scheme {
+	case "grpc":
+		scheme = "http"
+	case "grpcs":
+		scheme = "https"
+	}
+	return scheme + "://" + u.Host + grpcExportMethod
+}
+
+// newGRPCTransport 仅使用 HTTP/2：http:// 走 h2c（prior knowledge），https:// 通过 ALPN 协商 h2。
+func newGRPCTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Protocols = new(http.Protocols)
+	t.Protocols.SetHTTP2(true)
+	t.Protocols.SetUnencryptedHTTP2(true)
+	return t
+}
+
+// sendGRPC 以 OTLP/gRPC 发送一次 Export 请求，按 grpc-status 判断结果。
+func (e *exporter) sendGRPC(ctx context.Context, payload otlpRequest) error {
+	msg := marshalOTLPProto(payload)
+	frame := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	frame = append(frame, msg...)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(frame))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("collector returned %d", resp.StatusCode)
+	}
+	// 正常响应的状态在 trailer 中；出错时服务端可能只返回头部（trailers-only）。
+	status, message := resp.Trailer.Get("Grpc-Status"), resp.Trailer.Get("Grpc-Message")
+	if status == "" {
+		status, message = resp.Header.Get("Grpc-Status"), resp.Header.Get("Grpc-Message")
+	}
+	switch status {
+	case "0":
+		return nil
+	case "":
+		return fmt.Errorf("collector response missing grpc-status")
+	}
+	if m, err := url.PathUnescape(message); err == nil {
+		message = m
+	}
+	return fmt.Errorf("collector returned grpc-status %s: %s", status, message)
+}
+
+// OTLP protobuf 编码（opentelemetry/proto/trace/v1），只包含导出用到的字段。
+func marshalOTLPProto(req otlpRequest) []byte {
+	var out []byte
+	for _, rs := range req.ResourceSpans {
+		out = appendProtoMessage(out, 1, marshalResourceSpans(rs))
+	}
+	return out
+}
+
+func marshalResourceSpans(rs otlpResourceSpans) []byte {
+	var resource []byte
+	for _, kv := range rs.Resource.Attributes {
+		resource = appendProtoMessage(resource, 1, marshalKeyValue(kv))
+	}
+	out := appendProtoMessage(nil, 1, resource)
+	for _, ss := range rs.ScopeSpans {
+		scope := appendProtoString(nil, 1, ss.Scope.Name)
+		b := appendProtoMessage(nil, 1, scope)
+		for _, s := range ss.Spans {
+			b = appendProtoMessage(b, 2, marshalSpan(s))
+		}
+		out = appendProtoMessage(out, 2, b)
+	}
+	return out
+}
+
+func marshalSpan(s otlpSpan) []byte {
+	var out []byte
+	out = appendProtoBytes(out, 1, hexBytes(s.TraceID))
+	out = appendProtoBytes(out, 2, hexBytes(s.SpanID))
+	out = appendProtoBytes(out, 4, hexBytes(s.ParentSpanID))
+	out = appendProtoString(out, 5, s.Name)
+	out = appendProtoVarint(out, 6, uint64(s.Kind))
+	out = appendProtoFixed64(out, 7, parseUint(s.StartTimeUnixNano))
+	out = appendProtoFixed64(out, 8, parseUint(s.EndTimeUnixNano))
+	for _, kv := range s.Attributes {
+		out = appendProtoMessage(out, 9, marshalKeyValue(kv))
+	}
+	var status []byte
+	status = appendProtoString(status, 2, s.Status.Message)
+	status = appendProtoVarint(status, 3, uint64(s.Status.Code))
+	return appendProtoMessage(out, 15, status)
+}
+
+func marshalKeyValue(kv otlpKeyValue) []byte {
+	out := appendProtoString(nil, 1, kv.Key)
+	// AnyValue 为 oneof，已设置的字段即使是零值也要写出。
+	var value []byte
+	v := kv.Value
+	switch {
+	case v.StringValue != nil:
+		value = appendProtoMessage(value, 1, []byte(*v.StringValue))
+	case v.BoolValue != nil:
+		value = protoTag(value, 2, 0)
+		if *v.BoolValue {
+			value = binary.AppendUvarint(value, 1)
+		} else {
+			value = binary.AppendUvarint(value, 0)
+		}
+	case v.IntValue != nil:
+		n, _ := strconv.ParseInt(*v.IntValue, 10, 64)
+		value = protoTag(value, 3, 0)
+		value = binary.AppendUvarint(value, uint64(n))
+	case v.DoubleValue != nil:
+		value = protoTag(value, 4, 1)
+		value = binary.LittleEndian.AppendUint64(value, math.Float64bits(*v.DoubleValue))
+	}
+	return appendProtoMessage(out, 2, value)
+}
+
+func protoTag(b []byte, field, wireType int) []byte {
+	return binary.AppendUvarint(b, uint64(field<<3|wireType))
+}
+
+// appendProtoMessage 写出长度前缀字段（嵌套消息），空消息也写出。
+func appendProtoMessage(b []byte, field int, msg []byte) []byte {
+	b = protoTag(b, field, 2)
+	b = binary.AppendUvarint(b, uint64(len(msg)))
+	return append(b, msg...)
+}
+
+// 以下标量字段为零值时按 proto3 省略。
+func appendProtoBytes(b []byte, field int, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	return appendProtoMessage(b, field, v)
+}
+
+func appendProtoString(b []byte, field int, v string) []byte {
+	return appendProtoBytes(b, field, []byte(v))
+}
+
+func appendProtoVarint(b []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	return binary.AppendUvarint(protoTag(b, field, 0), v)
+}
+
+func appendProtoFixed64(b []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	return binary.LittleEndian.AppendUint64(protoTag(b, field, 1), v)
+}
+
+func hexBytes(s string) []byte {
+	b, _ := hex.DecodeString(s)
+	return b
+}
+
+func parseUint(s string) uint64 {
+	n, _ := strconv.ParseUint(s, 10, 64)
+	return n
+}
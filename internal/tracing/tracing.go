@@ -0,0 +1,345 @@
+// Package tracing 提供轻量的分布式追踪：W3C traceparent 传播、按比例采样，
+// 并以 OTLP/HTTP（JSON 编码）或 OTLP/gRPC 批量导出到 OpenTelemetry Collector。
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TraceparentHeader W3C Trace Context 请求头。
+const TraceparentHeader = "traceparent"
+
+// SpanKind 与 OTLP 定义一致。
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+// statusError OTLP 错误状态码。
+const statusError = 2
+
+// TraceID 16 字节追踪 ID。
+type TraceID [16]byte
+
+// SpanID 8 字节 Span ID。
+type SpanID [8]byte
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+func (s SpanID) String() string  { return hex.EncodeToString(s[:]) }
+
+// IsValid 全零 ID 无效。
+func (t TraceID) IsValid() bool { return t != TraceID{} }
+func (s SpanID) IsValid() bool  { return s != SpanID{} }
+
+// SpanContext 跨进程传播的追踪上下文。
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+	Remote  bool
+}
+
+// IsValid 判断上下文是否有效。
+func (sc SpanContext) IsValid() bool { return sc.TraceID.IsValid() && sc.SpanID.IsValid() }
+
+// Span 一段计时操作。nil Span 的所有方法均为空操作。
+type Span struct {
+	tracer *Tracer
+	sc     SpanContext
+	parent SpanID
+	name   string
+	kind   SpanKind
+	start  time.Time
+
+	mu         sync.Mutex
+	end        time.Time
+	attrs      map[string]any
+	statusCode int
+	statusMsg  string
+	ended      bool
+}
+
+type spanKey struct{}
+
+// SpanFromContext 返回 ctx 中的当前 Span。
+func SpanFromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// ContextWithSpan 返回以 s 为当前 Span 的 ctx。
+func ContextWithSpan(ctx context.Context, s *Span) context.Context {
+	if s == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, spanKey{}, s)
+}
+
+type remoteKey struct{}
+
+// ContextWithRemote 保存从请求头解析出的上游追踪上下文。
+func ContextWithRemote(ctx context.Context, sc SpanContext) context.Context {
+	if !sc.IsValid() {
+		return ctx
+	}
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// SpanContext 返回 Span 的追踪上下文。
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// SetAttr 设置属性，支持 string/bool/int/int64/float64。
+func (s *Span) SetAttr(key string, value any) {
+	if s == nil || !s.sc.Sampled {
+		return
+	}
+	s.mu.Lock()
+	if s.attrs == nil {
+		s.attrs = make(map[string]any)
+	}
+	s.attrs[key] = value
+	s.mu.Unlock()
+}
+
+// SetError 标记 Span 失败。
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.SetStatusError(err.Error())
+}
+
+// SetStatusError 以文本标记 Span 失败。
+func (s *Span) SetStatusError(msg string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.statusCode = statusError
+	s.statusMsg = msg
+	s.mu.Unlock()
+}
+
+// End 结束 Span 并交给导出器，重复调用无效。
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mu.Unlock()
+	if s.sc.Sampled && s.tracer != nil {
+		s.tracer.export(s)
+	}
+}
+
+// 导出协议。
+const (
+	ProtocolHTTPJSON = "http/json"
+	ProtocolGRPC     = "grpc"
+)
+
+// Config 追踪配置。
+type Config struct {
+	Enabled     bool
+	Endpoint    string            // Collector 地址，如 http://otel-collector:4318（HTTP）或 http://otel-collector:4317（gRPC）
+	Protocol    string            // ProtocolHTTPJSON（默认）或 ProtocolGRPC
+	ServiceName string            // resource service.name
+	SampleRatio float64           // 根 Span 采样比例 0~1
+	Headers     map[string]string // 导出请求附加头（如认证）
+	Timeout     time.Duration     // 导出超时
+}
+
+// Tracer 创建 Span 并异步导出。nil 或未启用时只做上下文传播，不记录 Span。
+type Tracer struct {
+	cfg      Config
+	ratio    atomic.Uint64 // math.Float64bits(SampleRatio)
+	exporter *exporter
+}
+
+// New 创建 Tracer；未启用时返回 nil。
+func New(cfg Config, logf func(format string, v ...any)) *Tracer {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = "qcc_plus"
+	}
+	t := &Tracer{cfg: cfg}
+	t.SetSampleRatio(cfg.SampleRatio)
+	if cfg.Endpoint != "" {
+		t.exporter = newExporter(cfg, logf)
+	}
+	return t
+}
+
+// SetSampleRatio 运行时调整采样比例。
+func (t *Tracer) SetSampleRatio(r float64) {
+	if t == nil {
+		return
+	}
+	if r < 0 || math.IsNaN(r) {
+		r = 0
+	}
+	if r > 1 {
+		r = 1
+	}
+	t.ratio.Store(math.Float64bits(r))
+}
+
+// SampleRatio 返回当前采样比例。
+func (t *Tracer) SampleRatio() float64 {
+	if t == nil {
+		return 0
+	}
+	return math.Float64frombits(t.ratio.Load())
+}
+
+// Shutdown 导出剩余 Span 并停止后台协程。
+func (t *Tracer) Shutdown() {
+	if t == nil || t.exporter == nil {
+		return
+	}
+	t.exporter.stop()
+}
+
+// Start 创建子 Span：优先以 ctx 中的 Span 为父，其次为远端上下文，否则新建追踪。
+// 采样遵循父 Span 的决定，根 Span 按 trace ID 与采样比例决定。
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	s := &Span{tracer: t, name: name, kind: kind, start: time.Now()}
+	if parent := SpanFromContext(ctx); parent != nil {
+		s.sc.TraceID = parent.sc.TraceID
+		s.sc.Sampled = parent.sc.Sampled
+		s.parent = parent.sc.SpanID
+	} else if remote, ok := ctx.Value(remoteKey{}).(SpanContext); ok && remote.IsValid() {
+		s.sc.TraceID = remote.TraceID
+		s.sc.Sampled = remote.Sampled
+		s.parent = remote.SpanID
+	} else {
+		s.sc.TraceID = newTraceID()
+		s.sc.Sampled = t.shouldSample(s.sc.TraceID)
+	}
+	s.sc.SpanID = newSpanID()
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+func (t *Tracer) shouldSample(id TraceID) bool {
+	r := t.SampleRatio()
+	if r >= 1 {
+		return true
+	}
+	if r <= 0 {
+		return false
+	}
+	// 与 OpenTelemetry TraceIDRatioBased 一致：取 trace ID 低 8 字节比较
+	bound := uint64(r * (1 << 63))
+	return binary.BigEndian.Uint64(id[8:])>>1 < bound
+}
+
+func (t *Tracer) export(s *Span) {
+	if t.exporter != nil {
+		t.exporter.enqueue(s)
+	}
+}
+
+// Extract 解析请求头中的 traceparent，返回带远端上下文的 ctx。
+func Extract(ctx context.Context, h http.Header) context.Context {
+	sc, ok := ParseTraceparent(h.Get(TraceparentHeader))
+	if !ok {
+		return ctx
+	}
+	return ContextWithRemote(ctx, sc)
+}
+
+// Inject 将 ctx 中当前 Span（或远端上下文）写入 traceparent 头。
+func Inject(ctx context.Context, h http.Header) {
+	var sc SpanContext
+	if s := SpanFromContext(ctx); s != nil {
+		sc = s.sc
+	} else if remote, ok := ctx.Value(remoteKey{}).(SpanContext); ok {
+		sc = remote
+	}
+	if !sc.IsValid() {
+		return
+	}
+	h.Set(TraceparentHeader, FormatTraceparent(sc))
+}
+
+// ParseTraceparent 解析 version-traceid-spanid-flags 格式。
+func ParseTraceparent(v string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(v), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}, false
+	}
+	var sc SpanContext
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return SpanContext{}, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return SpanContext{}, false
+	}
+	var flags [1]byte
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return SpanContext{}, false
+	}
+	sc.Sampled = flags[0]&0x01 == 1
+	sc.Remote = true
+	return sc, sc.IsValid()
+}
+
+// FormatTraceparent 生成 traceparent 头。
+func FormatTraceparent(sc SpanContext) string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags)
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
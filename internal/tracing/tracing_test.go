@@ -0,0 +1,242 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+// TestTraceparentRoundTrip 测试 traceparent 解析与格式化
+func TestTraceparentRoundTrip(t *testing.T) {
+	sc, ok := ParseTraceparent(testTraceparent)
+	if !ok || !sc.Sampled || !sc.Remote {
+		t.Fatalf("unexpected parse result: %+v ok=%v", sc, ok)
+	}
+	if got := FormatTraceparent(sc); got != testTraceparent {
+		t.Fatalf("round trip mismatch: %s", got)
+	}
+	for _, bad := range []string{
+		"",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-zzf067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+	} {
+		if _, ok := ParseTraceparent(bad); ok {
+			t.Errorf("expected %q rejected", bad)
+		}
+	}
+}
+
+// TestStartContinuesRemoteTrace 测试子 Span 继承远端追踪与采样决定
+func TestStartContinuesRemoteTrace(t *testing.T) {
+	tr := New(Config{Enabled: true, SampleRatio: 0}, nil)
+	h := http.Header{}
+	h.Set(TraceparentHeader, testTraceparent)
+	ctx, root := tr.Start(Extract(context.Background(), h), "root", SpanKindServer)
+	_, child := tr.Start(ctx, "child", SpanKindClient)
+
+	remote, _ := ParseTraceparent(testTraceparent)
+	if root.sc.TraceID != remote.TraceID || root.parent != remote.SpanID || !root.sc.Sampled {
+		t.Fatalf("root should continue remote trace: %+v", root.sc)
+	}
+	if child.sc.TraceID != remote.TraceID || child.parent != root.sc.SpanID {
+		t.Fatalf("child should be parented to root")
+	}
+
+	out := http.Header{}
+	Inject(ContextWithSpan(context.Background(), child), out)
+	sc, ok := ParseTraceparent(out.Get(TraceparentHeader))
+	if !ok || sc.SpanID != child.sc.SpanID {
+		t.Fatalf("unexpected injected header %q", out.Get(TraceparentHeader))
+	}
+}
+
+// TestSampleRatio 测试根 Span 采样比例及 nil Tracer 行为
+func TestSampleRatio(t *testing.T) {
+	tr := New(Config{Enabled: true, SampleRatio: 0}, nil)
+	if _, s := tr.Start(context.Background(), "x", SpanKindInternal); s.sc.Sampled {
+		t.Fatalf("ratio 0 should not sample")
+	}
+	tr.SetSampleRatio(5)
+	if tr.SampleRatio() != 1 {
+		t.Fatalf("ratio should be clamped to 1, got %v", tr.SampleRatio())
+	}
+	if _, s := tr.Start(context.Background(), "x", SpanKindInternal); !s.sc.Sampled {
+		t.Fatalf("ratio 1 should sample")
+	}
+
+	var nilTracer *Tracer
+	ctx, s := nilTracer.Start(context.Background(), "x", SpanKindInternal)
+	s.SetAttr("k", "v")
+	s.End()
+	if SpanFromContext(ctx) != nil {
+		t.Fatalf("nil tracer should not create spans")
+	}
+	if New(Config{}, nil) != nil {
+		t.Fatalf("disabled config should return nil tracer")
+	}
+}
+
+// TestExporterSendsOTLP 测试 Shutdown 时将 Span 以 OTLP JSON 发送到 Collector
+func TestExporterSendsOTLP(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		body []byte
+		path string
+		auth string
+	)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		body, _ = io.ReadAll(r.Body)
+		path = r.URL.Path
+		auth = r.Header.Get("Authorization")
+	}))
+	defer collector.Close()
+
+	tr := New(Config{
+		Enabled:     true,
+		Endpoint:    collector.URL,
+		ServiceName: "svc",
+		SampleRatio: 1,
+		Headers:     map[string]string{"Authorization": "Bearer t"},
+	}, nil)
+	ctx, root := tr.Start(context.Background(), "root", SpanKindServer)
+	_, child := tr.Start(ctx, "child", SpanKindClient)
+	child.SetAttr("node.id", "n1")
+	child.SetAttr("attempt", 2)
+	child.SetError(errors.New("boom"))
+	child.End()
+	root.End()
+	tr.Shutdown()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if path != "/v1/traces" || auth != "Bearer t" {
+		t.Fatalf("unexpected export request path=%q auth=%q", path, auth)
+	}
+	var req otlpRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 || *req.ResourceSpans[0].Resource.Attributes[0].Value.StringValue != "svc" {
+		t.Fatalf("unexpected payload: %s", body)
+	}
+	got := spans[0]
+	if got.Name != "child" || got.ParentSpanID != root.sc.SpanID.String() || got.Status.Code != statusError {
+		t.Fatalf("unexpected child span: %+v", got)
+	}
+	if len(got.Attributes) != 2 || got.Attributes[0].Key != "attempt" || *got.Attributes[0].Value.IntValue != "2" {
+		t.Fatalf("unexpected attributes: %+v", got.Attributes)
+	}
+}
+
+// TestExporterSendsOTLPGRPC 测试 gRPC 协议通过明文 HTTP/2 调用 TraceService/Export，并按 grpc-status 判断结果
+func TestExporterSendsOTLPGRPC(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		frame  []byte
+		path   string
+		proto  string
+		ctype  string
+		status = "0"
+	)
+	collector := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		frame, _ = io.ReadAll(r.Body)
+		path, proto, ctype = r.URL.Path, r.Proto, r.Header.Get("Content-Type")
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+		w.Write([]byte{0, 0, 0, 0, 0})
+		w.Header().Set("Grpc-Status", status)
+		w.Header().Set("Grpc-Message", "bad%20request")
+	}))
+	collector.Config.Protocols = new(http.Protocols)
+	collector.Config.Protocols.SetHTTP1(true)
+	collector.Config.Protocols.SetUnencryptedHTTP2(true)
+	collector.Start()
+	defer collector.Close()
+
+	cfg := Config{Enabled: true, Endpoint: collector.URL, Protocol: ProtocolGRPC, ServiceName: "svc", SampleRatio: 1}
+	tr := New(cfg, nil)
+	ctx, root := tr.Start(context.Background(), "root", SpanKindServer)
+	_, child := tr.Start(ctx, "child", SpanKindClient)
+	child.SetAttr("attempt", 2)
+	child.End()
+	root.End()
+	tr.Shutdown()
+
+	mu.Lock()
+	if path != grpcExportMethod || proto != "HTTP/2.0" || ctype != "application/grpc" {
+		t.Fatalf("unexpected export request path=%q proto=%q content-type=%q", path, proto, ctype)
+	}
+	if len(frame) < 5 || frame[0] != 0 || int(binary.BigEndian.Uint32(frame[1:5])) != len(frame)-5 {
+		t.Fatalf("invalid gRPC frame: %x", frame)
+	}
+	msg := frame[5:]
+	mu.Unlock()
+
+	rs := protoFields(t, protoFields(t, msg)[1][0])
+	spans := protoFields(t, rs[2][0])[2]
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	span := protoFields(t, spans[0])
+	if string(span[5][0]) != "child" || !bytes.Equal(span[4][0], root.sc.SpanID[:]) || !bytes.Equal(span[1][0], root.sc.TraceID[:]) {
+		t.Fatalf("unexpected child span fields: %v", span)
+	}
+	attr := protoFields(t, span[9][0])
+	if string(attr[1][0]) != "attempt" || !bytes.Equal(attr[2][0], []byte{3 << 3, 2}) {
+		t.Fatalf("unexpected attribute encoding: %v", attr)
+	}
+
+	mu.Lock()
+	status = "3"
+	mu.Unlock()
+	e := newExporter(cfg, nil)
+	defer e.stop()
+	_, s := tr.Start(context.Background(), "x", SpanKindInternal)
+	s.End()
+	if err := e.send([]*Span{s}); err == nil || !strings.Contains(err.Error(), "grpc-status 3: bad request") {
+		t.Fatalf("expected grpc-status error, got %v", err)
+	}
+}
+
+// protoFields 解析一层 protobuf 消息，返回长度前缀字段的原始内容（varint 字段以编码后的字节返回）。
+func protoFields(t *testing.T, b []byte) map[int][][]byte {
+	t.Helper()
+	out := make(map[int][][]byte)
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		b = b[n:]
+		field := int(tag >> 3)
+		switch tag & 7 {
+		case 0:
+			_, n = binary.Uvarint(b)
+		case 1:
+			n = 8
+		case 2:
+			l, m := binary.Uvarint(b)
+			b = b[m:]
+			n = int(l)
+		default:
+			t.Fatalf("unexpected wire type %d", tag&7)
+		}
+		out[field] = append(out[field], b[:n])
+		b = b[n:]
+	}
+	return out
+}
@@ -2,13 +2,12 @@ package healthcheckcli
 
 import (
 	"context"
-	"io"
-	"log"
 	"net/http"
 	"net/http/httptest"
 	"os/exec"
 	"testing"
 
+	"qcc_plus/internal/logging"
 	"qcc_plus/internal/proxy"
 	"qcc_plus/internal/store"
 )
@@ -18,7 +17,7 @@ func newProxyForTest(t *testing.T, upstream string, runner proxy.CliRunner) *pro
 	builder := proxy.NewBuilder().
 		WithUpstream(upstream).
 		WithAPIKey("sk-test").
-		WithLogger(log.New(io.Discard, "", 0))
+		WithLogger(logging.Discard())
 	if runner != nil {
 		builder = builder.WithCLIRunner(runner)
 	}